@@ -92,10 +92,20 @@ func (client *Client) Change(id string) (*Change, error) {
 
 // Abort attempts to abort a change that is in not yet ready.
 func (client *Client) Abort(id string) (*Change, error) {
+	return client.changeAction(id, "abort")
+}
+
+// Retry attempts to run again a failed change, starting from the tasks
+// that failed and keeping the work that was already done.
+func (client *Client) Retry(id string) (*Change, error) {
+	return client.changeAction(id, "retry")
+}
+
+func (client *Client) changeAction(id, action string) (*Change, error) {
 	var postData struct {
 		Action string `json:"action"`
 	}
-	postData.Action = "abort"
+	postData.Action = action
 
 	var body bytes.Buffer
 	if err := json.NewEncoder(&body).Encode(postData); err != nil {
@@ -120,6 +130,8 @@ func (c ChangeSelector) String() string {
 		return "ready"
 	case ChangesAll:
 		return "all"
+	case ChangesArchived:
+		return "archived"
 	}
 
 	panic(fmt.Sprintf("unknown ChangeSelector %d", c))
@@ -128,12 +140,17 @@ func (c ChangeSelector) String() string {
 const (
 	ChangesInProgress ChangeSelector = 1 << iota
 	ChangesReady
+	// ChangesArchived selects the changes that were pruned from the
+	// system state, and archived.
+	ChangesArchived
 	ChangesAll = ChangesReady | ChangesInProgress
 )
 
 type ChangesOptions struct {
 	SnapName string // if empty, no filtering by name is done
 	Selector ChangeSelector
+	Kind     string    // if empty, no filtering by kind is done
+	Since    time.Time // if set, only changes not ready yet at that time are returned
 }
 
 func (client *Client) Changes(opts *ChangesOptions) ([]*Change, error) {
@@ -145,6 +162,12 @@ func (client *Client) Changes(opts *ChangesOptions) ([]*Change, error) {
 		if opts.SnapName != "" {
 			query.Set("for", opts.SnapName)
 		}
+		if opts.Kind != "" {
+			query.Set("kind", opts.Kind)
+		}
+		if !opts.Since.IsZero() {
+			query.Set("since", opts.Since.Format(time.RFC3339Nano))
+		}
 	}
 
 	var chgds []changeAndData
@@ -21,6 +21,7 @@ package client_test
 
 import (
 	"io/ioutil"
+	"net/url"
 	"time"
 
 	"gopkg.in/check.v1"
@@ -135,6 +136,7 @@ func (cs *clientSuite) TestClientChangesString(c *check.C) {
 		client.ChangesAll:        "all",
 		client.ChangesReady:      "ready",
 		client.ChangesInProgress: "in-progress",
+		client.ChangesArchived:   "archived",
 	} {
 		c.Check(k.String(), check.Equals, v)
 	}
@@ -179,6 +181,25 @@ func (cs *clientSuite) TestClientChanges(c *check.C) {
 
 }
 
+func (cs *clientSuite) TestClientChangesArchived(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": []}`
+
+	_, err := cs.cli.Changes(&client.ChangesOptions{
+		Selector: client.ChangesArchived,
+		SnapName: "foo",
+		Kind:     "install-snap",
+		Since:    time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC),
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/changes")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"select": {"archived"},
+		"for":    {"foo"},
+		"kind":   {"install-snap"},
+		"since":  {"2022-03-01T12:00:00Z"},
+	})
+}
+
 func (cs *clientSuite) TestClientChangesData(c *check.C) {
 	cs.rsp = `{"type": "sync", "result": [{
   "id":   "uno",
@@ -232,3 +253,31 @@ func (cs *clientSuite) TestClientAbort(c *check.C) {
 
 	c.Assert(string(body), check.Equals, "{\"action\":\"abort\"}\n")
 }
+
+func (cs *clientSuite) TestClientRetry(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {
+  "id":   "uno",
+  "kind": "foo",
+  "summary": "...",
+  "status": "Do",
+  "spawn-time": "2016-04-21T01:02:03Z"
+}}`
+
+	chg, err := cs.cli.Retry("uno")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/changes/uno")
+	c.Check(chg, check.DeepEquals, &client.Change{
+		ID:      "uno",
+		Kind:    "foo",
+		Summary: "...",
+		Status:  "Do",
+
+		SpawnTime: time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC),
+	})
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+
+	c.Assert(string(body), check.Equals, "{\"action\":\"retry\"}\n")
+}
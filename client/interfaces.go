@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"net/url"
 	"strings"
+	"time"
 )
 
 // Plug represents the potential of a given snap to connect to a slot.
@@ -75,6 +76,7 @@ type InterfaceAction struct {
 	Forget bool   `json:"forget,omitempty"`
 	Plugs  []Plug `json:"plugs,omitempty"`
 	Slots  []Slot `json:"slots,omitempty"`
+	For    string `json:"for,omitempty"`
 }
 
 // InterfaceOptions represents opt-in elements include in responses.
@@ -86,6 +88,13 @@ type InterfaceOptions struct {
 	Connected bool
 }
 
+// ConnectOptions represents extra options for connect op
+type ConnectOptions struct {
+	// For limits the duration of the connection, after which it is
+	// automatically disconnected.
+	For time.Duration
+}
+
 // DisconnectOptions represents extra options for disconnect op
 type DisconnectOptions struct {
 	Forget bool
@@ -130,12 +139,16 @@ func (client *Client) performInterfaceAction(sa *InterfaceAction) (changeID stri
 
 // Connect establishes a connection between a plug and a slot.
 // The plug and the slot must have the same interface.
-func (client *Client) Connect(plugSnapName, plugName, slotSnapName, slotName string) (changeID string, err error) {
-	return client.performInterfaceAction(&InterfaceAction{
+func (client *Client) Connect(plugSnapName, plugName, slotSnapName, slotName string, opts *ConnectOptions) (changeID string, err error) {
+	action := &InterfaceAction{
 		Action: "connect",
 		Plugs:  []Plug{{Snap: plugSnapName, Name: plugName}},
 		Slots:  []Slot{{Snap: slotSnapName, Name: slotName}},
-	})
+	}
+	if opts != nil && opts.For > 0 {
+		action.For = opts.For.String()
+	}
+	return client.performInterfaceAction(action)
 }
 
 // Disconnect breaks the connection between a plug and a slot.
@@ -147,3 +160,68 @@ func (client *Client) Disconnect(plugSnapName, plugName, slotSnapName, slotName
 		Slots:  []Slot{{Snap: slotSnapName, Name: slotName}},
 	})
 }
+
+// ConnectionExplanation describes how the policies for connecting a plug to
+// a slot were evaluated.
+type ConnectionExplanation struct {
+	Plug      PlugRef `json:"plug"`
+	Slot      SlotRef `json:"slot"`
+	Interface string  `json:"interface"`
+	// Unasserted lists the snaps without a snap-declaration, manual
+	// connections involving them are not checked against the policy.
+	Unasserted []string `json:"unasserted,omitempty"`
+	// Connection is obeyed by manual connections and connections by
+	// the gadget, AutoConnection by other auto-connections.
+	Connection     *PolicyExplanation `json:"connection"`
+	AutoConnection *PolicyExplanation `json:"auto-connection"`
+}
+
+// PolicyExplanation describes the evaluation of the connection or
+// auto-connection rules of the declarations.
+type PolicyExplanation struct {
+	Allowed bool               `json:"allowed"`
+	Error   string             `json:"error,omitempty"`
+	Rules   []*RuleExplanation `json:"rules"`
+}
+
+// RuleExplanation describes the evaluation of the plug or slot rule for the
+// interface of a declaration, either the snap-declaration of the plug or
+// slot snap or the base-declaration.
+type RuleExplanation struct {
+	Declaration string                    `json:"declaration"`
+	Snap        string                    `json:"snap,omitempty"`
+	Side        string                    `json:"side"`
+	Found       bool                      `json:"found"`
+	Verdict     string                    `json:"verdict,omitempty"`
+	Deny        []*ConstraintsExplanation `json:"deny,omitempty"`
+	Allow       []*ConstraintsExplanation `json:"allow,omitempty"`
+}
+
+// ConstraintsExplanation describes the evaluation of one of the alternative
+// sets of constraints of a rule.
+type ConstraintsExplanation struct {
+	Matched     bool                     `json:"matched"`
+	Constraints []*ConstraintExplanation `json:"constraints,omitempty"`
+}
+
+// ConstraintExplanation describes the evaluation of a single constraint.
+type ConstraintExplanation struct {
+	Name    string `json:"name"`
+	Matched bool   `json:"matched"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ExplainConnection describes how the policies for connecting the plug to
+// the slot are evaluated, without connecting them.
+func (client *Client) ExplainConnection(plugSnapName, plugName, slotSnapName, slotName string) (*ConnectionExplanation, error) {
+	query := url.Values{
+		"select": []string{"explain"},
+		"plug":   []string{plugSnapName + ":" + plugName},
+		"slot":   []string{slotSnapName + ":" + slotName},
+	}
+	var expl ConnectionExplanation
+	if _, err := client.doSync("GET", "/v2/interfaces", query, nil, nil, &expl); err != nil {
+		return nil, err
+	}
+	return &expl, nil
+}
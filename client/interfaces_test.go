@@ -21,6 +21,8 @@ package client_test
 
 import (
 	"encoding/json"
+	"net/url"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -157,7 +159,7 @@ func (cs *clientSuite) TestClientInterfacesMultiple(c *check.C) {
 }
 
 func (cs *clientSuite) TestClientConnectCallsEndpoint(c *check.C) {
-	cs.cli.Connect("producer", "plug", "consumer", "slot")
+	cs.cli.Connect("producer", "plug", "consumer", "slot", nil)
 	c.Check(cs.req.Method, check.Equals, "POST")
 	c.Check(cs.req.URL.Path, check.Equals, "/v2/interfaces")
 }
@@ -170,7 +172,7 @@ func (cs *clientSuite) TestClientConnect(c *check.C) {
 		"result": { },
                 "change": "foo"
 	}`
-	id, err := cs.cli.Connect("producer", "plug", "consumer", "slot")
+	id, err := cs.cli.Connect("producer", "plug", "consumer", "slot", nil)
 	c.Assert(err, check.IsNil)
 	c.Check(id, check.Equals, "foo")
 	var body map[string]interface{}
@@ -194,6 +196,40 @@ func (cs *clientSuite) TestClientConnect(c *check.C) {
 	})
 }
 
+func (cs *clientSuite) TestClientConnectFor(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": { },
+		"change": "foo"
+	}`
+	opts := &client.ConnectOptions{For: 2 * time.Hour}
+	id, err := cs.cli.Connect("producer", "plug", "consumer", "slot", opts)
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "foo")
+	var body map[string]interface{}
+	decoder := json.NewDecoder(cs.req.Body)
+	err = decoder.Decode(&body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "connect",
+		"for":    "2h0m0s",
+		"plugs": []interface{}{
+			map[string]interface{}{
+				"snap": "producer",
+				"plug": "plug",
+			},
+		},
+		"slots": []interface{}{
+			map[string]interface{}{
+				"snap": "consumer",
+				"slot": "slot",
+			},
+		},
+	})
+}
+
 func (cs *clientSuite) TestClientDisconnectCallsEndpoint(c *check.C) {
 	cs.cli.Disconnect("producer", "plug", "consumer", "slot", nil)
 	c.Check(cs.req.Method, check.Equals, "POST")
@@ -266,3 +302,54 @@ func (cs *clientSuite) TestClientDisconnectForget(c *check.C) {
 		},
 	})
 }
+
+func (cs *clientSuite) TestClientExplainConnection(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {
+			"plug": {"snap": "consumer", "plug": "plug"},
+			"slot": {"snap": "producer", "slot": "slot"},
+			"interface": "test",
+			"unasserted": ["consumer"],
+			"connection": {
+				"allowed": false,
+				"error": "connection not allowed by slot rule of interface \"test\"",
+				"rules": [
+					{"declaration": "snap-declaration", "snap": "producer", "side": "slot", "found": false},
+					{"declaration": "base-declaration", "side": "plug", "found": false},
+					{"declaration": "base-declaration", "side": "slot", "found": true, "verdict": "not-allowed",
+					 "allow": [{"matched": false, "constraints": [{"name": "plug-snap-type", "matched": false, "error": "snap type does not match"}]}]}
+				]
+			},
+			"auto-connection": {"allowed": true, "rules": []}
+		}
+	}`
+	expl, err := cs.cli.ExplainConnection("consumer", "plug", "producer", "")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/interfaces")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"select": []string{"explain"},
+		"plug":   []string{"consumer:plug"},
+		"slot":   []string{"producer:"},
+	})
+	c.Check(expl, check.DeepEquals, &client.ConnectionExplanation{
+		Plug:       client.PlugRef{Snap: "consumer", Name: "plug"},
+		Slot:       client.SlotRef{Snap: "producer", Name: "slot"},
+		Interface:  "test",
+		Unasserted: []string{"consumer"},
+		Connection: &client.PolicyExplanation{
+			Error: `connection not allowed by slot rule of interface "test"`,
+			Rules: []*client.RuleExplanation{
+				{Declaration: "snap-declaration", Snap: "producer", Side: "slot"},
+				{Declaration: "base-declaration", Side: "plug"},
+				{Declaration: "base-declaration", Side: "slot", Found: true, Verdict: "not-allowed",
+					Allow: []*client.ConstraintsExplanation{{
+						Constraints: []*client.ConstraintExplanation{{Name: "plug-snap-type", Error: "snap type does not match"}},
+					}},
+				},
+			},
+		},
+		AutoConnection: &client.PolicyExplanation{Allowed: true, Rules: []*client.RuleExplanation{}},
+	})
+}
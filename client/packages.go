@@ -178,6 +178,59 @@ func (client *Client) List(names []string, opts *ListOptions) ([]*Snap, error) {
 	return snaps, nil
 }
 
+// RefreshCandidateInterface identifies a plug or slot of a refresh candidate.
+type RefreshCandidateInterface struct {
+	Name      string `json:"name"`
+	Interface string `json:"interface"`
+}
+
+// RefreshCandidate describes what refreshing an installed snap to the
+// revision offered by the store would change.
+type RefreshCandidate struct {
+	Name            string        `json:"name"`
+	Version         string        `json:"version"`
+	Revision        snap.Revision `json:"revision"`
+	CurrentVersion  string        `json:"current-version"`
+	CurrentRevision snap.Revision `json:"current-revision"`
+	Base            string        `json:"base,omitempty"`
+	CurrentBase     string        `json:"current-base,omitempty"`
+
+	DownloadSize int64 `json:"download-size"`
+	// DeltaSize is the size of the delta from the current revision, if the
+	// store offers one.
+	DeltaSize int64 `json:"delta-size,omitempty"`
+
+	PlugsAdded   []RefreshCandidateInterface `json:"plugs-added,omitempty"`
+	PlugsRemoved []RefreshCandidateInterface `json:"plugs-removed,omitempty"`
+	SlotsAdded   []RefreshCandidateInterface `json:"slots-added,omitempty"`
+	SlotsRemoved []RefreshCandidateInterface `json:"slots-removed,omitempty"`
+
+	ServicesAdded   []string `json:"services-added,omitempty"`
+	ServicesRemoved []string `json:"services-removed,omitempty"`
+	HooksAdded      []string `json:"hooks-added,omitempty"`
+	HooksRemoved    []string `json:"hooks-removed,omitempty"`
+
+	ContentProvidersAdded   []string `json:"content-providers-added,omitempty"`
+	ContentProvidersRemoved []string `json:"content-providers-removed,omitempty"`
+
+	// HeldBy lists the gating snaps, or "system" for the user, holding
+	// the refresh.
+	HeldBy []string `json:"held-by,omitempty"`
+}
+
+// RefreshCandidates returns what refreshing the installed snaps to the
+// revisions offered by the store would change.
+func (client *Client) RefreshCandidates() ([]*RefreshCandidate, error) {
+	q := url.Values{"select": []string{"refresh-candidates"}}
+
+	var candidates []*RefreshCandidate
+	if _, err := client.doSync("GET", "/v2/snaps", q, nil, nil, &candidates); err != nil {
+		fmt := "cannot list refresh candidates: %w"
+		return nil, xerrors.Errorf(fmt, err)
+	}
+	return candidates, nil
+}
+
 // Sections returns the list of existing snap sections in the store
 func (client *Client) Sections() ([]string, error) {
 	var sections []string
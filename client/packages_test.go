@@ -405,3 +405,49 @@ func (cs *clientSuite) TestClientFindFromPathErrIsWrapped(c *check.C) {
 	_, err = cs.cli.List([]string{"snap"}, nil)
 	c.Assert(xerrors.As(err, &e), check.Equals, true)
 }
+
+func (cs *clientSuite) TestClientRefreshCandidates(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": [{
+			"name": "foo",
+			"version": "2.0",
+			"revision": "12",
+			"current-version": "1.0",
+			"current-revision": "11",
+			"base": "core22",
+			"current-base": "core20",
+			"download-size": 10000,
+			"delta-size": 1000,
+			"plugs-added": [{"name": "camera", "interface": "camera"}],
+			"services-added": ["daemon"],
+			"held-by": ["bar"]
+		}]
+	}`
+	candidates, err := cs.cli.RefreshCandidates()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	c.Check(cs.req.URL.RawQuery, check.Equals, "select=refresh-candidates")
+	c.Check(candidates, check.DeepEquals, []*client.RefreshCandidate{{
+		Name:            "foo",
+		Version:         "2.0",
+		Revision:        snap.R(12),
+		CurrentVersion:  "1.0",
+		CurrentRevision: snap.R(11),
+		Base:            "core22",
+		CurrentBase:     "core20",
+		DownloadSize:    10000,
+		DeltaSize:       1000,
+		PlugsAdded:      []client.RefreshCandidateInterface{{Name: "camera", Interface: "camera"}},
+		ServicesAdded:   []string{"daemon"},
+		HeldBy:          []string{"bar"},
+	}})
+}
+
+func (cs *clientSuite) TestClientRefreshCandidatesErrIsWrapped(c *check.C) {
+	cs.err = errors.New("boom")
+	_, err := cs.cli.RefreshCandidates()
+	var e xerrors.Wrapper
+	c.Assert(err, check.Implements, &e)
+}
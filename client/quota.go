@@ -29,11 +29,12 @@ import (
 )
 
 type postQuotaData struct {
-	Action      string       `json:"action"`
-	GroupName   string       `json:"group-name"`
-	Parent      string       `json:"parent,omitempty"`
-	Snaps       []string     `json:"snaps,omitempty"`
-	Constraints *QuotaValues `json:"constraints,omitempty"`
+	Action      string            `json:"action"`
+	GroupName   string            `json:"group-name"`
+	Parent      string            `json:"parent,omitempty"`
+	Snaps       []string          `json:"snaps,omitempty"`
+	Constraints *QuotaValues      `json:"constraints,omitempty"`
+	LimitPolicy *QuotaLimitPolicy `json:"limit-policy,omitempty"`
 }
 
 type QuotaGroupResult struct {
@@ -43,6 +44,24 @@ type QuotaGroupResult struct {
 	Snaps       []string     `json:"snaps,omitempty"`
 	Constraints *QuotaValues `json:"constraints,omitempty"`
 	Current     *QuotaValues `json:"current,omitempty"`
+	// History is the resource usage of the group sampled periodically,
+	// ordered from the oldest to the newest sample.
+	History []QuotaUsageSample `json:"history,omitempty"`
+	// LimitPolicy is what snapd does when the group hits its limits.
+	LimitPolicy *QuotaLimitPolicy `json:"limit-policy,omitempty"`
+}
+
+type QuotaLimitPolicy struct {
+	Action        string        `json:"action"`
+	MemoryCeiling quantity.Size `json:"memory-ceiling,omitempty"`
+}
+
+type QuotaUsageSample struct {
+	Time        time.Time     `json:"time"`
+	CPUTime     time.Duration `json:"cpu-time,omitempty"`
+	Memory      quantity.Size `json:"memory,omitempty"`
+	Threads     int           `json:"threads,omitempty"`
+	JournalSize quantity.Size `json:"journal-size,omitempty"`
 }
 
 type QuotaCPUValues struct {
@@ -60,12 +79,24 @@ type QuotaJournalValues struct {
 	RatePeriod time.Duration `json:"rate-period,omitempty"`
 }
 
+type QuotaIOValues struct {
+	Device         string        `json:"device,omitempty"`
+	ReadBandwidth  quantity.Size `json:"read-bandwidth,omitempty"`
+	WriteBandwidth quantity.Size `json:"write-bandwidth,omitempty"`
+	ReadIOPS       int           `json:"read-iops,omitempty"`
+	WriteIOPS      int           `json:"write-iops,omitempty"`
+	Weight         int           `json:"weight,omitempty"`
+	// Unset lists the io limits to remove from an existing group.
+	Unset []string `json:"unset,omitempty"`
+}
+
 type QuotaValues struct {
 	Memory  quantity.Size       `json:"memory,omitempty"`
 	CPU     *QuotaCPUValues     `json:"cpu,omitempty"`
 	CPUSet  *QuotaCPUSetValues  `json:"cpu-set,omitempty"`
 	Threads int                 `json:"threads,omitempty"`
 	Journal *QuotaJournalValues `json:"journal,omitempty"`
+	IO      *QuotaIOValues      `json:"io,omitempty"`
 }
 
 // EnsureQuota creates a quota group or updates an existing group.
@@ -96,6 +127,29 @@ func (client *Client) EnsureQuota(groupName string, parent string, snaps []strin
 	return chgID, nil
 }
 
+// SetQuotaLimitPolicy sets the policy of an existing quota group for when it
+// hits its limits.
+func (client *Client) SetQuotaLimitPolicy(groupName string, policy *QuotaLimitPolicy) (changeID string, err error) {
+	if groupName == "" {
+		return "", fmt.Errorf("cannot set quota group limit policy without a name")
+	}
+	if policy == nil {
+		return "", fmt.Errorf("cannot set empty quota group limit policy")
+	}
+
+	data := &postQuotaData{
+		Action:      "ensure",
+		GroupName:   groupName,
+		LimitPolicy: policy,
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(data); err != nil {
+		return "", err
+	}
+	return client.doAsync("POST", "/v2/quotas", nil, nil, &body)
+}
+
 func (client *Client) GetQuotaGroup(groupName string) (*QuotaGroupResult, error) {
 	if groupName == "" {
 		return nil, fmt.Errorf("cannot get quota group without a name")
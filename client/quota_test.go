@@ -103,6 +103,44 @@ func (cs *clientSuite) TestEnsureQuotaGroupError(c *check.C) {
 	c.Check(err, check.ErrorMatches, `server error: "Internal Server Error"`)
 }
 
+func (cs *clientSuite) TestSetQuotaLimitPolicy(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"change": "42"
+	}`
+
+	chgID, err := cs.cli.SetQuotaLimitPolicy("foo", &client.QuotaLimitPolicy{
+		Action:        "raise",
+		MemoryCeiling: quantity.SizeGiB,
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(chgID, check.Equals, "42")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/quotas")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var req map[string]interface{}
+	err = jsonutil.DecodeWithNumber(bytes.NewReader(body), &req)
+	c.Assert(err, check.IsNil)
+	c.Assert(req, check.DeepEquals, map[string]interface{}{
+		"action":     "ensure",
+		"group-name": "foo",
+		"limit-policy": map[string]interface{}{
+			"action":         "raise",
+			"memory-ceiling": json.Number("1073741824"),
+		},
+	})
+}
+
+func (cs *clientSuite) TestSetQuotaLimitPolicyInvalid(c *check.C) {
+	_, err := cs.cli.SetQuotaLimitPolicy("", &client.QuotaLimitPolicy{Action: "warn"})
+	c.Check(err, check.ErrorMatches, `cannot set quota group limit policy without a name`)
+	_, err = cs.cli.SetQuotaLimitPolicy("foo", nil)
+	c.Check(err, check.ErrorMatches, `cannot set empty quota group limit policy`)
+}
+
 func (cs *clientSuite) TestGetQuotaGroupInvalidName(c *check.C) {
 	_, err := cs.cli.GetQuotaGroup("")
 	c.Assert(err, check.ErrorMatches, `cannot get quota group without a name`)
@@ -27,7 +27,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/url"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -39,6 +41,10 @@ import (
 // SnapshotExportMediaType is the media type used to identify snapshot exports in the API.
 const SnapshotExportMediaType = "application/x.snapd.snapshot"
 
+// SnapshotPassphraseHeader is the header carrying the passphrase snapshot
+// exports are encrypted with.
+const SnapshotPassphraseHeader = "Snapshot-Passphrase"
+
 var (
 	ErrSnapshotSetNotFound   = errors.New("no snapshot set with the given ID")
 	ErrSnapshotSnapsNotFound = errors.New("no snapshot for the requested snaps found in the set with the given ID")
@@ -50,6 +56,7 @@ type snapshotAction struct {
 	Action string   `json:"action"`
 	Snaps  []string `json:"snaps,omitempty"`
 	Users  []string `json:"users,omitempty"`
+	Paths  []string `json:"paths,omitempty"`
 }
 
 // A Snapshot is a collection of archives with a simple metadata json file
@@ -85,6 +92,11 @@ type Snapshot struct {
 	// newer snapd just updates this flag on the fly for snapshots
 	// returned by List().
 	Auto bool `json:"auto,omitempty"`
+	// the policy the snapshot was taken by, either "automatic" for
+	// snapshots taken on snap removal or "scheduled" for snapshots taken
+	// as configured by the snapshots.schedule system option; like Auto,
+	// this is set on the fly for snapshots returned by List().
+	Policy string `json:"policy,omitempty"`
 }
 
 // IsValid checks whether the snapshot is missing information that
@@ -100,6 +112,7 @@ func (sh *Snapshot) ContentHash() ([]byte, error) {
 	sh2.SetID = 0
 	sh2.Time = time.Time{}
 	sh2.Auto = false
+	sh2.Policy = ""
 	h := sha256.New()
 	enc := json.NewEncoder(h)
 	if err := enc.Encode(&sh2); err != nil {
@@ -137,6 +150,20 @@ func (ss SnapshotSet) Size() int64 {
 	return sum
 }
 
+// A SnapshotFile is a file in the data of a snap in a snapshot.
+type SnapshotFile struct {
+	// the user whose data the file is part of, empty for system data
+	User string `json:"user,omitempty"`
+	// the path of the file, relative to the directory holding the
+	// revision and common data directories, e.g. "x1/config.json"
+	Path    string      `json:"path"`
+	Mode    os.FileMode `json:"mode"`
+	Size    int64       `json:"size,omitempty"`
+	ModTime time.Time   `json:"mtime"`
+	// the target of symlinks and hard links
+	Link string `json:"link,omitempty"`
+}
+
 type bySnap []*Snapshot
 
 func (ss bySnap) Len() int           { return len(ss) }
@@ -213,6 +240,37 @@ func (client *Client) RestoreSnapshots(setID uint64, snaps []string, users []str
 	})
 }
 
+// RestoreSnapshotPaths extracts only the given paths, as listed by
+// SnapshotFiles, of the data of a snap from the given snapshot set.
+//
+// If users is non-empty, limit to restoring only those archives of the
+// snapshot.
+func (client *Client) RestoreSnapshotPaths(setID uint64, snap string, users []string, paths []string) (changeID string, err error) {
+	return client.snapshotAction(&snapshotAction{
+		SetID:  setID,
+		Action: "restore",
+		Snaps:  []string{snap},
+		Users:  users,
+		Paths:  paths,
+	})
+}
+
+// SnapshotFiles lists the files in the data of the given snap in the given
+// snapshot set.
+//
+// If users is non-empty, limit to listing only those archives of the
+// snapshot.
+func (client *Client) SnapshotFiles(setID uint64, snap string, users []string) ([]SnapshotFile, error) {
+	q := url.Values{"snap": []string{snap}}
+	if len(users) > 0 {
+		q.Add("users", strings.Join(users, ","))
+	}
+
+	var files []SnapshotFile
+	_, err := client.doSync("GET", fmt.Sprintf("/v2/snapshots/%v/files", setID), q, nil, nil, &files)
+	return files, err
+}
+
 func (client *Client) snapshotAction(action *snapshotAction) (changeID string, err error) {
 	data, err := json.Marshal(action)
 	if err != nil {
@@ -226,11 +284,32 @@ func (client *Client) snapshotAction(action *snapshotAction) (changeID string, e
 	return client.doAsync("POST", "/v2/snapshots", nil, headers, bytes.NewBuffer(data))
 }
 
-// SnapshotExport streams the requested snapshot set.
+// SnapshotExportOptions holds the options of a snapshot export.
+type SnapshotExportOptions struct {
+	// EncryptTo holds the public keys ("age1...") to encrypt the
+	// export to.
+	EncryptTo []string
+	// Passphrase, if set, is used to encrypt the export instead.
+	Passphrase string
+}
+
+// SnapshotExport streams the requested snapshot set, encrypted as
+// requested by opts, if given.
 //
 // The return value includes the length of the returned stream.
-func (client *Client) SnapshotExport(setID uint64) (stream io.ReadCloser, contentLength int64, err error) {
-	rsp, err := client.raw(context.Background(), "GET", fmt.Sprintf("/v2/snapshots/%v/export", setID), nil, nil, nil)
+func (client *Client) SnapshotExport(setID uint64, opts *SnapshotExportOptions) (stream io.ReadCloser, contentLength int64, err error) {
+	if opts == nil {
+		opts = &SnapshotExportOptions{}
+	}
+	var q url.Values
+	if len(opts.EncryptTo) > 0 {
+		q = url.Values{"encrypt-to": opts.EncryptTo}
+	}
+	var headers map[string]string
+	if opts.Passphrase != "" {
+		headers = map[string]string{SnapshotPassphraseHeader: opts.Passphrase}
+	}
+	rsp, err := client.raw(context.Background(), "GET", fmt.Sprintf("/v2/snapshots/%v/export", setID), q, headers, nil)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -258,12 +337,31 @@ type SnapshotImportSet struct {
 	Snaps []string `json:"snaps"`
 }
 
-// SnapshotImport imports an exported snapshot set.
-func (client *Client) SnapshotImport(exportStream io.Reader, size int64) (SnapshotImportSet, error) {
+// SnapshotImportOptions holds the options of a snapshot import.
+type SnapshotImportOptions struct {
+	// Identities holds the secret keys ("AGE-SECRET-KEY-1...") to
+	// decrypt an encrypted export with.
+	Identities []string
+	// Passphrase, if set, is used to decrypt an encrypted export.
+	Passphrase string
+}
+
+// SnapshotImport imports an exported snapshot set. Encrypted exports are
+// decrypted with the keys or passphrase from opts, if given.
+func (client *Client) SnapshotImport(exportStream io.Reader, size int64, opts *SnapshotImportOptions) (SnapshotImportSet, error) {
 	headers := map[string]string{
 		"Content-Type":   SnapshotExportMediaType,
 		"Content-Length": strconv.FormatInt(size, 10),
 	}
+	if opts != nil && (len(opts.Identities) > 0 || opts.Passphrase != "") {
+		// the secrets go in the body, ahead of the export
+		var err error
+		exportStream, size, headers["Content-Type"], err = snapshotImportForm(exportStream, size, opts)
+		if err != nil {
+			return SnapshotImportSet{}, err
+		}
+		headers["Content-Length"] = strconv.FormatInt(size, 10)
+	}
 
 	var importSet SnapshotImportSet
 	if _, err := client.doSync("POST", "/v2/snapshots", nil, headers, exportStream, &importSet); err != nil {
@@ -272,3 +370,34 @@ func (client *Client) SnapshotImport(exportStream io.Reader, size int64) (Snapsh
 
 	return importSet, nil
 }
+
+// snapshotImportForm returns a multipart/form-data body holding the
+// identities and passphrase from opts, followed by the export itself in the
+// "snapshot" file field, as well as its size and content type.
+func snapshotImportForm(exportStream io.Reader, size int64, opts *SnapshotImportOptions) (body io.Reader, bodySize int64, contentType string, err error) {
+	head := &bytes.Buffer{}
+	mw := multipart.NewWriter(head)
+	for _, identity := range opts.Identities {
+		if err := mw.WriteField("identity", identity); err != nil {
+			return nil, 0, "", err
+		}
+	}
+	if opts.Passphrase != "" {
+		if err := mw.WriteField("passphrase", opts.Passphrase); err != nil {
+			return nil, 0, "", err
+		}
+	}
+	if _, err := mw.CreateFormFile("snapshot", "snapshot"); err != nil {
+		return nil, 0, "", err
+	}
+	// the closing boundary is all that is written on close
+	headSize := head.Len()
+	if err := mw.Close(); err != nil {
+		return nil, 0, "", err
+	}
+	tail := append([]byte(nil), head.Bytes()[headSize:]...)
+	head.Truncate(headSize)
+
+	body = io.MultiReader(head, exportStream, bytes.NewReader(tail))
+	return body, int64(head.Len()) + size + int64(len(tail)), mw.FormDataContentType(), nil
+}
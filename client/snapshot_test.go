@@ -20,10 +20,14 @@
 package client_test
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -143,6 +147,53 @@ func (cs *clientSuite) TestClientRestoreSnapshots(c *check.C) {
 	cs.testClientSnapshotAction(c, "restore", cs.cli.RestoreSnapshots)
 }
 
+func (cs *clientSuite) TestClientRestoreSnapshotPaths(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"status-code": 202,
+		"type": "async",
+		"change": "1too3"
+	}`
+	id, err := cs.cli.RestoreSnapshotPaths(42, "asnap", []string{"auser"}, []string{"x1/config", "common/cache"})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "1too3")
+
+	act, err := client.UnmarshalSnapshotAction(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(act.SetID, check.Equals, uint64(42))
+	c.Check(act.Action, check.Equals, "restore")
+	c.Check(act.Snaps, check.DeepEquals, []string{"asnap"})
+	c.Check(act.Users, check.DeepEquals, []string{"auser"})
+	c.Check(act.Paths, check.DeepEquals, []string{"x1/config", "common/cache"})
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots")
+}
+
+func (cs *clientSuite) TestClientSnapshotFiles(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": [
+			{"path": "x1", "mode": 2147484141, "mtime": "2022-09-01T12:00:00Z"},
+			{"user": "auser", "path": "x1/config", "mode": 420, "size": 12, "mtime": "2022-09-01T12:00:00Z"},
+			{"user": "auser", "path": "x1/link", "mode": 134218239, "mtime": "2022-09-01T12:00:00Z", "link": "config"}
+		]
+}`
+	files, err := cs.cli.SnapshotFiles(42, "asnap", []string{"auser", "buser"})
+	c.Assert(err, check.IsNil)
+	mtime := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+	c.Check(files, check.DeepEquals, []client.SnapshotFile{
+		{Path: "x1", Mode: os.ModeDir | 0755, ModTime: mtime},
+		{User: "auser", Path: "x1/config", Mode: 0644, Size: 12, ModTime: mtime},
+		{User: "auser", Path: "x1/link", Mode: os.ModeSymlink | 0777, ModTime: mtime, Link: "config"},
+	})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots/42/files")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"snap":  []string{"asnap"},
+		"users": []string{"auser,buser"},
+	})
+}
+
 func (cs *clientSuite) TestClientExportSnapshot(c *check.C) {
 	type tableT struct {
 		content     string
@@ -164,7 +215,7 @@ func (cs *clientSuite) TestClientExportSnapshot(c *check.C) {
 		cs.rsp = t.content
 		cs.status = t.status
 
-		r, size, err := cs.cli.SnapshotExport(42)
+		r, size, err := cs.cli.SnapshotExport(42, nil)
 		if t.status == 200 {
 			c.Assert(err, check.IsNil, comm)
 			c.Assert(cs.countingCloser.closeCalled, check.Equals, 0)
@@ -182,6 +233,58 @@ func (cs *clientSuite) TestClientExportSnapshot(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientSnapshotExportEncrypted(c *check.C) {
+	cs.header = http.Header{"Content-Type": []string{client.SnapshotExportMediaType}}
+	cs.rsp = "encrypted-export"
+	cs.contentLength = int64(len(cs.rsp))
+
+	r, _, err := cs.cli.SnapshotExport(42, &client.SnapshotExportOptions{EncryptTo: []string{"age1foo", "age1bar"}})
+	c.Assert(err, check.IsNil)
+	r.Close()
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots/42/export")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{"encrypt-to": []string{"age1foo", "age1bar"}})
+	c.Check(cs.req.Header.Get(client.SnapshotPassphraseHeader), check.Equals, "")
+
+	// the passphrase is not part of the URL
+	r, _, err = cs.cli.SnapshotExport(42, &client.SnapshotExportOptions{Passphrase: "sekrit"})
+	c.Assert(err, check.IsNil)
+	r.Close()
+	c.Check(cs.req.URL.RawQuery, check.Equals, "")
+	c.Check(cs.req.Header.Get("Snapshot-Passphrase"), check.Equals, "sekrit")
+}
+
+func (cs *clientSuite) TestClientSnapshotImportEncrypted(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"set-id": 42, "snaps": ["foo"]}}`
+
+	_, err := cs.cli.SnapshotImport(strings.NewReader("fake"), 4, &client.SnapshotImportOptions{
+		Identities: []string{"AGE-SECRET-KEY-1FOO", "AGE-SECRET-KEY-1BAR"},
+		Passphrase: "sekrit",
+	})
+	c.Assert(err, check.IsNil)
+	// the secrets are not in the headers but in the body
+	c.Check(cs.req.Header.Get("Snapshot-Passphrase"), check.Equals, "")
+	mediaType, params, err := mime.ParseMediaType(cs.req.Header.Get("Content-Type"))
+	c.Assert(err, check.IsNil)
+	c.Check(mediaType, check.Equals, "multipart/form-data")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Header.Get("Content-Length"), check.Equals, strconv.Itoa(len(body)))
+
+	form, err := multipart.NewReader(bytes.NewReader(body), params["boundary"]).ReadForm(1024)
+	c.Assert(err, check.IsNil)
+	c.Check(form.Value, check.DeepEquals, map[string][]string{
+		"identity":   {"AGE-SECRET-KEY-1FOO", "AGE-SECRET-KEY-1BAR"},
+		"passphrase": {"sekrit"},
+	})
+	c.Assert(form.File["snapshot"], check.HasLen, 1)
+	f, err := form.File["snapshot"][0].Open()
+	c.Assert(err, check.IsNil)
+	defer f.Close()
+	export, err := ioutil.ReadAll(f)
+	c.Assert(err, check.IsNil)
+	c.Check(string(export), check.Equals, "fake")
+}
+
 func (cs *clientSuite) TestClientSnapshotImport(c *check.C) {
 	type tableT struct {
 		rsp    string
@@ -202,7 +305,7 @@ func (cs *clientSuite) TestClientSnapshotImport(c *check.C) {
 
 		fakeSnapshotData := "fake"
 		r := strings.NewReader(fakeSnapshotData)
-		importSet, err := cs.cli.SnapshotImport(r, int64(len(fakeSnapshotData)))
+		importSet, err := cs.cli.SnapshotImport(r, int64(len(fakeSnapshotData)), nil)
 		if t.error != "" {
 			c.Assert(err, check.NotNil, comm)
 			c.Check(err.Error(), check.Equals, t.error, comm)
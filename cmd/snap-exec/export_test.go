@@ -22,6 +22,7 @@ package main
 import (
 	"syscall"
 
+	"github.com/snapcore/snapd/sandbox/landlock"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -70,3 +71,9 @@ func MockSyscallStat(f func(string, *syscall.Stat_t) (err error)) func() {
 	syscallStat = f
 	return r
 }
+
+func MockLandlockRestrictSelf(f func(rules []landlock.Rule) error) func() {
+	r := testutil.Backup(&landlockRestrictSelf)
+	landlockRestrictSelf = f
+	return r
+}
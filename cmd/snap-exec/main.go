@@ -20,9 +20,13 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"syscall"
 
@@ -31,6 +35,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/sandbox/landlock"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snapenv"
 )
@@ -39,6 +44,7 @@ import (
 var syscallExec = syscall.Exec
 var syscallStat = syscall.Stat
 var osReadlink = os.Readlink
+var landlockRestrictSelf = landlock.RestrictSelf
 
 // commandline args
 var opts struct {
@@ -162,6 +168,65 @@ func completionHelper() (string, error) {
 	return filepath.Join(filepath.Dir(exe), "etelpmoc.sh"), nil
 }
 
+// snapDirOptions returns the options describing where the data of the
+// snap lives in the home directory of the user, as recorded in the
+// sequence file of the snap, see cmd/snap.getSnapDirOptions.
+func snapDirOptions(instanceName string) (*dirs.SnapDirOptions, error) {
+	var opts dirs.SnapDirOptions
+
+	data, err := ioutil.ReadFile(filepath.Join(dirs.SnapSeqDir, instanceName+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return &opts, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var seq struct {
+		MigratedToHiddenDir   bool `json:"migrated-hidden"`
+		MigratedToExposedHome bool `json:"migrated-exposed-home"`
+	}
+	if err := json.Unmarshal(data, &seq); err != nil {
+		return nil, err
+	}
+
+	opts.HiddenSnapDataDir = seq.MigratedToHiddenDir
+	opts.MigratedToExposedHome = seq.MigratedToExposedHome
+
+	return &opts, nil
+}
+
+// restrictFilesystemAccess applies the landlock profile that snapd wrote
+// for the given security tag, if any, see interfaces/landlock. The
+// variables in the paths of the rules are expanded from the values snapd
+// computes for the snap and the calling user, never from the environment
+// of the application or hook, which the snap can override.
+func restrictFilesystemAccess(info *snap.Info, securityTag string) error {
+	f, err := os.Open(filepath.Join(dirs.SnapLandlockDir, securityTag))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("cannot open landlock profile: %v", err)
+	}
+	defer f.Close()
+
+	rules, err := landlock.ReadRules(f)
+	if err != nil {
+		return fmt.Errorf("cannot read landlock profile %q: %v", f.Name(), err)
+	}
+	dirOpts, err := snapDirOptions(info.InstanceName())
+	if err != nil {
+		return fmt.Errorf("cannot get snap dir options: %v", err)
+	}
+	env := osutil.Environment{}
+	snapenv.ExtendEnvForRun(env, info, dirOpts)
+	rules = landlock.ExpandRules(rules, func(name string) string { return env[name] })
+	// landlock only restricts the calling thread, it must be the one that
+	// executes the application or hook
+	runtime.LockOSThread()
+	return landlockRestrictSelf(rules)
+}
+
 func execApp(snapApp, revision, command string, args []string) error {
 	rev, err := snap.ParseRevision(revision)
 	if err != nil {
@@ -247,6 +312,10 @@ func execApp(snapApp, revision, command string, args []string) error {
 
 	fullCmd = append(absoluteCommandChain(app.Snap, app.CommandChain), fullCmd...)
 
+	if err := restrictFilesystemAccess(info, app.SecurityTag()); err != nil {
+		return err
+	}
+
 	logger.StartupStageTimestamp("snap-exec to app")
 	if err := syscallExec(fullCmd[0], fullCmd, env.ForExec()); err != nil {
 		return fmt.Errorf("cannot exec %q: %s", fullCmd[0], err)
@@ -285,6 +354,10 @@ func execHook(snapName, revision, hookName string) error {
 		env.ExtendWithExpanded(eenv)
 	}
 
+	if err := restrictFilesystemAccess(info, hook.SecurityTag()); err != nil {
+		return err
+	}
+
 	// run the hook
 	cmd := append(absoluteCommandChain(hook.Snap, hook.CommandChain), filepath.Join(hook.Snap.HooksDir(), hook.Name))
 	return syscallExec(cmd[0], cmd, env.ForExec())
@@ -24,6 +24,7 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
 	"strings"
 	"syscall"
@@ -35,6 +36,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/sandbox/landlock"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/testutil"
@@ -294,6 +296,100 @@ func (s *snapExecSuite) TestSnapExecHookIntegration(c *C) {
 	c.Check(execArgs, DeepEquals, []string{execArgv0})
 }
 
+func (s *snapExecSuite) TestSnapExecLandlockIntegration(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	// the snap cannot widen its profile by overriding the variables
+	snaptest.MockSnap(c, `name: snapname
+version: 1.0
+environment:
+ SNAP_DATA: /
+ SNAP_REAL_HOME: /
+apps:
+ app:
+  command: run-app
+hooks:
+ configure:
+`, &snap.SideInfo{
+		Revision: snap.R("42"),
+	})
+	c.Assert(os.MkdirAll(dirs.SnapLandlockDir, 0755), IsNil)
+	profile := "# comment\nread /usr\nwrite $SNAP_DATA\nwrite $SNAP_USER_DATA\nwrite $SNAP_REAL_HOME/Documents\nwrite $UNSET\n"
+	for _, tag := range []string{"snap.snapname.app", "snap.snapname.hook.configure"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapLandlockDir, tag), []byte(profile), 0644), IsNil)
+	}
+	os.Setenv("SNAP_USER_DATA", "/")
+	defer os.Unsetenv("SNAP_USER_DATA")
+	usr, err := user.Current()
+	c.Assert(err, IsNil)
+
+	var calls []string
+	restore := snapExec.MockSyscallExec(func(argv0 string, argv []string, env []string) error {
+		calls = append(calls, "exec")
+		return nil
+	})
+	defer restore()
+	var restrictRules [][]landlock.Rule
+	restore = snapExec.MockLandlockRestrictSelf(func(rules []landlock.Rule) error {
+		calls = append(calls, "restrict")
+		restrictRules = append(restrictRules, rules)
+		return nil
+	})
+	defer restore()
+
+	c.Assert(snapExec.ExecApp("snapname.app", "42", "", nil), IsNil)
+	c.Assert(snapExec.ExecHook("snapname", "42", "configure"), IsNil)
+	// the rules using variables that are not set are dropped
+	expectedRules := []landlock.Rule{
+		{Access: landlock.AccessRead, Path: "/usr"},
+		{Access: landlock.AccessWrite, Path: filepath.Join(dirs.SnapDataDir, "snapname/42")},
+		{Access: landlock.AccessWrite, Path: filepath.Join(usr.HomeDir, "snap/snapname/42")},
+		{Access: landlock.AccessWrite, Path: filepath.Join(usr.HomeDir, "Documents")},
+	}
+	c.Check(restrictRules, DeepEquals, [][]landlock.Rule{expectedRules, expectedRules})
+	c.Check(calls, DeepEquals, []string{"restrict", "exec", "restrict", "exec"})
+
+	// the user data of snaps migrated to the hidden directory
+	c.Assert(os.MkdirAll(dirs.SnapSeqDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapSeqDir, "snapname.json"), []byte(`{"migrated-hidden": true}`), 0644), IsNil)
+	restrictRules = nil
+	c.Assert(snapExec.ExecApp("snapname.app", "42", "", nil), IsNil)
+	c.Assert(restrictRules, HasLen, 1)
+	c.Check(restrictRules[0][2], Equals, landlock.Rule{Access: landlock.AccessWrite, Path: filepath.Join(usr.HomeDir, ".snap/data/snapname/42")})
+
+	// no profile, no restriction
+	calls = nil
+	c.Assert(os.Remove(filepath.Join(dirs.SnapLandlockDir, "snap.snapname.app")), IsNil)
+	c.Assert(snapExec.ExecApp("snapname.app", "42", "", nil), IsNil)
+	c.Check(calls, DeepEquals, []string{"exec"})
+}
+
+func (s *snapExecSuite) TestSnapExecLandlockErrors(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	snaptest.MockSnap(c, string(mockYaml), &snap.SideInfo{
+		Revision: snap.R("42"),
+	})
+	c.Assert(os.MkdirAll(dirs.SnapLandlockDir, 0755), IsNil)
+	profilePath := filepath.Join(dirs.SnapLandlockDir, "snap.snapname.app")
+	c.Assert(ioutil.WriteFile(profilePath, []byte("bogus\n"), 0644), IsNil)
+
+	restore := snapExec.MockSyscallExec(func(argv0 string, argv []string, env []string) error {
+		c.Fatalf("unexpected exec")
+		return nil
+	})
+	defer restore()
+	restore = snapExec.MockLandlockRestrictSelf(func(rules []landlock.Rule) error {
+		return fmt.Errorf("boom")
+	})
+	defer restore()
+
+	err := snapExec.ExecApp("snapname.app", "42", "", nil)
+	c.Check(err, ErrorMatches, fmt.Sprintf(`cannot read landlock profile %q: cannot parse landlock rule "bogus"`, profilePath))
+
+	c.Assert(ioutil.WriteFile(profilePath, []byte("read /usr\n"), 0644), IsNil)
+	err = snapExec.ExecApp("snapname.app", "42", "", nil)
+	c.Check(err, ErrorMatches, "boom")
+}
+
 func (s *snapExecSuite) TestSnapExecHookCommandChainIntegration(c *C) {
 	dirs.SetRootDir(c.MkDir())
 	snaptest.MockSnap(c, string(mockHookCommandChainYaml), &snap.SideInfo{
@@ -28,6 +28,8 @@ package main
 //#include <errno.h>
 //#include <linux/can.h>
 //#include <linux/netlink.h>
+//#include <linux/uinput.h>
+//#include <linux/usbdevice_fs.h>
 //#include <sched.h>
 //#include <search.h>
 //#include <stdbool.h>
@@ -193,6 +195,7 @@ import (
 
 	"github.com/snapcore/snapd/arch"
 	"github.com/snapcore/snapd/osutil"
+	sandbox "github.com/snapcore/snapd/sandbox/seccomp"
 )
 
 // libseccomp maximum per ARG_COUNT_MAX in src/arch.h
@@ -374,6 +377,53 @@ var seccompResolver = map[string]uint64{
 	// man 4 tty_ioctl
 	"TIOCSTI": syscall.TIOCSTI,
 
+	// man 7 netdevice
+	"SIOCGIFNAME":   syscall.SIOCGIFNAME,
+	"SIOCGIFINDEX":  syscall.SIOCGIFINDEX,
+	"SIOCGIFFLAGS":  syscall.SIOCGIFFLAGS,
+	"SIOCGIFMTU":    syscall.SIOCGIFMTU,
+	"SIOCGIFHWADDR": syscall.SIOCGIFHWADDR,
+
+	// uapi/linux/uinput.h
+	"UI_DEV_CREATE":  C.UI_DEV_CREATE,
+	"UI_DEV_DESTROY": C.UI_DEV_DESTROY,
+	"UI_SET_EVBIT":   C.UI_SET_EVBIT,
+	"UI_SET_KEYBIT":  C.UI_SET_KEYBIT,
+	"UI_SET_RELBIT":  C.UI_SET_RELBIT,
+	"UI_SET_ABSBIT":  C.UI_SET_ABSBIT,
+	"UI_SET_MSCBIT":  C.UI_SET_MSCBIT,
+	"UI_SET_LEDBIT":  C.UI_SET_LEDBIT,
+	"UI_SET_SNDBIT":  C.UI_SET_SNDBIT,
+	"UI_SET_FFBIT":   C.UI_SET_FFBIT,
+	"UI_SET_PHYS":    C.UI_SET_PHYS,
+	"UI_SET_SWBIT":   C.UI_SET_SWBIT,
+	"UI_SET_PROPBIT": C.UI_SET_PROPBIT,
+
+	// uapi/linux/usbdevice_fs.h
+	"USBDEVFS_CONTROL":          C.USBDEVFS_CONTROL,
+	"USBDEVFS_BULK":             C.USBDEVFS_BULK,
+	"USBDEVFS_RESETEP":          C.USBDEVFS_RESETEP,
+	"USBDEVFS_SETINTERFACE":     C.USBDEVFS_SETINTERFACE,
+	"USBDEVFS_SETCONFIGURATION": C.USBDEVFS_SETCONFIGURATION,
+	"USBDEVFS_GETDRIVER":        C.USBDEVFS_GETDRIVER,
+	"USBDEVFS_SUBMITURB":        C.USBDEVFS_SUBMITURB,
+	"USBDEVFS_DISCARDURB":       C.USBDEVFS_DISCARDURB,
+	"USBDEVFS_REAPURB":          C.USBDEVFS_REAPURB,
+	"USBDEVFS_REAPURBNDELAY":    C.USBDEVFS_REAPURBNDELAY,
+	"USBDEVFS_CLAIMINTERFACE":   C.USBDEVFS_CLAIMINTERFACE,
+	"USBDEVFS_RELEASEINTERFACE": C.USBDEVFS_RELEASEINTERFACE,
+	"USBDEVFS_CONNECTINFO":      C.USBDEVFS_CONNECTINFO,
+	"USBDEVFS_IOCTL":            C.USBDEVFS_IOCTL,
+	"USBDEVFS_RESET":            C.USBDEVFS_RESET,
+	"USBDEVFS_CLEAR_HALT":       C.USBDEVFS_CLEAR_HALT,
+	"USBDEVFS_DISCONNECT":       C.USBDEVFS_DISCONNECT,
+	"USBDEVFS_CONNECT":          C.USBDEVFS_CONNECT,
+	"USBDEVFS_GET_CAPABILITIES": C.USBDEVFS_GET_CAPABILITIES,
+	"USBDEVFS_DISCONNECT_CLAIM": C.USBDEVFS_DISCONNECT_CLAIM,
+	"USBDEVFS_ALLOC_STREAMS":    C.USBDEVFS_ALLOC_STREAMS,
+	"USBDEVFS_FREE_STREAMS":     C.USBDEVFS_FREE_STREAMS,
+	"USBDEVFS_GET_SPEED":        C.USBDEVFS_GET_SPEED,
+
 	// man 2 quotactl (with what Linux supports)
 	"Q_SYNC":      C.Q_SYNC,
 	"Q_QUOTAON":   C.Q_QUOTAON,
@@ -420,6 +470,10 @@ var seccompResolver = map[string]uint64{
 	"NETLINK_CRYPTO":         C.NETLINK_CRYPTO,
 	"NETLINK_INET_DIAG":      C.NETLINK_INET_DIAG, // synonymous with NETLINK_SOCK_DIAG
 
+	// man 7 can (uapi/linux/can.h)
+	"CAN_RAW": C.CAN_RAW,
+	"CAN_BCM": C.CAN_BCM,
+
 	// man 2 ptrace
 	"PTRACE_ATTACH":     C.PTRACE_ATTACH,
 	"PTRACE_DETACH":     C.PTRACE_DETACH,
@@ -547,6 +601,31 @@ func parseLine(line string, secFilter *seccomp.ScmpFilter) error {
 		return fmt.Errorf("too many arguments specified for syscall '%s' in line %q", tokens[0], line)
 	}
 
+	// expand the named set of ioctl requests into one line per request
+	if tokens[0] == "ioctl" {
+		for pos, arg := range tokens[1:] {
+			if !strings.HasPrefix(arg, "@") {
+				continue
+			}
+			if pos != 1 {
+				return fmt.Errorf("cannot parse token %q (line %q): ioctl sets can only be used for the request argument", arg, line)
+			}
+			requests, ok := sandbox.IoctlSets[arg[1:]]
+			if !ok {
+				return fmt.Errorf("cannot parse token %q (line %q): unknown ioctl set", arg, line)
+			}
+			for _, request := range requests {
+				expanded := make([]string, len(tokens))
+				copy(expanded, tokens)
+				expanded[pos+1] = request
+				if err := parseLine(strings.Join(expanded, " "), secFilter); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
 	// fish out syscall
 	syscallName := tokens[0]
 	secSyscall, err := seccomp.GetSyscallFromName(syscallName)
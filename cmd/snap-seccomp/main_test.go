@@ -37,6 +37,7 @@ import (
 	main "github.com/snapcore/snapd/cmd/snap-seccomp"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/release"
+	sandbox "github.com/snapcore/snapd/sandbox/seccomp"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -448,6 +449,15 @@ func (s *snapSeccompSuite) TestCompile(c *C) {
 		{"ioctl - TIOCSTI", "ioctl;native;-,99", Deny},
 		{"ioctl - !TIOCSTI", "ioctl;native;-,TIOCSTI", Deny},
 
+		// named sets of ioctl requests
+		{"ioctl - @uinput", "ioctl;native;-,UI_DEV_CREATE", Allow},
+		{"ioctl - @uinput", "ioctl;native;-,UI_SET_KEYBIT", Allow},
+		{"ioctl - @uinput", "ioctl;native;-,TIOCSTI", Deny},
+		{"ioctl - @netdevice", "ioctl;native;-,SIOCGIFINDEX", Allow},
+		{"ioctl - @netdevice", "ioctl;native;-,UI_DEV_CREATE", Deny},
+		{"ioctl - @usbdevfs", "ioctl;native;-,USBDEVFS_SUBMITURB", Allow},
+		{"ioctl - @usbdevfs", "ioctl;native;-,UI_DEV_CREATE", Deny},
+
 		// test_bad_seccomp_filter_args_clone
 		{"setns - CLONE_NEWNET", "setns;native;-,99", Deny},
 		{"setns - CLONE_NEWNET", "setns;native;-,CLONE_NEWNET", Allow},
@@ -490,6 +500,16 @@ func (s *snapSeccompSuite) TestCompile(c *C) {
 	}
 }
 
+func (s *snapSeccompSuite) TestIoctlSetsResolved(c *C) {
+	for name, requests := range sandbox.IoctlSets {
+		c.Check(requests, Not(HasLen), 0, Commentf("ioctl set %q", name))
+		for _, request := range requests {
+			_, ok := main.SeccompResolver[request]
+			c.Check(ok, Equals, true, Commentf("ioctl set %q has unknown request %q", name, request))
+		}
+	}
+}
+
 // TestCompileSocket runs in a separate tests so that only this part
 // can be skipped when "socketcall()" is used instead of "socket()".
 //
@@ -513,6 +533,9 @@ func (s *snapSeccompSuite) TestCompileSocket(c *C) {
 		{"socket - SOCK_STREAM", "socket;native;-,99", Deny},
 		{"socket AF_CONN", "socket;native;AF_CONN", Allow},
 		{"socket AF_CONN", "socket;native;99", Deny},
+		{"socket AF_CAN - CAN_RAW", "socket;native;AF_CAN,0,CAN_RAW", Allow},
+		{"socket AF_CAN - CAN_RAW", "socket;native;AF_CAN,0,CAN_BCM", Deny},
+		{"socket AF_CAN - CAN_BCM", "socket;native;AF_CAN,0,CAN_BCM", Allow},
 	} {
 		s.runBpf(c, t.seccompWhitelist, t.bpfInput, t.expected)
 	}
@@ -586,6 +609,9 @@ func (s *snapSeccompSuite) TestCompileBadInput(c *C) {
 		{"ioctl - TIOCST", `cannot parse line: cannot parse token "TIOCST" .*`},
 		{"ioctl - TIOCSTII", `cannot parse line: cannot parse token "TIOCSTII" .*`},
 		{"ioctl - TIOCST1", `cannot parse line: cannot parse token "TIOCST1" .*`},
+		// ioctl sets
+		{"ioctl - @uinputt", `cannot parse line: cannot parse token "@uinputt" \(line "ioctl - @uinputt"\): unknown ioctl set`},
+		{"ioctl @uinput", `cannot parse line: cannot parse token "@uinput" \(line "ioctl @uinput"\): ioctl sets can only be used for the request argument`},
 		// ensure missing numbers are caught
 		{"setpriority >", `cannot parse line: cannot parse token ">" .*`},
 		{"setpriority >=", `cannot parse line: cannot parse token ">=" .*`},
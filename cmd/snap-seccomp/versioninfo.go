@@ -29,6 +29,7 @@ import (
 
 	"github.com/snapcore/snapd/cmd/snap-seccomp/syscalls"
 	"github.com/snapcore/snapd/osutil"
+	sandbox "github.com/snapcore/snapd/sandbox/seccomp"
 )
 
 var seccompSyscalls = syscalls.SeccompSyscalls
@@ -73,6 +74,8 @@ func goSeccompFeatures() string {
 	if actLogSupported() {
 		features = append(features, "bpf-actlog")
 	}
+	// the named sets of ioctl requests are always known
+	features = append(features, sandbox.IoctlSetsFeature)
 
 	if len(features) == 0 {
 		return "-"
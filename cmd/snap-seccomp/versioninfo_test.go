@@ -28,6 +28,7 @@ import (
 
 	main "github.com/snapcore/snapd/cmd/snap-seccomp"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/testutil"
 )
 
 type versionInfoSuite struct{}
@@ -72,3 +73,8 @@ func (s *versionInfoSuite) TestVersionInfo(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(vi, Equals, prefix+readHash+suffix)
 }
+
+func (s *versionInfoSuite) TestGoSeccompFeaturesIoctlSets(c *C) {
+	features := strings.Split(main.GoSeccompFeatures(), ":")
+	c.Check(features, testutil.Contains, "ioctl-sets")
+}
@@ -34,6 +34,9 @@ var shortChangesHelp = i18n.G("List system changes")
 var shortTasksHelp = i18n.G("List a change's tasks")
 var longChangesHelp = i18n.G(`
 The changes command displays a summary of system changes performed recently.
+
+With --archived, the older changes that were pruned from the system state
+and archived are displayed instead.
 `)
 var longTasksHelp = i18n.G(`
 The tasks command displays a summary of tasks associated with an individual
@@ -43,6 +46,7 @@ change.
 type cmdChanges struct {
 	clientMixin
 	timeMixin
+	Archived   bool `long:"archived"`
 	Positional struct {
 		Snap string `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -55,7 +59,10 @@ type cmdTasks struct {
 
 func init() {
 	addCommand("changes", shortChangesHelp, longChangesHelp,
-		func() flags.Commander { return &cmdChanges{} }, timeDescs, nil)
+		func() flags.Commander { return &cmdChanges{} }, timeDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"archived": i18n.G("Show the archived changes instead of the recent ones"),
+		}), nil)
 	addCommand("tasks", shortTasksHelp, longTasksHelp,
 		func() flags.Commander { return &cmdTasks{} },
 		changeIDMixinOptDesc.also(timeDescs),
@@ -100,6 +107,9 @@ func (c *cmdChanges) Execute(args []string) error {
 		SnapName: c.Positional.Snap,
 		Selector: client.ChangesAll,
 	}
+	if c.Archived {
+		opts.Selector = client.ChangesArchived
+	}
 
 	changes, err := queryChanges(c.client, &opts)
 	if err != nil {
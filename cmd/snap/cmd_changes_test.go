@@ -22,6 +22,7 @@ package main_test
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"gopkg.in/check.v1"
@@ -250,3 +251,38 @@ func (s *SnapSuite) TestNoChanges(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Check(s.Stderr(), check.Equals, "no changes found\n")
 }
+
+func (s *SnapSuite) TestChangesArchived(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes")
+			c.Check(r.URL.Query(), check.DeepEquals, url.Values{
+				"select": {"archived"},
+				"for":    {"foo"},
+			})
+			fmt.Fprintln(w, `{"type": "sync", "result": [{
+  "id":   "1",
+  "kind": "install-snap",
+  "summary": "Install \"foo\" snap",
+  "status": "Done",
+  "ready": true,
+  "spawn-time": "2016-04-21T01:02:03Z",
+  "ready-time": "2016-04-21T01:02:04Z"
+}]}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"changes", "--archived", "--abs-time", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?ms)ID +Status +Spawn +Ready +Summary
+1 +Done +2016-04-21T01:02:03Z +2016-04-21T01:02:04Z +Install "foo" snap
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
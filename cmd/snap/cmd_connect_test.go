@@ -53,9 +53,16 @@ $ snap connect <snap>:<plug>
 Connects the provided plug to the slot in the core snap with a name matching
 the plug name.
 
+$ snap connect --for <duration> <snap>:<plug>
+
+Connects the provided plug and disconnects it automatically once the given
+duration, e.g. 30m or 2h, has elapsed.
+
 [connect command options]
       --no-wait          Do not wait for the operation to finish but just print
                          the change id.
+      --for=             Disconnect the plug automatically after the given
+                         duration
 `
 	s.testSubCommandHelp(c, "connect", msg)
 }
@@ -94,6 +101,51 @@ func (s *SnapSuite) TestConnectExplicitEverything(c *C) {
 	c.Assert(rest, DeepEquals, []string{})
 }
 
+func (s *SnapSuite) TestConnectFor(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/interfaces":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action": "connect",
+				"for":    "2h0m0s",
+				"plugs": []interface{}{
+					map[string]interface{}{
+						"snap": "producer",
+						"plug": "log-observe",
+					},
+				},
+				"slots": []interface{}{
+					map[string]interface{}{
+						"snap": "",
+						"slot": "",
+					},
+				},
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+		case "/v2/changes/zzz":
+			c.Check(r.Method, Equals, "GET")
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	rest, err := Parser(Client()).ParseArgs([]string{"connect", "--for", "2h", "producer:log-observe"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+}
+
+func (s *SnapSuite) TestConnectForInvalid(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request to %q", r.URL.Path)
+	})
+	_, err := Parser(Client()).ParseArgs([]string{"connect", "--for", "-2h", "producer:log-observe"})
+	c.Assert(err, ErrorMatches, `cannot connect for a negative duration: -2h0m0s`)
+	_, err = Parser(Client()).ParseArgs([]string{"connect", "--for", "soon", "producer:log-observe"})
+	c.Assert(err, ErrorMatches, `.*invalid duration.*`)
+}
+
 func (s *SnapSuite) TestConnectExplicitPlugImplicitSlot(c *C) {
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
@@ -23,18 +23,22 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/strutil/quantity"
 )
 
 type cmdConnections struct {
 	clientMixin
 	All         bool `long:"all"`
+	Explain     bool `long:"explain"`
 	Positionals struct {
 		Snap installedSnapName
+		Slot connectSlotSpec
 	} `positional-args:"true"`
 }
 
@@ -51,6 +55,12 @@ $ snap connections <snap>
 
 Lists connected and unconnected plugs and slots for the specified
 snap.
+
+$ snap connections --explain <snap>:<plug> <snap>[:<slot>]
+
+Explains whether the plug may be connected, manually or automatically,
+to the slot: which rules of the snap declarations set by the store or of
+the base declaration decided, and which of their constraints matched.
 `)
 
 func init() {
@@ -58,11 +68,18 @@ func init() {
 		return &cmdConnections{}
 	}, map[string]string{
 		"all": i18n.G("Show connected and unconnected plugs and slots"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"explain": i18n.G("Explain the evaluation of the connection policies for a plug and a slot"),
 	}, []argDesc{{
 		// TRANSLATORS: This needs to be wrapped in <>s.
 		name: "<snap>",
 		// TRANSLATORS: This should not start with a lowercase letter.
 		desc: i18n.G("Constrain listing to a specific snap"),
+	}, {
+		// TRANSLATORS: This needs to begin with < and end with >
+		name: i18n.G("<snap>:<slot>"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("Slot to explain the connection of the plug to (with --explain)"),
 	}})
 }
 
@@ -84,6 +101,7 @@ type connection struct {
 	interfaceDeterminant string
 	manual               bool
 	gadget               bool
+	expiry               time.Time
 }
 
 func (cn connection) String() string {
@@ -94,6 +112,14 @@ func (cn connection) String() string {
 	if cn.gadget {
 		opts = append(opts, "gadget")
 	}
+	if !cn.expiry.IsZero() {
+		if remaining := cn.expiry.Sub(timeNow()); remaining > 0 {
+			opts = append(opts, fmt.Sprintf("expires in %s", strings.TrimSpace(quantity.FormatDuration(remaining.Seconds()))))
+		} else {
+			// snapd disconnects it shortly
+			opts = append(opts, "expired")
+		}
+	}
 	if len(opts) == 0 {
 		return "-"
 	}
@@ -124,6 +150,21 @@ func interfaceDeterminant(conn *client.Connection) string {
 		if value == "" {
 			value, _ = conn.SlotAttrs["content"].(string)
 		}
+	case "network":
+		// show the hosts and ports the egress is restricted to
+		var restrictions []string
+		for _, attr := range []string{"allow-hosts", "allow-ports"} {
+			list, _ := conn.PlugAttrs[attr].([]interface{})
+			if len(list) == 0 {
+				continue
+			}
+			elements := make([]string, len(list))
+			for i, element := range list {
+				elements[i] = fmt.Sprintf("%v", element)
+			}
+			restrictions = append(restrictions, fmt.Sprintf("%s=%s", attr, strings.Join(elements, ",")))
+		}
+		value = strings.Join(restrictions, " ")
 	}
 	if value == "" {
 		return ""
@@ -135,6 +176,12 @@ func (x *cmdConnections) Execute(args []string) error {
 	if len(args) > 0 {
 		return ErrExtraArgs
 	}
+	if x.Explain {
+		return x.explain()
+	}
+	if x.Positionals.Slot.Snap != "" || x.Positionals.Slot.Name != "" {
+		return ErrExtraArgs
+	}
 
 	opts := client.ConnectionOptions{
 		All: x.All,
@@ -169,6 +216,7 @@ func (x *cmdConnections) Execute(args []string) error {
 			slot:                 endpoint(conn.Slot.Snap, conn.Slot.Name),
 			manual:               conn.Manual,
 			gadget:               conn.Gadget,
+			expiry:               conn.Expiry,
 			interfaceName:        conn.Interface,
 			interfaceDeterminant: interfaceDeterminant(&conn),
 		})
@@ -212,3 +260,92 @@ func (x *cmdConnections) Execute(args []string) error {
 	}
 	return nil
 }
+
+func (x *cmdConnections) explain() error {
+	if x.All {
+		return fmt.Errorf(i18n.G("cannot use --all with --explain"))
+	}
+	if x.Positionals.Snap == "" {
+		return fmt.Errorf(i18n.G("--explain requires a plug"))
+	}
+	var plug connectPlugSpec
+	if err := plug.UnmarshalFlag(string(x.Positionals.Snap)); err != nil {
+		return err
+	}
+	// like "snap connect", a plug without snap is the plug of the snap
+	// of that name
+	if plug.Name == "" {
+		plug.Name = plug.Snap
+		plug.Snap = ""
+	}
+	slot := x.Positionals.Slot
+
+	expl, err := x.client.ExplainConnection(plug.Snap, plug.Name, slot.Snap, slot.Name)
+	if err != nil {
+		return err
+	}
+
+	w := tabWriter()
+	fmt.Fprintf(w, "interface:\t%s\n", expl.Interface)
+	fmt.Fprintf(w, "plug:\t%s\n", endpoint(expl.Plug.Snap, expl.Plug.Name))
+	fmt.Fprintf(w, "slot:\t%s\n", endpoint(expl.Slot.Snap, expl.Slot.Name))
+	if len(expl.Unasserted) > 0 {
+		fmt.Fprintf(w, "unasserted:\t%s\n", strings.Join(expl.Unasserted, ", "))
+	}
+	w.Flush()
+	if len(expl.Unasserted) > 0 {
+		fmt.Fprintln(Stdout, i18n.G("note: manual connections of snaps without a snap declaration are not checked against the connection rules"))
+	}
+
+	printPolicyExplanation("connection", expl.Connection)
+	printPolicyExplanation("auto-connection", expl.AutoConnection)
+	return nil
+}
+
+func printPolicyExplanation(kind string, expl *client.PolicyExplanation) {
+	if expl == nil {
+		return
+	}
+	verdict := "allowed"
+	if !expl.Allowed {
+		verdict = "not allowed"
+	}
+	fmt.Fprintf(Stdout, "%s: %s\n", kind, verdict)
+	for _, rule := range expl.Rules {
+		source := rule.Declaration
+		if rule.Snap != "" {
+			source = fmt.Sprintf("%s of %q", rule.Declaration, rule.Snap)
+		}
+		if !rule.Found {
+			fmt.Fprintf(Stdout, "  %s, %s rule: not found\n", source, rule.Side)
+			continue
+		}
+		fmt.Fprintf(Stdout, "  %s, %s rule: %s\n", source, rule.Side, rule.Verdict)
+		printConstraintsExplanations("deny-"+kind, rule.Deny)
+		printConstraintsExplanations("allow-"+kind, rule.Allow)
+	}
+	if expl.Error != "" {
+		fmt.Fprintf(Stdout, "  error: %s\n", expl.Error)
+	}
+}
+
+func printConstraintsExplanations(name string, alts []*client.ConstraintsExplanation) {
+	if len(alts) == 0 {
+		return
+	}
+	fmt.Fprintf(Stdout, "    %s:\n", name)
+	for _, alt := range alts {
+		if alt.Matched {
+			fmt.Fprintln(Stdout, "      - matched")
+		} else {
+			fmt.Fprintln(Stdout, "      - no match")
+		}
+		for _, cons := range alt.Constraints {
+			if cons.Matched {
+				fmt.Fprintf(Stdout, "        %s: matched\n", cons.Name)
+			} else {
+				fmt.Fprintf(Stdout, "        %s: %s\n", cons.Name, cons.Error)
+			}
+		}
+	}
+}
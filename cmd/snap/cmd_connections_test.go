@@ -24,6 +24,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -851,3 +852,204 @@ func (s *SnapSuite) TestConnectionsDefiningAttribute(c *C) {
 	c.Assert(s.Stdout(), Equals, expectedStdout)
 	c.Assert(s.Stderr(), Equals, "")
 }
+
+func (s *SnapSuite) TestConnectionsNetworkEgress(c *C) {
+	result := client.Connections{
+		Established: []client.Connection{
+			{
+				Plug:      client.PlugRef{Snap: "foo", Name: "network"},
+				Slot:      client.SlotRef{Snap: "core", Name: "network"},
+				Interface: "network",
+				PlugAttrs: map[string]interface{}{
+					"allow-hosts": []interface{}{"192.0.2.1", "2001:db8::/32"},
+					"allow-ports": []interface{}{443, "53/udp"},
+				},
+			}, {
+				Plug:      client.PlugRef{Snap: "bar", Name: "network"},
+				Slot:      client.SlotRef{Snap: "core", Name: "network"},
+				Interface: "network",
+				PlugAttrs: map[string]interface{}{
+					"allow-ports": []interface{}{"443/tcp"},
+				},
+			}, {
+				Plug:      client.PlugRef{Snap: "baz", Name: "network"},
+				Slot:      client.SlotRef{Snap: "core", Name: "network"},
+				Interface: "network",
+			},
+		},
+		Plugs: []client.Plug{
+			{
+				Snap:        "foo",
+				Name:        "network",
+				Interface:   "network",
+				Connections: []client.SlotRef{{Snap: "core", Name: "network"}},
+			}, {
+				Snap:        "bar",
+				Name:        "network",
+				Interface:   "network",
+				Connections: []client.SlotRef{{Snap: "core", Name: "network"}},
+			}, {
+				Snap:        "baz",
+				Name:        "network",
+				Interface:   "network",
+				Connections: []client.SlotRef{{Snap: "core", Name: "network"}},
+			},
+		},
+	}
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/connections")
+		EncodeResponseBody(c, w, map[string]interface{}{
+			"type":   "sync",
+			"result": result,
+		})
+	})
+
+	rest, err := Parser(Client()).ParseArgs([]string{"connections"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	expectedStdout := "" +
+		"Interface                                                            Plug         Slot      Notes\n" +
+		"network[allow-ports=443/tcp]                                         bar:network  :network  -\n" +
+		"network                                                              baz:network  :network  -\n" +
+		"network[allow-hosts=192.0.2.1,2001:db8::/32 allow-ports=443,53/udp]  foo:network  :network  -\n"
+	c.Assert(s.Stdout(), Equals, expectedStdout)
+	c.Assert(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestConnectionsExpiring(c *C) {
+	now := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	restore := MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	result := client.Connections{
+		Established: []client.Connection{
+			{
+				Plug:      client.PlugRef{Snap: "foo", Name: "log-observe"},
+				Slot:      client.SlotRef{Snap: "core", Name: "log-observe"},
+				Interface: "log-observe",
+				Manual:    true,
+				Expiry:    now.Add(90 * time.Minute),
+			}, {
+				Plug:      client.PlugRef{Snap: "bar", Name: "log-observe"},
+				Slot:      client.SlotRef{Snap: "core", Name: "log-observe"},
+				Interface: "log-observe",
+				Manual:    true,
+				// about to be disconnected
+				Expiry: now.Add(-time.Second),
+			},
+		},
+		Plugs: []client.Plug{
+			{
+				Snap:        "foo",
+				Name:        "log-observe",
+				Interface:   "log-observe",
+				Connections: []client.SlotRef{{Snap: "core", Name: "log-observe"}},
+			}, {
+				Snap:        "bar",
+				Name:        "log-observe",
+				Interface:   "log-observe",
+				Connections: []client.SlotRef{{Snap: "core", Name: "log-observe"}},
+			},
+		},
+	}
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/connections")
+		EncodeResponseBody(c, w, map[string]interface{}{
+			"type":   "sync",
+			"result": result,
+		})
+	})
+
+	rest, err := Parser(Client()).ParseArgs([]string{"connections"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	expectedStdout := "" +
+		"Interface    Plug             Slot          Notes\n" +
+		"log-observe  bar:log-observe  :log-observe  manual,expired\n" +
+		"log-observe  foo:log-observe  :log-observe  manual,expires in 90.0m\n"
+	c.Assert(s.Stdout(), Equals, expectedStdout)
+	c.Assert(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestConnectionsExplain(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/interfaces")
+		c.Check(r.URL.Query(), DeepEquals, url.Values{
+			"select": []string{"explain"},
+			"plug":   []string{"consumer:plug"},
+			"slot":   []string{"producer:"},
+		})
+		fmt.Fprintln(w, `{"type": "sync", "result": {
+			"plug": {"snap": "consumer", "plug": "plug"},
+			"slot": {"snap": "producer", "slot": "slot"},
+			"interface": "test",
+			"unasserted": ["consumer"],
+			"connection": {
+				"allowed": false,
+				"error": "connection not allowed by slot rule of interface \"test\"",
+				"rules": [
+					{"declaration": "snap-declaration", "snap": "producer", "side": "slot", "found": false},
+					{"declaration": "base-declaration", "side": "plug", "found": false},
+					{"declaration": "base-declaration", "side": "slot", "found": true, "verdict": "not-allowed",
+					 "deny": [{"matched": false, "constraints": [{"name": "false", "matched": false, "error": "not allowed"}]}],
+					 "allow": [{"matched": false, "constraints": [
+						{"name": "plug-snap-type", "matched": false, "error": "snap type does not match"},
+						{"name": "on-classic", "matched": true}
+					 ]}]}
+				]
+			},
+			"auto-connection": {
+				"allowed": true,
+				"rules": [
+					{"declaration": "snap-declaration", "snap": "producer", "side": "slot", "found": true, "verdict": "allowed",
+					 "deny": [{"matched": false, "constraints": [{"name": "false", "matched": false, "error": "not allowed"}]}],
+					 "allow": [{"matched": true}]}
+				]
+			}
+		}}`)
+	})
+	rest, err := Parser(Client()).ParseArgs([]string{"connections", "--explain", "consumer:plug", "producer"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, `interface:   test
+plug:        consumer:plug
+slot:        producer:slot
+unasserted:  consumer
+note: manual connections of snaps without a snap declaration are not checked against the connection rules
+connection: not allowed
+  snap-declaration of "producer", slot rule: not found
+  base-declaration, plug rule: not found
+  base-declaration, slot rule: not-allowed
+    deny-connection:
+      - no match
+        false: not allowed
+    allow-connection:
+      - no match
+        plug-snap-type: snap type does not match
+        on-classic: matched
+  error: connection not allowed by slot rule of interface "test"
+auto-connection: allowed
+  snap-declaration of "producer", slot rule: allowed
+    deny-auto-connection:
+      - no match
+        false: not allowed
+    allow-auto-connection:
+      - matched
+`)
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestConnectionsExplainErrors(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request")
+	})
+	_, err := Parser(Client()).ParseArgs([]string{"connections", "--explain"})
+	c.Check(err, ErrorMatches, `--explain requires a plug`)
+	_, err = Parser(Client()).ParseArgs([]string{"connections", "--explain", "--all", "consumer:plug"})
+	c.Check(err, ErrorMatches, `cannot use --all with --explain`)
+	_, err = Parser(Client()).ParseArgs([]string{"connections", "consumer", "producer"})
+	c.Check(err, ErrorMatches, `too many arguments for command`)
+}
@@ -0,0 +1,109 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdDebugDenials struct {
+	clientMixin
+	timeMixin
+	Positionals struct {
+		Snap installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"true"`
+}
+
+var shortDebugDenialsHelp = i18n.G("Show the accesses denied to snaps by their sandbox")
+var longDebugDenialsHelp = i18n.G(`
+The denials command shows the accesses that AppArmor and seccomp denied to
+the apps and hooks of all snaps, or of the given snap, as found in the audit
+log, along with the interfaces that would allow them, if any.
+
+Identical accesses denied to the same revision of an app or hook are shown
+once, with the number of times they were denied.
+`)
+
+func init() {
+	addDebugCommand("denials", shortDebugDenialsHelp, longDebugDenialsHelp,
+		func() flags.Commander {
+			return &cmdDebugDenials{}
+		}, timeDescs, []argDesc{{
+			// TRANSLATORS: This needs to begin with < and end with >
+			name: i18n.G("<snap>"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			desc: i18n.G("Only show the accesses denied to the given snap"),
+		}})
+}
+
+type deniedAccess struct {
+	Snap                string    `json:"snap"`
+	App                 string    `json:"app,omitempty"`
+	Hook                string    `json:"hook,omitempty"`
+	Revision            string    `json:"revision"`
+	Description         string    `json:"description"`
+	Count               int       `json:"count"`
+	LastSeen            time.Time `json:"last-seen"`
+	SuggestedInterfaces []string  `json:"suggested-interfaces"`
+}
+
+func (x *cmdDebugDenials) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	var accesses []*deniedAccess
+	snapName := string(x.Positionals.Snap)
+	if err := x.client.DebugGet("denials", &accesses, map[string]string{"snap": snapName}); err != nil {
+		return err
+	}
+	if len(accesses) == 0 {
+		if snapName != "" {
+			fmt.Fprintf(Stderr, i18n.G("No accesses denied to snap %q.\n"), snapName)
+		} else {
+			fmt.Fprintln(Stderr, i18n.G("No accesses denied to snaps."))
+		}
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+	fmt.Fprintln(w, i18n.G("Snap\tApp\tRev\tCount\tLast\tDenied\tSuggested interfaces"))
+	for _, access := range accesses {
+		app := access.App
+		if access.Hook != "" {
+			// TRANSLATORS: %s is a hook name
+			app = fmt.Sprintf(i18n.G("%s (hook)"), access.Hook)
+		}
+		suggested := "-"
+		if len(access.SuggestedInterfaces) > 0 {
+			suggested = strings.Join(access.SuggestedInterfaces, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n", access.Snap, app, access.Revision,
+			access.Count, x.fmtTime(access.LastSeen), access.Description, suggested)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+const denialsJSON = `{"type": "sync", "result": [
+	{"snap": "denied", "app": "app", "revision": "1", "description": "open /dev/video0 (r)", "count": 2,
+	 "first-seen": "2022-06-01T12:00:00Z", "last-seen": "2022-06-01T12:00:02Z", "suggested-interfaces": ["camera"],
+	 "denial": {"sandbox": "apparmor", "label": "snap.denied.app", "operation": "open", "path": "/dev/video0", "mask": "r"}},
+	{"snap": "denied", "hook": "configure", "revision": "1", "description": "syscall 165 (arch c000003e)", "count": 1,
+	 "first-seen": "2022-06-01T12:00:03Z", "last-seen": "2022-06-01T12:00:03Z",
+	 "denial": {"sandbox": "seccomp", "label": "snap.denied.hook.configure", "syscall": "165", "arch": "c000003e"}}
+]}`
+
+func (s *SnapSuite) TestDebugDenials(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/debug")
+		c.Check(r.URL.Query().Get("aspect"), check.Equals, "denials")
+		c.Check(r.URL.Query().Get("snap"), check.Equals, "denied")
+		fmt.Fprintln(w, denialsJSON)
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "denials", "--abs-time", "denied"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `
+Snap    App               Rev  Count  Last                  Denied                       Suggested interfaces
+denied  app               1    2      2022-06-01T12:00:02Z  open /dev/video0 (r)         camera
+denied  configure (hook)  1    1      2022-06-01T12:00:03Z  syscall 165 (arch c000003e)  -
+`[1:])
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapSuite) TestDebugDenialsNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "denials"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No accesses denied to snaps.\n")
+
+	s.ResetStdStreams()
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"debug", "denials", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No accesses denied to snap \"foo\".\n")
+}
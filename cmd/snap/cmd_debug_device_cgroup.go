@@ -0,0 +1,136 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdDebugDeviceCgroup struct {
+	clientMixin
+	Positionals struct {
+		SnapApp string `required:"yes"`
+	} `positional-args:"true"`
+}
+
+var shortDebugDeviceCgroupHelp = i18n.G("Show the devices the device cgroup of an app allows")
+var longDebugDeviceCgroupHelp = i18n.G(`
+The device-cgroup command reads back the devices that the device cgroup set
+up by snap-confine for the given app allows access to, from the map of its
+eBPF program with cgroup v2 or from devices.list with cgroup v1, and shows
+them alongside the udev rules of the interfaces tagging devices for the app
+and the devices tagged in the udev database.
+
+Tagged devices the device cgroup does not allow, and allowed devices that
+are neither tagged nor allowed to all apps, are reported as mismatches and
+make the command fail.
+`)
+
+func init() {
+	addDebugCommand("device-cgroup", shortDebugDeviceCgroupHelp, longDebugDeviceCgroupHelp,
+		func() flags.Commander {
+			return &cmdDebugDeviceCgroup{}
+		}, nil, []argDesc{{
+			// TRANSLATORS: This needs to begin with < and end with >
+			name: i18n.G("<snap>.<app>"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			desc: i18n.G("App of a snap to show the device cgroup of"),
+		}})
+}
+
+type deviceCgroup struct {
+	SecurityTag          string   `json:"security-tag"`
+	UdevTag              string   `json:"udev-tag"`
+	CgroupVersion        int      `json:"cgroup-version"`
+	ControlsDeviceCgroup bool     `json:"controls-device-cgroup"`
+	UdevRules            []string `json:"udev-rules"`
+	Found                bool     `json:"found"`
+	Allowed              []string `json:"allowed"`
+	Tagged               []string `json:"tagged"`
+	Missing              []string `json:"missing"`
+	Unexpected           []string `json:"unexpected"`
+}
+
+func printDeviceList(w io.Writer, header string, lines []string) {
+	if len(lines) == 0 {
+		fmt.Fprintf(w, "%s:\t-\n", header)
+		return
+	}
+	fmt.Fprintf(w, "%s:\n", header)
+	for _, line := range lines {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+}
+
+func (x *cmdDebugDeviceCgroup) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	var dc deviceCgroup
+	if err := x.client.DebugGet("device-cgroup", &dc, map[string]string{"snap": x.Positionals.SnapApp}); err != nil {
+		return err
+	}
+
+	w := tabWriter()
+	fmt.Fprintf(w, "security-tag:\t%s\n", dc.SecurityTag)
+	fmt.Fprintf(w, "udev-tag:\t%s\n", dc.UdevTag)
+	fmt.Fprintf(w, "cgroup:\tv%d\n", dc.CgroupVersion)
+	if dc.ControlsDeviceCgroup {
+		fmt.Fprintf(w, "controls-device-cgroup:\ttrue\n")
+	}
+	var rules []string
+	for _, rule := range dc.UdevRules {
+		// drop the comment naming the interface
+		for _, line := range strings.Split(rule, "\n") {
+			if !strings.HasPrefix(line, "#") {
+				rules = append(rules, line)
+			}
+		}
+	}
+	printDeviceList(w, "udev-rules", rules)
+	printDeviceList(w, "tagged", dc.Tagged)
+	if !dc.Found {
+		fmt.Fprintf(w, "allowed:\t-\n")
+		w.Flush()
+		switch {
+		case len(dc.Tagged) > 0 || len(dc.UdevRules) > 0:
+			fmt.Fprintf(Stderr, i18n.G("No device cgroup for %q, it is set up the next time the app is run.\n"), x.Positionals.SnapApp)
+		default:
+			fmt.Fprintf(Stderr, i18n.G("No device cgroup for %q, no device is tagged for it.\n"), x.Positionals.SnapApp)
+		}
+		return nil
+	}
+	printDeviceList(w, "allowed", dc.Allowed)
+	if len(dc.Missing) == 0 && len(dc.Unexpected) == 0 {
+		w.Flush()
+		return nil
+	}
+	printDeviceList(w, "missing", dc.Missing)
+	printDeviceList(w, "unexpected", dc.Unexpected)
+	w.Flush()
+	return fmt.Errorf(i18n.G("device cgroup of %q does not match the devices tagged for it"), x.Positionals.SnapApp)
+}
@@ -0,0 +1,115 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestDebugDeviceCgroup(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/debug")
+		c.Check(r.URL.Query().Get("aspect"), check.Equals, "device-cgroup")
+		c.Check(r.URL.Query().Get("snap"), check.Equals, "foo.app")
+		fmt.Fprintln(w, `{"type": "sync", "result": {
+			"snap": "foo", "app": "app", "security-tag": "snap.foo.app", "udev-tag": "snap_foo_app",
+			"cgroup-version": 2, "found": true,
+			"udev-rules": ["# camera\nKERNEL==\"video[0-9]*\", TAG+=\"snap_foo_app\""],
+			"allowed": ["c 1:3", "c 81:0", "c 136:*"],
+			"tagged": ["c 81:0"]}}`)
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "device-cgroup", "foo.app"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `
+security-tag:  snap.foo.app
+udev-tag:      snap_foo_app
+cgroup:        v2
+udev-rules:
+  KERNEL=="video[0-9]*", TAG+="snap_foo_app"
+tagged:
+  c 81:0
+allowed:
+  c 1:3
+  c 81:0
+  c 136:*
+`[1:])
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapSuite) TestDebugDeviceCgroupMismatch(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": {
+			"snap": "foo", "app": "app", "security-tag": "snap.foo.app", "udev-tag": "snap_foo_app",
+			"cgroup-version": 1, "found": true,
+			"udev-rules": ["# camera\nKERNEL==\"video[0-9]*\", TAG+=\"snap_foo_app\""],
+			"allowed": ["b 8:1", "c 81:0"],
+			"tagged": ["c 81:0", "c 81:1"],
+			"missing": ["c 81:1"],
+			"unexpected": ["b 8:1"]}}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "device-cgroup", "foo.app"})
+	c.Assert(err, check.ErrorMatches, `device cgroup of "foo.app" does not match the devices tagged for it`)
+	c.Check(s.Stdout(), check.Equals, `
+security-tag:  snap.foo.app
+udev-tag:      snap_foo_app
+cgroup:        v1
+udev-rules:
+  KERNEL=="video[0-9]*", TAG+="snap_foo_app"
+tagged:
+  c 81:0
+  c 81:1
+allowed:
+  b 8:1
+  c 81:0
+missing:
+  c 81:1
+unexpected:
+  b 8:1
+`[1:])
+}
+
+func (s *SnapSuite) TestDebugDeviceCgroupNotFound(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": {
+			"snap": "foo", "app": "app", "security-tag": "snap.foo.app", "udev-tag": "snap_foo_app",
+			"cgroup-version": 2, "found": false, "udev-rules": [], "allowed": [], "tagged": []}}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "device-cgroup", "foo.app"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `
+security-tag:  snap.foo.app
+udev-tag:      snap_foo_app
+cgroup:        v2
+udev-rules:    -
+tagged:        -
+allowed:       -
+`[1:])
+	c.Check(s.Stderr(), check.Equals, "No device cgroup for \"foo.app\", no device is tagged for it.\n")
+}
@@ -0,0 +1,131 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdDebugSandboxProfile struct {
+	clientMixin
+	Backend     string `long:"backend"`
+	Positionals struct {
+		SnapApp string `required:"yes"`
+	} `positional-args:"true"`
+}
+
+var shortDebugSandboxProfileHelp = i18n.G("Show the sandbox profile snippets of a snap")
+var longDebugSandboxProfileHelp = i18n.G(`
+The sandbox-profile command shows the snippets that the interfaces
+contributed to the security profiles of the given snap, or of one of its
+apps, grouped by security backend (apparmor, seccomp, udev, dbus, mount,
+kmod), along with the plug or slot, or their connection, that contributed
+each of them.
+
+Only the currently installed revision of the snap can be inspected.
+`)
+
+func init() {
+	addDebugCommand("sandbox-profile", shortDebugSandboxProfileHelp, longDebugSandboxProfileHelp,
+		func() flags.Commander {
+			return &cmdDebugSandboxProfile{}
+		}, map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"backend": i18n.G("Only show the snippets of the given security backend"),
+		}, []argDesc{{
+			// TRANSLATORS: This needs to begin with < and end with >
+			name: i18n.G("<snap>|<snap.app>"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			desc: i18n.G("Snap, or app of a snap, to show the snippets of"),
+		}})
+}
+
+type sandboxSnippets struct {
+	Interface string          `json:"interface"`
+	Plug      *client.PlugRef `json:"plug,omitempty"`
+	Slot      *client.SlotRef `json:"slot,omitempty"`
+	Connected bool            `json:"connected,omitempty"`
+	Tag       string          `json:"tag,omitempty"`
+	Snippets  []string        `json:"snippets"`
+}
+
+type sandboxProfile struct {
+	Snap     string `json:"snap"`
+	Revision string `json:"revision"`
+	App      string `json:"app,omitempty"`
+	Backends []struct {
+		Name     string             `json:"name"`
+		Snippets []*sandboxSnippets `json:"snippets"`
+	} `json:"backends"`
+}
+
+func (s *sandboxSnippets) source() string {
+	var source string
+	switch {
+	case s.Connected:
+		source = fmt.Sprintf("plug %s connected to slot %s", endpoint(s.Plug.Snap, s.Plug.Name), endpoint(s.Slot.Snap, s.Slot.Name))
+	case s.Plug != nil:
+		source = fmt.Sprintf("plug %s", endpoint(s.Plug.Snap, s.Plug.Name))
+	case s.Slot != nil:
+		source = fmt.Sprintf("slot %s", endpoint(s.Slot.Snap, s.Slot.Name))
+	}
+	if s.Tag != "" {
+		source += fmt.Sprintf(" (%s)", s.Tag)
+	}
+	return source
+}
+
+func (x *cmdDebugSandboxProfile) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	var profile sandboxProfile
+	if err := x.client.DebugGet("sandbox-profile", &profile, map[string]string{"snap": x.Positionals.SnapApp}); err != nil {
+		return err
+	}
+
+	found := false
+	for _, backend := range profile.Backends {
+		if x.Backend != "" && backend.Name != x.Backend {
+			continue
+		}
+		found = true
+		fmt.Fprintf(Stdout, "== %s\n", backend.Name)
+		for _, snippets := range backend.Snippets {
+			fmt.Fprintf(Stdout, "-- %s: %s\n", snippets.Interface, snippets.source())
+			fmt.Fprintf(Stdout, "%s\n", strings.Join(snippets.Snippets, "\n"))
+		}
+	}
+	if !found {
+		if x.Backend != "" {
+			fmt.Fprintf(Stderr, i18n.G("No %s snippets contributed by interfaces to %q.\n"), x.Backend, x.Positionals.SnapApp)
+		} else {
+			fmt.Fprintf(Stderr, i18n.G("No snippets contributed by interfaces to %q.\n"), x.Positionals.SnapApp)
+		}
+	}
+	return nil
+}
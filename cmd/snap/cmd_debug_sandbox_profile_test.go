@@ -0,0 +1,88 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+const sandboxProfileJSON = `{"type": "sync", "result": {
+	"snap": "consumer",
+	"revision": "1",
+	"app": "app",
+	"backends": [
+		{"name": "apparmor", "snippets": [
+			{"interface": "home", "plug": {"snap": "consumer", "plug": "home"}, "slot": {"snap": "core", "slot": "home"}, "connected": true, "tag": "snap.consumer.app",
+			 "snippets": ["owner @{HOME}/ r,", "owner @{HOME}/[^.]** rwkl,"]},
+			{"interface": "content", "slot": {"snap": "consumer", "slot": "data"}, "snippets": ["# update-ns"]}
+		]},
+		{"name": "kmod", "snippets": [
+			{"interface": "kvm", "plug": {"snap": "consumer", "plug": "kvm"}, "snippets": ["kvm"]}
+		]}
+	]
+}}`
+
+func (s *SnapSuite) TestDebugSandboxProfile(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/debug")
+		c.Check(r.URL.Query().Get("aspect"), check.Equals, "sandbox-profile")
+		c.Check(r.URL.Query().Get("snap"), check.Equals, "consumer.app")
+		fmt.Fprintln(w, sandboxProfileJSON)
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "sandbox-profile", "consumer.app"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `== apparmor
+-- home: plug consumer:home connected to slot :home (snap.consumer.app)
+owner @{HOME}/ r,
+owner @{HOME}/[^.]** rwkl,
+-- content: slot consumer:data
+# update-ns
+== kmod
+-- kvm: plug consumer:kvm
+kvm
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapSuite) TestDebugSandboxProfileBackend(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, sandboxProfileJSON)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "sandbox-profile", "--backend", "kmod", "consumer.app"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "== kmod\n-- kvm: plug consumer:kvm\nkvm\n")
+	c.Check(s.Stderr(), check.Equals, "")
+
+	s.ResetStdStreams()
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"debug", "sandbox-profile", "--backend", "udev", "consumer.app"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No udev snippets contributed by interfaces to \"consumer.app\".\n")
+}
@@ -40,6 +40,9 @@ var longQuotaHelp = i18n.G(`
 The quota command shows information about a quota group, including the set of 
 snaps and any sub-groups it contains, as well as its resource constraints and 
 the current usage of those constrained resources.
+
+With --history, the resource usage of the group sampled periodically by snapd
+over the last day is shown as well.
 `)
 
 var shortQuotasHelp = i18n.G("Show quota groups")
@@ -95,8 +98,24 @@ The journal limits can be increased and decreased after being set on a group.
 Setting a journal limit will cause the snaps in the group to be put into the same
 journal namespace. This will affect the behaviour of the log command.
 
-New quotas can be set on existing quota groups, but existing quotas cannot be removed
-from a quota group, without removing and recreating the entire group.
+The io limits set the maximum read and write bandwidth in bytes per second, the
+maximum read and write operations per second, and the relative io weight of the
+group. The limits apply to the block device given with --io-device, or to the
+device holding the snap data if not given. The io limits can be increased and
+decreased after being set on a group, and removed by setting them to "none".
+The io limits require cgroup v2.
+
+The --on-limit option sets what snapd does when the processes of the group are
+killed for running out of memory, or are throttled by the CPU limit of the group
+for a sustained period of time. With "warn" a warning is recorded, with
+"restart" the services of the snaps in the group are restarted as well, and
+with "raise" the memory limit of the group is raised to the value given with
+--memory-ceiling when the group runs out of memory. Each such event is also
+visible as a change. The limit policy requires cgroup v2.
+
+New quotas can be set on existing quota groups, but existing quotas other than the
+io limits cannot be removed from a quota group, without removing and recreating the
+entire group.
 
 Adding new snaps to a quota group will result in all non-disabled services in 
 that snap being restarted.
@@ -115,11 +134,22 @@ func init() {
 			"threads":            i18n.G("Threads quota"),
 			"journal-size":       i18n.G("Journal size quota"),
 			"journal-rate-limit": i18n.G("Journal rate limit as <message count>/<message period>"),
+			"io-device":          i18n.G("Block device or path on it the io quotas apply to"),
+			"io-read-max":        i18n.G("IO read bandwidth quota per second"),
+			"io-write-max":       i18n.G("IO write bandwidth quota per second"),
+			"io-read-iops":       i18n.G("IO read operations per second quota"),
+			"io-write-iops":      i18n.G("IO write operations per second quota"),
+			"io-weight":          i18n.G("IO weight between 1 and 10000"),
+			"on-limit":           i18n.G("Action when the group hits its limits: warn, restart or raise"),
+			"memory-ceiling":     i18n.G("Memory limit the group is raised to with --on-limit=raise"),
 			"parent":             i18n.G("Parent quota group"),
 		}), nil)
 	cmd.hidden = true
 
-	cmd = addCommand("quota", shortQuotaHelp, longQuotaHelp, func() flags.Commander { return &cmdQuota{} }, nil, nil)
+	cmd = addCommand("quota", shortQuotaHelp, longQuotaHelp, func() flags.Commander { return &cmdQuota{} },
+		timeDescs.also(map[string]string{
+			"history": i18n.G("Show the recorded resource usage history of the group"),
+		}), nil)
 	cmd.hidden = true
 
 	cmd = addCommand("quotas", shortQuotasHelp, longQuotasHelp, func() flags.Commander { return &cmdQuotas{} }, nil, nil)
@@ -138,6 +168,14 @@ type cmdSetQuota struct {
 	ThreadsMax       string `long:"threads" optional:"true"`
 	JournalSizeMax   string `long:"journal-size" optional:"true"`
 	JournalRateLimit string `long:"journal-rate-limit" optional:"true"`
+	IODevice         string `long:"io-device" optional:"true"`
+	IOReadMax        string `long:"io-read-max" optional:"true"`
+	IOWriteMax       string `long:"io-write-max" optional:"true"`
+	IOReadIOPS       string `long:"io-read-iops" optional:"true"`
+	IOWriteIOPS      string `long:"io-write-iops" optional:"true"`
+	IOWeight         string `long:"io-weight" optional:"true"`
+	OnLimit          string `long:"on-limit" optional:"true"`
+	MemoryCeiling    string `long:"memory-ceiling" optional:"true"`
 	Parent           string `long:"parent" optional:"true"`
 	Positional       struct {
 		GroupName string              `positional-arg-name:"<group-name>" required:"true"`
@@ -264,17 +302,102 @@ func (x *cmdSetQuota) parseQuotas() (*client.QuotaValues, error) {
 		}
 	}
 
+	if x.hasIOQuotaSet() {
+		quotaValues.IO = &client.QuotaIOValues{}
+		// "none" removes the limit from an existing group
+		ioValue := func(name, value string) string {
+			if value == "none" {
+				quotaValues.IO.Unset = append(quotaValues.IO.Unset, name)
+				return ""
+			}
+			return value
+		}
+		quotaValues.IO.Device = ioValue("device", x.IODevice)
+		readMax := ioValue("read-bandwidth", x.IOReadMax)
+		writeMax := ioValue("write-bandwidth", x.IOWriteMax)
+		readIOPS := ioValue("read-iops", x.IOReadIOPS)
+		writeIOPS := ioValue("write-iops", x.IOWriteIOPS)
+		weight := ioValue("weight", x.IOWeight)
+		if readMax != "" {
+			value, err := strutil.ParseByteSize(readMax)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse io read bandwidth %q: %v", readMax, err)
+			}
+			quotaValues.IO.ReadBandwidth = quantity.Size(value)
+		}
+		if writeMax != "" {
+			value, err := strutil.ParseByteSize(writeMax)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse io write bandwidth %q: %v", writeMax, err)
+			}
+			quotaValues.IO.WriteBandwidth = quantity.Size(value)
+		}
+		if readIOPS != "" {
+			value, err := strconv.ParseUint(readIOPS, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("cannot use io read iops value %q", readIOPS)
+			}
+			quotaValues.IO.ReadIOPS = int(value)
+		}
+		if writeIOPS != "" {
+			value, err := strconv.ParseUint(writeIOPS, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("cannot use io write iops value %q", writeIOPS)
+			}
+			quotaValues.IO.WriteIOPS = int(value)
+		}
+		if weight != "" {
+			value, err := strconv.ParseUint(weight, 10, 32)
+			if err != nil || value < 1 || value > 10000 {
+				return nil, fmt.Errorf("cannot use io weight value %q: weight must be between 1 and 10000", weight)
+			}
+			quotaValues.IO.Weight = int(value)
+		}
+	}
+
 	return &quotaValues, nil
 }
 
+func (x *cmdSetQuota) hasIOQuotaSet() bool {
+	return x.IODevice != "" || x.IOReadMax != "" || x.IOWriteMax != "" ||
+		x.IOReadIOPS != "" || x.IOWriteIOPS != "" || x.IOWeight != ""
+}
+
 func (x *cmdSetQuota) hasQuotaSet() bool {
 	return x.MemoryMax != "" || x.CPUMax != "" || x.CPUSet != "" ||
-		x.ThreadsMax != "" || x.JournalSizeMax != "" || x.JournalRateLimit != ""
+		x.ThreadsMax != "" || x.JournalSizeMax != "" || x.JournalRateLimit != "" ||
+		x.hasIOQuotaSet()
+}
+
+func (x *cmdSetQuota) parseLimitPolicy() (*client.QuotaLimitPolicy, error) {
+	if x.OnLimit == "" && x.MemoryCeiling == "" {
+		return nil, nil
+	}
+	if x.OnLimit == "" {
+		return nil, fmt.Errorf("cannot use --memory-ceiling without --on-limit=raise")
+	}
+
+	policy := &client.QuotaLimitPolicy{
+		Action: x.OnLimit,
+	}
+	if x.MemoryCeiling != "" {
+		value, err := strutil.ParseByteSize(x.MemoryCeiling)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse memory ceiling %q: %v", x.MemoryCeiling, err)
+		}
+		policy.MemoryCeiling = quantity.Size(value)
+	}
+	return policy, nil
 }
 
 func (x *cmdSetQuota) Execute(args []string) (err error) {
 	quotaProvided := x.hasQuotaSet()
 
+	policy, err := x.parseLimitPolicy()
+	if err != nil {
+		return err
+	}
+
 	names := installedSnapNames(x.Positional.Snaps)
 
 	// figure out if the group exists or not to make error messages more useful
@@ -286,6 +409,12 @@ func (x *cmdSetQuota) Execute(args []string) (err error) {
 	var chgID string
 
 	switch {
+	case policy != nil && !quotaProvided && x.Parent == "" && len(x.Positional.Snaps) == 0:
+		// only the limit policy of the group is set, which is done below
+		if !groupExists {
+			return fmt.Errorf("cannot create quota group without any limit")
+		}
+
 	case !quotaProvided && x.Parent == "" && len(x.Positional.Snaps) == 0:
 		// no snaps were specified, no memory limit was specified, and no parent
 		// was specified, so just the group name was provided - this is not
@@ -347,11 +476,33 @@ func (x *cmdSetQuota) Execute(args []string) (err error) {
 		panic("impossible set of options")
 	}
 
-	if _, err := x.wait(chgID); err != nil {
-		if err == noWait {
-			return nil
+	if chgID != "" {
+		if _, err := x.wait(chgID); err != nil {
+			if err != noWait {
+				return err
+			}
+			if policy == nil {
+				return nil
+			}
+			if !groupExists {
+				// the group does not exist until the change is done
+				return fmt.Errorf("cannot set the limit policy of a new quota group without waiting for it to be created")
+			}
+		}
+	}
+
+	if policy != nil {
+		// the limit policy can only be set on existing groups
+		chgID, err = x.client.SetQuotaLimitPolicy(x.Positional.GroupName, policy)
+		if err != nil {
+			return err
+		}
+		if _, err := x.wait(chgID); err != nil {
+			if err == noWait {
+				return nil
+			}
+			return err
 		}
-		return err
 	}
 
 	return nil
@@ -359,7 +510,9 @@ func (x *cmdSetQuota) Execute(args []string) (err error) {
 
 type cmdQuota struct {
 	clientMixin
+	timeMixin
 
+	History    bool `long:"history"`
 	Positional struct {
 		GroupName string `positional-arg-name:"<group-name>" required:"true"`
 	} `positional-args:"yes"`
@@ -415,6 +568,34 @@ func (x *cmdQuota) Execute(args []string) (err error) {
 			fmt.Fprintf(w, "  journal-rate:\t%d/%s\n", group.Constraints.Journal.RateCount, group.Constraints.Journal.RatePeriod)
 		}
 	}
+	if io := group.Constraints.IO; io != nil {
+		if io.Device != "" {
+			fmt.Fprintf(w, "  io-device:\t%s\n", io.Device)
+		}
+		if io.ReadBandwidth != 0 {
+			fmt.Fprintf(w, "  io-read-max:\t%s/s\n", strings.TrimSpace(fmtSize(int64(io.ReadBandwidth))))
+		}
+		if io.WriteBandwidth != 0 {
+			fmt.Fprintf(w, "  io-write-max:\t%s/s\n", strings.TrimSpace(fmtSize(int64(io.WriteBandwidth))))
+		}
+		if io.ReadIOPS != 0 {
+			fmt.Fprintf(w, "  io-read-iops:\t%d\n", io.ReadIOPS)
+		}
+		if io.WriteIOPS != 0 {
+			fmt.Fprintf(w, "  io-write-iops:\t%d\n", io.WriteIOPS)
+		}
+		if io.Weight != 0 {
+			fmt.Fprintf(w, "  io-weight:\t%d\n", io.Weight)
+		}
+	}
+
+	if policy := group.LimitPolicy; policy != nil {
+		fmt.Fprintf(w, "limit-policy:\n")
+		fmt.Fprintf(w, "  action:\t%s\n", policy.Action)
+		if policy.MemoryCeiling != 0 {
+			fmt.Fprintf(w, "  memory-ceiling:\t%s\n", strings.TrimSpace(fmtSize(int64(policy.MemoryCeiling))))
+		}
+	}
 
 	memoryUsage := "0B"
 	currentThreads := 0
@@ -444,9 +625,43 @@ func (x *cmdQuota) Execute(args []string) (err error) {
 		}
 	}
 
+	if x.History {
+		// the history is a table of its own, so don't let it affect the
+		// alignment of the group information above
+		w.Flush()
+		x.showHistory(group.History)
+	}
+
 	return nil
 }
 
+func (x *cmdQuota) showHistory(history []client.QuotaUsageSample) {
+	if len(history) == 0 {
+		fmt.Fprintln(Stdout, i18n.G("history: no resource usage recorded yet"))
+		return
+	}
+
+	fmt.Fprintln(Stdout, "history:")
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("  Time\tCPU\tMemory\tThreads\tJournal"))
+	for i, sample := range history {
+		// the CPU usage is the CPU time used in between two samples,
+		// so it is not known for the first sample
+		cpu := "-"
+		if i > 0 {
+			prev := history[i-1]
+			if elapsed := sample.Time.Sub(prev.Time); elapsed > 0 && sample.CPUTime >= prev.CPUTime {
+				cpu = fmt.Sprintf("%.1f%%", 100*float64(sample.CPUTime-prev.CPUTime)/float64(elapsed))
+			}
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%d\t%s\n", x.fmtTime(sample.Time), cpu,
+			strings.TrimSpace(fmtSize(int64(sample.Memory))), sample.Threads,
+			strings.TrimSpace(fmtSize(int64(sample.JournalSize))))
+	}
+}
+
 type cmdRemoveQuota struct {
 	waitMixin
 
@@ -528,6 +743,25 @@ func (x *cmdQuotas) Execute(args []string) (err error) {
 			}
 		}
 
+		// format io constraint as io-read-max=xMB/s,io-write-max=xMB/s,io-read-iops=N,io-write-iops=N,io-weight=N
+		if io := q.Constraints.IO; io != nil {
+			if io.ReadBandwidth != 0 {
+				grpConstraints = append(grpConstraints, "io-read-max="+strings.TrimSpace(fmtSize(int64(io.ReadBandwidth)))+"/s")
+			}
+			if io.WriteBandwidth != 0 {
+				grpConstraints = append(grpConstraints, "io-write-max="+strings.TrimSpace(fmtSize(int64(io.WriteBandwidth)))+"/s")
+			}
+			if io.ReadIOPS != 0 {
+				grpConstraints = append(grpConstraints, "io-read-iops="+strconv.Itoa(io.ReadIOPS))
+			}
+			if io.WriteIOPS != 0 {
+				grpConstraints = append(grpConstraints, "io-write-iops="+strconv.Itoa(io.WriteIOPS))
+			}
+			if io.Weight != 0 {
+				grpConstraints = append(grpConstraints, "io-weight="+strconv.Itoa(io.Weight))
+			}
+		}
+
 		// format current resource values as memory=N,threads=N
 		var grpCurrent []string
 		if q.Current != nil {
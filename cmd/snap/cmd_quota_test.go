@@ -108,6 +108,8 @@ type fakeQuotaGroupPostHandlerOpts struct {
 	cpuCount      int
 	cpuPercentage int
 	cpuSet        []int
+	limitAction   string
+	memoryCeiling int64
 }
 
 type quotasEnsureBodyConstraintsCPU struct {
@@ -126,12 +128,18 @@ type quotasEnsureBodyConstraints struct {
 	CPUSet  quotasEnsureBodyConstraintsCPUSet `json:"cpu-set,omitempty"`
 }
 
+type quotasEnsureBodyLimitPolicy struct {
+	Action        string `json:"action"`
+	MemoryCeiling int64  `json:"memory-ceiling,omitempty"`
+}
+
 type quotasEnsureBody struct {
-	Action      string                      `json:"action"`
-	GroupName   string                      `json:"group-name,omitempty"`
-	ParentName  string                      `json:"parent,omitempty"`
-	Snaps       []string                    `json:"snaps,omitempty"`
-	Constraints quotasEnsureBodyConstraints `json:"constraints,omitempty"`
+	Action      string                       `json:"action"`
+	GroupName   string                       `json:"group-name,omitempty"`
+	ParentName  string                       `json:"parent,omitempty"`
+	Snaps       []string                     `json:"snaps,omitempty"`
+	Constraints quotasEnsureBodyConstraints  `json:"constraints,omitempty"`
+	LimitPolicy *quotasEnsureBodyLimitPolicy `json:"limit-policy,omitempty"`
 }
 
 func (s *quotaSuite) makeFakeQuotaPostHandler(c *check.C, opts fakeQuotaGroupPostHandlerOpts) func(w http.ResponseWriter, r *http.Request) {
@@ -169,6 +177,12 @@ func (s *quotaSuite) makeFakeQuotaPostHandler(c *check.C, opts fakeQuotaGroupPos
 			if len(opts.cpuSet) != 0 {
 				exp.Constraints.CPUSet.CPUs = opts.cpuSet
 			}
+			if opts.limitAction != "" {
+				exp.LimitPolicy = &quotasEnsureBodyLimitPolicy{
+					Action:        opts.limitAction,
+					MemoryCeiling: opts.memoryCeiling,
+				}
+			}
 
 			postJSON := quotasEnsureBody{}
 			err := jsonutil.DecodeWithNumber(bytes.NewReader(buf), &postJSON)
@@ -255,6 +269,49 @@ func (s *quotaSuite) TestParseQuotas(c *check.C) {
 	}
 }
 
+func (s *quotaSuite) TestParseIOQuotas(c *check.C) {
+	for _, testData := range []struct {
+		device    string
+		readMax   string
+		writeMax  string
+		readIOPS  string
+		writeIOPS string
+		weight    string
+
+		// Use the JSON representation of the quota, as it's easier to handle in the test data
+		quotas string
+		err    string
+	}{
+		{readMax: "10MB", quotas: `{"io":{"read-bandwidth":10000000}}`},
+		{device: "/dev/sda", writeMax: "1KB", quotas: `{"io":{"device":"/dev/sda","write-bandwidth":1000}}`},
+		{readIOPS: "100", writeIOPS: "200", quotas: `{"io":{"read-iops":100,"write-iops":200}}`},
+		{weight: "500", quotas: `{"io":{"weight":500}}`},
+		{readMax: "none", weight: "none", quotas: `{"io":{"unset":["read-bandwidth","weight"]}}`},
+		{device: "none", writeIOPS: "10", quotas: `{"io":{"write-iops":10,"unset":["device"]}}`},
+
+		// Error cases
+		{readMax: "xxx", err: `cannot parse io read bandwidth "xxx": cannot parse "xxx": no numerical prefix`},
+		{writeMax: "-1MB", err: `cannot parse io write bandwidth "-1MB": .*`},
+		{readIOPS: "-3", err: `cannot use io read iops value "-3"`},
+		{writeIOPS: "x", err: `cannot use io write iops value "x"`},
+		{weight: "0", err: `cannot use io weight value "0": weight must be between 1 and 10000`},
+		{weight: "10001", err: `cannot use io weight value "10001": weight must be between 1 and 10000`},
+	} {
+		quotas, err := main.ParseIOQuotaValues(testData.device, testData.readMax, testData.writeMax,
+			testData.readIOPS, testData.writeIOPS, testData.weight)
+		testLabel := check.Commentf("%v", testData)
+		if testData.err == "" {
+			c.Check(err, check.IsNil, testLabel)
+			var jsonQuota bytes.Buffer
+			err := json.NewEncoder(&jsonQuota).Encode(quotas)
+			c.Assert(err, check.IsNil, testLabel)
+			c.Check(strings.TrimSpace(jsonQuota.String()), check.Equals, testData.quotas, testLabel)
+		} else {
+			c.Check(err, check.ErrorMatches, testData.err, testLabel)
+		}
+	}
+}
+
 func (s *quotaSuite) TestSetQuotaInvalidArgs(c *check.C) {
 	for _, args := range []struct {
 		args []string
@@ -351,6 +408,65 @@ snaps:
 	c.Check(s.quotaPostHandlerCalls, check.Equals, 0)
 }
 
+func (s *quotaSuite) TestGetQuotaGroupHistory(c *check.C) {
+	const json = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name":"foo",
+			"constraints": { "memory": 1000 },
+			"current": { "memory": 900 },
+			"history": [
+				{"time": "2022-03-01T10:00:00Z", "cpu-time": 1000000000, "memory": 800, "threads": 2},
+				{"time": "2022-03-01T10:05:00Z", "cpu-time": 31000000000, "memory": 900, "threads": 3, "journal-size": 2048}
+			]
+		}
+	}`
+
+	s.RedirectClientToTestServer(s.makeFakeGetQuotaGroupHandler(c, json))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quota", "--history", "--abs-time", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `
+name:  foo
+constraints:
+  memory:  1000B
+current:
+  memory:  900B
+history:
+  Time                  CPU    Memory  Threads  Journal
+  2022-03-01T10:00:00Z  -      800B    2        0B
+  2022-03-01T10:05:00Z  10.0%  900B    3        2048B
+`[1:])
+}
+
+func (s *quotaSuite) TestGetQuotaGroupHistoryEmpty(c *check.C) {
+	const json = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name":"foo",
+			"constraints": { "memory": 1000 },
+			"current": { "memory": 900 }
+		}
+	}`
+
+	s.RedirectClientToTestServer(s.makeFakeGetQuotaGroupHandler(c, json))
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"quota", "--history", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `
+name:  foo
+constraints:
+  memory:  1000B
+current:
+  memory:  900B
+history: no resource usage recorded yet
+`[1:])
+}
+
 func (s *quotaSuite) TestGetMemoryQuotaGroupSimple(c *check.C) {
 	const jsonTemplate = `{
 		"type": "sync",
@@ -465,6 +581,36 @@ current:
 	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 1)
 }
 
+func (s *quotaSuite) TestIOQuotaGroupSimple(c *check.C) {
+	const jsonTemplate = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name": "foo",
+			"constraints": {"io":{"device":"/dev/sda","read-bandwidth":10000000,"write-iops":100,"weight":50}}
+		}
+	}`
+
+	s.RedirectClientToTestServer(s.makeFakeGetQuotaGroupHandler(c, jsonTemplate))
+
+	outputTemplate := `
+name:  foo
+constraints:
+  io-device:      /dev/sda
+  io-read-max:    10.0MB/s
+  io-write-iops:  100
+  io-weight:      50
+current:
+`[1:]
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quota", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, outputTemplate)
+	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 1)
+}
+
 func (s *quotaSuite) TestSetQuotaGroupCreateNew(c *check.C) {
 	const postJSON = `{"type": "async", "status-code": 202,"change":"42", "result": []}`
 	fakeHandlerOpts := fakeQuotaGroupPostHandlerOpts{
@@ -619,6 +765,92 @@ func (s *quotaSuite) TestSetQuotaGroupUpdateExisting(c *check.C) {
 	c.Check(s.quotaPostHandlerCalls, check.Equals, 2)
 }
 
+func (s *quotaSuite) TestSetQuotaGroupLimitPolicy(c *check.C) {
+	const postJSON = `{"type": "async", "status-code": 202,"change":"42", "result": []}`
+	fakeHandlerOpts := fakeQuotaGroupPostHandlerOpts{
+		action:        "ensure",
+		body:          postJSON,
+		groupName:     "foo",
+		limitAction:   "raise",
+		memoryCeiling: 4000,
+	}
+
+	const getJson = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name":"foo",
+			"constraints": { "memory": 1000 },
+			"current": { "memory": 500 }
+		}
+	}`
+
+	routes := map[string]http.HandlerFunc{
+		"/v2/quotas":     s.makeFakeQuotaPostHandler(c, fakeHandlerOpts),
+		"/v2/quotas/foo": s.makeFakeGetQuotaGroupHandler(c, getJson),
+		"/v2/changes/42": makeChangesHandler(c),
+	}
+
+	s.RedirectClientToTestServer(dispatchFakeHandlers(c, routes))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"set-quota", "foo", "--on-limit=raise", "--memory-ceiling=4000B"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 1)
+	c.Check(s.quotaPostHandlerCalls, check.Equals, 1)
+}
+
+func (s *quotaSuite) TestSetQuotaGroupLimitPolicyNewGroupUnhappy(c *check.C) {
+	const exists = false
+	s.testSetQuotaGroupUpdateExistingUnhappy(c, "cannot create quota group without any limit", exists, "--on-limit=warn")
+}
+
+func (s *quotaSuite) TestSetQuotaGroupLimitPolicyInvalid(c *check.C) {
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"--memory-ceiling=1GB"}, `cannot use --memory-ceiling without --on-limit=raise`},
+		{[]string{"--on-limit=raise", "--memory-ceiling=lots"}, `cannot parse memory ceiling "lots": .*`},
+	} {
+		cmdArgs := append([]string{"set-quota", "foo"}, t.args...)
+		_, err := main.Parser(main.Client()).ParseArgs(cmdArgs)
+		c.Check(err, check.ErrorMatches, t.err)
+	}
+	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 0)
+	c.Check(s.quotaPostHandlerCalls, check.Equals, 0)
+}
+
+func (s *quotaSuite) TestGetQuotaGroupLimitPolicy(c *check.C) {
+	const json = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name":"foo",
+			"constraints": { "memory": 1000 },
+			"current": { "memory": 900 },
+			"limit-policy": { "action": "raise", "memory-ceiling": 4000 }
+		}
+	}`
+
+	s.RedirectClientToTestServer(s.makeFakeGetQuotaGroupHandler(c, json))
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"quota", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `
+name:  foo
+constraints:
+  memory:  1000B
+limit-policy:
+  action:          raise
+  memory-ceiling:  4000B
+current:
+  memory:  900B
+`[1:])
+}
+
 func (s *quotaSuite) TestRemoveQuotaGroup(c *check.C) {
 	const json = `{"type": "async", "status-code": 202,"change": "42"}`
 	fakeHandlerOpts := fakeQuotaGroupPostHandlerOpts{
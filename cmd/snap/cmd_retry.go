@@ -0,0 +1,79 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdRetry struct {
+	changeIDMixin
+	NoWait bool `long:"no-wait"`
+}
+
+var shortRetryHelp = i18n.G("Retry a failed change")
+
+var longRetryHelp = i18n.G(`
+The retry command runs again the tasks of a failed change, starting from the
+ones that failed, without undoing the work that was already completed.
+
+Only changes whose failed tasks can safely be run again can be retried.
+`)
+
+func init() {
+	addCommand("retry",
+		shortRetryHelp,
+		longRetryHelp,
+		func() flags.Commander {
+			return &cmdRetry{}
+		},
+		changeIDMixinOptDesc.also(waitDescs),
+		changeIDMixinArgDesc,
+	)
+}
+
+func (x *cmdRetry) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	id, err := x.GetChangeID()
+	if err != nil {
+		if err == noChangeFoundOK {
+			return nil
+		}
+		return err
+	}
+	if _, err := x.client.Retry(id); err != nil {
+		return err
+	}
+
+	wmx := &waitMixin{NoWait: x.NoWait}
+	wmx.client = x.client
+	if _, err := wmx.wait(id); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
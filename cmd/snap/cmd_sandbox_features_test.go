@@ -59,3 +59,20 @@ func (s *SnapSuite) TestSandboxFeaturesRequiredButMissing(c *C) {
 	c.Assert(s.Stdout(), Equals, "")
 	c.Assert(s.Stderr(), Equals, "")
 }
+
+func (s *SnapSuite) TestSandboxFeaturesLandlock(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": {"sandbox-features": {"confinement-options": ["devmode"], "landlock": ["abi:1", "abi:2", "confinement"]}}}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "sandbox-features"})
+	c.Assert(err, IsNil)
+	c.Assert(s.Stdout(), Equals, ""+
+		"confinement-options:  devmode\n"+
+		"landlock:             abi:1 abi:2 confinement\n")
+	s.ResetStdStreams()
+
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"debug", "sandbox-features", "--required=landlock:abi:1"})
+	c.Assert(err, IsNil)
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"debug", "sandbox-features", "--required=landlock:abi:3"})
+	c.Assert(err, ErrorMatches, `sandbox feature not available: "landlock:abi:3"`)
+}
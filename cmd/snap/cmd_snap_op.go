@@ -94,6 +94,11 @@ have developer access to the snap, either directly or through the
 store's collaboration feature, and to be logged in (see 'snap help login').
 
 Note a later refresh will typically undo a revision override.
+
+The --plan option shows, for each snap that would be updated, the download
+size, the plugs, slots, services and hooks that would be added or removed,
+changes of the base and of the default content providers, and the snaps
+holding the refresh.
 `)
 
 var longTryHelp = i18n.G(`
@@ -678,6 +683,7 @@ type cmdRefresh struct {
 	Cohort           string                 `long:"cohort"`
 	LeaveCohort      bool                   `long:"leave-cohort"`
 	List             bool                   `long:"list"`
+	Plan             bool                   `long:"plan"`
 	Time             bool                   `long:"time"`
 	IgnoreValidation bool                   `long:"ignore-validation"`
 	IgnoreRunning    bool                   `long:"ignore-running" hidden:"yes"`
@@ -813,6 +819,89 @@ func (x *cmdRefresh) listRefresh() error {
 	return nil
 }
 
+func fmtRefreshCandidateInterfaces(added, removed []client.RefreshCandidateInterface) string {
+	var l []string
+	fmtIface := func(sign string, iface client.RefreshCandidateInterface) string {
+		if iface.Name == iface.Interface {
+			return sign + iface.Name
+		}
+		return fmt.Sprintf("%s%s (%s)", sign, iface.Name, iface.Interface)
+	}
+	for _, iface := range added {
+		l = append(l, fmtIface("+", iface))
+	}
+	for _, iface := range removed {
+		l = append(l, fmtIface("-", iface))
+	}
+	return strings.Join(l, ", ")
+}
+
+func fmtRefreshCandidateNames(added, removed []string) string {
+	l := make([]string, 0, len(added)+len(removed))
+	for _, name := range added {
+		l = append(l, "+"+name)
+	}
+	for _, name := range removed {
+		l = append(l, "-"+name)
+	}
+	return strings.Join(l, ", ")
+}
+
+func (x *cmdRefresh) showRefreshPlan() error {
+	candidates, err := x.client.RefreshCandidates()
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("All snaps up to date."))
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	for _, cand := range candidates {
+		fmt.Fprintf(w, "%s:\n", cand.Name)
+		fmt.Fprintf(w, "  version:\t%s (%s) -> %s (%s)\n", cand.CurrentVersion, cand.CurrentRevision, cand.Version, cand.Revision)
+		download := strutil.SizeToStr(cand.DownloadSize)
+		if cand.DeltaSize > 0 {
+			// TRANSLATORS: %s is the size of the delta download
+			download += fmt.Sprintf(i18n.G(" (delta %s)"), strutil.SizeToStr(cand.DeltaSize))
+		}
+		fmt.Fprintf(w, "  download:\t%s\n", download)
+		if cand.Base != cand.CurrentBase {
+			fmt.Fprintf(w, "  base:\t%s -> %s\n", fmtBase(cand.CurrentBase), fmtBase(cand.Base))
+		}
+		if plugs := fmtRefreshCandidateInterfaces(cand.PlugsAdded, cand.PlugsRemoved); plugs != "" {
+			fmt.Fprintf(w, "  plugs:\t%s\n", plugs)
+		}
+		if slots := fmtRefreshCandidateInterfaces(cand.SlotsAdded, cand.SlotsRemoved); slots != "" {
+			fmt.Fprintf(w, "  slots:\t%s\n", slots)
+		}
+		if services := fmtRefreshCandidateNames(cand.ServicesAdded, cand.ServicesRemoved); services != "" {
+			fmt.Fprintf(w, "  services:\t%s\n", services)
+		}
+		if hooks := fmtRefreshCandidateNames(cand.HooksAdded, cand.HooksRemoved); hooks != "" {
+			fmt.Fprintf(w, "  hooks:\t%s\n", hooks)
+		}
+		if providers := fmtRefreshCandidateNames(cand.ContentProvidersAdded, cand.ContentProvidersRemoved); providers != "" {
+			fmt.Fprintf(w, "  content-providers:\t%s\n", providers)
+		}
+		if len(cand.HeldBy) > 0 {
+			fmt.Fprintf(w, "  held-by:\t%s\n", strings.Join(cand.HeldBy, ", "))
+		}
+	}
+
+	return nil
+}
+
+func fmtBase(base string) string {
+	if base == "" {
+		return "-"
+	}
+	return base
+}
+
 func (x *cmdRefresh) Execute([]string) error {
 	if err := x.setChannelFromCommandline(); err != nil {
 		return err
@@ -828,6 +917,14 @@ func (x *cmdRefresh) Execute([]string) error {
 		return x.showRefreshTimes()
 	}
 
+	if x.Plan {
+		if x.List || len(x.Positional.Snaps) > 0 || x.asksForMode() || x.asksForChannel() {
+			return errors.New(i18n.G("--plan does not accept additional arguments"))
+		}
+
+		return x.showRefreshPlan()
+	}
+
 	if x.List {
 		if len(x.Positional.Snaps) > 0 || x.asksForMode() || x.asksForChannel() {
 			return errors.New(i18n.G("--list does not accept additional arguments"))
@@ -1171,6 +1268,8 @@ func init() {
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"list": i18n.G("Show the new versions of snaps that would be updated with the next refresh"),
 			// TRANSLATORS: This should not start with a lowercase letter.
+			"plan": i18n.G("Show what updating the snaps with the next refresh would change"),
+			// TRANSLATORS: This should not start with a lowercase letter.
 			"time": i18n.G("Show auto refresh information but do not perform a refresh"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"ignore-validation": i18n.G("Ignore validation by other snaps blocking the refresh"),
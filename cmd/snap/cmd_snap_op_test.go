@@ -1324,6 +1324,71 @@ foo +4.2update1 +17 +436MB +bar +-.*
 	c.Check(n, check.Equals, 1)
 }
 
+func (s *SnapSuite) TestRefreshPlan(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			c.Check(r.URL.Query().Get("select"), check.Equals, "refresh-candidates")
+			fmt.Fprintln(w, `{"type": "sync", "result": [
+{"name": "bar", "version": "1.1", "revision": "4", "current-version": "1.0", "current-revision": "3", "download-size": 2000000, "held-by": ["foo", "system"]},
+{"name": "foo", "version": "2.0", "revision": "12", "current-version": "1.0", "current-revision": "11", "base": "core22", "current-base": "core20", "download-size": 436375552, "delta-size": 1000000,
+ "plugs-added": [{"name": "camera", "interface": "camera"}, {"name": "cam2", "interface": "camera"}], "plugs-removed": [{"name": "home", "interface": "home"}],
+ "slots-removed": [{"name": "foo-dbus", "interface": "dbus"}], "services-added": ["daemon"], "hooks-added": ["install"], "hooks-removed": ["remove"],
+ "content-providers-added": ["gtk-common-themes"]}
+]}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--plan"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `bar:
+  version:   1.0 (3) -> 1.1 (4)
+  download:  2MB
+  held-by:   foo, system
+foo:
+  version:            1.0 (11) -> 2.0 (12)
+  download:           436MB (delta 1MB)
+  base:               core20 -> core22
+  plugs:              +camera, +cam2 (camera), -home
+  slots:              -foo-dbus (dbus)
+  services:           +daemon
+  hooks:              +install, -remove
+  content-providers:  +gtk-common-themes
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+	// ensure that the fake server api was actually hit
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapSuite) TestRefreshPlanUpToDate(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--plan"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "All snaps up to date.\n")
+}
+
+func (s *SnapSuite) TestRefreshPlanBadArgs(c *check.C) {
+	for _, args := range [][]string{
+		{"refresh", "--plan", "foo"},
+		{"refresh", "--plan", "--list"},
+		{"refresh", "--plan", "--beta"},
+	} {
+		_, err := snap.Parser(snap.Client()).ParseArgs(args)
+		c.Check(err, check.ErrorMatches, "--plan does not accept additional arguments", check.Commentf("%v", args))
+	}
+}
+
 func (s *SnapSuite) TestRefreshLegacyTime(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
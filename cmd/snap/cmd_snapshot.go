@@ -20,14 +20,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
 
 	"github.com/jessevdk/go-flags"
 
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/strutil/quantity"
@@ -50,6 +53,10 @@ var (
 var longSavedHelp = i18n.G(`
 The saved command displays a list of snapshots that have been created
 previously with the 'save' command.
+
+With --list-files, the files in the data of the given snap in the snapshot
+given with --id are listed instead, as paths that can be given to
+'snap restore --path'.
 `)
 var longSaveHelp = i18n.G(`
 The save command creates a snapshot of the current user, system and
@@ -100,21 +107,36 @@ for which users, or a combination of these.
 If a snap is included in a restore operation, excluding its system and
 configuration data from the restore is not currently possible. This
 restriction may be lifted in the future.
+
+Alternatively, only some files or directories of the data of a single
+snap can be restored, by giving their paths with --path, as listed by
+'snap saved --id <id> --list-files <snap>'. The rest of the data and the
+configuration are then left alone.
 `)
 
 var longExportSnapshotHelp = i18n.G(`
 Export a snapshot to the given filename.
+
+The export can be encrypted, either to one or more age public keys
+("age1...") given with --encrypt-to, or with a passphrase that is prompted
+for when --passphrase is given. Encrypted exports can also be decrypted
+with the age tools.
 `)
 
 var longImportSnapshotHelp = i18n.G(`
 Import an exported snapshot set to the system. The snapshot is imported
 with a new snapshot ID and can be restored using the restore command.
+
+Encrypted exports are decrypted with the age secret keys read from the
+files given with --identity, or with a passphrase that is prompted for
+when --passphrase is given.
 `)
 
 type savedCmd struct {
 	clientMixin
 	durationMixin
 	ID         snapshotID `long:"id"`
+	ListFiles  bool       `long:"list-files"`
 	Positional struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -130,6 +152,12 @@ func (x *savedCmd) Execute([]string) error {
 		}
 	}
 	snaps := installedSnapNames(x.Positional.Snaps)
+	if x.ListFiles {
+		if setID == 0 || len(snaps) != 1 {
+			return errors.New(i18n.G("listing the files of a snapshot requires --id and a single snap"))
+		}
+		return x.listFiles(setID, snaps[0])
+	}
 	list, err := x.client.SnapshotSets(setID, snaps)
 	if err != nil {
 		return err
@@ -157,8 +185,11 @@ func (x *savedCmd) Execute([]string) error {
 	for _, sg := range list {
 		for _, sh := range sg.Snapshots {
 			notes := []string{}
-			if sh.Auto {
+			switch {
+			case sh.Auto:
 				notes = append(notes, "auto")
+			case sh.Policy != "":
+				notes = append(notes, sh.Policy)
 			}
 			if sh.Broken != "" {
 				notes = append(notes, "broken: "+sh.Broken)
@@ -175,6 +206,42 @@ func (x *savedCmd) Execute([]string) error {
 	return nil
 }
 
+func (x *savedCmd) listFiles(setID uint64, snapName string) error {
+	files, err := x.client.SnapshotFiles(setID, snapName, nil)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(Stdout, i18n.G("No files found."))
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+		i18n.G("User"),
+		i18n.G("Mode"),
+		i18n.G("Size"),
+		i18n.G("Path"))
+	for _, f := range files {
+		user := "-"
+		if f.User != "" {
+			user = f.User
+		}
+		size := "-"
+		if f.Mode.IsRegular() {
+			size = fmtSize(f.Size)
+		}
+		path := f.Path
+		if f.Link != "" {
+			path += " -> " + f.Link
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", user, f.Mode, size, path)
+	}
+	return nil
+}
+
 type saveCmd struct {
 	waitMixin
 	durationMixin
@@ -282,7 +349,8 @@ func (x *checkSnapshotCmd) Execute([]string) error {
 
 type restoreCmd struct {
 	waitMixin
-	Users      string `long:"users"`
+	Users      string   `long:"users"`
+	Paths      []string `long:"path" value-name:"<path>"`
 	Positional struct {
 		ID    snapshotID          `positional-arg-name:"<id>"`
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
@@ -296,6 +364,9 @@ func (x *restoreCmd) Execute([]string) error {
 	}
 	snaps := installedSnapNames(x.Positional.Snaps)
 	users := strutil.CommaSeparatedList(x.Users)
+	if len(x.Paths) > 0 {
+		return x.restorePaths(setID, snaps, users)
+	}
 	changeID, err := x.client.RestoreSnapshots(setID, snaps, users)
 	if err != nil {
 		return err
@@ -319,6 +390,28 @@ func (x *restoreCmd) Execute([]string) error {
 	return nil
 }
 
+func (x *restoreCmd) restorePaths(setID uint64, snaps, users []string) error {
+	if len(snaps) != 1 {
+		return errors.New(i18n.G("restoring specific paths requires a single snap"))
+	}
+	changeID, err := x.client.RestoreSnapshotPaths(setID, snaps[0], users, x.Paths)
+	if err != nil {
+		return err
+	}
+	_, err = x.wait(changeID)
+	if err == noWait {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// TRANSLATORS: the first %s is a comma-separated list of quoted paths
+	fmt.Fprintf(Stdout, i18n.G("Restored %s from snapshot #%s of snap %q.\n"),
+		strutil.Quoted(x.Paths), x.Positional.ID, snaps[0])
+	return nil
+}
+
 func init() {
 	addCommand("saved",
 		shortSavedHelp,
@@ -329,6 +422,8 @@ func init() {
 		durationDescs.also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"id": i18n.G("Show only a specific snapshot."),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"list-files": i18n.G("List the files in the data of a snap in the snapshot"),
 		}),
 		nil)
 
@@ -350,6 +445,8 @@ func init() {
 		}, waitDescs.also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"users": i18n.G("Restore data of only specific users (comma-separated) (default: all users)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"path": i18n.G("Restore only the given file or directory of the data of the snap (can be repeated)"),
 		}), []argDesc{
 			{
 				name: "<id>",
@@ -404,7 +501,12 @@ func init() {
 		longExportSnapshotHelp,
 		func() flags.Commander {
 			return &exportSnapshotCmd{}
-		}, nil, []argDesc{
+		}, map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"encrypt-to": i18n.G("Encrypt the export to the given age public key (can be repeated)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"passphrase": i18n.G("Encrypt the export with a passphrase, prompted for"),
+		}, []argDesc{
 			{
 				name: "<id>",
 				// TRANSLATORS: This should not start with a lowercase letter.
@@ -423,7 +525,12 @@ func init() {
 		longImportSnapshotHelp,
 		func() flags.Commander {
 			return &importSnapshotCmd{}
-		}, nil, []argDesc{
+		}, durationDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"identity": i18n.G("Decrypt the export with the age secret keys in the given file (can be repeated)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"passphrase": i18n.G("Decrypt the export with a passphrase, prompted for"),
+		}), []argDesc{
 			{
 				name: "<filename>",
 				// TRANSLATORS: This should not start with a lowercase letter.
@@ -434,6 +541,8 @@ func init() {
 
 type exportSnapshotCmd struct {
 	clientMixin
+	EncryptTo  []string `long:"encrypt-to" value-name:"<key>"`
+	Passphrase bool     `long:"passphrase"`
 	Positional struct {
 		ID       snapshotID `positional-arg-name:"<id>"`
 		Filename string     `long:"filename"`
@@ -446,7 +555,18 @@ func (x *exportSnapshotCmd) Execute([]string) (err error) {
 		return err
 	}
 
-	r, expectedSize, err := x.client.SnapshotExport(setID)
+	opts := &client.SnapshotExportOptions{EncryptTo: x.EncryptTo}
+	if x.Passphrase {
+		if len(x.EncryptTo) > 0 {
+			return errors.New(i18n.G("cannot use --passphrase together with --encrypt-to"))
+		}
+		opts.Passphrase, err = readNewPassphrase()
+		if err != nil {
+			return err
+		}
+	}
+
+	r, expectedSize, err := x.client.SnapshotExport(setID, opts)
 	if err != nil {
 		return err
 	}
@@ -488,12 +608,83 @@ func (x *exportSnapshotCmd) Execute([]string) (err error) {
 type importSnapshotCmd struct {
 	clientMixin
 	durationMixin
+	Identity   []string `long:"identity" value-name:"<file>"`
+	Passphrase bool     `long:"passphrase"`
 	Positional struct {
 		Filename string `long:"filename"`
 	} `positional-args:"yes" required:"yes"`
 }
 
+// readPassphrase prompts for a passphrase.
+func readPassphrase(prompt string) (string, error) {
+	fmt.Fprint(Stdout, prompt)
+	passphrase, err := ReadPassword(0)
+	fmt.Fprint(Stdout, "\n")
+	if err != nil {
+		return "", err
+	}
+	// strings.TrimSpace needed because we get \r from the pty in the tests
+	return strings.TrimSpace(string(passphrase)), nil
+}
+
+// readNewPassphrase prompts for a passphrase to encrypt with, twice to
+// catch typos.
+func readNewPassphrase() (string, error) {
+	passphrase, err := readPassphrase(i18n.G("Passphrase: "))
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New(i18n.G("passphrase cannot be empty"))
+	}
+	again, err := readPassphrase(i18n.G("Repeat passphrase: "))
+	if err != nil {
+		return "", err
+	}
+	if again != passphrase {
+		return "", errors.New(i18n.G("passphrases do not match"))
+	}
+	return passphrase, nil
+}
+
+// readIdentities reads the age secret keys from an identity file, as
+// written by age-keygen.
+func readIdentities(filename string) ([]string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot read identity file: %v"), err)
+	}
+	var identities []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		identities = append(identities, line)
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf(i18n.G("no secret keys found in %q"), filename)
+	}
+	return identities, nil
+}
+
 func (x *importSnapshotCmd) Execute([]string) error {
+	opts := &client.SnapshotImportOptions{}
+	for _, filename := range x.Identity {
+		identities, err := readIdentities(filename)
+		if err != nil {
+			return err
+		}
+		opts.Identities = append(opts.Identities, identities...)
+	}
+	if x.Passphrase {
+		passphrase, err := readPassphrase(i18n.G("Passphrase: "))
+		if err != nil {
+			return err
+		}
+		opts.Passphrase = passphrase
+	}
+
 	filename := x.Positional.Filename
 	f, err := os.Open(filename)
 	if err != nil {
@@ -505,7 +696,7 @@ func (x *importSnapshotCmd) Execute([]string) error {
 		return fmt.Errorf("cannot stat file: %v", err)
 	}
 
-	importSet, err := x.client.SnapshotImport(f, st.Size())
+	importSet, err := x.client.SnapshotImport(f, st.Size(), opts)
 	if err != nil {
 		return err
 	}
@@ -20,6 +20,7 @@
 package main_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -44,9 +45,25 @@ var snapshotsTests = []getCmdArgs{{
 }, {
 	args:   "saved --id=3",
 	stdout: "Set  Snap  Age    Version  Rev   Size    Notes\n3    htop  .*  2        1168      1B  auto\n",
+}, {
+	args:   "saved --id=5",
+	stdout: "Set  Snap  Age    Version  Rev   Size    Notes\n5    htop  .*  2        1168      1B  scheduled\n",
 }, {
 	args:   "saved",
 	stdout: "Set  Snap  Age    Version  Rev   Size    Notes\n1    htop  .*  2        1168      1B  -\n",
+}, {
+	args:  "saved --list-files htop",
+	error: `listing the files of a snapshot requires --id and a single snap`,
+}, {
+	args:  "saved --id=1 --list-files",
+	error: `listing the files of a snapshot requires --id and a single snap`,
+}, {
+	args: "saved --id=1 --list-files htop",
+	stdout: "User  Mode        Size  Path\n" +
+		"-     drwxr-xr-x     -  1168\n" +
+		"-     -rw-r--r--    3B  1168/a\n" +
+		"-     Lrwxrwxrwx     -  1168/b -> a\n" +
+		"joe   drwx------     -  common\n",
 }, {
 	args:  "forget x",
 	error: `invalid argument for snapshot set id: expected a non-negative integer argument \(see 'snap help saved'\)`,
@@ -56,6 +73,12 @@ var snapshotsTests = []getCmdArgs{{
 }, {
 	args:   "restore 1",
 	stdout: "Restored snapshot #1.\n",
+}, {
+	args:  "restore 1 --path 1168/a",
+	error: `restoring specific paths requires a single snap`,
+}, {
+	args:   "restore 1 htop --path 1168/a --path common",
+	stdout: "Restored \"1168/a\", \"common\" from snapshot #1 of snap \"htop\".\n",
 }, {
 	args:   "forget 2",
 	stdout: "Snapshot #2 forgotten.\n",
@@ -121,7 +144,11 @@ func (s *SnapSuite) mockSnapshotsServer(c *C) {
 				// simulate a 1-month old snapshot
 				snapshotTime := time.Now().AddDate(0, -1, 0).Format(time.RFC3339)
 				if r.URL.Query().Get("set") == "3" {
-					fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":3,"snapshots":[{"set":3,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","auto":true,"epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`, snapshotTime)
+					fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":3,"snapshots":[{"set":3,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","auto":true,"policy":"automatic","epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`, snapshotTime)
+					return
+				}
+				if r.URL.Query().Get("set") == "5" {
+					fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":5,"snapshots":[{"set":5,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","policy":"scheduled","epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`, snapshotTime)
 					return
 				}
 				fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":1,"snapshots":[{"set":1,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`, snapshotTime)
@@ -137,6 +164,14 @@ func (s *SnapSuite) mockSnapshotsServer(c *C) {
 			}
 		case "/v2/changes/9":
 			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {}}}`)
+		case "/v2/snapshots/1/files":
+			c.Check(r.URL.Query().Get("snap"), Equals, "htop")
+			fmt.Fprintln(w, `{"type": "sync", "result": [
+				{"path": "1168", "mode": 2147484141, "mtime": "2022-09-01T12:00:00Z"},
+				{"path": "1168/a", "mode": 420, "size": 3, "mtime": "2022-09-01T12:00:00Z"},
+				{"path": "1168/b", "mode": 134218239, "mtime": "2022-09-01T12:00:00Z", "link": "a"},
+				{"user": "joe", "path": "common", "mode": 2147484096, "mtime": "2022-09-01T12:00:00Z"}
+			]}`)
 		case "/v2/snapshots/1/export":
 			w.Header().Set("Content-Type", client.SnapshotExportMediaType)
 			fmt.Fprint(w, "Hello World!")
@@ -146,6 +181,35 @@ func (s *SnapSuite) mockSnapshotsServer(c *C) {
 	})
 }
 
+func (s *SnapSuite) TestSnapshotRestorePaths(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snapshots":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"set":    json.Number("1"),
+				"action": "restore",
+				"snaps":  []interface{}{"htop"},
+				"users":  []interface{}{"joe"},
+				"paths":  []interface{}{"1168/a", "common"},
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9"}`)
+		case "/v2/changes/9":
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {}}}`)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+		n++
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"restore", "--users=joe", "--path", "1168/a", "--path=common", "1", "htop"})
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 2)
+	c.Check(s.Stdout(), Equals, `Restored "1168/a", "common" from snapshot #1 of snap "htop".`+"\n")
+}
+
 func (s *SnapSuite) TestSnapshotImportHappy(c *C) {
 	// mockSnapshotServer will return set-id 42 and three snaps for all
 	// import calls
@@ -167,3 +231,103 @@ Set  Snap  Age    Version  Rev   Size    Notes
 1    htop  %-6s 2        1168      1B  -
 `, ageStr))
 }
+
+func (s *SnapSuite) TestSnapshotExportEncryptTo(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v2/snapshots/1/export")
+		c.Check(r.URL.Query()["encrypt-to"], DeepEquals, []string{"age1foo", "age1bar"})
+		c.Check(r.Header.Get("Snapshot-Passphrase"), Equals, "")
+		w.Header().Set("Content-Type", client.SnapshotExportMediaType)
+		fmt.Fprint(w, "encrypted")
+	})
+
+	exportedSnapshotPath := filepath.Join(c.MkDir(), "export-snapshot.snapshot")
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"export-snapshot", "--encrypt-to=age1foo", "--encrypt-to=age1bar", "1", exportedSnapshotPath})
+	c.Assert(err, IsNil)
+	c.Check(exportedSnapshotPath, testutil.FileEquals, "encrypted")
+}
+
+func (s *SnapSuite) TestSnapshotExportPassphrase(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v2/snapshots/1/export")
+		c.Check(r.URL.RawQuery, Equals, "")
+		c.Check(r.Header.Get("Snapshot-Passphrase"), Equals, "sekrit")
+		w.Header().Set("Content-Type", client.SnapshotExportMediaType)
+		fmt.Fprint(w, "encrypted")
+	})
+	s.password = "sekrit"
+
+	exportedSnapshotPath := filepath.Join(c.MkDir(), "export-snapshot.snapshot")
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"export-snapshot", "--passphrase", "1", exportedSnapshotPath})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), testutil.MatchesWrapped, `Passphrase: \nRepeat passphrase: \nExported snapshot #1 into ".*/export-snapshot.snapshot"\n`)
+	c.Check(exportedSnapshotPath, testutil.FileEquals, "encrypted")
+}
+
+func (s *SnapSuite) TestSnapshotExportPassphraseUnhappy(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Errorf("unexpected request %q", r.URL.Path)
+	})
+	exportedSnapshotPath := filepath.Join(c.MkDir(), "export-snapshot.snapshot")
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"export-snapshot", "--passphrase", "1", exportedSnapshotPath})
+	c.Check(err, ErrorMatches, "passphrase cannot be empty")
+
+	passphrases := []string{"sekrit", "typo"}
+	main.ReadPassword = func(int) ([]byte, error) {
+		p := passphrases[0]
+		passphrases = passphrases[1:]
+		return []byte(p), nil
+	}
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"export-snapshot", "--passphrase", "1", exportedSnapshotPath})
+	c.Check(err, ErrorMatches, "passphrases do not match")
+
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"export-snapshot", "--passphrase", "--encrypt-to=age1foo", "1", exportedSnapshotPath})
+	c.Check(err, ErrorMatches, "cannot use --passphrase together with --encrypt-to")
+	c.Check(exportedSnapshotPath, testutil.FileAbsent)
+}
+
+func (s *SnapSuite) TestSnapshotImportIdentity(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		switch r.URL.Path {
+		case "/v2/snapshots":
+			if r.Method == "POST" {
+				c.Check(r.Header.Get("Snapshot-Passphrase"), Equals, "")
+				c.Assert(r.ParseMultipartForm(1024), IsNil)
+				c.Check(r.MultipartForm.Value, DeepEquals, map[string][]string{
+					"identity":   {"AGE-SECRET-KEY-1FOO", "AGE-SECRET-KEY-1BAR"},
+					"passphrase": {"sekrit"},
+				})
+				c.Check(r.MultipartForm.File["snapshot"], HasLen, 1)
+				fmt.Fprintln(w, `{"type": "sync", "result": {"set-id": 42, "snaps": ["htop"]}}`)
+				return
+			}
+			fmt.Fprintln(w, `{"type":"sync","status-code":200,"status":"OK","result":[]}`)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+	s.password = "sekrit"
+
+	dir := c.MkDir()
+	identityPath := filepath.Join(dir, "key.txt")
+	err := ioutil.WriteFile(identityPath, []byte("# created: 2022-09-01T12:00:00Z\n# public key: age1foo\nAGE-SECRET-KEY-1FOO\n\nAGE-SECRET-KEY-1BAR\n"), 0600)
+	c.Assert(err, IsNil)
+	exportedSnapshotPath := filepath.Join(dir, "mocked-snapshot.snapshot")
+	err = ioutil.WriteFile(exportedSnapshotPath, []byte("encrypted snapshot data"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"import-snapshot", "--identity", identityPath, "--passphrase", exportedSnapshotPath})
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 2)
+	c.Check(s.Stdout(), testutil.MatchesWrapped, "Passphrase: \nImported snapshot as #42\n.*")
+
+	emptyPath := filepath.Join(dir, "empty.txt")
+	err = ioutil.WriteFile(emptyPath, []byte("# nothing\n"), 0600)
+	c.Assert(err, IsNil)
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"import-snapshot", "--identity", emptyPath, exportedSnapshotPath})
+	c.Check(err, ErrorMatches, `no secret keys found in ".*/empty.txt"`)
+	c.Check(n, Equals, 2)
+}
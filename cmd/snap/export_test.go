@@ -470,3 +470,16 @@ func ParseQuotaValues(maxMemory, cpuMax, cpuSet, threadsMax, journalSizeMax, jou
 
 	return quotas.parseQuotas()
 }
+
+func ParseIOQuotaValues(device, readMax, writeMax, readIOPS, writeIOPS, weight string) (*client.QuotaValues, error) {
+	var quotas cmdSetQuota
+
+	quotas.IODevice = device
+	quotas.IOReadMax = readMax
+	quotas.IOWriteMax = writeMax
+	quotas.IOReadIOPS = readIOPS
+	quotas.IOWriteIOPS = writeIOPS
+	quotas.IOWeight = weight
+
+	return quotas.parseQuotas()
+}
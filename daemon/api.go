@@ -65,6 +65,7 @@ var api = []*Command{
 	debugCmd,
 	snapshotCmd,
 	snapshotExportCmd,
+	snapshotFilesCmd,
 	connectionsCmd,
 	modelCmd,
 	cohortsCmd,
@@ -135,6 +136,7 @@ var (
 	snapstateInstallPath       = snapstate.InstallPath
 	snapstateInstallPathMany   = snapstate.InstallPathMany
 	snapstateRefreshCandidates = snapstate.RefreshCandidates
+	snapstateRefreshPlan       = snapstate.RefreshPlan
 	snapstateTryPath           = snapstate.TryPath
 	snapstateUpdate            = snapstate.Update
 	snapstateUpdateMany        = snapstate.UpdateMany
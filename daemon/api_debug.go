@@ -373,6 +373,15 @@ func getDebug(c *Command, r *http.Request, user *auth.UserState) Response {
 		return getGadgetDiskMapping(st)
 	case "disks":
 		return getDisks(st)
+	case "sandbox-profile":
+		return getSandboxProfile(st, c.d.overlord.InterfaceManager().Repository(), query.Get("snap"))
+	case "denials":
+		if rspe := checkDenialsAccess(c.d, r, user); rspe != nil {
+			return rspe
+		}
+		return getDenials(st, c.d.overlord.InterfaceManager(), query.Get("snap"))
+	case "device-cgroup":
+		return getDeviceCgroup(st, c.d.overlord.InterfaceManager().Repository(), query.Get("snap"))
 	default:
 		return BadRequest("unknown debug aspect %q", aspect)
 	}
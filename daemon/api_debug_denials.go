@@ -0,0 +1,59 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"net/http"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// denialsAccess is the access required to get the denials, which is
+// stricter than the open access of the other debug aspects as the denials
+// reveal what the snaps of every user tried to access.
+var denialsAccess = authenticatedAccess{}
+
+func checkDenialsAccess(d *Daemon, r *http.Request, user *auth.UserState) *apiError {
+	ucred, err := ucrednetGet(r.RemoteAddr)
+	if err != nil && err != errNoID {
+		return InternalError("cannot get peer credentials: %v", err)
+	}
+	return denialsAccess.CheckAccess(d, r, ucred, user)
+}
+
+// getDenials returns the accesses denied to the given snap, or to all
+// snaps, by their sandbox, after collecting the ones logged since the last
+// collection.
+func getDenials(st *state.State, ifaceMgr *ifacestate.InterfaceManager, snapName string) Response {
+	st.Unlock()
+	err := ifaceMgr.CollectDenials()
+	st.Lock()
+	if err != nil {
+		return InternalError("cannot collect sandbox denials: %v", err)
+	}
+
+	accesses, err := ifacestate.Denials(st, snapName)
+	if err != nil {
+		return InternalError("cannot get sandbox denials: %v", err)
+	}
+	return SyncResponse(accesses)
+}
@@ -0,0 +1,159 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var _ = check.Suite(&denialsSuite{})
+
+type denialsSuite struct {
+	apiBaseSuite
+}
+
+const deniedYaml = `
+name: denied
+version: 1
+apps:
+  app:
+`
+
+const otherDeniedYaml = `
+name: other-denied
+version: 1
+apps:
+  app:
+`
+
+func (s *denialsSuite) getDenials(c *check.C, query string) []interface{} {
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=denials"+query, nil)
+	c.Assert(err, check.IsNil)
+	s.asRootAuth(req)
+	rsp := s.syncReq(c, req, nil)
+
+	// check the result as seen by clients
+	data, err := json.Marshal(rsp.Result)
+	c.Assert(err, check.IsNil)
+	var result []interface{}
+	c.Assert(json.Unmarshal(data, &result), check.IsNil)
+	return result
+}
+
+func (s *denialsSuite) TestDenials(c *check.C) {
+	s.daemon(c)
+	s.mockSnap(c, deniedYaml)
+	s.mockSnap(c, otherDeniedYaml)
+
+	log := `{"__CURSOR": "c1", "__REALTIME_TIMESTAMP": "1654084800000000", "MESSAGE": "apparmor=\"DENIED\" operation=\"open\" profile=\"snap.denied.app\" name=\"/dev/video0\" pid=1 comm=\"app\" requested_mask=\"r\" denied_mask=\"r\" fsuid=1000 ouid=0"}
+{"__CURSOR": "c2", "__REALTIME_TIMESTAMP": "1654084801000000", "MESSAGE": "apparmor=\"DENIED\" operation=\"capable\" profile=\"snap.other-denied.app\" pid=2 comm=\"app\" capability=12 capname=\"net_admin\""}
+`
+	s.AddCleanup(systemd.MockJournalctlAudit(func(cursor string, n int) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(log)), nil
+	}))
+
+	result := s.getDenials(c, "&snap=denied")
+	c.Assert(result, check.HasLen, 1)
+	c.Check(result[0], check.DeepEquals, map[string]interface{}{
+		"snap":     "denied",
+		"app":      "app",
+		"revision": "1",
+		"denial": map[string]interface{}{
+			"sandbox":   "apparmor",
+			"label":     "snap.denied.app",
+			"operation": "open",
+			"path":      "/dev/video0",
+			"mask":      "r",
+		},
+		"description":          "open /dev/video0 (r)",
+		"count":                1.0,
+		"first-seen":           "2022-06-01T12:00:00Z",
+		"last-seen":            "2022-06-01T12:00:00Z",
+		"suggested-interfaces": []interface{}{"camera"},
+	})
+
+	// the log is collected again
+	result = s.getDenials(c, "")
+	c.Assert(result, check.HasLen, 2)
+	c.Check(result[0].(map[string]interface{})["count"], check.Equals, 2.0)
+	c.Check(result[1].(map[string]interface{})["snap"], check.Equals, "other-denied")
+	c.Check(result[1].(map[string]interface{})["description"], check.Equals, "capability net_admin")
+}
+
+func (s *denialsSuite) TestDenialsNone(c *check.C) {
+	s.daemon(c)
+	s.AddCleanup(systemd.MockJournalctlAudit(func(cursor string, n int) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}))
+
+	c.Check(s.getDenials(c, ""), check.HasLen, 0)
+}
+
+func (s *denialsSuite) TestDenialsError(c *check.C) {
+	s.daemon(c)
+	s.AddCleanup(systemd.MockJournalctlAudit(func(cursor string, n int) (io.ReadCloser, error) {
+		return nil, errors.New("boom")
+	}))
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=denials", nil)
+	c.Assert(err, check.IsNil)
+	s.asRootAuth(req)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 500)
+	c.Check(rspe.Message, check.Equals, "cannot collect sandbox denials: cannot read the audit log: boom")
+}
+
+func (s *denialsSuite) TestDenialsAccess(c *check.C) {
+	s.daemon(c)
+	s.AddCleanup(systemd.MockJournalctlAudit(func(cursor string, n int) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}))
+
+	// unauthenticated users cannot get the denials
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=denials", nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=1000;socket=%s;", dirs.SnapdSocket)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 401)
+	c.Check(rspe.Message, check.Equals, "access denied")
+
+	// nor can requests from snaps
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=0;socket=%s;", dirs.SnapSocket)
+	rspe = s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 403)
+
+	// but logged in users can
+	req, err = http.NewRequest("GET", "/v2/debug?aspect=denials", nil)
+	c.Assert(err, check.IsNil)
+	s.asUserAuth(c, req)
+	rsp := s.syncReq(c, req, s.authUser)
+	c.Check(rsp.Status, check.Equals, 200)
+}
@@ -0,0 +1,257 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/snap"
+)
+
+type deviceCgroupJSON struct {
+	Snap        string `json:"snap"`
+	App         string `json:"app"`
+	SecurityTag string `json:"security-tag"`
+	UdevTag     string `json:"udev-tag"`
+	// CgroupVersion is the version of the device cgroup, the allowed
+	// devices are read from its eBPF map with v2
+	CgroupVersion int `json:"cgroup-version"`
+	// ControlsDeviceCgroup is set when the snap manages the device cgroup
+	// itself, no device is then tagged for it.
+	ControlsDeviceCgroup bool `json:"controls-device-cgroup,omitempty"`
+	// UdevRules are the rules of the udev backend tagging devices for the
+	// app.
+	UdevRules []string `json:"udev-rules"`
+	// Found is set when snap-confine set up the device cgroup, which it
+	// does the first time the app is run with devices tagged for it.
+	Found bool `json:"found"`
+	// Allowed are the devices the device cgroup allows access to.
+	Allowed []string `json:"allowed"`
+	// Tagged are the devices the udev database has tagged for the app.
+	Tagged []string `json:"tagged"`
+	// Missing are the tagged devices the device cgroup does not allow
+	// access to.
+	Missing []string `json:"missing,omitempty"`
+	// Unexpected are the devices the device cgroup allows access to that
+	// are neither tagged nor allowed to all apps by snap-confine.
+	Unexpected []string `json:"unexpected,omitempty"`
+}
+
+// commonDevices are the devices snap-confine allows every app with a device
+// cgroup to access, see cmd/snap-confine/udev-support.c
+var commonDevices = []cgroup.Device{
+	// /dev/null, /dev/full, /dev/zero, /dev/random and /dev/urandom
+	{Type: 'c', Major: 1, Minor: 3},
+	{Type: 'c', Major: 1, Minor: 5},
+	{Type: 'c', Major: 1, Minor: 7},
+	{Type: 'c', Major: 1, Minor: 8},
+	{Type: 'c', Major: 1, Minor: 9},
+	// /dev/tty, /dev/console and /dev/ptmx
+	{Type: 'c', Major: 5, Minor: 0},
+	{Type: 'c', Major: 5, Minor: 1},
+	{Type: 'c', Major: 5, Minor: 2},
+	// the Unix98 PTY slaves
+	{Type: 'c', Major: 136, Minor: cgroup.AnyDevice},
+	{Type: 'c', Major: 137, Minor: cgroup.AnyDevice},
+	{Type: 'c', Major: 138, Minor: cgroup.AnyDevice},
+	{Type: 'c', Major: 139, Minor: cgroup.AnyDevice},
+	{Type: 'c', Major: 140, Minor: cgroup.AnyDevice},
+	{Type: 'c', Major: 141, Minor: cgroup.AnyDevice},
+	{Type: 'c', Major: 142, Minor: cgroup.AnyDevice},
+	{Type: 'c', Major: 143, Minor: cgroup.AnyDevice},
+}
+
+// commonDeviceGlobs are the device nodes snap-confine allows every app with
+// a device cgroup to access, when they exist.
+var commonDeviceGlobs = []string{
+	"/dev/nvidia[0-9]*",
+	"/dev/nvidiactl",
+	"/dev/nvidia-uvm",
+	"/dev/nvidia-modeset",
+	"/dev/uhid",
+	"/dev/net/tun",
+}
+
+func existingCommonDevices() []cgroup.Device {
+	devices := append([]cgroup.Device(nil), commonDevices...)
+	for _, glob := range commonDeviceGlobs {
+		matches, _ := filepath.Glob(filepath.Join(dirs.GlobalRootDir, glob))
+		for _, path := range matches {
+			fi, err := os.Stat(path)
+			if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+				continue
+			}
+			st, ok := fi.Sys().(*syscall.Stat_t)
+			if !ok {
+				continue
+			}
+			devices = append(devices, cgroup.Device{
+				Type:  'c',
+				Major: unix.Major(uint64(st.Rdev)),
+				Minor: unix.Minor(uint64(st.Rdev)),
+			})
+		}
+	}
+	return devices
+}
+
+// udevTaggedDevices returns the device nodes the udev database has tagged
+// with the given tag, those are listed in /run/udev/tags/<tag> as c<major>:<minor>
+// or b<major>:<minor>, alongside the devices without nodes.
+func udevTaggedDevices(tag string) ([]cgroup.Device, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(dirs.GlobalRootDir, "/run/udev/tags", tag))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var devices []cgroup.Device
+	for _, entry := range entries {
+		name := entry.Name()
+		if len(name) < 4 || (name[0] != 'c' && name[0] != 'b') {
+			continue
+		}
+		numbers := strings.Split(name[1:], ":")
+		if len(numbers) != 2 {
+			continue
+		}
+		major, err := strconv.ParseUint(numbers[0], 10, 32)
+		if err != nil {
+			continue
+		}
+		minor, err := strconv.ParseUint(numbers[1], 10, 32)
+		if err != nil {
+			continue
+		}
+		devices = append(devices, cgroup.Device{Type: name[0], Major: uint32(major), Minor: uint32(minor)})
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].Major != devices[j].Major {
+			return devices[i].Major < devices[j].Major
+		}
+		return devices[i].Minor < devices[j].Minor
+	})
+	return devices, nil
+}
+
+func allowedBy(rules []cgroup.Device, device cgroup.Device) bool {
+	for _, rule := range rules {
+		if rule.Allows(device) {
+			return true
+		}
+	}
+	return false
+}
+
+// getDeviceCgroup compares the devices that the device cgroup of an app,
+// given as <snap>.<app>, allows access to with the ones the interfaces
+// expect it to, as tagged in the udev database.
+func getDeviceCgroup(st *state.State, repo *interfaces.Repository, snapApp string) Response {
+	if snapApp == "" {
+		return BadRequest("missing app name")
+	}
+	snapName, appName := snap.SplitSnapApp(snapApp)
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if err != nil {
+		if _, ok := err.(*snap.NotInstalledError); ok {
+			return SnapNotFound(snapName, err)
+		}
+		return InternalError("cannot get snap info: %v", err)
+	}
+	app := info.Apps[appName]
+	if app == nil {
+		return AppNotFound("snap %q has no app %q", snapName, appName)
+	}
+
+	result := &deviceCgroupJSON{
+		Snap:          info.InstanceName(),
+		App:           app.Name,
+		SecurityTag:   app.SecurityTag(),
+		UdevTag:       strings.Replace(app.SecurityTag(), ".", "_", -1),
+		CgroupVersion: cgroup.V1,
+		UdevRules:     []string{},
+		Allowed:       []string{},
+		Tagged:        []string{},
+	}
+	if cgroup.IsUnified() {
+		result.CgroupVersion = cgroup.V2
+	}
+
+	spec, err := repo.SnapSpecification(interfaces.SecurityUDev, info.InstanceName())
+	if err != nil {
+		return InternalError("cannot obtain udev specification of snap %q: %v", info.InstanceName(), err)
+	}
+	udevSpec := spec.(*udev.Specification)
+	result.ControlsDeviceCgroup = udevSpec.ControlsDeviceCgroup()
+	for _, snippet := range udevSpec.InspectSnippets()[result.SecurityTag] {
+		if strings.Contains(snippet, `TAG+="`+result.UdevTag+`"`) {
+			result.UdevRules = append(result.UdevRules, snippet)
+		}
+	}
+
+	tagged, err := udevTaggedDevices(result.UdevTag)
+	if err != nil {
+		return InternalError("cannot read the devices tagged for %q: %v", result.SecurityTag, err)
+	}
+	for _, device := range tagged {
+		result.Tagged = append(result.Tagged, device.String())
+	}
+
+	// the slow bits are done with the state unlocked
+	st.Unlock()
+	allowed, err := cgroup.DeviceCgroupDevices(result.SecurityTag)
+	common := existingCommonDevices()
+	st.Lock()
+	if err != nil && err != cgroup.ErrDeviceCgroupNotFound {
+		return InternalError("cannot read the device cgroup of %q: %v", result.SecurityTag, err)
+	}
+	if err == cgroup.ErrDeviceCgroupNotFound {
+		return SyncResponse(result)
+	}
+
+	result.Found = true
+	for _, device := range allowed {
+		result.Allowed = append(result.Allowed, device.String())
+		if !allowedBy(tagged, device) && !allowedBy(common, device) {
+			result.Unexpected = append(result.Unexpected, device.String())
+		}
+	}
+	for _, device := range tagged {
+		if !allowedBy(allowed, device) {
+			result.Missing = append(result.Missing, device.String())
+		}
+	}
+	return SyncResponse(result)
+}
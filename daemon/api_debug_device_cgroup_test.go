@@ -0,0 +1,183 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+)
+
+var _ = check.Suite(&deviceCgroupSuite{})
+
+type deviceCgroupSuite struct {
+	apiBaseSuite
+}
+
+func (s *deviceCgroupSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+	s.AddCleanup(cgroup.MockVersion(cgroup.V1, nil))
+}
+
+func (s *deviceCgroupSuite) mockSnapsAndConnection(c *check.C) {
+	d := s.daemon(c)
+
+	repo := d.Overlord().InterfaceManager().Repository()
+	c.Assert(repo.AddBackend(&udev.Backend{}), check.IsNil)
+	mockIface(c, d, &ifacetest.TestInterface{
+		InterfaceName: "test",
+		UDevConnectedPlugCallback: func(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+			spec.TagDevice(`KERNEL=="video[0-9]*"`)
+			return nil
+		},
+	})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	connRef := &interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}
+	_, err := repo.Connect(connRef, nil, nil, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *deviceCgroupSuite) getDeviceCgroup(c *check.C, snapApp string) map[string]interface{} {
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=device-cgroup&snap="+snapApp, nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+
+	// check the result as seen by clients
+	data, err := json.Marshal(rsp.Result)
+	c.Assert(err, check.IsNil)
+	var result map[string]interface{}
+	c.Assert(json.Unmarshal(data, &result), check.IsNil)
+	return result
+}
+
+func mockUdevTags(c *check.C, tag string, entries ...string) {
+	dir := filepath.Join(dirs.GlobalRootDir, "/run/udev/tags", tag)
+	c.Assert(os.MkdirAll(dir, 0755), check.IsNil)
+	for _, entry := range entries {
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, entry), nil, 0644), check.IsNil)
+	}
+}
+
+func mockDevicesList(c *check.C, securityTag, content string) {
+	list := filepath.Join(dirs.GlobalRootDir, "/sys/fs/cgroup/devices", securityTag, "devices.list")
+	c.Assert(os.MkdirAll(filepath.Dir(list), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(list, []byte(content), 0644), check.IsNil)
+}
+
+const consumerAppUdevRule = "# test\nKERNEL==\"video[0-9]*\", TAG+=\"snap_consumer_app\""
+
+func (s *deviceCgroupSuite) TestDeviceCgroupNotFound(c *check.C) {
+	s.mockSnapsAndConnection(c)
+	mockUdevTags(c, "snap_consumer_app", "c81:0", "+video4linux:video0")
+
+	c.Check(s.getDeviceCgroup(c, "consumer.app"), check.DeepEquals, map[string]interface{}{
+		"snap":           "consumer",
+		"app":            "app",
+		"security-tag":   "snap.consumer.app",
+		"udev-tag":       "snap_consumer_app",
+		"cgroup-version": 1.0,
+		"udev-rules":     []interface{}{consumerAppUdevRule},
+		"found":          false,
+		"allowed":        []interface{}{},
+		"tagged":         []interface{}{"c 81:0"},
+	})
+}
+
+func (s *deviceCgroupSuite) TestDeviceCgroupMatches(c *check.C) {
+	s.mockSnapsAndConnection(c)
+	mockUdevTags(c, "snap_consumer_app", "c81:0", "n3")
+	mockDevicesList(c, "snap.consumer.app", "c 1:3 rwm\nc 136:* rwm\nc 81:0 rwm\n")
+
+	c.Check(s.getDeviceCgroup(c, "consumer.app"), check.DeepEquals, map[string]interface{}{
+		"snap":           "consumer",
+		"app":            "app",
+		"security-tag":   "snap.consumer.app",
+		"udev-tag":       "snap_consumer_app",
+		"cgroup-version": 1.0,
+		"udev-rules":     []interface{}{consumerAppUdevRule},
+		"found":          true,
+		"allowed":        []interface{}{"c 1:3", "c 81:0", "c 136:*"},
+		"tagged":         []interface{}{"c 81:0"},
+	})
+}
+
+func (s *deviceCgroupSuite) TestDeviceCgroupMismatch(c *check.C) {
+	s.mockSnapsAndConnection(c)
+	mockUdevTags(c, "snap_consumer_app", "c81:0", "c81:1")
+	mockDevicesList(c, "snap.consumer.app", "c 1:3 rwm\nc 81:0 rwm\nb 8:1 rwm\n")
+
+	result := s.getDeviceCgroup(c, "consumer.app")
+	c.Check(result["found"], check.Equals, true)
+	c.Check(result["allowed"], check.DeepEquals, []interface{}{"b 8:1", "c 1:3", "c 81:0"})
+	c.Check(result["tagged"], check.DeepEquals, []interface{}{"c 81:0", "c 81:1"})
+	c.Check(result["missing"], check.DeepEquals, []interface{}{"c 81:1"})
+	c.Check(result["unexpected"], check.DeepEquals, []interface{}{"b 8:1"})
+}
+
+func (s *deviceCgroupSuite) TestDeviceCgroupNoRules(c *check.C) {
+	s.mockSnapsAndConnection(c)
+
+	c.Check(s.getDeviceCgroup(c, "producer.app"), check.DeepEquals, map[string]interface{}{
+		"snap":           "producer",
+		"app":            "app",
+		"security-tag":   "snap.producer.app",
+		"udev-tag":       "snap_producer_app",
+		"cgroup-version": 1.0,
+		"udev-rules":     []interface{}{},
+		"found":          false,
+		"allowed":        []interface{}{},
+		"tagged":         []interface{}{},
+	})
+}
+
+func (s *deviceCgroupSuite) TestDeviceCgroupErrors(c *check.C) {
+	s.mockSnapsAndConnection(c)
+
+	for _, t := range []struct {
+		snapApp string
+		status  int
+		message string
+	}{
+		{"", 400, "missing app name"},
+		{"missing.app", 404, `snap "missing" is not installed`},
+		{"consumer.missing", 404, `snap "consumer" has no app "missing"`},
+	} {
+		req, err := http.NewRequest("GET", "/v2/debug?aspect=device-cgroup&snap="+t.snapApp, nil)
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, t.status, check.Commentf(t.snapApp))
+		c.Check(rspe.Message, check.Equals, t.message, check.Commentf(t.snapApp))
+	}
+}
@@ -0,0 +1,138 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"sort"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// sandboxSnippetsJSON holds the snippets that a plug or slot, or one of
+// their connections, contributed for a security tag.
+type sandboxSnippetsJSON struct {
+	Interface string              `json:"interface"`
+	Plug      *interfaces.PlugRef `json:"plug,omitempty"`
+	Slot      *interfaces.SlotRef `json:"slot,omitempty"`
+	// Connected is set when the snippets were contributed by the
+	// connection of the plug to the slot rather than by just having the
+	// plug or the slot.
+	Connected bool `json:"connected,omitempty"`
+	// Tag is the security tag the snippets apply to, it is empty for
+	// those applying to the whole snap.
+	Tag      string   `json:"tag,omitempty"`
+	Snippets []string `json:"snippets"`
+}
+
+type sandboxBackendJSON struct {
+	Name     interfaces.SecuritySystem `json:"name"`
+	Snippets []*sandboxSnippetsJSON    `json:"snippets"`
+}
+
+type sandboxProfileJSON struct {
+	Snap     string                `json:"snap"`
+	Revision snap.Revision         `json:"revision"`
+	App      string                `json:"app,omitempty"`
+	Backends []*sandboxBackendJSON `json:"backends"`
+}
+
+// getSandboxProfile collects the snippets the interfaces contributed to the
+// specifications of the security backends for a snap or one of its apps,
+// given as <snap>[.<app>].
+func getSandboxProfile(st *state.State, repo *interfaces.Repository, snapApp string) Response {
+	if snapApp == "" {
+		return BadRequest("missing snap name")
+	}
+	snapName, appName := snap.SplitSnapApp(snapApp)
+	// SplitSnapApp maps "foo" to "foo.foo", only look at an app when
+	// asked for one explicitly
+	if snapName == snapApp {
+		appName = ""
+	}
+
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if err != nil {
+		if _, ok := err.(*snap.NotInstalledError); ok {
+			return SnapNotFound(snapName, err)
+		}
+		return InternalError("cannot get snap info: %v", err)
+	}
+	wantTag := func(tag string) bool { return true }
+	if appName != "" {
+		app := info.Apps[appName]
+		if app == nil {
+			return AppNotFound("snap %q has no app %q", snapName, appName)
+		}
+		appTag := app.SecurityTag()
+		wantTag = func(tag string) bool { return tag == "" || tag == appTag }
+	}
+
+	profile := &sandboxProfileJSON{
+		Snap:     info.InstanceName(),
+		Revision: info.Revision,
+		App:      appName,
+		Backends: []*sandboxBackendJSON{},
+	}
+	for _, backend := range repo.Backends() {
+		if _, ok := backend.NewSpecification().(interfaces.InspectableSpecification); !ok {
+			continue
+		}
+		contribs, err := repo.SnapSpecificationContributions(backend.Name(), info.InstanceName())
+		if err != nil {
+			return InternalError("cannot obtain %s specification of snap %q: %v", backend.Name(), info.InstanceName(), err)
+		}
+		backendJSON := &sandboxBackendJSON{Name: backend.Name()}
+		for _, contrib := range contribs {
+			snippets := contrib.Spec.(interfaces.InspectableSpecification).InspectSnippets()
+			tags := make([]string, 0, len(snippets))
+			for tag := range snippets {
+				if len(snippets[tag]) > 0 && wantTag(tag) {
+					tags = append(tags, tag)
+				}
+			}
+			sort.Strings(tags)
+			for _, tag := range tags {
+				snippetsJSON := &sandboxSnippetsJSON{
+					Interface: contrib.Interface,
+					Tag:       tag,
+					Snippets:  snippets[tag],
+				}
+				switch {
+				case contrib.Connection != nil:
+					snippetsJSON.Plug = &contrib.Connection.PlugRef
+					snippetsJSON.Slot = &contrib.Connection.SlotRef
+					snippetsJSON.Connected = true
+				case contrib.Plug != nil:
+					snippetsJSON.Plug = &interfaces.PlugRef{Snap: contrib.Plug.Snap.InstanceName(), Name: contrib.Plug.Name}
+				case contrib.Slot != nil:
+					snippetsJSON.Slot = &interfaces.SlotRef{Snap: contrib.Slot.Snap.InstanceName(), Name: contrib.Slot.Name}
+				}
+				backendJSON.Snippets = append(backendJSON.Snippets, snippetsJSON)
+			}
+		}
+		if len(backendJSON.Snippets) > 0 {
+			profile.Backends = append(profile.Backends, backendJSON)
+		}
+	}
+	return SyncResponse(profile)
+}
@@ -0,0 +1,139 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/snap"
+)
+
+var _ = check.Suite(&sandboxProfileSuite{})
+
+type sandboxProfileSuite struct {
+	apiBaseSuite
+}
+
+func (s *sandboxProfileSuite) mockSnapsAndConnection(c *check.C) {
+	d := s.daemon(c)
+
+	repo := d.Overlord().InterfaceManager().Repository()
+	c.Assert(repo.AddBackend(&ifacetest.TestSecurityBackend{BackendName: "test-backend"}), check.IsNil)
+	mockIface(c, d, &ifacetest.TestInterface{
+		InterfaceName: "test",
+		TestPermanentPlugCallback: func(spec *ifacetest.Specification, plug *snap.PlugInfo) error {
+			spec.AddSnippet("permanent plug snippet")
+			return nil
+		},
+		TestConnectedPlugCallback: func(spec *ifacetest.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+			spec.AddSnippet("connected plug snippet 1")
+			spec.AddSnippet("connected plug snippet 2")
+			return nil
+		},
+	})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	connRef := &interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}
+	_, err := repo.Connect(connRef, nil, nil, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *sandboxProfileSuite) getSandboxProfile(c *check.C, snapApp string) map[string]interface{} {
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=sandbox-profile&snap="+snapApp, nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+
+	// check the result as seen by clients
+	data, err := json.Marshal(rsp.Result)
+	c.Assert(err, check.IsNil)
+	var result map[string]interface{}
+	c.Assert(json.Unmarshal(data, &result), check.IsNil)
+	return result
+}
+
+func (s *sandboxProfileSuite) TestSandboxProfile(c *check.C) {
+	s.mockSnapsAndConnection(c)
+
+	expectedBackends := []interface{}{
+		map[string]interface{}{
+			"name": "test-backend",
+			"snippets": []interface{}{
+				map[string]interface{}{
+					"interface": "test",
+					"plug":      map[string]interface{}{"snap": "consumer", "plug": "plug"},
+					"snippets":  []interface{}{"permanent plug snippet"},
+				},
+				map[string]interface{}{
+					"interface": "test",
+					"plug":      map[string]interface{}{"snap": "consumer", "plug": "plug"},
+					"slot":      map[string]interface{}{"snap": "producer", "slot": "slot"},
+					"connected": true,
+					"snippets":  []interface{}{"connected plug snippet 1", "connected plug snippet 2"},
+				},
+			},
+		},
+	}
+
+	c.Check(s.getSandboxProfile(c, "consumer"), check.DeepEquals, map[string]interface{}{
+		"snap":     "consumer",
+		"revision": "1",
+		"backends": expectedBackends,
+	})
+	c.Check(s.getSandboxProfile(c, "consumer.app"), check.DeepEquals, map[string]interface{}{
+		"snap":     "consumer",
+		"revision": "1",
+		"app":      "app",
+		"backends": expectedBackends,
+	})
+	c.Check(s.getSandboxProfile(c, "producer"), check.DeepEquals, map[string]interface{}{
+		"snap":     "producer",
+		"revision": "1",
+		"backends": []interface{}{},
+	})
+}
+
+func (s *sandboxProfileSuite) TestSandboxProfileErrors(c *check.C) {
+	s.mockSnapsAndConnection(c)
+
+	for _, t := range []struct {
+		snapApp string
+		status  int
+		message string
+	}{
+		{"", 400, "missing snap name"},
+		{"missing", 404, `snap "missing" is not installed`},
+		{"consumer.missing", 404, `snap "consumer" has no app "missing"`},
+	} {
+		req, err := http.NewRequest("GET", "/v2/debug?aspect=sandbox-profile&snap="+t.snapApp, nil)
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, t.status, check.Commentf(t.snapApp))
+		c.Check(rspe.Message, check.Equals, t.message, check.Commentf(t.snapApp))
+	}
+}
@@ -35,7 +35,9 @@ import (
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/changearchive"
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/sandbox"
@@ -59,7 +61,7 @@ var (
 	stateChangeCmd = &Command{
 		Path:        "/v2/changes/{id}",
 		GET:         getChange,
-		POST:        abortChange,
+		POST:        postChange,
 		ReadAccess:  openAccess{},
 		WriteAccess: authenticatedAccess{Polkit: polkitActionManage},
 	}
@@ -227,11 +229,38 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 		filter = func(chg *state.Change) bool { return !chg.Status().Ready() }
 	case "ready":
 		filter = func(chg *state.Change) bool { return chg.Status().Ready() }
+	case "archived":
+		// archived changes are filtered by the archive
 	default:
-		return BadRequest("select should be one of: all,in-progress,ready")
+		return BadRequest("select should be one of: all,in-progress,ready,archived")
 	}
 
-	if wantedName := query.Get("for"); wantedName != "" {
+	wantedName := query.Get("for")
+	if snapName := query.Get("snap"); snapName != "" {
+		if wantedName != "" && wantedName != snapName {
+			return BadRequest("cannot use both for and snap parameters with different snaps")
+		}
+		wantedName = snapName
+	}
+	wantedKind := query.Get("kind")
+	var since time.Time
+	if qsince := query.Get("since"); qsince != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339Nano, qsince)
+		if err != nil {
+			return BadRequest("invalid since parameter: %v", err)
+		}
+	}
+
+	if qselect == "archived" {
+		return getArchivedChanges(&changearchive.Filter{
+			Since:    since,
+			Kind:     wantedKind,
+			SnapName: wantedName,
+		})
+	}
+
+	if wantedName != "" {
 		outerFilter := filter
 		filter = func(chg *state.Change) bool {
 			if !outerFilter(chg) {
@@ -257,6 +286,20 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 			return false
 		}
 	}
+	if wantedKind != "" {
+		outerFilter := filter
+		filter = func(chg *state.Change) bool {
+			return outerFilter(chg) && chg.Kind() == wantedKind
+		}
+	}
+	if !since.IsZero() {
+		// select the changes that were not ready yet at that time
+		outerFilter := filter
+		filter = func(chg *state.Change) bool {
+			readyTime := chg.ReadyTime()
+			return outerFilter(chg) && (readyTime.IsZero() || !readyTime.Before(since))
+		}
+	}
 
 	state := c.d.overlord.State()
 	state.Lock()
@@ -272,7 +315,19 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(chgInfos)
 }
 
-func abortChange(c *Command, r *http.Request, user *auth.UserState) Response {
+func getArchivedChanges(filter *changearchive.Filter) Response {
+	entries, err := changearchive.Query(filter)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	chgInfos := make([]*changeInfo, len(entries))
+	for i, e := range entries {
+		chgInfos[i] = archived2changeInfo(e)
+	}
+	return SyncResponse(chgInfos)
+}
+
+func postChange(c *Command, r *http.Request, user *auth.UserState) Response {
 	chID := muxVars(r)["id"]
 	state := c.d.overlord.State()
 	state.Lock()
@@ -291,19 +346,49 @@ func abortChange(c *Command, r *http.Request, user *auth.UserState) Response {
 		return BadRequest("cannot decode data from request body: %v", err)
 	}
 
-	if reqData.Action != "abort" {
-		return BadRequest("change action %q is unsupported", reqData.Action)
+	switch reqData.Action {
+	case "abort":
+		return abortChange(chg)
+	case "retry":
+		return retryChange(c, chg)
 	}
+	return BadRequest("change action %q is unsupported", reqData.Action)
+}
 
+func abortChange(chg *state.Change) Response {
 	if chg.Status().Ready() {
-		return BadRequest("cannot abort change %s with nothing pending", chID)
+		return BadRequest("cannot abort change %s with nothing pending", chg.ID())
 	}
 
 	// flag the change
 	chg.Abort()
 
 	// actually ask to proceed with the abort
-	ensureStateSoon(state)
+	ensureStateSoon(chg.State())
+
+	return SyncResponse(change2changeInfo(chg))
+}
+
+func retryChange(c *Command, chg *state.Change) Response {
+	var snapNames []string
+	if err := chg.Get("snap-names", &snapNames); err != nil && !errors.Is(err, state.ErrNoState) {
+		return InternalError("cannot get snap names of change %s: %v", chg.ID(), err)
+	}
+	for i, name := range snapNames {
+		// the snap-names of service-control changes can include
+		// <snap>.<app>
+		snapNames[i], _ = snap.SplitSnapApp(name)
+	}
+	// the snaps of the change could have been operated on since
+	if err := snapstate.CheckChangeConflictMany(chg.State(), snapNames, chg.ID()); err != nil {
+		return errToResponse(err, snapNames, BadRequest, "cannot retry change: %v")
+	}
+
+	if err := c.d.overlord.TaskRunner().Retry(chg); err != nil {
+		return BadRequest("%v", err)
+	}
+
+	ensureStateSoon(chg.State())
 
 	return SyncResponse(change2changeInfo(chg))
 }
@@ -394,6 +479,43 @@ func change2changeInfo(chg *state.Change) *changeInfo {
 	return chgInfo
 }
 
+func archived2changeInfo(e *changearchive.Entry) *changeInfo {
+	optionalTime := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		return &t
+	}
+	chgInfo := &changeInfo{
+		ID:      e.ID,
+		Kind:    e.Kind,
+		Summary: e.Summary,
+		Status:  e.Status,
+		Ready:   e.Ready,
+		Err:     e.Err,
+
+		SpawnTime: e.SpawnTime,
+		ReadyTime: optionalTime(e.ReadyTime),
+
+		Data: e.Data,
+	}
+	chgInfo.Tasks = make([]*taskInfo, len(e.Tasks))
+	for j, t := range e.Tasks {
+		chgInfo.Tasks[j] = &taskInfo{
+			ID:       t.ID,
+			Kind:     t.Kind,
+			Summary:  t.Summary,
+			Status:   t.Status,
+			Log:      t.Log,
+			Progress: taskInfoProgress(t.Progress),
+
+			SpawnTime: t.SpawnTime,
+			ReadyTime: optionalTime(t.ReadyTime),
+		}
+	}
+	return chgInfo
+}
+
 var (
 	stateOkayWarnings    = (*state.State).OkayWarnings
 	stateAllWarnings     = (*state.State).AllWarnings
@@ -30,6 +30,7 @@ import (
 	"time"
 
 	"gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/arch"
 	"github.com/snapcore/snapd/boot"
@@ -37,10 +38,12 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces/ifacetest"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/changearchive"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/sandbox"
+	"github.com/snapcore/snapd/testutil"
 )
 
 var _ = check.Suite(&generalSuite{})
@@ -504,6 +507,91 @@ func (s *generalSuite) TestStateChangesForSnapName(c *check.C) {
 	c.Assert(rec.Code, check.Equals, 200)
 }
 
+func (s *generalSuite) TestStateChangesKindAndSince(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	d := s.daemon(c)
+	st := d.Overlord().State()
+	st.Lock()
+	setupChanges(st)
+	st.Unlock()
+
+	kinds := func(query string) []string {
+		req, err := http.NewRequest("GET", "/v2/changes?"+query, nil)
+		c.Assert(err, check.IsNil)
+		rsp := s.syncReq(c, req, nil)
+		c.Assert(rsp.Result, check.FitsTypeOf, []*daemon.ChangeInfo(nil))
+		var kinds []string
+		for _, chg := range rsp.Result.([]*daemon.ChangeInfo) {
+			kinds = append(kinds, chg.Kind)
+		}
+		return kinds
+	}
+
+	c.Check(kinds("select=all&kind=remove"), check.DeepEquals, []string{"remove"})
+	c.Check(kinds("select=all&kind=other"), check.HasLen, 0)
+	c.Check(kinds("select=all&snap=funky-snap-name"), check.DeepEquals, []string{"install"})
+	// changes that were not ready yet at that time
+	c.Check(kinds("select=all&since=2016-04-21T01:02:03Z"), testutil.DeepUnsortedMatches, []string{"install", "remove"})
+	c.Check(kinds("select=all&since=2016-04-21T01:02:04Z"), check.DeepEquals, []string{"install"})
+}
+
+func (s *generalSuite) TestStateChangesBadParameters(c *check.C) {
+	s.daemon(c)
+
+	for _, t := range []struct {
+		query string
+		err   string
+	}{
+		{"select=foo", "select should be one of: all,in-progress,ready,archived"},
+		{"since=yesterday", `invalid since parameter: .*`},
+		{"for=foo&snap=bar", "cannot use both for and snap parameters with different snaps"},
+	} {
+		req, err := http.NewRequest("GET", "/v2/changes?"+t.query, nil)
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Matches, t.err)
+	}
+}
+
+func (s *generalSuite) TestStateChangesArchived(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	d := s.daemon(c)
+	st := d.Overlord().State()
+	st.Lock()
+	ids := setupChanges(st)
+	for _, id := range ids[:2] {
+		c.Assert(changearchive.Add(st.Change(id)), check.IsNil)
+	}
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/changes?select=archived&kind=remove", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.HasLen, 1)
+
+	rec := httptest.NewRecorder()
+	rsp.ServeHTTP(rec, nil)
+	c.Assert(rec.Code, check.Equals, 200)
+	// the same as for the changes in the state
+	c.Check(rec.Body.String(), check.Matches, `.*{"id":"\w+","kind":"remove","summary":"remove..","status":"Error","tasks":\[{"id":"\w+","kind":"unlink","summary":"1...","status":"Error","log":\["2016-04-21T01:02:03Z ERROR rm failed"],"progress":{"label":"","done":1,"total":1},"spawn-time":"2016-04-21T01:02:03Z","ready-time":"2016-04-21T01:02:03Z"}.*],"ready":true,"err":"[^"]+".*`)
+
+	req, err = http.NewRequest("GET", "/v2/changes?select=archived&snap=funky-snap-name", nil)
+	c.Assert(err, check.IsNil)
+	rsp = s.syncReq(c, req, nil)
+	c.Assert(rsp.Result, check.FitsTypeOf, []*daemon.ChangeInfo(nil))
+	res := rsp.Result.([]*daemon.ChangeInfo)
+	c.Assert(res, check.HasLen, 1)
+	c.Check(res[0].ID, check.Equals, ids[0])
+	c.Check(res[0].Tasks, check.HasLen, 2)
+	c.Check(res[0].ReadyTime, check.IsNil)
+}
+
 func (s *generalSuite) TestStateChangesForSnapNameWithApp(c *check.C) {
 	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
 	defer restore()
@@ -709,6 +797,94 @@ func (s *generalSuite) TestStateChangeAbortIsReady(c *check.C) {
 	})
 }
 
+func (s *generalSuite) setupFailedChange(c *check.C, d *daemon.Daemon, failedKind string) (chgID string) {
+	runner := d.Overlord().TaskRunner()
+	nop := func(*state.Task, *tomb.Tomb) error { return nil }
+	runner.AddHandler("fetch", nop, nil)
+	runner.AddHandler("link", nop, nil)
+	c.Assert(runner.MarkIdempotent("fetch"), check.IsNil)
+	c.Assert(runner.MarkIdempotent("link"), check.IsNil)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.NewChange("install", "install...")
+	chg.Set("snap-names", []string{"foo"})
+	t1 := st.NewTask(failedKind, "1...")
+	t2 := st.NewTask("link", "2...")
+	t2.WaitFor(t1)
+	chg.AddAll(state.NewTaskSet(t1, t2))
+	t1.SetStatus(state.ErrorStatus)
+	t1.Errorf("fetch failed")
+	t2.SetStatus(state.HoldStatus)
+	t1.SetClean()
+	t2.SetClean()
+	c.Assert(chg.Status(), check.Equals, state.ErrorStatus)
+	return chg.ID()
+}
+
+func (s *generalSuite) TestStateChangeRetry(c *check.C) {
+	soon := 0
+	_, restore := daemon.MockEnsureStateSoon(func(st *state.State) {
+		soon++
+	})
+	defer restore()
+
+	d := s.daemon(c)
+	id := s.setupFailedChange(c, d, "fetch")
+
+	s.expectManageAccess()
+
+	buf := bytes.NewBufferString(`{"action": "retry"}`)
+	req, err := http.NewRequest("POST", "/v2/changes/"+id, buf)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(soon, check.Equals, 1)
+	c.Assert(rsp.Result, check.FitsTypeOf, &daemon.ChangeInfo{})
+	chgInfo := rsp.Result.(*daemon.ChangeInfo)
+	c.Check(chgInfo.Status, check.Equals, "Do")
+	c.Check(chgInfo.Ready, check.Equals, false)
+	c.Check(chgInfo.Err, check.Equals, "")
+	c.Assert(chgInfo.Tasks, check.HasLen, 2)
+	c.Check(chgInfo.Tasks[0].Status, check.Equals, "Do")
+	c.Check(chgInfo.Tasks[1].Status, check.Equals, "Do")
+}
+
+func (s *generalSuite) TestStateChangeRetryNotIdempotent(c *check.C) {
+	d := s.daemon(c)
+	d.Overlord().TaskRunner().AddHandler("download-once", func(*state.Task, *tomb.Tomb) error { return nil }, nil)
+	id := s.setupFailedChange(c, d, "download-once")
+
+	s.expectManageAccess()
+
+	buf := bytes.NewBufferString(`{"action": "retry"}`)
+	req, err := http.NewRequest("POST", "/v2/changes/"+id, buf)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, fmt.Sprintf(`cannot retry change %s: task "1..." cannot be run again`, id))
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Change(id).Status(), check.Equals, state.ErrorStatus)
+}
+
+func (s *generalSuite) TestStateChangeUnsupportedAction(c *check.C) {
+	d := s.daemon(c)
+	id := s.setupFailedChange(c, d, "fetch")
+
+	s.expectManageAccess()
+
+	buf := bytes.NewBufferString(`{"action": "frobnicate"}`)
+	req, err := http.NewRequest("POST", "/v2/changes/"+id, buf)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `change action "frobnicate" is unsupported`)
+}
+
 func (s *generalSuite) testWarnings(c *check.C, all bool, body io.Reader) (calls string, result interface{}) {
 	s.daemon(c)
 
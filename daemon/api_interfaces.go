@@ -26,8 +26,10 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/policy"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -59,6 +61,9 @@ func getInterfaces(c *Command, r *http.Request, user *auth.UserState) Response {
 	// Collect query options from request arguments.
 	q := r.URL.Query()
 	pselect := q.Get("select")
+	if pselect == "explain" {
+		return explainInterfaceConnection(c, r)
+	}
 	if pselect != "all" && pselect != "connected" {
 		return BadRequest("unsupported select qualifier")
 	}
@@ -141,6 +146,17 @@ func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Respons
 	if len(a.Plugs) == 0 || len(a.Slots) == 0 {
 		return BadRequest("at least one plug and slot is required")
 	}
+	var expiry time.Time
+	if a.For != "" {
+		if a.Action != "connect" {
+			return BadRequest("duration can only be specified for the connect action")
+		}
+		duration, err := time.ParseDuration(a.For)
+		if err != nil || duration <= 0 {
+			return BadRequest("invalid duration of connection: %q", a.For)
+		}
+		expiry = time.Now().Add(duration)
+	}
 
 	var summary string
 	var err error
@@ -152,31 +168,15 @@ func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Respons
 	st.Lock()
 	defer st.Unlock()
 
-	checkInstalled := func(snapName string) error {
-		// empty snap name is fine, ResolveConnect/ResolveDisconnect handles it.
-		if snapName == "" {
-			return nil
-		}
-		var snapst snapstate.SnapState
-		err := snapstate.Get(st, snapName, &snapst)
-		if (err == nil && !snapst.IsInstalled()) || errors.Is(err, state.ErrNoState) {
-			return fmt.Errorf("snap %q is not installed", snapName)
-		}
-		if err == nil {
-			return nil
-		}
-		return fmt.Errorf("internal error: cannot get state of snap %q: %v", snapName, err)
-	}
-
 	for i := range a.Plugs {
 		a.Plugs[i].Snap = ifacestate.RemapSnapFromRequest(a.Plugs[i].Snap)
-		if err := checkInstalled(a.Plugs[i].Snap); err != nil {
+		if err := checkInterfaceSnapInstalled(st, a.Plugs[i].Snap); err != nil {
 			return errToResponse(err, nil, BadRequest, "%v")
 		}
 	}
 	for i := range a.Slots {
 		a.Slots[i].Snap = ifacestate.RemapSnapFromRequest(a.Slots[i].Snap)
-		if err := checkInstalled(a.Slots[i].Snap); err != nil {
+		if err := checkInterfaceSnapInstalled(st, a.Slots[i].Snap); err != nil {
 			return errToResponse(err, nil, BadRequest, "%v")
 		}
 	}
@@ -190,7 +190,12 @@ func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Respons
 			var ts *state.TaskSet
 			affected = snapNamesFromConns([]*interfaces.ConnRef{connRef})
 			summary = fmt.Sprintf("Connect %s:%s to %s:%s", connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
-			ts, err = ifacestate.Connect(st, connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
+			if expiry.IsZero() {
+				ts, err = ifacestate.Connect(st, connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
+			} else {
+				summary += fmt.Sprintf(" until %s", expiry.Format(time.RFC3339))
+				ts, err = ifacestate.ConnectUntil(st, connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name, expiry)
+			}
 			if _, ok := err.(*ifacestate.ErrAlreadyConnected); ok {
 				change := newChange(st, a.Action+"-snap", summary, nil, affected)
 				change.SetStatus(state.DoneStatus)
@@ -241,6 +246,139 @@ func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Respons
 	return AsyncResponse(nil, change.ID())
 }
 
+// checkInterfaceSnapInstalled returns an error if the named snap is not
+// installed. The state must be locked.
+func checkInterfaceSnapInstalled(st *state.State, snapName string) error {
+	// empty snap name is fine, ResolveConnect/ResolveDisconnect handles it.
+	if snapName == "" {
+		return nil
+	}
+	var snapst snapstate.SnapState
+	err := snapstate.Get(st, snapName, &snapst)
+	if (err == nil && !snapst.IsInstalled()) || errors.Is(err, state.ErrNoState) {
+		return fmt.Errorf("snap %q is not installed", snapName)
+	}
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("internal error: cannot get state of snap %q: %v", snapName, err)
+}
+
+// explainInterfaceConnection describes how the policies are evaluated for
+// connecting the plug to the slot given as <snap>:<name> in the "plug" and
+// "slot" query parameters, without connecting them. Either the snap or the
+// name of the slot can be left empty.
+func explainInterfaceConnection(c *Command, r *http.Request) Response {
+	q := r.URL.Query()
+	plugSnap, plugName := splitSnapAndName(q.Get("plug"))
+	slotSnap, slotName := splitSnapAndName(q.Get("slot"))
+	if plugSnap == "" || plugName == "" {
+		return BadRequest("explaining a connection requires a plug")
+	}
+	if q.Get("slot") == "" {
+		return BadRequest("explaining a connection requires a slot")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	plugSnap = ifacestate.RemapSnapFromRequest(plugSnap)
+	slotSnap = ifacestate.RemapSnapFromRequest(slotSnap)
+	for _, snapName := range []string{plugSnap, slotSnap} {
+		if err := checkInterfaceSnapInstalled(st, snapName); err != nil {
+			return errToResponse(err, nil, BadRequest, "%v")
+		}
+	}
+
+	repo := c.d.overlord.InterfaceManager().Repository()
+	connRef, err := repo.ResolveConnect(plugSnap, plugName, slotSnap, slotName)
+	if err != nil {
+		return errToResponse(err, nil, BadRequest, "%v")
+	}
+	return explainConnection(st, repo, connRef)
+}
+
+// splitSnapAndName splits <snap>:<name> into the snap and the name, a value
+// without a colon is the snap.
+func splitSnapAndName(s string) (snapName, name string) {
+	if i := strings.IndexRune(s, ':'); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// explainConnection describes how the connection and auto-connection
+// policies are evaluated for the given connection.
+func explainConnection(st *state.State, repo *interfaces.Repository, connRef *interfaces.ConnRef) Response {
+	plug := repo.Plug(connRef.PlugRef.Snap, connRef.PlugRef.Name)
+	slot := repo.Slot(connRef.SlotRef.Snap, connRef.SlotRef.Name)
+	if plug == nil || slot == nil {
+		return InternalError("cannot find plug or slot of %s", connRef)
+	}
+	deviceCtx, err := snapstate.DeviceCtx(st, nil, nil)
+	if err != nil {
+		return InternalError("cannot get device context: %v", err)
+	}
+	expl, err := ifacestate.ExplainConnectionPolicy(st, plug, slot, deviceCtx)
+	if err != nil {
+		return InternalError("cannot explain connection: %v", err)
+	}
+
+	return SyncResponse(&connectionExplanationJSON{
+		Plug:           connRef.PlugRef,
+		Slot:           connRef.SlotRef,
+		Interface:      plug.Interface,
+		Unasserted:     expl.Unasserted,
+		Connection:     policyExplanationToJSON(expl.Connection),
+		AutoConnection: policyExplanationToJSON(expl.AutoConnection),
+	})
+}
+
+func policyExplanationToJSON(expl *policy.ConnectionExplanation) *policyExplanationJSON {
+	res := &policyExplanationJSON{
+		Allowed: expl.Err == nil,
+		Rules:   make([]*ruleExplanationJSON, 0, len(expl.Rules)),
+	}
+	if expl.Err != nil {
+		res.Error = expl.Err.Error()
+	}
+	for _, re := range expl.Rules {
+		res.Rules = append(res.Rules, &ruleExplanationJSON{
+			Declaration: re.Declaration,
+			Snap:        re.SnapName,
+			Side:        re.Side,
+			Found:       re.Found,
+			Verdict:     re.Verdict,
+			Deny:        constraintsExplanationsToJSON(re.Deny),
+			Allow:       constraintsExplanationsToJSON(re.Allow),
+		})
+	}
+	return res
+}
+
+func constraintsExplanationsToJSON(expls []*policy.ConstraintsExplanation) []*constraintsExplanationJSON {
+	if len(expls) == 0 {
+		return nil
+	}
+	res := make([]*constraintsExplanationJSON, 0, len(expls))
+	for _, ce := range expls {
+		cj := &constraintsExplanationJSON{Matched: ce.Matched}
+		for _, constraint := range ce.Constraints {
+			cons := constraintExplanationJSON{
+				Name:    constraint.Name,
+				Matched: constraint.Err == nil,
+			}
+			if constraint.Err != nil {
+				cons.Error = constraint.Err.Error()
+			}
+			cj.Constraints = append(cj.Constraints, cons)
+		}
+		res = append(res, cj)
+	}
+	return res
+}
+
 func snapNamesFromConns(conns []*interfaces.ConnRef) []string {
 	m := make(map[string]bool)
 	for _, conn := range conns {
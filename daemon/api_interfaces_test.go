@@ -26,9 +26,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"time"
 
 	"gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/asserts/assertstest"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/daemon"
 	"github.com/snapcore/snapd/interfaces"
@@ -177,6 +179,91 @@ func (s *interfacesSuite) TestConnectPlugSuccess(c *check.C) {
 	}})
 }
 
+func (s *interfacesSuite) TestConnectPlugFor(c *check.C) {
+	restore := builtin.MockInterface(&ifacetest.TestInterface{InterfaceName: "test"})
+	defer restore()
+
+	d := s.daemon(c)
+
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	d.Overlord().Loop()
+	defer d.Overlord().Stop()
+
+	action := &client.InterfaceAction{
+		Action: "connect",
+		Plugs:  []client.Plug{{Snap: "consumer", Name: "plug"}},
+		Slots:  []client.Slot{{Snap: "producer", Name: "slot"}},
+		For:    "2h",
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	c.Assert(err, check.IsNil)
+	before := time.Now()
+	rec := httptest.NewRecorder()
+	s.req(c, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 202)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	id := body["change"].(string)
+
+	st := d.Overlord().State()
+	st.Lock()
+	chg := st.Change(id)
+	st.Unlock()
+	c.Assert(chg, check.NotNil)
+
+	<-chg.Ready()
+
+	st.Lock()
+	err = chg.Err()
+	summary := chg.Summary()
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(summary, check.Matches, `Connect consumer:plug to producer:slot until .*`)
+
+	conns, err := d.Overlord().InterfaceManager().ConnectionStates()
+	c.Assert(err, check.IsNil)
+	c.Assert(conns, check.HasLen, 1)
+	expiry := conns["consumer:plug producer:slot"].Expiry
+	c.Check(expiry.Before(before.Add(2*time.Hour)), check.Equals, false)
+	c.Check(expiry.After(time.Now().Add(2*time.Hour)), check.Equals, false)
+}
+
+func (s *interfacesSuite) TestConnectPlugForInvalid(c *check.C) {
+	s.daemon(c)
+
+	for _, t := range []struct {
+		action  string
+		forWhat string
+		message string
+	}{
+		{"connect", "soon", `invalid duration of connection: "soon"`},
+		{"connect", "-2h", `invalid duration of connection: "-2h"`},
+		{"connect", "0s", `invalid duration of connection: "0s"`},
+		{"disconnect", "2h", `duration can only be specified for the connect action`},
+	} {
+		action := &client.InterfaceAction{
+			Action: t.action,
+			Plugs:  []client.Plug{{Snap: "consumer", Name: "plug"}},
+			Slots:  []client.Slot{{Snap: "producer", Name: "slot"}},
+			For:    t.forWhat,
+		}
+		text, err := json.Marshal(action)
+		c.Assert(err, check.IsNil)
+		buf := bytes.NewBuffer(text)
+		req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Equals, t.message)
+	}
+}
+
 func (s *interfacesSuite) TestConnectPlugFailureInterfaceMismatch(c *check.C) {
 	d := s.daemon(c)
 
@@ -1005,6 +1092,136 @@ func (s *interfacesSuite) TestUnsupportedInterfaceAction(c *check.C) {
 	})
 }
 
+func (s *interfacesSuite) TestExplainConnection(c *check.C) {
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    allow-connection:
+      plug-snap-type:
+        - core
+    deny-auto-connection: true
+`))
+	defer restore()
+
+	d := s.daemon(c)
+
+	mockIface(c, d, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	req, err := http.NewRequest("GET", "/v2/interfaces?select=explain&plug=consumer:plug&slot=producer", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+
+	// round trip through JSON to check the shape of the response
+	data, err := json.Marshal(rsp.Result)
+	c.Assert(err, check.IsNil)
+	var result map[string]interface{}
+	c.Assert(json.Unmarshal(data, &result), check.IsNil)
+
+	c.Check(result["plug"], check.DeepEquals, map[string]interface{}{"snap": "consumer", "plug": "plug"})
+	c.Check(result["slot"], check.DeepEquals, map[string]interface{}{"snap": "producer", "slot": "slot"})
+	c.Check(result["interface"], check.Equals, "test")
+	c.Check(result["unasserted"], check.DeepEquals, []interface{}{"consumer", "producer"})
+
+	notFound := func(side string) map[string]interface{} {
+		return map[string]interface{}{
+			"declaration": "base-declaration",
+			"side":        side,
+			"found":       false,
+		}
+	}
+	c.Check(result["connection"], check.DeepEquals, map[string]interface{}{
+		"allowed": false,
+		"error":   `connection not allowed by slot rule of interface "test"`,
+		"rules": []interface{}{
+			notFound("plug"),
+			map[string]interface{}{
+				"declaration": "base-declaration",
+				"side":        "slot",
+				"found":       true,
+				"verdict":     "not-allowed",
+				"deny": []interface{}{
+					map[string]interface{}{
+						"matched": false,
+						"constraints": []interface{}{
+							map[string]interface{}{"name": "false", "matched": false, "error": "not allowed"},
+						},
+					},
+				},
+				"allow": []interface{}{
+					map[string]interface{}{
+						"matched": false,
+						"constraints": []interface{}{
+							map[string]interface{}{"name": "plug-snap-type", "matched": false, "error": "snap type does not match"},
+						},
+					},
+				},
+			},
+		},
+	})
+	autoConn := result["auto-connection"].(map[string]interface{})
+	c.Check(autoConn["allowed"], check.Equals, false)
+	c.Check(autoConn["error"], check.Equals, `auto-connection denied by slot rule of interface "test"`)
+}
+
+func (s *interfacesSuite) TestExplainConnectionNoSuchPlug(c *check.C) {
+	d := s.daemon(c)
+
+	mockIface(c, d, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	req, err := http.NewRequest("GET", "/v2/interfaces?select=explain&plug=consumer:missing&slot=producer:slot", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `snap "consumer" has no plug named "missing"`)
+}
+
+func (s *interfacesSuite) TestExplainConnectionBadRequest(c *check.C) {
+	d := s.daemon(c)
+
+	mockIface(c, d, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+
+	for _, t := range []struct {
+		query string
+		err   string
+	}{
+		{"slot=producer", "explaining a connection requires a plug"},
+		{"plug=consumer&slot=producer", "explaining a connection requires a plug"},
+		{"plug=consumer:plug", "explaining a connection requires a slot"},
+		{"plug=consumer:plug&slot=producer", `snap "producer" is not installed`},
+	} {
+		req, err := http.NewRequest("GET", "/v2/interfaces?select=explain&"+t.query, nil)
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400, check.Commentf(t.query))
+		c.Check(rspe.Message, check.Equals, t.err, check.Commentf(t.query))
+	}
+}
+
+func (s *interfacesSuite) TestExplainConnectionNotAnAction(c *check.C) {
+	s.daemon(c)
+
+	action := &client.InterfaceAction{
+		Action: "explain",
+		Plugs:  []client.Plug{{Snap: "consumer", Name: "plug"}},
+		Slots:  []client.Slot{{Snap: "producer", Name: "slot"}},
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/interfaces", bytes.NewBuffer(text))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `unsupported interface action: "explain"`)
+}
+
 // Tests for GET /v2/interfaces
 
 func (s *interfacesSuite) TestInterfacesLegacy(c *check.C) {
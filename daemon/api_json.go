@@ -20,6 +20,8 @@
 package daemon
 
 import (
+	"time"
+
 	"github.com/snapcore/snapd/interfaces"
 )
 
@@ -62,6 +64,56 @@ type interfaceAction struct {
 	Forget bool       `json:"forget,omitempty"`
 	Plugs  []plugJSON `json:"plugs,omitempty"`
 	Slots  []slotJSON `json:"slots,omitempty"`
+	// For is the duration of a connection, after which it is
+	// automatically disconnected
+	For string `json:"for,omitempty"`
+}
+
+// connectionExplanationJSON aids in marshaling the evaluation of the
+// connection policies for a plug and a slot into JSON.
+type connectionExplanationJSON struct {
+	Plug       interfaces.PlugRef `json:"plug"`
+	Slot       interfaces.SlotRef `json:"slot"`
+	Interface  string             `json:"interface"`
+	Unasserted []string           `json:"unasserted,omitempty"`
+	// Connection is obeyed by manual connections and connections by
+	// the gadget, AutoConnection by other auto-connections.
+	Connection     *policyExplanationJSON `json:"connection"`
+	AutoConnection *policyExplanationJSON `json:"auto-connection"`
+}
+
+// policyExplanationJSON aids in marshaling policy.ConnectionExplanation
+// into JSON.
+type policyExplanationJSON struct {
+	Allowed bool                   `json:"allowed"`
+	Error   string                 `json:"error,omitempty"`
+	Rules   []*ruleExplanationJSON `json:"rules"`
+}
+
+// ruleExplanationJSON aids in marshaling policy.RuleExplanation into JSON.
+type ruleExplanationJSON struct {
+	Declaration string                        `json:"declaration"`
+	Snap        string                        `json:"snap,omitempty"`
+	Side        string                        `json:"side"`
+	Found       bool                          `json:"found"`
+	Verdict     string                        `json:"verdict,omitempty"`
+	Deny        []*constraintsExplanationJSON `json:"deny,omitempty"`
+	Allow       []*constraintsExplanationJSON `json:"allow,omitempty"`
+}
+
+// constraintsExplanationJSON aids in marshaling
+// policy.ConstraintsExplanation into JSON.
+type constraintsExplanationJSON struct {
+	Matched     bool                        `json:"matched"`
+	Constraints []constraintExplanationJSON `json:"constraints,omitempty"`
+}
+
+// constraintExplanationJSON aids in marshaling policy.ConstraintExplanation
+// into JSON.
+type constraintExplanationJSON struct {
+	Name    string `json:"name"`
+	Matched bool   `json:"matched"`
+	Error   string `json:"error,omitempty"`
 }
 
 // connectionsJSON aids in marshalling information about a single connection
@@ -74,6 +126,7 @@ type connectionJSON struct {
 	Gadget    bool                   `json:"gadget,omitempty"`
 	SlotAttrs map[string]interface{} `json:"slot-attrs,omitempty"`
 	PlugAttrs map[string]interface{} `json:"plug-attrs,omitempty"`
+	Expiry    *time.Time             `json:"expiry,omitempty"`
 }
 
 // legacyConnectionsJSON aids in marshaling legacy connections into JSON.
@@ -49,11 +49,12 @@ var (
 
 type postQuotaGroupData struct {
 	// Action can be "ensure" or "remove"
-	Action      string             `json:"action"`
-	GroupName   string             `json:"group-name"`
-	Parent      string             `json:"parent,omitempty"`
-	Snaps       []string           `json:"snaps,omitempty"`
-	Constraints client.QuotaValues `json:"constraints,omitempty"`
+	Action      string                   `json:"action"`
+	GroupName   string                   `json:"group-name"`
+	Parent      string                   `json:"parent,omitempty"`
+	Snaps       []string                 `json:"snaps,omitempty"`
+	Constraints client.QuotaValues       `json:"constraints,omitempty"`
+	LimitPolicy *client.QuotaLimitPolicy `json:"limit-policy,omitempty"`
 }
 
 var (
@@ -105,6 +106,16 @@ func createQuotaValues(grp *quota.Group) *client.QuotaValues {
 			RatePeriod: grp.JournalLimit.RatePeriod,
 		}
 	}
+	if grp.IOLimit != nil {
+		constraints.IO = &client.QuotaIOValues{
+			Device:         grp.IOLimit.Device,
+			ReadBandwidth:  grp.IOLimit.ReadBandwidth,
+			WriteBandwidth: grp.IOLimit.WriteBandwidth,
+			ReadIOPS:       grp.IOLimit.ReadIOPS,
+			WriteIOPS:      grp.IOLimit.WriteIOPS,
+			Weight:         grp.IOLimit.Weight,
+		}
+	}
 	return &constraints
 }
 
@@ -173,6 +184,11 @@ func getQuotaGroupInfo(c *Command, r *http.Request, _ *auth.UserState) Response
 		return InternalError(err.Error())
 	}
 
+	history, err := servicestate.QuotaUsageHistory(st, groupName)
+	if err != nil {
+		return InternalError(err.Error())
+	}
+
 	res := client.QuotaGroupResult{
 		GroupName:   group.Name,
 		Parent:      group.ParentGroup,
@@ -180,10 +196,34 @@ func getQuotaGroupInfo(c *Command, r *http.Request, _ *auth.UserState) Response
 		Subgroups:   group.SubGroups,
 		Constraints: createQuotaValues(group),
 		Current:     currentUsage,
+		History:     quotaUsageHistoryToClient(history),
+	}
+	if group.LimitPolicy != nil {
+		res.LimitPolicy = &client.QuotaLimitPolicy{
+			Action:        string(group.LimitPolicy.Action),
+			MemoryCeiling: group.LimitPolicy.MemoryCeiling,
+		}
 	}
 	return SyncResponse(res)
 }
 
+func quotaUsageHistoryToClient(history []servicestate.QuotaUsageSample) []client.QuotaUsageSample {
+	if len(history) == 0 {
+		return nil
+	}
+	res := make([]client.QuotaUsageSample, 0, len(history))
+	for _, sample := range history {
+		res = append(res, client.QuotaUsageSample{
+			Time:        sample.Time,
+			CPUTime:     sample.CPUTime,
+			Memory:      sample.Memory,
+			Threads:     sample.Threads,
+			JournalSize: sample.JournalSize,
+		})
+	}
+	return res
+}
+
 func quotaValuesToResources(values client.QuotaValues) quota.Resources {
 	resourcesBuilder := quota.NewResourcesBuilder()
 	if values.Memory != 0 {
@@ -212,6 +252,29 @@ func quotaValuesToResources(values client.QuotaValues) quota.Resources {
 			resourcesBuilder.WithJournalRate(values.Journal.RateCount, values.Journal.RatePeriod)
 		}
 	}
+	if values.IO != nil {
+		if values.IO.Device != "" {
+			resourcesBuilder.WithIODevice(values.IO.Device)
+		}
+		if values.IO.ReadBandwidth != 0 {
+			resourcesBuilder.WithIOReadBandwidth(values.IO.ReadBandwidth)
+		}
+		if values.IO.WriteBandwidth != 0 {
+			resourcesBuilder.WithIOWriteBandwidth(values.IO.WriteBandwidth)
+		}
+		if values.IO.ReadIOPS != 0 {
+			resourcesBuilder.WithIOReadIOPS(values.IO.ReadIOPS)
+		}
+		if values.IO.WriteIOPS != 0 {
+			resourcesBuilder.WithIOWriteIOPS(values.IO.WriteIOPS)
+		}
+		if values.IO.Weight != 0 {
+			resourcesBuilder.WithIOWeight(values.IO.Weight)
+		}
+		if len(values.IO.Unset) != 0 {
+			resourcesBuilder.WithIOUnset(values.IO.Unset...)
+		}
+	}
 	return resourcesBuilder.Build()
 }
 
@@ -247,6 +310,9 @@ func postQuotaGroup(c *Command, r *http.Request, _ *auth.UserState) Response {
 			return InternalError(err.Error())
 		}
 		if err == servicestate.ErrQuotaNotFound {
+			if data.LimitPolicy != nil {
+				return BadRequest("cannot set limit policy of non-existent quota group %q", data.GroupName)
+			}
 			// then we need to create the quota
 			ts, err = servicestateCreateQuota(st, data.GroupName, data.Parent, data.Snaps, resourceLimits)
 			if err != nil {
@@ -259,6 +325,12 @@ func postQuotaGroup(c *Command, r *http.Request, _ *auth.UserState) Response {
 				AddSnaps:          data.Snaps,
 				NewResourceLimits: resourceLimits,
 			}
+			if data.LimitPolicy != nil {
+				updateOpts.NewLimitPolicy = &quota.LimitPolicy{
+					Action:        quota.LimitAction(data.LimitPolicy.Action),
+					MemoryCeiling: data.LimitPolicy.MemoryCeiling,
+				}
+			}
 			ts, err = servicestateUpdateQuota(st, data.GroupName, updateOpts)
 			if err != nil {
 				return errToResponse(err, nil, BadRequest, "cannot update quota group: %v")
@@ -94,6 +94,10 @@ func (s *apiQuotaSuite) TestCreateQuotaValues(c *check.C) {
 			WithCPUSet([]int{0, 1}).
 			WithJournalRate(150, time.Second).
 			WithJournalSize(quantity.SizeMiB).
+			WithIODevice("/dev/sda").
+			WithIOReadBandwidth(10*quantity.SizeMiB).
+			WithIOWriteIOPS(100).
+			WithIOWeight(200).
 			Build())
 	allGroups, err2 := servicestate.AllQuotas(st)
 	st.Unlock()
@@ -120,6 +124,12 @@ func (s *apiQuotaSuite) TestCreateQuotaValues(c *check.C) {
 		RateCount:  150,
 		RatePeriod: time.Second,
 	})
+	c.Check(quotaValues.IO, check.DeepEquals, &client.QuotaIOValues{
+		Device:        "/dev/sda",
+		ReadBandwidth: 10 * quantity.SizeMiB,
+		WriteIOPS:     100,
+		Weight:        200,
+	})
 }
 
 func (s *apiQuotaSuite) TestPostQuotaUnknownAction(c *check.C) {
@@ -419,6 +429,155 @@ func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateMemoryHappy(c *check.C) {
 	c.Assert(s.ensureSoonCalled, check.Equals, 1)
 }
 
+func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateIOHappy(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	err := servicestatetest.MockQuotaInState(st, "ginger-ale", "", nil,
+		quota.NewResourcesBuilder().
+			WithIOReadBandwidth(quantity.SizeMiB).
+			Build())
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	updateCalled := 0
+	r := daemon.MockServicestateUpdateQuota(func(st *state.State, name string, opts servicestate.QuotaGroupUpdate) (*state.TaskSet, error) {
+		updateCalled++
+		c.Assert(name, check.Equals, "ginger-ale")
+		c.Assert(opts, check.DeepEquals, servicestate.QuotaGroupUpdate{
+			NewResourceLimits: quota.NewResourcesBuilder().
+				WithIODevice("/dev/mmcblk0").
+				WithIOReadBandwidth(2 * quantity.SizeMiB).
+				WithIOWriteBandwidth(quantity.SizeMiB).
+				WithIOReadIOPS(10).
+				WithIOWriteIOPS(20).
+				WithIOWeight(300).
+				Build(),
+		})
+		ts := state.NewTaskSet(st.NewTask("foo-quota", "..."))
+		return ts, nil
+	})
+	defer r()
+
+	data, err := json.Marshal(daemon.PostQuotaGroupData{
+		Action:    "ensure",
+		GroupName: "ginger-ale",
+		Constraints: client.QuotaValues{
+			IO: &client.QuotaIOValues{
+				Device:         "/dev/mmcblk0",
+				ReadBandwidth:  2 * quantity.SizeMiB,
+				WriteBandwidth: quantity.SizeMiB,
+				ReadIOPS:       10,
+				WriteIOPS:      20,
+				Weight:         300,
+			},
+		},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Assert(updateCalled, check.Equals, 1)
+	c.Assert(s.ensureSoonCalled, check.Equals, 1)
+}
+
+func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateIOUnset(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	err := servicestatetest.MockQuotaInState(st, "ginger-ale", "", nil,
+		quota.NewResourcesBuilder().
+			WithIOReadBandwidth(quantity.SizeMiB).
+			WithIOWeight(300).
+			Build())
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	updateCalled := 0
+	r := daemon.MockServicestateUpdateQuota(func(st *state.State, name string, opts servicestate.QuotaGroupUpdate) (*state.TaskSet, error) {
+		updateCalled++
+		c.Assert(opts, check.DeepEquals, servicestate.QuotaGroupUpdate{
+			NewResourceLimits: quota.NewResourcesBuilder().
+				WithIOUnset("weight").
+				Build(),
+		})
+		ts := state.NewTaskSet(st.NewTask("foo-quota", "..."))
+		return ts, nil
+	})
+	defer r()
+
+	data, err := json.Marshal(daemon.PostQuotaGroupData{
+		Action:    "ensure",
+		GroupName: "ginger-ale",
+		Constraints: client.QuotaValues{
+			IO: &client.QuotaIOValues{
+				Unset: []string{"weight"},
+			},
+		},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Assert(updateCalled, check.Equals, 1)
+}
+
+func (s *apiQuotaSuite) TestPostEnsureQuotaLimitPolicyHappy(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	err := servicestatetest.MockQuotaInState(st, "ginger-ale", "", nil, quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build())
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	updateCalled := 0
+	r := daemon.MockServicestateUpdateQuota(func(st *state.State, name string, opts servicestate.QuotaGroupUpdate) (*state.TaskSet, error) {
+		updateCalled++
+		c.Assert(name, check.Equals, "ginger-ale")
+		c.Assert(opts, check.DeepEquals, servicestate.QuotaGroupUpdate{
+			NewLimitPolicy: &quota.LimitPolicy{
+				Action:        quota.LimitActionRaise,
+				MemoryCeiling: 2 * quantity.SizeGiB,
+			},
+		})
+		ts := state.NewTaskSet(st.NewTask("foo-quota", "..."))
+		return ts, nil
+	})
+	defer r()
+
+	data, err := json.Marshal(daemon.PostQuotaGroupData{
+		Action:    "ensure",
+		GroupName: "ginger-ale",
+		LimitPolicy: &client.QuotaLimitPolicy{
+			Action:        "raise",
+			MemoryCeiling: 2 * quantity.SizeGiB,
+		},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Assert(updateCalled, check.Equals, 1)
+}
+
+func (s *apiQuotaSuite) TestPostEnsureQuotaLimitPolicyNonExistent(c *check.C) {
+	data, err := json.Marshal(daemon.PostQuotaGroupData{
+		Action:      "ensure",
+		GroupName:   "ginger-ale",
+		LimitPolicy: &client.QuotaLimitPolicy{Action: "warn"},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot set limit policy of non-existent quota group "ginger-ale"`)
+}
+
 func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateConflicts(c *check.C) {
 	st := s.d.Overlord().State()
 	st.Lock()
@@ -712,6 +871,52 @@ func (s *apiQuotaSuite) TestGetQuota(c *check.C) {
 	c.Check(s.ensureSoonCalled, check.Equals, 0)
 }
 
+func (s *apiQuotaSuite) TestGetQuotaWithHistoryAndPolicy(c *check.C) {
+	t0 := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	st := s.d.Overlord().State()
+	st.Lock()
+	mockQuotas(st, c)
+	allGrps, err := servicestate.AllQuotas(st)
+	c.Assert(err, check.IsNil)
+	allGrps["bar"].LimitPolicy = &quota.LimitPolicy{Action: quota.LimitActionWarn}
+	st.Set("quotas", allGrps)
+	st.Set("quota-usage-history", map[string][]servicestate.QuotaUsageSample{
+		"bar": {
+			{Time: t0, CPUTime: time.Second, Memory: 400, Threads: 2},
+			{Time: t0.Add(5 * time.Minute), CPUTime: 3 * time.Second, Memory: 500, Threads: 3, JournalSize: quantity.SizeKiB},
+		},
+		"foo": {
+			{Time: t0, Memory: 1000},
+		},
+	})
+	st.Unlock()
+
+	r := daemon.MockGetQuotaUsage(func(grp *quota.Group) (*client.QuotaValues, error) {
+		return &client.QuotaValues{
+			Memory: quantity.Size(500),
+		}, nil
+	})
+	defer r()
+
+	req, err := http.NewRequest("GET", "/v2/quotas/bar", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.FitsTypeOf, client.QuotaGroupResult{})
+	res := rsp.Result.(client.QuotaGroupResult)
+	c.Check(res, check.DeepEquals, client.QuotaGroupResult{
+		GroupName:   "bar",
+		Parent:      "foo",
+		Constraints: &client.QuotaValues{Memory: quantity.Size(4194304)},
+		Current:     &client.QuotaValues{Memory: quantity.Size(500)},
+		History: []client.QuotaUsageSample{
+			{Time: t0, CPUTime: time.Second, Memory: 400, Threads: 2},
+			{Time: t0.Add(5 * time.Minute), CPUTime: 3 * time.Second, Memory: 500, Threads: 3, JournalSize: quantity.SizeKiB},
+		},
+		LimitPolicy: &client.QuotaLimitPolicy{Action: "warn"},
+	})
+}
+
 func (s *apiQuotaSuite) TestGetQuotaInvalidName(c *check.C) {
 	st := s.d.Overlord().State()
 	st.Lock()
@@ -752,6 +752,11 @@ func getSnapsInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 		all = true
 	case "enabled", "":
 		all = false
+	case "refresh-candidates":
+		if len(query.Get("snaps")) > 0 {
+			return BadRequest("cannot use 'snaps' with 'select=refresh-candidates'")
+		}
+		return refreshCandidates(c, user)
 	default:
 		return BadRequest("invalid select parameter: %q", sel)
 	}
@@ -796,6 +801,58 @@ func getSnapsInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	}
 }
 
+// refreshCandidates describes what refreshing the installed snaps to the
+// revisions offered by the store would change.
+func refreshCandidates(c *Command, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	plan, err := snapstateRefreshPlan(st, user)
+	st.Unlock()
+	if err != nil {
+		return InternalError("cannot list refresh candidates: %v", err)
+	}
+
+	results := make([]*client.RefreshCandidate, len(plan))
+	for i, planned := range plan {
+		results[i] = mapRefreshCandidate(planned)
+	}
+	return SyncResponse(results)
+}
+
+func mapRefreshCandidate(planned *snapstate.PlannedRefresh) *client.RefreshCandidate {
+	cand := &client.RefreshCandidate{
+		Name:                    planned.Current.InstanceName(),
+		Version:                 planned.Candidate.Version,
+		Revision:                planned.Candidate.Revision,
+		CurrentVersion:          planned.Current.Version,
+		CurrentRevision:         planned.Current.Revision,
+		Base:                    planned.Candidate.Base,
+		CurrentBase:             planned.Current.Base,
+		DownloadSize:            planned.DownloadSize,
+		DeltaSize:               planned.DeltaSize,
+		ServicesAdded:           planned.ServicesAdded,
+		ServicesRemoved:         planned.ServicesRemoved,
+		HooksAdded:              planned.HooksAdded,
+		HooksRemoved:            planned.HooksRemoved,
+		ContentProvidersAdded:   planned.ContentProvidersAdded,
+		ContentProvidersRemoved: planned.ContentProvidersRemoved,
+		HeldBy:                  planned.HeldBy,
+	}
+	for _, plug := range planned.PlugsAdded {
+		cand.PlugsAdded = append(cand.PlugsAdded, client.RefreshCandidateInterface{Name: plug.Name, Interface: plug.Interface})
+	}
+	for _, plug := range planned.PlugsRemoved {
+		cand.PlugsRemoved = append(cand.PlugsRemoved, client.RefreshCandidateInterface{Name: plug.Name, Interface: plug.Interface})
+	}
+	for _, slot := range planned.SlotsAdded {
+		cand.SlotsAdded = append(cand.SlotsAdded, client.RefreshCandidateInterface{Name: slot.Name, Interface: slot.Interface})
+	}
+	for _, slot := range planned.SlotsRemoved {
+		cand.SlotsRemoved = append(cand.SlotsRemoved, client.RefreshCandidateInterface{Name: slot.Name, Interface: slot.Interface})
+	}
+	return cand
+}
+
 func shouldSearchStore(r *http.Request) bool {
 	// we should jump to the old behaviour iff q is given, or if
 	// sources is given and either empty or contains the word
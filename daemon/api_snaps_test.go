@@ -166,6 +166,76 @@ func (s *snapsSuite) checkSnapsInfoIntegration(c *check.C, all bool, names []str
 	}
 }
 
+func (s *snapsSuite) TestSnapsInfoRefreshCandidates(c *check.C) {
+	s.daemon(c)
+
+	current := snaptest.MockInfo(c, "name: foo\nversion: 1\nbase: core20\n", &snap.SideInfo{Revision: snap.R(1)})
+	candidate := snaptest.MockInfo(c, "name: foo\nversion: 2\nbase: core22\n", &snap.SideInfo{Revision: snap.R(2)})
+	plug := &snap.PlugInfo{Name: "cam", Interface: "camera"}
+	slot := &snap.SlotInfo{Name: "foo-dbus", Interface: "dbus"}
+
+	restore := daemon.MockSnapstateRefreshPlan(func(st *state.State, user *auth.UserState) ([]*snapstate.PlannedRefresh, error) {
+		return []*snapstate.PlannedRefresh{{
+			Current:               current,
+			Candidate:             candidate,
+			DownloadSize:          10000,
+			DeltaSize:             1000,
+			PlugsAdded:            []*snap.PlugInfo{plug},
+			SlotsRemoved:          []*snap.SlotInfo{slot},
+			ServicesAdded:         []string{"daemon"},
+			HooksRemoved:          []string{"configure"},
+			ContentProvidersAdded: []string{"gtk-common-themes"},
+			HeldBy:                []string{"bar"},
+		}}, nil
+	})
+	defer restore()
+
+	req, err := http.NewRequest("GET", "/v2/snaps?select=refresh-candidates", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, []*client.RefreshCandidate{{
+		Name:                  "foo",
+		Version:               "2",
+		Revision:              snap.R(2),
+		CurrentVersion:        "1",
+		CurrentRevision:       snap.R(1),
+		Base:                  "core22",
+		CurrentBase:           "core20",
+		DownloadSize:          10000,
+		DeltaSize:             1000,
+		PlugsAdded:            []client.RefreshCandidateInterface{{Name: "cam", Interface: "camera"}},
+		SlotsRemoved:          []client.RefreshCandidateInterface{{Name: "foo-dbus", Interface: "dbus"}},
+		ServicesAdded:         []string{"daemon"},
+		HooksRemoved:          []string{"configure"},
+		ContentProvidersAdded: []string{"gtk-common-themes"},
+		HeldBy:                []string{"bar"},
+	}})
+}
+
+func (s *snapsSuite) TestSnapsInfoRefreshCandidatesError(c *check.C) {
+	s.daemon(c)
+
+	restore := daemon.MockSnapstateRefreshPlan(func(st *state.State, user *auth.UserState) ([]*snapstate.PlannedRefresh, error) {
+		return nil, errors.New("boom")
+	})
+	defer restore()
+
+	req, err := http.NewRequest("GET", "/v2/snaps?select=refresh-candidates", nil)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 500)
+	c.Check(rspe.Message, check.Equals, "cannot list refresh candidates: boom")
+
+	req, err = http.NewRequest("GET", "/v2/snaps?select=refresh-candidates&snaps=foo", nil)
+	c.Assert(err, check.IsNil)
+
+	rspe = s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "cannot use 'snaps' with 'select=refresh-candidates'")
+}
+
 func (s *snapsSuite) TestSnapsInfoOnlyLocal(c *check.C) {
 	d := s.daemon(c)
 
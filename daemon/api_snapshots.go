@@ -20,18 +20,25 @@
 package daemon
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"filippo.io/age"
+
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/strutil"
 )
@@ -51,14 +58,22 @@ var snapshotExportCmd = &Command{
 	ReadAccess: authenticatedAccess{},
 }
 
+var snapshotFilesCmd = &Command{
+	Path:       "/v2/snapshots/{id}/files",
+	GET:        listSnapshotFiles,
+	ReadAccess: authenticatedAccess{},
+}
+
 var (
-	snapshotList    = snapshotstate.List
-	snapshotCheck   = snapshotstate.Check
-	snapshotForget  = snapshotstate.Forget
-	snapshotRestore = snapshotstate.Restore
-	snapshotSave    = snapshotstate.Save
-	snapshotExport  = snapshotstate.Export
-	snapshotImport  = snapshotstate.Import
+	snapshotList         = snapshotstate.List
+	snapshotCheck        = snapshotstate.Check
+	snapshotForget       = snapshotstate.Forget
+	snapshotRestore      = snapshotstate.Restore
+	snapshotRestorePaths = snapshotstate.RestorePaths
+	snapshotSave         = snapshotstate.Save
+	snapshotExport       = snapshotstate.Export
+	snapshotImport       = snapshotstate.Import
+	snapshotFiles        = snapshotstate.Files
 )
 
 func listSnapshots(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -89,24 +104,29 @@ type snapshotAction struct {
 	Action string   `json:"action"`
 	Snaps  []string `json:"snaps,omitempty"`
 	Users  []string `json:"users,omitempty"`
+	Paths  []string `json:"paths,omitempty"`
 }
 
 func (action snapshotAction) String() string {
-	// verb of snapshot #N [for snaps %q] [for users %q]
+	// verb of snapshot #N [for snaps %q] [for users %q] [for paths %q]
 	var snaps string
 	var users string
+	var paths string
 	if len(action.Snaps) > 0 {
 		snaps = " for snaps " + strutil.Quoted(action.Snaps)
 	}
 	if len(action.Users) > 0 {
 		users = " for users " + strutil.Quoted(action.Users)
 	}
-	return fmt.Sprintf("%s of snapshot set #%d%s%s", strings.Title(action.Action), action.SetID, snaps, users)
+	if len(action.Paths) > 0 {
+		paths = " for paths " + strutil.Quoted(action.Paths)
+	}
+	return fmt.Sprintf("%s of snapshot set #%d%s%s%s", strings.Title(action.Action), action.SetID, snaps, users, paths)
 }
 
 func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response {
 	contentType := r.Header.Get("Content-Type")
-	if contentType == client.SnapshotExportMediaType {
+	if contentType == client.SnapshotExportMediaType || strings.HasPrefix(contentType, "multipart/") {
 		return doSnapshotImport(c, r, user)
 	}
 
@@ -127,6 +147,20 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 		return BadRequest("snapshot operation requires action")
 	}
 
+	if len(action.Paths) > 0 {
+		if action.Action != "restore" {
+			return BadRequest("snapshot %q operation cannot specify paths", action.Action)
+		}
+		if len(action.Snaps) != 1 {
+			return BadRequest("snapshot restore of paths requires exactly one snap")
+		}
+		for _, p := range action.Paths {
+			if err := backend.CheckRestorePath(p); err != nil {
+				return BadRequest("%v", err)
+			}
+		}
+	}
+
 	var affected []string
 	var ts *state.TaskSet
 	var err error
@@ -139,7 +173,11 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 	case "check":
 		affected, ts, err = snapshotCheck(st, action.SetID, action.Snaps, action.Users)
 	case "restore":
-		affected, ts, err = snapshotRestore(st, action.SetID, action.Snaps, action.Users)
+		if len(action.Paths) > 0 {
+			affected, ts, err = snapshotRestorePaths(st, action.SetID, action.Snaps[0], action.Users, action.Paths)
+		} else {
+			affected, ts, err = snapshotRestore(st, action.SetID, action.Snaps, action.Users)
+		}
 	case "forget":
 		if len(action.Users) != 0 {
 			return BadRequest(`snapshot "forget" operation cannot specify users`)
@@ -165,6 +203,111 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 	return AsyncResponse(nil, chg.ID())
 }
 
+// listSnapshotFiles lists the files in the data of a snap in a snapshot.
+func listSnapshotFiles(c *Command, r *http.Request, user *auth.UserState) Response {
+	sid := muxVars(r)["id"]
+	setID, err := strconv.ParseUint(sid, 10, 64)
+	if err != nil {
+		return BadRequest("'id' must be a positive base 10 number; got %q", sid)
+	}
+	query := r.URL.Query()
+	snapName := query.Get("snap")
+	if snapName == "" {
+		return BadRequest("listing the files of a snapshot requires a snap")
+	}
+
+	// the state is locked only as needed, reading the snapshot can take
+	// a while
+	st := c.d.overlord.State()
+	files, err := snapshotFiles(r.Context(), st, setID, snapName, strutil.CommaSeparatedList(query.Get("users")))
+	switch err {
+	case nil:
+		// woo
+	case client.ErrSnapshotSetNotFound, client.ErrSnapshotSnapsNotFound:
+		return NotFound("%v", err)
+	default:
+		return InternalError("%v", err)
+	}
+	if files == nil {
+		files = []client.SnapshotFile{}
+	}
+	return SyncResponse(files)
+}
+
+// snapshotRecipients returns the recipients a snapshot export is to be
+// encrypted to, if any. The passphrase comes in a header rather than in the
+// query so that it does not end up in logs.
+func snapshotRecipients(r *http.Request) ([]age.Recipient, error) {
+	var recipients []age.Recipient
+	for _, s := range r.URL.Query()["encrypt-to"] {
+		recipient, err := age.ParseX25519Recipient(s)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, recipient)
+	}
+	if passphrase := r.Header.Get(client.SnapshotPassphraseHeader); passphrase != "" {
+		if len(recipients) > 0 {
+			return nil, fmt.Errorf("cannot encrypt to both a passphrase and keys")
+		}
+		recipient, err := age.NewScryptRecipient(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// maxSnapshotImportFieldLen is the maximum size of the non-file parts of a
+// snapshot import form
+const maxSnapshotImportFieldLen = 64 * 1024
+
+// readSnapshotImportForm reads the identities an encrypted snapshot import
+// can be decrypted with from the "identity" and "passphrase" fields of a
+// multipart/form-data import, up to the "snapshot" file field holding the
+// export itself, which is returned to be read next.
+func readSnapshotImportForm(body io.Reader, boundary string) (export io.Reader, identities []age.Identity, err error) {
+	mr := multipart.NewReader(body, boundary)
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, nil, fmt.Errorf(`cannot find "snapshot" file field in provided multipart/form-data payload`)
+			}
+			return nil, nil, fmt.Errorf("cannot read POST form: %v", err)
+		}
+		name := part.FormName()
+		if name == "snapshot" {
+			return part, identities, nil
+		}
+
+		buf := &bytes.Buffer{}
+		// copy one byte more than the max so we know if it exceeds the limit
+		n, err := io.CopyN(buf, part, maxSnapshotImportFieldLen+1)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, fmt.Errorf("cannot read form data: %v", err)
+		}
+		if n > maxSnapshotImportFieldLen {
+			return nil, nil, fmt.Errorf("cannot read form data: %q exceeds size limit", name)
+		}
+
+		var identity age.Identity
+		switch name {
+		case "identity":
+			identity, err = age.ParseX25519Identity(buf.String())
+		case "passphrase":
+			identity, err = age.NewScryptIdentity(buf.String())
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		identities = append(identities, identity)
+	}
+}
+
 // getSnapshotExport streams an archive containing an export of existing snapshots.
 //
 // The snapshots are re-packaged into a single uncompressed tar archive and
@@ -180,16 +323,30 @@ func getSnapshotExport(c *Command, r *http.Request, user *auth.UserState) Respon
 	if err != nil {
 		return BadRequest("'id' must be a positive base 10 number; got %q", sid)
 	}
+	recipients, err := snapshotRecipients(r)
+	if err != nil {
+		return BadRequest("cannot encrypt exported snapshot %v: %v", setID, err)
+	}
 
 	export, err := snapshotExport(context.TODO(), st, setID)
 	if err != nil {
 		return BadRequest("cannot export %v: %v", setID, err)
 	}
-	// init (size calculation) can be slow so drop the lock
+	// encryption (key derivation) and init (size calculation) can be
+	// slow so drop the lock
 	st.Unlock()
+	if len(recipients) > 0 {
+		err = export.EncryptTo(recipients...)
+		if err != nil {
+			export.Close()
+			st.Lock()
+			return BadRequest("cannot encrypt exported snapshot %v: %v", setID, err)
+		}
+	}
 	err = export.Init()
 	st.Lock()
 	if err != nil {
+		export.Close()
 		return BadRequest("cannot calculate size of exported snapshot %v: %v", setID, err)
 	}
 
@@ -206,9 +363,28 @@ func doSnapshotImport(c *Command, r *http.Request, user *auth.UserState) Respons
 	// ensure we don't read more than we expect
 	limitedBodyReader := io.LimitReader(r.Body, expectedSize)
 
+	// the keys to decrypt encrypted imports come ahead of the export
+	// in a form, rather than in headers
+	var identities []age.Identity
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return BadRequest("cannot parse Content-Type: %v", err)
+	}
+	switch mediaType {
+	case client.SnapshotExportMediaType:
+		// plain export
+	case "multipart/form-data":
+		limitedBodyReader, identities, err = readSnapshotImportForm(limitedBodyReader, params["boundary"])
+		if err != nil {
+			return BadRequest("cannot decrypt snapshot import: %v", err)
+		}
+	default:
+		return BadRequest("unexpected snapshot import content type %q", mediaType)
+	}
+
 	// XXX: check that we have enough space to import the compressed snapshots
 	st := c.d.overlord.State()
-	setID, snapNames, err := snapshotImport(context.TODO(), st, limitedBodyReader)
+	setID, snapNames, err := snapshotImport(context.TODO(), st, limitedBodyReader, identities)
 	if err != nil {
 		return BadRequest(err.Error())
 	}
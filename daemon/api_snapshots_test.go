@@ -20,16 +20,21 @@
 package daemon_test
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"os"
 	"strconv"
 	"strings"
 
+	"filippo.io/age"
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/client"
@@ -158,6 +163,9 @@ func (s *snapshotSuite) TestFormatSnapshotAction(c *check.C) {
 		}, {
 			`{"set": 2, "action": "verb", "users": ["meep", "quux"], "snaps": ["foo", "bar"]}`,
 			`Verb of snapshot set #2 for snaps "foo", "bar" for users "meep", "quux"`,
+		}, {
+			`{"set": 2, "action": "verb", "snaps": ["foo"], "paths": ["x1/a", "common"]}`,
+			`Verb of snapshot set #2 for snaps "foo" for paths "x1/a", "common"`,
 		},
 	}
 
@@ -188,6 +196,18 @@ func (s *snapshotSuite) TestChangeSnapshots400(c *check.C) {
 		}, {
 			body:  `{"set": 42, "action": "forget", "users": ["foo"]}`,
 			error: `snapshot "forget" operation cannot specify users`,
+		}, {
+			body:  `{"set": 42, "action": "check", "snaps": ["foo"], "paths": ["x1/a"]}`,
+			error: `snapshot "check" operation cannot specify paths`,
+		}, {
+			body:  `{"set": 42, "action": "restore", "paths": ["x1/a"]}`,
+			error: `snapshot restore of paths requires exactly one snap`,
+		}, {
+			body:  `{"set": 42, "action": "restore", "snaps": ["foo", "bar"], "paths": ["x1/a"]}`,
+			error: `snapshot restore of paths requires exactly one snap`,
+		}, {
+			body:  `{"set": 42, "action": "restore", "snaps": ["foo"], "paths": ["/x1/a"]}`,
+			error: `invalid path to restore "/x1/a": must be a clean relative path, as listed in the snapshot`,
 		},
 	}
 
@@ -308,6 +328,83 @@ func (s *snapshotSuite) TestChangeSnapshot(c *check.C) {
 	}
 }
 
+func (s *snapshotSuite) TestChangeSnapshotRestorePaths(c *check.C) {
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+		c.Fatal("unexpected full restore")
+		return nil, nil, nil
+	})()
+	defer daemon.MockSnapshotRestorePaths(func(_ *state.State, setID uint64, snapName string, users, paths []string) ([]string, *state.TaskSet, error) {
+		c.Check(setID, check.Equals, uint64(42))
+		c.Check(snapName, check.Equals, "foo")
+		c.Check(users, check.DeepEquals, []string{"meep"})
+		c.Check(paths, check.DeepEquals, []string{"x1/a", "common"})
+		return []string{"foo"}, state.NewTaskSet(), nil
+	})()
+
+	body := `{"set": 42, "action": "restore", "snaps": ["foo"], "users": ["meep"], "paths": ["x1/a", "common"]}`
+	req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+
+	rsp := s.asyncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 202)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "restore-snapshot")
+	c.Check(chg.Summary(), check.Equals, `Restore of snapshot set #42 for snaps "foo" for users "meep" for paths "x1/a", "common"`)
+}
+
+func (s *snapshotSuite) TestListSnapshotFiles(c *check.C) {
+	files := []client.SnapshotFile{
+		{Path: "x1", Mode: os.ModeDir | 0755},
+		{User: "meep", Path: "x1/a", Mode: 0644, Size: 3},
+	}
+	defer daemon.MockSnapshotFiles(func(_ context.Context, _ *state.State, setID uint64, snapName string, users []string) ([]client.SnapshotFile, error) {
+		c.Check(setID, check.Equals, uint64(42))
+		c.Check(snapName, check.Equals, "foo")
+		c.Check(users, check.DeepEquals, []string{"meep", "quux"})
+		return files, nil
+	})()
+
+	req, err := http.NewRequest("GET", "/v2/snapshots/42/files?snap=foo&users=meep,quux", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, files)
+}
+
+func (s *snapshotSuite) TestListSnapshotFilesErrors(c *check.C) {
+	var filesErr error
+	defer daemon.MockSnapshotFiles(func(context.Context, *state.State, uint64, string, []string) ([]client.SnapshotFile, error) {
+		return nil, filesErr
+	})()
+
+	type table struct {
+		url    string
+		err    error
+		status int
+		msg    string
+	}
+	for _, t := range []table{
+		{"/v2/snapshots/xxx/files?snap=foo", nil, 400, `'id' must be a positive base 10 number; got "xxx"`},
+		{"/v2/snapshots/42/files", nil, 400, `listing the files of a snapshot requires a snap`},
+		{"/v2/snapshots/42/files?snap=foo", client.ErrSnapshotSetNotFound, 404, client.ErrSnapshotSetNotFound.Error()},
+		{"/v2/snapshots/42/files?snap=foo", client.ErrSnapshotSnapsNotFound, 404, client.ErrSnapshotSnapsNotFound.Error()},
+		{"/v2/snapshots/42/files?snap=foo", errors.New("bzzt"), 500, "bzzt"},
+	} {
+		comm := check.Commentf("%s: %v", t.url, t.err)
+		filesErr = t.err
+		req, err := http.NewRequest("GET", t.url, nil)
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, t.status, comm)
+		c.Check(rspe.Message, check.Equals, t.msg, comm)
+	}
+}
+
 func (s *snapshotSuite) TestExportSnapshots(c *check.C) {
 	var snapshotExportCalled int
 
@@ -325,6 +422,56 @@ func (s *snapshotSuite) TestExportSnapshots(c *check.C) {
 	c.Check(snapshotExportCalled, check.Equals, 1)
 }
 
+func (s *snapshotSuite) TestExportSnapshotsEncrypted(c *check.C) {
+	var export *snapshotstate.SnapshotExport
+	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64) (*snapshotstate.SnapshotExport, error) {
+		export = &snapshotstate.SnapshotExport{}
+		return export, nil
+	})()
+
+	id, err := age.GenerateX25519Identity()
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("GET", "/v2/snapshots/1/export?encrypt-to="+id.Recipient().String(), nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.req(c, req, nil)
+	c.Assert(rsp, check.FitsTypeOf, &daemon.SnapshotExportResponse{})
+
+	// the export is encrypted to the given key
+	rec := httptest.NewRecorder()
+	rsp.ServeHTTP(rec, req)
+	c.Check(rec.Header().Get("Content-Length"), check.Equals, strconv.Itoa(rec.Body.Len()))
+	dr, err := age.Decrypt(rec.Body, id)
+	c.Assert(err, check.IsNil)
+	tr := tar.NewReader(dr)
+	hdr, err := tr.Next()
+	c.Assert(err, check.IsNil)
+	c.Check(hdr.Name, check.Equals, "content.json")
+}
+
+func (s *snapshotSuite) TestExportSnapshotsEncryptedBadRequest(c *check.C) {
+	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64) (*snapshotstate.SnapshotExport, error) {
+		c.Fatal("unexpected export")
+		return nil, nil
+	})()
+
+	id, err := age.GenerateX25519Identity()
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/snapshots/1/export?encrypt-to=age1foo", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Matches, `cannot encrypt exported snapshot 1: malformed recipient "age1foo": .*`)
+
+	req, err = http.NewRequest("GET", "/v2/snapshots/1/export?encrypt-to="+id.Recipient().String(), nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Snapshot-Passphrase", "sekrit")
+	rspe = s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot encrypt exported snapshot 1: cannot encrypt to both a passphrase and keys`)
+}
+
 func (s *snapshotSuite) TestExportSnapshotsBadRequestOnNonNumericID(c *check.C) {
 	req, err := http.NewRequest("GET", "/v2/snapshots/xxx/export", nil)
 	c.Assert(err, check.IsNil)
@@ -356,7 +503,7 @@ func (s *snapshotSuite) TestImportSnapshot(c *check.C) {
 
 	setID := uint64(3)
 	snapNames := []string{"baz", "bar", "foo"}
-	defer daemon.MockSnapshotImport(func(context.Context, *state.State, io.Reader) (uint64, []string, error) {
+	defer daemon.MockSnapshotImport(func(context.Context, *state.State, io.Reader, []age.Identity) (uint64, []string, error) {
 		return setID, snapNames, nil
 	})()
 
@@ -370,8 +517,93 @@ func (s *snapshotSuite) TestImportSnapshot(c *check.C) {
 	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{"set-id": setID, "snaps": snapNames})
 }
 
+func (s *snapshotSuite) TestImportSnapshotEncrypted(c *check.C) {
+	id1, err := age.GenerateX25519Identity()
+	c.Assert(err, check.IsNil)
+	id2, err := age.GenerateX25519Identity()
+	c.Assert(err, check.IsNil)
+
+	var identities []age.Identity
+	var exported []byte
+	defer daemon.MockSnapshotImport(func(ctx context.Context, st *state.State, r io.Reader, ids []age.Identity) (uint64, []string, error) {
+		identities = ids
+		var err error
+		exported, err = ioutil.ReadAll(r)
+		c.Assert(err, check.IsNil)
+		return uint64(3), []string{"foo"}, nil
+	})()
+
+	data := []byte("mocked snapshot export data file")
+	req := snapshotImportFormRequest(c, data, [][2]string{
+		{"identity", id1.String()},
+		{"identity", id2.String()},
+		{"passphrase", "sekrit"},
+	})
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Assert(identities, check.HasLen, 3)
+	c.Check(identities[0].(*age.X25519Identity).String(), check.Equals, id1.String())
+	c.Check(identities[1].(*age.X25519Identity).String(), check.Equals, id2.String())
+	c.Check(identities[2], check.FitsTypeOf, &age.ScryptIdentity{})
+	c.Check(exported, check.DeepEquals, data)
+
+	req = snapshotImportFormRequest(c, data, [][2]string{{"identity", "AGE-SECRET-KEY-1FOO"}})
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Matches, "cannot decrypt snapshot import: malformed secret key: .*")
+}
+
+// snapshotImportFormRequest returns an import request of the export data
+// with the given form fields ahead of it.
+func snapshotImportFormRequest(c *check.C, data []byte, fields [][2]string) *http.Request {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for _, field := range fields {
+		c.Assert(mw.WriteField(field[0], field[1]), check.IsNil)
+	}
+	if data != nil {
+		w, err := mw.CreateFormFile("snapshot", "snapshot")
+		c.Assert(err, check.IsNil)
+		_, err = w.Write(data)
+		c.Assert(err, check.IsNil)
+	}
+	c.Assert(mw.Close(), check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/snapshots", body)
+	c.Assert(err, check.IsNil)
+	req.Header.Add("Content-Length", strconv.Itoa(body.Len()))
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func (s *snapshotSuite) TestImportSnapshotFormUnhappy(c *check.C) {
+	defer daemon.MockSnapshotImport(func(context.Context, *state.State, io.Reader, []age.Identity) (uint64, []string, error) {
+		c.Fatal("unexpected import")
+		return 0, nil, nil
+	})()
+
+	req := snapshotImportFormRequest(c, nil, [][2]string{{"passphrase", "sekrit"}})
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot decrypt snapshot import: cannot find "snapshot" file field in provided multipart/form-data payload`)
+
+	req = snapshotImportFormRequest(c, []byte("data"), [][2]string{{"passphrase", strings.Repeat("x", 64*1024+1)}})
+	rspe = s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot decrypt snapshot import: cannot read form data: "passphrase" exceeds size limit`)
+
+	req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader("data"))
+	c.Assert(err, check.IsNil)
+	req.Header.Add("Content-Length", "4")
+	req.Header.Set("Content-Type", "multipart/mixed; boundary=foo")
+	rspe = s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `unexpected snapshot import content type "multipart/mixed"`)
+}
+
 func (s *snapshotSuite) TestImportSnapshotError(c *check.C) {
-	defer daemon.MockSnapshotImport(func(context.Context, *state.State, io.Reader) (uint64, []string, error) {
+	defer daemon.MockSnapshotImport(func(context.Context, *state.State, io.Reader, []age.Identity) (uint64, []string, error) {
 		return uint64(0), nil, errors.New("no")
 	})()
 
@@ -400,7 +632,7 @@ func (s *snapshotSuite) TestImportSnapshotNoContentLengthError(c *check.C) {
 func (s *snapshotSuite) TestImportSnapshotLimits(c *check.C) {
 	var dataRead int
 
-	defer daemon.MockSnapshotImport(func(ctx context.Context, st *state.State, r io.Reader) (uint64, []string, error) {
+	defer daemon.MockSnapshotImport(func(ctx context.Context, st *state.State, r io.Reader, identities []age.Identity) (uint64, []string, error) {
 		data, err := ioutil.ReadAll(r)
 		c.Assert(err, check.IsNil)
 		dataRead = len(data)
@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"io"
 
+	"filippo.io/age"
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/client"
@@ -71,6 +72,14 @@ func MockSnapshotRestore(newRestore func(*state.State, uint64, []string, []strin
 	}
 }
 
+func MockSnapshotRestorePaths(newRestorePaths func(*state.State, uint64, string, []string, []string) ([]string, *state.TaskSet, error)) (restore func()) {
+	oldRestorePaths := snapshotRestorePaths
+	snapshotRestorePaths = newRestorePaths
+	return func() {
+		snapshotRestorePaths = oldRestorePaths
+	}
+}
+
 func MockSnapshotForget(newForget func(*state.State, uint64, []string) ([]string, *state.TaskSet, error)) (restore func()) {
 	oldForget := snapshotForget
 	snapshotForget = newForget
@@ -79,7 +88,7 @@ func MockSnapshotForget(newForget func(*state.State, uint64, []string) ([]string
 	}
 }
 
-func MockSnapshotImport(newImport func(context.Context, *state.State, io.Reader) (uint64, []string, error)) (restore func()) {
+func MockSnapshotImport(newImport func(context.Context, *state.State, io.Reader, []age.Identity) (uint64, []string, error)) (restore func()) {
 	oldImport := snapshotImport
 	snapshotImport = newImport
 	return func() {
@@ -87,6 +96,14 @@ func MockSnapshotImport(newImport func(context.Context, *state.State, io.Reader)
 	}
 }
 
+func MockSnapshotFiles(newFiles func(context.Context, *state.State, uint64, string, []string) ([]client.SnapshotFile, error)) (restore func()) {
+	oldFiles := snapshotFiles
+	snapshotFiles = newFiles
+	return func() {
+		snapshotFiles = oldFiles
+	}
+}
+
 func MustUnmarshalSnapInstruction(c *check.C, jinst string) *snapInstruction {
 	var inst snapInstruction
 	if err := json.Unmarshal([]byte(jinst), &inst); err != nil {
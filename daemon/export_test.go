@@ -30,6 +30,7 @@ import (
 	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/restart"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -190,6 +191,14 @@ func MockSnapstateInstallMany(mock func(*state.State, []string, int, *snapstate.
 	}
 }
 
+func MockSnapstateRefreshPlan(mock func(*state.State, *auth.UserState) ([]*snapstate.PlannedRefresh, error)) (restore func()) {
+	oldSnapstateRefreshPlan := snapstateRefreshPlan
+	snapstateRefreshPlan = mock
+	return func() {
+		snapstateRefreshPlan = oldSnapstateRefreshPlan
+	}
+}
+
 func MockSnapstateUpdateMany(mock func(context.Context, *state.State, []string, int, *snapstate.Flags) ([]string, []*state.TaskSet, error)) (restore func()) {
 	oldSnapstateUpdateMany := snapstateUpdateMany
 	snapstateUpdateMany = mock
@@ -49,6 +49,8 @@ var (
 	SnapConfineAppArmorDir string
 	SnapSeccompBase        string
 	SnapSeccompDir         string
+	SnapLandlockDir        string
+	SnapNftablesDir        string
 	SnapMountPolicyDir     string
 	SnapUdevRulesDir       string
 	SnapKModModulesDir     string
@@ -135,6 +137,8 @@ var (
 
 	SnapshotsDir string
 
+	SnapChangesArchiveDir string
+
 	ErrtrackerDbDir string
 	SysfsDir        string
 
@@ -364,6 +368,8 @@ func SetRootDir(rootdir string) {
 	SnapDownloadCacheDir = filepath.Join(rootdir, snappyDir, "cache")
 	SnapSeccompBase = filepath.Join(rootdir, snappyDir, "seccomp")
 	SnapSeccompDir = filepath.Join(SnapSeccompBase, "bpf")
+	SnapLandlockDir = filepath.Join(rootdir, snappyDir, "landlock")
+	SnapNftablesDir = filepath.Join(rootdir, snappyDir, "nftables")
 	SnapMountPolicyDir = filepath.Join(rootdir, snappyDir, "mount")
 	SnapdMaintenanceFile = filepath.Join(rootdir, snappyDir, "maintenance.json")
 	SnapBlobDir = SnapBlobDirUnder(rootdir)
@@ -503,6 +509,8 @@ func SetRootDir(rootdir string) {
 
 	SnapshotsDir = filepath.Join(rootdir, snappyDir, "snapshots")
 
+	SnapChangesArchiveDir = filepath.Join(rootdir, snappyDir, "changes-archive")
+
 	ErrtrackerDbDir = filepath.Join(rootdir, snappyDir, "errtracker.db")
 	SysfsDir = filepath.Join(rootdir, "/sys")
 
@@ -67,6 +67,12 @@ const (
 	// QuotaGroups enable creating resource quota groups for snaps via the rest API and cli.
 	QuotaGroups
 
+	// StateJournal enables persisting changes to the snapd state as deltas appended to a journal.
+	StateJournal
+
+	// LandlockConfinement enables strict confinement of the filesystem access of snaps with Landlock on systems without AppArmor.
+	LandlockConfinement
+
 	// lastFeature is the final known feature, it is only used for testing.
 	lastFeature
 )
@@ -106,6 +112,10 @@ var featureNames = map[SnapdFeature]string{
 	GateAutoRefreshHook: "gate-auto-refresh-hook",
 
 	QuotaGroups: "quota-groups",
+
+	StateJournal: "state-journal",
+
+	LandlockConfinement: "landlock-confinement",
 }
 
 // featuresEnabledWhenUnset contains a set of features that are enabled when not explicitly configured.
@@ -127,6 +137,12 @@ var featuresExported = map[SnapdFeature]bool{
 	RobustMountNamespaceUpdates:   true,
 	HiddenSnapDataHomeDir:         true,
 	MoveSnapHomeDir:               true,
+
+	// StateJournal needs to be known before the state is read
+	StateJournal: true,
+
+	// LandlockConfinement is checked when snapd sets up the security backends
+	LandlockConfinement: true,
 }
 
 // String returns the name of a snapd feature.
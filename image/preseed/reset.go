@@ -98,6 +98,7 @@ func ResetPreseededChroot(preseedChroot string) error {
 	// globs that yield individual files
 	globs := []string{
 		dirs.SnapStateFile,
+		dirs.SnapStateFile + ".journal*",
 		dirs.SnapSystemKeyFile,
 		filepath.Join(dirs.SnapBlobDir, "*.snap"),
 		filepath.Join(dirs.SnapUdevRulesDir, "*-snap.*.rules"),
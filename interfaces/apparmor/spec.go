@@ -487,6 +487,17 @@ func (spec *Specification) Snippets() map[string][]string {
 	return snippets
 }
 
+// InspectSnippets returns a deep copy of all the added application
+// snippets, together with the snap-update-ns snippets that apply to the
+// whole snap, for inspection.
+func (spec *Specification) InspectSnippets() map[string][]string {
+	snippets := spec.Snippets()
+	if updateNS := spec.UpdateNS(); len(updateNS) > 0 {
+		snippets[""] = updateNS
+	}
+	return snippets
+}
+
 // SnippetForTag returns a combined snippet for given security tag with
 // individual snippets joined with the newline character. Empty string is
 // returned for non-existing security tag.
@@ -554,3 +554,18 @@ func (s *specSuite) TestSetSuppressHomeIx(c *C) {
 	s.spec.SetSuppressHomeIx()
 	c.Assert(s.spec.SuppressHomeIx(), Equals, true)
 }
+
+func (s *specSuite) TestInspectSnippets(c *C) {
+	var r interfaces.InspectableSpecification = s.spec
+	c.Check(r.InspectSnippets(), HasLen, 0)
+
+	restore := apparmor.SetSpecScope(s.spec, []string{"snap.demo.command"})
+	defer restore()
+	s.spec.AddSnippet("snippet 1")
+	s.spec.AddUpdateNS("update-ns snippet")
+
+	c.Check(r.InspectSnippets(), DeepEquals, map[string][]string{
+		"snap.demo.command": {"snippet 1"},
+		"":                  {"update-ns snippet"},
+	})
+}
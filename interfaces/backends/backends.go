@@ -26,12 +26,15 @@ import (
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/dbus"
 	"github.com/snapcore/snapd/interfaces/kmod"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/interfaces/mount"
+	"github.com/snapcore/snapd/interfaces/nftables"
 	"github.com/snapcore/snapd/interfaces/polkit"
 	"github.com/snapcore/snapd/interfaces/seccomp"
 	"github.com/snapcore/snapd/interfaces/systemd"
 	"github.com/snapcore/snapd/interfaces/udev"
 	apparmor_sandbox "github.com/snapcore/snapd/sandbox/apparmor"
+	landlock_sandbox "github.com/snapcore/snapd/sandbox/landlock"
 )
 
 var All []interfaces.SecurityBackend = backends()
@@ -49,6 +52,19 @@ func backends() []interfaces.SecurityBackend {
 		&polkit.Backend{},
 	}
 
+	// Enable the landlock backend if the kernel supports landlock. It only
+	// writes profiles when snaps are confined with landlock instead of
+	// AppArmor, see sandbox.LandlockConfinement.
+	if landlock_sandbox.ABIVersion() > 0 {
+		all = append(all, &landlock.Backend{})
+	}
+
+	// Enable the nftables backend if the egress of snap services can be
+	// filtered, see nftables.Supported.
+	if nftables.Supported() {
+		all = append(all, &nftables.Backend{})
+	}
+
 	// TODO use something like:
 	// level, summary := apparmor.ProbeResults()
 
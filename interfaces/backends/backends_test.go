@@ -23,7 +23,9 @@ import (
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/interfaces/backends"
+	"github.com/snapcore/snapd/interfaces/nftables"
 	apparmor_sandbox "github.com/snapcore/snapd/sandbox/apparmor"
+	landlock_sandbox "github.com/snapcore/snapd/sandbox/landlock"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -35,16 +37,21 @@ type backendsSuite struct{}
 
 var _ = Suite(&backendsSuite{})
 
+func backendNames() []string {
+	all := backends.Backends()
+	names := make([]string, len(all))
+	for i, backend := range all {
+		names[i] = string(backend.Name())
+	}
+	return names
+}
+
 func (s *backendsSuite) TestIsAppArmorEnabled(c *C) {
 	for _, level := range []apparmor_sandbox.LevelType{apparmor_sandbox.Unsupported, apparmor_sandbox.Unusable, apparmor_sandbox.Partial, apparmor_sandbox.Full} {
 		restore := apparmor_sandbox.MockLevel(level)
 		defer restore()
 
-		all := backends.Backends()
-		names := make([]string, len(all))
-		for i, backend := range all {
-			names[i] = string(backend.Name())
-		}
+		names := backendNames()
 		switch level {
 		case apparmor_sandbox.Unsupported, apparmor_sandbox.Unusable:
 			c.Assert(names, Not(testutil.Contains), "apparmor")
@@ -55,6 +62,34 @@ func (s *backendsSuite) TestIsAppArmorEnabled(c *C) {
 	}
 }
 
+func (s *backendsSuite) TestIsLandlockEnabled(c *C) {
+	for _, abi := range []int{0, 1, 3} {
+		restore := landlock_sandbox.MockABIVersion(abi)
+		defer restore()
+
+		names := backendNames()
+		if abi == 0 {
+			c.Check(names, Not(testutil.Contains), "landlock")
+		} else {
+			c.Check(names, testutil.Contains, "landlock")
+		}
+	}
+}
+
+func (s *backendsSuite) TestIsNftablesEnabled(c *C) {
+	for _, supported := range []bool{false, true} {
+		restore := nftables.MockSupported(supported)
+		defer restore()
+
+		names := backendNames()
+		if supported {
+			c.Check(names, testutil.Contains, "nftables")
+		} else {
+			c.Check(names, Not(testutil.Contains), "nftables")
+		}
+	}
+}
+
 func (s *backendsSuite) TestEssentialOrdering(c *C) {
 	restore := apparmor_sandbox.MockLevel(apparmor_sandbox.Full)
 	defer restore()
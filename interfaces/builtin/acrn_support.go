@@ -45,6 +45,10 @@ var acrnSupportConnectedPlugUDev = []string{
 	`KERNEL=="acrn_hsm"`,
 }
 
+const acrnSupportConnectedPlugLandlock = `
+write /dev/acrn_hsm
+`
+
 func init() {
 	registerIface(&acrnSupportInterface{commonInterface{
 		name:                  "acrn-support",
@@ -52,6 +56,7 @@ func init() {
 		implicitOnCore:        true,
 		implicitOnClassic:     true,
 		connectedPlugUDev:     acrnSupportConnectedPlugUDev,
+		connectedPlugLandlock: acrnSupportConnectedPlugLandlock,
 		baseDeclarationSlots:  acrnSupportBaseDeclarationSlots,
 		connectedPlugAppArmor: acrnSupportConnectedPlugAppArmor,
 	}})
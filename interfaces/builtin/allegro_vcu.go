@@ -51,6 +51,12 @@ var allegroVcuConnectedPlugUDev = []string{
 	`SUBSYSTEM=="char", KERNEL=="dmaproxy"`,
 }
 
+const allegroVcuConnectedPlugLandlock = `
+write /dev/allegroIP
+write /dev/allegroDecodeIP
+write /dev/dmaproxy
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "allegro-vcu",
@@ -60,5 +66,6 @@ func init() {
 		baseDeclarationSlots:  allegroVcuBaseDeclarationSlots,
 		connectedPlugAppArmor: allegroVcuConnectedPlugAppArmor,
 		connectedPlugUDev:     allegroVcuConnectedPlugUDev,
+		connectedPlugLandlock: allegroVcuConnectedPlugLandlock,
 	})
 }
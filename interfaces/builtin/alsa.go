@@ -56,6 +56,10 @@ var alsaConnectedPlugUDev = []string{
 	`SUBSYSTEM=="sound", KERNEL=="card[0-9]*"`,
 }
 
+const alsaConnectedPlugLandlock = `
+write /dev/snd
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "alsa",
@@ -65,5 +69,6 @@ func init() {
 		baseDeclarationSlots:  alsaBaseDeclarationSlots,
 		connectedPlugAppArmor: alsaConnectedPlugAppArmor,
 		connectedPlugUDev:     alsaConnectedPlugUDev,
+		connectedPlugLandlock: alsaConnectedPlugLandlock,
 	})
 }
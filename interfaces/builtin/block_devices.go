@@ -19,6 +19,18 @@
 
 package builtin
 
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+)
+
 // Only allow raw disk devices; not loop, ram, CDROM, generic SCSI, network,
 // tape, raid, etc devices or disk partitions. For some devices, allow controller
 // character devices since they are used to configure the corresponding block
@@ -104,6 +116,28 @@ capability sys_admin,
 /{,usr/}sbin/blkid ixr,
 `
 
+// Slots created by hotplug grant access to a single USB disk only.
+const blockDevicesDiskConnectedPlugAppArmor = `
+# Description: Allow write access to a single raw disk block device.
+
+@{PROC}/devices r,
+/run/udev/data/b[0-9]*:[0-9]* r,
+/sys/block/ r,
+/sys/devices/**/block/** r,
+/sys/dev/block/ r,
+
+%s rwk,
+
+# SCSI device commands, et al
+capability sys_rawio,
+
+# Perform various privileged block-device ioctl operations
+capability sys_admin,
+
+# Allow to use blkid to export key=value pairs such as UUID to get block device attributes
+/{,usr/}sbin/blkid ixr,
+`
+
 var blockDevicesConnectedPlugUDev = []string{
 	`SUBSYSTEM=="block"`,
 	// these additional subsystems may not directly be block devices but they
@@ -114,10 +148,84 @@ var blockDevicesConnectedPlugUDev = []string{
 	`KERNEL=="megaraid_sas_ioctl_node"`,
 }
 
+const blockDevicesConnectedPlugLandlock = `
+write /dev/hd[a-t]
+write /dev/sd[a-z]
+write /dev/sd[a-h][a-z]
+write /dev/sdi[a-v]
+write /dev/i2o/hd[a-z]
+write /dev/i2o/hd[a-c][a-z]
+write /dev/i2o/hdd[a-x]
+write /dev/mmcblk[0-9]
+write /dev/mmcblk[0-9][0-9]
+write /dev/mmcblk[0-9][0-9][0-9]
+write /dev/vd[a-z]
+write /dev/nvme[0-9]
+write /dev/nvme[1-9][0-9]
+write /dev/nvme[0-9]n[1-9]
+write /dev/nvme[0-9]n[1-6][0-9]
+write /dev/nvme[1-9][0-9]n[1-9]
+write /dev/nvme[1-9][0-9]n[1-6][0-9]
+write /dev/mpt2ctl
+write /dev/mpt2ctl_wd
+write /dev/megaraid_sas_ioctl_node
+`
+
+// Pattern to match the USB disks, for which slots are created by hotplug.
+var usbDiskDeviceNodePattern = regexp.MustCompile("^/dev/sd([a-h]?[a-z]|i[a-v])$")
+
+// usbDiskDetected returns a proposed slot for USB disks, the partitions of
+// the disks are not considered.
+func usbDiskDetected(di *hotplug.HotplugDeviceInfo) *hotplug.ProposedSlot {
+	bus, _ := di.Attribute("ID_BUS")
+	if di.Subsystem() != "block" || di.DeviceType() != "disk" || bus != "usb" || !usbDiskDeviceNodePattern.MatchString(di.DeviceName()) {
+		return nil
+	}
+	return &hotplug.ProposedSlot{
+		Attrs: map[string]interface{}{
+			"path": di.DeviceName(),
+		},
+	}
+}
+
 type blockDevicesInterface struct {
 	commonInterface
 }
 
+// BeforePrepareSlot checks the path attribute of slots created by hotplug.
+func (iface *blockDevicesInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
+	_, err := hotplugSlotDevicePath(&interfaces.SlotRef{Snap: slot.Snap.InstanceName(), Name: slot.Name}, slot, usbDiskDeviceNodePattern)
+	return err
+}
+
+func (iface *blockDevicesInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	path, err := hotplugSlotDevicePath(slot.Ref(), slot, usbDiskDeviceNodePattern)
+	if err != nil {
+		return nil
+	}
+	if path == "" {
+		return iface.commonInterface.AppArmorConnectedPlug(spec, plug, slot)
+	}
+	spec.AddSnippet(fmt.Sprintf(blockDevicesDiskConnectedPlugAppArmor, path))
+	return nil
+}
+
+func (iface *blockDevicesInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	path, err := hotplugSlotDevicePath(slot.Ref(), slot, usbDiskDeviceNodePattern)
+	if err != nil {
+		return nil
+	}
+	if path == "" {
+		return iface.commonInterface.UDevConnectedPlug(spec, plug, slot)
+	}
+	spec.TagDevice(fmt.Sprintf(`SUBSYSTEM=="block", KERNEL=="%s"`, strings.TrimPrefix(path, "/dev/")))
+	return nil
+}
+
+func (iface *blockDevicesInterface) HotplugDeviceDetected(di *hotplug.HotplugDeviceInfo) (*hotplug.ProposedSlot, error) {
+	return usbDiskDetected(di), nil
+}
+
 func init() {
 	registerIface(&blockDevicesInterface{commonInterface{
 		name:                  "block-devices",
@@ -128,5 +236,6 @@ func init() {
 		baseDeclarationSlots:  blockDevicesBaseDeclarationSlots,
 		connectedPlugAppArmor: blockDevicesConnectedPlugAppArmor,
 		connectedPlugUDev:     blockDevicesConnectedPlugUDev,
+		connectedPlugLandlock: blockDevicesConnectedPlugLandlock,
 	}})
 }
@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
@@ -112,3 +113,59 @@ func (s *blockDevicesInterfaceSuite) TestAutoConnect(c *C) {
 func (s *blockDevicesInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
+
+const blockDevicesHotplugCoreYaml = `name: core
+version: 0
+type: os
+slots:
+  usb-stick:
+    interface: block-devices
+    path: /dev/sdb
+  partition:
+    interface: block-devices
+    path: /dev/sdb1
+`
+
+func (s *blockDevicesInterfaceSuite) TestSanitizeHotplugSlot(c *C) {
+	slot := MockSlot(c, blockDevicesHotplugCoreYaml, nil, "usb-stick")
+	c.Check(interfaces.BeforePrepareSlot(s.iface, slot), IsNil)
+	slot = MockSlot(c, blockDevicesHotplugCoreYaml, nil, "partition")
+	c.Check(interfaces.BeforePrepareSlot(s.iface, slot), ErrorMatches, `slot "core:partition" path attribute must be a valid device node`)
+}
+
+func (s *blockDevicesInterfaceSuite) TestHotplugSlotSpecs(c *C) {
+	slot, _ := MockConnectedSlot(c, blockDevicesHotplugCoreYaml, nil, "usb-stick")
+
+	apparmorSpec := &apparmor.Specification{}
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Check(apparmorSpec.SnippetForTag("snap.consumer.app"), testutil.Contains, "/dev/sdb rwk,")
+	c.Check(apparmorSpec.SnippetForTag("snap.consumer.app"), Not(testutil.Contains), `/dev/sd{,[a-h]}[a-z] rwk,`)
+
+	udevSpec := &udev.Specification{}
+	c.Assert(udevSpec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Assert(udevSpec.Snippets(), HasLen, 2)
+	c.Check(udevSpec.Snippets(), testutil.Contains, `# block-devices
+SUBSYSTEM=="block", KERNEL=="sdb", TAG+="snap_consumer_app"`)
+}
+
+func (s *blockDevicesInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/sdb", "DEVTYPE": "disk", "ID_BUS": "usb", "ACTION": "add", "SUBSYSTEM": "block"})
+	c.Assert(err, IsNil)
+	proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Check(proposedSlot, DeepEquals, &hotplug.ProposedSlot{Attrs: map[string]interface{}{"path": "/dev/sdb"}})
+
+	for _, attrs := range []map[string]string{
+		// partitions
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/sdb1", "DEVTYPE": "partition", "ID_BUS": "usb", "ACTION": "add", "SUBSYSTEM": "block"},
+		// internal disks
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/sda", "DEVTYPE": "disk", "ID_BUS": "ata", "ACTION": "add", "SUBSYSTEM": "block"},
+	} {
+		di, err := hotplug.NewHotplugDeviceInfo(attrs)
+		c.Assert(err, IsNil)
+		proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+		c.Assert(err, IsNil)
+		c.Check(proposedSlot, IsNil)
+	}
+}
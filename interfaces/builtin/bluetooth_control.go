@@ -63,6 +63,11 @@ bind
 
 var bluetoothControlConnectedPlugUDev = []string{`SUBSYSTEM=="bluetooth"`, `SUBSYSTEM=="BT_chrdev"`}
 
+const bluetoothControlConnectedPlugLandlock = `
+write /dev/vhci
+write /dev/stpbt
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "bluetooth-control",
@@ -73,5 +78,6 @@ func init() {
 		connectedPlugAppArmor: bluetoothControlConnectedPlugAppArmor,
 		connectedPlugSecComp:  bluetoothControlConnectedPlugSecComp,
 		connectedPlugUDev:     bluetoothControlConnectedPlugUDev,
+		connectedPlugLandlock: bluetoothControlConnectedPlugLandlock,
 	})
 }
@@ -54,6 +54,12 @@ var broadcomAsicControlConnectedPlugUDev = []string{
 	`SUBSYSTEM=="net", KERNEL=="bcm[0-9]*"`,
 }
 
+const broadcomAsicControlConnectedPlugLandlock = `
+write /dev/linux-user-bde
+write /dev/linux-kernel-bde
+write /dev/linux-bcm-knet
+`
+
 // The upstream linux kernel doesn't come with support for the
 // necessary kernel modules we need to drive a Broadcom ASIC.
 // All necessary modules need to be loaded on demand if the
@@ -74,5 +80,6 @@ func init() {
 		connectedPlugAppArmor:    broadcomAsicControlConnectedPlugAppArmor,
 		connectedPlugKModModules: broadcomAsicControlConnectedPlugKMod,
 		connectedPlugUDev:        broadcomAsicControlConnectedPlugUDev,
+		connectedPlugLandlock:    broadcomAsicControlConnectedPlugLandlock,
 	})
 }
@@ -19,6 +19,18 @@
 
 package builtin
 
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+)
+
 const cameraSummary = `allows access to all cameras`
 
 const cameraBaseDeclarationSlots = `
@@ -35,7 +47,16 @@ const cameraConnectedPlugAppArmor = `
 
 # VideoCore cameras (shared device with VideoCore/EGL)
 /dev/vchiq rw,
+` + cameraDetectionConnectedPlugAppArmor
+
+// Slots created by hotplug grant access to the device node of a single
+// camera only.
+const cameraDeviceConnectedPlugAppArmor = `
+# Description: Allow access to a single camera
+%s rw,
+` + cameraDetectionConnectedPlugAppArmor
 
+const cameraDetectionConnectedPlugAppArmor = `
 # Allow detection of cameras. Leaks plugged in USB device info
 /sys/bus/usb/devices/ r,
 /sys/devices/pci**/usb*/**/busnum r,
@@ -57,8 +78,72 @@ var cameraConnectedPlugUDev = []string{
 	`KERNEL=="vchiq"`,
 }
 
+const cameraConnectedPlugLandlock = `
+write /dev/video[0-9]*
+write /dev/vchiq
+`
+
+// Pattern to match the video capture device nodes for which slots are
+// created by hotplug.
+var cameraDeviceNodePattern = regexp.MustCompile("^/dev/video[0-9]{1,3}$")
+
+type cameraInterface struct {
+	commonInterface
+}
+
+// BeforePrepareSlot checks the path attribute of slots created by hotplug.
+func (iface *cameraInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
+	_, err := hotplugSlotDevicePath(&interfaces.SlotRef{Snap: slot.Snap.InstanceName(), Name: slot.Name}, slot, cameraDeviceNodePattern)
+	return err
+}
+
+func (iface *cameraInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	path, err := hotplugSlotDevicePath(slot.Ref(), slot, cameraDeviceNodePattern)
+	if err != nil {
+		return nil
+	}
+	if path == "" {
+		return iface.commonInterface.AppArmorConnectedPlug(spec, plug, slot)
+	}
+	spec.AddSnippet(fmt.Sprintf(cameraDeviceConnectedPlugAppArmor, path))
+	return nil
+}
+
+func (iface *cameraInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	path, err := hotplugSlotDevicePath(slot.Ref(), slot, cameraDeviceNodePattern)
+	if err != nil {
+		return nil
+	}
+	if path == "" {
+		return iface.commonInterface.UDevConnectedPlug(spec, plug, slot)
+	}
+	spec.TagDevice(fmt.Sprintf(`SUBSYSTEM=="video4linux", KERNEL=="%s"`, strings.TrimPrefix(path, "/dev/")))
+	return nil
+}
+
+func (iface *cameraInterface) HotplugDeviceDetected(di *hotplug.HotplugDeviceInfo) (*hotplug.ProposedSlot, error) {
+	if di.Subsystem() != "video4linux" || !cameraDeviceNodePattern.MatchString(di.DeviceName()) {
+		return nil, nil
+	}
+	// cameras usually come with additional nodes for metadata, only the
+	// ones capturing video get a slot
+	caps, _ := di.Attribute("ID_V4L_CAPABILITIES")
+	if !strings.Contains(caps, ":capture:") {
+		return nil, nil
+	}
+	return &hotplug.ProposedSlot{
+		Attrs: map[string]interface{}{
+			"path": di.DeviceName(),
+		},
+	}, nil
+}
+
+func (iface *cameraInterface) HotplugKey(di *hotplug.HotplugDeviceInfo) (snap.HotplugKey, error) {
+	return usbInterfaceHotplugKey(di)
+}
+
 func init() {
-	registerIface(&commonInterface{
+	registerIface(&cameraInterface{commonInterface{
 		name:                  "camera",
 		summary:               cameraSummary,
 		implicitOnCore:        true,
@@ -66,5 +151,6 @@ func init() {
 		baseDeclarationSlots:  cameraBaseDeclarationSlots,
 		connectedPlugAppArmor: cameraConnectedPlugAppArmor,
 		connectedPlugUDev:     cameraConnectedPlugUDev,
-	})
+		connectedPlugLandlock: cameraConnectedPlugLandlock,
+	}})
 }
@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
@@ -109,3 +110,89 @@ func (s *CameraInterfaceSuite) TestAutoConnect(c *C) {
 func (s *CameraInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
+
+const cameraHotplugCoreYaml = `name: core
+version: 0
+type: os
+slots:
+  hd-webcam:
+    interface: camera
+    path: /dev/video2
+  bad-path:
+    interface: camera
+    path: /dev/sda
+`
+
+func (s *CameraInterfaceSuite) TestSanitizeHotplugSlot(c *C) {
+	slot := MockSlot(c, cameraHotplugCoreYaml, nil, "hd-webcam")
+	c.Check(interfaces.BeforePrepareSlot(s.iface, slot), IsNil)
+	slot = MockSlot(c, cameraHotplugCoreYaml, nil, "bad-path")
+	c.Check(interfaces.BeforePrepareSlot(s.iface, slot), ErrorMatches, `slot "core:bad-path" path attribute must be a valid device node`)
+}
+
+func (s *CameraInterfaceSuite) TestAppArmorSpecHotplugSlot(c *C) {
+	slot, _ := MockConnectedSlot(c, cameraHotplugCoreYaml, nil, "hd-webcam")
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "/dev/video2 rw,")
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "/sys/class/video4linux/ r,")
+	c.Check(spec.SnippetForTag("snap.consumer.app"), Not(testutil.Contains), "/dev/video[0-9]* rw")
+}
+
+func (s *CameraInterfaceSuite) TestUDevSpecHotplugSlot(c *C) {
+	slot, _ := MockConnectedSlot(c, cameraHotplugCoreYaml, nil, "hd-webcam")
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 2)
+	c.Check(spec.Snippets(), testutil.Contains, `# camera
+SUBSYSTEM=="video4linux", KERNEL=="video2", TAG+="snap_consumer_app"`)
+}
+
+func (s *CameraInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/video2", "ID_V4L_CAPABILITIES": ":capture:", "ACTION": "add", "SUBSYSTEM": "video4linux"})
+	c.Assert(err, IsNil)
+	proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Check(proposedSlot, DeepEquals, &hotplug.ProposedSlot{Attrs: map[string]interface{}{"path": "/dev/video2"}})
+
+	// metadata nodes are ignored
+	di, err = hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/video3", "ID_V4L_CAPABILITIES": ":", "ACTION": "add", "SUBSYSTEM": "video4linux"})
+	c.Assert(err, IsNil)
+	proposedSlot, err = hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Check(proposedSlot, IsNil)
+
+	di, err = hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/ttyUSB0", "ACTION": "add", "SUBSYSTEM": "tty"})
+	c.Assert(err, IsNil)
+	proposedSlot, err = hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Check(proposedSlot, IsNil)
+}
+
+func (s *CameraInterfaceSuite) TestHotplugKey(c *C) {
+	keyHandler := s.iface.(hotplug.HotplugKeyHandler)
+	attrs := map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/video0", "ID_VENDOR_ID": "046d", "ID_MODEL_ID": "0825", "ID_SERIAL": "Logitech_Webcam", "ID_USB_INTERFACE_NUM": "00", "SUBSYSTEM": "video4linux"}
+	di, err := hotplug.NewHotplugDeviceInfo(attrs)
+	c.Assert(err, IsNil)
+	key, err := keyHandler.HotplugKey(di)
+	c.Assert(err, IsNil)
+	c.Check(string(key), Matches, "0[0-9a-f]{64}")
+
+	// another USB interface of the same device has a different key
+	attrs["ID_USB_INTERFACE_NUM"] = "02"
+	di, err = hotplug.NewHotplugDeviceInfo(attrs)
+	c.Assert(err, IsNil)
+	otherKey, err := keyHandler.HotplugKey(di)
+	c.Assert(err, IsNil)
+	c.Check(string(otherKey), Matches, "0[0-9a-f]{64}")
+	c.Check(otherKey, Not(Equals), key)
+
+	// the default key is used for devices without USB interfaces
+	delete(attrs, "ID_USB_INTERFACE_NUM")
+	di, err = hotplug.NewHotplugDeviceInfo(attrs)
+	c.Assert(err, IsNil)
+	key, err = keyHandler.HotplugKey(di)
+	c.Assert(err, IsNil)
+	c.Check(key, Equals, snap.HotplugKey(""))
+}
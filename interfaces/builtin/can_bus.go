@@ -38,8 +38,9 @@ const canBusConnectedPlugSecComp = `
 # Description: Can use CAN networking
 bind
 
-# We allow AF_CAN in the default template since it is mediated via the AppArmor rule
-#socket AF_CAN
+# All the CAN protocols (raw, broadcast manager, ISO-TP, J1939...), AF_CAN is
+# not allowed by the default template
+socket AF_CAN
 `
 
 func init() {
@@ -85,6 +85,8 @@ func (s *CanBusInterfaceSuite) TestSecCompSpec(c *C) {
 	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
 	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
 	c.Assert(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "bind\n")
+	c.Assert(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "socket AF_CAN\n")
+	c.Assert(spec.SnippetForTag("snap.consumer.app"), Not(testutil.Contains), "socket AF_CAN -")
 }
 
 func (s *CanBusInterfaceSuite) TestStaticInfo(c *C) {
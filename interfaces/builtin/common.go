@@ -26,6 +26,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/kmod"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/interfaces/mount"
 	"github.com/snapcore/snapd/interfaces/seccomp"
 	"github.com/snapcore/snapd/interfaces/udev"
@@ -56,6 +57,7 @@ type commonInterface struct {
 
 	connectedPlugAppArmor  string
 	connectedPlugSecComp   string
+	connectedPlugLandlock  string
 	connectedPlugUDev      []string
 	rejectAutoConnectPairs bool
 
@@ -151,6 +153,13 @@ func (iface *commonInterface) KModConnectedSlot(spec *kmod.Specification, plug *
 	return nil
 }
 
+func (iface *commonInterface) LandlockConnectedPlug(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	if snippet := iface.connectedPlugLandlock; snippet != "" {
+		return spec.AddSnippet(snippet)
+	}
+	return nil
+}
+
 func (iface *commonInterface) MountConnectedPlug(spec *mount.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
 	for _, entry := range iface.connectedPlugMount {
 		if err := spec.AddMountEntry(entry); err != nil {
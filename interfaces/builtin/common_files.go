@@ -27,6 +27,7 @@ import (
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	apparmor_sandbox "github.com/snapcore/snapd/sandbox/apparmor"
 	"github.com/snapcore/snapd/snap"
 )
@@ -165,3 +166,38 @@ func (iface *commonFilesInterface) AppArmorConnectedPlug(spec *apparmor.Specific
 
 	return nil
 }
+
+// formatLandlockPath returns the path of a landlock rule granting access to
+// the given path. The $HOME of snap applications is their user data
+// directory, the home directory of the user is found at $SNAP_REAL_HOME.
+func formatLandlockPath(ip interface{}) (string, error) {
+	p, ok := ip.(string)
+	if !ok {
+		return "", fmt.Errorf("%[1]v (%[1]T) is not a string", ip)
+	}
+	p = filepath.Clean(p)
+	return strings.Replace(p, "$HOME", "$SNAP_REAL_HOME", -1), nil
+}
+
+func (iface *commonFilesInterface) LandlockConnectedPlug(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	var reads, writes []interface{}
+	_ = plug.Attr("read", &reads)
+	_ = plug.Attr("write", &writes)
+
+	errPrefix := fmt.Sprintf(`cannot connect plug %s: `, plug.Name())
+	for _, rawPath := range reads {
+		p, err := formatLandlockPath(rawPath)
+		if err != nil {
+			return fmt.Errorf("%s%v", errPrefix, err)
+		}
+		spec.AllowRead(p)
+	}
+	for _, rawPath := range writes {
+		p, err := formatLandlockPath(rawPath)
+		if err != nil {
+			return fmt.Errorf("%s%v", errPrefix, err)
+		}
+		spec.AllowWrite(p)
+	}
+	return nil
+}
@@ -28,6 +28,7 @@ import (
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/interfaces/utils"
 	"github.com/snapcore/snapd/snap"
@@ -179,6 +180,40 @@ func (iface *customDeviceInterface) validateUDevTaggingRule(rule map[string]inte
 	return nil
 }
 
+// validateHotplugMatch checks the udev properties that devices must have for a
+// slot to be instantiated from the template.
+func (iface *customDeviceInterface) validateHotplugMatch(match map[string]interface{}) error {
+	if len(match) == 0 {
+		return errors.New(`custom-device "hotplug" attribute must specify the properties of matching devices`)
+	}
+	_, hasVendor := match["usb-vendor"]
+	if _, hasProduct := match["usb-product"]; hasProduct && !hasVendor {
+		return errors.New(`custom-device "hotplug" attribute cannot match "usb-product" without "usb-vendor"`)
+	}
+
+	for key, value := range match {
+		var err error
+		switch key {
+		case "subsystem":
+			err = iface.validateUDevValue(value)
+		case "usb-vendor", "usb-product":
+			if id, ok := value.(int64); !ok || id < 0 || id > 0xFFFF {
+				err = fmt.Errorf(`value "%v" is not a valid USB ID`, value)
+			}
+		case "environment":
+			err = iface.validateUDevValueMap(value)
+		default:
+			err = errors.New(`unknown property`)
+		}
+
+		if err != nil {
+			return fmt.Errorf(`custom-device "hotplug" invalid %q property: %v`, key, err)
+		}
+	}
+
+	return nil
+}
+
 func (iface *customDeviceInterface) Name() string {
 	return "custom-device"
 }
@@ -254,7 +289,21 @@ func (iface *customDeviceInterface) BeforePrepareSlot(slot *snap.SlotInfo) error
 		}
 	}
 
-	if len(allDevices) == 0 && len(filesMap) == 0 {
+	// slots with the "hotplug" attribute are templates for the slots
+	// created for matching devices, which get access to the device node
+	var hotplugMatch map[string]interface{}
+	err = slot.Attr("hotplug", &hotplugMatch)
+	if err != nil && !errors.Is(err, snap.AttributeNotFoundError{}) {
+		return err
+	}
+	isTemplate := err == nil
+	if isTemplate {
+		if err := iface.validateHotplugMatch(hotplugMatch); err != nil {
+			return err
+		}
+	}
+
+	if len(allDevices) == 0 && len(filesMap) == 0 && !isTemplate {
 		return fmt.Errorf("cannot use custom-device slot without any files or devices")
 	}
 
@@ -416,6 +465,93 @@ func (iface *customDeviceInterface) AutoConnect(plug *snap.PlugInfo, slot *snap.
 	return true
 }
 
+func (iface *customDeviceInterface) HotplugDeviceDetected(di *hotplug.HotplugDeviceInfo) (*hotplug.ProposedSlot, error) {
+	// slots are only created from the templates declared by the gadget
+	return nil, nil
+}
+
+// hotplugMatches returns whether the device has all the udev properties of
+// the match. No validation is performed, since it already occurred when
+// installing the gadget.
+func (iface *customDeviceInterface) hotplugMatches(di *hotplug.HotplugDeviceInfo, match map[string]interface{}) bool {
+	if subsystem, ok := match["subsystem"].(string); ok && di.Subsystem() != subsystem {
+		return false
+	}
+	if usbVendor, ok := match["usb-vendor"].(int64); ok && !slotDeviceAttrEqual(di, "ID_VENDOR_ID", usbVendor) {
+		return false
+	}
+	if usbProduct, ok := match["usb-product"].(int64); ok && !slotDeviceAttrEqual(di, "ID_MODEL_ID", usbProduct) {
+		return false
+	}
+	for variable, value := range iface.extractStringMapAttribute(match, "environment") {
+		if actual, ok := di.Attribute(variable); !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (iface *customDeviceInterface) HotplugSlotFromTemplate(di *hotplug.HotplugDeviceInfo, template *snap.SlotInfo) (*hotplug.ProposedSlot, error) {
+	var match map[string]interface{}
+	if err := template.Attr("hotplug", &match); err != nil {
+		// not a template
+		return nil, nil
+	}
+	devicePath := di.DeviceName()
+	if devicePath == "" || !iface.hotplugMatches(di, match) {
+		return nil, nil
+	}
+	if err := iface.validateDevice(devicePath, "devices"); err != nil {
+		return nil, err
+	}
+
+	attrs := make(map[string]interface{}, len(template.Attrs))
+	for key, value := range template.Attrs {
+		if key != "hotplug" {
+			attrs[key] = value
+		}
+	}
+
+	var devices []interface{}
+	if templateDevices, ok := template.Attrs["devices"].([]interface{}); ok {
+		devices = append(devices, templateDevices...)
+	}
+	attrs["devices"] = append(devices, devicePath)
+
+	// Tag the device with a rule composed of the properties it was matched
+	// with, on top of the tagging rules of the template.
+	rule := map[string]interface{}{
+		"kernel": strings.TrimPrefix(devicePath, "/dev/"),
+	}
+	if subsystem := di.Subsystem(); subsystem != "" {
+		rule["subsystem"] = subsystem
+	}
+	environment := make(map[string]interface{})
+	for variable, value := range iface.extractStringMapAttribute(match, "environment") {
+		environment[variable] = value
+	}
+	for matchKey, variable := range map[string]string{"usb-vendor": "ID_VENDOR_ID", "usb-product": "ID_MODEL_ID"} {
+		if _, ok := match[matchKey]; ok {
+			environment[variable], _ = di.Attribute(variable)
+		}
+	}
+	if len(environment) > 0 {
+		rule["environment"] = environment
+	}
+	var udevTaggingRules []interface{}
+	if templateRules, ok := template.Attrs["udev-tagging"].([]interface{}); ok {
+		udevTaggingRules = append(udevTaggingRules, templateRules...)
+	}
+	attrs["udev-tagging"] = append(udevTaggingRules, rule)
+
+	slot := hotplug.ProposedSlot{
+		Name:  template.Name,
+		Label: template.Label,
+		Attrs: attrs,
+	}
+	return &slot, nil
+}
+
 func init() {
 	registerIface(&customDeviceInterface{})
 }
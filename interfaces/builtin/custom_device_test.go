@@ -29,6 +29,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
@@ -320,6 +321,38 @@ apps:
 			"devices: [/dev/null]\n  udev-tagging:\n    - environment: {key: \"va{ue}\"}",
 			`custom-device "udev-tagging" invalid "environment" tag: value "va{ue}" contains invalid characters`,
 		},
+		{
+			"hotplug: [tty]",
+			`snap "provider" has interface "custom-device" with invalid value type \[\]interface {} for "hotplug" attribute.*`,
+		},
+		{
+			"hotplug: {}",
+			`custom-device "hotplug" attribute must specify the properties of matching devices`,
+		},
+		{
+			"hotplug: {usb-product: 0x1234}",
+			`custom-device "hotplug" attribute cannot match "usb-product" without "usb-vendor"`,
+		},
+		{
+			"hotplug: {usb-vendor: 0x12345}",
+			`custom-device "hotplug" invalid "usb-vendor" property: value "74565" is not a valid USB ID`,
+		},
+		{
+			"hotplug: {usb-vendor: 0x1234, usb-product: one}",
+			`custom-device "hotplug" invalid "usb-product" property: value "one" is not a valid USB ID`,
+		},
+		{
+			"hotplug: {subsystem: \"tt{y}\"}",
+			`custom-device "hotplug" invalid "subsystem" property: value "tt{y}" contains invalid characters`,
+		},
+		{
+			"hotplug: {environment: {ID_BUS: [usb]}}",
+			`custom-device "hotplug" invalid "environment" property: value "\[usb\]" is not a string`,
+		},
+		{
+			"hotplug: {serial: 1234}",
+			`custom-device "hotplug" invalid "serial" property: unknown property`,
+		},
 	}
 
 	for _, testData := range data {
@@ -330,6 +363,160 @@ apps:
 	}
 }
 
+const customDeviceTemplateYaml = `name: gadget
+version: 0
+type: gadget
+slots:
+ dongle:
+  interface: custom-device
+  custom-device: foo
+  label: USB dongle
+  hotplug:
+    subsystem: tty
+    usb-vendor: 0x0525
+    usb-product: 0xa4a7
+    environment:
+      ID_USB_INTERFACE_NUM: "00"
+  files:
+    read: [ /sys/bus/usb/devices ]
+`
+
+func (s *CustomDeviceInterfaceSuite) TestSanitizeSlotTemplate(c *C) {
+	// a template does not need to specify devices or files
+	const snapYaml = `name: gadget
+version: 0
+type: gadget
+slots:
+ dongle:
+  interface: custom-device
+  hotplug:
+    subsystem: tty
+`
+	_, slotInfo := MockConnectedSlot(c, snapYaml, nil, "dongle")
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, slotInfo), IsNil)
+
+	_, slotInfo = MockConnectedSlot(c, customDeviceTemplateYaml, nil, "dongle")
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, slotInfo), IsNil)
+}
+
+func (s *CustomDeviceInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/ttyUSB0", "ACTION": "add", "SUBSYSTEM": "tty"})
+	c.Assert(err, IsNil)
+	// slots are only instantiated from templates
+	proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Check(proposedSlot, IsNil)
+}
+
+func (s *CustomDeviceInterfaceSuite) TestHotplugSlotFromTemplate(c *C) {
+	_, template := MockConnectedSlot(c, customDeviceTemplateYaml, nil, "dongle")
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, template), IsNil)
+
+	templateIface := s.iface.(hotplug.TemplateDefiner)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":              "/sys/foo/bar",
+		"DEVNAME":              "/dev/ttyACM0",
+		"ACTION":               "add",
+		"SUBSYSTEM":            "tty",
+		"ID_VENDOR_ID":         "0525",
+		"ID_MODEL_ID":          "a4a7",
+		"ID_USB_INTERFACE_NUM": "00",
+	})
+	c.Assert(err, IsNil)
+	proposedSlot, err := templateIface.HotplugSlotFromTemplate(di, template)
+	c.Assert(err, IsNil)
+	c.Assert(proposedSlot, NotNil)
+	c.Check(proposedSlot.Name, Equals, "dongle")
+	c.Check(proposedSlot.Label, Equals, "USB dongle")
+	c.Check(proposedSlot.Attrs, DeepEquals, map[string]interface{}{
+		"custom-device": "foo",
+		"files": map[string]interface{}{
+			"read": []interface{}{"/sys/bus/usb/devices"},
+		},
+		"devices": []interface{}{"/dev/ttyACM0"},
+		"udev-tagging": []interface{}{
+			map[string]interface{}{
+				"kernel":    "ttyACM0",
+				"subsystem": "tty",
+				"environment": map[string]interface{}{
+					"ID_VENDOR_ID":         "0525",
+					"ID_MODEL_ID":          "a4a7",
+					"ID_USB_INTERFACE_NUM": "00",
+				},
+			},
+		},
+	})
+	// the template is left untouched
+	c.Check(template.Attrs["hotplug"], NotNil)
+	c.Check(template.Attrs["devices"], IsNil)
+
+	// the instantiated slot is valid
+	cleanSlot, err := proposedSlot.Clean()
+	c.Assert(err, IsNil)
+	slotInfo := &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "core"},
+		Name:      cleanSlot.Name,
+		Interface: "custom-device",
+		Attrs:     cleanSlot.Attrs,
+	}
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, slotInfo), IsNil)
+
+	// and tags the device with the properties it was matched with
+	slot := interfaces.NewConnectedSlot(slotInfo, nil, nil)
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	snippets := spec.Snippets()
+	c.Assert(snippets, HasLen, 2)
+	lines := strings.Split(snippets[0], "\n")
+	c.Assert(lines, HasLen, 2)
+	c.Check(strings.Split(lines[1], ", "), testutil.DeepUnsortedMatches, []string{
+		`KERNEL=="ttyACM0"`,
+		`SUBSYSTEM=="tty"`,
+		`ENV{ID_VENDOR_ID}=="0525"`,
+		`ENV{ID_MODEL_ID}=="a4a7"`,
+		`ENV{ID_USB_INTERFACE_NUM}=="00"`,
+		`TAG+="snap_consumer_app"`,
+	})
+
+	// the device node is accessible
+	apparmorSpec := &apparmor.Specification{}
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Check(apparmorSpec.SnippetForTag("snap.consumer.app"), testutil.Contains, `"/dev/ttyACM0" rw,`)
+}
+
+func (s *CustomDeviceInterfaceSuite) TestHotplugSlotFromTemplateNoMatch(c *C) {
+	_, template := MockConnectedSlot(c, customDeviceTemplateYaml, nil, "dongle")
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, template), IsNil)
+	templateIface := s.iface.(hotplug.TemplateDefiner)
+
+	for _, env := range []map[string]string{
+		// different subsystem
+		{"DEVNAME": "/dev/hidraw0", "SUBSYSTEM": "hidraw", "ID_VENDOR_ID": "0525", "ID_MODEL_ID": "a4a7", "ID_USB_INTERFACE_NUM": "00"},
+		// different product
+		{"DEVNAME": "/dev/ttyACM0", "SUBSYSTEM": "tty", "ID_VENDOR_ID": "0525", "ID_MODEL_ID": "a4a8", "ID_USB_INTERFACE_NUM": "00"},
+		// different environment
+		{"DEVNAME": "/dev/ttyACM0", "SUBSYSTEM": "tty", "ID_VENDOR_ID": "0525", "ID_MODEL_ID": "a4a7", "ID_USB_INTERFACE_NUM": "01"},
+		// no device node
+		{"SUBSYSTEM": "tty", "ID_VENDOR_ID": "0525", "ID_MODEL_ID": "a4a7", "ID_USB_INTERFACE_NUM": "00"},
+	} {
+		env["DEVPATH"] = "/sys/foo/bar"
+		env["ACTION"] = "add"
+		di, err := hotplug.NewHotplugDeviceInfo(env)
+		c.Assert(err, IsNil)
+		proposedSlot, err := templateIface.HotplugSlotFromTemplate(di, template)
+		c.Assert(err, IsNil)
+		c.Check(proposedSlot, IsNil, Commentf("%v", env))
+	}
+
+	// slots without the hotplug attribute are not templates
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/input/mice", "ACTION": "add", "SUBSYSTEM": "input"})
+	c.Assert(err, IsNil)
+	proposedSlot, err := templateIface.HotplugSlotFromTemplate(di, s.slotInfo)
+	c.Assert(err, IsNil)
+	c.Check(proposedSlot, IsNil)
+}
+
 func (s *CustomDeviceInterfaceSuite) TestSlotNameAttribute(c *C) {
 	var slotYamlTemplate = `name: provider
 version: 0
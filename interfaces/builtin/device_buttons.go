@@ -70,6 +70,10 @@ var deviceButtonsConnectedPlugUDev = []string{
 	`KERNEL=="full", SUBSYSTEM=="mem"`,
 }
 
+const deviceButtonsConnectedPlugLandlock = `
+write /dev/input/event[0-9]*
+`
+
 type deviceButtonsInterface struct {
 	commonInterface
 }
@@ -88,5 +92,6 @@ func init() {
 		baseDeclarationSlots:  deviceButtonsBaseDeclarationSlots,
 		connectedPlugAppArmor: deviceButtonsConnectedPlugAppArmor,
 		connectedPlugUDev:     deviceButtonsConnectedPlugUDev,
+		connectedPlugLandlock: deviceButtonsConnectedPlugLandlock,
 	}})
 }
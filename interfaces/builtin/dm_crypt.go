@@ -42,7 +42,8 @@ const dmCryptBaseDeclarationPlugs = `
 type dmCryptInterface struct{}
 
 // XXX: this should not hardcode mount points like /run/media/ but
-//      unless we have an interface like "mount-control" this is needed
+//
+//	unless we have an interface like "mount-control" this is needed
 const dmCryptConnectedPlugAppArmor = `
 # Allow mapper access
 /dev/mapper/control rw,
@@ -87,6 +88,11 @@ var dmCryptConnectedPlugUDev = []string{
 	`SUBSYSTEM=="block"`,
 }
 
+const dmCryptConnectedPlugLandlock = `
+write /dev/mapper/control
+write /dev/dm-[0-9]*
+`
+
 func (iface *dmCryptInterface) AutoConnect(*snap.PlugInfo, *snap.SlotInfo) bool {
 	// Allow what is allowed in the declarations
 	return true
@@ -104,5 +110,6 @@ func init() {
 		connectedPlugSecComp:     dmCryptConnectedPlugSecComp,
 		connectedPlugKModModules: dmCryptConnectedPlugKmod,
 		connectedPlugUDev:        dmCryptConnectedPlugUDev,
+		connectedPlugLandlock:    dmCryptConnectedPlugLandlock,
 	})
 }
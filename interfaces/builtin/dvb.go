@@ -38,6 +38,10 @@ const dvbConnectedPlugAppArmor = `
 
 var dvbConnectedPlugUDev = []string{`SUBSYSTEM=="dvb"`}
 
+const dvbConnectedPlugLandlock = `
+write /dev/dvb/adapter[0-9]*
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "dvb",
@@ -47,5 +51,6 @@ func init() {
 		baseDeclarationSlots:  dvbBaseDeclarationSlots,
 		connectedPlugAppArmor: dvbConnectedPlugAppArmor,
 		connectedPlugUDev:     dvbConnectedPlugUDev,
+		connectedPlugLandlock: dvbConnectedPlugLandlock,
 	})
 }
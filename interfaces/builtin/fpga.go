@@ -59,6 +59,10 @@ var fpgaConnectedPlugUDev = []string{
 	`SUBSYSTEM=="misc", KERNEL=="fpga[0-9]*"`,
 }
 
+const fpgaConnectedPlugLandlock = `
+write /dev/fpga[0-9]*
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "fpga",
@@ -68,5 +72,6 @@ func init() {
 		baseDeclarationSlots:  fpgaBaseDeclarationSlots,
 		connectedPlugAppArmor: fpgaConnectedPlugAppArmor,
 		connectedPlugUDev:     fpgaConnectedPlugUDev,
+		connectedPlugLandlock: fpgaConnectedPlugLandlock,
 	})
 }
@@ -39,6 +39,10 @@ const framebufferConnectedPlugAppArmor = `
 
 var framebufferConnectedPlugUDev = []string{`KERNEL=="fb[0-9]*"`}
 
+const framebufferConnectedPlugLandlock = `
+write /dev/fb[0-9]*
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "framebuffer",
@@ -48,5 +52,6 @@ func init() {
 		baseDeclarationSlots:  framebufferBaseDeclarationSlots,
 		connectedPlugAppArmor: framebufferConnectedPlugAppArmor,
 		connectedPlugUDev:     framebufferConnectedPlugUDev,
+		connectedPlugLandlock: framebufferConnectedPlugLandlock,
 	})
 }
@@ -93,6 +93,10 @@ deny /etc/fuse.conf r,
 
 var fuseSupportConnectedPlugUDev = []string{`KERNEL=="fuse"`}
 
+const fuseSupportConnectedPlugLandlock = `
+write /dev/fuse
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "fuse-support",
@@ -103,5 +107,6 @@ func init() {
 		connectedPlugAppArmor: fuseSupportConnectedPlugAppArmor,
 		connectedPlugSecComp:  fuseSupportConnectedPlugSecComp,
 		connectedPlugUDev:     fuseSupportConnectedPlugUDev,
+		connectedPlugLandlock: fuseSupportConnectedPlugLandlock,
 	})
 }
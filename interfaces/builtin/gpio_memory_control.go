@@ -40,6 +40,10 @@ const gpioMemoryControlConnectedPlugAppArmor = `
 
 var gpioMemoryControlConnectedPlugUDev = []string{`KERNEL=="gpiomem"`}
 
+const gpioMemoryControlConnectedPlugLandlock = `
+write /dev/gpiomem
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "gpio-memory-control",
@@ -49,5 +53,6 @@ func init() {
 		baseDeclarationSlots:  gpioMemoryControlBaseDeclarationSlots,
 		connectedPlugAppArmor: gpioMemoryControlConnectedPlugAppArmor,
 		connectedPlugUDev:     gpioMemoryControlConnectedPlugUDev,
+		connectedPlugLandlock: gpioMemoryControlConnectedPlugLandlock,
 	})
 }
@@ -47,6 +47,10 @@ const hardwareRandomControlConnectedPlugAppArmor = `
 
 var hardwareRandomControlConnectedPlugUDev = []string{`KERNEL=="hwrng"`}
 
+const hardwareRandomControlConnectedPlugLandlock = `
+write /dev/hwrng
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "hardware-random-control",
@@ -56,5 +60,6 @@ func init() {
 		baseDeclarationSlots:  hardwareRandomControlBaseDeclarationSlots,
 		connectedPlugAppArmor: hardwareRandomControlConnectedPlugAppArmor,
 		connectedPlugUDev:     hardwareRandomControlConnectedPlugUDev,
+		connectedPlugLandlock: hardwareRandomControlConnectedPlugLandlock,
 	})
 }
@@ -42,6 +42,10 @@ const hardwareRandomObserveConnectedPlugAppArmor = `
 
 var hardwareRandomObserveConnectedPlugUDev = []string{`KERNEL=="hwrng"`}
 
+const hardwareRandomObserveConnectedPlugLandlock = `
+read /dev/hwrng
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "hardware-random-observe",
@@ -51,5 +55,6 @@ func init() {
 		baseDeclarationSlots:  hardwareRandomObserveBaseDeclarationSlots,
 		connectedPlugAppArmor: hardwareRandomObserveConnectedPlugAppArmor,
 		connectedPlugUDev:     hardwareRandomObserveConnectedPlugUDev,
+		connectedPlugLandlock: hardwareRandomObserveConnectedPlugLandlock,
 	})
 }
@@ -27,6 +27,8 @@ import (
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 )
@@ -155,6 +157,22 @@ func (iface *hidrawInterface) AppArmorConnectedPlug(spec *apparmor.Specification
 
 }
 
+func (iface *hidrawInterface) LandlockConnectedPlug(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	if iface.hasUsbAttrs(slot) {
+		// as with AppArmor, udev tagging and device cgroups restrict
+		// down to the specific device
+		spec.AllowWrite("/dev/hidraw[0-9]*")
+		return nil
+	}
+
+	var path string
+	if err := slot.Attr("path", &path); err != nil {
+		return err
+	}
+	spec.AllowWrite(filepath.Clean(path))
+	return nil
+}
+
 func (iface *hidrawInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
 	hasOnlyPath := true
 	if iface.hasUsbAttrs(slot) {
@@ -193,6 +211,40 @@ func (iface *hidrawInterface) AutoConnect(*snap.PlugInfo, *snap.SlotInfo) bool {
 	return true
 }
 
+func (iface *hidrawInterface) HotplugDeviceDetected(di *hotplug.HotplugDeviceInfo) (*hotplug.ProposedSlot, error) {
+	if di.Subsystem() != "hidraw" || !hidrawDeviceNodePattern.MatchString(di.DeviceName()) {
+		return nil, nil
+	}
+
+	slot := hotplug.ProposedSlot{
+		Attrs: map[string]interface{}{
+			"path": di.DeviceName(),
+		},
+	}
+	return &slot, nil
+}
+
+func (iface *hidrawInterface) HotplugKey(di *hotplug.HotplugDeviceInfo) (snap.HotplugKey, error) {
+	return usbInterfaceHotplugKey(di)
+}
+
+func (iface *hidrawInterface) HandledByGadget(di *hotplug.HotplugDeviceInfo, slot *snap.SlotInfo) bool {
+	// if the slot has vendor and product set, check if they match
+	var usbVendor, usbProduct int64
+	if err := slot.Attr("usb-vendor", &usbVendor); err == nil {
+		if err := slot.Attr("usb-product", &usbProduct); err != nil {
+			return false
+		}
+		return slotDeviceAttrEqual(di, "ID_VENDOR_ID", usbVendor) && slotDeviceAttrEqual(di, "ID_MODEL_ID", usbProduct)
+	}
+
+	var path string
+	if err := slot.Attr("path", &path); err != nil {
+		return false
+	}
+	return di.DeviceName() == path
+}
+
 func (iface *hidrawInterface) hasUsbAttrs(attrs interfaces.Attrer) bool {
 	var v int64
 	if err := attrs.Attr("usb-vendor", &v); err == nil {
@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
@@ -365,3 +366,37 @@ SUBSYSTEM=="hidraw", SUBSYSTEMS=="usb", ATTRS{idVendor}=="ffff", ATTRS{idProduct
 func (s *HidrawInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
+
+func (s *HidrawInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/hidraw3", "ACTION": "add", "SUBSYSTEM": "hidraw"})
+	c.Assert(err, IsNil)
+	proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Check(proposedSlot, DeepEquals, &hotplug.ProposedSlot{Attrs: map[string]interface{}{"path": "/dev/hidraw3"}})
+
+	// the slot can be used as is
+	slot := &snap.SlotInfo{Snap: &snap.Info{SuggestedName: "core"}, Name: "yubikey", Interface: "hidraw", Attrs: proposedSlot.Attrs}
+	c.Check(interfaces.BeforePrepareSlot(s.iface, slot), IsNil)
+}
+
+func (s *HidrawInterfaceSuite) TestHotplugDeviceDetectedNotHidraw(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/input/event3", "ACTION": "add", "SUBSYSTEM": "input"})
+	c.Assert(err, IsNil)
+	proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Check(proposedSlot, IsNil)
+}
+
+func (s *HidrawInterfaceSuite) TestHotplugHandledByGadget(c *C) {
+	byGadgetPred := s.iface.(hotplug.HandledByGadgetPredicate)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/hidraw0", "ID_VENDOR_ID": "0001", "ID_MODEL_ID": "0001", "ACTION": "add", "SUBSYSTEM": "hidraw"})
+	c.Assert(err, IsNil)
+	// matching path
+	c.Check(byGadgetPred.HandledByGadget(di, s.testSlot1Info), Equals, true)
+	c.Check(byGadgetPred.HandledByGadget(di, s.testSlot2Info), Equals, false)
+	// matching vendor and product
+	c.Check(byGadgetPred.HandledByGadget(di, s.testUDev1Info), Equals, true)
+	c.Check(byGadgetPred.HandledByGadget(di, s.testUDev2Info), Equals, false)
+}
@@ -24,6 +24,7 @@ import (
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/snap"
 )
 
@@ -121,6 +122,22 @@ func (iface *homeInterface) AppArmorConnectedPlug(spec *apparmor.Specification,
 	return nil
 }
 
+func (iface *homeInterface) LandlockConnectedPlug(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	var read string
+	_ = plug.Attr("read", &read)
+	// Landlock grants access to whole file hierarchies and cannot tell
+	// files owned by the user from others. To keep the hidden files of
+	// the user out of reach, the home directory can only be listed and
+	// write access is granted to the non-hidden entries present in it
+	// when the application starts, new ones cannot be created there.
+	spec.AllowRead("$SNAP_REAL_HOME")
+	spec.AllowWrite("$SNAP_REAL_HOME/*")
+	if read == "all" {
+		spec.AllowRead("/home/*/*")
+	}
+	return nil
+}
+
 func init() {
 	registerIface(&homeInterface{commonInterface{
 		name:                 "home",
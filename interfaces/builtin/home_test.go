@@ -25,6 +25,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/testutil"
@@ -171,6 +172,36 @@ apps:
 	c.Check(apparmorSpec.SnippetForTag("snap.home-plug-snap.app2"), testutil.Contains, `# Allow non-owner read`)
 }
 
+func (s *HomeInterfaceSuite) TestConnectedPlugLandlock(c *C) {
+	landlockSpec := &landlock.Specification{}
+	err := landlockSpec.AddConnectedPlug(s.iface, s.plug, s.slot)
+	c.Assert(err, IsNil)
+	c.Check(landlockSpec.Rules(), DeepEquals, map[string][]string{
+		"snap.other.app": {"read $SNAP_REAL_HOME", "write $SNAP_REAL_HOME/*"},
+	})
+}
+
+func (s *HomeInterfaceSuite) TestConnectedPlugLandlockWithAttribAll(c *C) {
+	const mockSnapYaml = `name: home-plug-snap
+version: 1.0
+plugs:
+ home:
+  read: all
+apps:
+ app2:
+  command: foo
+`
+	info := snaptest.MockInfo(c, mockSnapYaml, nil)
+	plug := interfaces.NewConnectedPlug(info.Plugs["home"], nil, nil)
+
+	landlockSpec := &landlock.Specification{}
+	err := landlockSpec.AddConnectedPlug(s.iface, plug, s.slot)
+	c.Assert(err, IsNil)
+	c.Check(landlockSpec.Rules(), DeepEquals, map[string][]string{
+		"snap.home-plug-snap.app2": {"read $SNAP_REAL_HOME", "write $SNAP_REAL_HOME/*", "read /home/*/*"},
+	})
+}
+
 func (s *HomeInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
@@ -27,6 +27,7 @@ import (
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 )
@@ -136,6 +137,17 @@ func (iface *i2cInterface) AppArmorConnectedPlug(spec *apparmor.Specification, p
 	return nil
 }
 
+func (iface *i2cInterface) LandlockConnectedPlug(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	// sysfs-name slots are only about sysfs, which is not reachable
+	// through the landlock profiles
+	var path string
+	if err := slot.Attr("path", &path); err != nil {
+		return nil
+	}
+	spec.AllowWrite(filepath.Clean(path))
+	return nil
+}
+
 func (iface *i2cInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
 	var path string
 	if err := slot.Attr("path", &path); err != nil {
@@ -27,6 +27,7 @@ import (
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 )
@@ -132,6 +133,15 @@ func (iface *iioInterface) AppArmorConnectedPlug(spec *apparmor.Specification, p
 	return nil
 }
 
+func (iface *iioInterface) LandlockConnectedPlug(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	var path string
+	if err := slot.Attr("path", &path); err != nil {
+		return nil
+	}
+	spec.AllowWrite(filepath.Clean(path))
+	return nil
+}
+
 func (iface *iioInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
 	var path string
 	if err := slot.Attr("path", &path); err != nil {
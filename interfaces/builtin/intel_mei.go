@@ -39,6 +39,10 @@ const intelMEIConnectedPlugAppArmor = `
 
 var intelMEIConnectedPlugUDev = []string{`SUBSYSTEM=="mei"`}
 
+const intelMEIConnectedPlugLandlock = `
+write /dev/mei[0-9]*
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "intel-mei",
@@ -48,5 +52,6 @@ func init() {
 		baseDeclarationSlots:  intelMEIBaseDeclarationSlots,
 		connectedPlugAppArmor: intelMEIConnectedPlugAppArmor,
 		connectedPlugUDev:     intelMEIConnectedPlugUDev,
+		connectedPlugLandlock: intelMEIConnectedPlugLandlock,
 	})
 }
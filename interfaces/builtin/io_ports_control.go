@@ -50,6 +50,10 @@ iopl
 
 var ioPortsControlConnectedPlugUDev = []string{`KERNEL=="port"`}
 
+const ioPortsControlConnectedPlugLandlock = `
+write /dev/port
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "io-ports-control",
@@ -60,5 +64,6 @@ func init() {
 		connectedPlugAppArmor: ioPortsControlConnectedPlugAppArmor,
 		connectedPlugSecComp:  ioPortsControlConnectedPlugSecComp,
 		connectedPlugUDev:     ioPortsControlConnectedPlugUDev,
+		connectedPlugLandlock: ioPortsControlConnectedPlugLandlock,
 	})
 }
@@ -47,6 +47,10 @@ var ionMemoryControlConnectedPlugUDev = []string{
 	`KERNEL=="ion"`,
 }
 
+const ionMemoryControlConnectedPlugLandlock = `
+write /dev/ion
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "ion-memory-control",
@@ -57,5 +61,6 @@ func init() {
 		baseDeclarationPlugs:  ionMemoryControlBaseDeclarationPlugs,
 		connectedPlugAppArmor: ionMemoryControlConnectedPlugAppArmor,
 		connectedPlugUDev:     ionMemoryControlConnectedPlugUDev,
+		connectedPlugLandlock: ionMemoryControlConnectedPlugLandlock,
 	})
 }
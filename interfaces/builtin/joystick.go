@@ -20,8 +20,15 @@
 package builtin
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
 )
 
 const joystickSummary = `allows access to joystick devices`
@@ -69,6 +76,15 @@ const joystickConnectedPlugAppArmor = `
 /sys/devices/**/input[0-9]*/capabilities/* r,
 `
 
+// Slots created by hotplug grant access to the evdev node of a single
+// joystick only.
+const joystickDeviceConnectedPlugAppArmor = `
+# Description: Allow reading and writing to a single joystick device
+%s rw,
+/run/udev/data/c13:{6[5-9],[7-9][0-9],[1-9][0-9][0-9]*} r,
+/sys/devices/**/input[0-9]*/capabilities/* r,
+`
+
 // Add the old joystick device (js*) and any evdev input interfaces which are
 // marked as joysticks. Note, some input devices are known to come up as
 // joysticks when they are not and while this rule would tag them, on systems
@@ -87,13 +103,64 @@ var joystickConnectedPlugUDev = []string{
 	`KERNEL=="full", SUBSYSTEM=="mem"`,
 }
 
+const joystickConnectedPlugLandlock = `
+write /dev/input/js[0-9]
+write /dev/input/js[12][0-9]
+write /dev/input/js3[01]
+write /dev/input/event[0-9]*
+`
+
+// Pattern to match the evdev device nodes of joysticks, for which slots are
+// created by hotplug.
+var joystickDeviceNodePattern = regexp.MustCompile("^/dev/input/event[0-9]+$")
+
 type joystickInterface struct {
 	commonInterface
 }
 
+// BeforePrepareSlot checks the path attribute of slots created by hotplug.
+func (iface *joystickInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
+	_, err := hotplugSlotDevicePath(&interfaces.SlotRef{Snap: slot.Snap.InstanceName(), Name: slot.Name}, slot, joystickDeviceNodePattern)
+	return err
+}
+
+func (iface *joystickInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	path, err := hotplugSlotDevicePath(slot.Ref(), slot, joystickDeviceNodePattern)
+	if err != nil {
+		return nil
+	}
+	if path == "" {
+		return iface.commonInterface.AppArmorConnectedPlug(spec, plug, slot)
+	}
+	spec.AddSnippet(fmt.Sprintf(joystickDeviceConnectedPlugAppArmor, path))
+	return nil
+}
+
 func (iface *joystickInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	path, err := hotplugSlotDevicePath(slot.Ref(), slot, joystickDeviceNodePattern)
+	if err != nil {
+		return nil
+	}
 	spec.TriggerSubsystem("input/joystick")
-	return iface.commonInterface.UDevConnectedPlug(spec, plug, slot)
+	if path == "" {
+		return iface.commonInterface.UDevConnectedPlug(spec, plug, slot)
+	}
+	spec.TagDevice(fmt.Sprintf(`KERNEL=="%s", SUBSYSTEM=="input", ENV{ID_INPUT_JOYSTICK}=="1"`, strings.TrimPrefix(path, "/dev/input/")))
+	return nil
+}
+
+func (iface *joystickInterface) HotplugDeviceDetected(di *hotplug.HotplugDeviceInfo) (*hotplug.ProposedSlot, error) {
+	// the legacy js* nodes of joysticks are left to the implicit slot,
+	// only the evdev ones get a slot
+	joystick, _ := di.Attribute("ID_INPUT_JOYSTICK")
+	if di.Subsystem() != "input" || joystick != "1" || !joystickDeviceNodePattern.MatchString(di.DeviceName()) {
+		return nil, nil
+	}
+	return &hotplug.ProposedSlot{
+		Attrs: map[string]interface{}{
+			"path": di.DeviceName(),
+		},
+	}, nil
 }
 
 func init() {
@@ -105,5 +172,6 @@ func init() {
 		baseDeclarationSlots:  joystickBaseDeclarationSlots,
 		connectedPlugAppArmor: joystickConnectedPlugAppArmor,
 		connectedPlugUDev:     joystickConnectedPlugUDev,
+		connectedPlugLandlock: joystickConnectedPlugLandlock,
 	}})
 }
@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
@@ -113,3 +114,60 @@ func (s *JoystickInterfaceSuite) TestAutoConnect(c *C) {
 func (s *JoystickInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
+
+const joystickHotplugCoreYaml = `name: core
+version: 0
+type: os
+slots:
+  gamepad:
+    interface: joystick
+    path: /dev/input/event12
+  bad-path:
+    interface: joystick
+    path: /dev/input/mice
+`
+
+func (s *JoystickInterfaceSuite) TestSanitizeHotplugSlot(c *C) {
+	slot := MockSlot(c, joystickHotplugCoreYaml, nil, "gamepad")
+	c.Check(interfaces.BeforePrepareSlot(s.iface, slot), IsNil)
+	slot = MockSlot(c, joystickHotplugCoreYaml, nil, "bad-path")
+	c.Check(interfaces.BeforePrepareSlot(s.iface, slot), ErrorMatches, `slot "core:bad-path" path attribute must be a valid device node`)
+}
+
+func (s *JoystickInterfaceSuite) TestHotplugSlotSpecs(c *C) {
+	slot, _ := MockConnectedSlot(c, joystickHotplugCoreYaml, nil, "gamepad")
+
+	apparmorSpec := &apparmor.Specification{}
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Check(apparmorSpec.SnippetForTag("snap.consumer.app"), testutil.Contains, "/dev/input/event12 rw,")
+	c.Check(apparmorSpec.SnippetForTag("snap.consumer.app"), Not(testutil.Contains), "/dev/input/event[0-9]* rw,")
+
+	udevSpec := &udev.Specification{}
+	c.Assert(udevSpec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Assert(udevSpec.Snippets(), HasLen, 2)
+	c.Check(udevSpec.Snippets(), testutil.Contains, `# joystick
+KERNEL=="event12", SUBSYSTEM=="input", ENV{ID_INPUT_JOYSTICK}=="1", TAG+="snap_consumer_app"`)
+	c.Check(udevSpec.TriggeredSubsystems(), DeepEquals, []string{"input/joystick"})
+}
+
+func (s *JoystickInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/input/event12", "ID_INPUT_JOYSTICK": "1", "ACTION": "add", "SUBSYSTEM": "input"})
+	c.Assert(err, IsNil)
+	proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Check(proposedSlot, DeepEquals, &hotplug.ProposedSlot{Attrs: map[string]interface{}{"path": "/dev/input/event12"}})
+
+	for _, attrs := range []map[string]string{
+		// keyboards and other input devices
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/input/event3", "ID_INPUT_KEYBOARD": "1", "ACTION": "add", "SUBSYSTEM": "input"},
+		// legacy joystick nodes
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/input/js0", "ID_INPUT_JOYSTICK": "1", "ACTION": "add", "SUBSYSTEM": "input"},
+	} {
+		di, err := hotplug.NewHotplugDeviceInfo(attrs)
+		c.Assert(err, IsNil)
+		proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+		c.Assert(err, IsNil)
+		c.Check(proposedSlot, IsNil)
+	}
+}
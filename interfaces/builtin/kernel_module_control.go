@@ -69,6 +69,10 @@ delete_module
 
 var kernelModuleControlConnectedPlugUDev = []string{`KERNEL=="mem"`}
 
+const kernelModuleControlConnectedPlugLandlock = `
+read /dev/mem
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "kernel-module-control",
@@ -80,6 +84,7 @@ func init() {
 		connectedPlugAppArmor: kernelModuleControlConnectedPlugAppArmor,
 		connectedPlugSecComp:  kernelModuleControlConnectedPlugSecComp,
 		connectedPlugUDev:     kernelModuleControlConnectedPlugUDev,
+		connectedPlugLandlock: kernelModuleControlConnectedPlugLandlock,
 
 		usesSysModuleCapability: true,
 	})
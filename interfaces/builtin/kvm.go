@@ -56,6 +56,10 @@ const kvmConnectedPlugAppArmor = `
 
 var kvmConnectedPlugUDev = []string{`KERNEL=="kvm"`}
 
+const kvmConnectedPlugLandlock = `
+write /dev/kvm
+`
+
 type kvmInterface struct {
 	commonInterface
 }
@@ -115,5 +119,6 @@ func init() {
 		baseDeclarationSlots:  kvmBaseDeclarationSlots,
 		connectedPlugAppArmor: kvmConnectedPlugAppArmor,
 		connectedPlugUDev:     kvmConnectedPlugUDev,
+		connectedPlugLandlock: kvmConnectedPlugLandlock,
 	}})
 }
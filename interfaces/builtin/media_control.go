@@ -48,6 +48,11 @@ var mediaControlConnectedPlugUDev = []string{
 	`SUBSYSTEM=="video4linux", KERNEL=="v4l-subdev[0-9]*"`,
 }
 
+const mediaControlConnectedPlugLandlock = `
+write /dev/media[0-9]*
+write /dev/v4l-subdev[0-9]*
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "media-control",
@@ -57,5 +62,6 @@ func init() {
 		baseDeclarationSlots:  mediaControlBaseDeclarationSlots,
 		connectedPlugAppArmor: mediaControlConnectedPlugAppArmor,
 		connectedPlugUDev:     mediaControlConnectedPlugUDev,
+		connectedPlugLandlock: mediaControlConnectedPlugLandlock,
 	})
 }
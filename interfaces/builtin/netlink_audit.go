@@ -23,6 +23,7 @@ import (
 	"errors"
 
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/seccomp"
 	apparmor_sandbox "github.com/snapcore/snapd/sandbox/apparmor"
 	"github.com/snapcore/snapd/strutil"
 )
@@ -40,7 +41,6 @@ const netlinkAuditBaseDeclarationSlots = `
 const netlinkAuditConnectedPlugSecComp = `
 # Description: Can use netlink to read/write to kernel audit system.
 bind
-socket AF_NETLINK - NETLINK_AUDIT
 `
 
 const netlinkAuditConnectedPlugAppArmor = `
@@ -63,6 +63,13 @@ type netlinkAuditInterface struct {
 	commonInterface
 }
 
+func (iface *netlinkAuditInterface) SecCompConnectedPlug(spec *seccomp.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	if err := iface.commonInterface.SecCompConnectedPlug(spec, plug, slot); err != nil {
+		return err
+	}
+	return spec.AllowSocket("AF_NETLINK", "NETLINK_AUDIT")
+}
+
 func (iface *netlinkAuditInterface) BeforeConnectPlug(plug *interfaces.ConnectedPlug) error {
 	if apparmor_sandbox.ProbedLevel() == apparmor_sandbox.Unsupported {
 		// no apparmor means we don't have to deal with parser features
@@ -19,6 +19,11 @@
 
 package builtin
 
+import (
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/seccomp"
+)
+
 const netlinkConnectorSummary = `allows communication through the kernel netlink connector`
 
 const netlinkConnectorBaseDeclarationSlots = `
@@ -35,7 +40,6 @@ const netlinkConnectorConnectedPlugSecComp = `
 # interface allows communications via all netlink connectors.
 # https://github.com/torvalds/linux/blob/master/Documentation/connector/connector.txt
 bind
-socket AF_NETLINK - NETLINK_CONNECTOR
 `
 
 const netlinkConnectorConnectedPlugAppArmor = `
@@ -48,8 +52,19 @@ network netlink,
 capability net_admin,
 `
 
+type netlinkConnectorInterface struct {
+	commonInterface
+}
+
+func (iface *netlinkConnectorInterface) SecCompConnectedPlug(spec *seccomp.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	if err := iface.commonInterface.SecCompConnectedPlug(spec, plug, slot); err != nil {
+		return err
+	}
+	return spec.AllowSocket("AF_NETLINK", "NETLINK_CONNECTOR")
+}
+
 func init() {
-	registerIface(&commonInterface{
+	registerIface(&netlinkConnectorInterface{commonInterface{
 		name:                  "netlink-connector",
 		summary:               netlinkConnectorSummary,
 		implicitOnCore:        true,
@@ -57,5 +72,5 @@ func init() {
 		baseDeclarationSlots:  netlinkConnectorBaseDeclarationSlots,
 		connectedPlugSecComp:  netlinkConnectorConnectedPlugSecComp,
 		connectedPlugAppArmor: netlinkConnectorConnectedPlugAppArmor,
-	})
+	}})
 }
@@ -22,6 +22,7 @@ package builtin
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/seccomp"
@@ -119,9 +120,8 @@ func (iface *netlinkDriverInterface) SecCompConnectedPlug(spec *seccomp.Specific
 
 	spec.AddSnippet(fmt.Sprintf(`# Description: Can access the Linux kernel custom netlink protocol
 # for family %s
-socket AF_NETLINK - %d
-bind`, familyName, familyNum))
-	return nil
+bind`, familyName))
+	return spec.AllowSocket("AF_NETLINK", strconv.FormatInt(familyNum, 10))
 }
 
 func (iface *netlinkDriverInterface) AutoConnect(plug *snap.PlugInfo, slot *snap.SlotInfo) bool {
@@ -19,13 +19,39 @@
 
 package builtin
 
+import (
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/nftables"
+	"github.com/snapcore/snapd/snap"
+)
+
 const networkSummary = `allows access to the network`
 
+// The hosts and ports that the egress of a network plug is restricted to
+// with "allow-hosts" and "allow-ports" must be granted by a snap declaration,
+// like the other attributes which are reviewed by the store.
 const networkBaseDeclarationSlots = `
   network:
     allow-installation:
       slot-snap-type:
         - core
+    deny-connection:
+      -
+        plug-attributes:
+          allow-hosts: .*
+      -
+        plug-attributes:
+          allow-ports: .*
+    deny-auto-connection:
+      -
+        plug-attributes:
+          allow-hosts: .*
+      -
+        plug-attributes:
+          allow-ports: .*
 `
 
 // http://bazaar.launchpad.net/~ubuntu-security/ubuntu-core-security/trunk/view/head:/data/apparmor/policygroups/ubuntu-core/16.04/network
@@ -90,8 +116,107 @@ socket AF_NETLINK - NETLINK_ROUTE
 socket AF_CONN
 `
 
+type networkInterface struct {
+	commonInterface
+}
+
+// networkAttrList returns the elements of a list attribute of a network
+// plug, as strings.
+func networkAttrList(attrs interfaces.Attrer, name string) ([]string, error) {
+	value, ok := attrs.Lookup(name)
+	if !ok {
+		return nil, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("network plug requires %q to be a non-empty list", name)
+	}
+	elements := make([]string, 0, len(list))
+	for _, element := range list {
+		switch element := element.(type) {
+		case string:
+			elements = append(elements, element)
+		case int64:
+			if name != "allow-ports" {
+				return nil, fmt.Errorf("network plug requires %q to be a list of strings", name)
+			}
+			elements = append(elements, fmt.Sprintf("%d", element))
+		default:
+			return nil, fmt.Errorf("network plug requires %q to be a list of strings", name)
+		}
+	}
+	return elements, nil
+}
+
+// networkEgress returns the egress that a network plug is restricted to
+// with the "allow-hosts" and "allow-ports" attributes, or nil if it is not
+// restricted.
+func networkEgress(attrs interfaces.Attrer) (*nftables.Egress, error) {
+	hosts, err := networkAttrList(attrs, "allow-hosts")
+	if err != nil {
+		return nil, err
+	}
+	ports, err := networkAttrList(attrs, "allow-ports")
+	if err != nil {
+		return nil, err
+	}
+	if hosts == nil && ports == nil {
+		return nil, nil
+	}
+	egress := &nftables.Egress{Hosts: hosts, Ports: ports}
+	if err := egress.Validate(); err != nil {
+		return nil, fmt.Errorf("network plug has %v", err)
+	}
+	return egress, nil
+}
+
+// BeforePreparePlug checks the "allow-hosts" and "allow-ports" attributes
+// of a network plug. The egress is only filtered for system services, so a
+// plug restricting it cannot be bound to other apps or to hooks, which would
+// otherwise silently keep an unrestricted access to the network.
+func (iface *networkInterface) BeforePreparePlug(plug *snap.PlugInfo) error {
+	egress, err := networkEgress(plug)
+	if err != nil || egress == nil {
+		return err
+	}
+	appNames := make([]string, 0, len(plug.Apps))
+	for name := range plug.Apps {
+		appNames = append(appNames, name)
+	}
+	sort.Strings(appNames)
+	for _, name := range appNames {
+		app := plug.Apps[name]
+		if !app.IsService() || app.DaemonScope != snap.SystemDaemon {
+			return fmt.Errorf(`network plug with "allow-hosts" or "allow-ports" can only be used by system services, not by app %q`, name)
+		}
+	}
+	if len(plug.Hooks) > 0 {
+		hookNames := make([]string, 0, len(plug.Hooks))
+		for name := range plug.Hooks {
+			hookNames = append(hookNames, name)
+		}
+		sort.Strings(hookNames)
+		return fmt.Errorf(`network plug with "allow-hosts" or "allow-ports" can only be used by system services, not by hook %q`, hookNames[0])
+	}
+	return nil
+}
+
+func (iface *networkInterface) NftablesConnectedPlug(spec *nftables.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	egress, err := networkEgress(plug)
+	if err != nil {
+		return err
+	}
+	// the egress of services is only filtered when all their network
+	// plugs restrict it
+	if egress == nil {
+		spec.AllowAllEgress()
+		return nil
+	}
+	return spec.AllowEgress(egress)
+}
+
 func init() {
-	registerIface(&commonInterface{
+	registerIface(&networkInterface{commonInterface{
 		name:                  "network",
 		summary:               networkSummary,
 		implicitOnCore:        true,
@@ -99,5 +224,5 @@ func init() {
 		baseDeclarationSlots:  networkBaseDeclarationSlots,
 		connectedPlugAppArmor: networkConnectedPlugAppArmor,
 		connectedPlugSecComp:  networkConnectedPlugSecComp,
-	})
+	}})
 }
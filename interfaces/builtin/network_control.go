@@ -331,6 +331,11 @@ var networkControlConnectedPlugUDev = []string{
 	`KERNEL=="tun"`,
 }
 
+const networkControlConnectedPlugLandlock = `
+write /dev/rfkill
+write /dev/net/tun
+`
+
 var networkControlConnectedPlugMount = []osutil.MountEntry{{
 	Name:    "/var/lib/snapd/hostfs/var/lib/dhcp",
 	Dir:     "/var/lib/dhcp",
@@ -388,6 +393,7 @@ func init() {
 		connectedPlugAppArmor: networkControlConnectedPlugAppArmor,
 		connectedPlugSecComp:  networkControlConnectedPlugSecComp,
 		connectedPlugUDev:     networkControlConnectedPlugUDev,
+		connectedPlugLandlock: networkControlConnectedPlugLandlock,
 
 		connectedPlugMount:            networkControlConnectedPlugMount,
 		connectedPlugUpdateNSAppArmor: networkControlConnectedPlugUpdateNSAppArmor,
@@ -20,11 +20,14 @@
 package builtin_test
 
 import (
+	"regexp"
+
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/nftables"
 	"github.com/snapcore/snapd/interfaces/seccomp"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
@@ -75,6 +78,124 @@ func (s *NetworkInterfaceSuite) TestSanitizePlug(c *C) {
 	c.Assert(interfaces.BeforePreparePlug(s.iface, s.plugInfo), IsNil)
 }
 
+func (s *NetworkInterfaceSuite) TestSanitizePlugEgress(c *C) {
+	const mockSnapYaml = `name: other
+version: 1.0
+plugs:
+ network:
+  allow-hosts: [192.0.2.1, 2001:db8::/32]
+  allow-ports: [443, 53/udp, "8000-8080/tcp"]
+apps:
+ app2:
+  command: foo
+  daemon: simple
+  plugs: [network]
+`
+	info := snaptest.MockInfo(c, mockSnapYaml, nil)
+	c.Assert(interfaces.BeforePreparePlug(s.iface, info.Plugs["network"]), IsNil)
+}
+
+func (s *NetworkInterfaceSuite) TestSanitizePlugEgressErrors(c *C) {
+	for _, t := range []struct {
+		attrs string
+		err   string
+	}{
+		{`allow-hosts: 192.0.2.1`, `network plug requires "allow-hosts" to be a non-empty list`},
+		{`allow-hosts: []`, `network plug requires "allow-hosts" to be a non-empty list`},
+		{`allow-hosts: [1]`, `network plug requires "allow-hosts" to be a list of strings`},
+		{`allow-hosts: [example.com]`, `network plug has invalid host "example.com": must be an IP address or a network in CIDR notation`},
+		{`allow-hosts: [192.0.2.0/33]`, `network plug has invalid host "192.0.2.0/33": not a valid network in CIDR notation`},
+		{`allow-ports: [true]`, `network plug requires "allow-ports" to be a list of strings`},
+		{`allow-ports: [0]`, `network plug has invalid port "0": port numbers must be between 1 and 65535`},
+		{`allow-ports: [443/icmp]`, `network plug has invalid port "443/icmp": protocol must be tcp or udp`},
+		{`allow-ports: [8080-8000]`, `network plug has invalid port "8080-8000": range must not end before it starts`},
+	} {
+		mockSnapYaml := `name: other
+version: 1.0
+plugs:
+ network:
+  ` + t.attrs + `
+apps:
+ app2:
+  command: foo
+  daemon: simple
+  plugs: [network]
+`
+		info := snaptest.MockInfo(c, mockSnapYaml, nil)
+		c.Check(interfaces.BeforePreparePlug(s.iface, info.Plugs["network"]), ErrorMatches, regexp.QuoteMeta(t.err), Commentf(t.attrs))
+	}
+}
+
+func (s *NetworkInterfaceSuite) TestSanitizePlugEgressNotSystemService(c *C) {
+	for _, t := range []struct {
+		apps string
+		err  string
+	}{
+		{`
+ app2:
+  command: foo
+  plugs: [network]
+`, `network plug with "allow-hosts" or "allow-ports" can only be used by system services, not by app "app2"`},
+		{`
+ app1:
+  command: foo
+  daemon: simple
+  plugs: [network]
+ app2:
+  command: foo
+  daemon: simple
+  daemon-scope: user
+  plugs: [network]
+`, `network plug with "allow-hosts" or "allow-ports" can only be used by system services, not by app "app2"`},
+		{`
+ app1:
+  command: foo
+  daemon: simple
+  plugs: [network]
+hooks:
+ configure:
+  plugs: [network]
+`, `network plug with "allow-hosts" or "allow-ports" can only be used by system services, not by hook "configure"`},
+	} {
+		mockSnapYaml := `name: other
+version: 1.0
+plugs:
+ network:
+  allow-ports: [443]
+apps:` + t.apps
+		info := snaptest.MockInfo(c, mockSnapYaml, nil)
+		c.Check(interfaces.BeforePreparePlug(s.iface, info.Plugs["network"]), ErrorMatches, regexp.QuoteMeta(t.err), Commentf(t.apps))
+	}
+}
+
+func (s *NetworkInterfaceSuite) TestNftablesConnectedPlug(c *C) {
+	// an unrestricted plug allows any egress
+	spec := &nftables.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Check(spec.SecurityTags(), HasLen, 0)
+
+	const mockSnapYaml = `name: other
+version: 1.0
+plugs:
+ network:
+  allow-hosts: [192.0.2.1]
+  allow-ports: [443/tcp]
+apps:
+ app2:
+  command: foo
+  daemon: simple
+  plugs: [network]
+`
+	info := snaptest.MockInfo(c, mockSnapYaml, nil)
+	plug := interfaces.NewConnectedPlug(info.Plugs["network"], nil, nil)
+	spec = &nftables.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, plug, s.slot), IsNil)
+	c.Check(spec.SecurityTags(), DeepEquals, []string{"snap.other.app2"})
+	c.Check(spec.RulesForTag("snap.other.app2"), DeepEquals, []string{
+		"ip daddr 192.0.2.1 tcp dport 443 accept",
+	})
+}
+
 func (s *NetworkInterfaceSuite) TestUsedSecuritySystems(c *C) {
 	// connected plugs have a non-nil security snippet for apparmor
 	apparmorSpec := &apparmor.Specification{}
@@ -184,6 +184,23 @@ var openglConnectedPlugUDev = []string{
 	`KERNEL=="galcore"`,
 }
 
+const openglConnectedPlugLandlock = `
+read /dev/dri
+write /dev/dri/card[0-9]*
+write /dev/dri/renderD[0-9]*
+write /dev/nvidia*
+write /dev/vchiq
+write /dev/vcsm-cma
+write /dev/nvhost-*
+write /dev/nvmap
+write /dev/tegra_dc_ctrl
+write /dev/tegra_dc_[0-9]*
+write /dev/pvr_sync
+write /dev/mali[0-9]*
+write /dev/dma_buf_te
+write /dev/galcore
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "opengl",
@@ -193,5 +210,6 @@ func init() {
 		baseDeclarationSlots:  openglBaseDeclarationSlots,
 		connectedPlugAppArmor: openglConnectedPlugAppArmor,
 		connectedPlugUDev:     openglConnectedPlugUDev,
+		connectedPlugLandlock: openglConnectedPlugLandlock,
 	})
 }
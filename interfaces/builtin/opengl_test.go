@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
@@ -86,6 +87,17 @@ func (s *OpenglInterfaceSuite) TestAppArmorSpec(c *C) {
 	c.Assert(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, `/dev/galcore rw,`)
 }
 
+func (s *OpenglInterfaceSuite) TestLandlockSpec(c *C) {
+	spec := &landlock.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	rules := spec.RulesForTag("snap.consumer.app")
+	c.Check(rules[0], Equals, "read /dev/dri")
+	c.Check(rules, testutil.Contains, "write /dev/nvidia*")
+	c.Check(rules, testutil.Contains, "write /dev/dri/renderD[0-9]*")
+	c.Check(rules, testutil.Contains, "write /dev/galcore")
+}
+
 func (s *OpenglInterfaceSuite) TestUDevSpec(c *C) {
 	spec := &udev.Specification{}
 	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
@@ -24,6 +24,7 @@ import (
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/snap"
 )
 
@@ -62,6 +63,18 @@ var opticalDriveConnectedPlugUDev = []string{
 	`SUBSYSTEM=="scsi_generic", SUBSYSTEMS=="scsi", ATTRS{type}=="4|5"`,
 }
 
+const opticalDriveConnectedPlugLandlock = `
+read /dev/sr[0-9]*
+read /dev/scd[0-9]*
+read /dev/sg[0-9]*
+`
+
+const opticalDriveConnectedPlugLandlockWrite = `
+write /dev/sr[0-9]*
+write /dev/scd[0-9]*
+write /dev/sg[0-9]*
+`
+
 // opticalDriveInterface is the type for optical drive interfaces.
 type opticalDriveInterface struct {
 	commonInterface
@@ -99,6 +112,17 @@ func (iface *opticalDriveInterface) AppArmorConnectedPlug(spec *apparmor.Specifi
 	return nil
 }
 
+func (iface *opticalDriveInterface) LandlockConnectedPlug(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	var write bool
+	_ = plug.Attr("write", &write)
+
+	// 'write: true' grants write access to the devices
+	if write {
+		return spec.AddSnippet(opticalDriveConnectedPlugLandlockWrite)
+	}
+	return spec.AddSnippet(opticalDriveConnectedPlugLandlock)
+}
+
 func init() {
 	registerIface(&opticalDriveInterface{commonInterface: commonInterface{
 		name:                 "optical-drive",
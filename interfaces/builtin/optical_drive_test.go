@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
@@ -143,6 +144,19 @@ func (s *OpticalDriveInterfaceSuite) TestAppArmorSpec(c *C) {
 	})
 }
 
+func (s *OpticalDriveInterfaceSuite) TestLandlockSpec(c *C) {
+	spec := &landlock.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.testPlugDefault, s.slot), IsNil)
+	c.Assert(spec.AddConnectedPlug(s.iface, s.testPlugReadonly, s.slot), IsNil)
+	c.Assert(spec.AddConnectedPlug(s.iface, s.testPlugWritable, s.slot), IsNil)
+	readonly := []string{"read /dev/sr[0-9]*", "read /dev/scd[0-9]*", "read /dev/sg[0-9]*"}
+	c.Check(spec.Rules(), DeepEquals, map[string][]string{
+		"snap.consumer.app":          readonly,
+		"snap.consumer.app-readonly": readonly,
+		"snap.consumer.app-writable": {"write /dev/sr[0-9]*", "write /dev/scd[0-9]*", "write /dev/sg[0-9]*"},
+	})
+}
+
 func (s *OpticalDriveInterfaceSuite) TestUDevSpec(c *C) {
 	spec := &udev.Specification{}
 	c.Assert(spec.AddConnectedPlug(s.iface, s.testPlugDefault, s.slot), IsNil)
@@ -27,6 +27,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/testutil"
@@ -87,6 +88,20 @@ owner "@{HOME}/.write-file{,/,/**}" rwkl,
 `)
 }
 
+func (s *personalFilesInterfaceSuite) TestConnectedPlugLandlock(c *C) {
+	landlockSpec := &landlock.Specification{}
+	err := landlockSpec.AddConnectedPlug(s.iface, s.plug, s.slot)
+	c.Assert(err, IsNil)
+	c.Check(landlockSpec.Rules(), DeepEquals, map[string][]string{
+		"snap.other.app": {
+			"read $SNAP_REAL_HOME/.read-dir",
+			"read $SNAP_REAL_HOME/.read-file",
+			"write $SNAP_REAL_HOME/.write-dir",
+			"write $SNAP_REAL_HOME/.write-file",
+		},
+	})
+}
+
 func (s *personalFilesInterfaceSuite) TestSanitizeSlot(c *C) {
 	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slotInfo), IsNil)
 }
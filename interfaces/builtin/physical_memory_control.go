@@ -43,6 +43,10 @@ capability sys_rawio,
 
 var physicalMemoryControlConnectedPlugUDev = []string{`KERNEL=="mem"`}
 
+const physicalMemoryControlConnectedPlugLandlock = `
+write /dev/mem
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "physical-memory-control",
@@ -52,5 +56,6 @@ func init() {
 		baseDeclarationSlots:  physicalMemoryControlBaseDeclarationSlots,
 		connectedPlugAppArmor: physicalMemoryControlConnectedPlugAppArmor,
 		connectedPlugUDev:     physicalMemoryControlConnectedPlugUDev,
+		connectedPlugLandlock: physicalMemoryControlConnectedPlugLandlock,
 	})
 }
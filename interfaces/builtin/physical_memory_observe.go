@@ -39,6 +39,10 @@ const physicalMemoryObserveConnectedPlugAppArmor = `
 
 var physicalMemoryObserveConnectedPlugUDev = []string{`KERNEL=="mem"`}
 
+const physicalMemoryObserveConnectedPlugLandlock = `
+read /dev/mem
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "physical-memory-observe",
@@ -48,5 +52,6 @@ func init() {
 		baseDeclarationSlots:  physicalMemoryObserveBaseDeclarationSlots,
 		connectedPlugAppArmor: physicalMemoryObserveConnectedPlugAppArmor,
 		connectedPlugUDev:     physicalMemoryObserveConnectedPlugUDev,
+		connectedPlugLandlock: physicalMemoryObserveConnectedPlugLandlock,
 	})
 }
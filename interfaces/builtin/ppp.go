@@ -63,6 +63,11 @@ var pppConnectedPlugUDev = []string{
 	`KERNEL=="tty[a-zA-Z]*[0-9]*"`,
 }
 
+const pppConnectedPlugLandlock = `
+write /dev/ppp
+write /dev/tty[^0-9]*
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                     "ppp",
@@ -73,5 +78,6 @@ func init() {
 		connectedPlugAppArmor:    pppConnectedPlugAppArmor,
 		connectedPlugKModModules: pppConnectedPlugKmod,
 		connectedPlugUDev:        pppConnectedPlugUDev,
+		connectedPlugLandlock:    pppConnectedPlugLandlock,
 	})
 }
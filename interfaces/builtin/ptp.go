@@ -42,6 +42,10 @@ var ptpConnectedPlugUDev = []string{
 	`SUBSYSTEM=="ptp", KERNEL=="ptp[0-9]*"`,
 }
 
+const ptpConnectedPlugLandlock = `
+write /dev/ptp[0-9]*
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "ptp",
@@ -51,5 +55,6 @@ func init() {
 		baseDeclarationSlots:  ptpBaseDeclarationSlots,
 		connectedPlugAppArmor: ptpConnectedPlugAppArmor,
 		connectedPlugUDev:     ptpConnectedPlugUDev,
+		connectedPlugLandlock: ptpConnectedPlugLandlock,
 	})
 }
@@ -63,6 +63,10 @@ var rawInputConnectedPlugUDev = []string{
 	`KERNEL=="ts[0-9]*"`,
 }
 
+const rawInputConnectedPlugLandlock = `
+write /dev/input
+`
+
 type rawInputInterface struct {
 	commonInterface
 }
@@ -82,5 +86,6 @@ func init() {
 		connectedPlugSecComp:  rawInputConnectedPlugSecComp,
 		connectedPlugAppArmor: rawInputConnectedPlugAppArmor,
 		connectedPlugUDev:     rawInputConnectedPlugUDev,
+		connectedPlugLandlock: rawInputConnectedPlugLandlock,
 	}})
 }
@@ -19,6 +19,19 @@
 
 package builtin
 
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/interfaces/seccomp"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+)
+
 const rawusbSummary = `allows raw access to all USB devices`
 
 const rawusbBaseDeclarationSlots = `
@@ -40,7 +53,15 @@ const rawusbConnectedPlugAppArmor = `
 
 # Allow raw access to USB printers (i.e. for receipt printers in POS systems).
 /dev/usb/lp[0-9]* rwk,
+` + rawusbDetectionConnectedPlugAppArmor
+
+// Slots created by hotplug grant raw access to a single USB device only.
+const rawusbDeviceConnectedPlugAppArmor = `
+# Description: Allow raw access to a single USB device.
+%s rw,
+` + rawusbDetectionConnectedPlugAppArmor
 
+const rawusbDetectionConnectedPlugAppArmor = `
 # Allow detection of usb devices. Leaks plugged in USB device info
 /sys/bus/usb/devices/ r,
 /sys/devices/pci**/usb[0-9]** r,
@@ -56,9 +77,6 @@ const rawusbConnectedPlugAppArmor = `
 const rawusbConnectedPlugSecComp = `
 # Description: Allow raw access to all connected USB devices.
 # This gives privileged access to the system.
-
-# kernel uevents
-socket AF_NETLINK - NETLINK_KOBJECT_UEVENT
 `
 
 var rawusbConnectedPlugUDev = []string{
@@ -67,8 +85,77 @@ var rawusbConnectedPlugUDev = []string{
 	`SUBSYSTEM=="tty", ENV{ID_BUS}=="usb"`,
 }
 
+const rawusbConnectedPlugLandlock = `
+write /dev/bus/usb/[0-9][0-9][0-9]/[0-9][0-9][0-9]
+write /dev/ttyUSB[0-9]*
+write /dev/ttyACM[0-9]*
+write /dev/usb/lp[0-9]*
+`
+
+// Pattern to match the device nodes of USB devices, for which slots are
+// created by hotplug.
+var rawusbDeviceNodePattern = regexp.MustCompile("^/dev/bus/usb/[0-9]{3}/[0-9]{3}$")
+
+type rawusbInterface struct {
+	commonInterface
+}
+
+// BeforePrepareSlot checks the path attribute of slots created by hotplug.
+func (iface *rawusbInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
+	_, err := hotplugSlotDevicePath(&interfaces.SlotRef{Snap: slot.Snap.InstanceName(), Name: slot.Name}, slot, rawusbDeviceNodePattern)
+	return err
+}
+
+func (iface *rawusbInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	path, err := hotplugSlotDevicePath(slot.Ref(), slot, rawusbDeviceNodePattern)
+	if err != nil {
+		return nil
+	}
+	if path == "" {
+		return iface.commonInterface.AppArmorConnectedPlug(spec, plug, slot)
+	}
+	spec.AddSnippet(fmt.Sprintf(rawusbDeviceConnectedPlugAppArmor, path))
+	return nil
+}
+
+func (iface *rawusbInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	path, err := hotplugSlotDevicePath(slot.Ref(), slot, rawusbDeviceNodePattern)
+	if err != nil {
+		return nil
+	}
+	if path == "" {
+		return iface.commonInterface.UDevConnectedPlug(spec, plug, slot)
+	}
+	spec.TagDevice(fmt.Sprintf(`SUBSYSTEM=="usb", ENV{DEVNAME}=="%s"`, path))
+	return nil
+}
+
+func (iface *rawusbInterface) SecCompConnectedPlug(spec *seccomp.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	if err := iface.commonInterface.SecCompConnectedPlug(spec, plug, slot); err != nil {
+		return err
+	}
+	// kernel uevents
+	return spec.AllowSocket("AF_NETLINK", "NETLINK_KOBJECT_UEVENT")
+}
+
+func (iface *rawusbInterface) HotplugDeviceDetected(di *hotplug.HotplugDeviceInfo) (*hotplug.ProposedSlot, error) {
+	if di.Subsystem() != "usb" || di.DeviceType() != "usb_device" || !rawusbDeviceNodePattern.MatchString(di.DeviceName()) {
+		return nil, nil
+	}
+	// TYPE is the class/subclass/protocol of the device, hubs (class 9)
+	// are of no use to applications
+	if devType, _ := di.Attribute("TYPE"); strings.HasPrefix(devType, "9/") {
+		return nil, nil
+	}
+	return &hotplug.ProposedSlot{
+		Attrs: map[string]interface{}{
+			"path": di.DeviceName(),
+		},
+	}, nil
+}
+
 func init() {
-	registerIface(&commonInterface{
+	registerIface(&rawusbInterface{commonInterface{
 		name:                  "raw-usb",
 		summary:               rawusbSummary,
 		implicitOnCore:        true,
@@ -77,5 +164,6 @@ func init() {
 		connectedPlugAppArmor: rawusbConnectedPlugAppArmor,
 		connectedPlugSecComp:  rawusbConnectedPlugSecComp,
 		connectedPlugUDev:     rawusbConnectedPlugUDev,
-	})
+		connectedPlugLandlock: rawusbConnectedPlugLandlock,
+	}})
 }
@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/seccomp"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
@@ -88,7 +89,7 @@ func (s *RawUsbInterfaceSuite) TestSecCompSpec(c *C) {
 	spec := &seccomp.Specification{}
 	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
 	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
-	c.Assert(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, `socket AF_NETLINK - NETLINK_KOBJECT_UEVENT`)
+	c.Assert(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "socket AF_NETLINK - NETLINK_KOBJECT_UEVENT\n")
 }
 
 func (s *RawUsbInterfaceSuite) TestUDevSpec(c *C) {
@@ -119,3 +120,66 @@ func (s *RawUsbInterfaceSuite) TestAutoConnect(c *C) {
 func (s *RawUsbInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
+
+const rawusbHotplugCoreYaml = `name: core
+version: 0
+type: os
+slots:
+  label-printer:
+    interface: raw-usb
+    path: /dev/bus/usb/001/004
+  bad-path:
+    interface: raw-usb
+    path: /dev/bus/usb/001/../004
+`
+
+func (s *RawUsbInterfaceSuite) TestSanitizeHotplugSlot(c *C) {
+	slot := MockSlot(c, rawusbHotplugCoreYaml, nil, "label-printer")
+	c.Check(interfaces.BeforePrepareSlot(s.iface, slot), IsNil)
+	slot = MockSlot(c, rawusbHotplugCoreYaml, nil, "bad-path")
+	c.Check(interfaces.BeforePrepareSlot(s.iface, slot), ErrorMatches, `cannot use slot "core:bad-path" path "/dev/bus/usb/001/../004": try "/dev/bus/usb/004""`)
+}
+
+func (s *RawUsbInterfaceSuite) TestHotplugSlotSpecs(c *C) {
+	slot, _ := MockConnectedSlot(c, rawusbHotplugCoreYaml, nil, "label-printer")
+
+	apparmorSpec := &apparmor.Specification{}
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Check(apparmorSpec.SnippetForTag("snap.consumer.app"), testutil.Contains, "/dev/bus/usb/001/004 rw,")
+	c.Check(apparmorSpec.SnippetForTag("snap.consumer.app"), testutil.Contains, "/run/udev/data/+usb:* r,")
+	c.Check(apparmorSpec.SnippetForTag("snap.consumer.app"), Not(testutil.Contains), "/dev/bus/usb/[0-9][0-9][0-9]/[0-9][0-9][0-9] rw,")
+
+	udevSpec := &udev.Specification{}
+	c.Assert(udevSpec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Assert(udevSpec.Snippets(), HasLen, 2)
+	c.Check(udevSpec.Snippets(), testutil.Contains, `# raw-usb
+SUBSYSTEM=="usb", ENV{DEVNAME}=="/dev/bus/usb/001/004", TAG+="snap_consumer_app"`)
+
+	// the seccomp rules are the same as for the implicit slot
+	seccompSpec := &seccomp.Specification{}
+	c.Assert(seccompSpec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Check(seccompSpec.SnippetForTag("snap.consumer.app"), testutil.Contains, "NETLINK_KOBJECT_UEVENT")
+}
+
+func (s *RawUsbInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/bus/usb/001/004", "DEVTYPE": "usb_device", "TYPE": "0/0/0", "ACTION": "add", "SUBSYSTEM": "usb"})
+	c.Assert(err, IsNil)
+	proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Check(proposedSlot, DeepEquals, &hotplug.ProposedSlot{Attrs: map[string]interface{}{"path": "/dev/bus/usb/001/004"}})
+
+	// hubs are ignored
+	di, err = hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/bus/usb/001/002", "DEVTYPE": "usb_device", "TYPE": "9/0/1", "ACTION": "add", "SUBSYSTEM": "usb"})
+	c.Assert(err, IsNil)
+	proposedSlot, err = hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Check(proposedSlot, IsNil)
+
+	// and so are the interfaces of devices
+	di, err = hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVTYPE": "usb_interface", "ACTION": "add", "SUBSYSTEM": "usb"})
+	c.Assert(err, IsNil)
+	proposedSlot, err = hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Check(proposedSlot, IsNil)
+}
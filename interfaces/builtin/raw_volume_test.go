@@ -29,6 +29,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
@@ -333,6 +334,16 @@ func (s *rawVolumeInterfaceSuite) TestAppArmorSpec(c *C) {
 	c.Assert(spec.SnippetForTag("snap.client-snap.app-accessing-3-part"), testutil.Contains, `capability sys_admin,`)
 }
 
+func (s *rawVolumeInterfaceSuite) TestLandlockSpec(c *C) {
+	spec := &landlock.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.testPlugPart1, s.testUDev1), IsNil)
+	c.Assert(spec.AddConnectedPlug(s.iface, s.testPlugPart2, s.testUDev2), IsNil)
+	c.Check(spec.Rules(), DeepEquals, map[string][]string{
+		"snap.client-snap.app-accessing-1-part": {"write /dev/vda1"},
+		"snap.client-snap.app-accessing-2-part": {"write /dev/mmcblk0p1"},
+	})
+}
+
 func (s *rawVolumeInterfaceSuite) TestStaticInfo(c *C) {
 	si := interfaces.StaticInfoOf(s.iface)
 	c.Assert(si.ImplicitOnCore, Equals, false)
@@ -19,6 +19,11 @@
 
 package builtin
 
+import (
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/landlock"
+)
+
 const removableMediaSummary = `allows access to mounted removable storage`
 
 const removableMediaBaseDeclarationSlots = `
@@ -49,13 +54,29 @@ const removableMediaConnectedPlugAppArmor = `
 /mnt/** mrwklix,
 `
 
+// removableMediaInterface only has the implicit slot of the system snap. It
+// does not create slots for USB disks as they are plugged in, as where their
+// filesystems will be mounted is not known at that time, so such slots would
+// grant the access to all removable media the implicit slot grants. USB disks
+// get a block-devices slot for the device itself instead.
+type removableMediaInterface struct {
+	commonInterface
+}
+
+func (iface *removableMediaInterface) LandlockConnectedPlug(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	spec.AllowWrite("/media")
+	spec.AllowWrite("/run/media")
+	spec.AllowWrite("/mnt")
+	return nil
+}
+
 func init() {
-	registerIface(&commonInterface{
+	registerIface(&removableMediaInterface{commonInterface{
 		name:                  "removable-media",
 		summary:               removableMediaSummary,
 		implicitOnCore:        true,
 		implicitOnClassic:     true,
 		baseDeclarationSlots:  removableMediaBaseDeclarationSlots,
 		connectedPlugAppArmor: removableMediaConnectedPlugAppArmor,
-	})
+	}})
 }
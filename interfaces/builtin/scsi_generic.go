@@ -45,6 +45,10 @@ var scsiGenericConnectedPlugUDev = []string{
 	`KERNEL=="sg[0-9]*"`,
 }
 
+const scsiGenericConnectedPlugLandlock = `
+write /dev/sg[0-9]*
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "scsi-generic",
@@ -54,5 +58,6 @@ func init() {
 		baseDeclarationSlots:  scsiGenericBaseDeclarationSlots,
 		connectedPlugAppArmor: scsiGenericConnectedPlugAppArmor,
 		connectedPlugUDev:     scsiGenericConnectedPlugUDev,
+		connectedPlugLandlock: scsiGenericConnectedPlugLandlock,
 	})
 }
@@ -29,6 +29,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 )
@@ -177,6 +178,22 @@ func (iface *serialPortInterface) AppArmorConnectedPlug(spec *apparmor.Specifica
 	return nil
 }
 
+func (iface *serialPortInterface) LandlockConnectedPlug(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	if iface.hasUsbAttrs(slot) {
+		// as with AppArmor, udev tagging and device cgroups restrict
+		// down to the specific device
+		spec.AllowWrite("/dev/tty[A-Z]*[0-9]")
+		return nil
+	}
+
+	var path string
+	if err := slot.Attr("path", &path); err != nil {
+		return nil
+	}
+	spec.AllowWrite(filepath.Clean(path))
+	return nil
+}
+
 func (iface *serialPortInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
 	// For connected plugs, we use vendor and product ids if available,
 	// otherwise add the kernel device
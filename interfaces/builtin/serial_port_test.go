@@ -29,6 +29,7 @@ import (
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
 	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
@@ -589,6 +590,17 @@ func (s *SerialPortInterfaceSuite) TestConnectedPlugAppArmorSnippets(c *C) {
 	checkConnectedPlugSnippet(s.testPlugPort2, s.testUDev3, expectedSnippet102)
 }
 
+func (s *SerialPortInterfaceSuite) TestConnectedPlugLandlock(c *C) {
+	checkConnectedPlugRules := func(slot *interfaces.ConnectedSlot, expectedRules []string) {
+		spec := &landlock.Specification{}
+		c.Assert(spec.AddConnectedPlug(s.iface, s.testPlugPort1, slot), IsNil)
+		c.Check(spec.RulesForTag("snap.client-snap.app-accessing-2-ports"), DeepEquals, expectedRules)
+	}
+	checkConnectedPlugRules(s.testSlot1, []string{"write /dev/ttyS0"})
+	checkConnectedPlugRules(s.testSlot2, []string{"write /dev/ttyUSB927"})
+	checkConnectedPlugRules(s.testUDev1, []string{"write /dev/tty[A-Z]*[0-9]"})
+}
+
 func (s *SerialPortInterfaceSuite) TestConnectedPlugUDevSnippetsForPath(c *C) {
 	checkConnectedPlugSnippet := func(plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot, expectedSnippet string, expectedExtraSnippet string) {
 		udevSpec := &udev.Specification{}
@@ -27,6 +27,7 @@ import (
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 )
@@ -91,6 +92,15 @@ func (iface *spiInterface) AppArmorConnectedPlug(spec *apparmor.Specification, p
 	return nil
 }
 
+func (iface *spiInterface) LandlockConnectedPlug(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	path, err := iface.path(slot.Ref(), slot)
+	if err != nil {
+		return nil
+	}
+	spec.AllowWrite(path)
+	return nil
+}
+
 func (iface *spiInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
 	path, err := iface.path(slot.Ref(), slot)
 	if err != nil {
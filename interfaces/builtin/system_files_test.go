@@ -27,6 +27,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/testutil"
@@ -86,6 +87,20 @@ func (s *systemFilesInterfaceSuite) TestConnectedPlugAppArmor(c *C) {
 `)
 }
 
+func (s *systemFilesInterfaceSuite) TestConnectedPlugLandlock(c *C) {
+	landlockSpec := &landlock.Specification{}
+	err := landlockSpec.AddConnectedPlug(s.iface, s.plug, s.slot)
+	c.Assert(err, IsNil)
+	c.Check(landlockSpec.Rules(), DeepEquals, map[string][]string{
+		"snap.other.app": {
+			"read /etc/read-dir2",
+			"read /etc/read-file2",
+			"write /etc/write-dir2",
+			"write /etc/write-file2",
+		},
+	})
+}
+
 func (s *systemFilesInterfaceSuite) TestSanitizeSlot(c *C) {
 	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slotInfo), IsNil)
 }
@@ -168,6 +183,10 @@ apps:
 	apparmorSpec := &apparmor.Specification{}
 	err := apparmorSpec.AddConnectedPlug(s.iface, s.plug, s.slot)
 	c.Assert(err, ErrorMatches, `cannot connect plug system-files: 123 \(int64\) is not a string`)
+
+	landlockSpec := &landlock.Specification{}
+	err = landlockSpec.AddConnectedPlug(s.iface, s.plug, s.slot)
+	c.Assert(err, ErrorMatches, `cannot connect plug system-files: 123 \(int64\) is not a string`)
 }
 
 func (s *systemFilesInterfaceSuite) TestInterfaces(c *C) {
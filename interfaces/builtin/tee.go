@@ -52,6 +52,12 @@ var teeConnectedPlugUDev = []string{
 	`KERNEL=="qseecom"`,
 }
 
+const teeConnectedPlugLandlock = `
+write /dev/tee[0-9]*
+write /dev/teepriv[0-9]*
+write /dev/qseecom
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "tee",
@@ -62,5 +68,6 @@ func init() {
 		baseDeclarationPlugs:  teeBaseDeclarationPlugs,
 		connectedPlugAppArmor: teeConnectedPlugAppArmor,
 		connectedPlugUDev:     teeConnectedPlugUDev,
+		connectedPlugLandlock: teeConnectedPlugLandlock,
 	})
 }
@@ -133,6 +133,11 @@ var timeControlConnectedPlugUDev = []string{
 	`KERNEL=="pps[0-9]*"`,
 }
 
+const timeControlConnectedPlugLandlock = `
+write /dev/rtc[0-9]*
+write /dev/pps[0-9]*
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "time-control",
@@ -143,5 +148,6 @@ func init() {
 		connectedPlugAppArmor: timeControlConnectedPlugAppArmor,
 		connectedPlugSecComp:  timeControlConnectedPlugSecComp,
 		connectedPlugUDev:     timeControlConnectedPlugUDev,
+		connectedPlugLandlock: timeControlConnectedPlugLandlock,
 	})
 }
@@ -42,6 +42,11 @@ var tpmConnectedPlugUDev = []string{
 	`KERNEL=="tpmrm[0-9]*"`,
 }
 
+const tpmConnectedPlugLandlock = `
+write /dev/tpm[0-9]*
+write /dev/tpmrm[0-9]*
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "tpm",
@@ -51,5 +56,6 @@ func init() {
 		baseDeclarationSlots:  tpmBaseDeclarationSlots,
 		connectedPlugAppArmor: tpmConnectedPlugAppArmor,
 		connectedPlugUDev:     tpmConnectedPlugUDev,
+		connectedPlugLandlock: tpmConnectedPlugLandlock,
 	})
 }
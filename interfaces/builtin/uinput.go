@@ -63,6 +63,11 @@ const uinputConnectedPlugAppArmor = `
 // https://forum.snapcraft.io/t/multiple-users-and-groups-in-snaps/1461.
 var uinputConnectedPlugUDev = []string{`KERNEL=="uinput"`}
 
+const uinputConnectedPlugLandlock = `
+write /dev/uinput
+write /dev/input/uinput
+`
+
 type uinputInterface struct {
 	commonInterface
 }
@@ -77,5 +82,6 @@ func init() {
 		baseDeclarationSlots:  uinputBaseDeclarationSlots,
 		connectedPlugAppArmor: uinputConnectedPlugAppArmor,
 		connectedPlugUDev:     uinputConnectedPlugUDev,
+		connectedPlugLandlock: uinputConnectedPlugLandlock,
 	}})
 }
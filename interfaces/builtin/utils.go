@@ -21,6 +21,7 @@ package builtin
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"path/filepath"
 	"regexp"
@@ -28,6 +29,7 @@ import (
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 )
@@ -127,6 +129,47 @@ func verifySlotPathAttribute(slotRef *interfaces.SlotRef, attrs interfaces.Attre
 	return cleanPath, nil
 }
 
+// hotplugSlotDevicePath returns the path of the device node a slot created
+// by hotplug is for. Slots without a path attribute, like the implicit slots
+// of the system snap, are not tied to a single device and an empty path is
+// returned for them.
+func hotplugSlotDevicePath(slotRef *interfaces.SlotRef, attrs interfaces.Attrer, reg *regexp.Regexp) (string, error) {
+	if _, ok := attrs.Lookup("path"); !ok {
+		return "", nil
+	}
+	return verifySlotPathAttribute(slotRef, attrs, reg, invalidDeviceNodeSlotPathErrFmt)
+}
+
+// usbInterfaceHotplugKeyVersion is the version of the keys computed by
+// usbInterfaceHotplugKey, any change to the attributes they are computed
+// from requires a new version.
+const usbInterfaceHotplugKeyVersion = 0
+
+// usbInterfaceHotplugKey returns the hotplug key of a device node that
+// belongs to an interface of a USB device. Such devices may expose several
+// nodes of the same kind, one per interface, which the default key computed
+// from the vendor, model and serial of the device cannot tell apart. An empty
+// key, meaning that the default one is used, is returned for other devices.
+//
+// Like the default keys, the key is the version followed by the sha256
+// checksum of the attributes.
+func usbInterfaceHotplugKey(di *hotplug.HotplugDeviceInfo) (snap.HotplugKey, error) {
+	attrs := []string{"ID_VENDOR_ID", "ID_MODEL_ID", "ID_USB_INTERFACE_NUM", "ID_SERIAL"}
+	key := sha256.New()
+	for i, attr := range attrs {
+		val, ok := di.Attribute(attr)
+		// the serial is optional
+		if (!ok || val == "") && i < 3 {
+			return "", nil
+		}
+		key.Write([]byte(attr))
+		key.Write([]byte{0})
+		key.Write([]byte(val))
+		key.Write([]byte{0})
+	}
+	return snap.HotplugKey(fmt.Sprintf("%x%x", usbInterfaceHotplugKeyVersion, key.Sum(nil))), nil
+}
+
 // aareExclusivePatterns takes a string and generates deny alternations. Eg,
 // aareExclusivePatterns("foo") returns:
 // []string{
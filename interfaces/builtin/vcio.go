@@ -52,6 +52,10 @@ var vcioConnectedPlugUDev = []string{
 	`SUBSYSTEM=="bcm2708_vcio", KERNEL=="vcio"`,
 }
 
+const vcioConnectedPlugLandlock = `
+write /dev/vcio
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "vcio",
@@ -61,5 +65,6 @@ func init() {
 		baseDeclarationSlots:  vcioBaseDeclarationSlots,
 		connectedPlugAppArmor: vcioConnectedPlugAppArmor,
 		connectedPlugUDev:     vcioConnectedPlugUDev,
+		connectedPlugLandlock: vcioConnectedPlugLandlock,
 	})
 }
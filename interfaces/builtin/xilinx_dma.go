@@ -56,6 +56,16 @@ var xilinxDmaConnectedPlugUDev = []string{
 	`SUBSYSTEM=="xdma"`,
 }
 
+const xilinxDmaConnectedPlugLandlock = `
+write /dev/xdma[0-9]*_c2h_[0-9]*
+write /dev/xdma[0-9]*_h2c_[0-9]*
+write /dev/xdma[0-9]*_events_[0-9]*
+write /dev/xdma[0-9]*_control
+write /dev/xdma[0-9]*_user
+write /dev/xdma[0-9]*_xvc
+write /dev/xdma
+`
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "xilinx-dma",
@@ -66,5 +76,6 @@ func init() {
 		baseDeclarationSlots:  xilinxDmaBaseDeclarationSlots,
 		connectedPlugAppArmor: xilinxDmaConnectedPlugAppArmor,
 		connectedPlugUDev:     xilinxDmaConnectedPlugUDev,
+		connectedPlugLandlock: xilinxDmaConnectedPlugLandlock,
 	})
 }
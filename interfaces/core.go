@@ -277,6 +277,16 @@ type Specification interface {
 	AddConnectedPlug(iface Interface, plug *ConnectedPlug, slot *ConnectedSlot) error
 }
 
+// InspectableSpecification is implemented by specifications that can
+// present what was recorded in them as snippets, for inspection.
+type InspectableSpecification interface {
+	Specification
+	// InspectSnippets returns the recorded snippets indexed by the
+	// security tag they apply to, or by "" for those that apply to the
+	// whole snap.
+	InspectSnippets() map[string][]string
+}
+
 // SecuritySystem is a name of a security system.
 type SecuritySystem string
 
@@ -297,6 +307,10 @@ const (
 	SecuritySystemd SecuritySystem = "systemd"
 	// SecurityPolkit identifies the polkit security system.
 	SecurityPolkit SecuritySystem = "polkit"
+	// SecurityLandlock identifies the landlock security system.
+	SecurityLandlock SecuritySystem = "landlock"
+	// SecurityNftables identifies the nftables security system.
+	SecurityNftables SecuritySystem = "nftables"
 )
 
 var isValidBusName = regexp.MustCompile(`^[a-zA-Z_-][a-zA-Z0-9_-]*(\.[a-zA-Z_-][a-zA-Z0-9_-]*)+$`).MatchString
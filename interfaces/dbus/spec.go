@@ -56,6 +56,12 @@ func (spec *Specification) Snippets() map[string][]string {
 	return result
 }
 
+// InspectSnippets returns a deep copy of all the added snippets, for
+// inspection.
+func (spec *Specification) InspectSnippets() map[string][]string {
+	return spec.Snippets()
+}
+
 // SnippetForTag returns a combined snippet for given security tag with individual snippets
 // joined with newline character. Empty string is returned for non-existing security tag.
 func (spec *Specification) SnippetForTag(tag string) string {
@@ -103,3 +103,14 @@ func (s *specSuite) TestSpecificationIface(c *C) {
 
 	c.Assert(s.spec.SnippetForTag("non-existing"), Equals, "")
 }
+
+func (s *specSuite) TestInspectSnippets(c *C) {
+	var r interfaces.InspectableSpecification = s.spec
+	c.Check(r.InspectSnippets(), HasLen, 0)
+
+	c.Assert(r.AddPermanentPlug(s.iface, s.plugInfo), IsNil)
+	c.Assert(r.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Check(r.InspectSnippets(), DeepEquals, map[string][]string{
+		"snap.snap1.app1": {"permanent-plug", "connected-plug"},
+	})
+}
@@ -0,0 +1,180 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package denials parses the AppArmor and seccomp denials logged by the
+// kernel audit subsystem and suggests the interfaces that would grant the
+// denied accesses.
+package denials
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+)
+
+// Denial is an access denied to a process by its sandbox.
+type Denial struct {
+	// Sandbox is the security system that denied the access.
+	Sandbox interfaces.SecuritySystem `json:"sandbox"`
+	// Label is the AppArmor label of the process, which is the security
+	// tag for snap apps and hooks.
+	Label string `json:"label"`
+
+	// Operation is the denied AppArmor operation, e.g. open or create.
+	Operation string `json:"operation,omitempty"`
+	// Path is the denied file path, or the object path of D-Bus denials.
+	Path string `json:"path,omitempty"`
+	// Mask is the denied AppArmor permissions, e.g. r or send.
+	Mask string `json:"mask,omitempty"`
+	// Family and SockType describe the denied socket of network denials.
+	Family   string `json:"family,omitempty"`
+	SockType string `json:"sock-type,omitempty"`
+	// Capability is the denied capability, e.g. net_admin.
+	Capability string `json:"capability,omitempty"`
+	// Interface and Member describe the denied D-Bus message.
+	Interface string `json:"interface,omitempty"`
+	Member    string `json:"member,omitempty"`
+
+	// Syscall and Arch are the number of the denied system call and the
+	// audit architecture it was made with, in hexadecimal.
+	Syscall string `json:"syscall,omitempty"`
+	Arch    string `json:"arch,omitempty"`
+
+	// Exe is the executable of the process, when logged.
+	Exe string `json:"exe,omitempty"`
+}
+
+// String describes the denied access.
+func (d *Denial) String() string {
+	switch {
+	case d.Sandbox == interfaces.SecuritySecComp:
+		return fmt.Sprintf("syscall %s (arch %s)", d.Syscall, d.Arch)
+	case d.Capability != "":
+		return fmt.Sprintf("capability %s", d.Capability)
+	case d.Family != "":
+		return strings.TrimSpace(fmt.Sprintf("%s network %s %s", d.Operation, d.Family, d.SockType))
+	case d.Interface != "" || d.Member != "":
+		return fmt.Sprintf("%s %s.%s on %s (%s)", d.Operation, d.Interface, d.Member, d.Path, d.Mask)
+	case d.Path != "" && d.Mask != "":
+		return fmt.Sprintf("%s %s (%s)", d.Operation, d.Path, d.Mask)
+	case d.Path != "":
+		return fmt.Sprintf("%s %s", d.Operation, d.Path)
+	}
+	return d.Operation
+}
+
+var auditFieldRegexp = regexp.MustCompile(`([a-z_]+)=("[^"]*"|[^ ]*)`)
+
+// the audit subsystem logs the untrusted strings of these fields hex
+// encoded, unless they are quoted
+var auditHexFields = map[string]bool{
+	"name":    true,
+	"profile": true,
+	"label":   true,
+	"comm":    true,
+	"exe":     true,
+	"path":    true,
+}
+
+func parseAuditFields(msg string) map[string]string {
+	// the messages of user space processes, like the D-Bus denials of the
+	// bus daemon, are wrapped in msg='...'
+	if idx := strings.Index(msg, "msg='"); idx >= 0 {
+		msg = strings.TrimSuffix(msg[idx+len("msg='"):], "'")
+	}
+	fields := make(map[string]string)
+	for _, match := range auditFieldRegexp.FindAllStringSubmatch(msg, -1) {
+		key, value := match[1], match[2]
+		if _, ok := fields[key]; ok {
+			// keep the first occurrence
+			continue
+		}
+		if strings.HasPrefix(value, `"`) {
+			value = strings.Trim(value, `"`)
+		} else if auditHexFields[key] {
+			if decoded, err := hex.DecodeString(value); err == nil {
+				value = string(decoded)
+			}
+		}
+		fields[key] = value
+	}
+	return fields
+}
+
+// seccomp actions that are logged without denying the system call, see
+// SECCOMP_RET_LOG and SECCOMP_RET_ALLOW
+var seccompAllowCodes = map[string]bool{
+	"0x7ffc0000": true,
+	"0x7fff0000": true,
+}
+
+// ParseAuditMessage parses the message of a journal entry of the audit
+// subsystem, as logged either through the kernel or the audit transport,
+// into a denial of a snap app or hook. It returns nil for messages that are
+// not such denials, e.g. those of unconfined processes or of snaps in devmode.
+func ParseAuditMessage(msg string) *Denial {
+	fields := parseAuditFields(msg)
+	var d *Denial
+	switch {
+	case fields["apparmor"] == "DENIED":
+		d = &Denial{
+			Sandbox:    interfaces.SecurityAppArmor,
+			Label:      fields["profile"],
+			Operation:  fields["operation"],
+			Path:       fields["name"],
+			Mask:       fields["denied_mask"],
+			Family:     fields["family"],
+			SockType:   fields["sock_type"],
+			Capability: fields["capname"],
+		}
+		if strings.HasPrefix(d.Operation, "dbus_") {
+			// D-Bus denials are logged by the bus daemon, name is
+			// then the bus name of the peer
+			d.Label = fields["label"]
+			d.Path = fields["path"]
+			d.Mask = fields["mask"]
+			d.Interface = fields["interface"]
+			d.Member = fields["member"]
+		}
+	case fields["type"] == "1326" || strings.HasPrefix(msg, "SECCOMP "):
+		if seccompAllowCodes[fields["code"]] {
+			return nil
+		}
+		d = &Denial{
+			Sandbox: interfaces.SecuritySecComp,
+			Label:   fields["subj"],
+			Syscall: fields["syscall"],
+			Arch:    fields["arch"],
+			Exe:     fields["exe"],
+		}
+	default:
+		return nil
+	}
+	// drop the hats and child profiles, e.g. snap.foo.app//null-/usr/bin/bar
+	if idx := strings.Index(d.Label, "//"); idx >= 0 {
+		d.Label = d.Label[:idx]
+	}
+	if !strings.HasPrefix(d.Label, "snap.") {
+		return nil
+	}
+	return d
+}
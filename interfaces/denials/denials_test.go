@@ -0,0 +1,134 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package denials_test
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/denials"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type denialsSuite struct{}
+
+var _ = Suite(&denialsSuite{})
+
+func (s *denialsSuite) TestParseAppArmorFile(c *C) {
+	for _, msg := range []string{
+		// kernel transport
+		`audit: type=1400 audit(1657193021.123:456): apparmor="DENIED" operation="open" profile="snap.foo.app" name="/dev/video0" pid=1234 comm="app" requested_mask="r" denied_mask="r" fsuid=1000 ouid=0`,
+		// audit transport
+		`AVC apparmor="DENIED" operation="open" profile="snap.foo.app" name="/dev/video0" pid=1234 comm="app" requested_mask="r" denied_mask="r" fsuid=1000 ouid=0`,
+		// child profile
+		`AVC apparmor="DENIED" operation="open" profile="snap.foo.app//null-/usr/bin/bar" name="/dev/video0" pid=1234 comm="bar" requested_mask="r" denied_mask="r" fsuid=1000 ouid=0`,
+	} {
+		d := denials.ParseAuditMessage(msg)
+		c.Assert(d, NotNil, Commentf(msg))
+		c.Check(d, DeepEquals, &denials.Denial{
+			Sandbox:   interfaces.SecurityAppArmor,
+			Label:     "snap.foo.app",
+			Operation: "open",
+			Path:      "/dev/video0",
+			Mask:      "r",
+		})
+		c.Check(d.String(), Equals, "open /dev/video0 (r)")
+	}
+}
+
+func (s *denialsSuite) TestParseAppArmorHexEncoded(c *C) {
+	// names with spaces are hex encoded
+	d := denials.ParseAuditMessage(`apparmor="DENIED" operation="mkdir" profile="snap.foo.hook.configure" name=2F746D702F6120622F pid=1 comm="mkdir" requested_mask="c" denied_mask="c" fsuid=0 ouid=0`)
+	c.Assert(d, NotNil)
+	c.Check(d.Label, Equals, "snap.foo.hook.configure")
+	c.Check(d.Path, Equals, "/tmp/a b/")
+	c.Check(d.String(), Equals, "mkdir /tmp/a b/ (c)")
+}
+
+func (s *denialsSuite) TestParseAppArmorNetwork(c *C) {
+	d := denials.ParseAuditMessage(`apparmor="DENIED" operation="create" profile="snap.foo.app" pid=1 comm="app" family="netlink" sock_type="raw" protocol=15 requested_mask="create" denied_mask="create"`)
+	c.Assert(d, NotNil)
+	c.Check(d.Family, Equals, "netlink")
+	c.Check(d.SockType, Equals, "raw")
+	c.Check(d.String(), Equals, "create network netlink raw")
+}
+
+func (s *denialsSuite) TestParseAppArmorCapability(c *C) {
+	d := denials.ParseAuditMessage(`apparmor="DENIED" operation="capable" profile="snap.foo.app" pid=1 comm="app" capability=12  capname="net_admin"`)
+	c.Assert(d, NotNil)
+	c.Check(d.Capability, Equals, "net_admin")
+	c.Check(d.String(), Equals, "capability net_admin")
+}
+
+func (s *denialsSuite) TestParseAppArmorDBus(c *C) {
+	d := denials.ParseAuditMessage(`USER_AVC pid=555 uid=103 auid=4294967295 ses=4294967295 msg='apparmor="DENIED" operation="dbus_method_call"  bus="system" path="/org/freedesktop/hostname1" interface="org.freedesktop.DBus.Properties" member="GetAll" mask="send" name="org.freedesktop.hostname1" pid=1 label="snap.foo.app" peer_label="unconfined"'`)
+	c.Assert(d, NotNil)
+	c.Check(d, DeepEquals, &denials.Denial{
+		Sandbox:   interfaces.SecurityAppArmor,
+		Label:     "snap.foo.app",
+		Operation: "dbus_method_call",
+		Path:      "/org/freedesktop/hostname1",
+		Mask:      "send",
+		Interface: "org.freedesktop.DBus.Properties",
+		Member:    "GetAll",
+	})
+	c.Check(d.String(), Equals, "dbus_method_call org.freedesktop.DBus.Properties.GetAll on /org/freedesktop/hostname1 (send)")
+}
+
+func (s *denialsSuite) TestParseSeccomp(c *C) {
+	for _, msg := range []string{
+		`audit: type=1326 audit(1657193021.123:457): auid=1000 uid=1000 gid=1000 ses=2 subj=snap.foo.app pid=1234 comm="app" exe="/snap/foo/x1/bin/app" sig=0 arch=c000003e syscall=165 compat=0 ip=0x7f2d code=0x50000`,
+		`SECCOMP auid=1000 uid=1000 gid=1000 ses=2 subj=snap.foo.app (enforce) pid=1234 comm="app" exe="/snap/foo/x1/bin/app" sig=0 arch=c000003e syscall=165 compat=0 ip=0x7f2d code=0x50000`,
+	} {
+		d := denials.ParseAuditMessage(msg)
+		c.Assert(d, NotNil, Commentf(msg))
+		c.Check(d, DeepEquals, &denials.Denial{
+			Sandbox: interfaces.SecuritySecComp,
+			Label:   "snap.foo.app",
+			Syscall: "165",
+			Arch:    "c000003e",
+			Exe:     "/snap/foo/x1/bin/app",
+		})
+		c.Check(d.String(), Equals, "syscall 165 (arch c000003e)")
+	}
+}
+
+func (s *denialsSuite) TestParseNotDenials(c *C) {
+	for _, msg := range []string{
+		"",
+		"usb 1-1: new high-speed USB device number 2 using xhci_hcd",
+		// complain mode
+		`apparmor="ALLOWED" operation="open" profile="snap.foo.app" name="/dev/video0" pid=1 comm="app" requested_mask="r" denied_mask="r" fsuid=1000 ouid=0`,
+		// not a snap
+		`apparmor="DENIED" operation="open" profile="/usr/sbin/cupsd" name="/etc/shadow" pid=1 comm="cupsd" requested_mask="r" denied_mask="r" fsuid=0 ouid=0`,
+		`apparmor="DENIED" operation="open" profile="snap-update-ns.foo" name="/etc/shadow" pid=1 comm="snap-update-ns" requested_mask="r" denied_mask="r" fsuid=0 ouid=0`,
+		// logged, not denied
+		`SECCOMP auid=1000 uid=1000 gid=1000 ses=2 subj=snap.foo.app pid=1 comm="app" exe="/snap/foo/x1/bin/app" sig=0 arch=c000003e syscall=165 compat=0 ip=0x7f2d code=0x7ffc0000`,
+		// unconfined
+		`SECCOMP auid=1000 uid=1000 gid=1000 ses=2 subj=unconfined pid=1 comm="app" exe="/usr/bin/app" sig=0 arch=c000003e syscall=165 compat=0 ip=0x7f2d code=0x50000`,
+	} {
+		c.Check(denials.ParseAuditMessage(msg), IsNil, Commentf(msg))
+	}
+}
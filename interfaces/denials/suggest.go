@@ -0,0 +1,315 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package denials
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/snap"
+)
+
+// Suggester suggests the interfaces that grant denied accesses, based on
+// the AppArmor rules of the snippets the interfaces contribute to the
+// profile of a snap plugging them.
+//
+// Only the snippets of interfaces plugged without any attribute and
+// connected to a slot of the system snap are considered.
+type Suggester struct {
+	ifaces []interfaces.Interface
+
+	once sync.Once
+	// rules are indexed by interface name
+	rules map[string][]*appArmorRule
+}
+
+// NewSuggester returns a suggester picking among the given interfaces.
+func NewSuggester(ifaces []interfaces.Interface) *Suggester {
+	return &Suggester{ifaces: ifaces}
+}
+
+// Suggest returns the sorted names of the interfaces that grant the denied
+// access, if any.
+func (s *Suggester) Suggest(d *Denial) []string {
+	if d.Sandbox != interfaces.SecurityAppArmor {
+		return nil
+	}
+	s.once.Do(s.collectRules)
+
+	var names []string
+	for name, rules := range s.rules {
+		for _, rule := range rules {
+			if rule.grants(d) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+const (
+	suggestSnapName = "snap"
+	suggestAppName  = "app"
+)
+
+func (s *Suggester) collectRules() {
+	plugSnap := &snap.Info{SuggestedName: suggestSnapName}
+	app := &snap.AppInfo{Snap: plugSnap, Name: suggestAppName}
+	plugSnap.Apps = map[string]*snap.AppInfo{suggestAppName: app}
+	slotSnap := &snap.Info{SuggestedName: "core", SnapType: snap.TypeOS}
+
+	s.rules = make(map[string][]*appArmorRule, len(s.ifaces))
+	for _, iface := range s.ifaces {
+		plugInfo := &snap.PlugInfo{
+			Snap:      plugSnap,
+			Name:      iface.Name(),
+			Interface: iface.Name(),
+			Apps:      plugSnap.Apps,
+		}
+		slotInfo := &snap.SlotInfo{
+			Snap:      slotSnap,
+			Name:      iface.Name(),
+			Interface: iface.Name(),
+		}
+		// skip the interfaces needing attributes
+		if err := interfaces.BeforePreparePlug(iface, plugInfo); err != nil {
+			continue
+		}
+		if err := interfaces.BeforePrepareSlot(iface, slotInfo); err != nil {
+			continue
+		}
+		plug := interfaces.NewConnectedPlug(plugInfo, nil, nil)
+		slot := interfaces.NewConnectedSlot(slotInfo, nil, nil)
+
+		spec := &apparmor.Specification{}
+		if err := spec.AddPermanentPlug(iface, plugInfo); err != nil {
+			continue
+		}
+		if err := spec.AddConnectedPlug(iface, plug, slot); err != nil {
+			continue
+		}
+		rules := parseAppArmorRules(spec.SnippetForTag(app.SecurityTag()))
+		if len(rules) > 0 {
+			s.rules[iface.Name()] = rules
+		}
+	}
+}
+
+type appArmorRule struct {
+	// path and perms are set for file rules
+	path  *regexp.Regexp
+	perms string
+	// network is set for network rules, with the optional family and
+	// socket type
+	network []string
+	// capabilities is set for capability rules
+	capabilities []string
+}
+
+func (r *appArmorRule) grants(d *Denial) bool {
+	switch {
+	case r.capabilities != nil:
+		for _, capability := range r.capabilities {
+			if capability == d.Capability {
+				return true
+			}
+		}
+		return false
+	case r.network != nil:
+		if d.Family == "" {
+			return false
+		}
+		if len(r.network) > 0 && r.network[0] != d.Family {
+			return false
+		}
+		if len(r.network) > 1 && r.network[1] != d.SockType {
+			return false
+		}
+		return true
+	case r.path != nil:
+		if d.Path == "" || d.Mask == "" || d.Family != "" || d.Capability != "" || d.Interface != "" {
+			return false
+		}
+		return r.path.MatchString(d.Path) && permsGrant(r.perms, d.Mask)
+	}
+	return false
+}
+
+// permsGrant returns whether the permissions of a file rule grant the
+// denied mask.
+func permsGrant(perms, mask string) bool {
+	for _, m := range mask {
+		var granted bool
+		switch m {
+		case 'r', 'w', 'm', 'k', 'l':
+			granted = strings.ContainsRune(perms, m)
+		case 'a':
+			granted = strings.ContainsAny(perms, "aw")
+		case 'c', 'd':
+			// create and delete are granted by write
+			granted = strings.ContainsRune(perms, 'w')
+		case 'x':
+			granted = strings.ContainsAny(perms, "xX")
+		case ':':
+			// separates the owner and other permissions
+			granted = true
+		}
+		if !granted {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	aaPermsRegexp = regexp.MustCompile(`^[rwamklixuUpPcCb]+$`)
+	aaQualifiers  = map[string]bool{"owner": true, "audit": true, "allow": true}
+)
+
+func isAppArmorPath(s string) bool {
+	return strings.HasPrefix(s, "/") || strings.HasPrefix(s, "@{") || strings.HasPrefix(s, `"`)
+}
+
+// parseAppArmorRules parses the file, network and capability rules of an
+// AppArmor snippet, ignoring all other rules.
+func parseAppArmorRules(snippet string) []*appArmorRule {
+	var rules []*appArmorRule
+	for _, line := range strings.Split(snippet, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSuffix(line, ",")
+		fields := strings.Fields(line)
+		for len(fields) > 0 && aaQualifiers[fields[0]] {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		switch {
+		case fields[0] == "network":
+			rules = append(rules, &appArmorRule{network: fields[1:]})
+		case fields[0] == "capability" && len(fields) > 1:
+			rules = append(rules, &appArmorRule{capabilities: fields[1:]})
+		case len(fields) == 2:
+			// both "<path> <perms>" and "<perms> <path>" are valid
+			path, perms := fields[0], fields[1]
+			if !isAppArmorPath(path) {
+				path, perms = perms, path
+			}
+			if !isAppArmorPath(path) || !aaPermsRegexp.MatchString(perms) {
+				continue
+			}
+			re, err := aareToRegexp(strings.Trim(path, `"`))
+			if err != nil {
+				continue
+			}
+			rules = append(rules, &appArmorRule{path: re, perms: perms})
+		}
+	}
+	return rules
+}
+
+// aareVariables are the values of the AppArmor variables used by the
+// snippets, the snap specific ones match any snap.
+var aareVariables = map[string]string{
+	"PROC":                  "/proc",
+	"HOME":                  "(?:/home/[^/]+|/root)",
+	"HOMEDIRS":              "/home",
+	"INSTALL_DIR":           "(?:/snap|/var/lib/snapd/snap)",
+	"pid":                   "[0-9]+",
+	"pids":                  "[0-9]+",
+	"tid":                   "[0-9]+",
+	"uid":                   "[0-9]+",
+	"multiarch":             "[^/]+",
+	"run":                   "(?:/var)?/run",
+	"sys":                   "/sys",
+	"SNAP_NAME":             "[^/]+",
+	"SNAP_INSTANCE_NAME":    "[^/]+",
+	"SNAP_INSTANCE_DESKTOP": "[^/]+",
+	"SNAP_COMMAND_NAME":     "[^/]+",
+	"SNAP_REVISION":         "[^/]+",
+	"SNAP_APP":              "[^/]+",
+}
+
+// aareToRegexp converts an AppArmor path expression, with its globs,
+// alternations and variables, to a regular expression matching the same
+// paths.
+func aareToRegexp(aare string) (*regexp.Regexp, error) {
+	var buf strings.Builder
+	buf.WriteString("^")
+	depth := 0
+	for i := 0; i < len(aare); i++ {
+		c := aare[i]
+		switch {
+		case strings.HasPrefix(aare[i:], "@{"):
+			end := strings.IndexByte(aare[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated variable in %q", aare)
+			}
+			value, ok := aareVariables[aare[i+2:i+end]]
+			if !ok {
+				value = "[^/]+"
+			}
+			buf.WriteString(value)
+			i += end
+		case c == '*' && strings.HasPrefix(aare[i:], "**"):
+			buf.WriteString(".*")
+			i++
+		case c == '*':
+			buf.WriteString("[^/]*")
+		case c == '?':
+			buf.WriteString("[^/]")
+		case c == '{':
+			buf.WriteString("(?:")
+			depth++
+		case c == '}' && depth > 0:
+			buf.WriteString(")")
+			depth--
+		case c == ',' && depth > 0:
+			buf.WriteString("|")
+		case c == '[':
+			end := strings.IndexByte(aare[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class in %q", aare)
+			}
+			buf.WriteString(aare[i : i+end+1])
+			i += end
+		case c == '\\' && i+1 < len(aare):
+			buf.WriteString(regexp.QuoteMeta(aare[i+1 : i+2]))
+			i++
+		default:
+			buf.WriteString(regexp.QuoteMeta(aare[i : i+1]))
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced alternation in %q", aare)
+	}
+	buf.WriteString("$")
+	return regexp.Compile(buf.String())
+}
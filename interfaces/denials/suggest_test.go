@@ -0,0 +1,166 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package denials_test
+
+import (
+	"fmt"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/denials"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type suggestSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&suggestSuite{})
+
+func appArmorInterface(name, snippet string) interfaces.Interface {
+	return &ifacetest.TestInterface{
+		InterfaceName: name,
+		AppArmorConnectedPlugCallback: func(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+			spec.AddSnippet(snippet)
+			return nil
+		},
+	}
+}
+
+func (s *suggestSuite) TestSuggest(c *C) {
+	suggester := denials.NewSuggester([]interfaces.Interface{
+		appArmorInterface("video", "# Description: video devices\n/dev/video[0-9]* rw,\nowner @{HOME}/.config/video/** r,\n"),
+		appArmorInterface("net", "network netlink raw,\ncapability net_admin net_raw,\n"),
+		appArmorInterface("all-net", "network,\n"),
+		appArmorInterface("dev", "/dev/** r,\ndeny /dev/video0 w,\n"),
+		appArmorInterface("none", ""),
+	})
+
+	for _, t := range []struct {
+		denial *denials.Denial
+		ifaces []string
+	}{
+		{&denials.Denial{Sandbox: interfaces.SecurityAppArmor, Operation: "open", Path: "/dev/video0", Mask: "r"}, []string{"dev", "video"}},
+		{&denials.Denial{Sandbox: interfaces.SecurityAppArmor, Operation: "open", Path: "/dev/video0", Mask: "w"}, []string{"video"}},
+		{&denials.Denial{Sandbox: interfaces.SecurityAppArmor, Operation: "open", Path: "/dev/video0", Mask: "rk"}, nil},
+		{&denials.Denial{Sandbox: interfaces.SecurityAppArmor, Operation: "open", Path: "/home/user/.config/video/settings", Mask: "r"}, []string{"video"}},
+		{&denials.Denial{Sandbox: interfaces.SecurityAppArmor, Operation: "open", Path: "/etc/shadow", Mask: "r"}, nil},
+		{&denials.Denial{Sandbox: interfaces.SecurityAppArmor, Operation: "create", Family: "netlink", SockType: "raw"}, []string{"all-net", "net"}},
+		{&denials.Denial{Sandbox: interfaces.SecurityAppArmor, Operation: "create", Family: "netlink", SockType: "dgram"}, []string{"all-net"}},
+		{&denials.Denial{Sandbox: interfaces.SecurityAppArmor, Operation: "capable", Capability: "net_raw"}, []string{"net"}},
+		{&denials.Denial{Sandbox: interfaces.SecurityAppArmor, Operation: "capable", Capability: "sys_admin"}, nil},
+		{&denials.Denial{Sandbox: interfaces.SecuritySecComp, Syscall: "165", Arch: "c000003e"}, nil},
+	} {
+		c.Check(suggester.Suggest(t.denial), DeepEquals, t.ifaces, Commentf("%s", t.denial))
+	}
+}
+
+func (s *suggestSuite) TestSuggestBuiltin(c *C) {
+	suggester := denials.NewSuggester(builtin.Interfaces())
+
+	c.Check(suggester.Suggest(&denials.Denial{
+		Sandbox: interfaces.SecurityAppArmor, Operation: "open", Path: "/dev/video0", Mask: "r",
+	}), DeepEquals, []string{"camera"})
+	c.Check(suggester.Suggest(&denials.Denial{
+		Sandbox: interfaces.SecurityAppArmor, Operation: "open", Path: "/dev/uinput", Mask: "w",
+	}), DeepEquals, []string{"uinput"})
+	c.Check(suggester.Suggest(&denials.Denial{
+		Sandbox: interfaces.SecurityAppArmor, Operation: "open", Path: "/home/user/.ssh/id_rsa", Mask: "r",
+	}), testutil.Contains, "ssh-keys")
+}
+
+func (s *suggestSuite) TestSuggestSkipsInterfacesNeedingAttributes(c *C) {
+	iface := &ifacetest.TestInterface{
+		InterfaceName: "attrs",
+		BeforePreparePlugCallback: func(plug *snap.PlugInfo) error {
+			if _, ok := plug.Attrs["path"]; !ok {
+				return fmt.Errorf("path attribute is required")
+			}
+			return nil
+		},
+		AppArmorConnectedPlugCallback: func(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+			c.Fatalf("unexpected call")
+			return nil
+		},
+	}
+	suggester := denials.NewSuggester([]interfaces.Interface{iface})
+	c.Check(suggester.Suggest(&denials.Denial{
+		Sandbox: interfaces.SecurityAppArmor, Operation: "open", Path: "/dev/video0", Mask: "r",
+	}), IsNil)
+}
+
+func (s *suggestSuite) TestAareToRegexp(c *C) {
+	for _, t := range []struct {
+		aare     string
+		matches  []string
+		mismatch []string
+	}{
+		{"/dev/video[0-9]*", []string{"/dev/video0", "/dev/video12"}, []string{"/dev/video", "/dev/videoX", "/dev/video0/x"}},
+		{"/sys/devices/**/input[0-9]*/capabilities/*", []string{"/sys/devices/pci0/a/b/input3/capabilities/ev"}, []string{"/sys/devices/input3/capabilities/ev/x"}},
+		{"/{,usr/}lib{,32,64}/foo?", []string{"/lib/foo1", "/usr/lib64/foox"}, []string{"/usr/lib/foo", "/lib128/foo1"}},
+		{"@{PROC}/@{pid}/mountinfo", []string{"/proc/1/mountinfo"}, []string{"/proc/self/mountinfo"}},
+		{"@{HOME}/.ssh/**", []string{"/home/user/.ssh/id_rsa", "/root/.ssh/config"}, []string{"/home/.ssh/id_rsa"}},
+		{"/run/foo.sock", []string{"/run/foo.sock"}, []string{"/run/fooxsock"}},
+		{"/dev/shm/\\{a\\}", []string{"/dev/shm/{a}"}, nil},
+	} {
+		re, err := denials.AareToRegexp(t.aare)
+		c.Assert(err, IsNil, Commentf(t.aare))
+		for _, path := range t.matches {
+			c.Check(re.MatchString(path), Equals, true, Commentf("%s %s", t.aare, path))
+		}
+		for _, path := range t.mismatch {
+			c.Check(re.MatchString(path), Equals, false, Commentf("%s %s", t.aare, path))
+		}
+	}
+
+	for _, aare := range []string{"/foo/@{PROC", "/dev/tty[0-9", "/{a,b"} {
+		_, err := denials.AareToRegexp(aare)
+		c.Check(err, NotNil, Commentf(aare))
+	}
+}
+
+func (s *suggestSuite) TestPermsGrant(c *C) {
+	for _, t := range []struct {
+		perms, mask string
+		granted     bool
+	}{
+		{"r", "r", true},
+		{"rw", "w", true},
+		{"r", "w", false},
+		{"rw", "a", true},
+		{"ra", "a", true},
+		{"rw", "c", true},
+		{"rw", "d", true},
+		{"rwk", "rk", true},
+		{"rw", "rk", false},
+		{"ixr", "rx", true},
+		{"Pxr", "x", true},
+		{"rwlk", "l", true},
+		{"mr", "m", true},
+		{"r", "r::", true},
+	} {
+		c.Check(denials.PermsGrant(t.perms, t.mask), Equals, t.granted, Commentf("%s %s", t.perms, t.mask))
+	}
+}
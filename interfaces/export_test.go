@@ -70,6 +70,14 @@ func MockReadBuildID(mock func(p string) (string, error)) (restore func()) {
 	}
 }
 
+func MockLandlockConfinement(enabled bool) (restore func()) {
+	old := landlockConfinement
+	landlockConfinement = func() bool { return enabled }
+	return func() {
+		landlockConfinement = old
+	}
+}
+
 type SystemKey = systemKey
 
 var (
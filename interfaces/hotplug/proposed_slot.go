@@ -41,6 +41,13 @@ type HandledByGadgetPredicate interface {
 	HandledByGadget(di *HotplugDeviceInfo, slot *snap.SlotInfo) bool
 }
 
+// TemplateDefiner can be implemented by hotplug interfaces whose slots are instantiated from slot templates declared by the gadget snap.
+type TemplateDefiner interface {
+	// HotplugSlotFromTemplate is called for all devices and every gadget slot of the interface and should return nil slot when the device doesn't match the template.
+	// Error should only be returned when the device matches the template, but there is a problem with creating a proposed slot for it.
+	HotplugSlotFromTemplate(di *HotplugDeviceInfo, template *snap.SlotInfo) (*ProposedSlot, error)
+}
+
 // ProposedSlot is a definition of the slot to create in response to a hotplug event.
 type ProposedSlot struct {
 	// Name is how the interface wants to name the slot. When left empty,
@@ -79,3 +79,12 @@ func (spec *Specification) AddPermanentSlot(iface interfaces.Interface, slot *sn
 	}
 	return nil
 }
+
+// InspectSnippets returns the snippets of the specification, they apply to
+// the whole snap.
+func (spec *Specification) InspectSnippets() map[string][]string {
+	if len(spec.Snippets) == 0 {
+		return nil
+	}
+	return map[string][]string{"": append([]string(nil), spec.Snippets...)}
+}
@@ -123,6 +123,8 @@ type TestHotplugInterface struct {
 	HotplugKeyCallback            func(deviceInfo *hotplug.HotplugDeviceInfo) (snap.HotplugKey, error)
 	HandledByGadgetCallback       func(deviceInfo *hotplug.HotplugDeviceInfo, slot *snap.SlotInfo) bool
 	HotplugDeviceDetectedCallback func(deviceInfo *hotplug.HotplugDeviceInfo) (*hotplug.ProposedSlot, error)
+
+	HotplugSlotFromTemplateCallback func(deviceInfo *hotplug.HotplugDeviceInfo, template *snap.SlotInfo) (*hotplug.ProposedSlot, error)
 }
 
 // String() returns the same value as Name().
@@ -466,6 +468,13 @@ func (t *TestHotplugInterface) HotplugDeviceDetected(deviceInfo *hotplug.Hotplug
 	return nil, nil
 }
 
+func (t *TestHotplugInterface) HotplugSlotFromTemplate(deviceInfo *hotplug.HotplugDeviceInfo, template *snap.SlotInfo) (*hotplug.ProposedSlot, error) {
+	if t.HotplugSlotFromTemplateCallback != nil {
+		return t.HotplugSlotFromTemplateCallback(deviceInfo, template)
+	}
+	return nil, nil
+}
+
 func (t *TestHotplugInterface) HandledByGadget(deviceInfo *hotplug.HotplugDeviceInfo, slot *snap.SlotInfo) bool {
 	if t.HandledByGadgetCallback != nil {
 		return t.HandledByGadgetCallback(deviceInfo, slot)
@@ -20,6 +20,7 @@
 package kmod
 
 import (
+	"fmt"
 	"sort"
 	"strings"
 
@@ -75,6 +76,34 @@ func (spec *Specification) ModuleOptions() map[string]string {
 	return spec.moduleOptions
 }
 
+// InspectSnippets returns the kernel modules to load and, in modprobe
+// configuration format, their options and the disallowed modules, for
+// inspection. They apply to the whole snap.
+func (spec *Specification) InspectSnippets() map[string][]string {
+	var snippets []string
+	modules := make([]string, 0, len(spec.modules))
+	for module := range spec.modules {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	snippets = append(snippets, modules...)
+	for _, module := range spec.DisallowedModules() {
+		snippets = append(snippets, fmt.Sprintf("blacklist %s", module))
+	}
+	withOptions := make([]string, 0, len(spec.moduleOptions))
+	for module := range spec.moduleOptions {
+		withOptions = append(withOptions, module)
+	}
+	sort.Strings(withOptions)
+	for _, module := range withOptions {
+		snippets = append(snippets, fmt.Sprintf("options %s %s", module, spec.moduleOptions[module]))
+	}
+	if len(snippets) == 0 {
+		return nil
+	}
+	return map[string][]string{"": snippets}
+}
+
 // DisallowModule adds a kernel module to the list of disallowed modules.
 func (spec *Specification) DisallowModule(module string) error {
 	m := strings.TrimSpace(module)
@@ -127,3 +127,16 @@ func (s *specSuite) TestSpecificationIface(c *C) {
 	c.Assert(s.spec.Modules(), DeepEquals, map[string]bool{
 		"module1": true, "module2": true, "module3": true, "module4": true})
 }
+
+func (s *specSuite) TestInspectSnippets(c *C) {
+	var r interfaces.InspectableSpecification = s.spec
+	c.Check(r.InspectSnippets(), IsNil)
+
+	c.Assert(s.spec.AddModule("module2"), IsNil)
+	c.Assert(s.spec.AddModule("module1"), IsNil)
+	c.Assert(s.spec.SetModuleOptions("module1", "opt=1"), IsNil)
+	c.Assert(s.spec.DisallowModule("module3"), IsNil)
+	c.Check(r.InspectSnippets(), DeepEquals, map[string][]string{
+		"": {"module1", "module2", "blacklist module3", "options module1 opt=1"},
+	})
+}
@@ -0,0 +1,169 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package landlock implements integration between snapd and the Landlock
+// LSM, which confines the filesystem access of snaps on systems without
+// AppArmor.
+//
+// Interfaces may grant access to file hierarchies by providing rules via
+// their respective "Landlock*" methods for the interfaces.SecurityLandlock
+// security system. The backend stores the rules of each application and
+// hook of a snap, together with the rules common to all snaps, in a
+// profile under /var/lib/snapd/landlock, named after its security tag.
+// snap-exec applies the profile right before executing the application or
+// hook, so that it applies to it and to all the processes it executes.
+//
+// Profiles are only written when the landlock confinement is in use, as
+// reported by sandbox.LandlockConfinement, and for snaps that are neither
+// in devmode nor classic.
+package landlock
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/sandbox"
+	landlock_sandbox "github.com/snapcore/snapd/sandbox/landlock"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/timings"
+)
+
+var landlockConfinement = sandbox.LandlockConfinement
+
+// Backend is responsible for maintaining landlock profiles.
+type Backend struct{}
+
+// Initialize does nothing.
+func (b *Backend) Initialize(*interfaces.SecurityBackendOptions) error {
+	return nil
+}
+
+// Name returns the name of the backend.
+func (b *Backend) Name() interfaces.SecuritySystem {
+	return interfaces.SecurityLandlock
+}
+
+// Setup creates the landlock profiles of the applications and hooks of a
+// snap, or removes them if the snap is not confined by landlock.
+//
+// This method should be called after changing plug, slots, connections
+// between them or application present in the snap.
+func (b *Backend) Setup(snapInfo *snap.Info, opts interfaces.ConfinementOptions, repo *interfaces.Repository, tm timings.Measurer) error {
+	snapName := snapInfo.InstanceName()
+	// Get the rules that apply to this snap
+	spec, err := repo.SnapSpecification(b.Name(), snapName)
+	if err != nil {
+		return fmt.Errorf("cannot obtain landlock specification for snap %q: %s", snapName, err)
+	}
+
+	content := deriveContent(spec.(*Specification), opts, snapInfo)
+	glob := interfaces.SecurityTagGlob(snapName)
+	dir := dirs.SnapLandlockDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create directory for landlock profiles %q: %s", dir, err)
+	}
+	if _, _, err := osutil.EnsureDirState(dir, glob, content); err != nil {
+		return fmt.Errorf("cannot synchronize landlock profiles for snap %q: %s", snapName, err)
+	}
+	return nil
+}
+
+// Remove removes the landlock profiles of a given snap.
+func (b *Backend) Remove(snapName string) error {
+	glob := interfaces.SecurityTagGlob(snapName)
+	if _, _, err := osutil.EnsureDirState(dirs.SnapLandlockDir, glob, nil); err != nil {
+		return fmt.Errorf("cannot synchronize landlock profiles for snap %q: %s", snapName, err)
+	}
+	return nil
+}
+
+// deriveContent combines the rules collected from all the interfaces
+// affecting a given snap into a content map applicable to EnsureDirState.
+func deriveContent(spec *Specification, opts interfaces.ConfinementOptions, snapInfo *snap.Info) map[string]osutil.FileState {
+	if !landlockConfinement() {
+		return nil
+	}
+	// devmode and classic snaps are not confined, unless in jailmode
+	if (opts.DevMode || opts.Classic) && !opts.JailMode {
+		return nil
+	}
+
+	var securityTags []string
+	for _, hookInfo := range snapInfo.Hooks {
+		securityTags = append(securityTags, hookInfo.SecurityTag())
+	}
+	for _, appInfo := range snapInfo.Apps {
+		securityTags = append(securityTags, appInfo.SecurityTag())
+	}
+	if len(securityTags) == 0 {
+		return nil
+	}
+
+	content := make(map[string]osutil.FileState, len(securityTags))
+	for _, securityTag := range securityTags {
+		content[securityTag] = &osutil.MemoryFileState{
+			Content: generateContent(spec.RulesForTag(securityTag)),
+			Mode:    0644,
+		}
+	}
+	return content
+}
+
+func generateContent(rules []string) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("# This file is automatically generated by snapd.\n")
+	buffer.WriteString(defaultTemplate)
+	if len(rules) > 0 {
+		buffer.WriteString("\n# Description: Allows access granted by interfaces.\n\n")
+		for _, rule := range rules {
+			buffer.WriteString(rule)
+			buffer.WriteRune('\n')
+		}
+	}
+	return buffer.Bytes()
+}
+
+// NewSpecification returns an empty landlock specification.
+func (b *Backend) NewSpecification() interfaces.Specification {
+	return &Specification{}
+}
+
+// SandboxFeatures returns the versions of the landlock ABI supported by the
+// kernel, and whether snaps are confined with it. Every version up to the
+// one of the kernel is listed, as they are backwards compatible, so that
+// "snap debug sandbox-features --required landlock:abi:1" holds on newer
+// kernels too.
+func (b *Backend) SandboxFeatures() []string {
+	abi := landlock_sandbox.ABIVersion()
+	if abi == 0 {
+		return nil
+	}
+	features := make([]string, 0, abi+1)
+	for v := 1; v <= abi; v++ {
+		features = append(features, fmt.Sprintf("abi:%d", v))
+	}
+	if landlockConfinement() {
+		features = append(features, "confinement")
+	}
+	return features
+}
@@ -0,0 +1,160 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package landlock_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/interfaces/landlock"
+	"github.com/snapcore/snapd/osutil"
+	landlock_sandbox "github.com/snapcore/snapd/sandbox/landlock"
+	"github.com/snapcore/snapd/snap"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type backendSuite struct {
+	ifacetest.BackendSuite
+
+	iface *testInterface
+}
+
+var _ = Suite(&backendSuite{})
+
+func (s *backendSuite) SetUpTest(c *C) {
+	s.Backend = &landlock.Backend{}
+	s.BackendSuite.SetUpTest(c)
+	// use an interface contributing to the landlock backend instead of
+	// the one of the BackendSuite
+	s.iface = &testInterface{
+		TestInterface: ifacetest.TestInterface{InterfaceName: "iface"},
+		permanentSlot: func(spec *landlock.Specification, slot *snap.SlotInfo) error {
+			spec.AllowRead("/srv/samba")
+			spec.AllowWrite("$SNAP_REAL_HOME/Public")
+			return nil
+		},
+	}
+	s.Repo = interfaces.NewRepository()
+	c.Assert(s.Repo.AddInterface(s.iface), IsNil)
+	c.Assert(s.Repo.AddBackend(s.Backend), IsNil)
+
+	s.AddCleanup(landlock.MockLandlockConfinement(true))
+}
+
+func (s *backendSuite) TestName(c *C) {
+	c.Check(s.Backend.Name(), Equals, interfaces.SecurityLandlock)
+}
+
+func (s *backendSuite) readRules(c *C, securityTag string) []landlock_sandbox.Rule {
+	content, err := ioutil.ReadFile(filepath.Join(dirs.SnapLandlockDir, securityTag))
+	c.Assert(err, IsNil)
+	rules, err := landlock_sandbox.ReadRules(bytes.NewReader(content))
+	c.Assert(err, IsNil)
+	return rules
+}
+
+func (s *backendSuite) TestInstallingSnapWritesProfiles(c *C) {
+	for _, opts := range []interfaces.ConfinementOptions{
+		{},
+		{JailMode: true},
+		{DevMode: true, JailMode: true},
+	} {
+		snapInfo := s.InstallSnap(c, opts, "", ifacetest.SambaYamlV1WithNmbd, 0)
+
+		for _, tag := range []string{"snap.samba.smbd", "snap.samba.nmbd"} {
+			rules := s.readRules(c, tag)
+			c.Check(rules[0], Equals, landlock_sandbox.Rule{Access: landlock_sandbox.AccessRead, Path: "/bin"})
+			c.Check(rules[len(rules)-2:], DeepEquals, []landlock_sandbox.Rule{
+				{Access: landlock_sandbox.AccessRead, Path: "/srv/samba"},
+				{Access: landlock_sandbox.AccessWrite, Path: "$SNAP_REAL_HOME/Public"},
+			})
+		}
+		s.RemoveSnap(c, snapInfo)
+		c.Check(osutil.FileExists(filepath.Join(dirs.SnapLandlockDir, "snap.samba.smbd")), Equals, false)
+		c.Check(osutil.FileExists(filepath.Join(dirs.SnapLandlockDir, "snap.samba.nmbd")), Equals, false)
+	}
+}
+
+func (s *backendSuite) TestInstallingSnapWithHookWritesProfiles(c *C) {
+	s.InstallSnap(c, interfaces.ConfinementOptions{}, "", ifacetest.HookYaml, 0)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapLandlockDir, "snap.foo.hook.configure")), Equals, true)
+}
+
+func (s *backendSuite) TestProfilesContentWithoutRules(c *C) {
+	s.iface.permanentSlot = nil
+	s.InstallSnap(c, interfaces.ConfinementOptions{}, "", ifacetest.SambaYamlV1, 0)
+
+	content, err := ioutil.ReadFile(filepath.Join(dirs.SnapLandlockDir, "snap.samba.smbd"))
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "# This file is automatically generated by snapd.\n"+landlock.DefaultTemplate)
+}
+
+func (s *backendSuite) TestNoProfilesWhenNotConfined(c *C) {
+	for _, opts := range []interfaces.ConfinementOptions{
+		{DevMode: true},
+		{Classic: true},
+	} {
+		snapInfo := s.InstallSnap(c, opts, "", ifacetest.SambaYamlV1, 0)
+		c.Check(osutil.FileExists(filepath.Join(dirs.SnapLandlockDir, "snap.samba.smbd")), Equals, false, Commentf("%+v", opts))
+		s.RemoveSnap(c, snapInfo)
+	}
+}
+
+func (s *backendSuite) TestNoProfilesWithoutLandlockConfinement(c *C) {
+	snapInfo := s.InstallSnap(c, interfaces.ConfinementOptions{}, "", ifacetest.SambaYamlV1, 0)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapLandlockDir, "snap.samba.smbd")), Equals, true)
+
+	// profiles are removed once landlock confinement is not used anymore
+	restore := landlock.MockLandlockConfinement(false)
+	defer restore()
+	s.UpdateSnap(c, snapInfo, interfaces.ConfinementOptions{}, ifacetest.SambaYamlV1, 0)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapLandlockDir, "snap.samba.smbd")), Equals, false)
+}
+
+func (s *backendSuite) TestUpdatingSnapToOneWithFewerApps(c *C) {
+	snapInfo := s.InstallSnap(c, interfaces.ConfinementOptions{}, "", ifacetest.SambaYamlV1WithNmbd, 0)
+	s.UpdateSnap(c, snapInfo, interfaces.ConfinementOptions{}, ifacetest.SambaYamlV1, 0)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapLandlockDir, "snap.samba.smbd")), Equals, true)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapLandlockDir, "snap.samba.nmbd")), Equals, false)
+}
+
+func (s *backendSuite) TestSandboxFeatures(c *C) {
+	restore := landlock_sandbox.MockABIVersion(2)
+	defer restore()
+	c.Check(s.Backend.SandboxFeatures(), DeepEquals, []string{"abi:1", "abi:2", "confinement"})
+
+	restore = landlock.MockLandlockConfinement(false)
+	defer restore()
+	c.Check(s.Backend.SandboxFeatures(), DeepEquals, []string{"abi:1", "abi:2"})
+
+	restore = landlock_sandbox.MockABIVersion(0)
+	defer restore()
+	c.Check(s.Backend.SandboxFeatures(), HasLen, 0)
+}
@@ -0,0 +1,188 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package landlock
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	landlock_sandbox "github.com/snapcore/snapd/sandbox/landlock"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// Specification assists in collecting the file hierarchies that interfaces
+// grant access to.
+type Specification struct {
+	// scope for the Allow{Read,Write} functions
+	securityTags []string
+
+	// rules are indexed by security tag, they are kept in the form used
+	// in the landlock profiles.
+	rules map[string]*strutil.OrderedSet
+}
+
+// setScope sets the scope of subsequent Allow{Read,Write} functions.
+// The returned function resets the scope to an empty scope.
+func (spec *Specification) setScope(securityTags []string) (restore func()) {
+	spec.securityTags = securityTags
+	return func() {
+		spec.securityTags = nil
+	}
+}
+
+func (spec *Specification) addRule(access landlock_sandbox.AccessFS, path string) {
+	if len(spec.securityTags) == 0 {
+		return
+	}
+	if spec.rules == nil {
+		spec.rules = make(map[string]*strutil.OrderedSet)
+	}
+	rule := landlock_sandbox.Rule{Access: access, Path: path}.String()
+	for _, tag := range spec.securityTags {
+		bag := spec.rules[tag]
+		if bag == nil {
+			bag = &strutil.OrderedSet{}
+			spec.rules[tag] = bag
+		}
+		bag.Put(rule)
+	}
+}
+
+// AllowRead grants all applications and hooks using the interface read
+// access to the file hierarchy rooted at path. The path may start with
+// $SNAP_REAL_HOME or other variables snapd sets for the snap, and may use
+// the patterns of filepath.Match, such as /dev/video[0-9]*, which are all
+// expanded when the profile is applied. Patterns do not match hidden files
+// unless they start with a dot.
+func (spec *Specification) AllowRead(path string) {
+	spec.addRule(landlock_sandbox.AccessRead, path)
+}
+
+// AllowWrite grants all applications and hooks using the interface write
+// access to the file hierarchy rooted at path, see AllowRead.
+func (spec *Specification) AllowWrite(path string) {
+	spec.addRule(landlock_sandbox.AccessWrite, path)
+}
+
+// AddSnippet adds the rules of a snippet written in the format of the
+// landlock profiles, one "read <path>" or "write <path>" rule per line,
+// see AllowRead and AllowWrite.
+func (spec *Specification) AddSnippet(snippet string) error {
+	rules, err := landlock_sandbox.ReadRules(strings.NewReader(snippet))
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		spec.addRule(rule.Access, rule.Path)
+	}
+	return nil
+}
+
+// Rules returns a copy of the rules, indexed by security tag.
+func (spec *Specification) Rules() map[string][]string {
+	result := make(map[string][]string, len(spec.rules))
+	for tag, bag := range spec.rules {
+		result[tag] = bag.Items()
+	}
+	return result
+}
+
+// RulesForTag returns the rules for the given security tag.
+func (spec *Specification) RulesForTag(tag string) []string {
+	if bag := spec.rules[tag]; bag != nil {
+		return bag.Items()
+	}
+	return nil
+}
+
+// SecurityTags returns a list of security tags which have a rule.
+func (spec *Specification) SecurityTags() []string {
+	tags := make([]string, 0, len(spec.rules))
+	for tag := range spec.rules {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// InspectSnippets returns the rules, indexed by security tag, for
+// inspection.
+func (spec *Specification) InspectSnippets() map[string][]string {
+	if len(spec.rules) == 0 {
+		return nil
+	}
+	return spec.Rules()
+}
+
+// Implementation of methods required by interfaces.Specification
+
+// AddConnectedPlug records landlock-specific side-effects of having a connected plug.
+func (spec *Specification) AddConnectedPlug(iface interfaces.Interface, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	type definer interface {
+		LandlockConnectedPlug(spec *Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error
+	}
+	if iface, ok := iface.(definer); ok {
+		restore := spec.setScope(plug.SecurityTags())
+		defer restore()
+		return iface.LandlockConnectedPlug(spec, plug, slot)
+	}
+	return nil
+}
+
+// AddConnectedSlot records landlock-specific side-effects of having a connected slot.
+func (spec *Specification) AddConnectedSlot(iface interfaces.Interface, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	type definer interface {
+		LandlockConnectedSlot(spec *Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error
+	}
+	if iface, ok := iface.(definer); ok {
+		restore := spec.setScope(slot.SecurityTags())
+		defer restore()
+		return iface.LandlockConnectedSlot(spec, plug, slot)
+	}
+	return nil
+}
+
+// AddPermanentPlug records landlock-specific side-effects of having a plug.
+func (spec *Specification) AddPermanentPlug(iface interfaces.Interface, plug *snap.PlugInfo) error {
+	type definer interface {
+		LandlockPermanentPlug(spec *Specification, plug *snap.PlugInfo) error
+	}
+	if iface, ok := iface.(definer); ok {
+		restore := spec.setScope(plug.SecurityTags())
+		defer restore()
+		return iface.LandlockPermanentPlug(spec, plug)
+	}
+	return nil
+}
+
+// AddPermanentSlot records landlock-specific side-effects of having a slot.
+func (spec *Specification) AddPermanentSlot(iface interfaces.Interface, slot *snap.SlotInfo) error {
+	type definer interface {
+		LandlockPermanentSlot(spec *Specification, slot *snap.SlotInfo) error
+	}
+	if iface, ok := iface.(definer); ok {
+		restore := spec.setScope(slot.SecurityTags())
+		defer restore()
+		return iface.LandlockPermanentSlot(spec, slot)
+	}
+	return nil
+}
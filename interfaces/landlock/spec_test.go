@@ -0,0 +1,178 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package landlock_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/interfaces/landlock"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+// testInterface is an ifacetest.TestInterface that also grants access to
+// the filesystem through the landlock backend.
+type testInterface struct {
+	ifacetest.TestInterface
+
+	connectedPlug func(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error
+	connectedSlot func(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error
+	permanentPlug func(spec *landlock.Specification, plug *snap.PlugInfo) error
+	permanentSlot func(spec *landlock.Specification, slot *snap.SlotInfo) error
+}
+
+func (t *testInterface) LandlockConnectedPlug(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	if t.connectedPlug != nil {
+		return t.connectedPlug(spec, plug, slot)
+	}
+	return nil
+}
+
+func (t *testInterface) LandlockConnectedSlot(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	if t.connectedSlot != nil {
+		return t.connectedSlot(spec, plug, slot)
+	}
+	return nil
+}
+
+func (t *testInterface) LandlockPermanentPlug(spec *landlock.Specification, plug *snap.PlugInfo) error {
+	if t.permanentPlug != nil {
+		return t.permanentPlug(spec, plug)
+	}
+	return nil
+}
+
+func (t *testInterface) LandlockPermanentSlot(spec *landlock.Specification, slot *snap.SlotInfo) error {
+	if t.permanentSlot != nil {
+		return t.permanentSlot(spec, slot)
+	}
+	return nil
+}
+
+type specSuite struct {
+	iface    *testInterface
+	spec     *landlock.Specification
+	plugInfo *snap.PlugInfo
+	plug     *interfaces.ConnectedPlug
+	slotInfo *snap.SlotInfo
+	slot     *interfaces.ConnectedSlot
+}
+
+var _ = Suite(&specSuite{
+	iface: &testInterface{
+		TestInterface: ifacetest.TestInterface{InterfaceName: "test"},
+		connectedPlug: func(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+			spec.AllowWrite("$SNAP_REAL_HOME/connected-plug")
+			return nil
+		},
+		connectedSlot: func(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+			spec.AllowWrite("/connected-slot")
+			return nil
+		},
+		permanentPlug: func(spec *landlock.Specification, plug *snap.PlugInfo) error {
+			spec.AllowRead("/permanent-plug")
+			// duplicated rules are dropped
+			spec.AllowRead("/permanent-plug")
+			return nil
+		},
+		permanentSlot: func(spec *landlock.Specification, slot *snap.SlotInfo) error {
+			spec.AllowRead("/permanent-slot")
+			return nil
+		},
+	},
+})
+
+const specSnapYaml = `name: snap
+version: 0
+apps:
+    app1:
+        plugs: [plug]
+    app2:
+        slots: [slot]
+plugs:
+    plug:
+        interface: test
+slots:
+    slot:
+        interface: test
+`
+
+func (s *specSuite) SetUpTest(c *C) {
+	s.spec = &landlock.Specification{}
+	info := snaptest.MockInfo(c, specSnapYaml, nil)
+	s.plugInfo = info.Plugs["plug"]
+	s.slotInfo = info.Slots["slot"]
+	s.plug = interfaces.NewConnectedPlug(s.plugInfo, nil, nil)
+	s.slot = interfaces.NewConnectedSlot(s.slotInfo, nil, nil)
+}
+
+// The landlock.Specification can be used through the interfaces.Specification interface
+func (s *specSuite) TestSpecificationIface(c *C) {
+	var r interfaces.Specification = s.spec
+	c.Assert(r.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(r.AddConnectedSlot(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(r.AddPermanentPlug(s.iface, s.plugInfo), IsNil)
+	c.Assert(r.AddPermanentSlot(s.iface, s.slotInfo), IsNil)
+	c.Check(s.spec.Rules(), DeepEquals, map[string][]string{
+		"snap.snap.app1": {"write $SNAP_REAL_HOME/connected-plug", "read /permanent-plug"},
+		"snap.snap.app2": {"write /connected-slot", "read /permanent-slot"},
+	})
+	c.Check(s.spec.SecurityTags(), DeepEquals, []string{"snap.snap.app1", "snap.snap.app2"})
+	c.Check(s.spec.RulesForTag("snap.snap.app2"), DeepEquals, []string{"write /connected-slot", "read /permanent-slot"})
+	c.Check(s.spec.RulesForTag("snap.snap.app3"), IsNil)
+}
+
+// Rules are only added within the scope of a plug or slot
+func (s *specSuite) TestNoScope(c *C) {
+	s.spec.AllowRead("/foo")
+	c.Check(s.spec.Rules(), HasLen, 0)
+}
+
+func (s *specSuite) TestInspectSnippets(c *C) {
+	var r interfaces.InspectableSpecification = s.spec
+	c.Check(r.InspectSnippets(), IsNil)
+
+	c.Assert(s.spec.AddPermanentPlug(s.iface, s.plugInfo), IsNil)
+	c.Check(r.InspectSnippets(), DeepEquals, map[string][]string{
+		"snap.snap.app1": {"read /permanent-plug"},
+	})
+}
+
+func (s *specSuite) TestAddSnippet(c *C) {
+	snippet := `
+# devices
+write /dev/kvm
+read /dev/dri
+`
+	iface := &testInterface{
+		TestInterface: ifacetest.TestInterface{InterfaceName: "test"},
+		permanentPlug: func(spec *landlock.Specification, plug *snap.PlugInfo) error {
+			return spec.AddSnippet(snippet)
+		},
+	}
+	c.Assert(s.spec.AddPermanentPlug(iface, s.plugInfo), IsNil)
+	c.Check(s.spec.RulesForTag("snap.snap.app1"), DeepEquals, []string{"write /dev/kvm", "read /dev/dri"})
+
+	snippet = "execute /dev/kvm"
+	err := s.spec.AddPermanentPlug(iface, s.plugInfo)
+	c.Check(err, ErrorMatches, `cannot parse landlock rule "execute /dev/kvm": unknown access "execute"`)
+}
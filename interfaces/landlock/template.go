@@ -0,0 +1,102 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package landlock
+
+// defaultTemplate contains the rules common to all the landlock profiles.
+//
+// The profiles are applied by snap-exec inside the mount namespace of the
+// snap, so the system directories are those of the base snap. Everything
+// that is not listed here, nor granted by an interface, is denied, most
+// notably the home directory of the user outside of the snap directories,
+// the devices, and the kernel interfaces in /proc and /sys.
+//
+// Landlock rules grant access to whole file hierarchies and cannot deny
+// parts of them, rules that AppArmor expresses with patterns are
+// approximated by granting the closest hierarchy, or the individual files
+// when the hierarchy holds more than the AppArmor template allows.
+var defaultTemplate = `
+# Description: Allows access to the system directories of the base snap
+# and of the host exposed in the mount namespace of the snap, as well as
+# to the data directories of the snap.
+
+read /bin
+read /sbin
+read /lib
+read /lib32
+read /lib64
+read /libx32
+read /usr
+read /etc
+read /opt
+read /snap
+read /var/lib/snapd
+
+# /etc/resolv.conf is usually a symlink to one of these
+read /run/systemd/resolve
+read /run/resolvconf
+read /run/NetworkManager/resolv.conf
+
+# Information about the system, as in the AppArmor template. The process
+# directory of the application is resolved when snap-exec applies the
+# profile, the processes it starts cannot read their own.
+read /proc/self
+read /proc/thread-self
+read /proc/cpuinfo
+read /proc/meminfo
+read /proc/stat
+read /proc/loadavg
+read /proc/uptime
+read /proc/version
+read /proc/filesystems
+read /proc/sys/kernel/hostname
+read /proc/sys/kernel/osrelease
+read /proc/sys/kernel/ostype
+read /proc/sys/kernel/pid_max
+read /proc/sys/kernel/random/boot_id
+read /proc/sys/kernel/random/uuid
+read /proc/sys/kernel/cap_last_cap
+read /proc/sys/kernel/yama/ptrace_scope
+read /proc/sys/vm/overcommit_memory
+read /proc/sys/fs/file-max
+read /proc/sys/fs/inotify/max_user_watches
+read /proc/sys/net/core/somaxconn
+read /sys/devices/system/cpu
+read /sys/devices/system/node/node[0-9]*
+read /sys/kernel/mm/transparent_hugepage/enabled
+read /sys/kernel/mm/transparent_hugepage/defrag
+
+write /tmp
+write /var/tmp
+write /dev/shm
+write /dev/null
+write /dev/zero
+write /dev/full
+write /dev/random
+write /dev/urandom
+write /dev/tty
+write /dev/ptmx
+write /dev/pts
+
+write $SNAP_DATA
+write $SNAP_COMMON
+write $SNAP_USER_DATA
+write $SNAP_USER_COMMON
+write $XDG_RUNTIME_DIR
+`
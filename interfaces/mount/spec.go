@@ -162,6 +162,23 @@ func (spec *Specification) MountEntries() []osutil.MountEntry {
 	return unclashMountEntries(result)
 }
 
+// InspectSnippets returns the added mount entries, and the user mount
+// entries marked as such, in fstab format for inspection. They apply to the
+// whole snap.
+func (spec *Specification) InspectSnippets() map[string][]string {
+	var snippets []string
+	for _, e := range spec.MountEntries() {
+		snippets = append(snippets, e.String())
+	}
+	for _, e := range spec.UserMountEntries() {
+		snippets = append(snippets, "# per-user mount namespace\n"+e.String())
+	}
+	if len(snippets) == 0 {
+		return nil
+	}
+	return map[string][]string{"": snippets}
+}
+
 // UserMountEntries returns a copy of the added user mount entries.
 func (spec *Specification) UserMountEntries() []osutil.MountEntry {
 	result := make([]osutil.MountEntry, len(spec.user))
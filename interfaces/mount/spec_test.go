@@ -286,3 +286,17 @@ func (s *specSuite) TestParallelInstanceMountEntriesReal(c *C) {
 	})
 	c.Assert(s.spec.UserMountEntries(), HasLen, 0)
 }
+
+func (s *specSuite) TestInspectSnippets(c *C) {
+	var r interfaces.InspectableSpecification = s.spec
+	c.Check(r.InspectSnippets(), IsNil)
+
+	c.Assert(s.spec.AddMountEntry(osutil.MountEntry{Name: "/src", Dir: "/dst", Type: "none", Options: []string{"bind", "ro"}}), IsNil)
+	c.Assert(s.spec.AddUserMountEntry(osutil.MountEntry{Name: "/usrc", Dir: "/udst", Type: "none", Options: []string{"bind"}}), IsNil)
+	c.Check(r.InspectSnippets(), DeepEquals, map[string][]string{
+		"": {
+			"/src /dst none bind,ro 0 0",
+			"# per-user mount namespace\n/usrc /udst none bind 0 0",
+		},
+	})
+}
@@ -0,0 +1,391 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package nftables implements integration between snapd and nftables,
+// which filters the outgoing network traffic of snap services.
+//
+// Interfaces may restrict the egress of the snaps plugging them to some
+// hosts and ports by providing rules via their respective "Nftables*"
+// methods for the interfaces.SecurityNftables security system. For each
+// system service of a snap whose egress is filtered, the backend stores a
+// ruleset under /var/lib/snapd/nftables, named after its security tag. The
+// ruleset defines a table which rejects the traffic sent from the cgroup
+// of the service to anything but the loopback interface, the established
+// connections and the allowed hosts and ports. Name resolution must thus
+// go through a resolver listening on the loopback interface, or be allowed
+// explicitly.
+//
+// As nftables resolves cgroups when the rules are loaded, and systemd
+// creates a new cgroup whenever a service starts, the ruleset is loaded by
+// a drop-in of the service right before it starts, as well as by the
+// backend itself when the service is already running. The cgroup of the
+// service is only known at that point, it depends on the quota group of the
+// snap, so the stored ruleset refers to it through placeholders which are
+// replaced when it is loaded.
+//
+// Only the egress of system services is filtered. Other applications, user
+// services and hooks run in transient scopes which cannot be matched in
+// advance, so rules for them are ignored; the network interface refuses to
+// restrict plugs bound to them rather than leave them unfiltered.
+package nftables
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/snap"
+	sysd "github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/timings"
+)
+
+const dropInName = "snap-nftables.conf"
+
+const (
+	// cgroupPlaceholder stands for the path of the cgroup of the service
+	// in the stored ruleset, relative to the root of the cgroup hierarchy
+	cgroupPlaceholder = "@CGROUP@"
+	// cgroupLevelPlaceholder stands for the depth of the cgroup of the
+	// service in the stored ruleset
+	cgroupLevelPlaceholder = "@CGROUP_LEVEL@"
+)
+
+var supported = func() bool {
+	return cgroup.IsUnified() && osutil.ExecutableExists("nft")
+}
+
+// Supported returns whether the egress of snap services can be filtered,
+// which requires the unified cgroup hierarchy and the nft command.
+func Supported() bool {
+	return supported()
+}
+
+func nftCommand() string {
+	return osutil.LookPathDefault("nft", "/usr/sbin/nft")
+}
+
+func runNft(stdin []byte, args ...string) error {
+	cmd := exec.Command(nftCommand(), args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return osutil.OutputErr(output, err)
+	}
+	return nil
+}
+
+// Backend is responsible for maintaining the nftables rulesets filtering
+// the egress of snap services.
+type Backend struct {
+	preseed bool
+}
+
+// Initialize does nothing but recording whether the system is being
+// preseeded, in which case rulesets are not loaded.
+func (b *Backend) Initialize(opts *interfaces.SecurityBackendOptions) error {
+	if opts != nil && opts.Preseed {
+		b.preseed = true
+	}
+	return nil
+}
+
+// Name returns the name of the backend.
+func (b *Backend) Name() interfaces.SecuritySystem {
+	return interfaces.SecurityNftables
+}
+
+// service describes a service whose egress is filtered.
+type service struct {
+	securityTag string
+	unit        string
+}
+
+func (s *service) rulesetPath() string {
+	return filepath.Join(dirs.SnapNftablesDir, s.securityTag+".nft")
+}
+
+func dropInPath(unit string) string {
+	return filepath.Join(dirs.SnapServicesDir, unit+".d", dropInName)
+}
+
+func dropInGlob(snapName string) string {
+	return filepath.Join(dirs.SnapServicesDir, interfaces.SecurityTagGlob(snapName)+".service.d", dropInName)
+}
+
+// Setup creates the nftables rulesets of the services of a snap whose
+// egress is filtered and loads them if the services are running. The
+// rulesets of the services whose egress is no longer filtered are
+// unloaded and removed.
+//
+// This method should be called after changing plug, slots, connections
+// between them or application present in the snap.
+func (b *Backend) Setup(snapInfo *snap.Info, opts interfaces.ConfinementOptions, repo *interfaces.Repository, tm timings.Measurer) error {
+	snapName := snapInfo.InstanceName()
+	spec, err := repo.SnapSpecification(b.Name(), snapName)
+	if err != nil {
+		return fmt.Errorf("cannot obtain nftables specification for snap %q: %s", snapName, err)
+	}
+
+	services := filteredServices(spec.(*Specification), snapInfo)
+	content := make(map[string]osutil.FileState, len(services))
+	dropIns := make(map[string]osutil.FileState, len(services))
+	for _, s := range services {
+		content[filepath.Base(s.rulesetPath())] = &osutil.MemoryFileState{
+			Content: generateRuleset(s, spec.(*Specification).RulesForTag(s.securityTag)),
+			Mode:    0644,
+		}
+		dropIns[dropInPath(s.unit)] = &osutil.MemoryFileState{
+			Content: generateDropIn(s),
+			Mode:    0644,
+		}
+	}
+
+	dir := dirs.SnapNftablesDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create directory for nftables rulesets %q: %s", dir, err)
+	}
+	changed, removed, err := osutil.EnsureDirState(dir, interfaces.SecurityTagGlob(snapName)+".nft", content)
+	if err != nil {
+		return fmt.Errorf("cannot synchronize nftables rulesets for snap %q: %s", snapName, err)
+	}
+	if err := b.ensureDropIns(snapName, dropIns); err != nil {
+		return err
+	}
+	if b.preseed {
+		return nil
+	}
+
+	b.unloadRulesets(removed)
+	for _, name := range changed {
+		for _, s := range services {
+			if filepath.Base(s.rulesetPath()) != name {
+				continue
+			}
+			// the ruleset is loaded by the drop-in when the service
+			// starts, load it now only if the service is running
+			cgroup := serviceCgroup(s.unit)
+			if cgroup == "" {
+				continue
+			}
+			ruleset := instantiateRuleset(content[name].(*osutil.MemoryFileState).Content, cgroup)
+			if err := runNft(ruleset, "-f", "-"); err != nil {
+				return fmt.Errorf("cannot load nftables ruleset for %q: %v", s.securityTag, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Remove unloads and removes the nftables rulesets of a given snap.
+func (b *Backend) Remove(snapName string) error {
+	_, removed, err := osutil.EnsureDirState(dirs.SnapNftablesDir, interfaces.SecurityTagGlob(snapName)+".nft", nil)
+	if err != nil {
+		return fmt.Errorf("cannot synchronize nftables rulesets for snap %q: %s", snapName, err)
+	}
+	if err := b.ensureDropIns(snapName, nil); err != nil {
+		return err
+	}
+	if !b.preseed {
+		b.unloadRulesets(removed)
+	}
+	return nil
+}
+
+// ensureDropIns ensures that the drop-ins loading the rulesets of the
+// services of a snap are the given ones, and reloads systemd when they
+// change.
+func (b *Backend) ensureDropIns(snapName string, dropIns map[string]osutil.FileState) error {
+	changed := false
+	for path, state := range dropIns {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("cannot create directory for systemd drop-in %q: %s", path, err)
+		}
+		err := osutil.EnsureFileState(path, state)
+		if err == osutil.ErrSameState {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("cannot write systemd drop-in %q: %s", path, err)
+		}
+		changed = true
+	}
+
+	existing, err := filepath.Glob(dropInGlob(snapName))
+	if err != nil {
+		return err
+	}
+	for _, path := range existing {
+		if _, ok := dropIns[path]; ok {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot remove systemd drop-in %q: %s", path, err)
+		}
+		// the drop-in directory is removed if the drop-in was the only
+		// file in it
+		os.Remove(filepath.Dir(path))
+		changed = true
+	}
+
+	if changed && !b.preseed {
+		systemd := sysd.New(sysd.SystemMode, &noopReporter{})
+		if err := systemd.DaemonReload(); err != nil {
+			logger.Noticef("cannot reload systemd state: %s", err)
+		}
+	}
+	return nil
+}
+
+// unloadRulesets deletes the tables defined by the given rulesets.
+func (b *Backend) unloadRulesets(rulesets []string) {
+	for _, name := range rulesets {
+		table := strings.TrimSuffix(name, ".nft")
+		// declaring the table before deleting it does not fail if the
+		// ruleset was not loaded
+		if err := runNft([]byte(fmt.Sprintf("table inet %[1]s\ndelete table inet %[1]s\n", table)), "-f", "-"); err != nil {
+			logger.Noticef("cannot unload nftables ruleset for %q: %v", table, err)
+		}
+	}
+}
+
+// filteredServices returns the system services of a snap whose egress is
+// filtered.
+func filteredServices(spec *Specification, snapInfo *snap.Info) []*service {
+	var services []*service
+	for _, tag := range spec.SecurityTags() {
+		for _, app := range snapInfo.Apps {
+			if app.SecurityTag() != tag || !app.IsService() || app.DaemonScope != snap.SystemDaemon {
+				continue
+			}
+			services = append(services, &service{
+				securityTag: tag,
+				unit:        app.ServiceName(),
+			})
+		}
+	}
+	return services
+}
+
+// serviceCgroup returns the path of the cgroup of the given service,
+// relative to the root of the cgroup hierarchy, or an empty string if the
+// service is not running. The cgroup is looked for in the slices, which
+// are nested when the snap is in a quota group.
+func serviceCgroup(unit string) string {
+	root := filepath.Join(dirs.GlobalRootDir, "/sys/fs/cgroup")
+	var find func(dir string) string
+	find = func(dir string) string {
+		if osutil.IsDirectory(filepath.Join(root, dir, unit)) {
+			return filepath.Join(dir, unit)
+		}
+		entries, err := ioutil.ReadDir(filepath.Join(root, dir))
+		if err != nil {
+			return ""
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || !strings.HasSuffix(entry.Name(), ".slice") {
+				continue
+			}
+			if cgroup := find(filepath.Join(dir, entry.Name())); cgroup != "" {
+				return cgroup
+			}
+		}
+		return ""
+	}
+	return find("")
+}
+
+// instantiateRuleset replaces the placeholders of a stored ruleset with the
+// given cgroup, in the same way as the drop-in does when the service starts.
+func instantiateRuleset(ruleset []byte, cgroup string) []byte {
+	level := strings.Count(cgroup, "/") + 1
+	ruleset = bytes.Replace(ruleset, []byte(cgroupPlaceholder), []byte(cgroup), -1)
+	return bytes.Replace(ruleset, []byte(cgroupLevelPlaceholder), []byte(fmt.Sprint(level)), -1)
+}
+
+func generateRuleset(s *service, rules []string) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("# This file is automatically generated by snapd.\n")
+	// the table is declared and deleted first so that loading the ruleset
+	// replaces the previous one
+	fmt.Fprintf(&buffer, "table inet %[1]s\ndelete table inet %[1]s\n", s.securityTag)
+	fmt.Fprintf(&buffer, "table inet %s {\n", s.securityTag)
+	buffer.WriteString("\tchain output {\n")
+	buffer.WriteString("\t\ttype filter hook output priority 0; policy accept;\n")
+	fmt.Fprintf(&buffer, "\t\tsocket cgroupv2 level %s \"%s\" jump egress\n", cgroupLevelPlaceholder, cgroupPlaceholder)
+	buffer.WriteString("\t}\n")
+	buffer.WriteString("\tchain egress {\n")
+	buffer.WriteString("\t\toifname \"lo\" accept\n")
+	buffer.WriteString("\t\tct state established,related accept\n")
+	for _, rule := range rules {
+		fmt.Fprintf(&buffer, "\t\t%s\n", rule)
+	}
+	buffer.WriteString("\t\treject with icmpx type admin-prohibited\n")
+	buffer.WriteString("\t}\n")
+	buffer.WriteString("}\n")
+	return buffer.Bytes()
+}
+
+func generateDropIn(s *service) []byte {
+	// the command runs in the cgroup of the service, which it reads from
+	// /proc/self/cgroup to replace the placeholders of the ruleset, see
+	// instantiateRuleset; "$$" stands for "$" in systemd command lines. A
+	// failure to load the ruleset prevents the service from starting
+	// rather than letting it run without filtering
+	script := fmt.Sprintf(`cgroup=$$(sed -n "s|^0::/||p" /proc/self/cgroup) && `+
+		`level=$$(echo "$$cgroup/" | tr -cd / | wc -c) && `+
+		`ruleset=$$(sed -e "s|%s|$$cgroup|g" -e "s|%s|$$level|g" %s) && `+
+		`echo "$$ruleset" | %s -f -`,
+		cgroupPlaceholder, cgroupLevelPlaceholder, s.rulesetPath(), nftCommand())
+	return []byte(fmt.Sprintf("[Service]\nExecStartPre=+/bin/sh -c '%s'\n", script))
+}
+
+// NewSpecification returns an empty nftables specification.
+func (b *Backend) NewSpecification() interfaces.Specification {
+	return &Specification{}
+}
+
+// SandboxFeatures returns the list of features supported by the nftables
+// backend.
+func (b *Backend) SandboxFeatures() []string {
+	return []string{"egress-filtering"}
+}
+
+type noopReporter struct{}
+
+func (dr *noopReporter) Notify(msg string) {
+}
+
+// MockSupported makes Supported return the given value.
+func MockSupported(value bool) (restore func()) {
+	old := supported
+	supported = func() bool { return value }
+	return func() {
+		supported = old
+	}
+}
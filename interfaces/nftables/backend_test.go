@@ -0,0 +1,243 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package nftables_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/interfaces/nftables"
+	"github.com/snapcore/snapd/osutil"
+	sysd "github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type backendSuite struct {
+	ifacetest.BackendSuite
+
+	iface *egressInterface
+
+	nft            *testutil.MockCmd
+	systemctlCalls [][]string
+}
+
+var _ = Suite(&backendSuite{})
+
+const sambaYaml = `
+name: samba
+version: 1
+developer: acme
+apps:
+    smbd:
+        daemon: simple
+    nmbd:
+slots:
+    slot:
+        interface: iface
+`
+
+func (s *backendSuite) SetUpTest(c *C) {
+	s.Backend = &nftables.Backend{}
+	s.BackendSuite.SetUpTest(c)
+
+	s.nft = testutil.MockCommand(c, "nft", "")
+	s.AddCleanup(s.nft.Restore)
+	s.systemctlCalls = nil
+	s.AddCleanup(sysd.MockSystemctl(func(args ...string) ([]byte, error) {
+		s.systemctlCalls = append(s.systemctlCalls, args)
+		return nil, nil
+	}))
+
+	// use an interface restricting the egress instead of the one of the
+	// BackendSuite
+	s.iface = &egressInterface{
+		TestInterface: ifacetest.TestInterface{InterfaceName: "iface"},
+		egress:        &nftables.Egress{Hosts: []string{"192.0.2.1"}, Ports: []string{"443/tcp"}},
+	}
+	s.Repo = interfaces.NewRepository()
+	c.Assert(s.Repo.AddInterface(s.iface), IsNil)
+	c.Assert(s.Repo.AddBackend(s.Backend), IsNil)
+}
+
+func (s *backendSuite) TestName(c *C) {
+	c.Check(s.Backend.Name(), Equals, interfaces.SecurityNftables)
+}
+
+func (s *backendSuite) rulesetPath() string {
+	return filepath.Join(dirs.SnapNftablesDir, "snap.samba.smbd.nft")
+}
+
+func (s *backendSuite) dropInPath() string {
+	return filepath.Join(dirs.SnapServicesDir, "snap.samba.smbd.service.d", "snap-nftables.conf")
+}
+
+func (s *backendSuite) TestInstallingSnapWritesRulesets(c *C) {
+	snapInfo := s.InstallSnap(c, interfaces.ConfinementOptions{}, "", sambaYaml, 0)
+
+	c.Check(s.rulesetPath(), testutil.FileEquals, `# This file is automatically generated by snapd.
+table inet snap.samba.smbd
+delete table inet snap.samba.smbd
+table inet snap.samba.smbd {
+	chain output {
+		type filter hook output priority 0; policy accept;
+		socket cgroupv2 level @CGROUP_LEVEL@ "@CGROUP@" jump egress
+	}
+	chain egress {
+		oifname "lo" accept
+		ct state established,related accept
+		ip daddr 192.0.2.1 tcp dport 443 accept
+		reject with icmpx type admin-prohibited
+	}
+}
+`)
+	c.Check(s.dropInPath(), testutil.FileEquals, fmt.Sprintf(`[Service]
+ExecStartPre=+/bin/sh -c 'cgroup=$$(sed -n "s|^0::/||p" /proc/self/cgroup) && level=$$(echo "$$cgroup/" | tr -cd / | wc -c) && ruleset=$$(sed -e "s|@CGROUP@|$$cgroup|g" -e "s|@CGROUP_LEVEL@|$$level|g" %s) && echo "$$ruleset" | %s -f -'
+`, s.rulesetPath(), s.nft.Exe()))
+	// nmbd is not a service
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapNftablesDir, "snap.samba.nmbd.nft")), Equals, false)
+	c.Check(s.systemctlCalls, DeepEquals, [][]string{{"daemon-reload"}})
+	// the service is not running
+	c.Check(s.nft.Calls(), HasLen, 0)
+
+	s.systemctlCalls = nil
+	s.RemoveSnap(c, snapInfo)
+	c.Check(osutil.FileExists(s.rulesetPath()), Equals, false)
+	c.Check(osutil.FileExists(filepath.Dir(s.dropInPath())), Equals, false)
+	c.Check(s.systemctlCalls, DeepEquals, [][]string{{"daemon-reload"}})
+	c.Check(s.nft.Calls(), DeepEquals, [][]string{{"nft", "-f", "-"}})
+}
+
+func (s *backendSuite) mockNftStdin(c *C) (stdin string) {
+	stdin = filepath.Join(c.MkDir(), "stdin")
+	nft := testutil.MockCommand(c, "nft", fmt.Sprintf("cat > %s", stdin))
+	s.AddCleanup(nft.Restore)
+	s.nft = nft
+	return stdin
+}
+
+func (s *backendSuite) TestSetupLoadsRulesetOfRunningService(c *C) {
+	stdin := s.mockNftStdin(c)
+	cgroup := filepath.Join(dirs.GlobalRootDir, "/sys/fs/cgroup/system.slice/snap.samba.smbd.service")
+	c.Assert(os.MkdirAll(cgroup, 0755), IsNil)
+
+	snapInfo := s.InstallSnap(c, interfaces.ConfinementOptions{}, "", sambaYaml, 0)
+	c.Check(s.nft.Calls(), DeepEquals, [][]string{{"nft", "-f", "-"}})
+	c.Check(stdin, testutil.FileContains,
+		`socket cgroupv2 level 2 "system.slice/snap.samba.smbd.service" jump egress`)
+
+	// an unchanged ruleset is not loaded again
+	s.nft.ForgetCalls()
+	c.Assert(s.Backend.Setup(snapInfo, interfaces.ConfinementOptions{}, s.Repo, nil), IsNil)
+	c.Check(s.nft.Calls(), HasLen, 0)
+}
+
+func (s *backendSuite) TestSetupLoadsRulesetOfRunningServiceInQuotaGroup(c *C) {
+	stdin := s.mockNftStdin(c)
+	// the service was started after the snap was added to a sub-group
+	for _, dir := range []string{"system.slice/other.service", "snap.foo.slice/snap.foo-bar.slice/snap.samba.smbd.service"} {
+		c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "/sys/fs/cgroup", dir), 0755), IsNil)
+	}
+
+	s.InstallSnap(c, interfaces.ConfinementOptions{}, "", sambaYaml, 0)
+	c.Check(s.nft.Calls(), DeepEquals, [][]string{{"nft", "-f", "-"}})
+	c.Check(stdin, testutil.FileContains,
+		`socket cgroupv2 level 3 "snap.foo.slice/snap.foo-bar.slice/snap.samba.smbd.service" jump egress`)
+}
+
+func (s *backendSuite) TestDropInLoadsRulesetOfServiceCgroup(c *C) {
+	stdin := s.mockNftStdin(c)
+	s.InstallSnap(c, interfaces.ConfinementOptions{}, "", sambaYaml, 0)
+
+	// run the command of the drop-in, which reads the cgroup it runs in
+	dropIn, err := ioutil.ReadFile(s.dropInPath())
+	c.Assert(err, IsNil)
+	script := strings.TrimPrefix(strings.TrimSpace(string(dropIn)), "[Service]\nExecStartPre=+/bin/sh -c '")
+	script = strings.Replace(strings.TrimSuffix(script, "'"), "$$", "$", -1)
+	output, err := exec.Command("/bin/sh", "-c", script).CombinedOutput()
+	c.Assert(err, IsNil, Commentf("%s", output))
+
+	procCgroup, err := ioutil.ReadFile("/proc/self/cgroup")
+	c.Assert(err, IsNil)
+	var cgroup string
+	for _, line := range strings.Split(string(procCgroup), "\n") {
+		if strings.HasPrefix(line, "0::/") {
+			cgroup = strings.TrimPrefix(line, "0::/")
+		}
+	}
+	c.Check(stdin, testutil.FileContains,
+		fmt.Sprintf("socket cgroupv2 level %d %q jump egress", strings.Count(cgroup, "/")+1, cgroup))
+	c.Check(stdin, testutil.FileContains, "ip daddr 192.0.2.1 tcp dport 443 accept")
+}
+
+func (s *backendSuite) TestSetupLoadRulesetError(c *C) {
+	snapInfo := s.InstallSnap(c, interfaces.ConfinementOptions{}, "", sambaYaml, 0)
+
+	cgroup := filepath.Join(dirs.GlobalRootDir, "/sys/fs/cgroup/system.slice/snap.samba.smbd.service")
+	c.Assert(os.MkdirAll(cgroup, 0755), IsNil)
+	nft := testutil.MockCommand(c, "nft", "echo 'Error: syntax error'; exit 1")
+	defer nft.Restore()
+	s.iface.egress = &nftables.Egress{Ports: []string{"443/tcp"}}
+	err := s.Backend.Setup(snapInfo, interfaces.ConfinementOptions{}, s.Repo, nil)
+	c.Check(err, ErrorMatches, `cannot load nftables ruleset for "snap.samba.smbd": Error: syntax error`)
+}
+
+func (s *backendSuite) TestUnrestrictedEgressRemovesRuleset(c *C) {
+	snapInfo := s.InstallSnap(c, interfaces.ConfinementOptions{}, "", sambaYaml, 0)
+	c.Check(osutil.FileExists(s.rulesetPath()), Equals, true)
+
+	s.iface.egress = nil
+	s.systemctlCalls = nil
+	c.Assert(s.Backend.Setup(snapInfo, interfaces.ConfinementOptions{}, s.Repo, nil), IsNil)
+	c.Check(osutil.FileExists(s.rulesetPath()), Equals, false)
+	c.Check(osutil.FileExists(s.dropInPath()), Equals, false)
+	c.Check(s.systemctlCalls, DeepEquals, [][]string{{"daemon-reload"}})
+	c.Check(s.nft.Calls(), DeepEquals, [][]string{{"nft", "-f", "-"}})
+}
+
+func (s *backendSuite) TestPreseed(c *C) {
+	s.Backend = &nftables.Backend{}
+	c.Assert(s.Backend.Initialize(&interfaces.SecurityBackendOptions{Preseed: true}), IsNil)
+	cgroup := filepath.Join(dirs.GlobalRootDir, "/sys/fs/cgroup/system.slice/snap.samba.smbd.service")
+	c.Assert(os.MkdirAll(cgroup, 0755), IsNil)
+
+	s.InstallSnap(c, interfaces.ConfinementOptions{}, "", sambaYaml, 0)
+	c.Check(osutil.FileExists(s.rulesetPath()), Equals, true)
+	c.Check(osutil.FileExists(s.dropInPath()), Equals, true)
+	c.Check(s.systemctlCalls, HasLen, 0)
+	c.Check(s.nft.Calls(), HasLen, 0)
+}
+
+func (s *backendSuite) TestSandboxFeatures(c *C) {
+	c.Check(s.Backend.SandboxFeatures(), DeepEquals, []string{"egress-filtering"})
+}
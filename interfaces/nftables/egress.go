@@ -0,0 +1,187 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package nftables
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Egress describes the outgoing traffic allowed by an interface.
+//
+// Hosts are IPv4 or IPv6 addresses, or networks in CIDR notation. Host
+// names are not supported as they would be resolved only once, when the
+// rules are loaded. Ports are port numbers or ranges of port numbers,
+// optionally followed by the protocol, e.g. "443", "53/udp" or
+// "8000-8080/tcp"; ports without a protocol apply to both tcp and udp.
+//
+// An empty list of hosts allows any host on the given ports and an empty
+// list of ports allows any port of the given hosts.
+type Egress struct {
+	Hosts []string
+	Ports []string
+}
+
+var egressProtocols = []string{"tcp", "udp"}
+
+// Validate checks that the hosts and ports are well formed.
+func (e *Egress) Validate() error {
+	if len(e.Hosts) == 0 && len(e.Ports) == 0 {
+		return fmt.Errorf("egress must allow some hosts or ports")
+	}
+	for _, host := range e.Hosts {
+		if _, err := parseHost(host); err != nil {
+			return err
+		}
+	}
+	for _, port := range e.Ports {
+		if _, _, err := parsePort(port); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseHost returns the nftables family of the given address or network.
+func parseHost(host string) (family string, err error) {
+	var ip net.IP
+	if strings.Contains(host, "/") {
+		ip, _, err = net.ParseCIDR(host)
+		if err != nil {
+			return "", fmt.Errorf("invalid host %q: not a valid network in CIDR notation", host)
+		}
+	} else {
+		ip = net.ParseIP(host)
+		if ip == nil {
+			return "", fmt.Errorf("invalid host %q: must be an IP address or a network in CIDR notation", host)
+		}
+	}
+	if ip.To4() != nil {
+		return "ip", nil
+	}
+	return "ip6", nil
+}
+
+func parsePortNumber(port, number string) (int, error) {
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 || n > 65535 {
+		return 0, fmt.Errorf("invalid port %q: port numbers must be between 1 and 65535", port)
+	}
+	return n, nil
+}
+
+// parsePort returns the protocols and the range of ports, in nftables
+// syntax, of the given port.
+func parsePort(port string) (protocols []string, portRange string, err error) {
+	portRange = port
+	protocols = egressProtocols
+	if idx := strings.IndexRune(port, '/'); idx >= 0 {
+		portRange = port[:idx]
+		protocol := port[idx+1:]
+		if protocol != "tcp" && protocol != "udp" {
+			return nil, "", fmt.Errorf("invalid port %q: protocol must be tcp or udp", port)
+		}
+		protocols = []string{protocol}
+	}
+
+	bounds := strings.SplitN(portRange, "-", 2)
+	start, err := parsePortNumber(port, bounds[0])
+	if err != nil {
+		return nil, "", err
+	}
+	if len(bounds) == 2 {
+		end, err := parsePortNumber(port, bounds[1])
+		if err != nil {
+			return nil, "", err
+		}
+		if end < start {
+			return nil, "", fmt.Errorf("invalid port %q: range must not end before it starts", port)
+		}
+		if end > start {
+			return protocols, fmt.Sprintf("%d-%d", start, end), nil
+		}
+	}
+	return protocols, strconv.Itoa(start), nil
+}
+
+func nftSet(elements []string) string {
+	if len(elements) == 1 {
+		return elements[0]
+	}
+	return "{ " + strings.Join(elements, ", ") + " }"
+}
+
+// Rules returns the nftables rules accepting the egress, in the order of
+// the given hosts and ports.
+func (e *Egress) Rules() ([]string, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	var families []string
+	hostsByFamily := make(map[string][]string)
+	for _, host := range e.Hosts {
+		family, _ := parseHost(host)
+		if hostsByFamily[family] == nil {
+			families = append(families, family)
+		}
+		hostsByFamily[family] = append(hostsByFamily[family], host)
+	}
+	var protocols []string
+	portsByProtocol := make(map[string][]string)
+	for _, port := range e.Ports {
+		portProtocols, portRange, _ := parsePort(port)
+		for _, protocol := range portProtocols {
+			if portsByProtocol[protocol] == nil {
+				protocols = append(protocols, protocol)
+			}
+			portsByProtocol[protocol] = append(portsByProtocol[protocol], portRange)
+		}
+	}
+
+	var hostMatches, portMatches []string
+	for _, family := range families {
+		hostMatches = append(hostMatches, fmt.Sprintf("%s daddr %s", family, nftSet(hostsByFamily[family])))
+	}
+	for _, protocol := range protocols {
+		portMatches = append(portMatches, fmt.Sprintf("%s dport %s", protocol, nftSet(portsByProtocol[protocol])))
+	}
+
+	var rules []string
+	switch {
+	case len(portMatches) == 0:
+		for _, hostMatch := range hostMatches {
+			rules = append(rules, hostMatch+" accept")
+		}
+	case len(hostMatches) == 0:
+		for _, portMatch := range portMatches {
+			rules = append(rules, portMatch+" accept")
+		}
+	default:
+		for _, hostMatch := range hostMatches {
+			for _, portMatch := range portMatches {
+				rules = append(rules, hostMatch+" "+portMatch+" accept")
+			}
+		}
+	}
+	return rules, nil
+}
@@ -0,0 +1,185 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package nftables
+
+import (
+	"sort"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// Specification assists in collecting the outgoing network traffic that
+// interfaces allow.
+//
+// The traffic of a security tag is only filtered when all the interfaces
+// allowing network access to it restrict the egress, a single interface
+// allowing any egress disables the filtering.
+type Specification struct {
+	// scope for the Allow{Egress,AllEgress} functions
+	securityTags []string
+
+	// rules are indexed by security tag
+	rules map[string]*strutil.OrderedSet
+	// unrestricted contains the security tags allowed any egress
+	unrestricted map[string]bool
+}
+
+// setScope sets the scope of subsequent Allow{Egress,AllEgress} functions.
+// The returned function resets the scope to an empty scope.
+func (spec *Specification) setScope(securityTags []string) (restore func()) {
+	spec.securityTags = securityTags
+	return func() {
+		spec.securityTags = nil
+	}
+}
+
+// AllowEgress allows all applications and hooks using the interface to
+// send the given outgoing traffic.
+func (spec *Specification) AllowEgress(egress *Egress) error {
+	rules, err := egress.Rules()
+	if err != nil {
+		return err
+	}
+	if len(spec.securityTags) == 0 {
+		return nil
+	}
+	if spec.rules == nil {
+		spec.rules = make(map[string]*strutil.OrderedSet)
+	}
+	for _, tag := range spec.securityTags {
+		bag := spec.rules[tag]
+		if bag == nil {
+			bag = &strutil.OrderedSet{}
+			spec.rules[tag] = bag
+		}
+		for _, rule := range rules {
+			bag.Put(rule)
+		}
+	}
+	return nil
+}
+
+// AllowAllEgress allows all applications and hooks using the interface to
+// send any outgoing traffic, which disables the filtering of their egress.
+func (spec *Specification) AllowAllEgress() {
+	if len(spec.securityTags) == 0 {
+		return
+	}
+	if spec.unrestricted == nil {
+		spec.unrestricted = make(map[string]bool)
+	}
+	for _, tag := range spec.securityTags {
+		spec.unrestricted[tag] = true
+	}
+}
+
+// RulesForTag returns the rules accepting the egress of the given security
+// tag, or nil if its egress is not filtered.
+func (spec *Specification) RulesForTag(tag string) []string {
+	if spec.unrestricted[tag] {
+		return nil
+	}
+	if bag := spec.rules[tag]; bag != nil {
+		return bag.Items()
+	}
+	return nil
+}
+
+// SecurityTags returns a list of security tags whose egress is filtered.
+func (spec *Specification) SecurityTags() []string {
+	tags := make([]string, 0, len(spec.rules))
+	for tag := range spec.rules {
+		if !spec.unrestricted[tag] {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// InspectSnippets returns the rules, indexed by security tag, of the
+// security tags whose egress is filtered, for inspection.
+func (spec *Specification) InspectSnippets() map[string][]string {
+	tags := spec.SecurityTags()
+	if len(tags) == 0 {
+		return nil
+	}
+	result := make(map[string][]string, len(tags))
+	for _, tag := range tags {
+		result[tag] = spec.RulesForTag(tag)
+	}
+	return result
+}
+
+// Implementation of methods required by interfaces.Specification
+
+// AddConnectedPlug records nftables-specific side-effects of having a connected plug.
+func (spec *Specification) AddConnectedPlug(iface interfaces.Interface, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	type definer interface {
+		NftablesConnectedPlug(spec *Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error
+	}
+	if iface, ok := iface.(definer); ok {
+		restore := spec.setScope(plug.SecurityTags())
+		defer restore()
+		return iface.NftablesConnectedPlug(spec, plug, slot)
+	}
+	return nil
+}
+
+// AddConnectedSlot records nftables-specific side-effects of having a connected slot.
+func (spec *Specification) AddConnectedSlot(iface interfaces.Interface, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	type definer interface {
+		NftablesConnectedSlot(spec *Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error
+	}
+	if iface, ok := iface.(definer); ok {
+		restore := spec.setScope(slot.SecurityTags())
+		defer restore()
+		return iface.NftablesConnectedSlot(spec, plug, slot)
+	}
+	return nil
+}
+
+// AddPermanentPlug records nftables-specific side-effects of having a plug.
+func (spec *Specification) AddPermanentPlug(iface interfaces.Interface, plug *snap.PlugInfo) error {
+	type definer interface {
+		NftablesPermanentPlug(spec *Specification, plug *snap.PlugInfo) error
+	}
+	if iface, ok := iface.(definer); ok {
+		restore := spec.setScope(plug.SecurityTags())
+		defer restore()
+		return iface.NftablesPermanentPlug(spec, plug)
+	}
+	return nil
+}
+
+// AddPermanentSlot records nftables-specific side-effects of having a slot.
+func (spec *Specification) AddPermanentSlot(iface interfaces.Interface, slot *snap.SlotInfo) error {
+	type definer interface {
+		NftablesPermanentSlot(spec *Specification, slot *snap.SlotInfo) error
+	}
+	if iface, ok := iface.(definer); ok {
+		restore := spec.setScope(slot.SecurityTags())
+		defer restore()
+		return iface.NftablesPermanentSlot(spec, slot)
+	}
+	return nil
+}
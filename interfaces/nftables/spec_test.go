@@ -0,0 +1,193 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package nftables_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/interfaces/nftables"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+// egressInterface restricts the egress of both sides of its connections to
+// the given egress, or allows any egress when it is nil.
+type egressInterface struct {
+	ifacetest.TestInterface
+
+	egress *nftables.Egress
+}
+
+func (iface *egressInterface) allowEgress(spec *nftables.Specification) error {
+	if iface.egress == nil {
+		spec.AllowAllEgress()
+		return nil
+	}
+	return spec.AllowEgress(iface.egress)
+}
+
+func (iface *egressInterface) NftablesConnectedPlug(spec *nftables.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	return iface.allowEgress(spec)
+}
+
+func (iface *egressInterface) NftablesConnectedSlot(spec *nftables.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	return iface.allowEgress(spec)
+}
+
+func (iface *egressInterface) NftablesPermanentPlug(spec *nftables.Specification, plug *snap.PlugInfo) error {
+	return iface.allowEgress(spec)
+}
+
+func (iface *egressInterface) NftablesPermanentSlot(spec *nftables.Specification, slot *snap.SlotInfo) error {
+	return iface.allowEgress(spec)
+}
+
+type specSuite struct {
+	iface    *egressInterface
+	spec     *nftables.Specification
+	plugInfo *snap.PlugInfo
+	plug     *interfaces.ConnectedPlug
+	slotInfo *snap.SlotInfo
+	slot     *interfaces.ConnectedSlot
+}
+
+var _ = Suite(&specSuite{
+	iface: &egressInterface{
+		TestInterface: ifacetest.TestInterface{InterfaceName: "test"},
+		egress:        &nftables.Egress{Hosts: []string{"192.0.2.1"}},
+	},
+})
+
+const specSnapYaml = `name: snap
+version: 0
+apps:
+    app1:
+        plugs: [plug]
+    app2:
+        slots: [slot]
+plugs:
+    plug:
+        interface: test
+slots:
+    slot:
+        interface: test
+`
+
+func (s *specSuite) SetUpTest(c *C) {
+	s.spec = &nftables.Specification{}
+	info := snaptest.MockInfo(c, specSnapYaml, nil)
+	s.plugInfo = info.Plugs["plug"]
+	s.slotInfo = info.Slots["slot"]
+	s.plug = interfaces.NewConnectedPlug(s.plugInfo, nil, nil)
+	s.slot = interfaces.NewConnectedSlot(s.slotInfo, nil, nil)
+}
+
+// The nftables.Specification can be used through the interfaces.Specification interface
+func (s *specSuite) TestSpecificationIface(c *C) {
+	var r interfaces.Specification = s.spec
+	c.Assert(r.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(r.AddConnectedSlot(s.iface, s.plug, s.slot), IsNil)
+	// duplicated rules are dropped
+	c.Assert(r.AddPermanentPlug(s.iface, s.plugInfo), IsNil)
+	c.Check(s.spec.SecurityTags(), DeepEquals, []string{"snap.snap.app1", "snap.snap.app2"})
+	c.Check(s.spec.RulesForTag("snap.snap.app1"), DeepEquals, []string{"ip daddr 192.0.2.1 accept"})
+	c.Check(s.spec.RulesForTag("snap.snap.app2"), DeepEquals, []string{"ip daddr 192.0.2.1 accept"})
+
+	// app2 is then allowed any egress by the permanent slot
+	unrestricted := &egressInterface{TestInterface: ifacetest.TestInterface{InterfaceName: "test"}}
+	c.Assert(r.AddPermanentSlot(unrestricted, s.slotInfo), IsNil)
+	c.Check(s.spec.SecurityTags(), DeepEquals, []string{"snap.snap.app1"})
+	c.Check(s.spec.RulesForTag("snap.snap.app2"), IsNil)
+	c.Check(s.spec.RulesForTag("snap.snap.app3"), IsNil)
+}
+
+// Rules are only added within the scope of a plug or slot
+func (s *specSuite) TestNoScope(c *C) {
+	c.Assert(s.spec.AllowEgress(&nftables.Egress{Ports: []string{"443"}}), IsNil)
+	c.Check(s.spec.SecurityTags(), HasLen, 0)
+}
+
+func (s *specSuite) TestAllowEgressInvalid(c *C) {
+	iface := &egressInterface{
+		TestInterface: ifacetest.TestInterface{InterfaceName: "test"},
+		egress:        &nftables.Egress{Hosts: []string{"example.com"}},
+	}
+	err := s.spec.AddConnectedPlug(iface, s.plug, s.slot)
+	c.Check(err, ErrorMatches, `invalid host "example.com": must be an IP address or a network in CIDR notation`)
+}
+
+func (s *specSuite) TestInspectSnippets(c *C) {
+	var r interfaces.InspectableSpecification = s.spec
+	c.Check(r.InspectSnippets(), IsNil)
+
+	c.Assert(s.spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Check(r.InspectSnippets(), DeepEquals, map[string][]string{
+		"snap.snap.app1": {"ip daddr 192.0.2.1 accept"},
+	})
+}
+
+func (s *specSuite) TestEgressRules(c *C) {
+	for _, t := range []struct {
+		egress nftables.Egress
+		rules  []string
+	}{{
+		egress: nftables.Egress{Hosts: []string{"192.0.2.1", "2001:db8::/32", "198.51.100.0/24"}},
+		rules: []string{
+			"ip daddr { 192.0.2.1, 198.51.100.0/24 } accept",
+			"ip6 daddr 2001:db8::/32 accept",
+		},
+	}, {
+		egress: nftables.Egress{Ports: []string{"443", "53/udp", "8000-8080/tcp", "80-80"}},
+		rules: []string{
+			"tcp dport { 443, 8000-8080, 80 } accept",
+			"udp dport { 443, 53, 80 } accept",
+		},
+	}, {
+		egress: nftables.Egress{Hosts: []string{"192.0.2.1", "2001:db8::1"}, Ports: []string{"443/tcp"}},
+		rules: []string{
+			"ip daddr 192.0.2.1 tcp dport 443 accept",
+			"ip6 daddr 2001:db8::1 tcp dport 443 accept",
+		},
+	}} {
+		rules, err := t.egress.Rules()
+		c.Assert(err, IsNil)
+		c.Check(rules, DeepEquals, t.rules, Commentf("%+v", t.egress))
+	}
+}
+
+func (s *specSuite) TestEgressValidate(c *C) {
+	for _, t := range []struct {
+		egress nftables.Egress
+		err    string
+	}{
+		{nftables.Egress{}, `egress must allow some hosts or ports`},
+		{nftables.Egress{Hosts: []string{"192.0.2"}}, `invalid host "192.0.2": must be an IP address or a network in CIDR notation`},
+		{nftables.Egress{Hosts: []string{"192.0.2.0/"}}, `invalid host "192.0.2.0/": not a valid network in CIDR notation`},
+		{nftables.Egress{Ports: []string{"http"}}, `invalid port "http": port numbers must be between 1 and 65535`},
+		{nftables.Egress{Ports: []string{"65536"}}, `invalid port "65536": port numbers must be between 1 and 65535`},
+		{nftables.Egress{Ports: []string{"1-"}}, `invalid port "1-": port numbers must be between 1 and 65535`},
+		{nftables.Egress{Ports: []string{"80/sctp"}}, `invalid port "80/sctp": protocol must be tcp or udp`},
+		{nftables.Egress{Ports: []string{"90-80"}}, `invalid port "90-80": range must not end before it starts`},
+	} {
+		c.Check(t.egress.Validate(), ErrorMatches, t.err, Commentf("%+v", t.egress))
+	}
+}
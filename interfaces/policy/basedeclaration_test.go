@@ -1491,6 +1491,66 @@ plugs:
 	}
 }
 
+func (s *baseDeclSuite) TestNetworkEgress(c *C) {
+	for _, attrs := range []string{
+		"allow-hosts: [192.0.2.1]",
+		"allow-ports: [443]",
+		"allow-hosts: [192.0.2.1]\n    allow-ports: [443]",
+	} {
+		plugYaml := `name: plug-snap
+version: 0
+plugs:
+  network:
+    ` + attrs + `
+`
+		cand := s.connectCand(c, "network", "", plugYaml)
+		err := cand.Check()
+		c.Check(err, ErrorMatches, `connection denied by slot rule of interface "network"`, Commentf(attrs))
+		_, err = cand.CheckAutoConnect()
+		c.Check(err, ErrorMatches, `auto-connection denied by slot rule of interface "network"`, Commentf(attrs))
+	}
+
+	// the restriction must be granted by the snap declaration
+	plugYaml := `name: plug-snap
+version: 0
+plugs:
+  network:
+    allow-hosts: [192.0.2.1]
+    allow-ports: [443]
+`
+	plugsSlots := `
+plugs:
+  network:
+    allow-connection:
+      plug-attributes:
+        allow-hosts: 192\.0\.2\.1
+        allow-ports: 443
+    allow-auto-connection:
+      plug-attributes:
+        allow-hosts: 192\.0\.2\.1
+        allow-ports: 443
+`
+	cand := s.connectCand(c, "network", "", plugYaml)
+	cand.PlugSnapDeclaration = s.mockSnapDecl(c, "plug-snap", "J60k4JY0HppjwOjW8dZdYc8obXKxujRu", "canonical", plugsSlots)
+	c.Check(cand.Check(), IsNil)
+	_, err := cand.CheckAutoConnect()
+	c.Check(err, IsNil)
+
+	// but not beyond what it pins
+	plugYaml = `name: plug-snap
+version: 0
+plugs:
+  network:
+    allow-hosts: [192.0.2.1, 198.51.100.1]
+    allow-ports: [443]
+`
+	cand = s.connectCand(c, "network", "", plugYaml)
+	cand.PlugSnapDeclaration = s.mockSnapDecl(c, "plug-snap", "J60k4JY0HppjwOjW8dZdYc8obXKxujRu", "canonical", plugsSlots)
+	c.Check(cand.Check(), NotNil)
+	_, err = cand.CheckAutoConnect()
+	c.Check(err, NotNil)
+}
+
 func (s *baseDeclSuite) TestRawVolumeOverride(c *C) {
 	slotYaml := `name: slot-snap
 type: gadget
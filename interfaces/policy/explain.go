@@ -0,0 +1,148 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"github.com/snapcore/snapd/asserts"
+)
+
+// Verdicts of the evaluation of a declaration rule.
+const (
+	// VerdictDenied is the verdict of rules whose deny constraints matched.
+	VerdictDenied = "denied"
+	// VerdictNotAllowed is the verdict of rules whose allow constraints
+	// did not match.
+	VerdictNotAllowed = "not-allowed"
+	// VerdictAllowed is the verdict of rules whose allow constraints
+	// matched.
+	VerdictAllowed = "allowed"
+)
+
+// ConnectionExplanation describes how the declarations were evaluated to
+// decide whether a connection or an auto-connection is allowed.
+type ConnectionExplanation struct {
+	// Kind is either "connection" or "auto-connection".
+	Kind string
+	// Rules are the rules for the interface that were looked up in the
+	// declarations, in order of precedence. The first one found decided.
+	Rules []*RuleExplanation
+	// Err is the outcome of the check, nil if it is allowed.
+	Err error
+}
+
+// RuleExplanation describes the evaluation of the plug or slot rule of a
+// declaration.
+type RuleExplanation struct {
+	// Declaration is either "snap-declaration", for the rules of the plug
+	// or slot snap set by the store, or "base-declaration".
+	Declaration string
+	// SnapName is the name of the snap of a snap-declaration.
+	SnapName string
+	// Side is either "plug" or "slot".
+	Side string
+	// Found is false if the declaration has no rule for the interface.
+	Found bool
+	// Deny and Allow describe the evaluation of the alternative
+	// constraints of the rule for the kind of connection.
+	Deny  []*ConstraintsExplanation
+	Allow []*ConstraintsExplanation
+	// Verdict is the outcome of the evaluation of a rule that was found.
+	Verdict string
+}
+
+// ConstraintsExplanation describes the evaluation of one of the alternative
+// sets of constraints of a rule.
+type ConstraintsExplanation struct {
+	// Matched is whether all the constraints matched.
+	Matched bool
+	// Constraints are the constraints that are set, an empty set always
+	// matches.
+	Constraints []*ConstraintExplanation
+}
+
+// ConstraintExplanation describes the evaluation of a single constraint.
+type ConstraintExplanation struct {
+	// Name is the name of the constraint as in the declarations, e.g.
+	// "plug-snap-type", "slot-attributes" or "on-store".
+	Name string
+	// Err is why the constraint did not match, nil if it matched.
+	Err error
+}
+
+func (e *ConnectionExplanation) lookedUp(declaration, snapName, side string, found bool) *RuleExplanation {
+	if e == nil {
+		return nil
+	}
+	re := &RuleExplanation{
+		Declaration: declaration,
+		SnapName:    snapName,
+		Side:        side,
+		Found:       found,
+	}
+	e.Rules = append(e.Rules, re)
+	return re
+}
+
+func (re *RuleExplanation) setVerdict(verdict string) {
+	if re != nil {
+		re.Verdict = verdict
+	}
+}
+
+func explainConstraintChecks(checks []constraintCheck) *ConstraintsExplanation {
+	ce := &ConstraintsExplanation{Matched: true}
+	for _, c := range checks {
+		err := c.check()
+		if err != nil {
+			ce.Matched = false
+		}
+		ce.Constraints = append(ce.Constraints, &ConstraintExplanation{Name: c.name, Err: err})
+	}
+	return ce
+}
+
+func explainPlugConnectionAltConstraints(connc *ConnectCandidate, altConstraints []*asserts.PlugConnectionConstraints) []*ConstraintsExplanation {
+	expls := make([]*ConstraintsExplanation, 0, len(altConstraints))
+	for _, constraints := range altConstraints {
+		expls = append(expls, explainConstraintChecks(plugConnectionConstraintChecks(connc, constraints)))
+	}
+	return expls
+}
+
+func explainSlotConnectionAltConstraints(connc *ConnectCandidate, altConstraints []*asserts.SlotConnectionConstraints) []*ConstraintsExplanation {
+	expls := make([]*ConstraintsExplanation, 0, len(altConstraints))
+	for _, constraints := range altConstraints {
+		expls = append(expls, explainConstraintChecks(slotConnectionConstraintChecks(connc, constraints)))
+	}
+	return expls
+}
+
+// Explain checks the connection, or the auto-connection if autoConnect is
+// set, like Check and CheckAutoConnect do, and describes how the rules of
+// the declarations were evaluated to reach the outcome.
+func (connc *ConnectCandidate) Explain(autoConnect bool) *ConnectionExplanation {
+	kind := "connection"
+	if autoConnect {
+		kind = "auto-connection"
+	}
+	expl := &ConnectionExplanation{Kind: kind}
+	_, expl.Err = connc.check(kind, expl)
+	return expl
+}
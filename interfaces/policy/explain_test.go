@@ -0,0 +1,164 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/policy"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+func (s *policySuite) TestExplainMatchesCheck(c *C) {
+	for _, iface := range []string{
+		"random",
+		"base-plug-allow", "base-plug-deny", "base-plug-not-allow",
+		"base-slot-allow", "base-slot-deny", "base-slot-not-allow",
+		"snap-plug-allow", "snap-plug-deny", "snap-plug-not-allow",
+		"snap-slot-allow", "snap-slot-deny", "snap-slot-not-allow",
+		"base-deny-snap-slot-allow", "base-allow-snap-slot-not-allow",
+		"auto-base-plug-allow", "auto-base-plug-deny", "auto-snap-slot-not-allow",
+	} {
+		cand := policy.ConnectCandidate{
+			Plug:                interfaces.NewConnectedPlug(s.plugSnap.Plugs[iface], nil, nil),
+			Slot:                interfaces.NewConnectedSlot(s.slotSnap.Slots[iface], nil, nil),
+			PlugSnapDeclaration: s.plugDecl,
+			SlotSnapDeclaration: s.slotDecl,
+			BaseDeclaration:     s.baseDecl,
+		}
+
+		err := cand.Check()
+		expl := cand.Explain(false)
+		c.Check(expl.Kind, Equals, "connection")
+		if err == nil {
+			c.Check(expl.Err, IsNil, Commentf(iface))
+		} else {
+			c.Check(expl.Err, ErrorMatches, err.Error(), Commentf(iface))
+		}
+
+		_, err = cand.CheckAutoConnect()
+		expl = cand.Explain(true)
+		c.Check(expl.Kind, Equals, "auto-connection")
+		if err == nil {
+			c.Check(expl.Err, IsNil, Commentf(iface))
+		} else {
+			c.Check(expl.Err, ErrorMatches, err.Error(), Commentf(iface))
+		}
+	}
+}
+
+func (s *policySuite) TestExplainNoRules(c *C) {
+	cand := policy.ConnectCandidate{
+		Plug:                interfaces.NewConnectedPlug(s.plugSnap.Plugs["random"], nil, nil),
+		Slot:                interfaces.NewConnectedSlot(s.slotSnap.Slots["random"], nil, nil),
+		PlugSnapDeclaration: s.plugDecl,
+		SlotSnapDeclaration: s.slotDecl,
+		BaseDeclaration:     s.baseDecl,
+	}
+
+	expl := cand.Explain(false)
+	c.Check(expl.Err, IsNil)
+	c.Check(expl.Rules, DeepEquals, []*policy.RuleExplanation{
+		{Declaration: "snap-declaration", SnapName: "plug-snap", Side: "plug"},
+		{Declaration: "snap-declaration", SnapName: "slot-snap", Side: "slot"},
+		{Declaration: "base-declaration", Side: "plug"},
+		{Declaration: "base-declaration", Side: "slot"},
+	})
+}
+
+func (s *policySuite) TestExplainSnapDeclarationOverride(c *C) {
+	cand := policy.ConnectCandidate{
+		Plug:                interfaces.NewConnectedPlug(s.plugSnap.Plugs["base-deny-snap-slot-allow"], nil, nil),
+		Slot:                interfaces.NewConnectedSlot(s.slotSnap.Slots["base-deny-snap-slot-allow"], nil, nil),
+		PlugSnapDeclaration: s.plugDecl,
+		SlotSnapDeclaration: s.slotDecl,
+		BaseDeclaration:     s.baseDecl,
+	}
+
+	expl := cand.Explain(false)
+	c.Check(expl.Err, IsNil)
+	c.Assert(expl.Rules, HasLen, 2)
+	c.Check(expl.Rules[0].Found, Equals, false)
+	// the store override of the slot snap decided, the base
+	// declaration was not consulted
+	re := expl.Rules[1]
+	c.Check(re.Declaration, Equals, "snap-declaration")
+	c.Check(re.SnapName, Equals, "slot-snap")
+	c.Check(re.Side, Equals, "slot")
+	c.Check(re.Found, Equals, true)
+	c.Check(re.Verdict, Equals, policy.VerdictAllowed)
+	c.Assert(re.Deny, HasLen, 1)
+	c.Check(re.Deny[0].Matched, Equals, false)
+	c.Assert(re.Allow, HasLen, 1)
+	c.Check(re.Allow[0].Matched, Equals, true)
+}
+
+func (s *policySuite) TestExplainBaseDeclarationConstraints(c *C) {
+	coreSnap := snaptest.MockInfo(c, `
+name: core
+version: 0
+type: os
+slots:
+   gadgethelp:
+`, nil)
+
+	cand := policy.ConnectCandidate{
+		Plug:            interfaces.NewConnectedPlug(s.plugSnap.Plugs["gadgethelp"], nil, nil),
+		Slot:            interfaces.NewConnectedSlot(coreSnap.Slots["gadgethelp"], nil, nil),
+		BaseDeclaration: s.baseDecl,
+	}
+
+	expl := cand.Explain(false)
+	c.Check(expl.Err, ErrorMatches, `connection not allowed by slot rule of interface "gadgethelp"`)
+	c.Assert(expl.Rules, HasLen, 2)
+	c.Check(expl.Rules[0].Declaration, Equals, "base-declaration")
+	c.Check(expl.Rules[0].Side, Equals, "plug")
+	c.Check(expl.Rules[0].Found, Equals, false)
+
+	re := expl.Rules[1]
+	c.Check(re.Declaration, Equals, "base-declaration")
+	c.Check(re.Side, Equals, "slot")
+	c.Check(re.Found, Equals, true)
+	c.Check(re.Verdict, Equals, policy.VerdictNotAllowed)
+	c.Assert(re.Allow, HasLen, 1)
+	c.Check(re.Allow[0].Matched, Equals, false)
+	c.Assert(re.Allow[0].Constraints, HasLen, 1)
+	c.Check(re.Allow[0].Constraints[0].Name, Equals, "plug-snap-type")
+	c.Check(re.Allow[0].Constraints[0].Err, ErrorMatches, `.*snap type.*`)
+}
+
+func (s *policySuite) TestExplainDenied(c *C) {
+	cand := policy.ConnectCandidate{
+		Plug:            interfaces.NewConnectedPlug(s.plugSnap.Plugs["base-plug-deny"], nil, nil),
+		Slot:            interfaces.NewConnectedSlot(s.slotSnap.Slots["base-plug-deny"], nil, nil),
+		BaseDeclaration: s.baseDecl,
+	}
+
+	expl := cand.Explain(false)
+	c.Check(expl.Err, ErrorMatches, `connection denied by plug rule of interface "base-plug-deny"`)
+	c.Assert(expl.Rules, HasLen, 1)
+	re := expl.Rules[0]
+	c.Check(re.Verdict, Equals, policy.VerdictDenied)
+	c.Assert(re.Deny, HasLen, 1)
+	// deny-connection: true has no constraints and always matches
+	c.Check(re.Deny[0].Matched, Equals, true)
+	c.Check(re.Deny[0].Constraints, HasLen, 0)
+}
@@ -103,39 +103,108 @@ func checkNameConstraints(c *asserts.NameConstraints, iface, which, name string)
 	return c.Check(which, name, special)
 }
 
-func checkPlugConnectionConstraints1(connc *ConnectCandidate, constraints *asserts.PlugConnectionConstraints) error {
-	if err := checkNameConstraints(constraints.PlugNames, connc.Plug.Interface(), "plug name", connc.Plug.Name()); err != nil {
-		return err
-	}
-	if err := checkNameConstraints(constraints.SlotNames, connc.Slot.Interface(), "slot name", connc.Slot.Name()); err != nil {
-		return err
-	}
+// constraintCheck is the check of a single connection constraint, named
+// like in the declarations.
+type constraintCheck struct {
+	name  string
+	check func() error
+}
 
-	if err := constraints.PlugAttributes.Check(connc.Plug, connc); err != nil {
-		return err
-	}
-	if err := constraints.SlotAttributes.Check(connc.Slot, connc); err != nil {
-		return err
-	}
-	if err := checkSnapType(connc.Slot.Snap(), constraints.SlotSnapTypes); err != nil {
-		return err
-	}
-	if err := checkID("snap id", connc.slotSnapID(), constraints.SlotSnapIDs, nil); err != nil {
-		return err
+func runConstraintChecks(checks []constraintCheck) error {
+	for _, c := range checks {
+		if err := c.check(); err != nil {
+			return err
+		}
 	}
-	err := checkID("publisher id", connc.slotPublisherID(), constraints.SlotPublisherIDs, map[string]string{
-		"$PLUG_PUBLISHER_ID": connc.plugPublisherID(),
-	})
-	if err != nil {
-		return err
+	return nil
+}
+
+func deviceScopeConstraintName(c *asserts.DeviceScopeConstraint) string {
+	var names []string
+	if len(c.Store) != 0 {
+		names = append(names, "on-store")
 	}
-	if err := checkOnClassic(constraints.OnClassic); err != nil {
-		return err
+	if len(c.Brand) != 0 {
+		names = append(names, "on-brand")
 	}
-	if err := checkDeviceScope(constraints.DeviceScope, connc.Model, connc.Store); err != nil {
-		return err
+	if len(c.Model) != 0 {
+		names = append(names, "on-model")
 	}
-	return nil
+	return strings.Join(names, "/")
+}
+
+// connectionConstraintChecks returns the checks of the constraints common to
+// plug and slot connection rules that are set. Unset constraints always match.
+func connectionConstraintChecks(connc *ConnectCandidate, plugNames, slotNames *asserts.NameConstraints, plugAttrs, slotAttrs *asserts.AttributeConstraints) []constraintCheck {
+	if plugAttrs == asserts.NeverMatchAttributes && slotAttrs == asserts.NeverMatchAttributes {
+		// the constraints are just "false"
+		return []constraintCheck{{"false", func() error { return plugAttrs.Check(connc.Plug, connc) }}}
+	}
+	var checks []constraintCheck
+	if plugNames != nil {
+		checks = append(checks, constraintCheck{"plug-names", func() error {
+			return checkNameConstraints(plugNames, connc.Plug.Interface(), "plug name", connc.Plug.Name())
+		}})
+	}
+	if slotNames != nil {
+		checks = append(checks, constraintCheck{"slot-names", func() error {
+			return checkNameConstraints(slotNames, connc.Slot.Interface(), "slot name", connc.Slot.Name())
+		}})
+	}
+	if plugAttrs != asserts.AlwaysMatchAttributes {
+		checks = append(checks, constraintCheck{"plug-attributes", func() error {
+			return plugAttrs.Check(connc.Plug, connc)
+		}})
+	}
+	if slotAttrs != asserts.AlwaysMatchAttributes {
+		checks = append(checks, constraintCheck{"slot-attributes", func() error {
+			return slotAttrs.Check(connc.Slot, connc)
+		}})
+	}
+	return checks
+}
+
+// deviceConstraintChecks returns the checks of the on-classic and device
+// scope constraints that are set.
+func deviceConstraintChecks(connc *ConnectCandidate, onClassic *asserts.OnClassicConstraint, deviceScope *asserts.DeviceScopeConstraint) []constraintCheck {
+	var checks []constraintCheck
+	if onClassic != nil {
+		checks = append(checks, constraintCheck{"on-classic", func() error {
+			return checkOnClassic(onClassic)
+		}})
+	}
+	if deviceScope != nil {
+		checks = append(checks, constraintCheck{deviceScopeConstraintName(deviceScope), func() error {
+			return checkDeviceScope(deviceScope, connc.Model, connc.Store)
+		}})
+	}
+	return checks
+}
+
+func plugConnectionConstraintChecks(connc *ConnectCandidate, constraints *asserts.PlugConnectionConstraints) []constraintCheck {
+	checks := connectionConstraintChecks(connc, constraints.PlugNames, constraints.SlotNames, constraints.PlugAttributes, constraints.SlotAttributes)
+	if len(constraints.SlotSnapTypes) != 0 {
+		checks = append(checks, constraintCheck{"slot-snap-type", func() error {
+			return checkSnapType(connc.Slot.Snap(), constraints.SlotSnapTypes)
+		}})
+	}
+	if len(constraints.SlotSnapIDs) != 0 {
+		checks = append(checks, constraintCheck{"slot-snap-id", func() error {
+			return checkID("snap id", connc.slotSnapID(), constraints.SlotSnapIDs, nil)
+		}})
+	}
+	if len(constraints.SlotPublisherIDs) != 0 {
+		checks = append(checks, constraintCheck{"slot-publisher-id", func() error {
+			return checkID("publisher id", connc.slotPublisherID(), constraints.SlotPublisherIDs, map[string]string{
+				"$PLUG_PUBLISHER_ID": connc.plugPublisherID(),
+			})
+		}})
+	}
+	return append(checks, deviceConstraintChecks(connc, constraints.OnClassic, constraints.DeviceScope)...)
+}
+
+func checkPlugConnectionConstraints1(connc *ConnectCandidate, constraints *asserts.PlugConnectionConstraints) error {
+	return runConstraintChecks(plugConnectionConstraintChecks(connc, constraints))
 }
 
 func checkPlugConnectionAltConstraints(connc *ConnectCandidate, altConstraints []*asserts.PlugConnectionConstraints) (*asserts.PlugConnectionConstraints, error) {
@@ -153,39 +222,30 @@ func checkPlugConnectionAltConstraints(connc *ConnectCandidate, altConstraints [
 	return nil, firstErr
 }
 
-func checkSlotConnectionConstraints1(connc *ConnectCandidate, constraints *asserts.SlotConnectionConstraints) error {
-	if err := checkNameConstraints(constraints.PlugNames, connc.Plug.Interface(), "plug name", connc.Plug.Name()); err != nil {
-		return err
-	}
-	if err := checkNameConstraints(constraints.SlotNames, connc.Slot.Interface(), "slot name", connc.Slot.Name()); err != nil {
-		return err
-	}
+func slotConnectionConstraintChecks(connc *ConnectCandidate, constraints *asserts.SlotConnectionConstraints) []constraintCheck {
+	checks := connectionConstraintChecks(connc, constraints.PlugNames, constraints.SlotNames, constraints.PlugAttributes, constraints.SlotAttributes)
+	if len(constraints.PlugSnapTypes) != 0 {
+		checks = append(checks, constraintCheck{"plug-snap-type", func() error {
+			return checkSnapType(connc.Plug.Snap(), constraints.PlugSnapTypes)
+		}})
+	}
+	if len(constraints.PlugSnapIDs) != 0 {
+		checks = append(checks, constraintCheck{"plug-snap-id", func() error {
+			return checkID("snap id", connc.plugSnapID(), constraints.PlugSnapIDs, nil)
+		}})
+	}
+	if len(constraints.PlugPublisherIDs) != 0 {
+		checks = append(checks, constraintCheck{"plug-publisher-id", func() error {
+			return checkID("publisher id", connc.plugPublisherID(), constraints.PlugPublisherIDs, map[string]string{
+				"$SLOT_PUBLISHER_ID": connc.slotPublisherID(),
+			})
+		}})
+	}
+	return append(checks, deviceConstraintChecks(connc, constraints.OnClassic, constraints.DeviceScope)...)
+}
 
-	if err := constraints.PlugAttributes.Check(connc.Plug, connc); err != nil {
-		return err
-	}
-	if err := constraints.SlotAttributes.Check(connc.Slot, connc); err != nil {
-		return err
-	}
-	if err := checkSnapType(connc.Plug.Snap(), constraints.PlugSnapTypes); err != nil {
-		return err
-	}
-	if err := checkID("snap id", connc.plugSnapID(), constraints.PlugSnapIDs, nil); err != nil {
-		return err
-	}
-	err := checkID("publisher id", connc.plugPublisherID(), constraints.PlugPublisherIDs, map[string]string{
-		"$SLOT_PUBLISHER_ID": connc.slotPublisherID(),
-	})
-	if err != nil {
-		return err
-	}
-	if err := checkOnClassic(constraints.OnClassic); err != nil {
-		return err
-	}
-	if err := checkDeviceScope(constraints.DeviceScope, connc.Model, connc.Store); err != nil {
-		return err
-	}
-	return nil
+func checkSlotConnectionConstraints1(connc *ConnectCandidate, constraints *asserts.SlotConnectionConstraints) error {
+	return runConstraintChecks(slotConnectionConstraintChecks(connc, constraints))
 }
 
 func checkSlotConnectionAltConstraints(connc *ConnectCandidate, altConstraints []*asserts.SlotConnectionConstraints) (*asserts.SlotConnectionConstraints, error) {
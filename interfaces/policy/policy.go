@@ -183,7 +183,7 @@ func (connc *ConnectCandidate) slotPublisherID() string {
 	return "" // never a valid publisher-id
 }
 
-func (connc *ConnectCandidate) checkPlugRule(kind string, rule *asserts.PlugRule, snapRule bool) (interfaces.SideArity, error) {
+func (connc *ConnectCandidate) checkPlugRule(kind string, rule *asserts.PlugRule, snapRule bool, expl *RuleExplanation) (interfaces.SideArity, error) {
 	context := ""
 	if snapRule {
 		context = fmt.Sprintf(" for %q snap", connc.PlugSnapDeclaration.SnapName())
@@ -194,18 +194,25 @@ func (connc *ConnectCandidate) checkPlugRule(kind string, rule *asserts.PlugRule
 		denyConst = rule.DenyAutoConnection
 		allowConst = rule.AllowAutoConnection
 	}
+	if expl != nil {
+		expl.Deny = explainPlugConnectionAltConstraints(connc, denyConst)
+		expl.Allow = explainPlugConnectionAltConstraints(connc, allowConst)
+	}
 	if _, err := checkPlugConnectionAltConstraints(connc, denyConst); err == nil {
+		expl.setVerdict(VerdictDenied)
 		return nil, fmt.Errorf("%s denied by plug rule of interface %q%s", kind, connc.Plug.Interface(), context)
 	}
 
 	allowedConstraints, err := checkPlugConnectionAltConstraints(connc, allowConst)
 	if err != nil {
+		expl.setVerdict(VerdictNotAllowed)
 		return nil, fmt.Errorf("%s not allowed by plug rule of interface %q%s", kind, connc.Plug.Interface(), context)
 	}
+	expl.setVerdict(VerdictAllowed)
 	return sideArity{allowedConstraints.SlotsPerPlug}, nil
 }
 
-func (connc *ConnectCandidate) checkSlotRule(kind string, rule *asserts.SlotRule, snapRule bool) (interfaces.SideArity, error) {
+func (connc *ConnectCandidate) checkSlotRule(kind string, rule *asserts.SlotRule, snapRule bool, expl *RuleExplanation) (interfaces.SideArity, error) {
 	context := ""
 	if snapRule {
 		context = fmt.Sprintf(" for %q snap", connc.SlotSnapDeclaration.SnapName())
@@ -216,18 +223,27 @@ func (connc *ConnectCandidate) checkSlotRule(kind string, rule *asserts.SlotRule
 		denyConst = rule.DenyAutoConnection
 		allowConst = rule.AllowAutoConnection
 	}
+	if expl != nil {
+		expl.Deny = explainSlotConnectionAltConstraints(connc, denyConst)
+		expl.Allow = explainSlotConnectionAltConstraints(connc, allowConst)
+	}
 	if _, err := checkSlotConnectionAltConstraints(connc, denyConst); err == nil {
+		expl.setVerdict(VerdictDenied)
 		return nil, fmt.Errorf("%s denied by slot rule of interface %q%s", kind, connc.Plug.Interface(), context)
 	}
 
 	allowedConstraints, err := checkSlotConnectionAltConstraints(connc, allowConst)
 	if err != nil {
+		expl.setVerdict(VerdictNotAllowed)
 		return nil, fmt.Errorf("%s not allowed by slot rule of interface %q%s", kind, connc.Plug.Interface(), context)
 	}
+	expl.setVerdict(VerdictAllowed)
 	return sideArity{allowedConstraints.SlotsPerPlug}, nil
 }
 
-func (connc *ConnectCandidate) check(kind string) (interfaces.SideArity, error) {
+// check checks the connection of the given kind, recording how the rules
+// were evaluated in expl if not nil.
+func (connc *ConnectCandidate) check(kind string, expl *ConnectionExplanation) (interfaces.SideArity, error) {
 	baseDecl := connc.BaseDeclaration
 	if baseDecl == nil {
 		return nil, fmt.Errorf("internal error: improperly initialized ConnectCandidate")
@@ -240,33 +256,39 @@ func (connc *ConnectCandidate) check(kind string) (interfaces.SideArity, error)
 	}
 
 	if plugDecl := connc.PlugSnapDeclaration; plugDecl != nil {
-		if rule := plugDecl.PlugRule(iface); rule != nil {
-			return connc.checkPlugRule(kind, rule, true)
+		rule := plugDecl.PlugRule(iface)
+		re := expl.lookedUp("snap-declaration", plugDecl.SnapName(), "plug", rule != nil)
+		if rule != nil {
+			return connc.checkPlugRule(kind, rule, true, re)
 		}
 	}
 	if slotDecl := connc.SlotSnapDeclaration; slotDecl != nil {
-		if rule := slotDecl.SlotRule(iface); rule != nil {
-			return connc.checkSlotRule(kind, rule, true)
+		rule := slotDecl.SlotRule(iface)
+		re := expl.lookedUp("snap-declaration", slotDecl.SnapName(), "slot", rule != nil)
+		if rule != nil {
+			return connc.checkSlotRule(kind, rule, true, re)
 		}
 	}
 	if rule := baseDecl.PlugRule(iface); rule != nil {
-		return connc.checkPlugRule(kind, rule, false)
+		return connc.checkPlugRule(kind, rule, false, expl.lookedUp("base-declaration", "", "plug", true))
 	}
+	expl.lookedUp("base-declaration", "", "plug", false)
 	if rule := baseDecl.SlotRule(iface); rule != nil {
-		return connc.checkSlotRule(kind, rule, false)
+		return connc.checkSlotRule(kind, rule, false, expl.lookedUp("base-declaration", "", "slot", true))
 	}
+	expl.lookedUp("base-declaration", "", "slot", false)
 	return nil, nil
 }
 
 // Check checks whether the connection is allowed.
 func (connc *ConnectCandidate) Check() error {
-	_, err := connc.check("connection")
+	_, err := connc.check("connection", nil)
 	return err
 }
 
 // CheckAutoConnect checks whether the connection is allowed to auto-connect.
 func (connc *ConnectCandidate) CheckAutoConnect() (interfaces.SideArity, error) {
-	arity, err := connc.check("auto-connection")
+	arity, err := connc.check("auto-connection", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -971,6 +971,117 @@ func (r *Repository) SnapSpecification(securitySystem SecuritySystem, snapName s
 	return spec, nil
 }
 
+// SpecificationContribution holds what a plug or slot of a snap, or one of
+// their connections, contributes to the specification of the snap.
+type SpecificationContribution struct {
+	// Interface is the name of the interface of the plug or slot.
+	Interface string
+	// Plug is set for contributions of a plug of the snap.
+	Plug *snap.PlugInfo
+	// Slot is set for contributions of a slot of the snap.
+	Slot *snap.SlotInfo
+	// Connection is set for contributions of a connection of the plug or
+	// slot, it is nil for the contribution of just having it.
+	Connection *ConnRef
+	// Spec is a specification holding only what was contributed.
+	Spec Specification
+}
+
+// SnapSpecificationContributions returns what each plug and slot of a given
+// snap, and each of their connections, contribute to the specification of
+// the snap in a given security system. Their order is stable, slots come
+// before plugs as for SnapSpecification.
+func (r *Repository) SnapSpecificationContributions(securitySystem SecuritySystem, snapName string) ([]*SpecificationContribution, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var backend SecurityBackend
+	for _, b := range r.backends {
+		if b.Name() == securitySystem {
+			backend = b
+			break
+		}
+	}
+	if backend == nil {
+		return nil, fmt.Errorf("cannot handle interfaces of snap %q, security system %q is not known", snapName, securitySystem)
+	}
+
+	var contribs []*SpecificationContribution
+
+	slots := make([]*snap.SlotInfo, 0, len(r.slots[snapName]))
+	for _, slotInfo := range r.slots[snapName] {
+		slots = append(slots, slotInfo)
+	}
+	sort.Sort(bySlotSnapAndName(slots))
+	for _, slotInfo := range slots {
+		iface := r.ifaces[slotInfo.Interface]
+		spec := backend.NewSpecification()
+		if err := spec.AddPermanentSlot(iface, slotInfo); err != nil {
+			return nil, err
+		}
+		contribs = append(contribs, &SpecificationContribution{
+			Interface: slotInfo.Interface,
+			Slot:      slotInfo,
+			Spec:      spec,
+		})
+		connPlugs := make([]*snap.PlugInfo, 0, len(r.slotPlugs[slotInfo]))
+		for plugInfo := range r.slotPlugs[slotInfo] {
+			connPlugs = append(connPlugs, plugInfo)
+		}
+		sort.Sort(byPlugSnapAndName(connPlugs))
+		for _, plugInfo := range connPlugs {
+			conn := r.slotPlugs[slotInfo][plugInfo]
+			spec := backend.NewSpecification()
+			if err := spec.AddConnectedSlot(iface, conn.Plug, conn.Slot); err != nil {
+				return nil, err
+			}
+			contribs = append(contribs, &SpecificationContribution{
+				Interface:  slotInfo.Interface,
+				Slot:       slotInfo,
+				Connection: NewConnRef(plugInfo, slotInfo),
+				Spec:       spec,
+			})
+		}
+	}
+
+	plugs := make([]*snap.PlugInfo, 0, len(r.plugs[snapName]))
+	for _, plugInfo := range r.plugs[snapName] {
+		plugs = append(plugs, plugInfo)
+	}
+	sort.Sort(byPlugSnapAndName(plugs))
+	for _, plugInfo := range plugs {
+		iface := r.ifaces[plugInfo.Interface]
+		spec := backend.NewSpecification()
+		if err := spec.AddPermanentPlug(iface, plugInfo); err != nil {
+			return nil, err
+		}
+		contribs = append(contribs, &SpecificationContribution{
+			Interface: plugInfo.Interface,
+			Plug:      plugInfo,
+			Spec:      spec,
+		})
+		connSlots := make([]*snap.SlotInfo, 0, len(r.plugSlots[plugInfo]))
+		for slotInfo := range r.plugSlots[plugInfo] {
+			connSlots = append(connSlots, slotInfo)
+		}
+		sort.Sort(bySlotSnapAndName(connSlots))
+		for _, slotInfo := range connSlots {
+			conn := r.plugSlots[plugInfo][slotInfo]
+			spec := backend.NewSpecification()
+			if err := spec.AddConnectedPlug(iface, conn.Plug, conn.Slot); err != nil {
+				return nil, err
+			}
+			contribs = append(contribs, &SpecificationContribution{
+				Interface:  plugInfo.Interface,
+				Plug:       plugInfo,
+				Connection: NewConnRef(plugInfo, slotInfo),
+				Spec:       spec,
+			})
+		}
+	}
+	return contribs, nil
+}
+
 // AddSnap adds plugs and slots declared by the given snap to the repository.
 //
 // This function can be used to implement snap install or, when used along with
@@ -1260,6 +1260,51 @@ func (s *RepositorySuite) TestSnapSpecification(c *C) {
 	})
 }
 
+func (s *RepositorySuite) TestSnapSpecificationContributions(c *C) {
+	repo := s.emptyRepo
+	backend := &ifacetest.TestSecurityBackend{BackendName: testSecurity}
+	c.Assert(repo.AddBackend(backend), IsNil)
+	c.Assert(repo.AddInterface(testInterface), IsNil)
+	c.Assert(repo.AddPlug(s.plug), IsNil)
+	c.Assert(repo.AddSlot(s.slot), IsNil)
+
+	contribs, err := repo.SnapSpecificationContributions(testSecurity, s.plug.Snap.InstanceName())
+	c.Assert(err, IsNil)
+	c.Assert(contribs, HasLen, 1)
+	c.Check(contribs[0].Interface, Equals, "interface")
+	c.Check(contribs[0].Plug, Equals, s.plug)
+	c.Check(contribs[0].Slot, IsNil)
+	c.Check(contribs[0].Connection, IsNil)
+	c.Check(contribs[0].Spec.(*ifacetest.Specification).Snippets, DeepEquals, []string{"static plug snippet"})
+
+	connRef := NewConnRef(s.plug, s.slot)
+	_, err = repo.Connect(connRef, nil, nil, nil, nil, nil)
+	c.Assert(err, IsNil)
+
+	// each contribution is recorded in its own specification
+	contribs, err = repo.SnapSpecificationContributions(testSecurity, s.plug.Snap.InstanceName())
+	c.Assert(err, IsNil)
+	c.Assert(contribs, HasLen, 2)
+	c.Check(contribs[0].Connection, IsNil)
+	c.Check(contribs[0].Spec.(*ifacetest.Specification).Snippets, DeepEquals, []string{"static plug snippet"})
+	c.Check(contribs[1].Plug, Equals, s.plug)
+	c.Check(contribs[1].Connection, DeepEquals, connRef)
+	c.Check(contribs[1].Spec.(*ifacetest.Specification).Snippets, DeepEquals, []string{"connection-specific plug snippet"})
+
+	contribs, err = repo.SnapSpecificationContributions(testSecurity, s.slot.Snap.InstanceName())
+	c.Assert(err, IsNil)
+	c.Assert(contribs, HasLen, 2)
+	c.Check(contribs[0].Slot, Equals, s.slot)
+	c.Check(contribs[0].Connection, IsNil)
+	c.Check(contribs[0].Spec.(*ifacetest.Specification).Snippets, DeepEquals, []string{"static slot snippet"})
+	c.Check(contribs[1].Slot, Equals, s.slot)
+	c.Check(contribs[1].Connection, DeepEquals, connRef)
+	c.Check(contribs[1].Spec.(*ifacetest.Specification).Snippets, DeepEquals, []string{"connection-specific slot snippet"})
+
+	_, err = repo.SnapSpecificationContributions("foo", s.slot.Snap.InstanceName())
+	c.Assert(err, ErrorMatches, `cannot handle interfaces of snap "producer", security system "foo" is not known`)
+}
+
 func (s *RepositorySuite) TestSnapSpecificationFailureWithConnectionSnippets(c *C) {
 	var testSecurity SecuritySystem = "security"
 	backend := &ifacetest.TestSecurityBackend{BackendName: testSecurity}
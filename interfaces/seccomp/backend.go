@@ -374,6 +374,9 @@ func generateContent(opts interfaces.ConfinementOptions, snippetForTag string, a
 	}
 
 	buffer.Write(defaultTemplate)
+	if res, err := versionInfo.HasFeature(seccomp.IoctlSetsFeature); err != nil || !res {
+		snippetForTag = dropIoctlSets(snippetForTag)
+	}
 	buffer.WriteString(snippetForTag)
 	buffer.WriteString(uidGidChownSyscalls)
 
@@ -391,6 +394,21 @@ func generateContent(opts interfaces.ConfinementOptions, snippetForTag string, a
 	return buffer.Bytes()
 }
 
+// dropIoctlSets removes the rules allowing named sets of ioctl requests from
+// the snippet, for versions of snap-seccomp which cannot compile them. The
+// requests are then only allowed by the more general ioctl rule of the
+// template.
+func dropIoctlSets(snippet string) string {
+	var buf bytes.Buffer
+	for _, line := range strings.SplitAfter(snippet, "\n") {
+		if fields := strings.Fields(line); len(fields) == 3 && fields[0] == "ioctl" && strings.HasPrefix(fields[2], "@") {
+			continue
+		}
+		buf.WriteString(line)
+	}
+	return buf.String()
+}
+
 // NewSpecification returns an empty seccomp specification.
 func (b *Backend) NewSpecification() interfaces.Specification {
 	return &Specification{}
@@ -397,6 +397,31 @@ func (s *backendSuite) TestBindIsAddedForNonFullApparmorSystems(c *C) {
 	c.Assert(profile+".src", testutil.FileContains, "# Add bind() for systems with only Seccomp enabled to workaround\n# LP #1644573\nbind\n")
 }
 
+func (s *backendSuite) TestIoctlSetsDroppedForOldSnapSeccomp(c *C) {
+	s.Iface.SecCompPermanentSlotCallback = func(spec *seccomp.Specification, slot *snap.SlotInfo) error {
+		spec.AddSnippet("ioctl - TIOCGWINSZ")
+		return spec.AllowIoctls("uinput")
+	}
+	profile := filepath.Join(dirs.SnapSeccompDir, "snap.samba.smbd")
+
+	// the snap-seccomp of the suite does not know the ioctl sets
+	s.InstallSnap(c, interfaces.ConfinementOptions{}, "", ifacetest.SambaYamlV1, 0)
+	c.Check(profile+".src", testutil.FileContains, "\nioctl - TIOCGWINSZ\n")
+	c.Check(profile+".src", Not(testutil.FileContains), "@uinput")
+	s.RemoveSnap(c, snaptest.MockInfo(c, ifacetest.SambaYamlV1, &snap.SideInfo{Revision: snap.R(0)}))
+
+	snapSeccomp := testutil.MockLockedCommand(c, filepath.Join(dirs.DistroLibExecDir, "snap-seccomp"), `
+if [ "$1" = "version-info" ]; then
+    echo "abcdef 1.2.3 1234abcd bpf-actlog:ioctl-sets"
+fi`)
+	defer snapSeccomp.Restore()
+	c.Assert(s.Backend.Initialize(nil), IsNil)
+
+	s.InstallSnap(c, interfaces.ConfinementOptions{}, "", ifacetest.SambaYamlV1, 0)
+	c.Check(profile+".src", testutil.FileContains, "\nioctl - TIOCGWINSZ\n")
+	c.Check(profile+".src", testutil.FileContains, "\nioctl - @uinput\n")
+}
+
 func (s *backendSuite) TestSocketcallIsAddedWhenRequired(c *C) {
 	restore := seccomp.MockRequiresSocketcall(func(string) bool { return true })
 	defer restore()
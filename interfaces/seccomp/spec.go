@@ -21,9 +21,12 @@ package seccomp
 
 import (
 	"bytes"
+	"fmt"
+	"regexp"
 	"sort"
 
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/sandbox/seccomp"
 	"github.com/snapcore/snapd/snap"
 )
 
@@ -47,6 +50,42 @@ func (spec *Specification) AddSnippet(snippet string) {
 	}
 }
 
+// AllowIoctls allows all applications and hooks using the interface to issue
+// the ioctl requests of the given named set, as defined by
+// seccomp.IoctlSets. Note that the default template still allows all ioctl
+// requests but TIOCSTI, so no interface uses the sets until it is scaled
+// back.
+func (spec *Specification) AllowIoctls(set string) error {
+	if _, ok := seccomp.IoctlSets[set]; !ok {
+		return fmt.Errorf("unknown ioctl set %q", set)
+	}
+	spec.AddSnippet(fmt.Sprintf("ioctl - @%s", set))
+	return nil
+}
+
+var (
+	socketFamilyRegexp   = regexp.MustCompile(`^[AP]F_[A-Z0-9]+$`)
+	socketProtocolRegexp = regexp.MustCompile(`^([A-Z][A-Z0-9_]*|[0-9]+)$`)
+)
+
+// AllowSocket allows all applications and hooks using the interface to
+// create sockets of the given family and protocol, e.g. AF_NETLINK and
+// NETLINK_AUDIT. An empty protocol allows any protocol of the family.
+func (spec *Specification) AllowSocket(family, protocol string) error {
+	if !socketFamilyRegexp.MatchString(family) {
+		return fmt.Errorf("invalid socket family %q", family)
+	}
+	if protocol == "" {
+		spec.AddSnippet(fmt.Sprintf("socket %s", family))
+		return nil
+	}
+	if !socketProtocolRegexp.MatchString(protocol) {
+		return fmt.Errorf("invalid socket protocol %q", protocol)
+	}
+	spec.AddSnippet(fmt.Sprintf("socket %s - %s", family, protocol))
+	return nil
+}
+
 // Snippets returns a deep copy of all the added snippets.
 func (spec *Specification) Snippets() map[string][]string {
 	result := make(map[string][]string, len(spec.snippets))
@@ -58,6 +97,16 @@ func (spec *Specification) Snippets() map[string][]string {
 	return result
 }
 
+// InspectSnippets returns a deep copy of all the added snippets, sorted as
+// in the generated profiles, for inspection.
+func (spec *Specification) InspectSnippets() map[string][]string {
+	result := spec.Snippets()
+	for _, snippets := range result {
+		sort.Strings(snippets)
+	}
+	return result
+}
+
 // SnippetForTag returns a combined snippet for given security tag with individual snippets
 // joined with newline character. Empty string is returned for non-existing security tag.
 func (spec *Specification) SnippetForTag(tag string) string {
@@ -103,3 +103,59 @@ func (s *specSuite) TestSpecificationIface(c *C) {
 
 	c.Assert(s.spec.SnippetForTag("non-existing"), Equals, "")
 }
+
+func (s *specSuite) TestInspectSnippets(c *C) {
+	var r interfaces.InspectableSpecification = s.spec
+	c.Check(r.InspectSnippets(), HasLen, 0)
+
+	c.Assert(r.AddPermanentPlug(s.iface, s.plugInfo), IsNil)
+	c.Assert(r.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Check(r.InspectSnippets(), DeepEquals, map[string][]string{
+		"snap.snap1.app1": {"connected-plug", "permanent-plug"},
+	})
+}
+
+func (s *specSuite) TestAllowIoctls(c *C) {
+	iface := &ifacetest.TestInterface{
+		InterfaceName: "test",
+		SecCompConnectedPlugCallback: func(spec *seccomp.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+			if err := spec.AllowIoctls("uinput"); err != nil {
+				return err
+			}
+			return spec.AllowIoctls("netdevice")
+		},
+	}
+	c.Assert(s.spec.AddConnectedPlug(iface, s.plug, s.slot), IsNil)
+	c.Check(s.spec.SnippetForTag("snap.snap1.app1"), Equals, "ioctl - @netdevice\nioctl - @uinput\n")
+
+	// no snippets are added outside of the scope of a plug or slot
+	c.Assert(s.spec.AllowIoctls("uinput"), IsNil)
+	c.Check(s.spec.SecurityTags(), DeepEquals, []string{"snap.snap1.app1"})
+}
+
+func (s *specSuite) TestAllowIoctlsUnknownSet(c *C) {
+	c.Check(s.spec.AllowIoctls("tty"), ErrorMatches, `unknown ioctl set "tty"`)
+}
+
+func (s *specSuite) TestAllowSocket(c *C) {
+	iface := &ifacetest.TestInterface{
+		InterfaceName: "test",
+		SecCompConnectedPlugCallback: func(spec *seccomp.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+			for _, tuple := range [][2]string{{"AF_CAN", "CAN_RAW"}, {"AF_NETLINK", "31"}, {"AF_ALG", ""}} {
+				if err := spec.AllowSocket(tuple[0], tuple[1]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	c.Assert(s.spec.AddConnectedPlug(iface, s.plug, s.slot), IsNil)
+	c.Check(s.spec.SnippetForTag("snap.snap1.app1"), Equals, "socket AF_ALG\nsocket AF_CAN - CAN_RAW\nsocket AF_NETLINK - 31\n")
+}
+
+func (s *specSuite) TestAllowSocketInvalid(c *C) {
+	c.Check(s.spec.AllowSocket("can", ""), ErrorMatches, `invalid socket family "can"`)
+	c.Check(s.spec.AllowSocket("AF_CAN -", ""), ErrorMatches, `invalid socket family "AF_CAN -"`)
+	c.Check(s.spec.AllowSocket("AF_CAN", "CAN_RAW SOCK_RAW"), ErrorMatches, `invalid socket protocol "CAN_RAW SOCK_RAW"`)
+	c.Check(s.spec.AllowSocket("AF_NETLINK", "-1"), ErrorMatches, `invalid socket protocol "-1"`)
+}
@@ -200,7 +200,8 @@ inotify_rm_watch
 # input (man tty_ioctl), so we disallow it to prevent snaps plugging interfaces
 # with 'capability sys_admin' from interfering with other snaps or the
 # unconfined user's terminal.
-# TODO: this should be scaled back even more
+# TODO: this should be scaled back even more, interfaces would then allow the
+# requests they need with the named ioctl sets of snap-seccomp
 ioctl - !TIOCSTI
 
 io_cancel
@@ -438,7 +439,7 @@ sigwaitinfo
 # AppArmor mediates AF_UNIX/AF_LOCAL via 'unix' rules and all other AF_*
 # domains via 'network' rules. We won't allow bare 'network' AppArmor rules, so
 # we can allow 'socket' for all domains except AF_NETLINK and let AppArmor
-# handle the rest.
+# handle the rest. AF_CAN is left to the can-bus interface.
 socket AF_UNIX
 socket AF_LOCAL
 socket AF_INET
@@ -450,7 +451,6 @@ socket AF_ATMPVC
 socket AF_APPLETALK
 socket AF_PACKET
 socket AF_ALG
-socket AF_CAN
 socket AF_BRIDGE
 socket AF_NETROM
 socket AF_ROSE
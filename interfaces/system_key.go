@@ -34,6 +34,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/sandbox"
 	"github.com/snapcore/snapd/sandbox/apparmor"
 	"github.com/snapcore/snapd/sandbox/cgroup"
 	"github.com/snapcore/snapd/sandbox/seccomp"
@@ -78,10 +79,11 @@ type systemKey struct {
 	SecCompActions         []string `json:"seccomp-features"`
 	SeccompCompilerVersion string   `json:"seccomp-compiler-version"`
 	CgroupVersion          string   `json:"cgroup-version"`
+	LandlockConfinement    bool     `json:"landlock-confinement"`
 }
 
 // IMPORTANT: when adding/removing/changing inputs bump this
-const systemKeyVersion = 10
+const systemKeyVersion = 11
 
 var (
 	isHomeUsingNFS        = osutil.IsHomeUsingNFS
@@ -89,6 +91,8 @@ var (
 	mockedSystemKey       *systemKey
 
 	readBuildID = osutil.ReadBuildID
+
+	landlockConfinement = sandbox.LandlockConfinement
 )
 
 func seccompCompilerVersionInfo(path string) (seccomp.VersionInfo, error) {
@@ -156,6 +160,9 @@ func generateSystemKey() (*systemKey, error) {
 	}
 	sk.CgroupVersion = strconv.FormatInt(int64(cgv), 10)
 
+	// Add whether the landlock profiles are in use
+	sk.LandlockConfinement = landlockConfinement()
+
 	return sk, nil
 }
 
@@ -81,7 +81,7 @@ func (s *systemKeySuite) TearDownTest(c *C) {
 	dirs.SetRootDir("/")
 }
 
-func (s *systemKeySuite) testInterfaceWriteSystemKey(c *C, nfsHome, overlayRoot bool) {
+func (s *systemKeySuite) testInterfaceWriteSystemKey(c *C, nfsHome, overlayRoot, landlock bool) {
 	var overlay string
 	if overlayRoot {
 		overlay = "overlay"
@@ -101,6 +101,9 @@ func (s *systemKeySuite) testInterfaceWriteSystemKey(c *C, nfsHome, overlayRoot
 	restore = cgroup.MockVersion(1, nil)
 	defer restore()
 
+	restore = interfaces.MockLandlockConfinement(landlock)
+	defer restore()
+
 	err := interfaces.WriteSystemKey()
 	c.Assert(err, IsNil)
 
@@ -130,7 +133,7 @@ func (s *systemKeySuite) testInterfaceWriteSystemKey(c *C, nfsHome, overlayRoot
 	c.Assert(err, IsNil)
 	c.Assert(seccompCompilerVersion, Equals, s.seccompCompilerVersion)
 
-	c.Check(string(systemKey), testutil.EqualsWrapped, fmt.Sprintf(`{"version":%d,"build-id":"%s","apparmor-features":%s,"apparmor-parser-mtime":%s,"apparmor-parser-features":%s,"nfs-home":%v,"overlay-root":%q,"seccomp-features":%s,"seccomp-compiler-version":"%s","cgroup-version":"1"}`,
+	c.Check(string(systemKey), testutil.EqualsWrapped, fmt.Sprintf(`{"version":%d,"build-id":"%s","apparmor-features":%s,"apparmor-parser-mtime":%s,"apparmor-parser-features":%s,"nfs-home":%v,"overlay-root":%q,"seccomp-features":%s,"seccomp-compiler-version":"%s","cgroup-version":"1","landlock-confinement":%v}`,
 		interfaces.SystemKeyVersion,
 		s.buildID,
 		apparmorFeaturesStr,
@@ -140,24 +143,29 @@ func (s *systemKeySuite) testInterfaceWriteSystemKey(c *C, nfsHome, overlayRoot
 		overlay,
 		seccompActionsStr,
 		seccompCompilerVersion,
+		landlock,
 	))
 }
 
 func (s *systemKeySuite) TestInterfaceWriteSystemKeyNoNFS(c *C) {
-	s.testInterfaceWriteSystemKey(c, false, false)
+	s.testInterfaceWriteSystemKey(c, false, false, false)
 }
 
 func (s *systemKeySuite) TestInterfaceWriteSystemKeyWithNFS(c *C) {
-	s.testInterfaceWriteSystemKey(c, true, false)
+	s.testInterfaceWriteSystemKey(c, true, false, false)
 }
 
 func (s *systemKeySuite) TestInterfaceWriteSystemKeyWithOverlayRoot(c *C) {
-	s.testInterfaceWriteSystemKey(c, false, true)
+	s.testInterfaceWriteSystemKey(c, false, true, false)
 }
 
 // bonus points to someone who actually runs this
 func (s *systemKeySuite) TestInterfaceWriteSystemKeyWithNFSWithOverlayRoot(c *C) {
-	s.testInterfaceWriteSystemKey(c, true, true)
+	s.testInterfaceWriteSystemKey(c, true, true, false)
+}
+
+func (s *systemKeySuite) TestInterfaceWriteSystemKeyWithLandlock(c *C) {
+	s.testInterfaceWriteSystemKey(c, false, false, true)
 }
 
 func (s *systemKeySuite) TestInterfaceWriteSystemKeyErrorOnBuildID(c *C) {
@@ -281,6 +289,7 @@ func (s *systemKeySuite) TestStaticVersion(c *C) {
 		"SecCompActions:[]",
 		"SeccompCompilerVersion:",
 		"CgroupVersion:",
+		"LandlockConfinement:false",
 	}, " ")+"}")
 }
 
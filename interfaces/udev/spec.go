@@ -34,6 +34,8 @@ type entry struct {
 	snippet string
 	iface   string
 	tag     string
+	// securityTag is the security tag the udev tag was derived from
+	securityTag string
 }
 
 // Specification assists in collecting udev snippets associated with an interface.
@@ -64,16 +66,17 @@ func (spec *Specification) ControlsDeviceCgroup() bool {
 	return spec.controlsDeviceCgroup
 }
 
-func (spec *Specification) addEntry(snippet, tag string) {
+func (spec *Specification) addEntry(snippet, tag, securityTag string) {
 	if spec.snippets == nil {
 		spec.snippets = make(map[string]bool)
 	}
 	if !spec.snippets[snippet] {
 		spec.snippets[snippet] = true
 		e := entry{
-			snippet: snippet,
-			iface:   spec.iface,
-			tag:     tag,
+			snippet:     snippet,
+			iface:       spec.iface,
+			tag:         tag,
+			securityTag: securityTag,
 		}
 		spec.entries = append(spec.entries, e)
 	}
@@ -81,7 +84,7 @@ func (spec *Specification) addEntry(snippet, tag string) {
 
 // AddSnippet adds a new udev snippet.
 func (spec *Specification) AddSnippet(snippet string) {
-	spec.addEntry(snippet, "")
+	spec.addEntry(snippet, "", "")
 }
 
 func udevTag(securityTag string) string {
@@ -93,9 +96,9 @@ func udevTag(securityTag string) string {
 func (spec *Specification) TagDevice(snippet string) {
 	for _, securityTag := range spec.securityTags {
 		tag := udevTag(securityTag)
-		spec.addEntry(fmt.Sprintf("# %s\n%s, TAG+=\"%s\"", spec.iface, snippet, tag), tag)
+		spec.addEntry(fmt.Sprintf("# %s\n%s, TAG+=\"%s\"", spec.iface, snippet, tag), tag, securityTag)
 		spec.addEntry(fmt.Sprintf("TAG==\"%s\", RUN+=\"%s/snap-device-helper $env{ACTION} %s $devpath $major:$minor\"",
-			tag, dirs.DistroLibExecDir, tag), tag)
+			tag, dirs.DistroLibExecDir, tag), tag, securityTag)
 	}
 }
 
@@ -110,6 +113,21 @@ func (c byTagAndSnippet) Less(i, j int) bool {
 	return c[i].snippet < c[j].snippet
 }
 
+// InspectSnippets returns a copy of all the snippets added so far, indexed
+// by the security tag of the applications whose devices they tag or by ""
+// for the other ones, for inspection.
+func (spec *Specification) InspectSnippets() map[string][]string {
+	// see Snippets
+	if spec.ControlsDeviceCgroup() {
+		return nil
+	}
+	result := make(map[string][]string)
+	for _, entry := range spec.entries {
+		result[entry.securityTag] = append(result[entry.securityTag], entry.snippet)
+	}
+	return result
+}
+
 // Snippets returns a copy of all the snippets added so far.
 func (spec *Specification) Snippets() (result []string) {
 	// If one of the interfaces controls it's own device cgroup, then
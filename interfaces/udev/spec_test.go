@@ -166,3 +166,40 @@ func (s *specSuite) TestControlsDeviceCgroup(c *C) {
 	s.spec.SetControlsDeviceCgroup()
 	c.Assert(s.spec.ControlsDeviceCgroup(), Equals, true)
 }
+
+func (s *specSuite) TestInspectSnippets(c *C) {
+	restore := release.MockReleaseInfo(&release.OS{ID: "ubuntu"})
+	defer restore()
+
+	var r interfaces.InspectableSpecification = s.spec
+	c.Check(r.InspectSnippets(), HasLen, 0)
+
+	iface := &ifacetest.TestInterface{
+		InterfaceName: "iface-1",
+		UDevConnectedPlugCallback: func(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+			spec.AddSnippet("foo")
+			spec.TagDevice(`kernel="voodoo"`)
+			return nil
+		},
+	}
+	c.Assert(s.spec.AddConnectedPlug(iface, s.plug, s.slot), IsNil)
+
+	// the snippets tagging devices are indexed by the security tag of the
+	// app or hook
+	c.Check(r.InspectSnippets(), DeepEquals, map[string][]string{
+		"": {"foo"},
+		"snap.snap1.foo": {
+			"# iface-1\nkernel=\"voodoo\", TAG+=\"snap_snap1_foo\"",
+			fmt.Sprintf(`TAG=="snap_snap1_foo", RUN+="%s/snap-device-helper $env{ACTION} snap_snap1_foo $devpath $major:$minor"`, dirs.DistroLibExecDir),
+		},
+		"snap.snap1.hook.configure": {
+			"# iface-1\nkernel=\"voodoo\", TAG+=\"snap_snap1_hook_configure\"",
+			fmt.Sprintf(`TAG=="snap_snap1_hook_configure", RUN+="%s/snap-device-helper $env{ACTION} snap_snap1_hook_configure $devpath $major:$minor"`, dirs.DistroLibExecDir),
+		},
+	})
+
+	// like Snippets, nothing when the device cgroup is controlled by the
+	// snap
+	s.spec.SetControlsDeviceCgroup()
+	c.Check(r.InspectSnippets(), IsNil)
+}
@@ -42,6 +42,9 @@ func Manager(s *state.State, runner *state.TaskRunner) (*AssertManager, error) {
 	delayedCrossMgrInit()
 
 	runner.AddHandler("validate-snap", doValidateSnap, nil)
+	if err := runner.MarkIdempotent("validate-snap"); err != nil {
+		return nil, err
+	}
 
 	db, err := sysdb.Open()
 	if err != nil {
@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
 )
 
 type overlordStateBackend struct {
@@ -37,3 +38,28 @@ func (osb *overlordStateBackend) Checkpoint(data []byte) error {
 func (osb *overlordStateBackend) EnsureBefore(d time.Duration) {
 	osb.ensureBefore(d)
 }
+
+// journalStateBackend persists the changes to the state as deltas appended
+// to the journal of the state file.
+type journalStateBackend struct {
+	overlordStateBackend
+	journal *state.Journal
+}
+
+func newJournalStateBackend(path string, ensureBefore func(d time.Duration)) *journalStateBackend {
+	return &journalStateBackend{
+		overlordStateBackend: overlordStateBackend{
+			path:         path,
+			ensureBefore: ensureBefore,
+		},
+		journal: state.NewJournal(path),
+	}
+}
+
+func (jsb *journalStateBackend) Checkpoint(data []byte) error {
+	return jsb.journal.Checkpoint(data)
+}
+
+func (jsb *journalStateBackend) CheckpointDelta(delta *state.Delta) error {
+	return jsb.journal.Append(delta)
+}
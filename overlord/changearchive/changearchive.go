@@ -0,0 +1,272 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package changearchive keeps the changes pruned from the state, with
+// their tasks and logs, in a rotating on-disk archive.
+package changearchive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+const (
+	currentSegment = "changes.jsonl"
+	segmentPrefix  = "changes-"
+	segmentSuffix  = ".jsonl"
+	// segments are named after the time they were rotated at, so that
+	// they sort by it
+	segmentTimeFormat = "20060102T150405.000000000Z"
+)
+
+var (
+	// the current segment is rotated once it gets bigger than this
+	maxSegmentSize int64 = 4 * 1024 * 1024
+	// how many rotated segments are kept
+	maxSegments = 8
+
+	timeNow = time.Now
+)
+
+// archive writes and reads are serialized
+var mu sync.Mutex
+
+// An Entry is an archived change.
+type Entry struct {
+	client.Change
+	Data map[string]*json.RawMessage `json:"data,omitempty"`
+	// SnapNames are the names of the snaps the change was about.
+	SnapNames []string `json:"snap-names,omitempty"`
+}
+
+func newEntry(chg *state.Change) *Entry {
+	status := chg.Status()
+	e := &Entry{
+		Change: client.Change{
+			ID:        chg.ID(),
+			Kind:      chg.Kind(),
+			Summary:   chg.Summary(),
+			Status:    status.String(),
+			Ready:     status.Ready(),
+			SpawnTime: chg.SpawnTime(),
+			ReadyTime: chg.ReadyTime(),
+		},
+	}
+	if err := chg.Err(); err != nil {
+		e.Err = err.Error()
+	}
+	for _, t := range chg.Tasks() {
+		label, done, total := t.Progress()
+		e.Tasks = append(e.Tasks, &client.Task{
+			ID:      t.ID(),
+			Kind:    t.Kind(),
+			Summary: t.Summary(),
+			Status:  t.Status().String(),
+			Log:     t.Log(),
+			Progress: client.TaskProgress{
+				Label: label,
+				Done:  done,
+				Total: total,
+			},
+			SpawnTime: t.SpawnTime(),
+			ReadyTime: t.ReadyTime(),
+		})
+	}
+	var data map[string]*json.RawMessage
+	if chg.Get("api-data", &data) == nil {
+		e.Data = data
+	}
+	var snapNames []string
+	if chg.Get("snap-names", &snapNames) == nil {
+		e.SnapNames = snapNames
+	}
+	return e
+}
+
+// Add archives the given change. The state must be locked.
+func Add(chg *state.Change) error {
+	line, err := json.Marshal(newEntry(chg))
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := os.MkdirAll(dirs.SnapChangesArchiveDir, 0700); err != nil {
+		return fmt.Errorf("cannot create change archive: %v", err)
+	}
+	fn := filepath.Join(dirs.SnapChangesArchiveDir, currentSegment)
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot open change archive: %v", err)
+	}
+	_, err = f.Write(line)
+	var size int64
+	if err == nil {
+		var fi os.FileInfo
+		if fi, err = f.Stat(); err == nil {
+			size = fi.Size()
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("cannot write to change archive: %v", err)
+	}
+
+	if size >= maxSegmentSize {
+		if err := rotate(); err != nil {
+			return fmt.Errorf("cannot rotate change archive: %v", err)
+		}
+	}
+	return nil
+}
+
+// segments returns the rotated segments of the archive, oldest first.
+func segments() ([]string, error) {
+	segs, err := filepath.Glob(filepath.Join(dirs.SnapChangesArchiveDir, segmentPrefix+"*"+segmentSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(segs)
+	return segs, nil
+}
+
+func rotate() error {
+	name := segmentPrefix + timeNow().UTC().Format(segmentTimeFormat) + segmentSuffix
+	if err := os.Rename(filepath.Join(dirs.SnapChangesArchiveDir, currentSegment), filepath.Join(dirs.SnapChangesArchiveDir, name)); err != nil {
+		return err
+	}
+	segs, err := segments()
+	if err != nil {
+		return err
+	}
+	for len(segs) > maxSegments {
+		if err := os.Remove(segs[0]); err != nil {
+			return err
+		}
+		segs = segs[1:]
+	}
+	return nil
+}
+
+// Filter selects archived changes.
+type Filter struct {
+	// Since selects the changes that became ready at or after it, if set.
+	Since time.Time
+	// Kind selects the changes of the given kind, if set.
+	Kind string
+	// SnapName selects the changes about the given snap, if set.
+	SnapName string
+}
+
+func (f *Filter) match(e *Entry) bool {
+	if !f.Since.IsZero() && e.ReadyTime.Before(f.Since) {
+		return false
+	}
+	if f.Kind != "" && e.Kind != f.Kind {
+		return false
+	}
+	if f.SnapName != "" {
+		for _, name := range e.SnapNames {
+			// the snap-names of service-control changes can
+			// include <snap>.<app>
+			if snapName, _ := snap.SplitSnapApp(name); snapName == f.SnapName {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func readSegment(fn string, filter *Filter, entries []*Entry) ([]*Entry, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// rotated or not written yet
+			return entries, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			var e Entry
+			if err := json.Unmarshal(line, &e); err != nil {
+				// a change that could not be fully written
+				logger.Debugf("Skipping corrupted entry in change archive %q: %v", fn, err)
+			} else if filter.match(&e) {
+				entries = append(entries, &e)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// Query returns the archived changes selected by the filter, in the order
+// they were archived.
+func Query(filter *Filter) ([]*Entry, error) {
+	if filter == nil {
+		filter = &Filter{}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	segs, err := segments()
+	if err != nil {
+		return nil, err
+	}
+	segs = append(segs, filepath.Join(dirs.SnapChangesArchiveDir, currentSegment))
+
+	var entries []*Entry
+	for _, fn := range segs {
+		entries, err = readSegment(fn, filter, entries)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read change archive: %v", err)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,171 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package changearchive_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/changearchive"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type archiveSuite struct {
+	testutil.BaseTest
+	st *state.State
+}
+
+var _ = Suite(&archiveSuite{})
+
+func (s *archiveSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	s.st = state.New(nil)
+}
+
+func (s *archiveSuite) addChange(c *C, kind string, snapNames []string) *state.Change {
+	s.st.Lock()
+	defer s.st.Unlock()
+	chg := s.st.NewChange(kind, kind+" summary")
+	if snapNames != nil {
+		chg.Set("snap-names", snapNames)
+	}
+	chg.Set("api-data", map[string]interface{}{"snap-names": snapNames})
+	t := s.st.NewTask("some-task", "some task")
+	t.Logf("did something")
+	t.SetProgress("label", 1, 2)
+	chg.AddTask(t)
+	t.SetStatus(state.DoneStatus)
+	c.Assert(changearchive.Add(chg), IsNil)
+	return chg
+}
+
+func (s *archiveSuite) TestAddQuery(c *C) {
+	chg := s.addChange(c, "install-snap", []string{"foo"})
+
+	entries, err := changearchive.Query(nil)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	e := entries[0]
+	c.Check(e.ID, Equals, chg.ID())
+	c.Check(e.Kind, Equals, "install-snap")
+	c.Check(e.Summary, Equals, "install-snap summary")
+	c.Check(e.Status, Equals, "Done")
+	c.Check(e.Ready, Equals, true)
+	c.Check(e.SnapNames, DeepEquals, []string{"foo"})
+	c.Check(e.Data, HasLen, 1)
+	s.st.Lock()
+	c.Check(e.SpawnTime.Equal(chg.SpawnTime()), Equals, true)
+	c.Check(e.ReadyTime.Equal(chg.ReadyTime()), Equals, true)
+	s.st.Unlock()
+	c.Assert(e.Tasks, HasLen, 1)
+	c.Check(e.Tasks[0].Kind, Equals, "some-task")
+	c.Check(e.Tasks[0].Status, Equals, "Done")
+	c.Check(e.Tasks[0].Log, HasLen, 1)
+	c.Check(e.Tasks[0].Log[0], Matches, `.* INFO did something`)
+	c.Check(e.Tasks[0].Progress.Label, Equals, "label")
+
+	c.Check(filepath.Join(dirs.SnapChangesArchiveDir, "changes.jsonl"), testutil.FilePresent)
+}
+
+func (s *archiveSuite) TestQueryNothingArchived(c *C) {
+	entries, err := changearchive.Query(nil)
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 0)
+}
+
+func (s *archiveSuite) TestQueryFilter(c *C) {
+	chg1 := s.addChange(c, "install-snap", []string{"foo"})
+	chg2 := s.addChange(c, "refresh-snap", []string{"bar"})
+	chg3 := s.addChange(c, "service-control", []string{"foo.app"})
+
+	ids := func(filter *changearchive.Filter) []string {
+		entries, err := changearchive.Query(filter)
+		c.Assert(err, IsNil)
+		var ids []string
+		for _, e := range entries {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+
+	c.Check(ids(&changearchive.Filter{}), DeepEquals, []string{chg1.ID(), chg2.ID(), chg3.ID()})
+	c.Check(ids(&changearchive.Filter{Kind: "refresh-snap"}), DeepEquals, []string{chg2.ID()})
+	c.Check(ids(&changearchive.Filter{SnapName: "foo"}), DeepEquals, []string{chg1.ID(), chg3.ID()})
+	c.Check(ids(&changearchive.Filter{SnapName: "foo", Kind: "install-snap"}), DeepEquals, []string{chg1.ID()})
+	c.Check(ids(&changearchive.Filter{SnapName: "baz"}), HasLen, 0)
+
+	s.st.Lock()
+	since := chg2.ReadyTime()
+	s.st.Unlock()
+	c.Check(ids(&changearchive.Filter{Since: since}), DeepEquals, []string{chg2.ID(), chg3.ID()})
+	c.Check(ids(&changearchive.Filter{Since: time.Now().Add(time.Hour)}), HasLen, 0)
+}
+
+func (s *archiveSuite) TestQuerySkipsCorrupted(c *C) {
+	chg := s.addChange(c, "install-snap", nil)
+	f, err := os.OpenFile(filepath.Join(dirs.SnapChangesArchiveDir, "changes.jsonl"), os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, IsNil)
+	_, err = f.WriteString(`{"id":"4`)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	entries, err := changearchive.Query(nil)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Check(entries[0].ID, Equals, chg.ID())
+}
+
+func (s *archiveSuite) TestRotation(c *C) {
+	s.AddCleanup(changearchive.MockRotation(1, 2))
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.AddCleanup(changearchive.MockTimeNow(func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}))
+
+	var chgs []*state.Change
+	for i := 0; i < 4; i++ {
+		chgs = append(chgs, s.addChange(c, "install-snap", nil))
+	}
+
+	// only the most recent segments are kept
+	segs, err := filepath.Glob(filepath.Join(dirs.SnapChangesArchiveDir, "*"))
+	c.Assert(err, IsNil)
+	c.Check(segs, DeepEquals, []string{
+		filepath.Join(dirs.SnapChangesArchiveDir, "changes-20220301T120003.000000000Z.jsonl"),
+		filepath.Join(dirs.SnapChangesArchiveDir, "changes-20220301T120004.000000000Z.jsonl"),
+	})
+
+	entries, err := changearchive.Query(nil)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Check(entries[0].ID, Equals, chgs[2].ID())
+	c.Check(entries[1].ID, Equals, chgs[3].ID())
+}
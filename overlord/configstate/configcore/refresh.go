@@ -39,6 +39,9 @@ func init() {
 	supportedConfigurations["core.refresh.metered"] = true
 	supportedConfigurations["core.refresh.retain"] = true
 	supportedConfigurations["core.refresh.rate-limit"] = true
+	supportedConfigurations["core.refresh.health-grace-period"] = true
+	supportedConfigurations["core.refresh.idle"] = true
+	supportedConfigurations["core.refresh.idle-max-load"] = true
 }
 
 func reportOrIgnoreInvalidManageRefreshes(tr config.Conf, optName string) error {
@@ -147,3 +150,46 @@ func validateRefreshRateLimit(tr config.Conf) error {
 	}
 	return nil
 }
+
+func validateRefreshHealthGracePeriod(tr config.Conf) error {
+	gracePeriodStr, err := coreCfg(tr, "refresh.health-grace-period")
+	if err != nil {
+		return err
+	}
+	// unset disables the automatic rollback of refreshes
+	if gracePeriodStr == "" {
+		return nil
+	}
+	gracePeriod, err := time.ParseDuration(gracePeriodStr)
+	if err != nil || gracePeriod < 0 {
+		return fmt.Errorf("refresh.health-grace-period must be a non-negative duration, not %q", gracePeriodStr)
+	}
+	return nil
+}
+
+func validateRefreshIdle(tr config.Conf) error {
+	idleStr, err := coreCfg(tr, "refresh.idle")
+	if err != nil {
+		return err
+	}
+	for _, check := range strutil.CommaSeparatedList(idleStr) {
+		switch check {
+		case "load", "sessions", "busy-snaps":
+		default:
+			return fmt.Errorf("refresh.idle contains an invalid check %q, expected load, sessions or busy-snaps", check)
+		}
+	}
+
+	maxLoadStr, err := coreCfg(tr, "refresh.idle-max-load")
+	if err != nil {
+		return err
+	}
+	if maxLoadStr == "" {
+		return nil
+	}
+	maxLoad, err := strconv.ParseFloat(maxLoadStr, 64)
+	if err != nil || maxLoad <= 0 {
+		return fmt.Errorf("refresh.idle-max-load must be a positive number, not %q", maxLoadStr)
+	}
+	return nil
+}
@@ -20,6 +20,7 @@
 package configcore_test
 
 import (
+	"fmt"
 	"time"
 
 	. "gopkg.in/check.v1"
@@ -219,3 +220,56 @@ func (s *refreshSuite) TestConfigureRefreshRetainInvalid(c *C) {
 	})
 	c.Assert(err, ErrorMatches, `retain must be a number between 2 and 20, not "invalid"`)
 }
+
+func (s *refreshSuite) TestConfigureRefreshHealthGracePeriodHappy(c *C) {
+	for _, gracePeriod := range []string{"", "0", "10m", "1h30m"} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"refresh.health-grace-period": gracePeriod,
+			},
+		})
+		c.Check(err, IsNil, Commentf(gracePeriod))
+	}
+}
+
+func (s *refreshSuite) TestConfigureRefreshHealthGracePeriodInvalid(c *C) {
+	for _, gracePeriod := range []string{"10", "invalid", "-10m"} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"refresh.health-grace-period": gracePeriod,
+			},
+		})
+		c.Check(err, ErrorMatches, fmt.Sprintf(`refresh.health-grace-period must be a non-negative duration, not %q`, gracePeriod))
+	}
+}
+
+func (s *refreshSuite) TestConfigureRefreshIdleHappy(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"refresh.idle":          "load,sessions,busy-snaps",
+			"refresh.idle-max-load": "0.8",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *refreshSuite) TestConfigureRefreshIdleInvalid(c *C) {
+	for _, t := range []struct {
+		conf map[string]interface{}
+		err  string
+	}{
+		{map[string]interface{}{"refresh.idle": "load,cpu"}, `refresh.idle contains an invalid check "cpu", expected load, sessions or busy-snaps`},
+		{map[string]interface{}{"refresh.idle-max-load": "high"}, `refresh.idle-max-load must be a positive number, not "high"`},
+		{map[string]interface{}{"refresh.idle-max-load": "0"}, `refresh.idle-max-load must be a positive number, not "0"`},
+		{map[string]interface{}{"refresh.idle-max-load": "-1.5"}, `refresh.idle-max-load must be a positive number, not "-1.5"`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf:  t.conf,
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.conf))
+	}
+}
@@ -57,7 +57,12 @@ func init() {
 	validateOnly := &flags{validatedOnlyStateConfig: true}
 	addWithStateHandler(validateRefreshSchedule, nil, validateOnly)
 	addWithStateHandler(validateRefreshRateLimit, nil, validateOnly)
+	addWithStateHandler(validateRefreshHealthGracePeriod, nil, validateOnly)
+	addWithStateHandler(validateRefreshIdle, nil, validateOnly)
 	addWithStateHandler(validateAutomaticSnapshotsExpiration, nil, validateOnly)
+	addWithStateHandler(validateScheduledSnapshots, nil, validateOnly)
+	addWithStateHandler(validateStoreDirectory, nil, validateOnly)
+	addWithStateHandler(validateStorePeers, nil, validateOnly)
 
 	// netplan.*
 	addWithStateHandler(validateNetplanSettings, handleNetplanConfiguration, &flags{coreOnlyConfig: true})
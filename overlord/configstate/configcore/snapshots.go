@@ -23,14 +23,23 @@ package configcore
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 func init() {
 	// add supported configuration of this module
 	supportedConfigurations["core.snapshots.automatic.retention"] = true
+	supportedConfigurations["core.snapshots.schedule"] = true
+	supportedConfigurations["core.snapshots.schedule-snaps"] = true
+	supportedConfigurations["core.snapshots.schedule-keep"] = true
+	supportedConfigurations["core.snapshots.schedule-keep-daily"] = true
+	supportedConfigurations["core.snapshots.schedule-keep-weekly"] = true
 }
 
 func validateAutomaticSnapshotsExpiration(tr config.Conf) error {
@@ -49,3 +58,39 @@ func validateAutomaticSnapshotsExpiration(tr config.Conf) error {
 	}
 	return nil
 }
+
+func validateScheduledSnapshots(tr config.Conf) error {
+	scheduleStr, err := coreCfg(tr, "snapshots.schedule")
+	if err != nil {
+		return err
+	}
+	if scheduleStr != "" {
+		if _, err := timeutil.ParseSchedule(scheduleStr); err != nil {
+			return fmt.Errorf("snapshots.schedule cannot be parsed: %v", err)
+		}
+	}
+
+	snapsStr, err := coreCfg(tr, "snapshots.schedule-snaps")
+	if err != nil {
+		return err
+	}
+	for _, name := range strutil.CommaSeparatedList(snapsStr) {
+		if err := snap.ValidateInstanceName(name); err != nil {
+			return fmt.Errorf("snapshots.schedule-snaps contains an invalid snap name: %v", err)
+		}
+	}
+
+	for _, opt := range []string{"snapshots.schedule-keep", "snapshots.schedule-keep-daily", "snapshots.schedule-keep-weekly"} {
+		countStr, err := coreCfg(tr, opt)
+		if err != nil {
+			return err
+		}
+		if countStr == "" {
+			continue
+		}
+		if _, err := strconv.ParseUint(countStr, 10, 16); err != nil {
+			return fmt.Errorf("%s must be a positive number or 0 to disable, not %q", opt, countStr)
+		}
+	}
+	return nil
+}
@@ -70,3 +70,36 @@ func (s *refreshSuite) TestConfigureAutomaticSnapshotsExpirationInvalid(c *C) {
 	})
 	c.Assert(err, ErrorMatches, `snapshots.automatic.retention cannot be parsed:.*`)
 }
+
+func (s *snapshotsSuite) TestConfigureScheduledSnapshotsHappy(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"snapshots.schedule":             "mon,02:00",
+			"snapshots.schedule-snaps":       "foo,bar_instance",
+			"snapshots.schedule-keep":        "3",
+			"snapshots.schedule-keep-daily":  "7",
+			"snapshots.schedule-keep-weekly": "0",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *snapshotsSuite) TestConfigureScheduledSnapshotsInvalid(c *C) {
+	for _, t := range []struct {
+		conf map[string]interface{}
+		err  string
+	}{
+		{map[string]interface{}{"snapshots.schedule": "whenever"}, `snapshots.schedule cannot be parsed: .*`},
+		{map[string]interface{}{"snapshots.schedule-snaps": "foo,-bar"}, `snapshots.schedule-snaps contains an invalid snap name: invalid snap name: "-bar"`},
+		{map[string]interface{}{"snapshots.schedule-keep": "-1"}, `snapshots.schedule-keep must be a positive number or 0 to disable, not "-1"`},
+		{map[string]interface{}{"snapshots.schedule-keep-daily": "a lot"}, `snapshots.schedule-keep-daily must be a positive number or 0 to disable, not "a lot"`},
+		{map[string]interface{}{"snapshots.schedule-keep-weekly": "1.5"}, `snapshots.schedule-keep-weekly must be a positive number or 0 to disable, not "1.5"`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf:  t.conf,
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.conf))
+	}
+}
@@ -0,0 +1,102 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+// +build !nomanagers
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/strutil"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.store.directory"] = true
+	supportedConfigurations["core.store.peers.discover"] = true
+	supportedConfigurations["core.store.peers.list"] = true
+	supportedConfigurations["core.store.peers.serve"] = true
+	supportedConfigurations["core.store.peers.port"] = true
+}
+
+// validateStoreDirectory checks store.directory, the directory to serve
+// snaps and assertions from instead of the store. It is only used for the
+// stores created after it is set, that is once snapd restarts or on
+// remodel.
+func validateStoreDirectory(tr config.Conf) error {
+	dir, err := coreCfg(tr, "store.directory")
+	if err != nil {
+		return err
+	}
+	if dir != "" && !filepath.IsAbs(dir) {
+		return fmt.Errorf("store.directory must be an absolute path, not %q", dir)
+	}
+	return nil
+}
+
+func validatePort(port string) error {
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil || port == "0" {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// validateStorePeers checks the store.peers.* options configuring the
+// sharing of downloaded snaps with the peers of the LAN. Note that with
+// store.peers.serve the downloaded snaps, private ones included, are
+// served without authentication to anyone on the LAN knowing their digest.
+func validateStorePeers(tr config.Conf) error {
+	for _, flag := range []string{"store.peers.discover", "store.peers.serve"} {
+		if err := validateBoolFlag(tr, flag); err != nil {
+			return err
+		}
+	}
+
+	port, err := coreCfg(tr, "store.peers.port")
+	if err != nil {
+		return err
+	}
+	if port != "" {
+		if err := validatePort(port); err != nil {
+			return fmt.Errorf("store.peers.port must be a port number, not %q", port)
+		}
+	}
+
+	peers, err := coreCfg(tr, "store.peers.list")
+	if err != nil {
+		return err
+	}
+	for _, peer := range strutil.CommaSeparatedList(peers) {
+		host, port, err := net.SplitHostPort(peer)
+		if err != nil {
+			// without port
+			host, port = peer, ""
+		}
+		if host == "" || (net.ParseIP(host) == nil && strings.ContainsAny(host, ":/ []")) || (port != "" && validatePort(port) != nil) {
+			return fmt.Errorf("store.peers.list contains an invalid peer address %q, expected host or host:port", peer)
+		}
+	}
+	return nil
+}
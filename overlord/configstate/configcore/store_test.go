@@ -0,0 +1,89 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type storeSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&storeSuite{})
+
+func (s *storeSuite) TestConfigureStoreDirectoryHappy(c *C) {
+	for _, dir := range []string{"", "/media/usb/snaps"} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"store.directory": dir,
+			},
+		})
+		c.Check(err, IsNil)
+	}
+}
+
+func (s *storeSuite) TestConfigureStoreDirectoryRelative(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"store.directory": "media/usb/snaps",
+		},
+	})
+	c.Assert(err, ErrorMatches, `store.directory must be an absolute path, not "media/usb/snaps"`)
+}
+
+func (s *storeSuite) TestConfigureStorePeersHappy(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"store.peers.discover": "true",
+			"store.peers.serve":    "false",
+			"store.peers.port":     "8080",
+			"store.peers.list":     "cache.lan,192.168.1.5:8080,[fe80::1]:8080,fe80::2",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *storeSuite) TestConfigureStorePeersInvalid(c *C) {
+	for _, t := range []struct {
+		opt, value, err string
+	}{
+		{"store.peers.discover", "yes", `store.peers.discover can only be set to 'true' or 'false'`},
+		{"store.peers.serve", "1", `store.peers.serve can only be set to 'true' or 'false'`},
+		{"store.peers.port", "0", `store.peers.port must be a port number, not "0"`},
+		{"store.peers.port", "65536", `store.peers.port must be a port number, not "65536"`},
+		{"store.peers.list", "cache.lan,http://other.lan", `store.peers.list contains an invalid peer address "http://other.lan", expected host or host:port`},
+		{"store.peers.list", "cache.lan:http", `store.peers.list contains an invalid peer address "cache.lan:http", expected host or host:port`},
+		{"store.peers.list", ":8080", `store.peers.list contains an invalid peer address ":8080", expected host or host:port`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				t.opt: t.value,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%s=%s", t.opt, t.value))
+	}
+}
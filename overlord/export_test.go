@@ -86,6 +86,11 @@ func (o *Overlord) NewStore(devBE storecontext.DeviceBackend) snapstate.StoreSer
 	return o.newStore(devBE)
 }
 
+// PeerSettings exposes peerSettings.
+func (o *Overlord) PeerSettings() (*store.PeerSettings, error) {
+	return o.peerSettings()
+}
+
 // MockStoreNew mocks store.New as called by overlord.New.
 func MockStoreNew(new func(*store.Config, store.DeviceAndAuthContext) *store.Store) (restore func()) {
 	storeNew = new
@@ -0,0 +1,383 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package healthstate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var (
+	// healthCheckInterval is the interval at which the health of the
+	// snaps refreshed within the grace period is checked
+	healthCheckInterval = time.Minute
+	// crashLoopRestarts is the number of automatic restarts of a service
+	// after which it is considered failing, even though systemd did not
+	// give up on it yet
+	crashLoopRestarts uint64 = 3
+
+	timeNow         = time.Now
+	snapstateRevert = snapstate.Revert
+)
+
+// HealthManager reverts the refreshes of snaps whose health degrades within
+// the refresh.health-grace-period following the refresh, that is when the
+// check-health hook of the snap reports an error or one of its services
+// fails or keeps being restarted, unless it was already the case before the
+// refresh.
+type HealthManager struct {
+	state *state.State
+
+	nextCheck time.Time
+}
+
+// Manager returns a new health manager.
+func Manager(st *state.State) *HealthManager {
+	snapstate.StoppingServicesForRefresh = recordFailingServices
+	return &HealthManager{state: st}
+}
+
+// healthGracePeriod returns the value of refresh.health-grace-period, zero
+// when the automatic rollback of refreshes is disabled.
+func healthGracePeriod(st *state.State) (time.Duration, error) {
+	var gracePeriod string
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "refresh.health-grace-period", &gracePeriod); err != nil && !config.IsNoOption(err) {
+		return 0, err
+	}
+	if gracePeriod == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(gracePeriod)
+}
+
+type watchedRefresh struct {
+	snapName    string
+	revision    snap.Revision
+	refreshTime time.Time
+	services    []string
+	// failingBefore are the services that were already failing
+	// before the refresh
+	failingBefore map[string]bool
+}
+
+// Ensure implements StateManager.Ensure.
+func (m *HealthManager) Ensure() error {
+	now := timeNow()
+	if now.Before(m.nextCheck) {
+		return nil
+	}
+	m.nextCheck = now.Add(healthCheckInterval)
+
+	m.state.Lock()
+	watched, err := m.watchedRefreshes(now)
+	m.state.Unlock()
+	if err != nil || len(watched) == 0 {
+		return err
+	}
+
+	// the services are queried with the state unlocked
+	failing, err := failingServicesOf(watched)
+	if err != nil {
+		// the health status can still be checked
+		logger.Noticef("cannot get the status of the services of refreshed snaps: %v", err)
+	}
+
+	m.state.Lock()
+	defer m.state.Unlock()
+	for _, w := range watched {
+		reason, err := degradedReason(m.state, w, failing)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			m.revert(w, reason)
+		}
+	}
+	// keep watching until the grace period of all refreshes is over
+	m.state.EnsureBefore(healthCheckInterval)
+	return nil
+}
+
+// watchedRefreshes returns the refreshes still within the grace period and
+// not reverted yet.
+func (m *HealthManager) watchedRefreshes(now time.Time) ([]*watchedRefresh, error) {
+	gracePeriod, err := healthGracePeriod(m.state)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get refresh.health-grace-period: %v", err)
+	}
+	if gracePeriod <= 0 {
+		return nil, nil
+	}
+	reverted, err := revertedRefreshes(m.state)
+	if err != nil {
+		return nil, err
+	}
+	failingBefore, err := failingBeforeRefresh(m.state)
+	if err != nil {
+		return nil, err
+	}
+	snapStates, err := snapstate.All(m.state)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(snapStates))
+	for name := range snapStates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var watched []*watchedRefresh
+	for _, name := range names {
+		snapst := snapStates[name]
+		if snapst.LastRefreshTime == nil || !snapst.Active || len(snapst.Sequence) < 2 {
+			continue
+		}
+		refreshTime := *snapst.LastRefreshTime
+		if now.After(refreshTime.Add(gracePeriod)) {
+			continue
+		}
+		if t, ok := reverted[name]; ok && t.Equal(refreshTime) {
+			continue
+		}
+		// the last refresh was reverted manually since
+		if snapst.Current != snapst.Sequence[len(snapst.Sequence)-1].Revision {
+			continue
+		}
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			return nil, err
+		}
+		w := &watchedRefresh{
+			snapName:      name,
+			revision:      snapst.Current,
+			refreshTime:   refreshTime,
+			services:      systemServices(info.Services()),
+			failingBefore: make(map[string]bool),
+		}
+		for _, service := range failingBefore[name] {
+			w.failingBefore[service] = true
+		}
+		watched = append(watched, w)
+	}
+	return watched, nil
+}
+
+// systemServices returns the names of the units of the given services that
+// are visible to the system instance of systemd, that is all but the user
+// services.
+func systemServices(apps []*snap.AppInfo) []string {
+	var services []string
+	for _, app := range apps {
+		if app.DaemonScope == snap.SystemDaemon {
+			services = append(services, app.ServiceName())
+		}
+	}
+	return services
+}
+
+// failingServicesOf returns the failing services among those of the
+// watched refreshes.
+func failingServicesOf(watched []*watchedRefresh) (map[string]string, error) {
+	var services []string
+	for _, w := range watched {
+		services = append(services, w.services...)
+	}
+	return failingServices(services)
+}
+
+// failingServices returns the services among the given ones that failed or
+// keep being restarted by systemd, mapped to how often they were restarted
+// in the latter case.
+func failingServices(services []string) (map[string]string, error) {
+	if len(services) == 0 {
+		return nil, nil
+	}
+	sysd := systemd.New(systemd.SystemMode, nil)
+	sts, err := sysd.Status(services)
+	if err != nil {
+		return nil, err
+	}
+	failing := make(map[string]string)
+	for _, st := range sts {
+		if !st.Installed {
+			continue
+		}
+		if st.Failed {
+			failing[st.Name] = ""
+			continue
+		}
+		restarts, err := sysd.RestartsCount(st.Name)
+		if err != nil {
+			// NRestarts is only known to systemd 235 and later
+			logger.Debugf("cannot get the restarts count of %q: %v", st.Name, err)
+			continue
+		}
+		if restarts >= crashLoopRestarts {
+			failing[st.Name] = fmt.Sprintf("restarted %d times", restarts)
+		}
+	}
+	return failing, nil
+}
+
+// failingBeforeRefresh returns the services that were failing right before
+// the last refresh of each snap, indexed by snap name.
+func failingBeforeRefresh(st *state.State) (map[string][]string, error) {
+	var failing map[string][]string
+	if err := st.Get("health-failing-before-refresh", &failing); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if failing == nil {
+		failing = make(map[string][]string)
+	}
+	return failing, nil
+}
+
+// recordFailingServices records the services of the snap that are failing
+// right before they are stopped for a refresh, so that they do not count
+// against the health of the new revision. It is called with the state
+// unlocked.
+func recordFailingServices(st *state.State, snapName string, apps []*snap.AppInfo) {
+	st.Lock()
+	gracePeriod, err := healthGracePeriod(st)
+	st.Unlock()
+	if err != nil || gracePeriod <= 0 {
+		return
+	}
+
+	var failingBefore []string
+	failing, err := failingServices(systemServices(apps))
+	if err != nil {
+		logger.Noticef("cannot get the status of the services of snap %q before its refresh: %v", snapName, err)
+	}
+	for service := range failing {
+		failingBefore = append(failingBefore, service)
+	}
+	sort.Strings(failingBefore)
+
+	st.Lock()
+	defer st.Unlock()
+	record, err := failingBeforeRefresh(st)
+	if err != nil {
+		// overwrite the unreadable entry
+		record = make(map[string][]string)
+	}
+	if len(failingBefore) > 0 {
+		record[snapName] = failingBefore
+	} else {
+		delete(record, snapName)
+	}
+	st.Set("health-failing-before-refresh", record)
+}
+
+// degradedReason returns why the health of a refreshed snap is considered
+// degraded, or an empty string if it is not.
+func degradedReason(st *state.State, w *watchedRefresh, failing map[string]string) (string, error) {
+	health, err := Get(st, w.snapName)
+	if err != nil {
+		return "", err
+	}
+	if health != nil && health.Status == ErrorStatus && health.Revision == w.revision && !health.Timestamp.Before(w.refreshTime) {
+		reason := "its health check reported an error"
+		if health.Message != "" {
+			reason += fmt.Sprintf(": %s", health.Message)
+		}
+		return reason, nil
+	}
+	var failedNames []string
+	for _, service := range w.services {
+		restarts, ok := failing[service]
+		if !ok || w.failingBefore[service] {
+			continue
+		}
+		if restarts != "" {
+			service += fmt.Sprintf(" (%s)", restarts)
+		}
+		failedNames = append(failedNames, service)
+	}
+	if len(failedNames) > 0 {
+		return fmt.Sprintf("its services failed: %s", strings.Join(failedNames, ", ")), nil
+	}
+	return "", nil
+}
+
+// revertedRefreshes returns the time of the refreshes that were reverted
+// by the health manager, indexed by snap name.
+func revertedRefreshes(st *state.State) (map[string]time.Time, error) {
+	var reverted map[string]time.Time
+	if err := st.Get("health-reverted-refreshes", &reverted); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if reverted == nil {
+		reverted = make(map[string]time.Time)
+	}
+	return reverted, nil
+}
+
+func (m *HealthManager) revert(w *watchedRefresh, reason string) {
+	ts, err := snapstateRevert(m.state, w.snapName, snapstate.Flags{}, "")
+	if err != nil {
+		if _, ok := err.(*snapstate.ChangeConflictError); ok {
+			// try again once the conflicting change is done
+			logger.Debugf("cannot revert snap %q yet: %v", w.snapName, err)
+			return
+		}
+		// do not try again
+		logger.Noticef("cannot revert snap %q: %v", w.snapName, err)
+		m.state.Warnf("cannot revert snap %q to its previous revision after %s: %v", w.snapName, reason, err)
+		m.markReverted(w)
+		return
+	}
+	msg := fmt.Sprintf("Revert %q snap after its health degraded following the refresh to revision %s", w.snapName, w.revision)
+	chg := m.state.NewChange("revert-snap", msg)
+	chg.AddAll(ts)
+	chg.Set("api-data", map[string]interface{}{"snap-names": []string{w.snapName}})
+	m.markReverted(w)
+	logger.Noticef("Reverting snap %q: %s", w.snapName, reason)
+	m.state.Warnf("snap %q was reverted to its previous revision as %s within the refresh.health-grace-period after its refresh to revision %s", w.snapName, reason, w.revision)
+	m.state.EnsureBefore(0)
+}
+
+func (m *HealthManager) markReverted(w *watchedRefresh) {
+	reverted, err := revertedRefreshes(m.state)
+	if err != nil {
+		// overwrite the unreadable entry
+		reverted = make(map[string]time.Time)
+	}
+	// forget about the snaps that are gone
+	for name := range reverted {
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(m.state, name, &snapst); errors.Is(err, state.ErrNoState) {
+			delete(reverted, name)
+		}
+	}
+	reverted[w.snapName] = w.refreshTime
+	m.state.Set("health-reverted-refreshes", reverted)
+}
@@ -0,0 +1,391 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package healthstate_test
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type rollbackSuite struct {
+	testutil.BaseTest
+	state *state.State
+	mgr   *healthstate.HealthManager
+
+	now         time.Time
+	refreshTime time.Time
+	activeState string
+	restarts    string
+	reverted    []string
+}
+
+var _ = check.Suite(&rollbackSuite{})
+
+const rollbackSnapYaml = `name: test-snap
+version: v2
+apps:
+  svc:
+    daemon: simple
+  user-svc:
+    daemon: simple
+    daemon-scope: user
+  cmd:
+`
+
+func (s *rollbackSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+
+	s.state = state.New(nil)
+	s.mgr = healthstate.Manager(s.state)
+
+	s.refreshTime = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	s.now = s.refreshTime.Add(5 * time.Minute)
+	s.AddCleanup(healthstate.MockTimeNow(func() time.Time { return s.now }))
+
+	s.activeState = "active"
+	s.restarts = "0"
+	s.AddCleanup(systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		if len(args) == 4 && args[2] == "NRestarts" {
+			c.Check(args, check.DeepEquals, []string{"show", "--property", "NRestarts", "snap.test-snap.svc.service"})
+			if s.restarts == "" {
+				// systemd before 235
+				return nil, nil
+			}
+			return []byte("NRestarts=" + s.restarts), nil
+		}
+		c.Check(args, check.DeepEquals, []string{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload", "snap.test-snap.svc.service"})
+		return []byte(fmt.Sprintf(`Type=simple
+Id=snap.test-snap.svc.service
+Names=snap.test-snap.svc.service
+ActiveState=%s
+UnitFileState=enabled
+NeedDaemonReload=no
+`, s.activeState)), nil
+	}))
+
+	s.reverted = nil
+	s.AddCleanup(healthstate.MockSnapstateRevert(func(st *state.State, name string, flags snapstate.Flags, fromChange string) (*state.TaskSet, error) {
+		s.reverted = append(s.reverted, name)
+		return state.NewTaskSet(st.NewTask("fake-revert", "Revert "+name)), nil
+	}))
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	si1 := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	si2 := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(2)}
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Sequence:        []*snap.SideInfo{si1, si2},
+		Current:         snap.R(2),
+		Active:          true,
+		SnapType:        "app",
+		LastRefreshTime: &s.refreshTime,
+	})
+	snaptest.MockSnap(c, rollbackSnapYaml, si2)
+	s.setGracePeriod(c, "10m")
+}
+
+func (s *rollbackSuite) setGracePeriod(c *check.C, gracePeriod string) {
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.health-grace-period", gracePeriod), check.IsNil)
+	tr.Commit()
+}
+
+func (s *rollbackSuite) setHealth(c *check.C, rev snap.Revision, status healthstate.HealthStatus, t time.Time) {
+	s.state.Set("health", map[string]*healthstate.HealthState{
+		"test-snap": {Revision: rev, Timestamp: t, Status: status, Message: "database is gone"},
+	})
+}
+
+func (s *rollbackSuite) ensure(c *check.C) {
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	// ignore the throttling
+	s.mgr = healthstate.Manager(s.state)
+}
+
+func (s *rollbackSuite) TestHealthyRefresh(c *check.C) {
+	s.state.Lock()
+	s.setHealth(c, snap.R(2), healthstate.OkayStatus, s.refreshTime.Add(time.Minute))
+	s.state.Unlock()
+
+	s.ensure(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.reverted, check.HasLen, 0)
+	c.Check(s.state.Changes(), check.HasLen, 0)
+	c.Check(s.state.AllWarnings(), check.HasLen, 0)
+}
+
+func (s *rollbackSuite) checkReverted(c *check.C, reason string) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.reverted, check.DeepEquals, []string{"test-snap"})
+	chgs := s.state.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	c.Check(chgs[0].Kind(), check.Equals, "revert-snap")
+	c.Check(chgs[0].Summary(), check.Equals, `Revert "test-snap" snap after its health degraded following the refresh to revision 2`)
+	c.Check(chgs[0].Tasks(), check.HasLen, 1)
+	var apiData map[string]interface{}
+	c.Assert(chgs[0].Get("api-data", &apiData), check.IsNil)
+	c.Check(apiData, check.DeepEquals, map[string]interface{}{"snap-names": []interface{}{"test-snap"}})
+
+	warnings := s.state.AllWarnings()
+	c.Assert(warnings, check.HasLen, 1)
+	c.Check(warnings[0].String(), check.Equals, fmt.Sprintf(`snap "test-snap" was reverted to its previous revision as %s within the refresh.health-grace-period after its refresh to revision 2`, reason))
+}
+
+func (s *rollbackSuite) TestRevertOnHealthError(c *check.C) {
+	s.state.Lock()
+	s.setHealth(c, snap.R(2), healthstate.ErrorStatus, s.refreshTime.Add(time.Minute))
+	s.state.Unlock()
+
+	s.ensure(c)
+	s.checkReverted(c, "its health check reported an error: database is gone")
+
+	// the refresh is reverted only once
+	s.ensure(c)
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.reverted, check.HasLen, 1)
+	c.Check(s.state.Changes(), check.HasLen, 1)
+}
+
+func (s *rollbackSuite) TestRevertOnFailedService(c *check.C) {
+	s.activeState = "failed"
+
+	s.ensure(c)
+	s.checkReverted(c, "its services failed: snap.test-snap.svc.service")
+}
+
+func (s *rollbackSuite) TestRevertOnCrashLoopingService(c *check.C) {
+	s.activeState = "activating"
+	s.restarts = "5"
+
+	s.ensure(c)
+	s.checkReverted(c, "its services failed: snap.test-snap.svc.service (restarted 5 times)")
+}
+
+func (s *rollbackSuite) TestNoRevertOnFewRestarts(c *check.C) {
+	s.restarts = "2"
+
+	s.ensure(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.reverted, check.HasLen, 0)
+	c.Check(s.state.Changes(), check.HasLen, 0)
+}
+
+func (s *rollbackSuite) TestNoRevertWithoutRestartsCount(c *check.C) {
+	s.restarts = ""
+
+	s.ensure(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.reverted, check.HasLen, 0)
+	c.Check(s.state.Changes(), check.HasLen, 0)
+}
+
+func (s *rollbackSuite) serviceApps(c *check.C) []*snap.AppInfo {
+	info := snaptest.MockInfo(c, rollbackSnapYaml, &snap.SideInfo{Revision: snap.R(1)})
+	return info.Services()
+}
+
+func (s *rollbackSuite) TestNoRevertOfServiceFailingBeforeRefresh(c *check.C) {
+	s.activeState = "failed"
+	// the service was already failed when it was stopped for the refresh
+	snapstate.StoppingServicesForRefresh(s.state, "test-snap", s.serviceApps(c))
+
+	s.state.Lock()
+	var failing map[string][]string
+	c.Assert(s.state.Get("health-failing-before-refresh", &failing), check.IsNil)
+	c.Check(failing, check.DeepEquals, map[string][]string{"test-snap": {"snap.test-snap.svc.service"}})
+	s.state.Unlock()
+
+	s.ensure(c)
+
+	s.state.Lock()
+	c.Check(s.reverted, check.HasLen, 0)
+	c.Check(s.state.Changes(), check.HasLen, 0)
+	s.state.Unlock()
+
+	// the next refresh finds it healthy
+	s.activeState = "active"
+	snapstate.StoppingServicesForRefresh(s.state, "test-snap", s.serviceApps(c))
+
+	s.state.Lock()
+	failing = nil
+	c.Assert(s.state.Get("health-failing-before-refresh", &failing), check.IsNil)
+	c.Check(failing, check.HasLen, 0)
+	s.state.Unlock()
+
+	// so it failing afterwards is a regression
+	s.activeState = "failed"
+	s.ensure(c)
+	s.checkReverted(c, "its services failed: snap.test-snap.svc.service")
+}
+
+func (s *rollbackSuite) TestFailingServicesNotRecordedWhenDisabled(c *check.C) {
+	s.state.Lock()
+	s.setGracePeriod(c, "")
+	s.state.Unlock()
+	s.AddCleanup(systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		c.Errorf("unexpected systemctl call: %v", args)
+		return nil, nil
+	}))
+
+	snapstate.StoppingServicesForRefresh(s.state, "test-snap", s.serviceApps(c))
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var failing map[string][]string
+	c.Check(s.state.Get("health-failing-before-refresh", &failing), testutil.ErrorIs, state.ErrNoState)
+}
+
+func (s *rollbackSuite) TestNoRevertOfHealthErrorOfOtherRevision(c *check.C) {
+	s.state.Lock()
+	// reported by the previous revision, before the refresh
+	s.setHealth(c, snap.R(1), healthstate.ErrorStatus, s.refreshTime.Add(-time.Minute))
+	s.state.Unlock()
+
+	s.ensure(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.reverted, check.HasLen, 0)
+	c.Check(s.state.Changes(), check.HasLen, 0)
+}
+
+func (s *rollbackSuite) TestNoRevertAfterGracePeriod(c *check.C) {
+	s.activeState = "failed"
+	s.now = s.refreshTime.Add(11 * time.Minute)
+
+	s.ensure(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.reverted, check.HasLen, 0)
+	c.Check(s.state.Changes(), check.HasLen, 0)
+}
+
+func (s *rollbackSuite) TestNoRevertWhenDisabled(c *check.C) {
+	s.activeState = "failed"
+	s.state.Lock()
+	s.setGracePeriod(c, "")
+	s.state.Unlock()
+
+	s.ensure(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.reverted, check.HasLen, 0)
+	c.Check(s.state.Changes(), check.HasLen, 0)
+}
+
+func (s *rollbackSuite) TestNoRevertAfterManualRevert(c *check.C) {
+	s.activeState = "failed"
+	s.state.Lock()
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "test-snap", &snapst), check.IsNil)
+	snapst.Current = snap.R(1)
+	snapstate.Set(s.state, "test-snap", &snapst)
+	s.state.Unlock()
+
+	s.ensure(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.reverted, check.HasLen, 0)
+}
+
+func (s *rollbackSuite) TestRevertConflictRetried(c *check.C) {
+	s.activeState = "failed"
+	restore := healthstate.MockSnapstateRevert(func(st *state.State, name string, flags snapstate.Flags, fromChange string) (*state.TaskSet, error) {
+		return nil, &snapstate.ChangeConflictError{Snap: name, ChangeKind: "refresh-snap"}
+	})
+	s.ensure(c)
+	restore()
+
+	s.state.Lock()
+	c.Check(s.state.Changes(), check.HasLen, 0)
+	c.Check(s.state.AllWarnings(), check.HasLen, 0)
+	s.state.Unlock()
+
+	s.ensure(c)
+	s.checkReverted(c, "its services failed: snap.test-snap.svc.service")
+}
+
+func (s *rollbackSuite) TestRevertErrorWarns(c *check.C) {
+	s.activeState = "failed"
+	s.AddCleanup(healthstate.MockSnapstateRevert(func(st *state.State, name string, flags snapstate.Flags, fromChange string) (*state.TaskSet, error) {
+		return nil, fmt.Errorf("boom")
+	}))
+
+	s.ensure(c)
+	s.ensure(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), check.HasLen, 0)
+	warnings := s.state.AllWarnings()
+	c.Assert(warnings, check.HasLen, 1)
+	c.Check(strings.HasPrefix(warnings[0].String(), `cannot revert snap "test-snap" to its previous revision after its services failed`), check.Equals, true)
+}
+
+func (s *rollbackSuite) TestEnsureThrottled(c *check.C) {
+	n := 0
+	s.AddCleanup(systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		if args[2] == "NRestarts" {
+			return []byte("NRestarts=0"), nil
+		}
+		n++
+		return []byte(`Type=simple
+Id=snap.test-snap.svc.service
+Names=snap.test-snap.svc.service
+ActiveState=active
+UnitFileState=enabled
+NeedDaemonReload=no
+`), nil
+	}))
+
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	c.Check(n, check.Equals, 1)
+
+	s.now = s.now.Add(time.Minute)
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	c.Check(n, check.Equals, 2)
+}
@@ -0,0 +1,84 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/snapstate"
+)
+
+var (
+	shortBusyHelp = i18n.G("Mark the snap as busy to postpone auto-refreshes")
+	longBusyHelp  = i18n.G(`
+The set-busy command is called from within a snap to tell the system that the
+snap is doing work that should not be interrupted, such as a robot executing a
+task or a kiosk serving a customer.
+
+When the system is configured to refresh only while idle, by listing busy-snaps
+in the refresh.idle option, automatic refreshes are postponed while any snap is
+marked busy. The mark expires after the given duration (one hour by default, at
+most 24 hours) and can be removed earlier with --clear.
+`)
+)
+
+var timeNow = time.Now
+
+func init() {
+	addCommand("set-busy", shortBusyHelp, longBusyHelp, func() command { return &busyCommand{} })
+}
+
+type busyCommand struct {
+	baseCommand
+	For   string `long:"for" value-name:"<duration>" description:"how long the snap stays busy, at most 24h (defaults to 1h)"`
+	Clear bool   `long:"clear" description:"the snap is no longer busy"`
+}
+
+const defaultBusyDuration = time.Hour
+
+func (c *busyCommand) Execute([]string) error {
+	if c.Clear && c.For != "" {
+		return fmt.Errorf("cannot use --for and --clear together")
+	}
+
+	duration := defaultBusyDuration
+	if c.For != "" {
+		var err error
+		duration, err = time.ParseDuration(c.For)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("invalid duration %q, expected a positive duration like 30m or 2h", c.For)
+		}
+	}
+
+	ctx, err := c.ensureContext()
+	if err != nil {
+		return err
+	}
+	ctx.Lock()
+	defer ctx.Unlock()
+
+	var until time.Time
+	if !c.Clear {
+		until = timeNow().Add(duration)
+	}
+	return snapstate.SetBusy(ctx.State(), ctx.InstanceName(), until)
+}
@@ -0,0 +1,125 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type busySuite struct {
+	testutil.BaseTest
+	state       *state.State
+	mockContext *hookstate.Context
+}
+
+var _ = check.Suite(&busySuite{})
+
+func (s *busySuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+
+	s.state = state.New(nil)
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(42)}
+
+	ctx, err := hookstate.NewContext(nil, s.state, setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, check.IsNil)
+	s.mockContext = ctx
+}
+
+func (s *busySuite) busyUntil(c *check.C) (time.Time, bool) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	var busy map[string]time.Time
+	err := s.state.Get("refresh-busy-snaps", &busy)
+	if err != nil {
+		c.Assert(err, testutil.ErrorIs, state.ErrNoState)
+	}
+	until, ok := busy["test-snap"]
+	return until, ok
+}
+
+func (s *busySuite) TestBadArgs(c *check.C) {
+	for i, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"set-busy", "--for=soon"}, `invalid duration "soon", expected a positive duration like 30m or 2h`},
+		{[]string{"set-busy", "--for=-1h"}, `invalid duration "-1h", expected a positive duration like 30m or 2h`},
+		{[]string{"set-busy", "--for=25h"}, `cannot mark snap "test-snap" busy for more than 24h0m0s`},
+		{[]string{"set-busy", "--for=1h", "--clear"}, `cannot use --for and --clear together`},
+	} {
+		_, _, err := ctlcmd.Run(s.mockContext, t.args, 0)
+		c.Check(err, check.ErrorMatches, t.err, check.Commentf("%d", i))
+	}
+
+	_, _, err := ctlcmd.Run(nil, []string{"set-busy"}, 0)
+	c.Check(err, check.ErrorMatches, `cannot invoke snapctl operation commands \(here "set-busy"\) from outside of a snap`)
+}
+
+func (s *busySuite) TestSetBusy(c *check.C) {
+	now := time.Now().Truncate(time.Second)
+	restore := ctlcmd.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"set-busy"}, 0)
+	c.Assert(err, check.IsNil)
+
+	until, ok := s.busyUntil(c)
+	c.Assert(ok, check.Equals, true)
+	c.Check(until.Equal(now.Add(time.Hour)), check.Equals, true)
+
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"set-busy", "--for=3h"}, 0)
+	c.Assert(err, check.IsNil)
+
+	until, ok = s.busyUntil(c)
+	c.Assert(ok, check.Equals, true)
+	c.Check(until.Equal(now.Add(3*time.Hour)), check.Equals, true)
+}
+
+func (s *busySuite) TestSetBusyNeedsRoot(c *check.C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"set-busy"}, 1000)
+	c.Check(err, check.ErrorMatches, `cannot use "set-busy" with uid 1000, try with sudo`)
+	_, ok := s.busyUntil(c)
+	c.Check(ok, check.Equals, false)
+}
+
+func (s *busySuite) TestClearBusy(c *check.C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"set-busy", "--for=30m"}, 0)
+	c.Assert(err, check.IsNil)
+	_, ok := s.busyUntil(c)
+	c.Assert(ok, check.Equals, true)
+
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"set-busy", "--clear"}, 0)
+	c.Assert(err, check.IsNil)
+	_, ok = s.busyUntil(c)
+	c.Check(ok, check.Equals, false)
+}
@@ -21,6 +21,7 @@ package ctlcmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/overlord/devicestate"
@@ -148,6 +149,14 @@ func MockCgroupSnapNameFromPid(f func(int) (string, error)) (restore func()) {
 	}
 }
 
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
+
 func MockAutoRefreshForGatingSnap(f func(st *state.State, gatingSnap string) error) (restore func()) {
 	old := autoRefreshForGatingSnap
 	autoRefreshForGatingSnap = f
@@ -0,0 +1,288 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/interfaces/denials"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var (
+	// denialsCollectInterval is the interval at which the denials are
+	// collected from the audit log by Ensure
+	denialsCollectInterval = 10 * time.Minute
+	// denialsInitialLogEntries is the number of audit log entries looked
+	// at when collecting the denials for the first time
+	denialsInitialLogEntries = 1000
+	// maxDeniedAccesses is the number of denied accesses kept in the
+	// state, the ones seen least recently are dropped first
+	maxDeniedAccesses = 500
+)
+
+// DeniedAccess is an access that the sandbox of an app or a hook of a snap
+// denied, aggregated over all the times it was denied.
+type DeniedAccess struct {
+	Snap     string        `json:"snap"`
+	App      string        `json:"app,omitempty"`
+	Hook     string        `json:"hook,omitempty"`
+	Revision snap.Revision `json:"revision"`
+
+	Denial *denials.Denial `json:"denial"`
+	// Description describes the denied access in a human readable way.
+	Description string `json:"description"`
+
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first-seen"`
+	LastSeen  time.Time `json:"last-seen"`
+
+	// SuggestedInterfaces are the interfaces that would grant the
+	// denied access, if any.
+	SuggestedInterfaces []string `json:"suggested-interfaces,omitempty"`
+}
+
+func (a *DeniedAccess) key() string {
+	return fmt.Sprintf("%s:%s:%s:%s:%s:%s", a.Snap, a.App, a.Hook, a.Revision, a.Denial.Sandbox, a.Description)
+}
+
+func (a *DeniedAccess) warning() string {
+	who := fmt.Sprintf("snap %q", a.Snap)
+	switch {
+	case a.App != "":
+		who = fmt.Sprintf("app %q of snap %q", a.App, a.Snap)
+	case a.Hook != "":
+		who = fmt.Sprintf("hook %q of snap %q", a.Hook, a.Snap)
+	}
+	msg := fmt.Sprintf("%s was denied %q by %s", who, a.Description, a.Denial.Sandbox)
+	if len(a.SuggestedInterfaces) > 0 {
+		msg += fmt.Sprintf("; connecting one of the interfaces %s might allow it", strings.Join(a.SuggestedInterfaces, ", "))
+	}
+	return msg
+}
+
+// Denials returns the accesses denied to the apps and hooks of the given
+// snap, or of all snaps when the name is empty, sorted by snap name and
+// the time they were first denied.
+func Denials(st *state.State, snapName string) ([]*DeniedAccess, error) {
+	var accesses []*DeniedAccess
+	if err := st.Get("denials", &accesses); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	result := make([]*DeniedAccess, 0, len(accesses))
+	for _, access := range accesses {
+		if snapName == "" || access.Snap == snapName {
+			result = append(result, access)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Snap != result[j].Snap {
+			return result[i].Snap < result[j].Snap
+		}
+		return result[i].FirstSeen.Before(result[j].FirstSeen)
+	})
+	return result, nil
+}
+
+type auditDenial struct {
+	denial *denials.Denial
+	time   time.Time
+}
+
+// CollectDenials reads the AppArmor and seccomp denials logged to the audit
+// log since the last collection and records them in the state, attributed
+// to the snaps they were denied to. A warning is added for each new access
+// denied to a strictly confined snap.
+//
+// The state must not be locked by the caller.
+func (m *InterfaceManager) CollectDenials() error {
+	m.denialsMu.Lock()
+	defer m.denialsMu.Unlock()
+
+	var cursor string
+	m.state.Lock()
+	err := m.state.Get("denials-cursor", &cursor)
+	m.state.Unlock()
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+
+	rd, err := systemd.AuditLogReader(cursor, denialsInitialLogEntries)
+	if err != nil {
+		return fmt.Errorf("cannot read the audit log: %v", err)
+	}
+	defer rd.Close()
+
+	var found []auditDenial
+	newCursor := cursor
+	decoder := json.NewDecoder(rd)
+	for {
+		var log systemd.Log
+		if err := decoder.Decode(&log); err != nil {
+			if err == io.EOF {
+				break
+			}
+			if cursor != "" {
+				// start over from the most recent entries
+				// next time, the cursor might refer to
+				// entries that are gone
+				m.state.Lock()
+				m.state.Set("denials-cursor", nil)
+				m.state.Unlock()
+			}
+			return fmt.Errorf("cannot read the audit log: %v", err)
+		}
+		if c := log.Cursor(); c != "" {
+			newCursor = c
+		}
+		denial := denials.ParseAuditMessage(log.Message())
+		if denial == nil {
+			continue
+		}
+		t, err := log.Time()
+		if err != nil {
+			t = timeNow()
+		}
+		found = append(found, auditDenial{denial: denial, time: t})
+	}
+
+	m.state.Lock()
+	defer m.state.Unlock()
+	m.state.Set("denials-cursor", newCursor)
+	return m.recordDenials(found)
+}
+
+func (m *InterfaceManager) recordDenials(found []auditDenial) error {
+	if len(found) == 0 {
+		return nil
+	}
+	if m.denialsSuggester == nil {
+		m.denialsSuggester = denials.NewSuggester(m.repo.AllInterfaces())
+	}
+
+	var accesses []*DeniedAccess
+	if err := m.state.Get("denials", &accesses); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	known := make(map[string]*DeniedAccess, len(accesses))
+	for _, access := range accesses {
+		known[access.key()] = access
+	}
+
+	for _, ad := range found {
+		tag, err := naming.ParseSecurityTag(ad.denial.Label)
+		if err != nil {
+			continue
+		}
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(m.state, tag.InstanceName(), &snapst); err != nil {
+			if errors.Is(err, state.ErrNoState) {
+				// the snap was removed since
+				continue
+			}
+			return err
+		}
+		access := &DeniedAccess{
+			Snap:        tag.InstanceName(),
+			Revision:    snapst.Current,
+			Denial:      ad.denial,
+			Description: ad.denial.String(),
+		}
+		switch tag := tag.(type) {
+		case naming.AppSecurityTag:
+			access.App = tag.AppName()
+		case naming.HookSecurityTag:
+			access.Hook = tag.HookName()
+		}
+
+		if known := known[access.key()]; known != nil {
+			known.Count++
+			if ad.time.After(known.LastSeen) {
+				known.LastSeen = ad.time
+			}
+			continue
+		}
+		access.Count = 1
+		access.FirstSeen = ad.time
+		access.LastSeen = ad.time
+		access.SuggestedInterfaces = m.denialsSuggester.Suggest(ad.denial)
+		known[access.key()] = access
+		accesses = append(accesses, access)
+
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			logger.Noticef("cannot get info of snap %q: %v", access.Snap, err)
+			continue
+		}
+		// devmode snaps are not denied anything, those are from a
+		// previous revision
+		if info.Confinement == snap.StrictConfinement && !snapst.DevMode {
+			m.state.Warnf("%s", access.warning())
+		}
+	}
+
+	if len(accesses) > maxDeniedAccesses {
+		sort.SliceStable(accesses, func(i, j int) bool {
+			return accesses[i].LastSeen.After(accesses[j].LastSeen)
+		})
+		accesses = accesses[:maxDeniedAccesses]
+	}
+	m.state.Set("denials", accesses)
+	return nil
+}
+
+// collectDenialsPeriodically starts collecting the denials in the
+// background, at most once per denialsCollectInterval, so that reading the
+// audit log does not hold up the ensure loop.
+func (m *InterfaceManager) collectDenialsPeriodically() {
+	now := timeNow()
+	if now.Before(m.denialsNextCollect) {
+		return
+	}
+	if m.denialsCollected != nil {
+		select {
+		case <-m.denialsCollected:
+		default:
+			// still collecting
+			return
+		}
+	}
+	m.denialsNextCollect = now.Add(denialsCollectInterval)
+
+	collected := make(chan struct{})
+	m.denialsCollected = collected
+	go func() {
+		defer close(collected)
+		if err := m.CollectDenials(); err != nil {
+			logger.Noticef("cannot collect sandbox denials: %v", err)
+		}
+	}()
+}
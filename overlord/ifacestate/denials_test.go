@@ -0,0 +1,252 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/testutil"
+)
+
+const deniedSnapYaml = `
+name: denied
+version: 1
+apps:
+  app:
+hooks:
+  configure:
+`
+
+const deniedDevmodeSnapYaml = `
+name: denied-devmode
+version: 1
+confinement: devmode
+apps:
+  app:
+`
+
+func auditLogEntry(c *C, cursor string, t time.Time, msg string) string {
+	entry, err := json.Marshal(map[string]string{
+		"__CURSOR":             cursor,
+		"__REALTIME_TIMESTAMP": fmt.Sprintf("%d", t.UnixNano()/1000),
+		"MESSAGE":              msg,
+	})
+	c.Assert(err, IsNil)
+	return string(entry) + "\n"
+}
+
+func (s *interfaceManagerSuite) mockAuditLog(c *C, entries ...string) (cursors *[]string) {
+	var calls []string
+	restore := systemd.MockJournalctlAudit(func(cursor string, n int) (io.ReadCloser, error) {
+		c.Check(n, Equals, 1000)
+		calls = append(calls, cursor)
+		return ioutil.NopCloser(strings.NewReader(strings.Join(entries, ""))), nil
+	})
+	s.AddCleanup(restore)
+	return &calls
+}
+
+func (s *interfaceManagerSuite) TestCollectDenials(c *C) {
+	s.mockSnap(c, deniedSnapYaml)
+	s.mockSnap(c, deniedDevmodeSnapYaml)
+
+	t0 := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	cursors := s.mockAuditLog(c,
+		auditLogEntry(c, "c1", t0, `audit: type=1400 audit(1654084800.000:1): apparmor="DENIED" operation="open" profile="snap.denied.app" name="/dev/video0" pid=1 comm="app" requested_mask="r" denied_mask="r" fsuid=1000 ouid=0`),
+		auditLogEntry(c, "c2", t0.Add(time.Second), "usb 1-1: new high-speed USB device number 2 using xhci_hcd"),
+		auditLogEntry(c, "c3", t0.Add(2*time.Second), `audit: type=1400 audit(1654084802.000:2): apparmor="DENIED" operation="open" profile="snap.denied.app" name="/dev/video0" pid=1 comm="app" requested_mask="r" denied_mask="r" fsuid=1000 ouid=0`),
+		auditLogEntry(c, "c4", t0.Add(3*time.Second), `SECCOMP auid=1000 uid=1000 gid=1000 ses=2 subj=snap.denied.hook.configure pid=2 comm="configure" exe="/snap/denied/1/bin/configure" sig=0 arch=c000003e syscall=165 compat=0 ip=0x7f2d code=0x50000`),
+		// not installed
+		auditLogEntry(c, "c5", t0.Add(4*time.Second), `audit: type=1400 audit(1654084804.000:3): apparmor="DENIED" operation="open" profile="snap.other.app" name="/etc/shadow" pid=3 comm="app" requested_mask="r" denied_mask="r" fsuid=0 ouid=0`),
+		// no warning for devmode snaps
+		auditLogEntry(c, "c6", t0.Add(5*time.Second), `audit: type=1400 audit(1654084805.000:4): apparmor="DENIED" operation="open" profile="snap.denied-devmode.app" name="/etc/shadow" pid=4 comm="app" requested_mask="r" denied_mask="r" fsuid=0 ouid=0`),
+	)
+
+	mgr := s.manager(c)
+	c.Assert(mgr.CollectDenials(), IsNil)
+	c.Check(*cursors, DeepEquals, []string{""})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	var cursor string
+	c.Assert(s.state.Get("denials-cursor", &cursor), IsNil)
+	c.Check(cursor, Equals, "c6")
+
+	accesses, err := ifacestate.Denials(s.state, "")
+	c.Assert(err, IsNil)
+	c.Assert(accesses, HasLen, 3)
+	c.Check(accesses[0].Snap, Equals, "denied")
+	c.Check(accesses[0].App, Equals, "app")
+	c.Check(accesses[0].Revision, Equals, snap.R(1))
+	c.Check(accesses[0].Description, Equals, "open /dev/video0 (r)")
+	c.Check(accesses[0].Count, Equals, 2)
+	c.Check(accesses[0].FirstSeen.Equal(t0), Equals, true)
+	c.Check(accesses[0].LastSeen.Equal(t0.Add(2*time.Second)), Equals, true)
+	c.Check(accesses[0].SuggestedInterfaces, DeepEquals, []string{"camera"})
+	c.Check(accesses[1].Snap, Equals, "denied")
+	c.Check(accesses[1].Hook, Equals, "configure")
+	c.Check(accesses[1].Description, Equals, "syscall 165 (arch c000003e)")
+	c.Check(accesses[1].Denial.Sandbox, Equals, interfaces.SecuritySecComp)
+	c.Check(accesses[1].Count, Equals, 1)
+	c.Check(accesses[1].SuggestedInterfaces, HasLen, 0)
+	c.Check(accesses[2].Snap, Equals, "denied-devmode")
+
+	accesses, err = ifacestate.Denials(s.state, "denied-devmode")
+	c.Assert(err, IsNil)
+	c.Assert(accesses, HasLen, 1)
+	c.Check(accesses[0].Description, Equals, "open /etc/shadow (r)")
+
+	warns := s.state.AllWarnings()
+	c.Assert(warns, HasLen, 2)
+	c.Check(warns[0].String(), Equals, `app "app" of snap "denied" was denied "open /dev/video0 (r)" by apparmor; connecting one of the interfaces camera might allow it`)
+	c.Check(warns[1].String(), Equals, `hook "configure" of snap "denied" was denied "syscall 165 (arch c000003e)" by seccomp`)
+}
+
+func (s *interfaceManagerSuite) TestCollectDenialsResumesAfterCursor(c *C) {
+	s.mockSnap(c, deniedSnapYaml)
+
+	t0 := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	cursors := s.mockAuditLog(c,
+		auditLogEntry(c, "c1", t0, `apparmor="DENIED" operation="open" profile="snap.denied.app" name="/etc/shadow" pid=1 comm="app" requested_mask="r" denied_mask="r" fsuid=0 ouid=0`),
+	)
+
+	mgr := s.manager(c)
+	c.Assert(mgr.CollectDenials(), IsNil)
+	c.Assert(mgr.CollectDenials(), IsNil)
+	c.Check(*cursors, DeepEquals, []string{"", "c1"})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	// the mocked log returned the same entry again
+	accesses, err := ifacestate.Denials(s.state, "denied")
+	c.Assert(err, IsNil)
+	c.Assert(accesses, HasLen, 1)
+	c.Check(accesses[0].Count, Equals, 2)
+	// but the warning is only added once
+	c.Check(s.state.AllWarnings(), HasLen, 1)
+}
+
+func (s *interfaceManagerSuite) TestCollectDenialsReadError(c *C) {
+	restore := systemd.MockJournalctlAudit(func(cursor string, n int) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("{garbage")), nil
+	})
+	defer restore()
+
+	s.state.Lock()
+	s.state.Set("denials-cursor", "c1")
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+	c.Check(mgr.CollectDenials(), ErrorMatches, "cannot read the audit log: .*")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	// the collection starts over next time
+	var cursor string
+	c.Check(s.state.Get("denials-cursor", &cursor), testutil.ErrorIs, state.ErrNoState)
+}
+
+func (s *interfaceManagerSuite) TestCollectDenialsJournalctlError(c *C) {
+	restore := systemd.MockJournalctlAudit(func(cursor string, n int) (io.ReadCloser, error) {
+		return nil, errors.New("boom")
+	})
+	defer restore()
+
+	mgr := s.manager(c)
+	c.Check(mgr.CollectDenials(), ErrorMatches, "cannot read the audit log: boom")
+}
+
+func (s *interfaceManagerSuite) TestEnsureCollectsDenialsPeriodically(c *C) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	restore := ifacestate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+	restore = ifacestate.MockDenialsCollectInterval(time.Hour)
+	defer restore()
+	cursors := s.mockAuditLog(c)
+
+	mgr := s.manager(c)
+	// not right after starting
+	c.Assert(mgr.Ensure(), IsNil)
+	ifacestate.WaitDenialsCollected(mgr)
+	c.Check(*cursors, HasLen, 0)
+
+	now = now.Add(time.Hour)
+	c.Assert(mgr.Ensure(), IsNil)
+	ifacestate.WaitDenialsCollected(mgr)
+	c.Check(*cursors, HasLen, 1)
+
+	now = now.Add(time.Minute)
+	c.Assert(mgr.Ensure(), IsNil)
+	ifacestate.WaitDenialsCollected(mgr)
+	c.Check(*cursors, HasLen, 1)
+
+	now = now.Add(time.Hour)
+	c.Assert(mgr.Ensure(), IsNil)
+	ifacestate.WaitDenialsCollected(mgr)
+	c.Check(*cursors, HasLen, 2)
+}
+
+func (s *interfaceManagerSuite) TestEnsureDoesNotWaitForDenials(c *C) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	restore := ifacestate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+	restore = ifacestate.MockDenialsCollectInterval(time.Hour)
+	defer restore()
+	unblock := make(chan struct{})
+	calls := 0
+	restore = systemd.MockJournalctlAudit(func(cursor string, n int) (io.ReadCloser, error) {
+		calls++
+		<-unblock
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	})
+	defer restore()
+
+	mgr := s.manager(c)
+	now = now.Add(time.Hour)
+	// Ensure returns while journalctl is still running
+	c.Assert(mgr.Ensure(), IsNil)
+
+	// and does not start another collection meanwhile
+	now = now.Add(2 * time.Hour)
+	c.Assert(mgr.Ensure(), IsNil)
+
+	close(unblock)
+	ifacestate.WaitDenialsCollected(mgr)
+	c.Check(calls, Equals, 1)
+
+	// once done, collecting starts again
+	c.Assert(mgr.Ensure(), IsNil)
+	ifacestate.WaitDenialsCollected(mgr)
+	c.Check(calls, Equals, 2)
+}
@@ -64,6 +64,8 @@ var (
 	BatchConnectTasks                = batchConnectTasks
 	FirstTaskAfterBootWhenPreseeding = firstTaskAfterBootWhenPreseeding
 	BuildConfinementOptions          = buildConfinementOptions
+
+	ExpiryRetryTimeout = expiryRetryTimeout
 )
 
 type ConnectOpts = connectOpts
@@ -102,6 +104,12 @@ func MockContentLinkRetryTimeout(d time.Duration) (restore func()) {
 	return func() { contentLinkRetryTimeout = old }
 }
 
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() { timeNow = old }
+}
+
 func MockHotplugRetryTimeout(d time.Duration) (restore func()) {
 	old := hotplugRetryTimeout
 	hotplugRetryTimeout = d
@@ -193,3 +201,17 @@ func (m *InterfaceManager) TransitionConnectionsCoreMigration(st *state.State, o
 func (m *InterfaceManager) SetupSecurityByBackend(task *state.Task, snaps []*snap.Info, opts []interfaces.ConfinementOptions, tm timings.Measurer) error {
 	return m.setupSecurityByBackend(task, snaps, opts, tm)
 }
+
+// WaitDenialsCollected waits for the collection of denials started last by
+// Ensure, if any.
+func WaitDenialsCollected(m *InterfaceManager) {
+	if m.denialsCollected != nil {
+		<-m.denialsCollected
+	}
+}
+
+func MockDenialsCollectInterval(d time.Duration) (restore func()) {
+	old := denialsCollectInterval
+	denialsCollectInterval = d
+	return func() { denialsCollectInterval = old }
+}
@@ -506,6 +506,10 @@ func (m *InterfaceManager) doConnect(task *state.Task, _ *tomb.Tomb) (err error)
 	if err := task.Get("delayed-setup-profiles", &delayedSetupProfiles); err != nil && !errors.Is(err, state.ErrNoState) {
 		return err
 	}
+	var expiry time.Time
+	if err := task.Get("expiry", &expiry); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
 
 	deviceCtx, err := snapstate.DeviceCtx(st, task, nil)
 	if err != nil {
@@ -619,7 +623,7 @@ func (m *InterfaceManager) doConnect(task *state.Task, _ *tomb.Tomb) (err error)
 		task.Set("old-conn", old)
 	}
 
-	conns[connRef.ID()] = &schema.ConnState{
+	cstate := &schema.ConnState{
 		Interface:        conn.Interface(),
 		StaticPlugAttrs:  conn.Plug.StaticAttrs(),
 		DynamicPlugAttrs: conn.Plug.DynamicAttrs(),
@@ -629,6 +633,13 @@ func (m *InterfaceManager) doConnect(task *state.Task, _ *tomb.Tomb) (err error)
 		ByGadget:         byGadget,
 		HotplugKey:       slot.HotplugKey,
 	}
+	if !expiry.IsZero() {
+		cstate.Expiry = &expiry
+		// make sure the connection is disconnected on time
+		m.expiryNextCheck = time.Time{}
+		st.EnsureBefore(expiry.Sub(timeNow()))
+	}
+	conns[connRef.ID()] = cstate
 	setConns(st, conns)
 
 	// the dynamic attributes might have been updated by the interface's BeforeConnectPlug/Slot code,
@@ -862,7 +862,9 @@ func newConnectChecker(s *state.State, deviceCtx snapstate.DeviceContext) (*conn
 	}, nil
 }
 
-func (c *connectChecker) check(plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) (bool, error) {
+// candidate builds the policy.ConnectCandidate for checking the
+// connection of the given plug and slot.
+func (c *connectChecker) candidate(plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) (*policy.ConnectCandidate, error) {
 	modelAs := c.deviceCtx.Model()
 
 	var storeAs *asserts.Store
@@ -870,7 +872,7 @@ func (c *connectChecker) check(plug *interfaces.ConnectedPlug, slot *interfaces.
 		var err error
 		storeAs, err = assertstate.Store(c.st, modelAs.Store())
 		if err != nil && !asserts.IsNotFound(err) {
-			return false, err
+			return nil, err
 		}
 	}
 
@@ -879,7 +881,7 @@ func (c *connectChecker) check(plug *interfaces.ConnectedPlug, slot *interfaces.
 		var err error
 		plugDecl, err = assertstate.SnapDeclaration(c.st, plug.Snap().SnapID)
 		if err != nil {
-			return false, fmt.Errorf("cannot find snap declaration for %q: %v", plug.Snap().InstanceName(), err)
+			return nil, fmt.Errorf("cannot find snap declaration for %q: %v", plug.Snap().InstanceName(), err)
 		}
 	}
 
@@ -888,12 +890,11 @@ func (c *connectChecker) check(plug *interfaces.ConnectedPlug, slot *interfaces.
 		var err error
 		slotDecl, err = assertstate.SnapDeclaration(c.st, slot.Snap().SnapID)
 		if err != nil {
-			return false, fmt.Errorf("cannot find snap declaration for %q: %v", slot.Snap().InstanceName(), err)
+			return nil, fmt.Errorf("cannot find snap declaration for %q: %v", slot.Snap().InstanceName(), err)
 		}
 	}
 
-	// check the connection against the declarations' rules
-	ic := policy.ConnectCandidate{
+	return &policy.ConnectCandidate{
 		Plug:                plug,
 		PlugSnapDeclaration: plugDecl,
 		Slot:                slot,
@@ -901,12 +902,19 @@ func (c *connectChecker) check(plug *interfaces.ConnectedPlug, slot *interfaces.
 		BaseDeclaration:     c.baseDecl,
 		Model:               modelAs,
 		Store:               storeAs,
+	}, nil
+}
+
+func (c *connectChecker) check(plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) (bool, error) {
+	ic, err := c.candidate(plug, slot)
+	if err != nil {
+		return false, err
 	}
 
 	// if either of plug or slot snaps don't have a declaration it
 	// means they were installed with "dangerous", so the security
 	// check should be skipped at this point.
-	if plugDecl != nil && slotDecl != nil {
+	if ic.PlugSnapDeclaration != nil && ic.SlotSnapDeclaration != nil {
 		if err := ic.Check(); err != nil {
 			return false, err
 		}
@@ -23,6 +23,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"unicode"
 
@@ -166,6 +167,12 @@ InterfacesLoop:
 		}
 
 		proposedSlot, err := hotplugHandler.HotplugDeviceDetected(devinfo)
+		if err == nil && proposedSlot == nil {
+			// the slot may be instantiated from a template declared by the gadget instead
+			if templateHandler, ok := iface.(hotplug.TemplateDefiner); ok {
+				proposedSlot, err = slotFromGadgetTemplates(templateHandler, devinfo, gadgetSlotsByInterface[iface.Name()])
+			}
+		}
 		if err != nil {
 			logger.Noticef("cannot process hotplug event by the rule of interface %q: %s", iface.Name(), err)
 			continue
@@ -239,6 +246,25 @@ InterfacesLoop:
 	}
 }
 
+// slotFromGadgetTemplates proposes a slot for the device from the first of the
+// given gadget slots, in the order of their names, acting as a template that
+// matches the device.
+func slotFromGadgetTemplates(templateHandler hotplug.TemplateDefiner, devinfo *hotplug.HotplugDeviceInfo, gadgetSlots []*snap.SlotInfo) (*hotplug.ProposedSlot, error) {
+	sort.Slice(gadgetSlots, func(i, j int) bool {
+		return gadgetSlots[i].Name < gadgetSlots[j].Name
+	})
+	for _, template := range gadgetSlots {
+		proposedSlot, err := templateHandler.HotplugSlotFromTemplate(devinfo, template)
+		if err != nil {
+			return nil, fmt.Errorf("cannot instantiate gadget slot %q: %v", template.Name, err)
+		}
+		if proposedSlot != nil {
+			return proposedSlot, nil
+		}
+	}
+	return nil, nil
+}
+
 // hotplugDeviceRemoved gets called when a device is removed from the system.
 func (m *InterfaceManager) hotplugDeviceRemoved(devinfo *hotplug.HotplugDeviceInfo) {
 	st := m.state
@@ -384,6 +384,98 @@ slots:
 	c.Assert(slot, IsNil)
 }
 
+func (s *hotplugSuite) TestHotplugAddAndRemoveFromGadgetTemplate(c *C) {
+	var templates []string
+	templateIface := &ifacetest.TestHotplugInterface{
+		TestInterface: ifacetest.TestInterface{
+			InterfaceName: "test-e",
+		},
+		HotplugKeyCallback: func(deviceInfo *hotplug.HotplugDeviceInfo) (snap.HotplugKey, error) {
+			return "key-5", nil
+		},
+		HotplugSlotFromTemplateCallback: func(deviceInfo *hotplug.HotplugDeviceInfo, template *snap.SlotInfo) (*hotplug.ProposedSlot, error) {
+			templates = append(templates, template.Name)
+			var subsystem string
+			if err := template.Attr("subsystem", &subsystem); err != nil || subsystem != deviceInfo.Subsystem() {
+				return nil, nil
+			}
+			return &hotplug.ProposedSlot{
+				Name:  template.Name,
+				Label: template.Label,
+				Attrs: map[string]interface{}{
+					"path": deviceInfo.DeviceName(),
+				}}, nil
+		},
+	}
+	c.Assert(s.mgr.Repository().AddInterface(templateIface), IsNil)
+	s.AddCleanup(builtin.MockInterface(templateIface))
+
+	s.MockModel(c, map[string]interface{}{
+		"gadget": "the-gadget",
+	})
+
+	st := s.state
+	st.Lock()
+	gadgetSideInfo := &snap.SideInfo{RealName: "the-gadget", SnapID: "the-gadget-id", Revision: snap.R(1)}
+	gadgetInfo := snaptest.MockSnap(c, `
+name: the-gadget
+type: gadget
+version: 1.0
+
+slots:
+  tmpl-c:
+    interface: test-e
+    subsystem: foo
+  tmpl-b:
+    interface: test-e
+    subsystem: foo
+    label: Foo device
+  tmpl-a:
+    interface: test-e
+    subsystem: bar
+`, gadgetSideInfo)
+	snapstate.Set(s.state, "the-gadget", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{&gadgetInfo.SideInfo},
+		Current:  snap.R(1),
+		SnapType: "gadget"})
+	st.Unlock()
+
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{
+		"DEVNAME":   "/dev/foo0",
+		"DEVPATH":   "a/path",
+		"ACTION":    "add",
+		"SUBSYSTEM": "foo"})
+	c.Assert(err, IsNil)
+	s.udevMon.AddDevice(di)
+
+	c.Assert(s.o.Settle(5*time.Second), IsNil)
+
+	// the templates are tried in the order of their names
+	c.Check(templates, DeepEquals, []string{"tmpl-a", "tmpl-b"})
+
+	// the slot has been instantiated from the first matching template
+	repo := s.mgr.Repository()
+	slot, err := repo.SlotForHotplugKey("test-e", "key-5")
+	c.Assert(err, IsNil)
+	c.Assert(slot, NotNil)
+	c.Check(slot.Snap.InstanceName(), Equals, "core")
+	c.Check(slot.Name, Equals, "tmpl-b")
+	c.Check(slot.Label, Equals, "Foo device")
+	c.Check(slot.Attrs, DeepEquals, map[string]interface{}{"path": "/dev/foo0"})
+
+	// and it is removed with the device
+	di, err = hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "a/path", "ACTION": "remove", "SUBSYSTEM": "foo"})
+	c.Assert(err, IsNil)
+	s.udevMon.RemoveDevice(di)
+
+	c.Assert(s.o.Settle(5*time.Second), IsNil)
+
+	slot, err = repo.SlotForHotplugKey("test-e", "key-5")
+	c.Assert(err, IsNil)
+	c.Check(slot, IsNil)
+}
+
 func (s *hotplugSuite) TestHotplugAddWithDefaultKey(c *C) {
 	s.MockModel(c, nil)
 
@@ -21,11 +21,14 @@ package ifacestate
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/backends"
+	"github.com/snapcore/snapd/interfaces/denials"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate/ifacerepo"
@@ -37,6 +40,8 @@ import (
 	"github.com/snapcore/snapd/timings"
 )
 
+var timeNow = time.Now
+
 type deviceData struct {
 	ifaceName  string
 	hotplugKey snap.HotplugKey
@@ -63,6 +68,17 @@ type InterfaceManager struct {
 	extraInterfaces []interfaces.Interface
 	extraBackends   []interfaces.SecurityBackend
 
+	// expiryNextCheck is when expired connections are looked for
+	// again, protected by the state lock
+	expiryNextCheck time.Time
+
+	denialsMu          sync.Mutex
+	denialsSuggester   *denials.Suggester
+	denialsNextCollect time.Time
+	// denialsCollected is closed once the collection of denials started
+	// last by Ensure is done, only accessed from Ensure and Stop
+	denialsCollected chan struct{}
+
 	preseed bool
 }
 
@@ -86,7 +102,9 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, runner *state.T
 		// extras
 		extraInterfaces: extraInterfaces,
 		extraBackends:   extraBackends,
-		preseed:         snapdenv.Preseeding(),
+		// do not look at the audit log right away while snapd starts
+		denialsNextCollect: timeNow().Add(denialsCollectInterval),
+		preseed:            snapdenv.Preseeding(),
 	}
 
 	taskKinds := map[string]bool{}
@@ -191,11 +209,18 @@ Run "systemctl enable --now snapd.apparmor" to correct this.`)
 
 // Ensure implements StateManager.Ensure.
 func (m *InterfaceManager) Ensure() error {
-	// do not worry about udev monitor in preseeding mode
+	// do not worry about udev monitor, expired connections nor denials
+	// in preseeding mode
 	if m.preseed {
 		return nil
 	}
 
+	if err := m.disconnectExpiredConnections(); err != nil {
+		logger.Noticef("cannot disconnect expired connections: %v", err)
+	}
+
+	m.collectDenialsPeriodically()
+
 	if m.udevMonitorDisabled {
 		return nil
 	}
@@ -224,9 +249,85 @@ func (m *InterfaceManager) Ensure() error {
 	return nil
 }
 
-// Stop implements StateStopper. It stops the udev monitor,
-// if running.
+// disconnectExpiredConnections starts changes disconnecting the
+// connections whose expiry has passed, and makes sure that the manager is
+// ensured again when the next connection expires. Connections are only
+// looked at again once the next one expires, or after expiryRetryTimeout
+// when one could not be disconnected because of a conflicting change.
+func (m *InterfaceManager) disconnectExpiredConnections() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	now := timeNow()
+	if now.Before(m.expiryNextCheck) {
+		return nil
+	}
+
+	conns, err := getConns(m.state)
+	if err != nil {
+		return err
+	}
+	connIDs := make([]string, 0, len(conns))
+	for connID := range conns {
+		connIDs = append(connIDs, connID)
+	}
+	sort.Strings(connIDs)
+
+	var nextExpiry time.Time
+	for _, connID := range connIDs {
+		cstate := conns[connID]
+		if cstate.Expiry == nil || cstate.Undesired || cstate.HotplugGone {
+			continue
+		}
+		if cstate.Expiry.After(now) {
+			if nextExpiry.IsZero() || cstate.Expiry.Before(nextExpiry) {
+				nextExpiry = *cstate.Expiry
+			}
+			continue
+		}
+
+		connRef, err := interfaces.ParseConnRef(connID)
+		if err != nil {
+			return err
+		}
+		conn, err := m.repo.Connection(connRef)
+		if err != nil {
+			// the plug or the slot is not present in the current
+			// revision of its snap
+			continue
+		}
+		ts, err := Disconnect(m.state, conn)
+		if err != nil {
+			// most likely a conflicting change, try again once it
+			// is done
+			logger.Debugf("cannot disconnect expired connection %s: %v", connRef, err)
+			retry := now.Add(expiryRetryTimeout)
+			if nextExpiry.IsZero() || retry.Before(nextExpiry) {
+				nextExpiry = retry
+			}
+			continue
+		}
+		summary := fmt.Sprintf(i18n.G("Disconnect %s:%s from %s:%s after the connection expired"),
+			connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
+		chg := m.state.NewChange("disconnect-snap", summary)
+		chg.Set("snap-names", []string{connRef.PlugRef.Snap, connRef.SlotRef.Snap})
+		chg.AddAll(ts)
+		m.state.EnsureBefore(0)
+	}
+	m.expiryNextCheck = nextExpiry
+	if !nextExpiry.IsZero() {
+		m.state.EnsureBefore(nextExpiry.Sub(now))
+	}
+	return nil
+}
+
+// Stop implements StateStopper. It waits for the collection of
+// denials and stops the udev monitor, if running.
 func (m *InterfaceManager) Stop() {
+	if m.denialsCollected != nil {
+		<-m.denialsCollected
+	}
+
 	m.udevMonMu.Lock()
 	udevMon := m.udevMon
 	m.udevMonMu.Unlock()
@@ -268,6 +369,9 @@ type ConnectionState struct {
 	StaticSlotAttrs  map[string]interface{}
 	DynamicSlotAttrs map[string]interface{}
 	HotplugGone      bool
+	// Expiry is the time after which the connection is automatically
+	// disconnected, it is zero for permanent connections.
+	Expiry time.Time
 }
 
 // Active returns true if connection is not undesired and not removed by
@@ -300,6 +404,11 @@ func ConnectionStates(st *state.State) (connStateByRef map[string]ConnectionStat
 			DynamicSlotAttrs: cstate.DynamicSlotAttrs,
 			HotplugGone:      cstate.HotplugGone,
 		}
+		if cstate.Expiry != nil {
+			connState := connStateByRef[cref]
+			connState.Expiry = *cstate.Expiry
+			connStateByRef[cref] = connState
+		}
 	}
 	return connStateByRef, nil
 }
@@ -449,6 +558,7 @@ func (m *InterfaceManager) DisableUDevMonitor() {
 
 var (
 	udevInitRetryTimeout = time.Minute * 5
+	expiryRetryTimeout   = time.Minute
 	createUDevMonitor    = udevmonitor.New
 )
 
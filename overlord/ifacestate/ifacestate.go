@@ -85,6 +85,10 @@ type connectOpts struct {
 	AutoConnect bool
 
 	DelayedSetupProfiles bool
+
+	// Expiry is the time after which the connection is automatically
+	// disconnected, if not zero.
+	Expiry time.Time
 }
 
 // Connect returns a set of tasks for connecting an interface.
@@ -97,6 +101,16 @@ func Connect(st *state.State, plugSnap, plugName, slotSnap, slotName string) (*s
 	return connect(st, plugSnap, plugName, slotSnap, slotName, connectOpts{})
 }
 
+// ConnectUntil returns a set of tasks for connecting an interface until the
+// given time, after which the interface manager disconnects it.
+func ConnectUntil(st *state.State, plugSnap, plugName, slotSnap, slotName string, expiry time.Time) (*state.TaskSet, error) {
+	if err := snapstate.CheckChangeConflictMany(st, []string{plugSnap, slotSnap}, ""); err != nil {
+		return nil, err
+	}
+
+	return connect(st, plugSnap, plugName, slotSnap, slotName, connectOpts{Expiry: expiry})
+}
+
 func connect(st *state.State, plugSnap, plugName, slotSnap, slotName string, flags connectOpts) (*state.TaskSet, error) {
 	// TODO: Store the intent-to-connect in the state so that we automatically
 	// try to reconnect on reboot (reconnection can fail or can connect with
@@ -209,6 +223,9 @@ func connect(st *state.State, plugSnap, plugName, slotSnap, slotName string, fla
 	if flags.DelayedSetupProfiles {
 		connectInterface.Set("delayed-setup-profiles", true)
 	}
+	if !flags.Expiry.IsZero() {
+		connectInterface.Set("expiry", flags.Expiry)
+	}
 
 	// Expose a copy of all plug and slot attributes coming from yaml to interface hooks. The hooks will be able
 	// to modify them but all attributes will be checked against assertions after the hooks are run.
@@ -523,6 +540,46 @@ func CheckInterfaces(st *state.State, snapInfo *snap.Info, deviceCtx snapstate.D
 	return ic.Check()
 }
 
+// ConnectionPolicyExplanation describes how the policies for connecting a
+// plug to a slot were evaluated.
+type ConnectionPolicyExplanation struct {
+	// Connection describes the evaluation of the connection rules,
+	// obeyed by manual connections and connections by the gadget.
+	Connection *policy.ConnectionExplanation
+	// AutoConnection describes the evaluation of the auto-connection
+	// rules.
+	AutoConnection *policy.ConnectionExplanation
+	// Unasserted lists the snaps without a snap-declaration, manual
+	// connections involving them are not checked against the policy.
+	Unasserted []string
+}
+
+// ExplainConnectionPolicy evaluates the connection and auto-connection
+// policies for the given plug and slot and describes how the rules of the
+// declarations were evaluated.
+func ExplainConnectionPolicy(st *state.State, plug *snap.PlugInfo, slot *snap.SlotInfo, deviceCtx snapstate.DeviceContext) (*ConnectionPolicyExplanation, error) {
+	checker, err := newConnectChecker(st, deviceCtx)
+	if err != nil {
+		return nil, err
+	}
+	ic, err := checker.candidate(interfaces.NewConnectedPlug(plug, nil, nil), interfaces.NewConnectedSlot(slot, nil, nil))
+	if err != nil {
+		return nil, err
+	}
+
+	expl := &ConnectionPolicyExplanation{
+		Connection:     ic.Explain(false),
+		AutoConnection: ic.Explain(true),
+	}
+	if ic.PlugSnapDeclaration == nil {
+		expl.Unasserted = append(expl.Unasserted, plug.Snap.InstanceName())
+	}
+	if ic.SlotSnapDeclaration == nil && slot.Snap.InstanceName() != plug.Snap.InstanceName() {
+		expl.Unasserted = append(expl.Unasserted, slot.Snap.InstanceName())
+	}
+	return expl, nil
+}
+
 var once sync.Once
 
 func delayedCrossMgrInit() {
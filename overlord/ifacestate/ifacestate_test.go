@@ -26,6 +26,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -39,6 +40,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/interfaces/policy"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
@@ -4205,6 +4207,169 @@ func (s *interfaceManagerSuite) TestConnectSetsUpSecurity(c *C) {
 	c.Check(s.secBackend.SetupCalls[1].Options, DeepEquals, interfaces.ConfinementOptions{})
 }
 
+func (s *interfaceManagerSuite) TestConnectUntilTracksExpiryInState(c *C) {
+	s.MockModel(c, nil)
+
+	s.mockIfaces(&ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	_ = s.manager(c)
+
+	s.state.Lock()
+
+	expiry := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	ts, err := ifacestate.ConnectUntil(s.state, "consumer", "plug", "producer", "slot", expiry)
+	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 5)
+	var taskExpiry time.Time
+	c.Assert(ts.Tasks()[2].Get("expiry", &taskExpiry), IsNil)
+	c.Check(taskExpiry.Equal(expiry), Equals, true)
+
+	change := s.state.NewChange("connect", "")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(change.Err(), IsNil)
+	c.Check(change.Status(), Equals, state.DoneStatus)
+	var conns map[string]interface{}
+	err = s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface":   "test",
+			"plug-static": map[string]interface{}{"attr1": "value1"},
+			"slot-static": map[string]interface{}{"attr2": "value2"},
+			"expiry":      expiry.Format(time.RFC3339),
+		},
+	})
+
+	connStates, err := ifacestate.ConnectionStates(s.state)
+	c.Assert(err, IsNil)
+	c.Check(connStates["consumer:plug producer:slot"].Expiry.Equal(expiry), Equals, true)
+	// the connection has not expired yet
+	c.Check(s.state.Changes(), HasLen, 1)
+}
+
+func (s *interfaceManagerSuite) TestEnsureDisconnectsExpiredConnections(c *C) {
+	s.MockModel(c, nil)
+
+	s.mockIfaces(&ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	s.mockSnap(c, consumer2Yaml)
+
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	restore := ifacestate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test",
+			"expiry":    now.Add(-time.Minute).Format(time.RFC3339),
+		},
+		"consumer2:plug producer:slot": map[string]interface{}{
+			"interface": "test",
+			"expiry":    now.Add(time.Hour).Format(time.RFC3339),
+		},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+	c.Assert(mgr.Ensure(), IsNil)
+
+	s.state.Lock()
+	changes := s.state.Changes()
+	c.Assert(changes, HasLen, 1)
+	c.Check(changes[0].Kind(), Equals, "disconnect-snap")
+	c.Check(changes[0].Summary(), Equals, "Disconnect consumer:plug from producer:slot after the connection expired")
+	var snapNames []string
+	c.Assert(changes[0].Get("snap-names", &snapNames), IsNil)
+	c.Check(snapNames, DeepEquals, []string{"consumer", "producer"})
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(changes[0].Err(), IsNil)
+	c.Check(changes[0].Status(), Equals, state.DoneStatus)
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, HasLen, 1)
+	c.Check(conns["consumer2:plug producer:slot"], NotNil)
+	c.Check(s.state.Changes(), HasLen, 1)
+	c.Assert(mgr.Repository().Interfaces().Connections, HasLen, 1)
+}
+
+func (s *interfaceManagerSuite) TestEnsureRetriesExpiredConnectionsAfterConflict(c *C) {
+	s.MockModel(c, nil)
+
+	s.mockIfaces(&ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	restore := ifacestate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test",
+			"expiry":    now.Add(-time.Minute).Format(time.RFC3339),
+		},
+	})
+	// a change in progress conflicts with the disconnection
+	chg := s.state.NewChange("other-chg", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: "consumer"},
+	})
+	chg.AddTask(t)
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+	c.Assert(mgr.Ensure(), IsNil)
+	// the conflict is not worth a notice
+	c.Check(logbuf.String(), Equals, "")
+
+	s.state.Lock()
+	c.Check(s.state.Changes(), HasLen, 1)
+	chg.SetStatus(state.DoneStatus)
+	s.state.Unlock()
+
+	// the connection is not looked at again right away
+	now = now.Add(ifacestate.ExpiryRetryTimeout - time.Second)
+	c.Assert(mgr.Ensure(), IsNil)
+	s.state.Lock()
+	c.Check(s.state.Changes(), HasLen, 1)
+	s.state.Unlock()
+
+	now = now.Add(time.Second)
+	c.Assert(mgr.Ensure(), IsNil)
+	s.state.Lock()
+	defer s.state.Unlock()
+	changes := s.state.Changes()
+	c.Assert(changes, HasLen, 2)
+	var kinds []string
+	for _, chg := range changes {
+		kinds = append(kinds, chg.Kind())
+	}
+	sort.Strings(kinds)
+	c.Check(kinds, DeepEquals, []string{"disconnect-snap", "other-chg"})
+}
+
 func (s *interfaceManagerSuite) TestConnectSetsHotplugKeyFromTheSlot(c *C) {
 	s.MockModel(c, nil)
 
@@ -5021,6 +5186,111 @@ func (s *interfaceManagerSuite) TestCheckInterfacesConsidersImplicitSlots(c *C)
 	c.Check(snapInfo.Slots["home"], NotNil)
 }
 
+func (s *interfaceManagerSuite) TestExplainConnectionPolicy(c *C) {
+	deviceCtx := s.TrivialDeviceContext(c, nil)
+
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    allow-connection:
+      plug-publisher-id:
+        - $SLOT_PUBLISHER_ID
+    deny-auto-connection: true
+`))
+	defer restore()
+	s.mockIface(&ifacetest.TestInterface{InterfaceName: "test"})
+
+	s.MockSnapDecl(c, "consumer", "consumer-publisher", nil)
+	consumer := s.mockSnap(c, consumerYaml)
+	s.MockSnapDecl(c, "producer", "producer-publisher", nil)
+	producer := s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	expl, err := ifacestate.ExplainConnectionPolicy(s.state, consumer.Plugs["plug"], producer.Slots["slot"], deviceCtx)
+	c.Assert(err, IsNil)
+	c.Check(expl.Unasserted, HasLen, 0)
+
+	// the connection is decided by the base declaration
+	c.Check(expl.Connection.Err, ErrorMatches, `connection not allowed by slot rule of interface "test"`)
+	c.Assert(expl.Connection.Rules, HasLen, 4)
+	for _, re := range expl.Connection.Rules[:3] {
+		c.Check(re.Found, Equals, false)
+	}
+	re := expl.Connection.Rules[3]
+	c.Check(re.Declaration, Equals, "base-declaration")
+	c.Check(re.Side, Equals, "slot")
+	c.Check(re.Verdict, Equals, policy.VerdictNotAllowed)
+	c.Assert(re.Allow, HasLen, 1)
+	c.Assert(re.Allow[0].Constraints, HasLen, 1)
+	c.Check(re.Allow[0].Constraints[0].Name, Equals, "plug-publisher-id")
+
+	c.Check(expl.AutoConnection.Err, ErrorMatches, `auto-connection denied by slot rule of interface "test"`)
+	c.Assert(expl.AutoConnection.Rules, HasLen, 4)
+	re = expl.AutoConnection.Rules[3]
+	c.Check(re.Verdict, Equals, policy.VerdictDenied)
+	c.Assert(re.Deny, HasLen, 1)
+	c.Check(re.Deny[0].Matched, Equals, true)
+}
+
+func (s *interfaceManagerSuite) TestExplainConnectionPolicyStoreOverride(c *C) {
+	deviceCtx := s.TrivialDeviceContext(c, nil)
+
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    deny-auto-connection: true
+`))
+	defer restore()
+	s.mockIface(&ifacetest.TestInterface{InterfaceName: "test"})
+
+	s.MockSnapDecl(c, "consumer", "consumer-publisher", nil)
+	consumer := s.mockSnap(c, consumerYaml)
+	s.MockSnapDecl(c, "producer", "producer-publisher", map[string]interface{}{
+		"format": "1",
+		"slots": map[string]interface{}{
+			"test": map[string]interface{}{
+				"allow-auto-connection": "true",
+			},
+		},
+	})
+	producer := s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	expl, err := ifacestate.ExplainConnectionPolicy(s.state, consumer.Plugs["plug"], producer.Slots["slot"], deviceCtx)
+	c.Assert(err, IsNil)
+
+	c.Check(expl.AutoConnection.Err, IsNil)
+	c.Assert(expl.AutoConnection.Rules, HasLen, 2)
+	re := expl.AutoConnection.Rules[1]
+	c.Check(re.Declaration, Equals, "snap-declaration")
+	c.Check(re.SnapName, Equals, "producer")
+	c.Check(re.Verdict, Equals, policy.VerdictAllowed)
+}
+
+func (s *interfaceManagerSuite) TestExplainConnectionPolicyUnasserted(c *C) {
+	deviceCtx := s.TrivialDeviceContext(c, nil)
+	s.mockIface(&ifacetest.TestInterface{InterfaceName: "test"})
+
+	consumer := s.mockSnap(c, consumerYaml)
+	s.MockSnapDecl(c, "producer", "producer-publisher", nil)
+	producer := s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	expl, err := ifacestate.ExplainConnectionPolicy(s.state, consumer.Plugs["plug"], producer.Slots["slot"], deviceCtx)
+	c.Assert(err, IsNil)
+	c.Check(expl.Unasserted, DeepEquals, []string{"consumer"})
+	c.Check(expl.Connection.Err, IsNil)
+}
+
 // Test that setup-snap-security gets undone correctly when a snap is installed
 // but the installation fails (the security profiles are removed).
 func (s *interfaceManagerSuite) TestUndoSetupProfilesOnInstall(c *C) {
@@ -23,10 +23,12 @@ package overlord
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -34,11 +36,14 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/changearchive"
 	"github.com/snapcore/snapd/overlord/cmdstate"
 	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/configstate/proxyconf"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/healthstate"
@@ -54,6 +59,7 @@ import (
 	"github.com/snapcore/snapd/overlord/storecontext"
 	"github.com/snapcore/snapd/snapdenv"
 	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/timings"
 )
@@ -83,6 +89,8 @@ var pruneTickerC = func(t *time.Ticker) <-chan time.Time {
 // track of all available state managers and related helpers.
 type Overlord struct {
 	stateFLock *osutil.FileLock
+	// stateJournal is set when the state changes are journaled
+	stateJournal *state.Journal
 
 	stateEng *StateEngine
 	// ensure loop
@@ -120,15 +128,23 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 		inited: true,
 	}
 
-	backend := &overlordStateBackend{
+	var backend state.Backend = &overlordStateBackend{
 		path:         dirs.SnapStateFile,
 		ensureBefore: o.ensureBefore,
 	}
+	if features.StateJournal.IsEnabled() {
+		jsb := newJournalStateBackend(dirs.SnapStateFile, o.ensureBefore)
+		o.stateJournal = jsb.journal
+		backend = jsb
+	}
 	s, err := o.loadState(backend, restartHandler)
 	if err != nil {
 		return nil, err
 	}
 
+	// keep the history of the pruned changes
+	s.OnPrune(archivePrunedChange)
+
 	o.stateEng = NewStateEngine(s)
 	o.runner = state.NewTaskRunner(s)
 
@@ -178,6 +194,8 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 		return nil, err
 	}
 	healthstate.Init(hookMgr)
+	o.addManager(healthstate.Manager(s))
+	o.addManager(store.NewPeerServer(dirs.SnapDownloadCacheDir, o.peerSettings))
 
 	// the shared task runner should be added last!
 	o.stateEng.AddManager(o.runner)
@@ -287,15 +305,9 @@ func (o *Overlord) loadState(backend state.Backend, restartHandler restart.Handl
 		return s, nil
 	}
 
-	r, err := os.Open(dirs.SnapStateFile)
-	if err != nil {
-		return nil, fmt.Errorf("cannot read the state file: %s", err)
-	}
-	defer r.Close()
-
 	var s *state.State
 	timings.Run(perfTimings, "read-state", "read snapd state from disk", func(tm timings.Measurer) {
-		s, err = state.ReadState(backend, r)
+		s, err = state.ReadStateFile(backend, dirs.SnapStateFile)
 	})
 	if err != nil {
 		return nil, err
@@ -323,16 +335,92 @@ func initRestart(s *state.State, curBootID string, restartHandler restart.Handle
 	return restart.Init(s, curBootID, restartHandler)
 }
 
+// storeDir returns the directory to serve snaps and assertions from instead
+// of the store, set through $SNAPD_STORE_DIR or the store.directory core
+// option, if any.
+func storeDir(st *state.State) string {
+	if dir := os.Getenv("SNAPD_STORE_DIR"); dir != "" {
+		return dir
+	}
+	var dir string
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "store.directory", &dir); err != nil && !config.IsNoOption(err) {
+		logger.Noticef("cannot get store.directory: %v", err)
+	}
+	return dir
+}
+
 func (o *Overlord) newStoreWithContext(storeCtx store.DeviceAndAuthContext) snapstate.StoreService {
+	if dir := storeDir(o.State()); dir != "" {
+		logger.Noticef("Serving snaps and assertions from %q instead of the store", dir)
+		return store.NewDirStore(dir)
+	}
 	cfg := store.DefaultConfig()
 	cfg.Proxy = o.proxyConf
+	cfg.Peers = o.peerSettings
 	sto := storeNew(cfg, storeCtx)
 	sto.SetCacheDownloads(defaultCachedDownloads)
 	return sto
 }
 
+// peerSettings returns the settings of the sharing of downloaded snaps with
+// the peers of the LAN, set through the store.peers.* core options.
+func (o *Overlord) peerSettings() (*store.PeerSettings, error) {
+	st := o.State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	st.Unlock()
+
+	get := func(key string) (string, error) {
+		var v interface{}
+		if err := tr.Get("core", "store.peers."+key, &v); err != nil && !config.IsNoOption(err) {
+			return "", err
+		}
+		if v == nil {
+			return "", nil
+		}
+		return fmt.Sprintf("%v", v), nil
+	}
+	settings := &store.PeerSettings{Port: store.DefaultPeerPort}
+	port, err := get("port")
+	if err != nil {
+		return nil, err
+	}
+	if port != "" {
+		if settings.Port, err = strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("invalid store.peers.port: %v", err)
+		}
+	}
+	for _, opt := range []struct {
+		key   string
+		value *bool
+	}{
+		{"discover", &settings.Discover},
+		{"serve", &settings.Serve},
+	} {
+		v, err := get(opt.key)
+		if err != nil {
+			return nil, err
+		}
+		*opt.value = v == "true"
+	}
+	peers, err := get("list")
+	if err != nil {
+		return nil, err
+	}
+	for _, peer := range strutil.CommaSeparatedList(peers) {
+		if _, _, err := net.SplitHostPort(peer); err != nil {
+			// the peers use the same port by default
+			peer = net.JoinHostPort(peer, strconv.Itoa(settings.Port))
+		}
+		settings.Peers = append(settings.Peers, peer)
+	}
+	return settings, nil
+}
+
 // newStore can make new stores for use during remodeling.
 // The device backend will tie them to the remodeling device state.
+// The state must be locked by the caller.
 func (o *Overlord) newStore(devBE storecontext.DeviceBackend) snapstate.StoreService {
 	scb := o.deviceMgr.StoreContextBackend()
 	stoCtx := storecontext.NewComposed(o.State(), devBE, scb, scb)
@@ -481,6 +569,12 @@ func (o *Overlord) Loop() {
 	})
 }
 
+func archivePrunedChange(chg *state.Change) {
+	if err := changearchive.Add(chg); err != nil {
+		logger.Noticef("Cannot archive change %s: %v", chg.ID(), err)
+	}
+}
+
 func (o *Overlord) ensureDidRun() {
 	atomic.StoreInt32(&o.ensureRun, 1)
 }
@@ -498,6 +592,13 @@ func (o *Overlord) Stop() error {
 		err = o.loopTomb.Wait()
 	}
 	o.stateEng.Stop()
+	if o.stateJournal != nil {
+		// leave the state file up to date for those reading it
+		// directly
+		if jerr := o.stateJournal.Close(); jerr != nil {
+			logger.Noticef("Cannot close the state journal: %v", jerr)
+		}
+	}
 	if o.stateFLock != nil {
 		// This will also unlock the file
 		o.stateFLock.Close()
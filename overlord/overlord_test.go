@@ -38,9 +38,12 @@ import (
 
 	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/changearchive"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
@@ -157,11 +160,78 @@ func (ovs *overlordSuite) TestNewStore(c *C) {
 
 	devBE := o.DeviceManager().StoreContextBackend()
 
+	st := o.State()
+	st.Lock()
+	defer st.Unlock()
 	sto := o.NewStore(devBE)
 	c.Check(sto, FitsTypeOf, &store.Store{})
 	c.Check(sto.(*store.Store).CacheDownloads(), Equals, 5)
 }
 
+func (ovs *overlordSuite) TestNewStoreDirectoryFromEnv(c *C) {
+	dir := c.MkDir()
+	os.Setenv("SNAPD_STORE_DIR", dir)
+	defer os.Unsetenv("SNAPD_STORE_DIR")
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+
+	st := o.State()
+	st.Lock()
+	defer st.Unlock()
+	sto := snapstate.Store(st, nil)
+	c.Assert(sto, FitsTypeOf, &store.DirStore{})
+	c.Check(sto.(*store.DirStore).Dir(), Equals, dir)
+}
+
+func (ovs *overlordSuite) TestNewStoreDirectoryFromConfig(c *C) {
+	fakeState := []byte(fmt.Sprintf(`{"data":{"patch-level":%d,"patch-sublevel":%d,"patch-sublevel-last-version":%q,"config":{"core":{"store":{"directory":"/media/usb/snaps"}}}},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0}`, patch.Level, patch.Sublevel, snapdtool.Version))
+	err := ioutil.WriteFile(dirs.SnapStateFile, fakeState, 0600)
+	c.Assert(err, IsNil)
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+
+	st := o.State()
+	st.Lock()
+	defer st.Unlock()
+	sto := snapstate.Store(st, nil)
+	c.Assert(sto, FitsTypeOf, &store.DirStore{})
+	c.Check(sto.(*store.DirStore).Dir(), Equals, "/media/usb/snaps")
+
+	// also for the stores created on remodel
+	sto = o.NewStore(o.DeviceManager().StoreContextBackend())
+	c.Check(sto, FitsTypeOf, &store.DirStore{})
+}
+
+func (ovs *overlordSuite) TestPeerSettings(c *C) {
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+
+	settings, err := o.PeerSettings()
+	c.Assert(err, IsNil)
+	c.Check(settings, DeepEquals, &store.PeerSettings{Port: store.DefaultPeerPort})
+
+	st := o.State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", "store.peers.discover", true), IsNil)
+	c.Assert(tr.Set("core", "store.peers.serve", "true"), IsNil)
+	c.Assert(tr.Set("core", "store.peers.port", 8080), IsNil)
+	c.Assert(tr.Set("core", "store.peers.list", "cache.lan, 192.168.1.5:9090,fe80::1"), IsNil)
+	tr.Commit()
+	st.Unlock()
+
+	settings, err = o.PeerSettings()
+	c.Assert(err, IsNil)
+	c.Check(settings, DeepEquals, &store.PeerSettings{
+		Discover: true,
+		Serve:    true,
+		Port:     8080,
+		Peers:    []string{"cache.lan:8080", "192.168.1.5:9090", "[fe80::1]:8080"},
+	})
+}
+
 func (ovs *overlordSuite) TestNewWithGoodState(c *C) {
 	// ensure we don't write state load timing in the state on really
 	// slow architectures (e.g. risc-v)
@@ -196,6 +266,30 @@ func (ovs *overlordSuite) TestNewWithGoodState(c *C) {
 	c.Check(got, DeepEquals, expected)
 }
 
+func (ovs *overlordSuite) TestNewWithStateJournal(c *C) {
+	fakeState := []byte(fmt.Sprintf(`{"data":{"patch-level":%d,"patch-sublevel":%d,"patch-sublevel-last-version":%q,"some":"data","refresh-privacy-key":"0123456789ABCDEF"},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0}`, patch.Level, patch.Sublevel, snapdtool.Version))
+	err := ioutil.WriteFile(dirs.SnapStateFile, fakeState, 0600)
+	c.Assert(err, IsNil)
+	c.Assert(os.MkdirAll(dirs.FeaturesDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(features.StateJournal.ControlFile(), nil, 0644), IsNil)
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+
+	st := o.State()
+	st.Lock()
+	st.Set("some", "other-data")
+	st.Unlock()
+
+	// the change went to the journal
+	c.Check(dirs.SnapStateFile+".journal", testutil.FilePresent)
+	c.Check(dirs.SnapStateFile, Not(testutil.FileContains), "other-data")
+
+	// and is in the state file after stopping
+	c.Assert(o.Stop(), IsNil)
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"some":"other-data"`)
+}
+
 func (ovs *overlordSuite) TestNewWithStateSnapmgrUpdate(c *C) {
 	fakeState := []byte(fmt.Sprintf(`{"data":{"patch-level":%d,"some":"data"},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0}`, patch.Level))
 	err := ioutil.WriteFile(dirs.SnapStateFile, fakeState, 0600)
@@ -613,6 +707,27 @@ func (ovs *overlordSuite) TestEnsureLoopPrune(c *C) {
 	c.Assert(t1.Status(), Equals, state.HoldStatus)
 }
 
+func (ovs *overlordSuite) TestPruneArchivesChanges(c *C) {
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+
+	st := o.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.NewChange("prune", "...")
+	chg.AddTask(st.NewTask("foo", "..."))
+	chg.SetStatus(state.DoneStatus)
+	st.Prune(time.Now(), 0, time.Hour, 100)
+	c.Assert(st.Change(chg.ID()), IsNil)
+
+	entries, err := changearchive.Query(nil)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Check(entries[0].ID, Equals, chg.ID())
+	c.Check(entries[0].Kind, Equals, "prune")
+	c.Check(entries[0].Tasks, HasLen, 1)
+}
+
 func (ovs *overlordSuite) TestEnsureLoopPruneRunsMultipleTimes(c *C) {
 	restoreIntv := overlord.MockPruneInterval(100*time.Millisecond, 5*time.Millisecond, 1*time.Hour)
 	defer restoreIntv()
@@ -20,9 +20,12 @@
 package servicestate
 
 import (
+	"time"
+
 	tomb "gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/sandbox/cgroup"
 	"github.com/snapcore/snapd/snap/quota"
 	"github.com/snapcore/snapd/testutil"
 )
@@ -67,3 +70,39 @@ func MockResourcesCheckFeatureRequirements(f func(*quota.Resources) error) (rest
 	resourcesCheckFeatureRequirements = f
 	return r
 }
+
+func MockLimitPolicyCheckFeatureRequirements(f func(*quota.LimitPolicy) error) (restore func()) {
+	r := testutil.Backup(&limitPolicyCheckFeatureRequirements)
+	limitPolicyCheckFeatureRequirements = f
+	return r
+}
+
+func MockQuotaGroupUsage(f func(grp *quota.Group) (*QuotaUsageSample, error)) (restore func()) {
+	r := testutil.Backup(&quotaGroupUsage)
+	quotaGroupUsage = f
+	return r
+}
+
+func MockQuotaUsageHistoryMax(max int) (restore func()) {
+	r := testutil.Backup(&quotaUsageHistoryMax)
+	quotaUsageHistoryMax = max
+	return r
+}
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	r := testutil.Backup(&timeNow)
+	timeNow = f
+	return r
+}
+
+func MockCgroupMemoryEventsOfGroup(f func(groupPath string) (*cgroup.MemoryEvents, error)) (restore func()) {
+	r := testutil.Backup(&cgroupMemoryEventsOfGroup)
+	cgroupMemoryEventsOfGroup = f
+	return r
+}
+
+func MockCgroupCPUStatOfGroup(f func(groupPath string) (*cgroup.CPUStat, error)) (restore func()) {
+	r := testutil.Backup(&cgroupCPUStatOfGroup)
+	cgroupCPUStatOfGroup = f
+	return r
+}
@@ -24,6 +24,7 @@ import (
 
 	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/servicestate/internal"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -64,6 +65,23 @@ var resourcesCheckFeatureRequirements = func(r *quota.Resources) error {
 	return r.CheckFeatureRequirements()
 }
 
+var limitPolicyCheckFeatureRequirements = func(p *quota.LimitPolicy) error {
+	return p.CheckFeatureRequirements()
+}
+
+// validateIOQuotaDevice verifies that the device an I/O quota refers to
+// exists, systemd only logs when it cannot resolve the device of an I/O
+// limit and the limit would then silently not be applied.
+func validateIOQuotaDevice(resourceLimits *quota.Resources) error {
+	if resourceLimits.IO == nil || resourceLimits.IO.Device == "" {
+		return nil
+	}
+	if !osutil.FileExists(resourceLimits.IO.Device) {
+		return fmt.Errorf("io quota device %q does not exist", resourceLimits.IO.Device)
+	}
+	return nil
+}
+
 func quotaGroupsAvailable(st *state.State) error {
 	// check if the systemd version is too old
 	if systemdVersionError != nil {
@@ -108,6 +126,9 @@ func CreateQuota(st *state.State, name string, parentName string, snaps []string
 	if err := resourcesCheckFeatureRequirements(&resourceLimits); err != nil {
 		return nil, fmt.Errorf("cannot create quota group %q: %v", name, err)
 	}
+	if err := validateIOQuotaDevice(&resourceLimits); err != nil {
+		return nil, fmt.Errorf("cannot create quota group %q: %v", name, err)
+	}
 
 	// make sure the specified snaps exist and aren't currently in another group
 	if err := validateSnapForAddingToGroup(st, snaps, name, allGrps); err != nil {
@@ -199,6 +220,10 @@ type QuotaGroupUpdate struct {
 	// NewResourceLimits is the new resource limits to be used for the quota group. A
 	// limit is only changed if the corresponding limit is != nil.
 	NewResourceLimits quota.Resources
+
+	// NewLimitPolicy is the new policy for the group hitting its limits, the
+	// policy is only changed if it is != nil.
+	NewLimitPolicy *quota.LimitPolicy
 }
 
 // UpdateQuota updates the quota as per the options.
@@ -228,6 +253,21 @@ func UpdateQuota(st *state.State, name string, updateOpts QuotaGroupUpdate) (*st
 	if err := resourcesCheckFeatureRequirements(&updateOpts.NewResourceLimits); err != nil {
 		return nil, fmt.Errorf("cannot update group %q: %v", name, err)
 	}
+	if err := validateIOQuotaDevice(&updateOpts.NewResourceLimits); err != nil {
+		return nil, fmt.Errorf("cannot update group %q: %v", name, err)
+	}
+	if updateOpts.NewLimitPolicy != nil {
+		// the policy must be valid for the limits resulting from the update
+		if err := currentQuotas.Change(updateOpts.NewResourceLimits); err != nil {
+			return nil, fmt.Errorf("cannot update group %q: %v", name, err)
+		}
+		if err := updateOpts.NewLimitPolicy.Validate(currentQuotas); err != nil {
+			return nil, fmt.Errorf("cannot update group %q: %v", name, err)
+		}
+		if err := limitPolicyCheckFeatureRequirements(updateOpts.NewLimitPolicy); err != nil {
+			return nil, fmt.Errorf("cannot update group %q: %v", name, err)
+		}
+	}
 
 	// ensure that the group we are modifying does not contain a mix of snaps and sub-groups
 	// as we no longer support this, and existing quota groups might have this
@@ -253,6 +293,7 @@ func UpdateQuota(st *state.State, name string, updateOpts QuotaGroupUpdate) (*st
 		Action:         "update",
 		QuotaName:      name,
 		ResourceLimits: updateOpts.NewResourceLimits,
+		LimitPolicy:    updateOpts.NewLimitPolicy,
 		AddSnaps:       updateOpts.AddSnaps,
 	}
 
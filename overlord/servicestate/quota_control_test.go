@@ -128,7 +128,7 @@ func checkQuotaState(c *C, st *state.State, exp map[string]quotaGroupState) {
 func shouldMentionSlice(resources quota.Resources) bool {
 	if resources.Memory == nil && resources.CPU == nil &&
 		resources.CPUSet == nil && resources.Threads == nil &&
-		resources.Journal == nil {
+		resources.Journal == nil && resources.IO == nil {
 		return false
 	}
 	return true
@@ -170,6 +170,12 @@ func checkSliceState(c *C, sliceName string, resources quota.Resources) {
 	if resources.Threads != nil {
 		c.Assert(sliceFileName, testutil.FileContains, fmt.Sprintf("\nThreadsMax=%d\n", resources.Threads.Limit))
 	}
+	if resources.IO != nil && resources.IO.ReadBandwidth != 0 {
+		c.Assert(sliceFileName, testutil.FileContains, fmt.Sprintf("\nIOReadBandwidthMax=%s %d\n", dirs.SnapDataDir, resources.IO.ReadBandwidth))
+	}
+	if resources.IO != nil && resources.IO.WriteBandwidth != 0 {
+		c.Assert(sliceFileName, testutil.FileContains, fmt.Sprintf("\nIOWriteBandwidthMax=%s %d\n", dirs.SnapDataDir, resources.IO.WriteBandwidth))
+	}
 }
 
 func systemctlCallsForSliceStart(name string) []expectedSystemctl {
@@ -294,6 +300,80 @@ func (s *quotaControlSuite) TestCreateQuotaPrecond(c *C) {
 	}
 }
 
+func (s *quotaControlSuite) TestCreateQuotaIODeviceMissing(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	quotaConstraints := quota.NewResourcesBuilder().
+		WithIODevice("/dev/not-a-device").
+		WithIOReadBandwidth(quantity.SizeMiB).
+		Build()
+	_, err := servicestate.CreateQuota(st, "foo", "", nil, quotaConstraints)
+	c.Check(err, ErrorMatches, `cannot create quota group "foo": io quota device "/dev/not-a-device" does not exist`)
+}
+
+func (s *quotaControlSuite) TestCreateSubGroupIOQuotaHappy(c *C) {
+	r := s.mockSystemctlCalls(c, join(
+		// CreateQuota for foo/bar, the slices are only started once the
+		// sub-group contains a snap
+		[]expectedSystemctl{{expArgs: []string{"daemon-reload"}}},
+		systemctlCallsForSliceStart("foo/bar"),
+		systemctlCallsForSliceStart("foo"),
+		systemctlCallsForServiceRestart("test-snap"),
+	))
+	defer r()
+
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	// setup the snap so it exists
+	snapstate.Set(s.state, "test-snap", s.testSnapState)
+	snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
+
+	parentConstraints := quota.NewResourcesBuilder().WithIOReadBandwidth(10 * quantity.SizeMiB).Build()
+	s.createQuota(c, "foo", parentConstraints)
+
+	// a sub-group that does not fit into the parent is refused by the handler
+	ts, err := servicestate.CreateQuota(st, "bar", "foo", []string{"test-snap"},
+		quota.NewResourcesBuilder().WithIOReadBandwidth(20*quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	chg := st.NewChange("quota-control", "...")
+	chg.AddAll(ts)
+	st.Unlock()
+	err = s.o.Settle(5 * time.Second)
+	st.Lock()
+	c.Assert(err, IsNil)
+	c.Assert(chg.Err(), ErrorMatches, `(?s).*sub-group io read bandwidth limit of 20971520B/s is too large to fit inside group "foo" remaining quota space 10485760B/s.*`)
+
+	subConstraints := quota.NewResourcesBuilder().
+		WithIOReadBandwidth(5 * quantity.SizeMiB).
+		WithIOWriteBandwidth(quantity.SizeMiB).
+		Build()
+	ts, err = servicestate.CreateQuota(st, "bar", "foo", []string{"test-snap"}, subConstraints)
+	c.Assert(err, IsNil)
+	chg = st.NewChange("quota-control", "...")
+	chg.AddAll(ts)
+	st.Unlock()
+	err = s.o.Settle(5 * time.Second)
+	st.Lock()
+	c.Assert(err, IsNil)
+	c.Assert(chg.Err(), IsNil)
+
+	checkQuotaState(c, st, map[string]quotaGroupState{
+		"foo": {
+			ResourceLimits: parentConstraints,
+			SubGroups:      []string{"bar"},
+		},
+		"bar": {
+			ResourceLimits: subConstraints,
+			ParentGroup:    "foo",
+			Snaps:          []string{"test-snap"},
+		},
+	})
+}
+
 func (s *quotaControlSuite) TestRemoveQuotaPreseeding(c *C) {
 	r := snapdenv.MockPreseeding(true)
 	defer r()
@@ -664,6 +744,76 @@ func (s *quotaControlSuite) TestUpdateQuotaPrecond(c *C) {
 	}
 }
 
+func (s *quotaControlSuite) TestUpdateQuotaLimitPolicy(c *C) {
+	r := servicestate.MockLimitPolicyCheckFeatureRequirements(func(p *quota.LimitPolicy) error {
+		return nil
+	})
+	defer r()
+
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	quotaConstraits := quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build()
+	err := servicestatetest.MockQuotaInState(st, "foo", "", nil, quotaConstraits)
+	c.Assert(err, IsNil)
+
+	policy := &quota.LimitPolicy{Action: quota.LimitActionRaise, MemoryCeiling: 2 * quantity.SizeGiB}
+	ts, err := servicestate.UpdateQuota(st, "foo", servicestate.QuotaGroupUpdate{NewLimitPolicy: policy})
+	c.Assert(err, IsNil)
+
+	chg := st.NewChange("quota-control", "...")
+	chg.AddAll(ts)
+
+	st.Unlock()
+	err = s.o.Settle(5 * time.Second)
+	st.Lock()
+	c.Assert(err, IsNil)
+	c.Assert(chg.Err(), IsNil)
+
+	grp, err := servicestate.GetQuota(st, "foo")
+	c.Assert(err, IsNil)
+	c.Check(grp.LimitPolicy, DeepEquals, policy)
+	c.Check(grp.MemoryLimit, Equals, quantity.SizeGiB)
+}
+
+func (s *quotaControlSuite) TestUpdateQuotaLimitPolicyPrecond(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	quotaConstraits := quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build()
+	err := servicestatetest.MockQuotaInState(st, "foo", "", nil, quotaConstraits)
+	c.Assert(err, IsNil)
+
+	r := servicestate.MockLimitPolicyCheckFeatureRequirements(func(p *quota.LimitPolicy) error {
+		return fmt.Errorf("cannot use limit policy with cgroup version 1")
+	})
+	defer r()
+
+	tests := []struct {
+		opts servicestate.QuotaGroupUpdate
+		err  string
+	}{
+		{servicestate.QuotaGroupUpdate{
+			NewLimitPolicy: &quota.LimitPolicy{Action: "explode"},
+		}, `cannot update group "foo": unknown limit action "explode"`},
+		{servicestate.QuotaGroupUpdate{
+			NewLimitPolicy: &quota.LimitPolicy{Action: quota.LimitActionRaise, MemoryCeiling: quantity.SizeGiB},
+			// the policy is validated against the new limits
+			NewResourceLimits: quota.NewResourcesBuilder().WithMemoryLimit(2 * quantity.SizeGiB).Build(),
+		}, `cannot update group "foo": memory ceiling 1 GiB is smaller than the memory limit 2 GiB`},
+		{servicestate.QuotaGroupUpdate{
+			NewLimitPolicy: &quota.LimitPolicy{Action: quota.LimitActionWarn},
+		}, `cannot update group "foo": cannot use limit policy with cgroup version 1`},
+	}
+
+	for _, t := range tests {
+		_, err := servicestate.UpdateQuota(st, "foo", t.opts)
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
+
 func (s *quotaControlSuite) TestRemoveQuotaPrecond(c *C) {
 	st := s.state
 	st.Lock()
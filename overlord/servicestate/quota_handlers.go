@@ -62,6 +62,10 @@ type QuotaControlAction struct {
 	// value to be set.
 	ResourceLimits quota.Resources `json:"resource-limits,omitempty"`
 
+	// LimitPolicy is the new policy of the quota group for when it hits its
+	// limits, valid for the "update" action. If nil the policy is unchanged.
+	LimitPolicy *quota.LimitPolicy `json:"limit-policy,omitempty"`
+
 	// ParentName is the name of the parent for the quota group if it is being
 	// created. Eventually this could be used with the "update" action to
 	// support moving quota groups from one parent to another, but that is
@@ -517,6 +521,9 @@ func quotaUpdate(st *state.State, action QuotaControlAction, allGrps map[string]
 	if err := quotaUpdateGroupLimits(grp, action.ResourceLimits); err != nil {
 		return nil, nil, false, err
 	}
+	if action.LimitPolicy != nil {
+		grp.LimitPolicy = action.LimitPolicy
+	}
 
 	// update the quota group state
 	allGrps, err := internal.PatchQuotas(st, modifiedGrps...)
@@ -0,0 +1,348 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	tomb "gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/quota"
+)
+
+const (
+	// QuotaLimitOOM is the kind of the event of the processes of a quota
+	// group getting killed by the OOM killer.
+	QuotaLimitOOM = "oom"
+	// QuotaLimitCPUThrottled is the kind of the event of a quota group
+	// being throttled by its CPU limit for a sustained period of time.
+	QuotaLimitCPUThrottled = "cpu-throttled"
+)
+
+var (
+	// quotaLimitsCheckInterval is how often the cgroup counters of the
+	// quota groups with a limit policy are checked.
+	quotaLimitsCheckInterval = time.Minute
+	// cpuThrottledRatio is the ratio of CPU enforcement periods in which a
+	// group must have been throttled during a check interval for the
+	// interval to count as throttled.
+	cpuThrottledRatio = 0.5
+	// cpuThrottledIntervals is the number of consecutive throttled check
+	// intervals after which the throttling is considered sustained.
+	cpuThrottledIntervals = 5
+
+	cgroupMemoryEventsOfGroup = cgroup.MemoryEventsOfGroup
+	cgroupCPUStatOfGroup      = cgroup.CPUStatOfGroup
+)
+
+// QuotaLimitEvent is the serialized representation of a quota group hitting
+// one of its limits that lives in a task.
+type QuotaLimitEvent struct {
+	// QuotaName is the name of the quota group that hit its limit.
+	QuotaName string `json:"quota-name"`
+	// Kind is the kind of limit event, either QuotaLimitOOM or
+	// QuotaLimitCPUThrottled.
+	Kind string `json:"kind"`
+	// Action is the action of the limit policy of the group at the time
+	// of the event.
+	Action quota.LimitAction `json:"action"`
+	// MemoryCeiling is the memory ceiling of the limit policy of the group
+	// at the time of the event, valid for the "raise" action.
+	MemoryCeiling quantity.Size `json:"memory-ceiling,omitempty"`
+}
+
+// quotaLimitCounters are the cgroup counters of a quota group seen at the last
+// check, the events are detected by comparing them with the current ones.
+type quotaLimitCounters struct {
+	memory *cgroup.MemoryEvents
+	cpu    *cgroup.CPUStat
+	// throttledIntervals is the number of consecutive check intervals
+	// the group was throttled in.
+	throttledIntervals int
+}
+
+// ensureQuotaLimitsMonitored checks the cgroup counters of all the quota
+// groups with a limit policy once every quotaLimitsCheckInterval, and
+// acts according to the policy of the groups that hit their limits since
+// the last check.
+func (m *ServiceManager) ensureQuotaLimitsMonitored() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	now := timeNow()
+	if elapsed := now.Sub(m.lastQuotaLimitsCheck); elapsed < quotaLimitsCheckInterval {
+		// the counters are only kept for the groups with a limit policy,
+		// make sure the next check is not delayed until the next
+		// regular ensure
+		if len(m.quotaLimitCounters) != 0 {
+			m.state.EnsureBefore(quotaLimitsCheckInterval - elapsed)
+		}
+		return nil
+	}
+	m.lastQuotaLimitsCheck = now
+
+	allGrps, err := AllQuotas(m.state)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(allGrps))
+	for name, grp := range allGrps {
+		if grp.LimitPolicy != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	counters := make(map[string]*quotaLimitCounters, len(names))
+	for _, name := range names {
+		grp := allGrps[name]
+		cur, events := checkQuotaLimits(grp, m.quotaLimitCounters[name])
+		counters[name] = cur
+		for _, kind := range events {
+			if err := handleQuotaLimitEvent(m.state, grp, kind); err != nil {
+				return err
+			}
+		}
+	}
+	// this also forgets the counters of removed groups or of groups
+	// whose policy was unset
+	m.quotaLimitCounters = counters
+	if len(names) != 0 {
+		// the throttling is counted in check intervals, so the checks
+		// must happen once every interval
+		m.state.EnsureBefore(quotaLimitsCheckInterval)
+	}
+	return nil
+}
+
+// checkQuotaLimits reads the current cgroup counters of the group and returns
+// them along with the kinds of the limit events that happened since the
+// previous counters were read.
+func checkQuotaLimits(grp *quota.Group, prev *quotaLimitCounters) (*quotaLimitCounters, []string) {
+	cur := &quotaLimitCounters{}
+	var events []string
+
+	// the counters are reset when the slice of the group is restarted, in
+	// which case they cannot be compared with the previous ones
+	if grp.MemoryLimit != 0 {
+		memEvents, err := cgroupMemoryEventsOfGroup(grp.CgroupPath())
+		if err != nil {
+			// the slice of the group is not active if none of its
+			// services is running
+			logger.Debugf("cannot check memory events of quota group %q: %v", grp.Name, err)
+		} else {
+			cur.memory = memEvents
+			if prev != nil && prev.memory != nil {
+				if memEvents.OOM > prev.memory.OOM || memEvents.OOMKill > prev.memory.OOMKill {
+					events = append(events, QuotaLimitOOM)
+				}
+			}
+		}
+	}
+
+	if grp.CPULimit != nil && grp.CPULimit.Percentage != 0 {
+		stat, err := cgroupCPUStatOfGroup(grp.CgroupPath())
+		if err != nil {
+			logger.Debugf("cannot check CPU statistics of quota group %q: %v", grp.Name, err)
+		} else {
+			cur.cpu = stat
+			if prev != nil && prev.cpu != nil && stat.Periods > prev.cpu.Periods && stat.ThrottledPeriods >= prev.cpu.ThrottledPeriods {
+				periods := stat.Periods - prev.cpu.Periods
+				throttled := stat.ThrottledPeriods - prev.cpu.ThrottledPeriods
+				if float64(throttled) >= cpuThrottledRatio*float64(periods) {
+					cur.throttledIntervals = prev.throttledIntervals + 1
+				}
+			}
+			if cur.throttledIntervals >= cpuThrottledIntervals {
+				events = append(events, QuotaLimitCPUThrottled)
+				cur.throttledIntervals = 0
+			}
+		}
+	}
+
+	return cur, events
+}
+
+func quotaLimitEventDescription(grp *quota.Group, kind string) string {
+	if kind == QuotaLimitOOM {
+		return fmt.Sprintf("quota group %q ran out of memory", grp.Name)
+	}
+	return fmt.Sprintf("quota group %q was throttled by its CPU limit for over %v",
+		grp.Name, time.Duration(cpuThrottledIntervals)*quotaLimitsCheckInterval)
+}
+
+// handleQuotaLimitEvent records a warning about the group hitting its limit and
+// creates a change to act according to the limit policy of the group.
+func handleQuotaLimitEvent(st *state.State, grp *quota.Group, kind string) error {
+	event := QuotaLimitEvent{
+		QuotaName:     grp.Name,
+		Kind:          kind,
+		Action:        grp.LimitPolicy.Action,
+		MemoryCeiling: grp.LimitPolicy.MemoryCeiling,
+	}
+	desc := quotaLimitEventDescription(grp, kind)
+
+	var summary string
+	switch {
+	case event.Action == quota.LimitActionRestart:
+		st.Warnf("%s, restarting its services", desc)
+		summary = fmt.Sprintf(i18n.G("Restart services of quota group %q after it hit its limit"), grp.Name)
+	case event.Action == quota.LimitActionRaise && kind == QuotaLimitOOM:
+		if grp.MemoryLimit >= event.MemoryCeiling {
+			st.Warnf("%s, its memory limit is already at its ceiling of %s", desc, event.MemoryCeiling.IECString())
+		} else {
+			st.Warnf("%s, raising its memory limit to %s", desc, event.MemoryCeiling.IECString())
+		}
+		summary = fmt.Sprintf(i18n.G("Raise memory limit of quota group %q after it ran out of memory"), grp.Name)
+	default:
+		st.Warnf("%s", desc)
+		summary = fmt.Sprintf(i18n.G("Record quota group %q hitting its limit"), grp.Name)
+	}
+
+	// don't act on the event while the group is being modified, or while
+	// the previous limit event is still handled, the warning is enough
+	if err := CheckQuotaChangeConflictMany(st, []string{grp.Name}); err != nil {
+		if _, ok := err.(*QuotaChangeConflictError); ok {
+			logger.Noticef("not acting on %s: %v", desc, err)
+			return nil
+		}
+		return err
+	}
+
+	task := st.NewTask("quota-limit-action", summary)
+	task.Set("quota-limit-event", event)
+	chg := st.NewChange("quota-limit-event", summary)
+	chg.AddTask(task)
+	st.EnsureBefore(0)
+	return nil
+}
+
+func (m *ServiceManager) doQuotaLimitAction(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var event QuotaLimitEvent
+	if err := t.Get("quota-limit-event", &event); err != nil {
+		return fmt.Errorf("internal error: cannot get quota-limit-event: %v", err)
+	}
+
+	allGrps, err := AllQuotas(st)
+	if err != nil {
+		return err
+	}
+	grp, ok := allGrps[event.QuotaName]
+	if !ok {
+		t.Logf("quota group %q was removed, nothing to do", event.QuotaName)
+		return nil
+	}
+	t.Logf("%s", quotaLimitEventDescription(grp, event.Kind))
+
+	ts := state.NewTaskSet()
+	switch event.Action {
+	case quota.LimitActionRestart:
+		servicesAffected, err := quotaGroupServices(st, grp, allGrps)
+		if err != nil {
+			return err
+		}
+		if len(servicesAffected) == 0 {
+			t.Logf("no services to restart")
+			return nil
+		}
+		var prevTask *state.Task
+		queueTask := func(task *state.Task) {
+			if prevTask != nil {
+				task.WaitFor(prevTask)
+			}
+			ts.AddTask(task)
+			prevTask = task
+		}
+		addRestartServicesTasks(st, queueTask, grp.Name, servicesAffected)
+	case quota.LimitActionRaise:
+		if event.Kind != QuotaLimitOOM {
+			return nil
+		}
+		if grp.MemoryLimit >= event.MemoryCeiling {
+			t.Logf("memory limit is already at its ceiling of %s", event.MemoryCeiling.IECString())
+			return nil
+		}
+		// the quota-control handler takes care of validating that the
+		// raised limit still fits in the parent group
+		qc := QuotaControlAction{
+			Action:         "update",
+			QuotaName:      grp.Name,
+			ResourceLimits: quota.NewResourcesBuilder().WithMemoryLimit(event.MemoryCeiling).Build(),
+		}
+		summary := fmt.Sprintf(i18n.G("Raise memory limit of quota group %q to %s"), grp.Name, event.MemoryCeiling.IECString())
+		task := st.NewTask("quota-control", summary)
+		task.Set("quota-control-actions", []QuotaControlAction{qc})
+		ts.AddTask(task)
+	default:
+		return nil
+	}
+
+	snapstate.InjectTasks(t, ts)
+	t.SetStatus(state.DoneStatus)
+	return nil
+}
+
+// quotaGroupServices returns the services of the snaps in the group and in all
+// of its sub-groups.
+func quotaGroupServices(st *state.State, grp *quota.Group, allGrps map[string]*quota.Group) (map[*snap.Info][]*snap.AppInfo, error) {
+	services := make(map[*snap.Info][]*snap.AppInfo)
+	grps := []*quota.Group{grp}
+	for len(grps) > 0 {
+		g := grps[0]
+		grps = grps[1:]
+		for _, subGrpName := range g.SubGroups {
+			if subGrp := allGrps[subGrpName]; subGrp != nil {
+				grps = append(grps, subGrp)
+			}
+		}
+		for _, snapName := range g.Snaps {
+			info, err := snapstate.CurrentInfo(st, snapName)
+			if err != nil {
+				return nil, err
+			}
+			if svcs := info.Services(); len(svcs) > 0 {
+				services[info] = svcs
+			}
+		}
+	}
+	return services, nil
+}
+
+func affectedQuotasForQuotaLimitAction(t *state.Task) ([]string, error) {
+	var event QuotaLimitEvent
+	if err := t.Get("quota-limit-event", &event); err != nil {
+		return nil, fmt.Errorf("internal error: cannot get quota-limit-event: %v", err)
+	}
+	return []string{event.QuotaName}, nil
+}
@@ -0,0 +1,357 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate_test
+
+import (
+	"fmt"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/servicestate/servicestatetest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type quotaLimitsSuite struct {
+	baseServiceMgrTestSuite
+
+	now time.Time
+
+	memEvents map[string]*cgroup.MemoryEvents
+	cpuStats  map[string]*cgroup.CPUStat
+}
+
+var _ = Suite(&quotaLimitsSuite{})
+
+func (s *quotaLimitsSuite) SetUpTest(c *C) {
+	s.baseServiceMgrTestSuite.SetUpTest(c)
+
+	// we don't need the EnsureSnapServices ensure loop to run by default
+	servicestate.MockEnsuredSnapServices(s.mgr, true)
+
+	s.now = time.Now()
+	s.AddCleanup(servicestate.MockTimeNow(func() time.Time { return s.now }))
+	s.AddCleanup(servicestate.MockQuotaGroupUsage(func(grp *quota.Group) (*servicestate.QuotaUsageSample, error) {
+		return &servicestate.QuotaUsageSample{}, nil
+	}))
+
+	s.memEvents = make(map[string]*cgroup.MemoryEvents)
+	s.AddCleanup(servicestate.MockCgroupMemoryEventsOfGroup(func(groupPath string) (*cgroup.MemoryEvents, error) {
+		events := s.memEvents[groupPath]
+		if events == nil {
+			return nil, fmt.Errorf("no memory events for %s", groupPath)
+		}
+		// return a copy so that the test can modify the counters
+		eventsCopy := *events
+		return &eventsCopy, nil
+	}))
+	s.cpuStats = make(map[string]*cgroup.CPUStat)
+	s.AddCleanup(servicestate.MockCgroupCPUStatOfGroup(func(groupPath string) (*cgroup.CPUStat, error) {
+		stat := s.cpuStats[groupPath]
+		if stat == nil {
+			return nil, fmt.Errorf("no cpu stat for %s", groupPath)
+		}
+		statCopy := *stat
+		return &statCopy, nil
+	}))
+}
+
+func (s *quotaLimitsSuite) ensureAfter(c *C, d time.Duration) {
+	s.now = s.now.Add(d)
+	c.Assert(s.mgr.Ensure(), IsNil)
+}
+
+func (s *quotaLimitsSuite) mockGroup(c *C, grp *quota.Group) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	_, err := servicestatetest.PatchQuotas(s.state, grp)
+	c.Assert(err, IsNil)
+}
+
+func (s *quotaLimitsSuite) limitEventChanges() []*state.Change {
+	var chgs []*state.Change
+	for _, chg := range s.state.Changes() {
+		if chg.Kind() == "quota-limit-event" {
+			chgs = append(chgs, chg)
+		}
+	}
+	return chgs
+}
+
+func (s *quotaLimitsSuite) warnings() []string {
+	var msgs []string
+	for _, w := range s.state.AllWarnings() {
+		msgs = append(msgs, w.String())
+	}
+	return msgs
+}
+
+// runLimitAction runs the pending quota-limit-action tasks, but not the
+// tasks they inject.
+func (s *quotaLimitsSuite) runLimitAction(c *C) {
+	s.state.Unlock()
+	defer s.state.Lock()
+	runner := s.o.TaskRunner()
+	c.Assert(runner.Ensure(), IsNil)
+	runner.Wait()
+}
+
+func (s *quotaLimitsSuite) TestOOMWarn(c *C) {
+	s.mockGroup(c, &quota.Group{
+		Name:        "foo",
+		MemoryLimit: quantity.SizeGiB,
+		LimitPolicy: &quota.LimitPolicy{Action: quota.LimitActionWarn},
+	})
+	s.memEvents["/snap.foo.slice"] = &cgroup.MemoryEvents{OOM: 2, OOMKill: 2}
+
+	// the first check only records the current counters
+	s.ensureAfter(c, 0)
+	s.state.Lock()
+	c.Check(s.limitEventChanges(), HasLen, 0)
+	c.Check(s.warnings(), HasLen, 0)
+	s.state.Unlock()
+
+	// no new OOM events
+	s.ensureAfter(c, time.Minute)
+	s.state.Lock()
+	c.Check(s.limitEventChanges(), HasLen, 0)
+	s.state.Unlock()
+
+	s.memEvents["/snap.foo.slice"].OOMKill = 3
+	// nothing happens before the next check interval
+	s.ensureAfter(c, 30*time.Second)
+	s.state.Lock()
+	c.Check(s.limitEventChanges(), HasLen, 0)
+	s.state.Unlock()
+
+	s.ensureAfter(c, 30*time.Second)
+	s.state.Lock()
+	c.Check(s.warnings(), DeepEquals, []string{`quota group "foo" ran out of memory`})
+	chgs := s.limitEventChanges()
+	c.Assert(chgs, HasLen, 1)
+	c.Check(chgs[0].Summary(), Equals, `Record quota group "foo" hitting its limit`)
+	tasks := chgs[0].Tasks()
+	c.Assert(tasks, HasLen, 1)
+	c.Check(tasks[0].Kind(), Equals, "quota-limit-action")
+	var event servicestate.QuotaLimitEvent
+	c.Assert(tasks[0].Get("quota-limit-event", &event), IsNil)
+	c.Check(event, DeepEquals, servicestate.QuotaLimitEvent{
+		QuotaName: "foo",
+		Kind:      servicestate.QuotaLimitOOM,
+		Action:    quota.LimitActionWarn,
+	})
+
+	s.runLimitAction(c)
+	c.Check(chgs[0].Status(), Equals, state.DoneStatus)
+	c.Check(chgs[0].Tasks(), HasLen, 1)
+	s.state.Unlock()
+
+	// the slice was restarted and the counters reset, this is not an event
+	s.memEvents["/snap.foo.slice"] = &cgroup.MemoryEvents{}
+	s.ensureAfter(c, time.Minute)
+	s.state.Lock()
+	c.Check(s.limitEventChanges(), HasLen, 1)
+	s.state.Unlock()
+}
+
+func (s *quotaLimitsSuite) TestOOMRestart(c *C) {
+	s.state.Lock()
+	snapstate.Set(s.state, "test-snap", s.testSnapState)
+	snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
+	s.state.Unlock()
+
+	s.mockGroup(c, &quota.Group{
+		Name:        "foo",
+		MemoryLimit: quantity.SizeGiB,
+		Snaps:       []string{"test-snap"},
+		LimitPolicy: &quota.LimitPolicy{Action: quota.LimitActionRestart},
+	})
+	s.memEvents["/snap.foo.slice"] = &cgroup.MemoryEvents{}
+
+	s.ensureAfter(c, 0)
+	s.memEvents["/snap.foo.slice"].OOM = 1
+	s.ensureAfter(c, time.Minute)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.warnings(), DeepEquals, []string{`quota group "foo" ran out of memory, restarting its services`})
+	chgs := s.limitEventChanges()
+	c.Assert(chgs, HasLen, 1)
+	c.Check(chgs[0].Summary(), Equals, `Restart services of quota group "foo" after it hit its limit`)
+
+	s.runLimitAction(c)
+	tasks := chgs[0].Tasks()
+	c.Assert(tasks, HasLen, 2)
+	c.Check(tasks[0].Status(), Equals, state.DoneStatus)
+	c.Check(tasks[1].Kind(), Equals, "service-control")
+	var action servicestate.ServiceAction
+	c.Assert(tasks[1].Get("service-action", &action), IsNil)
+	c.Check(action, DeepEquals, servicestate.ServiceAction{
+		Action:   "restart",
+		SnapName: "test-snap",
+		Services: []string{"svc1"},
+	})
+}
+
+func (s *quotaLimitsSuite) TestOOMRaise(c *C) {
+	s.mockGroup(c, &quota.Group{
+		Name:        "foo",
+		MemoryLimit: quantity.SizeGiB,
+		LimitPolicy: &quota.LimitPolicy{Action: quota.LimitActionRaise, MemoryCeiling: 2 * quantity.SizeGiB},
+	})
+	s.memEvents["/snap.foo.slice"] = &cgroup.MemoryEvents{}
+
+	s.ensureAfter(c, 0)
+	s.memEvents["/snap.foo.slice"].OOM = 1
+	s.ensureAfter(c, time.Minute)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.warnings(), DeepEquals, []string{`quota group "foo" ran out of memory, raising its memory limit to 2 GiB`})
+	chgs := s.limitEventChanges()
+	c.Assert(chgs, HasLen, 1)
+	c.Check(chgs[0].Summary(), Equals, `Raise memory limit of quota group "foo" after it ran out of memory`)
+
+	s.runLimitAction(c)
+	tasks := chgs[0].Tasks()
+	c.Assert(tasks, HasLen, 2)
+	c.Check(tasks[1].Kind(), Equals, "quota-control")
+	c.Check(tasks[1].Summary(), Equals, `Raise memory limit of quota group "foo" to 2 GiB`)
+	var qcs []servicestate.QuotaControlAction
+	c.Assert(tasks[1].Get("quota-control-actions", &qcs), IsNil)
+	c.Check(qcs, DeepEquals, []servicestate.QuotaControlAction{{
+		Action:         "update",
+		QuotaName:      "foo",
+		ResourceLimits: quota.NewResourcesBuilder().WithMemoryLimit(2 * quantity.SizeGiB).Build(),
+	}})
+}
+
+func (s *quotaLimitsSuite) TestOOMRaiseAtCeiling(c *C) {
+	s.mockGroup(c, &quota.Group{
+		Name:        "foo",
+		MemoryLimit: 2 * quantity.SizeGiB,
+		LimitPolicy: &quota.LimitPolicy{Action: quota.LimitActionRaise, MemoryCeiling: 2 * quantity.SizeGiB},
+	})
+	s.memEvents["/snap.foo.slice"] = &cgroup.MemoryEvents{}
+
+	s.ensureAfter(c, 0)
+	s.memEvents["/snap.foo.slice"].OOMKill = 1
+	s.ensureAfter(c, time.Minute)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.warnings(), DeepEquals, []string{`quota group "foo" ran out of memory, its memory limit is already at its ceiling of 2 GiB`})
+	chgs := s.limitEventChanges()
+	c.Assert(chgs, HasLen, 1)
+
+	s.runLimitAction(c)
+	c.Check(chgs[0].Status(), Equals, state.DoneStatus)
+	c.Check(chgs[0].Tasks(), HasLen, 1)
+}
+
+func (s *quotaLimitsSuite) TestSustainedCPUThrottling(c *C) {
+	s.mockGroup(c, &quota.Group{
+		Name:        "foo",
+		CPULimit:    &quota.GroupQuotaCPU{Percentage: 50},
+		LimitPolicy: &quota.LimitPolicy{Action: quota.LimitActionWarn},
+	})
+	stat := &cgroup.CPUStat{}
+	s.cpuStats["/snap.foo.slice"] = stat
+
+	s.ensureAfter(c, 0)
+	// throttled in 4 consecutive intervals
+	for i := 0; i < 4; i++ {
+		stat.Periods += 600
+		stat.ThrottledPeriods += 400
+		s.ensureAfter(c, time.Minute)
+	}
+	// not throttled enough in the next one, which resets the count
+	stat.Periods += 600
+	stat.ThrottledPeriods += 100
+	s.ensureAfter(c, time.Minute)
+
+	s.state.Lock()
+	c.Check(s.limitEventChanges(), HasLen, 0)
+	s.state.Unlock()
+
+	for i := 0; i < 5; i++ {
+		stat.Periods += 600
+		stat.ThrottledPeriods += 300
+		s.ensureAfter(c, time.Minute)
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.warnings(), DeepEquals, []string{`quota group "foo" was throttled by its CPU limit for over 5m0s`})
+	chgs := s.limitEventChanges()
+	c.Assert(chgs, HasLen, 1)
+	var event servicestate.QuotaLimitEvent
+	c.Assert(chgs[0].Tasks()[0].Get("quota-limit-event", &event), IsNil)
+	c.Check(event.Kind, Equals, servicestate.QuotaLimitCPUThrottled)
+}
+
+func (s *quotaLimitsSuite) TestNoPolicyNoEvents(c *C) {
+	s.mockGroup(c, &quota.Group{
+		Name:        "foo",
+		MemoryLimit: quantity.SizeGiB,
+	})
+	s.memEvents["/snap.foo.slice"] = &cgroup.MemoryEvents{}
+
+	s.ensureAfter(c, 0)
+	s.memEvents["/snap.foo.slice"].OOM = 1
+	s.ensureAfter(c, time.Minute)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.limitEventChanges(), HasLen, 0)
+	c.Check(s.warnings(), HasLen, 0)
+}
+
+func (s *quotaLimitsSuite) TestEventWithChangeInProgress(c *C) {
+	s.mockGroup(c, &quota.Group{
+		Name:        "foo",
+		MemoryLimit: quantity.SizeGiB,
+		LimitPolicy: &quota.LimitPolicy{Action: quota.LimitActionWarn},
+	})
+	s.memEvents["/snap.foo.slice"] = &cgroup.MemoryEvents{}
+
+	s.ensureAfter(c, 0)
+	s.memEvents["/snap.foo.slice"].OOM = 1
+	s.ensureAfter(c, time.Minute)
+	s.state.Lock()
+	chgs := s.limitEventChanges()
+	c.Assert(chgs, HasLen, 1)
+	s.state.Unlock()
+
+	// the previous event is still being handled, only a warning is
+	// recorded for the new one
+	s.memEvents["/snap.foo.slice"].OOM = 2
+	s.ensureAfter(c, time.Minute)
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.limitEventChanges(), HasLen, 1)
+	c.Check(s.state.AllWarnings()[0].String(), Equals, `quota group "foo" ran out of memory`)
+}
@@ -0,0 +1,158 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate
+
+import (
+	"errors"
+	"time"
+
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap/quota"
+)
+
+var (
+	// quotaUsageSampleInterval is how often the resource usage of the quota
+	// groups is sampled.
+	quotaUsageSampleInterval = 5 * time.Minute
+	// quotaUsageHistoryMax is the number of samples kept for each quota
+	// group, with the default interval this is 24h worth of samples.
+	quotaUsageHistoryMax = 288
+
+	timeNow = time.Now
+)
+
+// QuotaUsageSample is the resource usage of a quota group at a point in time.
+type QuotaUsageSample struct {
+	Time time.Time `json:"time"`
+	// CPUTime is the total CPU time consumed by the group, the CPU usage
+	// in between two samples is the difference of their CPUTime.
+	CPUTime     time.Duration `json:"cpu-time,omitempty"`
+	Memory      quantity.Size `json:"memory,omitempty"`
+	Threads     int           `json:"threads,omitempty"`
+	JournalSize quantity.Size `json:"journal-size,omitempty"`
+}
+
+var quotaGroupUsage = func(grp *quota.Group) (*QuotaUsageSample, error) {
+	cpuTime, err := grp.CurrentCPUUsage()
+	if err != nil {
+		return nil, err
+	}
+	mem, err := grp.CurrentMemoryUsage()
+	if err != nil {
+		return nil, err
+	}
+	threads, err := grp.CurrentTaskUsage()
+	if err != nil {
+		return nil, err
+	}
+	journalSize, err := grp.CurrentJournalUsage()
+	if err != nil {
+		return nil, err
+	}
+	return &QuotaUsageSample{
+		CPUTime:     cpuTime,
+		Memory:      mem,
+		Threads:     threads,
+		JournalSize: journalSize,
+	}, nil
+}
+
+func quotaUsageHistory(st *state.State) (map[string][]QuotaUsageSample, error) {
+	var history map[string][]QuotaUsageSample
+	if err := st.Get("quota-usage-history", &history); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	return history, nil
+}
+
+// QuotaUsageHistory returns the recorded resource usage samples of the given
+// quota group, ordered from the oldest to the newest sample.
+func QuotaUsageHistory(st *state.State, name string) ([]QuotaUsageSample, error) {
+	history, err := quotaUsageHistory(st)
+	if err != nil {
+		return nil, err
+	}
+	return history[name], nil
+}
+
+// ensureQuotaUsageSampled samples the resource usage of all quota groups once
+// every quotaUsageSampleInterval and appends the samples to the history of
+// each group, only the last quotaUsageHistoryMax samples are kept.
+func (m *ServiceManager) ensureQuotaUsageSampled() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	now := timeNow()
+	if now.Sub(m.lastQuotaUsageSample) < quotaUsageSampleInterval {
+		return nil
+	}
+	m.lastQuotaUsageSample = now
+
+	allGrps, err := AllQuotas(m.state)
+	if err != nil {
+		return err
+	}
+
+	// querying systemd for the usage of each group is slow, so don't
+	// hold the state lock meanwhile
+	samples := make(map[string]QuotaUsageSample, len(allGrps))
+	m.state.Unlock()
+	for name, grp := range allGrps {
+		sample, err := quotaGroupUsage(grp)
+		if err != nil {
+			logger.Noticef("cannot sample resource usage of quota group %q: %v", name, err)
+			continue
+		}
+		sample.Time = now
+		samples[name] = *sample
+	}
+	m.state.Lock()
+
+	// groups may have been created or removed while the state was unlocked
+	allGrps, err = AllQuotas(m.state)
+	if err != nil {
+		return err
+	}
+	history, err := quotaUsageHistory(m.state)
+	if err != nil {
+		return err
+	}
+	if len(allGrps) == 0 && len(history) == 0 {
+		return nil
+	}
+
+	newHistory := make(map[string][]QuotaUsageSample, len(allGrps))
+	for name := range allGrps {
+		grpHistory := history[name]
+		if sample, ok := samples[name]; ok {
+			grpHistory = append(grpHistory, sample)
+		}
+		if len(grpHistory) > quotaUsageHistoryMax {
+			grpHistory = grpHistory[len(grpHistory)-quotaUsageHistoryMax:]
+		}
+		if len(grpHistory) != 0 {
+			newHistory[name] = grpHistory
+		}
+	}
+	m.state.Set("quota-usage-history", newHistory)
+	return nil
+}
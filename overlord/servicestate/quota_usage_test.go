@@ -0,0 +1,202 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate_test
+
+import (
+	"fmt"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/servicestate/servicestatetest"
+	"github.com/snapcore/snapd/snap/quota"
+)
+
+type quotaUsageSuite struct {
+	baseServiceMgrTestSuite
+
+	now time.Time
+}
+
+var _ = Suite(&quotaUsageSuite{})
+
+func (s *quotaUsageSuite) SetUpTest(c *C) {
+	s.baseServiceMgrTestSuite.SetUpTest(c)
+
+	// we don't need the EnsureSnapServices ensure loop to run by default
+	servicestate.MockEnsuredSnapServices(s.mgr, true)
+
+	s.now = time.Now()
+	s.AddCleanup(servicestate.MockTimeNow(func() time.Time { return s.now }))
+}
+
+func (s *quotaUsageSuite) advance(d time.Duration) {
+	s.now = s.now.Add(d)
+}
+
+func (s *quotaUsageSuite) TestEnsureSamplesQuotaUsage(c *C) {
+	st := s.state
+	st.Lock()
+	_, err := servicestatetest.PatchQuotas(st, &quota.Group{
+		Name:        "foo",
+		MemoryLimit: quantity.SizeGiB,
+	})
+	c.Assert(err, IsNil)
+	st.Unlock()
+
+	calls := 0
+	r := servicestate.MockQuotaGroupUsage(func(grp *quota.Group) (*servicestate.QuotaUsageSample, error) {
+		calls++
+		c.Check(grp.Name, Equals, "foo")
+		return &servicestate.QuotaUsageSample{
+			CPUTime: time.Duration(calls) * time.Second,
+			Memory:  quantity.Size(calls) * quantity.SizeMiB,
+			Threads: calls,
+		}, nil
+	})
+	defer r()
+
+	// no sample is taken before the interval elapsed since the manager
+	// was created
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(calls, Equals, 0)
+
+	s.advance(5 * time.Minute)
+	first := s.now
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(calls, Equals, 1)
+
+	// nothing happens until the next interval
+	s.advance(time.Minute)
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(calls, Equals, 1)
+
+	s.advance(4 * time.Minute)
+	second := s.now
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(calls, Equals, 2)
+
+	st.Lock()
+	defer st.Unlock()
+	history, err := servicestate.QuotaUsageHistory(st, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 2)
+	c.Check(history[0].Time.Equal(first), Equals, true)
+	c.Check(history[0].CPUTime, Equals, time.Second)
+	c.Check(history[0].Memory, Equals, quantity.SizeMiB)
+	c.Check(history[0].Threads, Equals, 1)
+	c.Check(history[1].Time.Equal(second), Equals, true)
+	c.Check(history[1].CPUTime, Equals, 2*time.Second)
+	c.Check(history[1].Memory, Equals, 2*quantity.SizeMiB)
+	c.Check(history[1].Threads, Equals, 2)
+
+	// no history for unknown groups
+	history, err = servicestate.QuotaUsageHistory(st, "bar")
+	c.Assert(err, IsNil)
+	c.Check(history, HasLen, 0)
+}
+
+func (s *quotaUsageSuite) TestEnsureQuotaUsageHistoryTrimmed(c *C) {
+	r := servicestate.MockQuotaUsageHistoryMax(3)
+	defer r()
+
+	st := s.state
+	st.Lock()
+	_, err := servicestatetest.PatchQuotas(st, &quota.Group{
+		Name:        "foo",
+		MemoryLimit: quantity.SizeGiB,
+	})
+	c.Assert(err, IsNil)
+	st.Unlock()
+
+	calls := 0
+	r = servicestate.MockQuotaGroupUsage(func(grp *quota.Group) (*servicestate.QuotaUsageSample, error) {
+		calls++
+		return &servicestate.QuotaUsageSample{Threads: calls}, nil
+	})
+	defer r()
+
+	for i := 0; i < 5; i++ {
+		s.advance(5 * time.Minute)
+		c.Assert(s.mgr.Ensure(), IsNil)
+	}
+	c.Check(calls, Equals, 5)
+
+	st.Lock()
+	defer st.Unlock()
+	history, err := servicestate.QuotaUsageHistory(st, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 3)
+	for i, sample := range history {
+		c.Check(sample.Threads, Equals, i+3)
+	}
+}
+
+func (s *quotaUsageSuite) TestEnsureQuotaUsageErrorsAndRemovedGroups(c *C) {
+	st := s.state
+	st.Lock()
+	_, err := servicestatetest.PatchQuotas(st,
+		&quota.Group{Name: "foo", MemoryLimit: quantity.SizeGiB},
+		&quota.Group{Name: "bar", MemoryLimit: quantity.SizeGiB},
+	)
+	c.Assert(err, IsNil)
+	st.Unlock()
+
+	r := servicestate.MockQuotaGroupUsage(func(grp *quota.Group) (*servicestate.QuotaUsageSample, error) {
+		if grp.Name == "bar" {
+			return nil, fmt.Errorf("boom")
+		}
+		return &servicestate.QuotaUsageSample{Threads: 1}, nil
+	})
+	defer r()
+	logbuf, r := logger.MockLogger()
+	defer r()
+
+	s.advance(5 * time.Minute)
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(logbuf.String(), Matches, `(?s).*cannot sample resource usage of quota group "bar": boom.*`)
+
+	st.Lock()
+	history, err := servicestate.QuotaUsageHistory(st, "foo")
+	c.Assert(err, IsNil)
+	c.Check(history, HasLen, 1)
+	history, err = servicestate.QuotaUsageHistory(st, "bar")
+	c.Assert(err, IsNil)
+	c.Check(history, HasLen, 0)
+
+	// remove the foo group, its history is dropped at the next sample
+	allGrps, err := servicestate.AllQuotas(st)
+	c.Assert(err, IsNil)
+	delete(allGrps, "foo")
+	st.Set("quotas", allGrps)
+	st.Unlock()
+
+	s.advance(5 * time.Minute)
+	c.Assert(s.mgr.Ensure(), IsNil)
+
+	st.Lock()
+	defer st.Unlock()
+	history, err = servicestate.QuotaUsageHistory(st, "foo")
+	c.Assert(err, IsNil)
+	c.Check(history, HasLen, 0)
+}
@@ -48,6 +48,11 @@ type ServiceManager struct {
 	state *state.State
 
 	ensuredSnapSvcs bool
+
+	lastQuotaUsageSample time.Time
+
+	lastQuotaLimitsCheck time.Time
+	quotaLimitCounters   map[string]*quotaLimitCounters
 }
 
 // Manager returns a new service manager.
@@ -55,6 +60,9 @@ func Manager(st *state.State, runner *state.TaskRunner) *ServiceManager {
 	delayedCrossMgrInit()
 	m := &ServiceManager{
 		state: st,
+		// the first sample of the quota usage is taken only after a full
+		// interval, to not slow down the startup of snapd
+		lastQuotaUsageSample: timeNow(),
 	}
 	// TODO: undo handler
 	runner.AddHandler("service-control", m.doServiceControl, nil)
@@ -73,6 +81,9 @@ func Manager(st *state.State, runner *state.TaskRunner) *ServiceManager {
 	// quota-add-snap uses snap-setup and because of this retrieving the snap
 	// that is being added is implicitly already supported by snapstate/conflict.go
 
+	runner.AddHandler("quota-limit-action", m.doQuotaLimitAction, nil)
+	AddAffectedQuotasByKind("quota-limit-action", affectedQuotasForQuotaLimitAction)
+
 	return m
 }
 
@@ -204,6 +215,12 @@ func (m *ServiceManager) Ensure() error {
 	if err := m.ensureSnapServicesUpdated(); err != nil {
 		return err
 	}
+	if err := m.ensureQuotaUsageSampled(); err != nil {
+		return err
+	}
+	if err := m.ensureQuotaLimitsMonitored(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -22,6 +22,7 @@ package backend
 import (
 	"archive/tar"
 	"archive/zip"
+	"bufio"
 	"bytes"
 	"context"
 	"crypto"
@@ -41,6 +42,8 @@ import (
 	"syscall"
 	"time"
 
+	"filippo.io/age"
+
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
@@ -51,9 +54,12 @@ import (
 )
 
 const (
-	archiveName  = "archive.tgz"
-	metadataName = "meta.json"
-	metaHashName = "meta.sha3_384"
+	// snapshots taken by older versions of snapd use tarballs, see
+	// chunks.go for the chunked archives used now
+	archiveName        = "archive.tgz"
+	chunkedArchiveName = "archive" + chunkedArchiveExt
+	metadataName       = "meta.json"
+	metaHashName       = "meta.sha3_384"
 
 	userArchivePrefix = "user/"
 	userArchiveSuffix = ".tgz"
@@ -326,6 +332,13 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 		return nil, err
 	}
 
+	// keep unused chunks from being removed while the snapshot is taken
+	lock, err := lockChunks(chunksDir(), false)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Close()
+
 	aw, err := osutil.NewAtomicFile(Filename(snapshot), 0600, 0, osutil.NoChown, osutil.NoChown)
 	if err != nil {
 		return nil, err
@@ -337,7 +350,7 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 	defer w.Close() // note this does not close the file descriptor (that's done by hand on the atomic writer, above)
 	savingUserData := false
 	baseDataDir := snap.BaseDataDir(si.InstanceName())
-	if err := addSnapDirToZip(ctx, snapshot, w, "root", archiveName, baseDataDir, savingUserData, snapshotOptions.ExcludePaths); err != nil {
+	if err := addSnapDirToZip(ctx, snapshot, w, "root", chunkedArchiveName, baseDataDir, savingUserData, snapshotOptions.ExcludePaths); err != nil {
 		return nil, err
 	}
 
@@ -438,19 +451,23 @@ func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writ
 
 // addToZip adds 'paths' to the snapshot. tar will change into the paths' parent
 // directory before creating the archive so that parent dirs are not added.
+// Entries with the chunked archive extension are added as chunked archives,
+// others as tarballs.
 func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry string, paths []string, excludePaths []string) error {
-	archiveWriter, err := w.CreateHeader(&zip.FileHeader{Name: entry})
-	if err != nil {
-		return err
-	}
+	chunked := isChunkedArchive(entry)
 
 	tarArgs := []string{
 		"--create",
-		"--sparse", "--gzip",
+		"--sparse",
+	}
+	if !chunked {
+		tarArgs = append(tarArgs, "--gzip")
+	}
+	tarArgs = append(tarArgs,
 		"--format", "gnu",
 		"--anchored",
 		"--no-wildcards-match-slash",
-	}
+	)
 
 	for _, path := range excludePaths {
 		tarArgs = append(tarArgs, fmt.Sprintf("--exclude=%s", path))
@@ -462,11 +479,37 @@ func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, use
 		tarArgs = append(tarArgs, "--directory", parent, dir)
 	}
 
+	runTar := func(stdout io.Writer) error {
+		return runTarAsUser(ctx, username, stdout, tarArgs)
+	}
+
+	if chunked {
+		return addChunkedToZip(snapshot, w, entry, runTar)
+	}
+
+	archiveWriter, err := w.CreateHeader(&zip.FileHeader{Name: entry})
+	if err != nil {
+		return err
+	}
+
 	var sz osutil.Sizer
 	hasher := crypto.SHA3_384.New()
 
+	if err := runTar(io.MultiWriter(archiveWriter, hasher, &sz)); err != nil {
+		return err
+	}
+
+	snapshot.SHA3_384[entry] = fmt.Sprintf("%x", hasher.Sum(nil))
+	snapshot.Size += sz.Size()
+
+	return nil
+}
+
+// runTarAsUser runs tar to create an archive as the given user, writing the
+// archive to stdout.
+func runTarAsUser(ctx context.Context, username string, stdout io.Writer, tarArgs []string) error {
 	cmd := tarAsUser(username, tarArgs...)
-	cmd.Stdout = io.MultiWriter(archiveWriter, hasher, &sz)
+	cmd.Stdout = stdout
 
 	// keep (at most) the last 5 non-empty lines of what 'tar' writes to stderr
 	// (those are the most likely contain the reason for fatal errors)
@@ -495,9 +538,6 @@ func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, use
 		return fmt.Errorf("tar failed: %v", err)
 	}
 
-	snapshot.SHA3_384[entry] = fmt.Sprintf("%x", hasher.Sum(nil))
-	snapshot.Size += sz.Size()
-
 	return nil
 }
 
@@ -722,6 +762,8 @@ type ImportFlags struct {
 	// noDuplicatedImportCheck tells import not to check for existing snapshot
 	// with same content hash (and not report DuplicatedSnapshotImportError).
 	NoDuplicatedImportCheck bool
+	// Identities are used to decrypt an encrypted import.
+	Identities []age.Identity
 }
 
 // Import a snapshot from the export file format
@@ -818,14 +860,28 @@ func checkDuplicatedSnapshotSetWithContentHash(ctx context.Context, contentHash
 func unpackVerifySnapshotImport(ctx context.Context, r io.Reader, realSetID uint64, flags *ImportFlags) (snapNames []string, err error) {
 	var exportFound bool
 
-	tr := tar.NewReader(r)
-	var tarErr error
-	var header *tar.Header
-
 	if flags == nil {
 		flags = &ImportFlags{}
 	}
 
+	r, err = maybeDecryptImport(r, flags.Identities)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	tr := tar.NewReader(r)
+	var tarErr error
+	var header *tar.Header
+	var chunksLock *osutil.FileLock
+	defer func() {
+		if chunksLock != nil {
+			chunksLock.Close()
+		}
+	}()
+
 	for tarErr == nil {
 		header, tarErr = tr.Next()
 		if tarErr == io.EOF {
@@ -865,6 +921,21 @@ func unpackVerifySnapshotImport(ctx context.Context, r io.Reader, realSetID uint
 			continue
 		}
 
+		// the chunks used by the snapshots come before them
+		if strings.HasPrefix(header.Name, chunksDirName+"/") {
+			if chunksLock == nil {
+				// keep the imported chunks from being removed
+				// before the snapshots using them are in place
+				if chunksLock, err = lockChunks(chunksDir(), false); err != nil {
+					return snapNames, err
+				}
+			}
+			if err := importChunk(chunksDir(), strings.TrimPrefix(header.Name, chunksDirName+"/"), tr); err != nil {
+				return snapNames, fmt.Errorf("cannot import snapshot chunk: %v", err)
+			}
+			continue
+		}
+
 		// Format of the snapshot import is:
 		//     $setID_.....
 		// But because the setID is local this will not be correct
@@ -901,6 +972,50 @@ func unpackVerifySnapshotImport(ctx context.Context, r io.Reader, realSetID uint
 	return snapNames, nil
 }
 
+// ageIntro is how files in the age format start
+const ageIntro = "age-encryption.org/v1\n"
+
+// maybeDecryptImport returns a reader of the decrypted import if r is
+// encrypted, and r itself otherwise.
+//
+// Only the end of the encrypted stream authenticates it as a whole, so the
+// import is decrypted into an unlinked temporary file first, and nothing of
+// it is handed out before that succeeded. The returned reader must be closed
+// in that case.
+func maybeDecryptImport(r io.Reader, identities []age.Identity) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	// errors are left to the actual reading of the import
+	prefix, _ := br.Peek(len(ageIntro))
+	if string(prefix) != ageIntro {
+		return br, nil
+	}
+	if len(identities) == 0 {
+		return nil, errors.New("snapshot import is encrypted, a key or passphrase is needed")
+	}
+	dr, err := age.Decrypt(br, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt snapshot import: %v", err)
+	}
+
+	f, err := ioutil.TempFile(dirs.SnapshotsDir, ".import-decrypted-")
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt snapshot import: %v", err)
+	}
+	if err := os.Remove(f.Name()); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cannot decrypt snapshot import: %v", err)
+	}
+	if _, err := io.Copy(f, dr); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cannot decrypt snapshot import: %v", err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cannot decrypt snapshot import: %v", err)
+	}
+	return f, nil
+}
+
 type exportMetadata struct {
 	Format int       `json:"format"`
 	Date   time.Time `json:"date"`
@@ -911,6 +1026,11 @@ type SnapshotExport struct {
 	// open snapshot files
 	snapshotFiles []*os.File
 
+	// chunks used by the snapshots, and the lock that keeps them in
+	// the chunk store while the export is open
+	chunks     []string
+	chunksLock *osutil.FileLock
+
 	// contentHash of the full snapshot
 	contentHash []byte
 
@@ -919,6 +1039,11 @@ type SnapshotExport struct {
 
 	// cached size, needs to be calculated with CalculateSize
 	size int64
+
+	// recipients the export is encrypted to, if it is encrypted, and
+	// the size of the age header for them
+	recipients       []age.Recipient
+	encryptionHeader int64
 }
 
 // NewSnapshotExport will return a SnapshotExport structure. It must be
@@ -926,6 +1051,13 @@ type SnapshotExport struct {
 func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, err error) {
 	var snapshotFiles []*os.File
 	var snapshotSet client.SnapshotSet
+	var chunks []string
+	seenChunks := make(map[string]bool)
+
+	lock, err := lockChunks(chunksDir(), false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot export snapshot %v: %v", setID, err)
+	}
 
 	defer func() {
 		// cleanup any open FDs if anything goes wrong
@@ -933,6 +1065,7 @@ func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, e
 			for _, f := range snapshotFiles {
 				f.Close()
 			}
+			lock.Close()
 		}
 	}()
 
@@ -958,6 +1091,22 @@ func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, e
 				return fmt.Errorf("cannot open file from descriptor %d", fd)
 			}
 			snapshotFiles = append(snapshotFiles, f)
+
+			for entry := range reader.SHA3_384 {
+				if !isChunkedArchive(entry) {
+					continue
+				}
+				archive, err := reader.chunkedArchive(entry)
+				if err != nil {
+					return err
+				}
+				for _, chunk := range archive.chunks() {
+					if !seenChunks[chunk] {
+						seenChunks[chunk] = true
+						chunks = append(chunks, chunk)
+					}
+				}
+			}
 		}
 		return nil
 	})
@@ -972,7 +1121,13 @@ func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, e
 	if err != nil {
 		return nil, fmt.Errorf("cannot calculate content hash for snapshot export %v: %v", setID, err)
 	}
-	se = &SnapshotExport{snapshotFiles: snapshotFiles, setID: setID, contentHash: h}
+	se = &SnapshotExport{
+		snapshotFiles: snapshotFiles,
+		chunks:        chunks,
+		chunksLock:    lock,
+		setID:         setID,
+		contentHash:   h,
+	}
 
 	// ensure we never leak FDs even if the user does not call close
 	runtime.SetFinalizer(se, (*SnapshotExport).Close)
@@ -993,13 +1148,48 @@ func (se *SnapshotExport) Init() error {
 	// to the client to a time after the year 2242. This is unlikely
 	// but a known issue with this approach here.
 	var sz osutil.Sizer
-	if err := se.StreamTo(&sz); err != nil {
+	if err := se.streamTo(&sz); err != nil {
 		return fmt.Errorf("cannot calculcate the size for %v: %s", se.setID, err)
 	}
 	se.size = sz.Size()
+	if len(se.recipients) > 0 {
+		se.size = se.encryptionHeader + encryptedPayloadSize(se.size)
+	}
+	return nil
+}
+
+// EncryptTo makes the export encrypted to the given recipients. It must be
+// called before Init. Every stream of the export is encrypted with its own
+// file key; wrapping it for a passphrase is slow on purpose, so this and
+// StreamTo should be called without any locks.
+func (se *SnapshotExport) EncryptTo(recipients ...age.Recipient) error {
+	// the size of the header does not depend on the file key, so
+	// measure it with a throwaway one
+	var sz osutil.Sizer
+	if _, err := age.Encrypt(&sz, recipients...); err != nil {
+		return fmt.Errorf("cannot encrypt snapshot export %v: %v", se.setID, err)
+	}
+	se.recipients = recipients
+	se.encryptionHeader = sz.Size()
 	return nil
 }
 
+const (
+	// size of the chunks of the age payload, and of their tag
+	ageChunkSize = 64 * 1024
+	ageTagSize   = 16
+)
+
+// encryptedPayloadSize returns the size of the age payload for size bytes
+// of data, the last chunk of which can be empty but is always there.
+func encryptedPayloadSize(size int64) int64 {
+	chunks := (size + ageChunkSize - 1) / ageChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+	return size + chunks*ageTagSize
+}
+
 func (se *SnapshotExport) Size() int64 {
 	return se.size
 }
@@ -1009,13 +1199,32 @@ func (se *SnapshotExport) Close() {
 		f.Close()
 	}
 	se.snapshotFiles = nil
+	if se.chunksLock != nil {
+		se.chunksLock.Close()
+		se.chunksLock = nil
+	}
 }
 
 type contentJSON struct {
 	ContentHash []byte `json:"content-hash"`
 }
 
+// StreamTo writes the export to w, encrypted if EncryptTo was called.
 func (se *SnapshotExport) StreamTo(w io.Writer) error {
+	if len(se.recipients) == 0 {
+		return se.streamTo(w)
+	}
+	ew, err := age.Encrypt(w, se.recipients...)
+	if err != nil {
+		return fmt.Errorf("cannot encrypt snapshot export %v: %v", se.setID, err)
+	}
+	if err := se.streamTo(ew); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+func (se *SnapshotExport) streamTo(w io.Writer) error {
 	// write out a tar
 	var files []string
 	tw := tar.NewWriter(w)
@@ -1040,6 +1249,14 @@ func (se *SnapshotExport) StreamTo(w io.Writer) error {
 		return err
 	}
 
+	// write out the chunks used by the snapshots, before the snapshots
+	// so that these can be checked as they are imported
+	for _, chunk := range se.chunks {
+		if err := streamChunkTo(tw, chunksDir(), chunk); err != nil {
+			return err
+		}
+	}
+
 	// write out the individual snapshots
 	for _, snapshotFile := range se.snapshotFiles {
 		stat, err := snapshotFile.Stat()
@@ -1069,8 +1286,12 @@ func (se *SnapshotExport) StreamTo(w io.Writer) error {
 
 	// write the metadata last, then the client can use that to
 	// validate the archive is complete
+	format := 1
+	if len(se.chunks) > 0 {
+		format = 2
+	}
 	meta := exportMetadata{
-		Format: 1,
+		Format: format,
 		Date:   timeNow(),
 		Files:  files,
 	}
@@ -41,6 +41,7 @@ import (
 	"testing"
 	"time"
 
+	"filippo.io/age"
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/client"
@@ -457,7 +458,7 @@ func (s *snapshotSuite) TestIterSetIDoverride(c *check.C) {
 
 	snapshotPath := filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip")
 	c.Check(backend.Filename(shw), check.Equals, snapshotPath)
-	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tgz", "user/snapuser.tgz"})
+	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.chunks", "user/snapuser.chunks"})
 
 	// rename the snapshot, verify that set id from the filename is used by the reader.
 	c.Assert(os.Rename(snapshotPath, filepath.Join(dirs.SnapshotsDir, "33_hello.zip")), check.IsNil)
@@ -731,7 +732,7 @@ func (s *snapshotSuite) testHappyRoundtrip(c *check.C, marker string) {
 	c.Check(shw.Conf, check.DeepEquals, cfg)
 	c.Check(shw.Auto, check.Equals, false)
 	c.Check(backend.Filename(shw), check.Equals, filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip"))
-	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tgz", "user/snapuser.tgz"})
+	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.chunks", "user/snapuser.chunks"})
 
 	shs, err := backend.List(context.TODO(), 0, nil)
 	c.Assert(err, check.IsNil)
@@ -762,7 +763,7 @@ func (s *snapshotSuite) testHappyRoundtrip(c *check.C, marker string) {
 	dirs.SetRootDir(newroot)
 
 	var diff = func() *exec.Cmd {
-		cmd := exec.Command("diff", "-urN", "-x*.zip", s.root, newroot)
+		cmd := exec.Command("diff", "-urN", "-x*.zip", "-xchunks", s.root, newroot)
 		// cmd.Stdout = os.Stdout
 		// cmd.Stderr = os.Stderr
 		return cmd
@@ -799,7 +800,7 @@ func (s *snapshotSuite) TestOpenSetIDoverride(c *check.C) {
 	c.Check(shw.SetID, check.Equals, uint64(12))
 
 	c.Check(backend.Filename(shw), check.Equals, filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip"))
-	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tgz", "user/snapuser.tgz"})
+	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.chunks", "user/snapuser.chunks"})
 
 	shr, err := backend.Open(backend.Filename(shw), 99)
 	c.Assert(err, check.IsNil)
@@ -842,7 +843,7 @@ func (s *snapshotSuite) TestRestoreRoundtripDifferentRevision(c *check.C) {
 	dirs.SetRootDir(newroot)
 
 	var diff = func() *exec.Cmd {
-		cmd := exec.Command("diff", "-urN", "-x*.zip", s.root, newroot)
+		cmd := exec.Command("diff", "-urN", "-x*.zip", "-xchunks", s.root, newroot)
 		// cmd.Stdout = os.Stdout
 		// cmd.Stderr = os.Stderr
 		return cmd
@@ -1118,7 +1119,7 @@ func (s *snapshotSuite) TestImportExportRoundtrip(c *check.C) {
 	c.Check(shw.SetID, check.Equals, shID)
 
 	c.Check(backend.Filename(shw), check.Equals, filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip"))
-	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tgz", "user/snapuser.tgz"})
+	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.chunks", "user/snapuser.chunks"})
 
 	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
 	c.Assert(err, check.IsNil)
@@ -1277,8 +1278,8 @@ func (s *snapshotSuite) TestExportTwice(c *check.C) {
 	_, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil)
 	c.Check(err, check.IsNil)
 
-	// content.json + num_files + export.json + footer
-	expectedSize := int64(1024 + 4*512 + 1024 + 2*512)
+	// content.json + chunks + num_files + export.json + footer
+	expectedSize := int64(1024 + 4*(512+512) + 6*512 + 1024 + 2*512)
 	// do on export at the start of the epoch
 	restore := backend.MockTimeNow(func() time.Time { return time.Time{} })
 	defer restore()
@@ -1314,6 +1315,162 @@ func (s *snapshotSuite) TestExportTwice(c *check.C) {
 	c.Check(buf.Len(), check.Equals, int(expectedSize))
 }
 
+func (s *snapshotSuite) encryptedExport(c *check.C, shID uint64, recipients ...age.Recipient) *bytes.Buffer {
+	se, err := backend.NewSnapshotExport(context.TODO(), shID)
+	c.Assert(err, check.IsNil)
+	defer se.Close()
+	c.Assert(se.EncryptTo(recipients...), check.IsNil)
+	c.Assert(se.Init(), check.IsNil)
+	buf := bytes.NewBuffer(nil)
+	c.Assert(se.StreamTo(buf), check.IsNil)
+	c.Check(buf.Len(), check.Equals, int(se.Size()))
+	c.Check(bytes.HasPrefix(buf.Bytes(), []byte("age-encryption.org/v1\n")), check.Equals, true)
+	return buf
+}
+
+func (s *snapshotSuite) TestEncryptedExportImportRoundtrip(c *check.C) {
+	s.mockPlainTar()
+
+	ctx := context.TODO()
+	shw, err := backend.Save(ctx, 12, helloSnapInfo(), nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	id, err := age.GenerateX25519Identity()
+	c.Assert(err, check.IsNil)
+	other, err := age.GenerateX25519Identity()
+	c.Assert(err, check.IsNil)
+
+	buf := s.encryptedExport(c, shw.SetID, other.Recipient(), id.Recipient())
+
+	// the content hash is checked on the decrypted export
+	_, err = backend.Import(ctx, 123, bytes.NewReader(buf.Bytes()), &backend.ImportFlags{Identities: []age.Identity{id}})
+	c.Check(err, check.DeepEquals, backend.DuplicatedSnapshotImportError{SetID: 12, SnapNames: []string{"hello-snap"}})
+
+	// import into a system without the snapshot nor its chunks
+	c.Assert(os.RemoveAll(dirs.SnapshotsDir), check.IsNil)
+	snapNames, err := backend.Import(ctx, 123, buf, &backend.ImportFlags{Identities: []age.Identity{id}})
+	c.Assert(err, check.IsNil)
+	c.Check(snapNames, check.DeepEquals, []string{"hello-snap"})
+
+	shr, err := backend.Open(filepath.Join(dirs.SnapshotsDir, "123_hello-snap_v1.33_42.zip"), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Check(ctx, nil), check.IsNil)
+}
+
+func (s *snapshotSuite) TestEncryptedExportPassphrase(c *check.C) {
+	s.mockPlainTar()
+
+	ctx := context.TODO()
+	shw, err := backend.Save(ctx, 12, helloSnapInfo(), nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	r, err := age.NewScryptRecipient("sekrit")
+	c.Assert(err, check.IsNil)
+	r.SetWorkFactor(10)
+
+	buf := s.encryptedExport(c, shw.SetID, r)
+
+	wrong, err := age.NewScryptIdentity("guess")
+	c.Assert(err, check.IsNil)
+	_, err = backend.Import(ctx, 123, bytes.NewReader(buf.Bytes()), &backend.ImportFlags{Identities: []age.Identity{wrong}})
+	c.Check(err, check.ErrorMatches, "cannot import snapshot 123: cannot decrypt snapshot import: no identity matched any of the recipients")
+
+	id, err := age.NewScryptIdentity("sekrit")
+	c.Assert(err, check.IsNil)
+	snapNames, err := backend.Import(ctx, 124, buf, &backend.ImportFlags{Identities: []age.Identity{id}, NoDuplicatedImportCheck: true})
+	c.Assert(err, check.IsNil)
+	c.Check(snapNames, check.DeepEquals, []string{"hello-snap"})
+}
+
+func (s *snapshotSuite) TestImportEncryptedUnhappy(c *check.C) {
+	s.mockPlainTar()
+
+	ctx := context.TODO()
+	shw, err := backend.Save(ctx, 12, helloSnapInfo(), nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	id, err := age.GenerateX25519Identity()
+	c.Assert(err, check.IsNil)
+
+	buf := s.encryptedExport(c, shw.SetID, id.Recipient())
+
+	_, err = backend.Import(ctx, 123, bytes.NewReader(buf.Bytes()), nil)
+	c.Check(err, check.ErrorMatches, "cannot import snapshot 123: snapshot import is encrypted, a key or passphrase is needed")
+
+	flags := &backend.ImportFlags{Identities: []age.Identity{id}, NoDuplicatedImportCheck: true}
+	truncated := buf.Bytes()[:buf.Len()-1]
+	_, err = backend.Import(ctx, 124, bytes.NewReader(truncated), flags)
+	c.Check(err, check.ErrorMatches, "cannot import snapshot 124: cannot decrypt snapshot import: failed to decrypt and authenticate payload chunk")
+
+	// nothing was left behind
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapshotsDir, "12[34]_*"))
+	c.Assert(err, check.IsNil)
+	c.Check(matches, check.HasLen, 0)
+	matches, err = filepath.Glob(filepath.Join(dirs.SnapshotsDir, ".import-decrypted-*"))
+	c.Assert(err, check.IsNil)
+	c.Check(matches, check.HasLen, 0)
+}
+
+func (s *snapshotSuite) TestImportEncryptedNothingUnpackedBeforeAuthenticated(c *check.C) {
+	s.mockPlainTar()
+
+	ctx := context.TODO()
+	shw, err := backend.Save(ctx, 12, helloSnapInfo(), nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	id, err := age.GenerateX25519Identity()
+	c.Assert(err, check.IsNil)
+
+	buf := s.encryptedExport(c, shw.SetID, id.Recipient())
+	c.Assert(os.RemoveAll(dirs.SnapshotsDir), check.IsNil)
+
+	// flip a bit of the last chunk, everything before it decrypts fine
+	corrupted := append([]byte(nil), buf.Bytes()...)
+	corrupted[len(corrupted)-1] ^= 1
+	flags := &backend.ImportFlags{Identities: []age.Identity{id}}
+	_, err = backend.Import(ctx, 123, bytes.NewReader(corrupted), flags)
+	c.Check(err, check.ErrorMatches, "cannot import snapshot 123: cannot decrypt snapshot import: failed to decrypt and authenticate payload chunk")
+
+	// neither the chunks nor the snapshots were imported
+	c.Check(filepath.Join(dirs.SnapshotsDir, "chunks"), testutil.FileAbsent)
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapshotsDir, "123_*"))
+	c.Assert(err, check.IsNil)
+	c.Check(matches, check.HasLen, 0)
+}
+
+func (s *snapshotSuite) TestEncryptedExportFreshFileKeyPerStream(c *check.C) {
+	s.mockPlainTar()
+
+	ctx := context.TODO()
+	shw, err := backend.Save(ctx, 12, helloSnapInfo(), nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	id, err := age.GenerateX25519Identity()
+	c.Assert(err, check.IsNil)
+
+	se, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	c.Assert(err, check.IsNil)
+	defer se.Close()
+	c.Assert(se.EncryptTo(id.Recipient()), check.IsNil)
+	c.Assert(se.Init(), check.IsNil)
+
+	buf1 := bytes.NewBuffer(nil)
+	c.Assert(se.StreamTo(buf1), check.IsNil)
+	buf2 := bytes.NewBuffer(nil)
+	c.Assert(se.StreamTo(buf2), check.IsNil)
+	c.Check(buf1.Len(), check.Equals, int(se.Size()))
+	c.Check(buf2.Len(), check.Equals, int(se.Size()))
+
+	// each stream has its own header, with its own wrapped file key
+	hdr1 := buf1.Bytes()[:bytes.Index(buf1.Bytes(), []byte("\n---"))]
+	hdr2 := buf2.Bytes()[:bytes.Index(buf2.Bytes(), []byte("\n---"))]
+	c.Check(hdr1, check.Not(check.DeepEquals), hdr2)
+
+	// and both decrypt
+	for _, buf := range []*bytes.Buffer{buf1, buf2} {
+		r, err := age.Decrypt(buf, id)
+		c.Assert(err, check.IsNil)
+		_, err = io.Copy(ioutil.Discard, r)
+		c.Check(err, check.IsNil)
+	}
+}
+
 func (s *snapshotSuite) TestExportUnhappy(c *check.C) {
 	se, err := backend.NewSnapshotExport(context.Background(), 5)
 	c.Assert(err, check.ErrorMatches, "no snapshot data found for 5")
@@ -0,0 +1,604 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"syscall"
+	"time"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// Snapshots store the data of a snap as chunked archives: instead of a
+// tarball, the snapshot zip holds a manifest listing the files of the
+// archive, with the content of each regular file split into chunks that are
+// kept, compressed and addressed by their hash, in a chunk store under the
+// snapshots directory. Files that did not change between two snapshots are
+// thus stored only once.
+//
+// The archives of snapshots taken by older versions of snapd are tarballs,
+// and are still restored as such.
+
+const (
+	chunksDirName     = "chunks"
+	chunksLockName    = ".lock"
+	chunkedArchiveExt = ".chunks"
+
+	chunkedArchiveFormat = 1
+)
+
+var (
+	// chunkSize is the maximum size of the chunks the content of files is
+	// split into.
+	chunkSize = 4 * 1024 * 1024
+
+	chunkNameRegexp = regexp.MustCompile("^[0-9a-f]{96}$")
+
+	// ErrChunkStoreBusy is returned by CleanupUnusedChunks when another
+	// snapshot operation is using the chunk store.
+	ErrChunkStoreBusy = errors.New("snapshot chunk store is busy")
+)
+
+// chunkedArchive is the manifest stored in a snapshot in place of a tarball.
+type chunkedArchive struct {
+	Format  int             `json:"format"`
+	Entries []*chunkedEntry `json:"entries"`
+}
+
+// chunkedEntry is a member of a chunked archive, it carries the tar header
+// of the member and the chunks of its content.
+type chunkedEntry struct {
+	Typeflag   byte              `json:"type"`
+	Name       string            `json:"name"`
+	Linkname   string            `json:"linkname,omitempty"`
+	Size       int64             `json:"size,omitempty"`
+	Mode       int64             `json:"mode"`
+	Uid        int               `json:"uid"`
+	Gid        int               `json:"gid"`
+	Uname      string            `json:"uname,omitempty"`
+	Gname      string            `json:"gname,omitempty"`
+	ModTime    time.Time         `json:"mtime"`
+	Devmajor   int64             `json:"devmajor,omitempty"`
+	Devminor   int64             `json:"devminor,omitempty"`
+	PAXRecords map[string]string `json:"pax-records,omitempty"`
+	Chunks     []string          `json:"chunks,omitempty"`
+}
+
+func newChunkedEntry(hdr *tar.Header) *chunkedEntry {
+	typeflag := hdr.Typeflag
+	if typeflag == tar.TypeGNUSparse {
+		// the content of sparse files is read back with the holes
+		// filled in, so they are stored as regular files
+		typeflag = tar.TypeReg
+	}
+	return &chunkedEntry{
+		Typeflag:   typeflag,
+		Name:       hdr.Name,
+		Linkname:   hdr.Linkname,
+		Size:       hdr.Size,
+		Mode:       hdr.Mode,
+		Uid:        hdr.Uid,
+		Gid:        hdr.Gid,
+		Uname:      hdr.Uname,
+		Gname:      hdr.Gname,
+		ModTime:    hdr.ModTime,
+		Devmajor:   hdr.Devmajor,
+		Devminor:   hdr.Devminor,
+		PAXRecords: hdr.PAXRecords,
+	}
+}
+
+func (e *chunkedEntry) header() *tar.Header {
+	return &tar.Header{
+		Typeflag:   e.Typeflag,
+		Name:       e.Name,
+		Linkname:   e.Linkname,
+		Size:       e.Size,
+		Mode:       e.Mode,
+		Uid:        e.Uid,
+		Gid:        e.Gid,
+		Uname:      e.Uname,
+		Gname:      e.Gname,
+		ModTime:    e.ModTime,
+		Devmajor:   e.Devmajor,
+		Devminor:   e.Devminor,
+		PAXRecords: e.PAXRecords,
+	}
+}
+
+func isChunkedArchive(entry string) bool {
+	return filepath.Ext(entry) == chunkedArchiveExt
+}
+
+// chunks returns the unique chunks referenced by the archive.
+func (a *chunkedArchive) chunks() []string {
+	seen := make(map[string]bool)
+	var chunks []string
+	for _, e := range a.Entries {
+		for _, chunk := range e.Chunks {
+			if !seen[chunk] {
+				seen[chunk] = true
+				chunks = append(chunks, chunk)
+			}
+		}
+	}
+	return chunks
+}
+
+// chunksDir returns the chunk store of the snapshots in the snapshots
+// directory.
+func chunksDir() string {
+	return filepath.Join(dirs.SnapshotsDir, chunksDirName)
+}
+
+func chunkPath(dir, chunk string) string {
+	return filepath.Join(dir, chunk[:2], chunk)
+}
+
+// lockChunks takes a lock on the chunk store in dir, shared unless exclusive
+// is set, in which case the lock is only tried for. The lock must be closed
+// by the caller.
+func lockChunks(dir string, exclusive bool) (*osutil.FileLock, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	lock, err := osutil.NewFileLock(filepath.Join(dir, chunksLockName))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open snapshot chunk store lock: %v", err)
+	}
+	if exclusive {
+		err = lock.TryLock()
+		if err == osutil.ErrAlreadyLocked {
+			err = ErrChunkStoreBusy
+		}
+	} else {
+		err = lock.ReadLock()
+	}
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return lock, nil
+}
+
+func chunkHash(data []byte) string {
+	hasher := crypto.SHA3_384.New()
+	hasher.Write(data)
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// writeChunk adds the given data to the chunk store in dir, unless it is
+// there already. It returns the name of the chunk, and the size it takes in
+// the store.
+func writeChunk(dir string, data []byte) (chunk string, size int64, err error) {
+	chunk = chunkHash(data)
+	p := chunkPath(dir, chunk)
+	if fi, err := os.Stat(p); err == nil {
+		return chunk, fi.Size(), nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return "", 0, err
+	}
+
+	aw, err := osutil.NewAtomicFile(p, 0600, 0, osutil.NoChown, osutil.NoChown)
+	if err != nil {
+		return "", 0, err
+	}
+	// if things worked, we'll commit (and Cancel becomes a NOP)
+	defer aw.Cancel()
+
+	var sz osutil.Sizer
+	gz := gzip.NewWriter(io.MultiWriter(aw, &sz))
+	if _, err := gz.Write(data); err != nil {
+		return "", 0, err
+	}
+	if err := gz.Close(); err != nil {
+		return "", 0, err
+	}
+	if err := aw.Commit(); err != nil {
+		return "", 0, err
+	}
+	return chunk, sz.Size(), nil
+}
+
+// decompressChunk decompresses the stored data of a chunk, and checks that
+// it matches the name of the chunk.
+func decompressChunk(chunk string, r io.Reader) ([]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snapshot chunk %.7s…: %v", chunk, err)
+	}
+	defer gz.Close()
+	// chunks are never bigger than chunkSize, but don't trust that
+	data, err := ioutil.ReadAll(io.LimitReader(gz, int64(chunkSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snapshot chunk %.7s…: %v", chunk, err)
+	}
+	if len(data) > chunkSize {
+		return nil, fmt.Errorf("snapshot chunk %.7s… is too big", chunk)
+	}
+	if actual := chunkHash(data); actual != chunk {
+		return nil, fmt.Errorf("snapshot chunk %.7s… does not match its hash (%.7s…)", chunk, actual)
+	}
+	return data, nil
+}
+
+// readChunk returns the data of the given chunk from the chunk store in dir.
+func readChunk(dir, chunk string) ([]byte, error) {
+	f, err := os.Open(chunkPath(dir, chunk))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("snapshot chunk %.7s… is missing", chunk)
+		}
+		return nil, err
+	}
+	defer f.Close()
+	return decompressChunk(chunk, f)
+}
+
+// importChunk adds a chunk read from r, as stored in a chunk store, to the
+// chunk store in dir.
+func importChunk(dir, chunk string, r io.Reader) error {
+	if !chunkNameRegexp.MatchString(chunk) {
+		return fmt.Errorf("invalid snapshot chunk name %q", chunk)
+	}
+	// the stored data is compressed, chunks that do not compress end up
+	// only a little bigger than chunkSize
+	stored, err := ioutil.ReadAll(io.LimitReader(r, 2*int64(chunkSize)))
+	if err != nil {
+		return err
+	}
+	if _, err := decompressChunk(chunk, bytes.NewReader(stored)); err != nil {
+		return err
+	}
+	p := chunkPath(dir, chunk)
+	if osutil.FileExists(p) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(p, stored, 0600, 0)
+}
+
+// streamChunkTo writes the given chunk, as stored in the chunk store in dir,
+// as a member of the snapshot export tar.
+func streamChunkTo(tw *tar.Writer, dir, chunk string) error {
+	f, err := os.Open(chunkPath(dir, chunk))
+	if err != nil {
+		return fmt.Errorf("cannot export snapshot chunk %.7s…: %v", chunk, err)
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     chunksDirName + "/" + chunk,
+		Size:     stat.Size(),
+		Mode:     0600,
+		ModTime:  stat.ModTime(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("cannot write header for snapshot chunk %.7s…: %v", chunk, err)
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("cannot write data for snapshot chunk %.7s…: %v", chunk, err)
+	}
+	return nil
+}
+
+// addChunkedToZip adds the tar archive created by runTar to the snapshot as a
+// chunked archive.
+func addChunkedToZip(snapshot *client.Snapshot, w *zip.Writer, entry string, runTar func(stdout io.Writer) error) error {
+	archive := &chunkedArchive{Format: chunkedArchiveFormat}
+	var storedSize int64
+
+	// split the members of the tar stream as it is being created
+	pr, pw := io.Pipe()
+	splitErr := make(chan error, 1)
+	go func() {
+		seen := make(map[string]bool)
+		buf := make([]byte, chunkSize)
+		tr := tar.NewReader(pr)
+		err := func() error {
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				e := newChunkedEntry(hdr)
+				archive.Entries = append(archive.Entries, e)
+				if e.Typeflag != tar.TypeReg {
+					continue
+				}
+				for {
+					n, err := io.ReadFull(tr, buf)
+					if n > 0 {
+						chunk, size, err := writeChunk(chunksDir(), buf[:n])
+						if err != nil {
+							return err
+						}
+						e.Chunks = append(e.Chunks, chunk)
+						if !seen[chunk] {
+							seen[chunk] = true
+							storedSize += size
+						}
+					}
+					if err == io.EOF || err == io.ErrUnexpectedEOF {
+						break
+					}
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}()
+		if err == nil {
+			// consume the padding tar adds after the end of the archive
+			_, err = io.Copy(ioutil.Discard, pr)
+		}
+		// make tar fail rather than block if the stream is not consumed
+		// anymore
+		pr.CloseWithError(err)
+		splitErr <- err
+	}()
+
+	tarErr := runTar(pw)
+	pw.CloseWithError(tarErr)
+	if err := <-splitErr; err != nil && err != tarErr {
+		return fmt.Errorf("cannot store snapshot data: %v", err)
+	}
+	if tarErr != nil {
+		return tarErr
+	}
+
+	data, err := json.Marshal(archive)
+	if err != nil {
+		return err
+	}
+	archiveWriter, err := w.CreateHeader(&zip.FileHeader{Name: entry})
+	if err != nil {
+		return err
+	}
+	if _, err := archiveWriter.Write(data); err != nil {
+		return err
+	}
+
+	hasher := crypto.SHA3_384.New()
+	hasher.Write(data)
+	snapshot.SHA3_384[entry] = fmt.Sprintf("%x", hasher.Sum(nil))
+	snapshot.Size += int64(len(data)) + storedSize
+
+	return nil
+}
+
+// readChunkedArchive decodes the manifest of a chunked archive.
+func readChunkedArchive(r io.Reader) (*chunkedArchive, error) {
+	var archive chunkedArchive
+	if err := json.NewDecoder(r).Decode(&archive); err != nil {
+		return nil, fmt.Errorf("cannot decode chunked archive: %v", err)
+	}
+	if archive.Format != chunkedArchiveFormat {
+		return nil, fmt.Errorf("unsupported chunked archive format %d", archive.Format)
+	}
+	return &archive, nil
+}
+
+// chunkedArchive returns the manifest of the given chunked archive entry of
+// the snapshot, after checking it against the hash of the entry.
+func (r *Reader) chunkedArchive(entry string) (*chunkedArchive, error) {
+	body, reportedSize, err := zipMember(r.File, entry)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != reportedSize {
+		return nil, fmt.Errorf("snapshot entry %q size (%d) different from actual (%d)", entry, reportedSize, len(data))
+	}
+	expectedHash := r.SHA3_384[entry]
+	if actualHash := chunkHash(data); actualHash != expectedHash {
+		return nil, fmt.Errorf("snapshot entry %q expected hash (%.7s…) does not match actual (%.7s…)", entry, expectedHash, actualHash)
+	}
+	return readChunkedArchive(bytes.NewReader(data))
+}
+
+// writeTar writes the archive, as a tar stream, to w. The chunks are read
+// from the chunk store in dir.
+func (a *chunkedArchive) writeTar(ctx context.Context, dir string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	for _, e := range a.Entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(e.header()); err != nil {
+			return err
+		}
+		for _, chunk := range e.Chunks {
+			data, err := readChunk(dir, chunk)
+			if err != nil {
+				return err
+			}
+			if _, err := tw.Write(data); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+// checkChunks checks that all the chunks used by the archive are present in
+// the chunk store in dir and match their hash.
+func (a *chunkedArchive) checkChunks(ctx context.Context, dir string) error {
+	for _, chunk := range a.chunks() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := readChunk(dir, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CleanupUnusedChunks removes the chunks that are not used by any snapshot
+// from the chunk store. ErrChunkStoreBusy is returned if the chunk store is
+// being used by another snapshot operation, in which case nothing is
+// removed.
+func CleanupUnusedChunks(ctx context.Context) (removed int, err error) {
+	if !osutil.IsDirectory(chunksDir()) {
+		return 0, nil
+	}
+	lock, err := lockChunks(chunksDir(), true)
+	if err != nil {
+		return 0, err
+	}
+	defer lock.Close()
+
+	used := make(map[string]bool)
+	err = Iter(ctx, func(r *Reader) error {
+		if r.Broken != "" {
+			// the snapshot cannot be trusted as a whole, but its data may
+			// still be salvaged so keep the chunks it references
+			for _, chunk := range brokenSnapshotChunks(r) {
+				used[chunk] = true
+			}
+			return nil
+		}
+		for entry := range r.SHA3_384 {
+			if !isChunkedArchive(entry) {
+				continue
+			}
+			archive, err := r.chunkedArchive(entry)
+			if err != nil {
+				return fmt.Errorf("cannot read %q of snapshot %q: %v", entry, r.Name(), err)
+			}
+			for _, chunk := range archive.chunks() {
+				used[chunk] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	subdirs, err := ioutil.ReadDir(chunksDir())
+	if err != nil {
+		return 0, err
+	}
+	for _, subdir := range subdirs {
+		if !subdir.IsDir() {
+			continue
+		}
+		dir := filepath.Join(chunksDir(), subdir.Name())
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return removed, err
+		}
+		for _, fi := range files {
+			// this also removes leftovers of interrupted writes
+			if used[fi.Name()] {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, fi.Name())); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+		if len(files) > 0 {
+			// only removes the directory if it is now empty
+			if err := os.Remove(dir); err != nil && !isDirNotEmpty(err) {
+				logger.Debugf("Cannot remove snapshot chunk directory %q: %v.", dir, err)
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+// brokenSnapshotChunks returns the chunks referenced by the chunked archives
+// of a broken snapshot, as far as they can be read. Their hashes cannot be
+// checked as the metadata of the snapshot is not trusted.
+func brokenSnapshotChunks(r *Reader) []string {
+	if r.File == nil {
+		return nil
+	}
+	// the file of a broken snapshot has already been closed
+	arch, err := zip.OpenReader(r.Name())
+	if err != nil {
+		logger.Noticef("Cannot read broken snapshot %q: %v.", r.Name(), err)
+		return nil
+	}
+	defer arch.Close()
+	var chunks []string
+	for _, fh := range arch.File {
+		if !isChunkedArchive(fh.Name) {
+			continue
+		}
+		archive, err := func() (*chunkedArchive, error) {
+			body, err := fh.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer body.Close()
+			return readChunkedArchive(body)
+		}()
+		if err != nil {
+			logger.Noticef("Cannot read %q of broken snapshot %q: %v.", fh.Name, r.Name(), err)
+			continue
+		}
+		chunks = append(chunks, archive.chunks()...)
+	}
+	return chunks
+}
+
+func isDirNotEmpty(err error) bool {
+	// depending on the filesystem removing a non-empty directory fails
+	// with either ENOTEMPTY or EEXIST
+	return errors.Is(err, syscall.ENOTEMPTY) || errors.Is(err, syscall.EEXIST)
+}
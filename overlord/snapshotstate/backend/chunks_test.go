@@ -0,0 +1,419 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+// mockPlainTar makes the backend run tar directly instead of as the
+// snapshot's user, so that snapshots can be taken and restored as root.
+func (s *snapshotSuite) mockPlainTar() {
+	s.restore = append(s.restore, backend.MockTarAsUser(func(username string, args ...string) *exec.Cmd {
+		return exec.Command(s.tarPath, args...)
+	}))
+}
+
+func chunkFiles(c *check.C) []string {
+	var chunks []string
+	err := filepath.Walk(filepath.Join(dirs.SnapshotsDir, "chunks"), func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() && fi.Name() != ".lock" {
+			chunks = append(chunks, fi.Name())
+		}
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	return chunks
+}
+
+func helloSnapInfo() *snap.Info {
+	return &snap.Info{
+		SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"},
+		Version:  "v1.33",
+	}
+}
+
+func (s *snapshotSuite) TestChunkedRoundtrip(c *check.C) {
+	s.mockPlainTar()
+	defer backend.MockChunkSize(16)()
+
+	info := helloSnapInfo()
+	// a file spanning several chunks, some of them identical
+	big := strings.Repeat("0123456789abcdef", 4) + "tail"
+	c.Assert(ioutil.WriteFile(filepath.Join(info.DataDir(), "big"), []byte(big), 0600), check.IsNil)
+	c.Assert(os.Symlink("big", filepath.Join(info.DataDir(), "link")), check.IsNil)
+
+	shw, err := backend.Save(context.TODO(), 12, info, nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.chunks", "user/snapuser.chunks"})
+	// two chunks for each of the canaries, one for the repeated content
+	// of big and one for its tail
+	c.Check(chunkFiles(c), check.HasLen, 10)
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+
+	// mess with the data
+	c.Assert(ioutil.WriteFile(filepath.Join(info.DataDir(), "foo"), []byte("scribble\n"), 0644), check.IsNil)
+	c.Assert(os.Remove(filepath.Join(info.DataDir(), "big")), check.IsNil)
+	c.Assert(os.Remove(filepath.Join(info.DataDir(), "link")), check.IsNil)
+	c.Assert(os.RemoveAll(info.UserCommonDataDir(filepath.Join(dirs.GlobalRootDir, "home/snapuser"), nil)), check.IsNil)
+
+	rs, err := shr.Restore(context.TODO(), snap.R(0), nil, func(string, ...interface{}) {}, nil)
+	c.Assert(err, check.IsNil)
+	rs.Cleanup()
+
+	for _, t := range table(info, filepath.Join(dirs.GlobalRootDir, "home/snapuser")) {
+		c.Check(filepath.Join(t.dir, t.name), testutil.FileEquals, t.content)
+	}
+	c.Check(filepath.Join(info.DataDir(), "big"), testutil.FileEquals, big)
+	target, err := os.Readlink(filepath.Join(info.DataDir(), "link"))
+	c.Assert(err, check.IsNil)
+	c.Check(target, check.Equals, "big")
+}
+
+func (s *snapshotSuite) TestChunkedSaveDeduplicates(c *check.C) {
+	s.mockPlainTar()
+
+	info := helloSnapInfo()
+	shw1, err := backend.Save(context.TODO(), 12, info, nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	chunks := chunkFiles(c)
+	c.Check(chunks, check.HasLen, 4)
+
+	// unchanged data is not stored again
+	shw2, err := backend.Save(context.TODO(), 13, info, nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(chunkFiles(c), check.DeepEquals, chunks)
+	c.Check(shw2.SHA3_384, check.DeepEquals, shw1.SHA3_384)
+
+	// only the changed file gets a new chunk
+	c.Assert(ioutil.WriteFile(filepath.Join(info.DataDir(), "foo"), []byte("changed\n"), 0644), check.IsNil)
+	_, err = backend.Save(context.TODO(), 14, info, nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(chunkFiles(c), check.HasLen, 5)
+}
+
+func (s *snapshotSuite) TestChunkedCheckMissingChunk(c *check.C) {
+	s.mockPlainTar()
+
+	shw, err := backend.Save(context.TODO(), 12, helloSnapInfo(), nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	chunks := chunkFiles(c)
+	c.Assert(chunks, check.HasLen, 4)
+	c.Assert(os.Remove(filepath.Join(dirs.SnapshotsDir, "chunks", chunks[0][:2], chunks[0])), check.IsNil)
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Check(context.TODO(), nil), check.ErrorMatches, fmt.Sprintf(`snapshot entry ".*\.chunks": snapshot chunk %.7s… is missing`, chunks[0]))
+
+	_, err = shr.Restore(context.TODO(), snap.R(0), nil, func(string, ...interface{}) {}, nil)
+	c.Check(err, check.ErrorMatches, fmt.Sprintf(`snapshot ".*" entry ".*\.chunks": snapshot chunk %.7s… is missing`, chunks[0]))
+}
+
+func (s *snapshotSuite) TestChunkedCheckCorruptChunk(c *check.C) {
+	s.mockPlainTar()
+
+	shw, err := backend.Save(context.TODO(), 12, helloSnapInfo(), nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	chunks := chunkFiles(c)
+	c.Assert(chunks, check.HasLen, 4)
+	// replace the content of a chunk with that of another
+	data, err := ioutil.ReadFile(filepath.Join(dirs.SnapshotsDir, "chunks", chunks[1][:2], chunks[1]))
+	c.Assert(err, check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapshotsDir, "chunks", chunks[0][:2], chunks[0]), data, 0600), check.IsNil)
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Check(context.TODO(), nil), check.ErrorMatches, fmt.Sprintf(`snapshot entry ".*\.chunks": snapshot chunk %.7s… does not match its hash \(%.7s…\)`, chunks[0], chunks[1]))
+}
+
+func (s *snapshotSuite) TestCleanupUnusedChunks(c *check.C) {
+	s.mockPlainTar()
+
+	// nothing to do without a chunk store
+	removed, err := backend.CleanupUnusedChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, 0)
+
+	info := helloSnapInfo()
+	shw1, err := backend.Save(context.TODO(), 12, info, nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(info.DataDir(), "foo"), []byte("changed\n"), 0644), check.IsNil)
+	shw2, err := backend.Save(context.TODO(), 13, info, nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(chunkFiles(c), check.HasLen, 5)
+
+	// all chunks are in use
+	removed, err = backend.CleanupUnusedChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, 0)
+	c.Check(chunkFiles(c), check.HasLen, 5)
+
+	// the old content of foo is only used by the first snapshot
+	c.Assert(os.Remove(backend.Filename(shw1)), check.IsNil)
+	removed, err = backend.CleanupUnusedChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, 1)
+	c.Check(chunkFiles(c), check.HasLen, 4)
+
+	shr, err := backend.Open(backend.Filename(shw2), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+
+	c.Assert(os.Remove(backend.Filename(shw2)), check.IsNil)
+	removed, err = backend.CleanupUnusedChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, 4)
+	c.Check(chunkFiles(c), check.HasLen, 0)
+}
+
+// breakSnapshot rewrites the snapshot with a metadata hash that does not
+// match.
+func breakSnapshot(c *check.C, fn string) {
+	zr, err := zip.OpenReader(fn)
+	c.Assert(err, check.IsNil)
+	defer zr.Close()
+
+	buf := bytes.NewBuffer(nil)
+	zw := zip.NewWriter(buf)
+	for _, fh := range zr.File {
+		w, err := zw.Create(fh.Name)
+		c.Assert(err, check.IsNil)
+		if fh.Name == "meta.sha3_384" {
+			_, err = w.Write([]byte("0123456789\n"))
+			c.Assert(err, check.IsNil)
+			continue
+		}
+		r, err := fh.Open()
+		c.Assert(err, check.IsNil)
+		_, err = io.Copy(w, r)
+		r.Close()
+		c.Assert(err, check.IsNil)
+	}
+	c.Assert(zw.Close(), check.IsNil)
+	c.Assert(ioutil.WriteFile(fn, buf.Bytes(), 0600), check.IsNil)
+}
+
+func (s *snapshotSuite) TestCleanupUnusedChunksKeepsBrokenSnapshotChunks(c *check.C) {
+	s.mockPlainTar()
+
+	shw, err := backend.Save(context.TODO(), 12, helloSnapInfo(), nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(chunkFiles(c), check.HasLen, 4)
+
+	breakSnapshot(c, backend.Filename(shw))
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.ErrorMatches, "declared hash .* does not match actual .*")
+	c.Assert(shr.Broken, check.Not(check.Equals), "")
+	shr.Close()
+
+	removed, err := backend.CleanupUnusedChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, 0)
+	c.Check(chunkFiles(c), check.HasLen, 4)
+}
+
+func (s *snapshotSuite) TestCleanupUnusedChunksBusy(c *check.C) {
+	s.mockPlainTar()
+
+	_, err := backend.Save(context.TODO(), 12, helloSnapInfo(), nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+
+	lock, err := osutil.NewFileLock(filepath.Join(dirs.SnapshotsDir, "chunks", ".lock"))
+	c.Assert(err, check.IsNil)
+	defer lock.Close()
+	c.Assert(lock.ReadLock(), check.IsNil)
+
+	_, err = backend.CleanupUnusedChunks(context.TODO())
+	c.Check(err, check.Equals, backend.ErrChunkStoreBusy)
+}
+
+func (s *snapshotSuite) TestChunkedExportImportRoundtrip(c *check.C) {
+	s.mockPlainTar()
+
+	ctx := context.TODO()
+	shw, err := backend.Save(ctx, 12, helloSnapInfo(), nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	chunks := chunkFiles(c)
+
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	c.Assert(err, check.IsNil)
+	// exports keep the chunks they use from being removed
+	_, err = backend.CleanupUnusedChunks(ctx)
+	c.Check(err, check.Equals, backend.ErrChunkStoreBusy)
+	c.Assert(export.Init(), check.IsNil)
+	buf := bytes.NewBuffer(nil)
+	c.Assert(export.StreamTo(buf), check.IsNil)
+	c.Check(buf.Len(), check.Equals, int(export.Size()))
+	export.Close()
+
+	var names []string
+	var meta struct {
+		Format int `json:"format"`
+	}
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, check.IsNil)
+		names = append(names, hdr.Name)
+		if hdr.Name == "export.json" {
+			c.Assert(json.NewDecoder(tr).Decode(&meta), check.IsNil)
+		}
+	}
+	c.Check(meta.Format, check.Equals, 2)
+	c.Assert(names, check.HasLen, len(chunks)+3)
+	c.Check(names[0], check.Equals, "content.json")
+	for _, name := range names[1 : len(chunks)+1] {
+		c.Check(name, check.Matches, "chunks/[0-9a-f]{96}")
+	}
+	c.Check(names[len(chunks)+1:], check.DeepEquals, []string{"12_hello-snap_v1.33_42.zip", "export.json"})
+
+	// import into a system without the snapshot nor its chunks
+	c.Assert(os.RemoveAll(dirs.SnapshotsDir), check.IsNil)
+	snapNames, err := backend.Import(ctx, 123, buf, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(snapNames, check.DeepEquals, []string{"hello-snap"})
+	c.Check(chunkFiles(c), check.HasLen, len(chunks))
+
+	shr, err := backend.Open(filepath.Join(dirs.SnapshotsDir, "123_hello-snap_v1.33_42.zip"), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Check(ctx, nil), check.IsNil)
+}
+
+func (s *snapshotSuite) TestImportCorruptChunk(c *check.C) {
+	c.Assert(os.MkdirAll(dirs.SnapshotsDir, 0700), check.IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	tw := tar.NewWriter(buf)
+	data := []byte("not a chunk")
+	c.Assert(tw.WriteHeader(&tar.Header{
+		Name: "chunks/" + strings.Repeat("0", 96),
+		Mode: 0600,
+		Size: int64(len(data)),
+	}), check.IsNil)
+	_, err := tw.Write(data)
+	c.Assert(err, check.IsNil)
+	c.Assert(tw.Close(), check.IsNil)
+
+	_, err = backend.Import(context.TODO(), 123, buf, nil)
+	c.Check(err, check.ErrorMatches, `cannot import snapshot 123: cannot import snapshot chunk: cannot read snapshot chunk 0000000…: .*`)
+	c.Check(chunkFiles(c), check.HasLen, 0)
+}
+
+// writeTarballSnapshot writes a snapshot of the system data of the snap
+// using a tarball, as done by older versions of snapd.
+func writeTarballSnapshot(c *check.C, setID uint64, info *snap.Info) string {
+	snapshot := &client.Snapshot{
+		SetID:    setID,
+		Snap:     info.InstanceName(),
+		Revision: info.Revision,
+		Version:  info.Version,
+		Time:     time.Now(),
+		SHA3_384: make(map[string]string),
+	}
+
+	archive, err := exec.Command("tar", "--create", "--gzip",
+		"--directory", filepath.Dir(info.DataDir()), "common", info.Revision.String()).Output()
+	c.Assert(err, check.IsNil)
+	hasher := crypto.SHA3_384.New()
+	hasher.Write(archive)
+	snapshot.SHA3_384["archive.tgz"] = fmt.Sprintf("%x", hasher.Sum(nil))
+	snapshot.Size = int64(len(archive))
+
+	buf := bytes.NewBuffer(nil)
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create("archive.tgz")
+	c.Assert(err, check.IsNil)
+	_, err = w.Write(archive)
+	c.Assert(err, check.IsNil)
+
+	w, err = zw.Create("meta.json")
+	c.Assert(err, check.IsNil)
+	hasher = crypto.SHA3_384.New()
+	c.Assert(json.NewEncoder(io.MultiWriter(w, hasher)).Encode(snapshot), check.IsNil)
+	w, err = zw.Create("meta.sha3_384")
+	c.Assert(err, check.IsNil)
+	fmt.Fprintf(w, "%x\n", hasher.Sum(nil))
+	c.Assert(zw.Close(), check.IsNil)
+
+	c.Assert(os.MkdirAll(dirs.SnapshotsDir, 0700), check.IsNil)
+	fn := backend.Filename(snapshot)
+	c.Assert(ioutil.WriteFile(fn, buf.Bytes(), 0600), check.IsNil)
+	return fn
+}
+
+func (s *snapshotSuite) TestRestoreTarballSnapshot(c *check.C) {
+	s.mockPlainTar()
+
+	info := helloSnapInfo()
+	fn := writeTarballSnapshot(c, 12, info)
+
+	shr, err := backend.Open(fn, backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+
+	c.Assert(ioutil.WriteFile(filepath.Join(info.DataDir(), "foo"), []byte("scribble\n"), 0644), check.IsNil)
+
+	rs, err := shr.Restore(context.TODO(), snap.R(0), nil, func(string, ...interface{}) {}, nil)
+	c.Assert(err, check.IsNil)
+	rs.Cleanup()
+	c.Check(filepath.Join(info.DataDir(), "foo"), testutil.FileEquals, "versioned system canary\n")
+
+	// tarball snapshots do not use the chunk store
+	removed, err := backend.CleanupUnusedChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, 0)
+}
@@ -146,3 +146,9 @@ func MockFilepathGlob(new func(pattern string) (matches []string, err error)) (r
 func (se *SnapshotExport) ContentHash() []byte {
 	return se.contentHash
 }
+
+func MockChunkSize(size int) (restore func()) {
+	r := testutil.Backup(&chunkSize)
+	chunkSize = size
+	return r
+}
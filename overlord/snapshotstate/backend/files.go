@@ -0,0 +1,337 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/sys"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// CheckRestorePath checks that p can be given to RestorePaths: it must be
+// a clean relative path, within the directory it is relative to.
+func CheckRestorePath(p string) error {
+	if p == "" || path.IsAbs(p) || path.Clean(p) != p || p == "." || p == ".." || strings.HasPrefix(p, "../") {
+		return fmt.Errorf("invalid path to restore %q: must be a clean relative path, as listed in the snapshot", p)
+	}
+	return nil
+}
+
+// pathSelector selects the members of the archives of a snapshot that are
+// or are under one of the given paths, and remembers which of the paths were
+// found.
+type pathSelector struct {
+	paths []string
+	found map[string]bool
+}
+
+func newPathSelector(paths []string) (*pathSelector, error) {
+	for _, p := range paths {
+		if err := CheckRestorePath(p); err != nil {
+			return nil, err
+		}
+	}
+	return &pathSelector{paths: paths, found: make(map[string]bool)}, nil
+}
+
+func (sel *pathSelector) match(name string) bool {
+	name = strings.TrimSuffix(name, "/")
+	matched := false
+	for _, p := range sel.paths {
+		if name == p || strings.HasPrefix(name, p+"/") {
+			sel.found[p] = true
+			matched = true
+		}
+	}
+	return matched
+}
+
+// missing returns the paths that were not found in any archive.
+func (sel *pathSelector) missing() []string {
+	var missing []string
+	for _, p := range sel.paths {
+		if !sel.found[p] {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// filter returns the archive with only the members selected by sel.
+func (a *chunkedArchive) filter(sel *pathSelector) *chunkedArchive {
+	filtered := &chunkedArchive{Format: a.Format}
+	for _, e := range a.Entries {
+		if sel.match(e.Name) {
+			filtered.Entries = append(filtered.Entries, e)
+		}
+	}
+	return filtered
+}
+
+// filterTarball writes the members of the tarball read from r that are
+// selected by sel, as a tar stream, to w.
+func filterTarball(r io.Reader, w io.Writer, sel *pathSelector) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	tw := tar.NewWriter(w)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if !sel.match(hdr.Name) {
+			continue
+		}
+		if hdr.Typeflag == tar.TypeGNUSparse {
+			// the content is read back with the holes filled in
+			hdr.Typeflag = tar.TypeReg
+		}
+		// let the writer pick a format that can hold the header
+		hdr.Format = tar.FormatUnknown
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// restoreSelected moves the paths selected by sel from tempdir, where they
+// were extracted, to targetDir. Paths in the revision directory of the
+// snapshot, snapRevdir, are moved to the revision directory revdir.
+func restoreSelected(rs *RestoreState, sel *pathSelector, snapRevdir, revdir, tempdir, targetDir string, uid sys.UserID, gid sys.GroupID) error {
+	for _, p := range sel.paths {
+		rel := p
+		if first := strings.SplitN(p, "/", 2); first[0] == snapRevdir {
+			first[0] = revdir
+			rel = strings.Join(first, "/")
+		}
+		if _, err := os.Lstat(filepath.Join(tempdir, rel)); err != nil {
+			if os.IsNotExist(err) {
+				// not in this archive, or already moved as
+				// part of another path
+				continue
+			}
+			return err
+		}
+		if err := mkdirAllRecorded(rs, targetDir, filepath.Dir(rel), uid, gid); err != nil {
+			return err
+		}
+		if err := movePath(rs, rel, tempdir, targetDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mkdirAllRecorded creates the directory relDir under targetDir and its
+// missing parents, registering the topmost directory it creates in the
+// RestoreState. The restore runs as root in directories the snap and the user
+// can write to, so none of the existing directories under targetDir can be
+// a symlink.
+func mkdirAllRecorded(rs *RestoreState, targetDir, relDir string, uid sys.UserID, gid sys.GroupID) error {
+	if relDir == "." {
+		return nil
+	}
+	missing := ""
+	dir := targetDir
+	for _, name := range strings.Split(relDir, "/") {
+		dir = filepath.Join(dir, name)
+		fi, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			missing = dir
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("cannot restore snapshot through symlink %q", dir)
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("cannot restore snapshot into %q: not a directory", dir)
+		}
+	}
+	if missing == "" {
+		return nil
+	}
+	if err := osutil.MkdirAllChown(filepath.Join(targetDir, relDir), 0755, uid, gid); err != nil {
+		return err
+	}
+	rs.Created = append(rs.Created, missing)
+	return nil
+}
+
+// movePath moves the file, directory or symlink at the given path from the
+// sourceDir to the targetDir, moving aside what is in its place. Both are
+// registered in the RestoreState.
+func movePath(rs *RestoreState, p, sourceDir, targetDir string) error {
+	src := filepath.Join(sourceDir, p)
+	dst := filepath.Join(targetDir, p)
+	if _, err := os.Lstat(dst); err == nil {
+		rsfn := restoreStateFilename(dst)
+		if err := os.Rename(dst, rsfn); err != nil {
+			return err
+		}
+		rs.Moved = append(rs.Moved, rsfn)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Rename(src, dst); err != nil {
+		return err
+	}
+	rs.Created = append(rs.Created, dst)
+
+	return nil
+}
+
+// Files lists the files in the snapshot, limited to the data of the given
+// users if any. The system data comes first, followed by that of each user.
+// The archives are checked against their hashes as they are read, like
+// Check does.
+func (r *Reader) Files(ctx context.Context, usernames []string) ([]client.SnapshotFile, error) {
+	sort.Strings(usernames)
+
+	var entries []string
+	for entry := range r.SHA3_384 {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		ui, uj := isUserArchive(entries[i]), isUserArchive(entries[j])
+		if ui != uj {
+			return !ui
+		}
+		return entries[i] < entries[j]
+	})
+
+	var files []client.SnapshotFile
+	for _, entry := range entries {
+		var username string
+		if isUserArchive(entry) {
+			username = entryUsername(entry)
+			if len(usernames) > 0 && !strutil.SortedListContains(usernames, username) {
+				continue
+			}
+		} else if entry != archiveName && entry != chunkedArchiveName {
+			continue
+		}
+
+		headers, err := r.archiveHeaders(ctx, entry)
+		if err != nil {
+			return nil, err
+		}
+		for _, hdr := range headers {
+			f := client.SnapshotFile{
+				User:    username,
+				Path:    strings.TrimSuffix(hdr.Name, "/"),
+				Mode:    hdr.FileInfo().Mode(),
+				ModTime: hdr.ModTime,
+				Link:    hdr.Linkname,
+			}
+			if f.Mode.IsRegular() {
+				f.Size = hdr.Size
+			}
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// archiveHeaders returns the headers of the members of the given archive
+// entry of the snapshot.
+func (r *Reader) archiveHeaders(ctx context.Context, entry string) ([]*tar.Header, error) {
+	if isChunkedArchive(entry) {
+		archive, err := r.chunkedArchive(entry)
+		if err != nil {
+			return nil, err
+		}
+		headers := make([]*tar.Header, len(archive.Entries))
+		for i, e := range archive.Entries {
+			headers[i] = e.header()
+		}
+		return headers, nil
+	}
+
+	body, reportedSize, err := zipMember(r.File, entry)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var sz osutil.Sizer
+	hasher := crypto.SHA3_384.New()
+	tee := io.TeeReader(body, io.MultiWriter(osutil.ContextWriter(ctx), hasher, &sz))
+	gz, err := gzip.NewReader(tee)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot entry %q: %v", entry, err)
+	}
+	defer gz.Close()
+
+	var headers []*tar.Header
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("snapshot entry %q: %v", entry, err)
+		}
+		headers = append(headers, hdr)
+	}
+	// the whole entry is checked, not only what was needed
+	if _, err := io.Copy(ioutil.Discard, tee); err != nil {
+		return nil, err
+	}
+
+	if sz.Size() != reportedSize {
+		return nil, fmt.Errorf("snapshot entry %q size (%d) different from actual (%d)", entry, reportedSize, sz.Size())
+	}
+	expectedHash := r.SHA3_384[entry]
+	if actualHash := fmt.Sprintf("%x", hasher.Sum(nil)); actualHash != expectedHash {
+		return nil, fmt.Errorf("snapshot entry %q expected hash (%.7s…) does not match actual (%.7s…)", entry, expectedHash, actualHash)
+	}
+	return headers, nil
+}
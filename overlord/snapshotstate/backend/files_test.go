@@ -0,0 +1,263 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func nopLogf(string, ...interface{}) {}
+
+// filesByUser returns the paths of the files, keyed by the user they
+// belong to.
+func filesByUser(files []client.SnapshotFile) map[string][]string {
+	byUser := make(map[string][]string)
+	for _, f := range files {
+		byUser[f.User] = append(byUser[f.User], f.Path)
+	}
+	return byUser
+}
+
+func (s *snapshotSuite) TestCheckRestorePath(c *check.C) {
+	for _, p := range []string{"42", "42/foo", "common/a/b", "..foo"} {
+		c.Check(backend.CheckRestorePath(p), check.IsNil, check.Commentf("%q", p))
+	}
+	for _, p := range []string{"", ".", "..", "../foo", "/42/foo", "42/", "42//foo", "42/./foo", "42/../foo"} {
+		c.Check(backend.CheckRestorePath(p), check.ErrorMatches, `invalid path to restore ".*": must be a clean relative path, as listed in the snapshot`, check.Commentf("%q", p))
+	}
+}
+
+func (s *snapshotSuite) TestFilesChunked(c *check.C) {
+	s.mockPlainTar()
+
+	info := helloSnapInfo()
+	c.Assert(os.Symlink("foo", filepath.Join(info.DataDir(), "link")), check.IsNil)
+
+	shw, err := backend.Save(context.TODO(), 12, info, nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+
+	files, err := shr.Files(context.TODO(), nil)
+	c.Assert(err, check.IsNil)
+	byUser := filesByUser(files)
+	c.Check(byUser[""], testutil.DeepUnsortedMatches, []string{"common", "common/bar", "42", "42/foo", "42/link"})
+	c.Check(byUser["snapuser"], testutil.DeepUnsortedMatches, []string{"common", "common/ubar", "42", "42/ufoo"})
+	c.Check(byUser, check.HasLen, 2)
+	// the system data comes first
+	c.Check(files[0].User, check.Equals, "")
+
+	for _, f := range files {
+		switch f.Path {
+		case "42/foo":
+			if f.User == "" {
+				c.Check(f.Mode, check.Equals, os.FileMode(0644))
+				c.Check(f.Size, check.Equals, int64(len("versioned system canary\n")))
+			}
+		case "42/link":
+			c.Check(f.Mode&os.ModeSymlink, check.Not(check.Equals), os.FileMode(0))
+			c.Check(f.Link, check.Equals, "foo")
+			c.Check(f.Size, check.Equals, int64(0))
+		case "42":
+			c.Check(f.Mode.IsDir(), check.Equals, true)
+		}
+	}
+
+	files, err = shr.Files(context.TODO(), []string{"someone-else"})
+	c.Assert(err, check.IsNil)
+	byUser = filesByUser(files)
+	c.Check(byUser, check.HasLen, 1)
+	c.Check(byUser[""], check.HasLen, 5)
+}
+
+func (s *snapshotSuite) TestFilesTarball(c *check.C) {
+	info := helloSnapInfo()
+	fn := writeTarballSnapshot(c, 12, info)
+
+	shr, err := backend.Open(fn, backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+
+	files, err := shr.Files(context.TODO(), nil)
+	c.Assert(err, check.IsNil)
+	c.Check(filesByUser(files), check.DeepEquals, map[string][]string{
+		"": {"common", "common/bar", "42", "42/foo"},
+	})
+
+	// the listing is checked like Check does
+	shr.SHA3_384["archive.tgz"] = "deadbeef"
+	_, err = shr.Files(context.TODO(), nil)
+	c.Check(err, check.ErrorMatches, `snapshot entry "archive.tgz" expected hash \(deadbee…\) does not match actual \(.*\)`)
+}
+
+func (s *snapshotSuite) TestRestorePathsChunked(c *check.C) {
+	s.mockPlainTar()
+
+	info := helloSnapInfo()
+	homeDir := filepath.Join(dirs.GlobalRootDir, "home/snapuser")
+	shw, err := backend.Save(context.TODO(), 12, info, nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+
+	// mess with the data
+	for _, t := range table(info, homeDir) {
+		c.Assert(ioutil.WriteFile(filepath.Join(t.dir, t.name), []byte("scribble\n"), 0644), check.IsNil)
+	}
+	c.Assert(ioutil.WriteFile(filepath.Join(info.DataDir(), "new"), []byte("new\n"), 0644), check.IsNil)
+
+	rs, err := shr.RestorePaths(context.TODO(), snap.R(0), nil, []string{"42/foo", "common/ubar"}, nopLogf, nil)
+	c.Assert(err, check.IsNil)
+
+	c.Check(filepath.Join(info.DataDir(), "foo"), testutil.FileEquals, "versioned system canary\n")
+	c.Check(filepath.Join(info.UserCommonDataDir(homeDir, nil), "ubar"), testutil.FileEquals, "common user canary\n")
+	// everything else is left alone
+	c.Check(filepath.Join(info.CommonDataDir(), "bar"), testutil.FileEquals, "scribble\n")
+	c.Check(filepath.Join(info.UserDataDir(homeDir, nil), "ufoo"), testutil.FileEquals, "scribble\n")
+	c.Check(filepath.Join(info.DataDir(), "new"), testutil.FileEquals, "new\n")
+
+	// and it can be undone
+	rs.Revert()
+	for _, t := range table(info, homeDir) {
+		c.Check(filepath.Join(t.dir, t.name), testutil.FileEquals, "scribble\n")
+	}
+	matches, err := filepath.Glob(filepath.Join(info.DataDir(), "*~"))
+	c.Assert(err, check.IsNil)
+	c.Check(matches, check.HasLen, 0)
+}
+
+func (s *snapshotSuite) TestRestorePathsDirIntoCurrentRevision(c *check.C) {
+	s.mockPlainTar()
+
+	info := helloSnapInfo()
+	homeDir := filepath.Join(dirs.GlobalRootDir, "home/snapuser")
+	shw, err := backend.Save(context.TODO(), 12, info, nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+
+	// the snap was refreshed, and its data lost
+	cur := snap.MinimalPlaceInfo("hello-snap", snap.R(17))
+	for _, dir := range []string{info.DataDir(), info.UserDataDir(homeDir, nil)} {
+		c.Assert(os.RemoveAll(dir), check.IsNil)
+	}
+	c.Assert(os.MkdirAll(cur.DataDir(), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(cur.DataDir(), "other"), []byte("other\n"), 0644), check.IsNil)
+
+	rs, err := shr.RestorePaths(context.TODO(), snap.R(17), []string{"snapuser"}, []string{"42"}, nopLogf, nil)
+	c.Assert(err, check.IsNil)
+	rs.Cleanup()
+
+	c.Check(filepath.Join(cur.DataDir(), "foo"), testutil.FileEquals, "versioned system canary\n")
+	c.Check(filepath.Join(cur.UserDataDir(homeDir, nil), "ufoo"), testutil.FileEquals, "versioned user canary\n")
+	// the directory as a whole was restored
+	c.Check(filepath.Join(cur.DataDir(), "other"), testutil.FileAbsent)
+	c.Check(info.DataDir(), testutil.FileAbsent)
+}
+
+func (s *snapshotSuite) TestRestorePathsTarball(c *check.C) {
+	s.mockPlainTar()
+
+	info := helloSnapInfo()
+	fn := writeTarballSnapshot(c, 12, info)
+	shr, err := backend.Open(fn, backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+
+	c.Assert(os.RemoveAll(info.DataDir()), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(info.CommonDataDir(), "bar"), []byte("scribble\n"), 0644), check.IsNil)
+
+	rs, err := shr.RestorePaths(context.TODO(), snap.R(0), nil, []string{"42/foo"}, nopLogf, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(filepath.Join(info.DataDir(), "foo"), testutil.FileEquals, "versioned system canary\n")
+	c.Check(filepath.Join(info.CommonDataDir(), "bar"), testutil.FileEquals, "scribble\n")
+
+	// the directories that had to be created are removed on revert
+	rs.Revert()
+	c.Check(info.DataDir(), testutil.FileAbsent)
+
+	// the entry is checked even if only the start of it is needed
+	shr.SHA3_384["archive.tgz"] = "deadbeef"
+	_, err = shr.RestorePaths(context.TODO(), snap.R(0), nil, []string{"common"}, nopLogf, nil)
+	c.Check(err, check.ErrorMatches, `.* expected hash \(deadbee…\) does not match actual \(.*\)`)
+	c.Check(filepath.Join(info.CommonDataDir(), "bar"), testutil.FileEquals, "scribble\n")
+}
+
+func (s *snapshotSuite) TestRestorePathsRefusesSymlinks(c *check.C) {
+	s.mockPlainTar()
+
+	info := helloSnapInfo()
+	shw, err := backend.Save(context.TODO(), 12, info, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+
+	// the revision directory is replaced with a symlink elsewhere
+	elsewhere := filepath.Join(dirs.GlobalRootDir, "elsewhere")
+	c.Assert(os.MkdirAll(elsewhere, 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(elsewhere, "foo"), []byte("precious\n"), 0644), check.IsNil)
+	c.Assert(os.RemoveAll(info.DataDir()), check.IsNil)
+	c.Assert(os.Symlink(elsewhere, info.DataDir()), check.IsNil)
+
+	_, err = shr.RestorePaths(context.TODO(), snap.R(0), nil, []string{"42/foo"}, nopLogf, nil)
+	c.Check(err, check.ErrorMatches, `cannot restore snapshot through symlink ".*/hello-snap/42"`)
+	c.Check(filepath.Join(elsewhere, "foo"), testutil.FileEquals, "precious\n")
+	matches, err := filepath.Glob(filepath.Join(elsewhere, "*"))
+	c.Assert(err, check.IsNil)
+	c.Check(matches, check.DeepEquals, []string{filepath.Join(elsewhere, "foo")})
+}
+
+func (s *snapshotSuite) TestRestorePathsMissing(c *check.C) {
+	s.mockPlainTar()
+
+	info := helloSnapInfo()
+	shw, err := backend.Save(context.TODO(), 12, info, nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+
+	c.Assert(ioutil.WriteFile(filepath.Join(info.DataDir(), "foo"), []byte("scribble\n"), 0644), check.IsNil)
+
+	_, err = shr.RestorePaths(context.TODO(), snap.R(0), nil, []string{"42/foo", "42/nope", "common/nope"}, nopLogf, nil)
+	c.Check(err, check.ErrorMatches, `cannot find "42/nope", "common/nope" in snapshot ".*/12_hello-snap_v1.33_42.zip"`)
+	// what was restored before noticing was reverted
+	c.Check(filepath.Join(info.DataDir(), "foo"), testutil.FileEquals, "scribble\n")
+
+	_, err = shr.RestorePaths(context.TODO(), snap.R(0), nil, []string{"../foo"}, nopLogf, nil)
+	c.Check(err, check.ErrorMatches, `invalid path to restore "../foo": .*`)
+	_, err = shr.RestorePaths(context.TODO(), snap.R(0), nil, nil, nopLogf, nil)
+	c.Check(err, check.ErrorMatches, `internal error: no paths to restore from snapshot .*`)
+}
@@ -65,16 +65,16 @@ func zipMember(f *os.File, member string) (r io.ReadCloser, sz int64, err error)
 }
 
 func userArchiveName(usr *user.User) string {
-	return filepath.Join(userArchivePrefix, usr.Username+userArchiveSuffix)
+	return filepath.Join(userArchivePrefix, usr.Username+chunkedArchiveExt)
 }
 
 func isUserArchive(entry string) bool {
-	return strings.HasPrefix(entry, userArchivePrefix) && strings.HasSuffix(entry, userArchiveSuffix)
+	return strings.HasPrefix(entry, userArchivePrefix) && (strings.HasSuffix(entry, userArchiveSuffix) || strings.HasSuffix(entry, chunkedArchiveExt))
 }
 
 func entryUsername(entry string) string {
 	// this _will_ panic if !isUserArchive(entry)
-	return entry[len(userArchivePrefix) : len(entry)-len(userArchiveSuffix)]
+	return entry[len(userArchivePrefix) : len(entry)-len(filepath.Ext(entry))]
 }
 
 type bySnap []*client.Snapshot
@@ -170,6 +170,12 @@ func (r *Reader) checkOne(ctx context.Context, entry string, hasher hash.Hash) e
 func (r *Reader) Check(ctx context.Context, usernames []string) error {
 	sort.Strings(usernames)
 
+	unlock, err := r.lockChunks()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	hasher := crypto.SHA3_384.New()
 	for entry := range r.SHA3_384 {
 		if len(usernames) > 0 && isUserArchive(entry) {
@@ -184,6 +190,16 @@ func (r *Reader) Check(ctx context.Context, usernames []string) error {
 			return err
 		}
 		hasher.Reset()
+
+		if isChunkedArchive(entry) {
+			archive, err := r.chunkedArchive(entry)
+			if err != nil {
+				return err
+			}
+			if err := archive.checkChunks(ctx, r.chunksDir()); err != nil {
+				return fmt.Errorf("snapshot entry %q: %v", entry, err)
+			}
+		}
 	}
 
 	return nil
@@ -198,6 +214,34 @@ type Logf func(format string, args ...interface{})
 // or the one in the snapshot) with that contained in the snapshot. It keeps
 // track of the old data in the task so it can be undone (or cleaned up).
 func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames []string, logf Logf, opts *dirs.SnapDirOptions) (rs *RestoreState, e error) {
+	return r.restore(ctx, current, usernames, nil, logf, opts)
+}
+
+// RestorePaths restores only the given paths from the snapshot, leaving the
+// rest of the existing data alone. The paths are relative to the directory
+// holding the revision and common data directories, as listed by Files,
+// e.g. "x1/config.json" or "common/cache". Directories are restored with
+// their content.
+//
+// Like Restore, it keeps track of the data it replaces so it can be undone
+// (or cleaned up).
+func (r *Reader) RestorePaths(ctx context.Context, current snap.Revision, usernames []string, paths []string, logf Logf, opts *dirs.SnapDirOptions) (rs *RestoreState, e error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("internal error: no paths to restore from snapshot %q", r.Name())
+	}
+	return r.restore(ctx, current, usernames, paths, logf, opts)
+}
+
+func (r *Reader) restore(ctx context.Context, current snap.Revision, usernames []string, paths []string, logf Logf, opts *dirs.SnapDirOptions) (rs *RestoreState, e error) {
+	var sel *pathSelector
+	if len(paths) > 0 {
+		var err error
+		sel, err = newPathSelector(paths)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	rs = &RestoreState{}
 	defer func() {
 		if e != nil {
@@ -218,6 +262,12 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 		curdir = current.String()
 	}
 
+	unlock, err := r.lockChunks()
+	if err != nil {
+		return rs, err
+	}
+	defer unlock()
+
 	for entry := range r.SHA3_384 {
 		if err := ctx.Err(); err != nil {
 			return rs, err
@@ -230,7 +280,7 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 		gid := sys.GroupID(osutil.NoChown)
 
 		if !isUser {
-			if entry != archiveName {
+			if entry != archiveName && entry != chunkedArchiveName {
 				// hmmm
 				logf("Skipping restore of unknown entry %q.", entry)
 				continue
@@ -311,72 +361,189 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 
 		logger.Debugf("Restoring %q from %q into %q.", entry, r.Name(), tempdir)
 
-		body, expectedSize, err := zipMember(r.File, entry)
+		if isChunkedArchive(entry) {
+			err = r.restoreChunked(ctx, entry, username, tempdir, sel)
+		} else {
+			err = r.restoreTarball(ctx, entry, username, tempdir, hasher, &sz, sel)
+		}
 		if err != nil {
 			return rs, err
 		}
 
-		expectedHash := r.SHA3_384[entry]
-
-		tr := io.TeeReader(body, io.MultiWriter(hasher, &sz))
-
-		// resist the temptation of using archive/tar unless it's proven
-		// that calling out to tar has issues -- there are a lot of
-		// special cases we'd need to consider otherwise
-		cmd := tarAsUser(username,
-			"--extract",
-			"--preserve-permissions", "--preserve-order", "--gunzip",
-			"--directory", tempdir)
-		cmd.Env = []string{}
-		cmd.Stdin = tr
-		matchCounter := &strutil.MatchCounter{N: 1}
-		cmd.Stderr = matchCounter
-		cmd.Stdout = os.Stderr
-		if isTesting {
-			matchCounter.N = -1
-			cmd.Stderr = io.MultiWriter(os.Stderr, matchCounter)
-		}
-
-		if err = osutil.RunWithContext(ctx, cmd); err != nil {
-			matches, count := matchCounter.Matches()
-			if count > 0 {
-				return rs, fmt.Errorf("cannot unpack archive: %s (and %d more)", matches[0], count-1)
-			}
-			return rs, fmt.Errorf("tar failed: %v", err)
-		}
-
-		if sz.Size() != expectedSize {
-			return rs, fmt.Errorf("snapshot %q entry %q expected size (%d) does not match actual (%d)",
-				r.Name(), entry, expectedSize, sz.Size())
-		}
-
-		if actualHash := fmt.Sprintf("%x", hasher.Sum(nil)); actualHash != expectedHash {
-			return rs, fmt.Errorf("snapshot %q entry %q expected hash (%.7s…) does not match actual (%.7s…)",
-				r.Name(), entry, expectedHash, actualHash)
-		}
-
+		snapRevdir := revdir
 		if curdir != "" && curdir != revdir {
 			// rename it in tempdir
 			// this is where we assume the current revision can read the snapshot revision's data
-			if err := os.Rename(filepath.Join(tempdir, revdir), filepath.Join(tempdir, curdir)); err != nil {
+			if err := os.Rename(filepath.Join(tempdir, revdir), filepath.Join(tempdir, curdir)); err != nil && !(sel != nil && os.IsNotExist(err)) {
 				return rs, err
 			}
 			revdir = curdir
 		}
 
-		for _, dir := range []string{"common", revdir} {
-			if err := moveFile(rs, dir, tempdir, parent); err != nil {
+		if sel != nil {
+			if err := restoreSelected(rs, sel, snapRevdir, revdir, tempdir, parent, uid, gid); err != nil {
 				return rs, err
 			}
+		} else {
+			for _, dir := range []string{"common", revdir} {
+				if err := moveFile(rs, dir, tempdir, parent); err != nil {
+					return rs, err
+				}
+			}
 		}
 
 		sz.Reset()
 		hasher.Reset()
 	}
 
+	if sel != nil {
+		if missing := sel.missing(); len(missing) > 0 {
+			return rs, fmt.Errorf("cannot find %s in snapshot %q", strutil.Quoted(missing), r.Name())
+		}
+	}
+
 	return rs, nil
 }
 
+// restoreTarball extracts the given tarball entry of the snapshot, or only
+// the paths selected by sel if not nil, into dir.
+func (r *Reader) restoreTarball(ctx context.Context, entry, username, dir string, hasher hash.Hash, sz *osutil.Sizer, sel *pathSelector) error {
+	body, expectedSize, err := zipMember(r.File, entry)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	expectedHash := r.SHA3_384[entry]
+
+	tr := io.TeeReader(body, io.MultiWriter(hasher, sz))
+	if sel == nil {
+		err = extractAsUser(ctx, username, tr, dir, "--gunzip")
+	} else {
+		err = extractSelected(ctx, username, dir, func(w io.Writer) error {
+			err := filterTarball(tr, w, sel)
+			if err != nil && err != io.ErrClosedPipe {
+				return fmt.Errorf("snapshot %q entry %q: %v", r.Name(), entry, err)
+			}
+			return err
+		})
+	}
+	if err != nil {
+		return err
+	}
+	// make sure the whole entry is checked even if the end of it was
+	// not needed
+	if _, err := io.Copy(ioutil.Discard, tr); err != nil {
+		return err
+	}
+
+	if sz.Size() != expectedSize {
+		return fmt.Errorf("snapshot %q entry %q expected size (%d) does not match actual (%d)",
+			r.Name(), entry, expectedSize, sz.Size())
+	}
+
+	if actualHash := fmt.Sprintf("%x", hasher.Sum(nil)); actualHash != expectedHash {
+		return fmt.Errorf("snapshot %q entry %q expected hash (%.7s…) does not match actual (%.7s…)",
+			r.Name(), entry, expectedHash, actualHash)
+	}
+
+	return nil
+}
+
+// restoreChunked extracts the given chunked archive entry of the snapshot,
+// or only the paths selected by sel if not nil, into dir.
+func (r *Reader) restoreChunked(ctx context.Context, entry, username, dir string, sel *pathSelector) error {
+	archive, err := r.chunkedArchive(entry)
+	if err != nil {
+		return fmt.Errorf("snapshot %q: %v", r.Name(), err)
+	}
+	if sel != nil {
+		archive = archive.filter(sel)
+	}
+
+	return extractSelected(ctx, username, dir, func(w io.Writer) error {
+		err := archive.writeTar(ctx, r.chunksDir(), w)
+		if err != nil && err != io.ErrClosedPipe {
+			return fmt.Errorf("snapshot %q entry %q: %v", r.Name(), entry, err)
+		}
+		return err
+	})
+}
+
+// extractSelected extracts the tar stream written by writeTar into dir.
+func extractSelected(ctx context.Context, username, dir string, writeTar func(w io.Writer) error) error {
+	pr, pw := io.Pipe()
+	writeErr := make(chan error, 1)
+	go func() {
+		err := writeTar(pw)
+		pw.CloseWithError(err)
+		writeErr <- err
+	}()
+
+	err := extractAsUser(ctx, username, pr, dir)
+	// unblock the writer if tar stopped reading early
+	pr.Close()
+	if err2 := <-writeErr; err2 != nil && err2 != io.ErrClosedPipe {
+		// tar failing is a consequence of this
+		return err2
+	}
+	return err
+}
+
+// extractAsUser runs tar as the given user to extract the archive read from
+// stdin into dir.
+func extractAsUser(ctx context.Context, username string, stdin io.Reader, dir string, extraArgs ...string) error {
+	// resist the temptation of using archive/tar unless it's proven
+	// that calling out to tar has issues -- there are a lot of
+	// special cases we'd need to consider otherwise
+	tarArgs := []string{
+		"--extract",
+		"--preserve-permissions", "--preserve-order",
+	}
+	tarArgs = append(tarArgs, extraArgs...)
+	tarArgs = append(tarArgs, "--directory", dir)
+	cmd := tarAsUser(username, tarArgs...)
+	cmd.Env = []string{}
+	cmd.Stdin = stdin
+	matchCounter := &strutil.MatchCounter{N: 1}
+	cmd.Stderr = matchCounter
+	cmd.Stdout = os.Stderr
+	if isTesting {
+		matchCounter.N = -1
+		cmd.Stderr = io.MultiWriter(os.Stderr, matchCounter)
+	}
+
+	if err := osutil.RunWithContext(ctx, cmd); err != nil {
+		matches, count := matchCounter.Matches()
+		if count > 0 {
+			return fmt.Errorf("cannot unpack archive: %s (and %d more)", matches[0], count-1)
+		}
+		return fmt.Errorf("tar failed: %v", err)
+	}
+	return nil
+}
+
+// chunksDir returns the chunk store of the snapshot, which is next to the
+// snapshot file.
+func (r *Reader) chunksDir() string {
+	return filepath.Join(filepath.Dir(r.Name()), chunksDirName)
+}
+
+// lockChunks takes a shared lock on the chunk store if the snapshot uses
+// chunked archives. The returned function releases the lock.
+func (r *Reader) lockChunks() (unlock func(), err error) {
+	for entry := range r.SHA3_384 {
+		if isChunkedArchive(entry) {
+			lock, err := lockChunks(r.chunksDir(), false)
+			if err != nil {
+				return nil, err
+			}
+			return func() { lock.Close() }, nil
+		}
+	}
+	return func() {}, nil
+}
+
 // moveFile moves file from the sourceDir to the targetDir. Directories moved
 // and created are registered in the RestoreState.
 func moveFile(rs *RestoreState, file, sourceDir, targetDir string) error {
@@ -49,11 +49,16 @@ var (
 	ExpiredSnapshotSets        = expiredSnapshotSets
 	RemoveSnapshotState        = removeSnapshotState
 
+	UnretainedScheduledSnapshotSets = unretainedScheduledSnapshotSets
+	RequestChunksCleanup            = requestChunksCleanup
+
 	SetSnapshotOpInProgress = setSnapshotOpInProgress
 
 	DefaultAutomaticSnapshotExpiration = defaultAutomaticSnapshotExpiration
 )
 
+type ScheduledSnapshotRetention = scheduledSnapshotRetention
+
 func (summaries snapshotSnapSummaries) AsMaps() []map[string]string {
 	out := make([]map[string]string, len(summaries))
 	for i, summary := range summaries {
@@ -131,6 +136,22 @@ func MockBackendRestore(f func(*backend.Reader, context.Context, snap.Revision,
 	}
 }
 
+func MockBackendRestorePaths(f func(*backend.Reader, context.Context, snap.Revision, []string, []string, backend.Logf, *dirs.SnapDirOptions) (*backend.RestoreState, error)) (restore func()) {
+	old := backendRestorePaths
+	backendRestorePaths = f
+	return func() {
+		backendRestorePaths = old
+	}
+}
+
+func MockBackendFiles(f func(*backend.Reader, context.Context, []string) ([]client.SnapshotFile, error)) (restore func()) {
+	old := backendFiles
+	backendFiles = f
+	return func() {
+		backendFiles = old
+	}
+}
+
 func MockBackendCheck(f func(*backend.Reader, context.Context, []string) error) (restore func()) {
 	old := backendCheck
 	backendCheck = f
@@ -171,6 +192,14 @@ func MockBackenCleanupAbandondedImports(f func() (int, error)) (restore func())
 	}
 }
 
+func MockBackendCleanupUnusedChunks(f func(context.Context) (int, error)) (restore func()) {
+	old := backendCleanupUnusedChunks
+	backendCleanupUnusedChunks = f
+	return func() {
+		backendCleanupUnusedChunks = old
+	}
+}
+
 func MockBackendEstimateSnapshotSize(f func(*snap.Info, []string, *dirs.SnapDirOptions) (uint64, error)) (restore func()) {
 	old := backendEstimateSnapshotSize
 	backendEstimateSnapshotSize = f
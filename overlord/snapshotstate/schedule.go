@@ -0,0 +1,360 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+var (
+	// scheduledSnapshotMaxDelay is the longest time between two scheduled
+	// snapshots, regardless of the schedule.
+	scheduledSnapshotMaxDelay = 60 * 24 * time.Hour
+	// scheduledSnapshotRetryDelay is how long to wait before trying again
+	// when a scheduled snapshot could not be started.
+	scheduledSnapshotRetryDelay = 20 * time.Minute
+)
+
+// scheduledSnapshotRetention describes how many scheduled snapshot sets are
+// kept, a set is kept if any of the rules keeps it.
+type scheduledSnapshotRetention struct {
+	// Keep is the number of most recent sets that are kept.
+	Keep int
+	// Daily is the number of days for which the most recent set of the
+	// day is kept.
+	Daily int
+	// Weekly is the number of weeks for which the most recent set of the
+	// week is kept.
+	Weekly int
+}
+
+func (r *scheduledSnapshotRetention) unlimited() bool {
+	return r.Keep == 0 && r.Daily == 0 && r.Weekly == 0
+}
+
+func coreStringOption(tr *config.Transaction, key string) (string, error) {
+	var val string
+	if err := tr.Get("core", key, &val); err != nil && !config.IsNoOption(err) {
+		return "", err
+	}
+	return val, nil
+}
+
+// coreCountOption returns the value of a core option that is a number, it
+// deals with numbers that were set as strings.
+func coreCountOption(tr *config.Transaction, key string) (int, error) {
+	var val interface{}
+	if err := tr.Get("core", key, &val); err != nil {
+		if config.IsNoOption(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var n int
+	var err error
+	switch v := val.(type) {
+	case json.Number:
+		n, err = strconv.Atoi(string(v))
+	case string:
+		n, err = strconv.Atoi(v)
+	default:
+		err = fmt.Errorf("unexpected type %T", v)
+	}
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s system option is not a valid count: %v", key, val)
+	}
+	return n, nil
+}
+
+func scheduledSnapshotSchedule(tr *config.Transaction) (sched []*timeutil.Schedule, scheduleStr string, err error) {
+	scheduleStr, err = coreStringOption(tr, "snapshots.schedule")
+	if err != nil || scheduleStr == "" {
+		return nil, "", err
+	}
+	sched, err = timeutil.ParseSchedule(scheduleStr)
+	if err != nil {
+		return nil, "", fmt.Errorf("snapshots.schedule cannot be parsed: %v", err)
+	}
+	return sched, scheduleStr, nil
+}
+
+// scheduledSnapshotSnaps returns the names of the snaps that the scheduled
+// snapshots are taken of, an empty list means all active snaps.
+func scheduledSnapshotSnaps(tr *config.Transaction) ([]string, error) {
+	snapsStr, err := coreStringOption(tr, "snapshots.schedule-snaps")
+	if err != nil {
+		return nil, err
+	}
+	return strutil.CommaSeparatedList(snapsStr), nil
+}
+
+func scheduledSnapshotRetentionFromConfig(tr *config.Transaction) (*scheduledSnapshotRetention, error) {
+	var r scheduledSnapshotRetention
+	var err error
+	if r.Keep, err = coreCountOption(tr, "snapshots.schedule-keep"); err != nil {
+		return nil, err
+	}
+	if r.Daily, err = coreCountOption(tr, "snapshots.schedule-keep-daily"); err != nil {
+		return nil, err
+	}
+	if r.Weekly, err = coreCountOption(tr, "snapshots.schedule-keep-weekly"); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func lastScheduledSnapshot(st *state.State) (time.Time, error) {
+	var last time.Time
+	if err := st.Get("last-scheduled-snapshot", &last); err != nil && !errors.Is(err, state.ErrNoState) {
+		return time.Time{}, err
+	}
+	return last, nil
+}
+
+func scheduledSnapshotInFlight(st *state.State) bool {
+	for _, chg := range st.Changes() {
+		if chg.Kind() == "scheduled-snapshot" && !chg.Status().Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureScheduledSnapshot takes a snapshot set of the snaps configured in
+// snapshots.schedule-snaps when it is due according to snapshots.schedule.
+func (mgr *SnapshotManager) ensureScheduledSnapshot() error {
+	st := mgr.state
+	st.Lock()
+	defer st.Unlock()
+
+	tr := config.NewTransaction(st)
+	sched, scheduleStr, err := scheduledSnapshotSchedule(tr)
+	if err != nil {
+		return err
+	}
+	if scheduleStr != mgr.lastSnapshotSchedule {
+		// the schedule has changed
+		mgr.nextScheduledSnapshot = time.Time{}
+		mgr.lastSnapshotSchedule = scheduleStr
+	}
+	if len(sched) == 0 {
+		return nil
+	}
+
+	if scheduledSnapshotInFlight(st) {
+		return nil
+	}
+
+	now := time.Now()
+	if mgr.nextScheduledSnapshot.IsZero() {
+		last, err := lastScheduledSnapshot(st)
+		if err != nil {
+			return err
+		}
+		if last.IsZero() {
+			// the schedule was just set, start counting from now
+			// rather than taking a snapshot right away
+			last = now
+			st.Set("last-scheduled-snapshot", last)
+		}
+		delta := timeutil.Next(sched, last, scheduledSnapshotMaxDelay)
+		mgr.nextScheduledSnapshot = now.Add(delta)
+		logger.Debugf("Next scheduled snapshot at %s.", mgr.nextScheduledSnapshot.Format(time.RFC3339))
+	}
+	if now.Before(mgr.nextScheduledSnapshot) {
+		return nil
+	}
+
+	names, err := scheduledSnapshotSnaps(tr)
+	if err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		// snaps may have been removed since the option was set
+		active, err := allActiveSnapNames(st)
+		if err != nil {
+			return err
+		}
+		present := make([]string, 0, len(names))
+		for _, name := range names {
+			if strutil.SortedListContains(active, name) {
+				present = append(present, name)
+			} else {
+				logger.Noticef("cannot take scheduled snapshot of snap %q: snap is not installed or not active", name)
+			}
+		}
+		if len(present) == 0 {
+			st.Set("last-scheduled-snapshot", now)
+			mgr.nextScheduledSnapshot = time.Time{}
+			return nil
+		}
+		names = present
+	}
+
+	setID, saved, ts, err := Save(st, names, nil)
+	if err != nil {
+		logger.Noticef("cannot take scheduled snapshot: %v", err)
+		mgr.nextScheduledSnapshot = now.Add(scheduledSnapshotRetryDelay)
+		return nil
+	}
+	st.Set("last-scheduled-snapshot", now)
+	mgr.nextScheduledSnapshot = time.Time{}
+	if len(saved) == 0 {
+		// no active snaps
+		return nil
+	}
+	if err := saveSnapshotState(st, setID, &snapshotState{ScheduleTime: &now}); err != nil {
+		return err
+	}
+
+	var msg string
+	if len(saved) == 1 {
+		msg = fmt.Sprintf("Save scheduled snapshot of snap %q", saved[0])
+	} else {
+		msg = fmt.Sprintf("Save scheduled snapshot of snaps %s", strutil.Quoted(saved))
+	}
+	chg := st.NewChange("scheduled-snapshot", msg)
+	chg.AddAll(ts)
+	chg.Set("snap-names", saved)
+	st.EnsureBefore(0)
+
+	return nil
+}
+
+type scheduledSnapshotSet struct {
+	setID uint64
+	time  time.Time
+}
+
+// unretainedScheduledSnapshotSets returns the scheduled snapshot sets that
+// are not kept by the given retention.
+// The state needs to be locked by the caller.
+func unretainedScheduledSnapshotSets(st *state.State, retention *scheduledSnapshotRetention) (map[uint64]bool, error) {
+	var snapshots map[uint64]*snapshotState
+	if err := st.Get("snapshots", &snapshots); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var sets []scheduledSnapshotSet
+	for setID, sst := range snapshots {
+		if sst.ScheduleTime != nil {
+			sets = append(sets, scheduledSnapshotSet{setID: setID, time: *sst.ScheduleTime})
+		}
+	}
+	// most recent first
+	sort.Slice(sets, func(i, j int) bool {
+		if sets[i].time.Equal(sets[j].time) {
+			return sets[i].setID > sets[j].setID
+		}
+		return sets[i].time.After(sets[j].time)
+	})
+
+	kept := make(map[uint64]bool, len(sets))
+	keepPeriods := func(count int, period func(time.Time) string) {
+		seen := make(map[string]bool, count)
+		for _, set := range sets {
+			if len(seen) >= count {
+				break
+			}
+			p := period(set.time.Local())
+			if !seen[p] {
+				seen[p] = true
+				kept[set.setID] = true
+			}
+		}
+	}
+	for i := 0; i < retention.Keep && i < len(sets); i++ {
+		kept[sets[i].setID] = true
+	}
+	keepPeriods(retention.Daily, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepPeriods(retention.Weekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-%d", year, week)
+	})
+
+	unretained := make(map[uint64]bool)
+	for _, set := range sets {
+		if !kept[set.setID] {
+			unretained[set.setID] = true
+		}
+	}
+	return unretained, nil
+}
+
+// forgetUnretainedScheduledSnapshots removes the scheduled snapshot sets
+// that are not kept by the retention configured in the
+// snapshots.schedule-keep* options.
+func (mgr *SnapshotManager) forgetUnretainedScheduledSnapshots() error {
+	st := mgr.state
+	st.Lock()
+	defer st.Unlock()
+
+	retention, err := scheduledSnapshotRetentionFromConfig(config.NewTransaction(st))
+	if err != nil {
+		return err
+	}
+	if retention.unlimited() {
+		return nil
+	}
+
+	sets, err := unretainedScheduledSnapshotSets(st, retention)
+	if err != nil {
+		return fmt.Errorf("internal error: cannot determine unretained scheduled snapshots: %v", err)
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+
+	postponed, err := mgr.forgetSnapshotSets(sets)
+	if err != nil {
+		return fmt.Errorf("cannot process unretained scheduled snapshots: %v", err)
+	}
+	// sets that are neither forgotten nor postponed have no files left,
+	// drop them from the state so that they are not looked for again
+	var missing []uint64
+	for setID := range sets {
+		if !postponed[setID] {
+			missing = append(missing, setID)
+		}
+	}
+	if len(missing) > 0 {
+		if err := removeSnapshotState(st, missing...); err != nil {
+			return fmt.Errorf("internal error: cannot remove state of snapshot sets %v: %v", missing, err)
+		}
+	}
+
+	return nil
+}
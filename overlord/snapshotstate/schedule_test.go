@@ -0,0 +1,371 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func setCoreConfig(c *check.C, st *state.State, conf map[string]interface{}) {
+	tr := config.NewTransaction(st)
+	for k, v := range conf {
+		c.Assert(tr.Set("core", k, v), check.IsNil)
+	}
+	tr.Commit()
+}
+
+func (s *snapshotSuite) mockScheduledSnapshotSnaps(c *check.C, st *state.State, names ...string) {
+	restore := snapshotstate.MockSnapstateCheckChangeConflictMany(func(*state.State, []string, string) error {
+		return nil
+	})
+	s.AddCleanup(restore)
+
+	for _, name := range names {
+		snapstate.Set(st, name, &snapstate.SnapState{
+			Active: true,
+			Sequence: []*snap.SideInfo{
+				{RealName: name, Revision: snap.R(1)},
+			},
+			Current:  snap.R(1),
+			SnapType: "app",
+		})
+	}
+}
+
+func (s *snapshotSuite) TestEnsureScheduledSnapshotNotDue(c *check.C) {
+	st := state.New(nil)
+	mgr := snapshotstate.Manager(st, state.NewTaskRunner(st))
+
+	st.Lock()
+	defer st.Unlock()
+	s.mockScheduledSnapshotSnaps(c, st, "foo")
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.schedule": "mon,10:00",
+	})
+
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+
+	// no snapshot is taken right away, but the schedule starts now
+	c.Check(st.Changes(), check.HasLen, 0)
+	var last time.Time
+	c.Assert(st.Get("last-scheduled-snapshot", &last), check.IsNil)
+	c.Check(time.Since(last) < time.Minute, check.Equals, true)
+}
+
+func (s *snapshotSuite) TestEnsureScheduledSnapshotDisabled(c *check.C) {
+	st := state.New(nil)
+	mgr := snapshotstate.Manager(st, state.NewTaskRunner(st))
+
+	st.Lock()
+	defer st.Unlock()
+	s.mockScheduledSnapshotSnaps(c, st, "foo")
+	st.Set("last-scheduled-snapshot", time.Now().AddDate(0, 0, -1))
+
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+
+	c.Check(st.Changes(), check.HasLen, 0)
+}
+
+func (s *snapshotSuite) TestEnsureScheduledSnapshot(c *check.C) {
+	st := state.New(nil)
+	mgr := snapshotstate.Manager(st, state.NewTaskRunner(st))
+
+	st.Lock()
+	defer st.Unlock()
+	s.mockScheduledSnapshotSnaps(c, st, "foo", "bar", "baz")
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.schedule":       "00:00-24:00",
+		"snapshots.schedule-snaps": "foo,bar,gone",
+	})
+	lastScheduled := time.Now().AddDate(0, 0, -1)
+	st.Set("last-scheduled-snapshot", lastScheduled)
+
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+
+	chgs := st.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	chg := chgs[0]
+	c.Check(chg.Kind(), check.Equals, "scheduled-snapshot")
+	c.Check(chg.Summary(), check.Equals, `Save scheduled snapshot of snaps "foo", "bar"`)
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"foo", "bar"})
+
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 2)
+	var setID uint64
+	for i, name := range names {
+		c.Check(tasks[i].Kind(), check.Equals, "save-snapshot")
+		var snapshot map[string]interface{}
+		c.Assert(tasks[i].Get("snapshot-setup", &snapshot), check.IsNil)
+		c.Check(snapshot["snap"], check.Equals, name)
+		setID = uint64(snapshot["set-id"].(float64))
+	}
+
+	var snapshots map[uint64]map[string]interface{}
+	c.Assert(st.Get("snapshots", &snapshots), check.IsNil)
+	c.Assert(snapshots[setID], check.NotNil)
+	c.Check(snapshots[setID]["schedule-time"], check.NotNil)
+
+	var last time.Time
+	c.Assert(st.Get("last-scheduled-snapshot", &last), check.IsNil)
+	c.Check(last.After(lastScheduled), check.Equals, true)
+
+	// no new snapshot while the previous one is in progress, nor once
+	// it is done as the next one is not due yet
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 1)
+
+	chg.SetStatus(state.DoneStatus)
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 1)
+}
+
+func (s *snapshotSuite) TestEnsureScheduledSnapshotAllSnaps(c *check.C) {
+	st := state.New(nil)
+	mgr := snapshotstate.Manager(st, state.NewTaskRunner(st))
+
+	st.Lock()
+	defer st.Unlock()
+	s.mockScheduledSnapshotSnaps(c, st, "foo")
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.schedule": "00:00-24:00",
+	})
+	st.Set("last-scheduled-snapshot", time.Now().AddDate(0, 0, -1))
+
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+
+	chgs := st.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	c.Check(chgs[0].Summary(), check.Equals, `Save scheduled snapshot of snap "foo"`)
+}
+
+func (s *snapshotSuite) TestEnsureScheduledSnapshotNoSnapsLeft(c *check.C) {
+	st := state.New(nil)
+	mgr := snapshotstate.Manager(st, state.NewTaskRunner(st))
+
+	st.Lock()
+	defer st.Unlock()
+	s.mockScheduledSnapshotSnaps(c, st, "foo")
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.schedule":       "00:00-24:00",
+		"snapshots.schedule-snaps": "gone",
+	})
+	lastScheduled := time.Now().AddDate(0, 0, -1)
+	st.Set("last-scheduled-snapshot", lastScheduled)
+
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+
+	c.Check(st.Changes(), check.HasLen, 0)
+	var last time.Time
+	c.Assert(st.Get("last-scheduled-snapshot", &last), check.IsNil)
+	c.Check(last.After(lastScheduled), check.Equals, true)
+}
+
+func scheduledSnapshotsState(times map[uint64]string) map[uint64]interface{} {
+	snapshots := make(map[uint64]interface{}, len(times))
+	for setID, t := range times {
+		snapshots[setID] = map[string]interface{}{
+			"expiry-time":   "0001-01-01T00:00:00Z",
+			"schedule-time": t,
+		}
+	}
+	return snapshots
+}
+
+func (snapshotSuite) TestUnretainedScheduledSnapshotSets(c *check.C) {
+	// days and weeks are computed in local time
+	defer func(loc *time.Location) { time.Local = loc }(time.Local)
+	time.Local = time.UTC
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	snapshots := scheduledSnapshotsState(map[uint64]string{
+		// a week with two snapshots a day
+		1:  "2022-03-07T01:00:00Z",
+		2:  "2022-03-07T13:00:00Z",
+		3:  "2022-03-08T01:00:00Z",
+		4:  "2022-03-08T13:00:00Z",
+		5:  "2022-03-09T01:00:00Z",
+		6:  "2022-03-09T13:00:00Z",
+		7:  "2022-03-10T01:00:00Z",
+		8:  "2022-03-10T13:00:00Z",
+		9:  "2022-03-14T01:00:00Z",
+		10: "2022-03-14T13:00:00Z",
+	})
+	// automatic snapshots are not affected
+	snapshots[11] = map[string]interface{}{"expiry-time": "2022-03-01T00:00:00Z"}
+	st.Set("snapshots", snapshots)
+
+	for _, t := range []struct {
+		retention  snapshotstate.ScheduledSnapshotRetention
+		unretained []uint64
+	}{
+		{snapshotstate.ScheduledSnapshotRetention{Keep: 3}, []uint64{1, 2, 3, 4, 5, 6, 7}},
+		{snapshotstate.ScheduledSnapshotRetention{Keep: 20}, nil},
+		{snapshotstate.ScheduledSnapshotRetention{Daily: 2}, []uint64{1, 2, 3, 4, 5, 6, 7, 9}},
+		{snapshotstate.ScheduledSnapshotRetention{Weekly: 2}, []uint64{1, 2, 3, 4, 5, 6, 7, 9}},
+		{snapshotstate.ScheduledSnapshotRetention{Keep: 1, Daily: 3, Weekly: 1}, []uint64{1, 2, 3, 4, 5, 7, 9}},
+		{snapshotstate.ScheduledSnapshotRetention{Daily: 7, Weekly: 4}, []uint64{1, 3, 5, 7, 9}},
+	} {
+		sets, err := snapshotstate.UnretainedScheduledSnapshotSets(st, &t.retention)
+		c.Assert(err, check.IsNil)
+		var unretained []uint64
+		for setID := range sets {
+			unretained = append(unretained, setID)
+		}
+		sort.Slice(unretained, func(i, j int) bool { return unretained[i] < unretained[j] })
+		c.Check(unretained, check.DeepEquals, t.unretained, check.Commentf("%+v", t.retention))
+	}
+}
+
+func (s *snapshotSuite) TestEnsureForgetsUnretainedScheduledSnapshots(c *check.C) {
+	var removed []string
+	restore := snapshotstate.MockOsRemove(func(fileName string) error {
+		removed = append(removed, filepath.Base(fileName))
+		return nil
+	})
+	defer restore()
+
+	dir := c.MkDir()
+	fakeIter := func(_ context.Context, f func(*backend.Reader) error) error {
+		for _, sh := range []client.Snapshot{
+			{SetID: 1, Snap: "foo"},
+			{SetID: 1, Snap: "bar"},
+			{SetID: 2, Snap: "foo"},
+			{SetID: 4, Snap: "foo"},
+			{SetID: 5, Snap: "foo"},
+		} {
+			shotfile, err := os.Create(filepath.Join(dir, fmt.Sprintf("%d_%s.zip", sh.SetID, sh.Snap)))
+			c.Assert(err, check.IsNil)
+			err = f(&backend.Reader{Snapshot: sh, File: shotfile})
+			shotfile.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	defer snapshotstate.MockBackendIter(fakeIter)()
+
+	st := state.New(nil)
+	mgr := snapshotstate.Manager(st, state.NewTaskRunner(st))
+
+	st.Lock()
+	defer st.Unlock()
+	setCoreConfig(c, st, map[string]interface{}{
+		"snapshots.schedule-keep": 2,
+	})
+	snapshots := scheduledSnapshotsState(map[uint64]string{
+		1: "2022-03-07T01:00:00Z",
+		2: "2022-03-08T01:00:00Z",
+		// the files of set 3 are gone
+		3: "2022-03-09T01:00:00Z",
+		4: "2022-03-10T01:00:00Z",
+		5: "2022-03-11T01:00:00Z",
+	})
+	snapshots[6] = map[string]interface{}{"expiry-time": "2037-02-12T12:50:00Z"}
+	st.Set("snapshots", snapshots)
+
+	// set 2 is being checked
+	chg := st.NewChange("check-snapshot", "...")
+	task := st.NewTask("check-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{"set-id": 2})
+	chg.AddTask(task)
+
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+
+	sort.Strings(removed)
+	c.Check(removed, check.DeepEquals, []string{"1_bar.zip", "1_foo.zip"})
+
+	var left map[uint64]interface{}
+	c.Assert(st.Get("snapshots", &left), check.IsNil)
+	var leftIDs []uint64
+	for setID := range left {
+		leftIDs = append(leftIDs, setID)
+	}
+	sort.Slice(leftIDs, func(i, j int) bool { return leftIDs[i] < leftIDs[j] })
+	c.Check(leftIDs, check.DeepEquals, []uint64{2, 4, 5, 6})
+}
+
+func (snapshotSuite) TestListScheduledSnapshotPolicy(c *check.C) {
+	restore := snapshotstate.MockBackendList(func(context.Context, uint64, []string) ([]client.SnapshotSet, error) {
+		return []client.SnapshotSet{
+			{ID: 1, Snapshots: []*client.Snapshot{{Snap: "foo", SetID: 1}}},
+			{ID: 2, Snapshots: []*client.Snapshot{{Snap: "foo", SetID: 2}}},
+			{ID: 3, Snapshots: []*client.Snapshot{{Snap: "foo", SetID: 3}, {Snap: "bar", SetID: 3}}},
+		}, nil
+	})
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	snapshots := scheduledSnapshotsState(map[uint64]string{
+		3: "2022-03-07T01:00:00Z",
+	})
+	snapshots[2] = map[string]interface{}{"expiry-time": "2037-02-12T12:50:00Z"}
+	st.Set("snapshots", snapshots)
+
+	sets, err := snapshotstate.List(context.TODO(), st, 0, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(sets, check.HasLen, 3)
+	c.Check(sets[0].Snapshots[0].Policy, check.Equals, "")
+	c.Check(sets[0].Snapshots[0].Auto, check.Equals, false)
+	c.Check(sets[1].Snapshots[0].Policy, check.Equals, "automatic")
+	c.Check(sets[1].Snapshots[0].Auto, check.Equals, true)
+	for _, sh := range sets[2].Snapshots {
+		c.Check(sh.Policy, check.Equals, "scheduled")
+		c.Check(sh.Auto, check.Equals, false)
+	}
+}
@@ -47,11 +47,14 @@ var (
 	backendSave          = backend.Save
 	backendImport        = backend.Import
 	backendRestore       = (*backend.Reader).Restore // TODO: look into using an interface instead
+	backendRestorePaths  = (*backend.Reader).RestorePaths
+	backendFiles         = (*backend.Reader).Files
 	backendCheck         = (*backend.Reader).Check
 	backendRevert        = (*backend.RestoreState).Revert // ditto
 	backendCleanup       = (*backend.RestoreState).Cleanup
 
 	backendCleanupAbandondedImports = backend.CleanupAbandondedImports
+	backendCleanupUnusedChunks      = backend.CleanupUnusedChunks
 
 	autoExpirationInterval = time.Hour * 24 // interval between forgetExpiredSnapshots runs as part of Ensure()
 
@@ -63,6 +66,9 @@ type SnapshotManager struct {
 	state *state.State
 
 	lastForgetExpiredSnapshotTime time.Time
+
+	lastSnapshotSchedule  string
+	nextScheduledSnapshot time.Time
 }
 
 // Manager returns a new SnapshotManager
@@ -87,9 +93,21 @@ func Manager(st *state.State, runner *state.TaskRunner) *SnapshotManager {
 func (mgr *SnapshotManager) Ensure() error {
 	// process expired snapshots once a day.
 	if time.Now().After(mgr.lastForgetExpiredSnapshotTime.Add(autoExpirationInterval)) {
-		return mgr.forgetExpiredSnapshots()
+		if err := mgr.forgetExpiredSnapshots(); err != nil {
+			return err
+		}
+	}
+
+	if err := mgr.ensureScheduledSnapshot(); err != nil {
+		return err
 	}
 
+	if err := mgr.forgetUnretainedScheduledSnapshots(); err != nil {
+		return err
+	}
+
+	mgr.cleanupUnusedChunks()
+
 	return nil
 }
 
@@ -97,9 +115,54 @@ func (mgr *SnapshotManager) StartUp() error {
 	if _, err := backendCleanupAbandondedImports(); err != nil {
 		logger.Noticef("cannot cleanup incomplete imports: %v", err)
 	}
+
+	// chunks can be left behind by snapshots that were interrupted
+	mgr.state.Lock()
+	requestChunksCleanup(mgr.state)
+	mgr.state.Unlock()
+
 	return nil
 }
 
+type chunksCleanupKey struct{}
+
+// requestChunksCleanup makes the next Ensure remove the snapshot chunks that
+// are not used anymore.
+// The state needs to be locked by the caller.
+func requestChunksCleanup(st *state.State) {
+	st.Cache(chunksCleanupKey{}, true)
+}
+
+// cleanupUnusedChunks removes the snapshot chunks that are not used anymore,
+// if snapshots were removed since it last ran.
+func (mgr *SnapshotManager) cleanupUnusedChunks() {
+	st := mgr.state
+	st.Lock()
+	requested := st.Cached(chunksCleanupKey{}) != nil
+	st.Cache(chunksCleanupKey{}, nil)
+	st.Unlock()
+	if !requested {
+		return
+	}
+
+	// this reads all the snapshots, don't hold the state lock meanwhile
+	removed, err := backendCleanupUnusedChunks(context.TODO())
+	if err == backend.ErrChunkStoreBusy {
+		// try again on the next Ensure
+		st.Lock()
+		requestChunksCleanup(st)
+		st.Unlock()
+		return
+	}
+	if err != nil {
+		logger.Noticef("cannot remove unused snapshot chunks: %v", err)
+		return
+	}
+	if removed > 0 {
+		logger.Debugf("Removed %d unused snapshot chunks.", removed)
+	}
+}
+
 func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
 	mgr.state.Lock()
 	defer mgr.state.Unlock()
@@ -113,15 +176,41 @@ func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
 		return nil
 	}
 
+	if _, err := mgr.forgetSnapshotSets(sets); err != nil {
+		return fmt.Errorf("cannot process expired snapshots: %v", err)
+	}
+
+	// only reset time if there are no sets left because of conflicts
+	if len(sets) == 0 {
+		mgr.lastForgetExpiredSnapshotTime = time.Now()
+	}
+
+	return nil
+}
+
+// forgetSnapshotSets removes the given snapshot sets from the state and from
+// the disk. Sets that were removed are deleted from the sets map, sets that
+// are the subject of a conflicting operation are left in place and returned
+// as postponed.
+// The state needs to be locked by the caller.
+func (mgr *SnapshotManager) forgetSnapshotSets(sets map[uint64]bool) (postponed map[uint64]bool, err error) {
+	postponed = make(map[uint64]bool)
+	forgotten := make(map[uint64]bool)
 	err = backendIter(context.TODO(), func(r *backend.Reader) error {
-		// forget needs to conflict with check and restore
-		if err := checkSnapshotConflict(mgr.state, r.SetID, "export-snapshot",
-			"check-snapshot", "restore-snapshot"); err != nil {
-			// there is a conflict, do nothing and we will retry this set on next Ensure().
+		if !sets[r.SetID] || postponed[r.SetID] {
 			return nil
 		}
-		if sets[r.SetID] {
-			delete(sets, r.SetID)
+		// a set has one file per snap, the conflict check and the state
+		// only need to be looked at for the first one
+		if !forgotten[r.SetID] {
+			// forget needs to conflict with check and restore
+			if err := checkSnapshotConflict(mgr.state, r.SetID, "export-snapshot",
+				"check-snapshot", "restore-snapshot"); err != nil {
+				// there is a conflict, do nothing and we will retry this set on next Ensure().
+				postponed[r.SetID] = true
+				return nil
+			}
+			forgotten[r.SetID] = true
 			// remove from state first: in case removeSnapshotState succeeds but osRemove fails we will never attempt
 			// to automatically remove this snapshot again and will leave it on the disk (so the user can still try to remove it manually);
 			// this is better than the other way around where a failing osRemove would be retried forever because snapshot would never
@@ -129,23 +218,19 @@ func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
 			if err := removeSnapshotState(mgr.state, r.SetID); err != nil {
 				return fmt.Errorf("internal error: cannot remove state of snapshot set %d: %v", r.SetID, err)
 			}
-			if err := osRemove(r.Name()); err != nil {
-				return fmt.Errorf("cannot remove snapshot file %q: %v", r.Name(), err)
-			}
+		}
+		if err := osRemove(r.Name()); err != nil {
+			return fmt.Errorf("cannot remove snapshot file %q: %v", r.Name(), err)
 		}
 		return nil
 	})
-
-	if err != nil {
-		return fmt.Errorf("cannot process expired snapshots: %v", err)
+	for setID := range forgotten {
+		delete(sets, setID)
 	}
-
-	// only reset time if there are no sets left because of conflicts
-	if len(sets) == 0 {
-		mgr.lastForgetExpiredSnapshotTime = time.Now()
+	if len(forgotten) > 0 {
+		requestChunksCleanup(mgr.state)
 	}
-
-	return nil
+	return postponed, err
 }
 
 func (SnapshotManager) affectedSnaps(t *state.Task) ([]string, error) {
@@ -169,6 +254,9 @@ type snapshotSetup struct {
 	Filename string        `json:"filename,omitempty"`
 	Current  snap.Revision `json:"current"`
 	Auto     bool          `json:"auto,omitempty"`
+	// Paths, if set, are the only paths of the data restored; the
+	// config of the snap is then left alone.
+	Paths []string `json:"paths,omitempty"`
 }
 
 func filename(setID uint64, si *snap.Info) string {
@@ -237,6 +325,8 @@ func doSave(task *state.Task, tomb *tomb.Tomb) error {
 		st.Lock()
 		defer st.Unlock()
 		removeSnapshotState(st, snapshot.SetID)
+		// the chunks stored before the failure are not used
+		requestChunksCleanup(st)
 	}
 	return err
 }
@@ -317,6 +407,17 @@ func doRestore(task *state.Task, tomb *tomb.Tomb) error {
 		return err
 	}
 
+	if len(snapshot.Paths) > 0 {
+		restoreState, err := backendRestorePaths(reader, tomb.Context(nil), snapshot.Current, snapshot.Users, snapshot.Paths, logf, opts)
+		if err != nil {
+			return err
+		}
+		st.Lock()
+		defer st.Unlock()
+		task.Set("restore-state", restoreState)
+		return nil
+	}
+
 	restoreState, err := backendRestore(reader, tomb.Context(nil), snapshot.Current, snapshot.Users, logf, opts)
 	if err != nil {
 		return err
@@ -357,13 +458,16 @@ func undoRestore(task *state.Task, _ *tomb.Tomb) error {
 		return taskGetErrMsg(task, err, "snapshot")
 	}
 
-	raw, err := marshalSnapConfig(restoreState.Config)
-	if err != nil {
-		return fmt.Errorf("cannot marshal saved config: %v", err)
-	}
+	// restoring only some paths leaves the config alone
+	if len(snapshot.Paths) == 0 {
+		raw, err := marshalSnapConfig(restoreState.Config)
+		if err != nil {
+			return fmt.Errorf("cannot marshal saved config: %v", err)
+		}
 
-	if err := configSetSnapConfig(st, snapshot.Snap, raw); err != nil {
-		return fmt.Errorf("cannot restore saved config: %v", err)
+		if err := configSetSnapConfig(st, snapshot.Snap, raw); err != nil {
+			return fmt.Errorf("cannot restore saved config: %v", err)
+		}
 	}
 
 	backendRevert(&restoreState)
@@ -457,7 +561,12 @@ func doForget(task *state.Task, _ *tomb.Tomb) error {
 		return fmt.Errorf("internal error: cannot remove state of snapshot set %d: %v", snapshot.SetID, err)
 	}
 
-	return osRemove(snapshot.Filename)
+	if err := osRemove(snapshot.Filename); err != nil {
+		return err
+	}
+	requestChunksCleanup(st)
+
+	return nil
 }
 
 func delayedCrossMgrInit() {
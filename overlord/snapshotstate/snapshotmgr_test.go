@@ -778,6 +778,72 @@ func (rs *readerSuite) TestUndoRestoreNoConfig(c *check.C) {
 	c.Check(rs.calls, check.DeepEquals, []string{"set config", "revert"})
 }
 
+func (rs *readerSuite) setRestorePaths(paths ...string) {
+	st := rs.task.State()
+	st.Lock()
+	defer st.Unlock()
+	rs.task.Set("snapshot-setup", map[string]interface{}{
+		"snap":     "a-snap",
+		"filename": "/some/1_file.zip",
+		"users":    []string{"a-user"},
+		"paths":    paths,
+	})
+}
+
+func (rs *readerSuite) TestDoRestorePaths(c *check.C) {
+	rs.setRestorePaths("x1/foo", "common/bar")
+	defer snapshotstate.MockBackendOpen(func(filename string, setID uint64) (*backend.Reader, error) {
+		rs.calls = append(rs.calls, "open")
+		return &backend.Reader{
+			Snapshot: client.Snapshot{Conf: map[string]interface{}{"hello": "there"}},
+		}, nil
+	})()
+	defer snapshotstate.MockBackendRestorePaths(func(_ *backend.Reader, _ context.Context, _ snap.Revision, users []string, paths []string, _ backend.Logf, _ *dirs.SnapDirOptions) (*backend.RestoreState, error) {
+		rs.calls = append(rs.calls, "restore paths")
+		c.Check(users, check.DeepEquals, []string{"a-user"})
+		c.Check(paths, check.DeepEquals, []string{"x1/foo", "common/bar"})
+		return &backend.RestoreState{Created: []string{"/some/x1/foo"}}, nil
+	})()
+
+	err := snapshotstate.DoRestore(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	// the config is left alone
+	c.Check(rs.calls, check.DeepEquals, []string{"get config", "open", "restore paths"})
+
+	st := rs.task.State()
+	st.Lock()
+	var v map[string]interface{}
+	rs.task.Get("restore-state", &v)
+	st.Unlock()
+	c.Check(v, check.DeepEquals, map[string]interface{}{"created": []interface{}{"/some/x1/foo"}})
+}
+
+func (rs *readerSuite) TestDoRestorePathsFails(c *check.C) {
+	rs.setRestorePaths("x1/foo")
+	defer snapshotstate.MockBackendRestorePaths(func(*backend.Reader, context.Context, snap.Revision, []string, []string, backend.Logf, *dirs.SnapDirOptions) (*backend.RestoreState, error) {
+		rs.calls = append(rs.calls, "restore paths")
+		return nil, errors.New("bzzt")
+	})()
+
+	err := snapshotstate.DoRestore(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.ErrorMatches, "bzzt")
+	c.Check(rs.calls, check.DeepEquals, []string{"get config", "open", "restore paths"})
+}
+
+func (rs *readerSuite) TestUndoRestorePaths(c *check.C) {
+	rs.setRestorePaths("x1/foo")
+
+	st := rs.task.State()
+	st.Lock()
+	rs.task.Set("restore-state", map[string]interface{}{})
+	st.Unlock()
+
+	err := snapshotstate.UndoRestore(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	// the config was not restored, so it is not reset either
+	c.Check(rs.calls, check.DeepEquals, []string{"revert"})
+}
+
 func (rs *readerSuite) TestCleanupRestore(c *check.C) {
 	st := rs.task.State()
 	st.Lock()
@@ -908,3 +974,88 @@ func (snapshotSuite) TestManagerRunCleanupAbandondedImportsAtStartupErrorLogged(
 	c.Check(n, check.Equals, 1)
 	c.Check(logbuf.String(), testutil.Contains, "cannot cleanup incomplete imports: some error\n")
 }
+
+func (snapshotSuite) TestManagerCleanupUnusedChunksAtStartup(c *check.C) {
+	n := 0
+	restore := snapshotstate.MockBackendCleanupUnusedChunks(func(context.Context) (int, error) {
+		n++
+		return 0, nil
+	})
+	defer restore()
+
+	o := overlord.Mock()
+	st := o.State()
+	mgr := snapshotstate.Manager(st, state.NewTaskRunner(st))
+	c.Assert(mgr, check.NotNil)
+	o.AddManager(mgr)
+	err := o.Settle(100 * time.Millisecond)
+	c.Assert(err, check.IsNil)
+	c.Check(n, check.Equals, 1)
+
+	// not again until snapshots are removed
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(n, check.Equals, 1)
+}
+
+func (snapshotSuite) TestEnsureCleanupUnusedChunksAfterForget(c *check.C) {
+	defer snapshotstate.MockOsRemove(func(string) error { return nil })()
+	n := 0
+	restore := snapshotstate.MockBackendCleanupUnusedChunks(func(context.Context) (int, error) {
+		n++
+		return 1, nil
+	})
+	defer restore()
+
+	st := state.New(nil)
+	mgr := snapshotstate.Manager(st, state.NewTaskRunner(st))
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(n, check.Equals, 0)
+
+	st.Lock()
+	task := st.NewTask("forget-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{
+		"set-id":   1,
+		"filename": "a-file",
+		"snap":     "a-snap",
+	})
+	st.Unlock()
+	c.Assert(snapshotstate.DoForget(task, &tomb.Tomb{}), check.IsNil)
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(n, check.Equals, 1)
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(n, check.Equals, 1)
+}
+
+func (snapshotSuite) TestEnsureCleanupUnusedChunksBusy(c *check.C) {
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	var errs = []error{backend.ErrChunkStoreBusy, errors.New("some error")}
+	n := 0
+	restore = snapshotstate.MockBackendCleanupUnusedChunks(func(context.Context) (int, error) {
+		err := errs[n]
+		n++
+		return 0, err
+	})
+	defer restore()
+
+	st := state.New(nil)
+	mgr := snapshotstate.Manager(st, state.NewTaskRunner(st))
+	st.Lock()
+	snapshotstate.RequestChunksCleanup(st)
+	st.Unlock()
+
+	// busy, tried again on the next Ensure
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(n, check.Equals, 1)
+	c.Check(logbuf.String(), check.Equals, "")
+
+	// other errors are only logged
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(n, check.Equals, 2)
+	c.Check(logbuf.String(), testutil.Contains, "cannot remove unused snapshot chunks: some error\n")
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(n, check.Equals, 2)
+}
@@ -28,6 +28,8 @@ import (
 	"sort"
 	"time"
 
+	"filippo.io/age"
+
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/configstate/config"
@@ -51,8 +53,31 @@ var (
 	defaultAutomaticSnapshotExpiration = time.Hour * 24 * 31
 )
 
+const (
+	// automaticSnapshotPolicy is the policy of the snapshots taken
+	// automatically when a snap is removed.
+	automaticSnapshotPolicy = "automatic"
+	// scheduledSnapshotPolicy is the policy of the snapshots taken
+	// periodically as configured by snapshots.schedule.
+	scheduledSnapshotPolicy = "scheduled"
+)
+
 type snapshotState struct {
+	// ExpiryTime is set for automatic snapshots.
 	ExpiryTime time.Time `json:"expiry-time"`
+	// ScheduleTime is set for scheduled snapshots, it is the time the
+	// snapshot set was taken at.
+	ScheduleTime *time.Time `json:"schedule-time,omitempty"`
+}
+
+func (sst *snapshotState) policy() string {
+	switch {
+	case !sst.ExpiryTime.IsZero():
+		return automaticSnapshotPolicy
+	case sst.ScheduleTime != nil:
+		return scheduledSnapshotPolicy
+	}
+	return ""
 }
 
 func newSnapshotSetID(st *state.State) (uint64, error) {
@@ -154,6 +179,14 @@ func AutomaticSnapshotExpiration(st *state.State) (time.Duration, error) {
 // saveExpiration saves expiration date of the given snapshot set, in the state.
 // The state needs to be locked by the caller.
 func saveExpiration(st *state.State, setID uint64, expiryTime time.Time) error {
+	return saveSnapshotState(st, setID, &snapshotState{
+		ExpiryTime: expiryTime,
+	})
+}
+
+// saveSnapshotState saves the state of the given snapshot set.
+// The state needs to be locked by the caller.
+func saveSnapshotState(st *state.State, setID uint64, sst *snapshotState) error {
 	var snapshots map[uint64]*json.RawMessage
 	err := st.Get("snapshots", &snapshots)
 	if err != nil && !errors.Is(err, state.ErrNoState) {
@@ -162,9 +195,7 @@ func saveExpiration(st *state.State, setID uint64, expiryTime time.Time) error {
 	if snapshots == nil {
 		snapshots = make(map[uint64]*json.RawMessage)
 	}
-	data, err := json.Marshal(&snapshotState{
-		ExpiryTime: expiryTime,
-	})
+	data, err := json.Marshal(sst)
 	if err != nil {
 		return err
 	}
@@ -207,6 +238,10 @@ func expiredSnapshotSets(st *state.State, cutoffTime time.Time) (map[uint64]bool
 
 	expired := make(map[uint64]bool)
 	for setID, snapshotSet := range snapshots {
+		// only automatic snapshots expire
+		if snapshotSet.ExpiryTime.IsZero() {
+			continue
+		}
 		if snapshotSet.ExpiryTime.Before(cutoffTime) {
 			expired[setID] = true
 		}
@@ -320,13 +355,17 @@ func List(ctx context.Context, st *state.State, setID uint64, snapNames []string
 		return nil, err
 	}
 
-	// decorate all snapshots with "auto" flag if we have expiry time set for them.
+	// decorate all snapshots with the policy they were taken by, and with
+	// the "auto" flag if we have expiry time set for them.
 	for _, sset := range sets {
-		// at the moment we only keep records with expiry time so checking non-zero
-		// expiry-time is not strictly necessary, but it makes it future-proof in case
-		// we add more attributes to these entries.
-		if snapshotState, ok := snapshots[sset.ID]; ok && !snapshotState.ExpiryTime.IsZero() {
-			for _, snapshot := range sset.Snapshots {
+		snapshotState, ok := snapshots[sset.ID]
+		if !ok {
+			continue
+		}
+		policy := snapshotState.policy()
+		for _, snapshot := range sset.Snapshots {
+			snapshot.Policy = policy
+			if policy == automaticSnapshotPolicy {
 				snapshot.Auto = true
 			}
 		}
@@ -335,8 +374,9 @@ func List(ctx context.Context, st *state.State, setID uint64, snapNames []string
 	return sets, nil
 }
 
-// Import a given snapshot ID from an exported snapshot
-func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, snapNames []string, err error) {
+// Import a given snapshot ID from an exported snapshot. The identities are
+// used to decrypt an encrypted export.
+func Import(ctx context.Context, st *state.State, r io.Reader, identities []age.Identity) (setID uint64, snapNames []string, err error) {
 	st.Lock()
 	setID, err = newSnapshotSetID(st)
 	// note, this is a new set id which is not exposed yet, no need to mark it
@@ -347,7 +387,11 @@ func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, sn
 		return 0, nil, err
 	}
 
-	snapNames, err = backendImport(ctx, setID, r, nil)
+	var flags *backend.ImportFlags
+	if len(identities) > 0 {
+		flags = &backend.ImportFlags{Identities: identities}
+	}
+	snapNames, err = backendImport(ctx, setID, r, flags)
 	if err != nil {
 		if dupErr, ok := err.(backend.DuplicatedSnapshotImportError); ok {
 			st.Lock()
@@ -356,7 +400,10 @@ func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, sn
 			if err := checkSnapshotConflict(st, dupErr.SetID, "forget-snapshot"); err != nil {
 				// we found an existing snapshot but it's being forgotten, so
 				// retry the import without checking for existing snapshot.
-				flags := &backend.ImportFlags{NoDuplicatedImportCheck: true}
+				flags := &backend.ImportFlags{
+					NoDuplicatedImportCheck: true,
+					Identities:              identities,
+				}
 				st.Unlock()
 				snapNames, err = backendImport(ctx, setID, r, flags)
 				st.Lock()
@@ -365,9 +412,10 @@ func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, sn
 
 			// trying to import identical snapshot; instead return set ID of
 			// the existing one and reset its expiry time.
-			// XXX: at the moment expiry-time is the only attribute so we can
-			// just remove the record. If we ever add more attributes this needs
-			// to reset expiry-time only.
+			// XXX: at the moment the record only tracks the policy the
+			// snapshot was taken by, an imported snapshot is not subject to
+			// any policy so we can just remove the record. If we ever add
+			// more attributes this needs to reset the policy only.
 			if err := removeSnapshotState(st, dupErr.SetID); err != nil {
 				return 0, nil, err
 			}
@@ -467,6 +515,26 @@ func AutomaticSnapshot(st *state.State, snapName string) (ts *state.TaskSet, err
 // Restore creates a taskset for restoring a snapshot's data.
 // Note that the state must be locked by the caller.
 func Restore(st *state.State, setID uint64, snapNames []string, users []string) (snapsFound []string, ts *state.TaskSet, err error) {
+	return restore(st, setID, snapNames, users, nil)
+}
+
+// RestorePaths creates a taskset for restoring only the given paths of the
+// data of a snap in a snapshot, as listed by Files. Unlike Restore, it does
+// not restore the config of the snap.
+// Note that the state must be locked by the caller.
+func RestorePaths(st *state.State, setID uint64, snapName string, users []string, paths []string) (snapsFound []string, ts *state.TaskSet, err error) {
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("internal error: no paths to restore")
+	}
+	for _, p := range paths {
+		if err := backend.CheckRestorePath(p); err != nil {
+			return nil, nil, err
+		}
+	}
+	return restore(st, setID, []string{snapName}, users, paths)
+}
+
+func restore(st *state.State, setID uint64, snapNames []string, users []string, paths []string) (snapsFound []string, ts *state.TaskSet, err error) {
 	summaries, err := snapSummariesInSnapshotSet(setID, snapNames)
 	if err != nil {
 		return nil, nil, err
@@ -509,6 +577,9 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 		}
 
 		desc := fmt.Sprintf("Restore data of snap %q from snapshot set #%d", summary.snap, setID)
+		if len(paths) > 0 {
+			desc = fmt.Sprintf("Restore %s of snap %q from snapshot set #%d", strutil.Quoted(paths), summary.snap, setID)
+		}
 		task := st.NewTask("restore-snapshot", desc)
 		snapshot := snapshotSetup{
 			SetID:    setID,
@@ -516,6 +587,7 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 			Users:    users,
 			Filename: summary.filename,
 			Current:  current,
+			Paths:    paths,
 		}
 		task.Set("snapshot-setup", &snapshot)
 		// see the note about snapshots not using lanes, above.
@@ -535,6 +607,31 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 	return snapsFound, ts, nil
 }
 
+// Files lists the files in the data of a snap in a snapshot, limited to
+// that of the given users if any.
+// Note that the state must not be locked by the caller, as reading the
+// snapshot can take a while.
+func Files(ctx context.Context, st *state.State, setID uint64, snapName string, users []string) ([]client.SnapshotFile, error) {
+	st.Lock()
+	err := checkSnapshotConflict(st, setID, "forget-snapshot")
+	st.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	summaries, err := snapSummariesInSnapshotSet(setID, []string{snapName})
+	if err != nil {
+		return nil, err
+	}
+	reader, err := backendOpen(summaries[0].filename, backend.ExtractFnameSetID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open snapshot: %v", err)
+	}
+	defer reader.Close()
+
+	return backendFiles(reader, ctx, users)
+}
+
 // Check creates a taskset for checking a snapshot's data.
 // Note that the state must be locked by the caller.
 func Check(st *state.State, setID uint64, snapNames []string, users []string) (snapsFound []string, ts *state.TaskSet, err error) {
@@ -36,6 +36,7 @@ import (
 	"testing"
 	"time"
 
+	"filippo.io/age"
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/asserts"
@@ -1170,6 +1171,80 @@ func (snapshotSuite) TestRestore(c *check.C) {
 	})
 }
 
+func (snapshotSuite) TestRestorePaths(c *check.C) {
+	shotfile, err := os.Create(filepath.Join(c.MkDir(), "yadda.zip"))
+	c.Assert(err, check.IsNil)
+	defer shotfile.Close()
+	fakeIter := func(_ context.Context, f func(*backend.Reader) error) error {
+		for _, name := range []string{"a-snap", "b-snap"} {
+			c.Assert(f(&backend.Reader{
+				Snapshot: client.Snapshot{SetID: 42, Snap: name},
+				File:     shotfile,
+			}), check.IsNil)
+		}
+		return nil
+	}
+	defer snapshotstate.MockBackendIter(fakeIter)()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	found, taskset, err := snapshotstate.RestorePaths(st, 42, "a-snap", nil, []string{"x1/foo", "common"})
+	c.Assert(err, check.IsNil)
+	c.Check(found, check.DeepEquals, []string{"a-snap"})
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 2)
+	c.Check(tasks[0].Kind(), check.Equals, "restore-snapshot")
+	c.Check(tasks[1].Kind(), check.Equals, "cleanup-after-restore")
+	c.Check(tasks[0].Summary(), check.Equals, `Restore "x1/foo", "common" of snap "a-snap" from snapshot set #42`)
+	var snapshot map[string]interface{}
+	c.Check(tasks[0].Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot, check.DeepEquals, map[string]interface{}{
+		"set-id":   42.,
+		"snap":     "a-snap",
+		"filename": shotfile.Name(),
+		"current":  "unset",
+		"paths":    []interface{}{"x1/foo", "common"},
+	})
+
+	_, _, err = snapshotstate.RestorePaths(st, 42, "a-snap", nil, []string{"x1/../foo"})
+	c.Check(err, check.ErrorMatches, `invalid path to restore "x1/../foo": .*`)
+	_, _, err = snapshotstate.RestorePaths(st, 42, "c-snap", nil, []string{"x1/foo"})
+	c.Check(err, check.Equals, client.ErrSnapshotSnapsNotFound)
+}
+
+func (snapshotSuite) TestFiles(c *check.C) {
+	shotfile, err := os.Create(filepath.Join(c.MkDir(), "yadda.zip"))
+	c.Assert(err, check.IsNil)
+	defer shotfile.Close()
+	defer snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		return f(&backend.Reader{
+			Snapshot: client.Snapshot{SetID: 42, Snap: "a-snap"},
+			File:     shotfile,
+		})
+	})()
+	defer snapshotstate.MockBackendOpen(func(filename string, setID uint64) (*backend.Reader, error) {
+		c.Check(filename, check.Equals, shotfile.Name())
+		return &backend.Reader{}, nil
+	})()
+	files := []client.SnapshotFile{{Path: "x1/foo"}, {User: "a-user", Path: "common"}}
+	defer snapshotstate.MockBackendFiles(func(_ *backend.Reader, _ context.Context, users []string) ([]client.SnapshotFile, error) {
+		c.Check(users, check.DeepEquals, []string{"a-user"})
+		return files, nil
+	})()
+
+	st := state.New(nil)
+	listed, err := snapshotstate.Files(context.TODO(), st, 42, "a-snap", []string{"a-user"})
+	c.Assert(err, check.IsNil)
+	c.Check(listed, check.DeepEquals, files)
+
+	_, err = snapshotstate.Files(context.TODO(), st, 43, "a-snap", nil)
+	c.Check(err, check.Equals, client.ErrSnapshotSetNotFound)
+	_, err = snapshotstate.Files(context.TODO(), st, 42, "b-snap", nil)
+	c.Check(err, check.Equals, client.ErrSnapshotSnapsNotFound)
+}
+
 func (snapshotSuite) TestRestoreIntegration(c *check.C) {
 	testRestoreIntegration(c, dirs.UserHomeSnapDir, nil)
 }
@@ -1822,12 +1897,33 @@ func (snapshotSuite) TestImportSnapshotHappy(c *check.C) {
 	})
 	defer restore()
 
-	sid, names, err := snapshotstate.Import(context.TODO(), st, buf)
+	sid, names, err := snapshotstate.Import(context.TODO(), st, buf, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(sid, check.Equals, uint64(1))
 	c.Check(names, check.DeepEquals, fakeSnapNames)
 }
 
+func (snapshotSuite) TestImportSnapshotEncrypted(c *check.C) {
+	st := state.New(nil)
+
+	id, err := age.GenerateX25519Identity()
+	c.Assert(err, check.IsNil)
+	var importCalls int
+	restore := snapshotstate.MockBackendImport(func(ctx context.Context, setID uint64, r io.Reader, flags *backend.ImportFlags) ([]string, error) {
+		importCalls++
+		c.Assert(flags, check.NotNil)
+		c.Check(flags.Identities, check.DeepEquals, []age.Identity{id})
+		return []string{"foo"}, nil
+	})
+	defer restore()
+
+	sid, names, err := snapshotstate.Import(context.TODO(), st, bytes.NewBufferString("encrypted"), []age.Identity{id})
+	c.Assert(err, check.IsNil)
+	c.Check(importCalls, check.Equals, 1)
+	c.Check(sid, check.Equals, uint64(1))
+	c.Check(names, check.DeepEquals, []string{"foo"})
+}
+
 func (snapshotSuite) TestImportSnapshotImportError(c *check.C) {
 	st := state.New(nil)
 
@@ -1837,7 +1933,7 @@ func (snapshotSuite) TestImportSnapshotImportError(c *check.C) {
 	defer restore()
 
 	r := bytes.NewBufferString("faked-import-data")
-	sid, _, err := snapshotstate.Import(context.TODO(), st, r)
+	sid, _, err := snapshotstate.Import(context.TODO(), st, r, nil)
 	c.Assert(err, check.NotNil)
 	c.Assert(err.Error(), check.Equals, "some-error")
 	c.Check(sid, check.Equals, uint64(0))
@@ -1858,7 +1954,7 @@ func (snapshotSuite) TestImportSnapshotDuplicate(c *check.C) {
 	})
 	st.Unlock()
 
-	sid, snapNames, err := snapshotstate.Import(context.TODO(), st, bytes.NewBufferString(""))
+	sid, snapNames, err := snapshotstate.Import(context.TODO(), st, bytes.NewBufferString(""), nil)
 	c.Assert(err, check.IsNil)
 	c.Check(sid, check.Equals, uint64(3))
 	c.Check(snapNames, check.DeepEquals, []string{"foo-snap"})
@@ -1992,7 +2088,7 @@ func (snapshotSuite) TestImportSnapshotDuplicatedNoConflict(c *check.C) {
 	defer restore()
 
 	st := state.New(nil)
-	setID, snaps, err := snapshotstate.Import(context.TODO(), st, buf)
+	setID, snaps, err := snapshotstate.Import(context.TODO(), st, buf, nil)
 	c.Check(importCalls, check.Equals, 1)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(42))
@@ -2032,7 +2128,7 @@ func (snapshotSuite) TestImportSnapshotConflictsWithForget(c *check.C) {
 	chg.AddTask(tsk)
 
 	st.Unlock()
-	setID, snaps, err := snapshotstate.Import(context.TODO(), st, buf)
+	setID, snaps, err := snapshotstate.Import(context.TODO(), st, buf, nil)
 	st.Lock()
 	c.Check(importCalls, check.Equals, 2)
 	c.Assert(err, check.IsNil)
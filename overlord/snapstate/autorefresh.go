@@ -23,9 +23,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/httputil"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
@@ -265,6 +272,206 @@ func (m *autoRefresh) canRefreshRespectingMetered(now, lastRefresh time.Time) (c
 	return false, nil
 }
 
+// the checks of the system being idle that refresh.idle can list
+const (
+	// the load average per CPU is below refresh.idle-max-load
+	idleCheckLoad = "load"
+	// no user is logged in
+	idleCheckSessions = "sessions"
+	// no snap marked itself busy with "snapctl set-busy"
+	idleCheckBusySnaps = "busy-snaps"
+)
+
+// the default of refresh.idle-max-load
+const defaultIdleMaxLoad = 0.5
+
+// cannot mark a snap busy for more than maxBusyDuration at once
+const maxBusyDuration = 24 * time.Hour
+
+var (
+	loadAverage    = loadAverageImpl
+	activeSessions = activeSessionsImpl
+)
+
+// loadAverageImpl returns the load average over the last 5 minutes.
+func loadAverageImpl() (float64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dirs.GlobalRootDir, "/proc/loadavg"))
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("cannot parse load average %q", data)
+	}
+	return strconv.ParseFloat(fields[1], 64)
+}
+
+// activeSessionsImpl returns the number of active user sessions known to
+// logind.
+func activeSessionsImpl() (int, error) {
+	sessionFiles, err := filepath.Glob(filepath.Join(dirs.GlobalRootDir, "/run/systemd/sessions/*[0-9]"))
+	if err != nil {
+		return 0, err
+	}
+	active := 0
+	for _, sessionFile := range sessionFiles {
+		data, err := ioutil.ReadFile(sessionFile)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// the session is gone since
+				continue
+			}
+			return 0, err
+		}
+		session := make(map[string]string)
+		for _, line := range strings.Split(string(data), "\n") {
+			if kv := strings.SplitN(line, "=", 2); len(kv) == 2 {
+				session[kv[0]] = kv[1]
+			}
+		}
+		if session["CLASS"] == "user" && session["STATE"] == "active" {
+			active++
+		}
+	}
+	return active, nil
+}
+
+// SetBusy marks the snap as busy until the given time, or clears the mark
+// if the time is zero. Auto-refreshes are postponed while snaps are busy
+// if refresh.idle lists busy-snaps.
+func SetBusy(st *state.State, snapName string, until time.Time) error {
+	if !until.IsZero() && until.Sub(timeNow()) > maxBusyDuration {
+		return fmt.Errorf("cannot mark snap %q busy for more than %s", snapName, maxBusyDuration)
+	}
+	busy, err := busySnaps(st)
+	if err != nil {
+		return err
+	}
+	now := timeNow()
+	for name, t := range busy {
+		if !t.After(now) {
+			delete(busy, name)
+		}
+	}
+	if until.IsZero() {
+		delete(busy, snapName)
+	} else {
+		busy[snapName] = until
+	}
+	if len(busy) == 0 {
+		st.Set("refresh-busy-snaps", nil)
+	} else {
+		st.Set("refresh-busy-snaps", busy)
+	}
+	return nil
+}
+
+func busySnaps(st *state.State) (map[string]time.Time, error) {
+	var busy map[string]time.Time
+	if err := st.Get("refresh-busy-snaps", &busy); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if busy == nil {
+		busy = make(map[string]time.Time)
+	}
+	return busy, nil
+}
+
+func getIdleRefreshConf(st *state.State) (checks []string, maxLoad float64, err error) {
+	tr := config.NewTransaction(st)
+	var idle string
+	if err := tr.GetMaybe("core", "refresh.idle", &idle); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, 0, err
+	}
+	checks = strutil.CommaSeparatedList(idle)
+	if len(checks) == 0 {
+		return nil, 0, nil
+	}
+	var maxLoadStr string
+	if err := tr.GetMaybe("core", "refresh.idle-max-load", &maxLoadStr); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, 0, err
+	}
+	maxLoad = defaultIdleMaxLoad
+	if maxLoadStr != "" {
+		if maxLoad, err = strconv.ParseFloat(maxLoadStr, 64); err != nil {
+			return nil, 0, fmt.Errorf("cannot parse refresh.idle-max-load: %v", err)
+		}
+	}
+	return checks, maxLoad, nil
+}
+
+// busyReason returns why the system is not idle according to the checks
+// listed in refresh.idle, or an empty string if it is idle.
+func busyReason(st *state.State, checks []string, maxLoad float64) (string, error) {
+	var reasons []string
+	for _, check := range checks {
+		switch check {
+		case idleCheckLoad:
+			load, err := loadAverage()
+			if err != nil {
+				// do not hold refreshes because of this
+				logger.Noticef("Cannot get the load average: %v", err)
+				continue
+			}
+			if perCPU := load / float64(runtime.NumCPU()); perCPU > maxLoad {
+				reasons = append(reasons, fmt.Sprintf("load average per CPU %.2f above %.2f", perCPU, maxLoad))
+			}
+		case idleCheckSessions:
+			n, err := activeSessions()
+			if err != nil {
+				logger.Noticef("Cannot get the active login sessions: %v", err)
+				continue
+			}
+			if n > 0 {
+				reasons = append(reasons, fmt.Sprintf("%d active login sessions", n))
+			}
+		case idleCheckBusySnaps:
+			busy, err := busySnaps(st)
+			if err != nil {
+				return "", err
+			}
+			now := timeNow()
+			var names []string
+			for name, until := range busy {
+				if until.After(now) {
+					names = append(names, name)
+				}
+			}
+			if len(names) > 0 {
+				sort.Strings(names)
+				reasons = append(reasons, fmt.Sprintf("snaps marked busy: %s", strings.Join(names, ", ")))
+			}
+		}
+	}
+	return strings.Join(reasons, "; "), nil
+}
+
+func (m *autoRefresh) canRefreshRespectingIdle(now, lastRefresh time.Time) (can bool, err error) {
+	checks, maxLoad, err := getIdleRefreshConf(m.state)
+	if err != nil {
+		return false, err
+	}
+	if len(checks) == 0 {
+		return true, nil
+	}
+	reason, err := busyReason(m.state, checks, maxLoad)
+	if err != nil {
+		return false, err
+	}
+	if reason == "" {
+		return true, nil
+	}
+
+	if now.Sub(lastRefresh) >= maxPostponement {
+		logger.Noticef("Auto refresh postponed while the system is busy (%s), but pending for too long (%d days). Trying to refresh now.", reason, int(maxPostponement.Hours()/24))
+		return true, nil
+	}
+
+	logger.Debugf("Auto refresh postponed while the system is busy: %s", reason)
+
+	return false, nil
+}
+
 // Ensure ensures that we refresh all installed snaps periodically
 func (m *autoRefresh) Ensure() error {
 	m.state.Lock()
@@ -359,6 +566,15 @@ func (m *autoRefresh) Ensure() error {
 				m.nextRefresh = time.Time{}
 				return nil
 			}
+			can, err = m.canRefreshRespectingIdle(now, lastRefresh)
+			if err != nil {
+				return err
+			}
+			if !can {
+				// try again later in the refresh window
+				m.nextRefresh = time.Time{}
+				return nil
+			}
 
 			// Check that we have reasonable delays between attempts.
 			// If the store is under stress we need to make sure we do not
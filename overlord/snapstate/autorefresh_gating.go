@@ -421,29 +421,42 @@ func HeldSnaps(st *state.State) (map[string]bool, error) {
 	now := timeNow()
 
 	held := make(map[string]bool)
-Loop:
 	for heldSnap, holds := range gating {
-		lastRefresh, err := lastRefreshed(st, heldSnap)
+		holding, err := holdingSnaps(st, heldSnap, holds, now)
 		if err != nil {
 			return nil, err
 		}
-
-		for holdingSnap, hold := range holds {
-			// enforce the maxPostponement limit on a hold, unless it's held by the user
-			if holdingSnap != "system" && lastRefresh.Add(maxPostponement).Before(now) {
-				continue
-			}
-
-			if hold.HoldUntil.Before(now) {
-				continue
-			}
+		if len(holding) > 0 {
 			held[heldSnap] = true
-			continue Loop
 		}
 	}
 	return held, nil
 }
 
+// holdingSnaps returns the sorted names of the snaps (or "system" for the
+// user) whose holds on the refresh of heldSnap are still in effect.
+func holdingSnaps(st *state.State, heldSnap string, holds map[string]*holdState, now time.Time) ([]string, error) {
+	lastRefresh, err := lastRefreshed(st, heldSnap)
+	if err != nil {
+		return nil, err
+	}
+
+	var holding []string
+	for holdingSnap, hold := range holds {
+		// enforce the maxPostponement limit on a hold, unless it's held by the user
+		if holdingSnap != "system" && lastRefresh.Add(maxPostponement).Before(now) {
+			continue
+		}
+
+		if hold.HoldUntil.Before(now) {
+			continue
+		}
+		holding = append(holding, holdingSnap)
+	}
+	sort.Strings(holding)
+	return holding, nil
+}
+
 type AffectedSnapInfo struct {
 	Restart        bool
 	Base           bool
@@ -22,6 +22,7 @@ package snapstate_test
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -924,6 +925,189 @@ func (s *autoRefreshTestSuite) TestRefreshOnMeteredConnNotMetered(c *C) {
 	c.Check(s.store.ops, DeepEquals, []string{"list-refresh"})
 }
 
+func (s *autoRefreshTestSuite) testRefreshIdleBusy(c *C, idle string) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.idle", idle)
+	tr.Commit()
+
+	af := snapstate.NewAutoRefresh(s.state)
+
+	s.state.Set("last-refresh", time.Now().Add(-5*24*time.Hour))
+	s.state.Unlock()
+	err := af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+	// no refresh
+	c.Check(s.store.ops, HasLen, 0)
+
+	c.Check(af.NextRefresh(), DeepEquals, time.Time{})
+
+	// last refresh over 96 days ago, new one is launched regardless of
+	// the system being busy
+	s.state.Set("last-refresh", time.Now().Add(-96*24*time.Hour))
+	s.state.Unlock()
+	err = af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+	c.Check(s.store.ops, DeepEquals, []string{"list-refresh"})
+}
+
+func (s *autoRefreshTestSuite) TestRefreshIdleHighLoad(c *C) {
+	restore := snapstate.MockLoadAverage(func() (float64, error) {
+		return 1000, nil
+	})
+	defer restore()
+
+	s.testRefreshIdleBusy(c, "load")
+}
+
+func (s *autoRefreshTestSuite) TestRefreshIdleActiveSessions(c *C) {
+	restore := snapstate.MockActiveSessions(func() (int, error) {
+		return 1, nil
+	})
+	defer restore()
+
+	s.testRefreshIdleBusy(c, "load,sessions")
+}
+
+func (s *autoRefreshTestSuite) TestRefreshIdleBusySnaps(c *C) {
+	s.state.Lock()
+	err := snapstate.SetBusy(s.state, "some-snap", time.Now().Add(time.Hour))
+	s.state.Unlock()
+	c.Assert(err, IsNil)
+
+	s.testRefreshIdleBusy(c, "busy-snaps")
+}
+
+func (s *autoRefreshTestSuite) TestRefreshIdleIsIdle(c *C) {
+	restore := snapstate.MockLoadAverage(func() (float64, error) {
+		return 0, nil
+	})
+	defer restore()
+	restore = snapstate.MockActiveSessions(func() (int, error) {
+		return 0, nil
+	})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// an expired busy mark does not count
+	s.state.Set("refresh-busy-snaps", map[string]time.Time{
+		"some-snap": time.Now().Add(-time.Minute),
+	})
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.idle", "load,sessions,busy-snaps")
+	tr.Commit()
+
+	af := snapstate.NewAutoRefresh(s.state)
+
+	s.state.Set("last-refresh", time.Now().Add(-5*24*time.Hour))
+	s.state.Unlock()
+	err := af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+	c.Check(s.store.ops, DeepEquals, []string{"list-refresh"})
+}
+
+func (s *autoRefreshTestSuite) TestRefreshIdleChecksFailing(c *C) {
+	restore := snapstate.MockLoadAverage(func() (float64, error) {
+		return 0, fmt.Errorf("boom")
+	})
+	defer restore()
+	restore = snapstate.MockActiveSessions(func() (int, error) {
+		return 0, fmt.Errorf("boom")
+	})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.idle", "load,sessions")
+	tr.Commit()
+
+	af := snapstate.NewAutoRefresh(s.state)
+
+	s.state.Set("last-refresh", time.Now().Add(-5*24*time.Hour))
+	s.state.Unlock()
+	err := af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+	// failing checks do not hold refreshes
+	c.Check(s.store.ops, DeepEquals, []string{"list-refresh"})
+}
+
+func (s *autoRefreshTestSuite) TestSetBusy(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	now := time.Now()
+	restore := snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	err := snapstate.SetBusy(s.state, "foo", now.Add(time.Hour))
+	c.Assert(err, IsNil)
+	err = snapstate.SetBusy(s.state, "bar", now.Add(2*time.Hour))
+	c.Assert(err, IsNil)
+
+	var busy map[string]time.Time
+	c.Assert(s.state.Get("refresh-busy-snaps", &busy), IsNil)
+	c.Check(busy, HasLen, 2)
+	c.Check(busy["foo"].Equal(now.Add(time.Hour)), Equals, true)
+
+	// expired marks are dropped
+	now = now.Add(90 * time.Minute)
+	err = snapstate.SetBusy(s.state, "bar", time.Time{})
+	c.Assert(err, IsNil)
+	err = s.state.Get("refresh-busy-snaps", &busy)
+	c.Check(err, testutil.ErrorIs, state.ErrNoState)
+
+	err = snapstate.SetBusy(s.state, "foo", now.Add(25*time.Hour))
+	c.Check(err, ErrorMatches, `cannot mark snap "foo" busy for more than 24h0m0s`)
+}
+
+func (s *autoRefreshTestSuite) TestLoadAverage(c *C) {
+	p := filepath.Join(dirs.GlobalRootDir, "/proc/loadavg")
+	c.Assert(os.MkdirAll(filepath.Dir(p), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(p, []byte("0.10 1.25 2.00 1/123 4567\n"), 0644), IsNil)
+
+	load, err := snapstate.LoadAverageImpl()
+	c.Assert(err, IsNil)
+	c.Check(load, Equals, 1.25)
+
+	c.Assert(ioutil.WriteFile(p, []byte("garbage"), 0644), IsNil)
+	_, err = snapstate.LoadAverageImpl()
+	c.Check(err, ErrorMatches, `cannot parse load average "garbage"`)
+}
+
+func (s *autoRefreshTestSuite) TestActiveSessions(c *C) {
+	d := filepath.Join(dirs.GlobalRootDir, "/run/systemd/sessions")
+	c.Assert(os.MkdirAll(d, 0755), IsNil)
+
+	n, err := snapstate.ActiveSessionsImpl()
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 0)
+
+	for name, content := range map[string]string{
+		"1":     "UID=1000\nCLASS=user\nSTATE=active\n",
+		"2":     "UID=1000\nCLASS=user\nSTATE=online\n",
+		"3":     "UID=0\nCLASS=greeter\nSTATE=active\n",
+		"c4":    "UID=1001\nCLASS=user\nSTATE=active\n",
+		"1.ref": "ignored",
+	} {
+		c.Assert(ioutil.WriteFile(filepath.Join(d, name), []byte(content), 0644), IsNil)
+	}
+
+	n, err = snapstate.ActiveSessionsImpl()
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 2)
+}
+
 func (s *autoRefreshTestSuite) TestInitialInhibitRefreshWithinInhibitWindow(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -297,6 +297,21 @@ func (f *fakeStore) snap(spec snapSpec) (*snap.Info, error) {
 				DaemonScope: "user",
 			},
 		}
+	case "channel-for-user-daemon-in-snap-yaml":
+		// the store fills in the apps from the snap.yaml it serves
+		yamlInfo, err := snap.InfoFromSnapYaml([]byte(fmt.Sprintf(`name: %s
+apps:
+  user-daemon:
+    daemon: simple
+    daemon-scope: user
+`, spec.Name)))
+		if err != nil {
+			panic(err)
+		}
+		info.Apps = yamlInfo.Apps
+		for _, app := range info.Apps {
+			app.Snap = info
+		}
 	case "channel-for-dbus-activation":
 		slot := &snap.SlotInfo{
 			Snap:      info,
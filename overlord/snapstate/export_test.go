@@ -369,6 +369,27 @@ func MockTimeNow(f func() time.Time) (restore func()) {
 	}
 }
 
+var (
+	LoadAverageImpl    = loadAverageImpl
+	ActiveSessionsImpl = activeSessionsImpl
+)
+
+func MockLoadAverage(f func() (float64, error)) (restore func()) {
+	old := loadAverage
+	loadAverage = f
+	return func() {
+		loadAverage = old
+	}
+}
+
+func MockActiveSessions(f func() (int, error)) (restore func()) {
+	old := activeSessions
+	activeSessions = f
+	return func() {
+		activeSessions = old
+	}
+}
+
 func MockHoldState(firstHeld string, holdUntil string) *HoldState {
 	first, err := time.Parse(time.RFC3339, firstHeld)
 	if err != nil {
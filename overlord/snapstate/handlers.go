@@ -73,6 +73,11 @@ var SecurityProfilesRemoveLate = func(snapName string, rev snap.Revision, typ sn
 	panic("internal error: snapstate.SecurityProfilesRemoveLate is unset")
 }
 
+// StoppingServicesForRefresh is a hook set by healthstate, called with the
+// state unlocked right before the services of a snap are stopped for a
+// refresh.
+var StoppingServicesForRefresh = func(st *state.State, instanceName string, svcs []*snap.AppInfo) {}
+
 // TaskSnapSetup returns the SnapSetup with task params hold by or referred to by the task.
 func TaskSnapSetup(t *state.Task) (*SnapSetup, error) {
 	var snapsup SnapSetup
@@ -2535,6 +2540,10 @@ func (m *SnapManager) stopSnapServices(t *state.Task, _ *tomb.Tomb) error {
 	st.Unlock()
 	defer st.Lock()
 
+	if stopReason == snap.StopReasonRefresh {
+		StoppingServicesForRefresh(st, snapsup.InstanceName(), svcs)
+	}
+
 	// stop the services
 	err = m.backend.StopServices(svcs, stopReason, pb, perfTimings)
 	if err != nil {
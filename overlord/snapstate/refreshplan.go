@@ -0,0 +1,204 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// PlannedRefresh describes what refreshing an installed snap to the
+// revision offered by the store would change.
+type PlannedRefresh struct {
+	// Current is the installed revision of the snap.
+	Current *snap.Info
+	// Candidate is the revision of the snap offered by the store.
+	Candidate *snap.Info
+
+	// DownloadSize is the size of the full snap.
+	DownloadSize int64
+	// DeltaSize is the size of the delta from the installed revision,
+	// or 0 if the store does not offer one.
+	DeltaSize int64
+
+	PlugsAdded   []*snap.PlugInfo
+	PlugsRemoved []*snap.PlugInfo
+	SlotsAdded   []*snap.SlotInfo
+	SlotsRemoved []*snap.SlotInfo
+
+	ServicesAdded   []string
+	ServicesRemoved []string
+	HooksAdded      []string
+	HooksRemoved    []string
+
+	// ContentProvidersAdded and ContentProvidersRemoved list the changes
+	// to the default providers of the content plugs of the snap.
+	ContentProvidersAdded   []string
+	ContentProvidersRemoved []string
+
+	// HeldBy lists the gating snaps, or "system" for the user, holding
+	// the refresh of the snap.
+	HeldBy []string
+}
+
+// BaseChanged returns whether the candidate uses a different base than the
+// installed revision.
+func (p *PlannedRefresh) BaseChanged() bool {
+	return p.Current.Base != p.Candidate.Base
+}
+
+// RefreshPlan asks the store for the refresh candidates of the installed
+// snaps, like RefreshCandidates, and describes what refreshing each of them
+// would change.
+// Note that the state must be locked by the caller.
+func RefreshPlan(st *state.State, user *auth.UserState) ([]*PlannedRefresh, error) {
+	updates, stateByInstanceName, _, err := refreshCandidates(context.TODO(), st, nil, user, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	gating, err := refreshGating(st)
+	if err != nil {
+		return nil, err
+	}
+	now := timeNow()
+
+	plan := make([]*PlannedRefresh, 0, len(updates))
+	for _, update := range updates {
+		instanceName := update.InstanceName()
+		snapst := stateByInstanceName[instanceName]
+		if snapst == nil {
+			return nil, fmt.Errorf("internal error: no state for refresh candidate %q", instanceName)
+		}
+		current, err := snapst.CurrentInfo()
+		if err != nil {
+			return nil, err
+		}
+
+		planned := planRefresh(current, update)
+		if holds := gating[instanceName]; len(holds) > 0 {
+			planned.HeldBy, err = holdingSnaps(st, instanceName, holds, now)
+			if err != nil {
+				return nil, err
+			}
+		}
+		plan = append(plan, planned)
+	}
+	sort.Slice(plan, func(i, j int) bool {
+		return plan[i].Current.InstanceName() < plan[j].Current.InstanceName()
+	})
+
+	return plan, nil
+}
+
+func planRefresh(current, candidate *snap.Info) *PlannedRefresh {
+	planned := &PlannedRefresh{
+		Current:      current,
+		Candidate:    candidate,
+		DownloadSize: candidate.Size,
+	}
+
+	for _, delta := range candidate.Deltas {
+		if delta.FromRevision == current.Revision.N && delta.ToRevision == candidate.Revision.N {
+			planned.DeltaSize = delta.Size
+			break
+		}
+	}
+
+	for name, plug := range candidate.Plugs {
+		if old, ok := current.Plugs[name]; !ok || old.Interface != plug.Interface {
+			planned.PlugsAdded = append(planned.PlugsAdded, plug)
+		}
+	}
+	for name, plug := range current.Plugs {
+		if cand, ok := candidate.Plugs[name]; !ok || cand.Interface != plug.Interface {
+			planned.PlugsRemoved = append(planned.PlugsRemoved, plug)
+		}
+	}
+	for name, slot := range candidate.Slots {
+		if old, ok := current.Slots[name]; !ok || old.Interface != slot.Interface {
+			planned.SlotsAdded = append(planned.SlotsAdded, slot)
+		}
+	}
+	for name, slot := range current.Slots {
+		if cand, ok := candidate.Slots[name]; !ok || cand.Interface != slot.Interface {
+			planned.SlotsRemoved = append(planned.SlotsRemoved, slot)
+		}
+	}
+	sort.Slice(planned.PlugsAdded, func(i, j int) bool { return planned.PlugsAdded[i].Name < planned.PlugsAdded[j].Name })
+	sort.Slice(planned.PlugsRemoved, func(i, j int) bool { return planned.PlugsRemoved[i].Name < planned.PlugsRemoved[j].Name })
+	sort.Slice(planned.SlotsAdded, func(i, j int) bool { return planned.SlotsAdded[i].Name < planned.SlotsAdded[j].Name })
+	sort.Slice(planned.SlotsRemoved, func(i, j int) bool { return planned.SlotsRemoved[i].Name < planned.SlotsRemoved[j].Name })
+
+	planned.ServicesAdded, planned.ServicesRemoved = diffNames(serviceNames(current), serviceNames(candidate))
+	planned.HooksAdded, planned.HooksRemoved = diffNames(hookNames(current), hookNames(candidate))
+	planned.ContentProvidersAdded, planned.ContentProvidersRemoved = diffNames(contentProviders(current), contentProviders(candidate))
+
+	return planned
+}
+
+func serviceNames(info *snap.Info) map[string]bool {
+	names := make(map[string]bool)
+	for name, app := range info.Apps {
+		if app.IsService() {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+func hookNames(info *snap.Info) map[string]bool {
+	names := make(map[string]bool, len(info.Hooks))
+	for name := range info.Hooks {
+		names[name] = true
+	}
+	return names
+}
+
+func contentProviders(info *snap.Info) map[string]bool {
+	providers := snap.NeededDefaultProviders(info)
+	names := make(map[string]bool, len(providers))
+	for name := range providers {
+		names[name] = true
+	}
+	return names
+}
+
+// diffNames returns the sorted names only in after and only in before.
+func diffNames(before, after map[string]bool) (added, removed []string) {
+	for name := range after {
+		if !before[name] {
+			added = append(added, name)
+		}
+	}
+	for name := range before {
+		if !after[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
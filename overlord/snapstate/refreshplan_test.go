@@ -0,0 +1,242 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/storetest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type refreshPlanStore struct {
+	storetest.Store
+
+	results []store.SnapActionResult
+}
+
+func (r *refreshPlanStore) SnapAction(ctx context.Context, currentSnaps []*store.CurrentSnap, actions []*store.SnapAction, assertQuery store.AssertionQuery, user *auth.UserState, opts *store.RefreshOptions) ([]store.SnapActionResult, []store.AssertionResult, error) {
+	for _, a := range actions {
+		if a.Action != "refresh" {
+			panic("expected refresh actions")
+		}
+	}
+	return r.results, nil, nil
+}
+
+type refreshPlanSuite struct {
+	testutil.BaseTest
+	state *state.State
+	store *refreshPlanStore
+}
+
+var _ = Suite(&refreshPlanSuite{})
+
+func (s *refreshPlanSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	s.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+
+	s.state = state.New(nil)
+	s.store = &refreshPlanStore{}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.ReplaceStore(s.state, s.store)
+	s.state.Set("refresh-privacy-key", "privacy-key")
+	s.AddCleanup(snapstatetest.MockDeviceModel(DefaultModel()))
+
+	restore := snapstate.MockEnforcedValidationSets(func(st *state.State, extraVss ...*asserts.ValidationSet) (*snapasserts.ValidationSets, error) {
+		return nil, nil
+	})
+	s.AddCleanup(restore)
+}
+
+func (s *refreshPlanSuite) mockInstalled(c *C, yaml string, si *snap.SideInfo) {
+	snaptest.MockSnap(c, yaml, si)
+	lastRefresh := time.Now().Add(-time.Hour)
+	snapstate.Set(s.state, si.RealName, &snapstate.SnapState{
+		Active:          true,
+		Sequence:        []*snap.SideInfo{si},
+		Current:         si.Revision,
+		SnapType:        "app",
+		LastRefreshTime: &lastRefresh,
+	})
+}
+
+func (s *refreshPlanSuite) mockCandidate(c *C, yaml string, si *snap.SideInfo, size int64, deltas []snap.DeltaInfo) {
+	info, err := snap.InfoFromSnapYaml([]byte(yaml))
+	c.Assert(err, IsNil)
+	info.SideInfo = *si
+	info.Size = size
+	info.Deltas = deltas
+	s.store.results = append(s.store.results, store.SnapActionResult{Info: info})
+}
+
+func (s *refreshPlanSuite) TestRefreshPlan(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockInstalled(c, `name: foo
+version: 1
+base: core20
+apps:
+  foo:
+    command: bin/foo
+    plugs: [network, home]
+  old-svc:
+    command: bin/old
+    daemon: simple
+hooks:
+  configure:
+plugs:
+  themes:
+    interface: content
+    content: themes
+    target: $SNAP/themes
+    default-provider: old-themes
+slots:
+  foo-dbus:
+    interface: dbus
+    bus: session
+    name: org.example.foo
+`, &snap.SideInfo{RealName: "foo", SnapID: "foo-id", Revision: snap.R(1)})
+	s.mockCandidate(c, `name: foo
+version: 2
+base: core22
+apps:
+  foo:
+    command: bin/foo
+    plugs: [network, camera]
+  new-svc:
+    command: bin/new
+    daemon: simple
+hooks:
+  configure:
+  install:
+plugs:
+  themes:
+    interface: content
+    content: themes
+    target: $SNAP/themes
+    default-provider: new-themes
+slots:
+  foo-dbus:
+    interface: dbus
+    bus: session
+    name: org.example.foo
+`, &snap.SideInfo{RealName: "foo", SnapID: "foo-id", Revision: snap.R(2)}, 10000, []snap.DeltaInfo{
+		{FromRevision: 0, ToRevision: 2, Size: 5000},
+		{FromRevision: 1, ToRevision: 2, Size: 1000},
+	})
+
+	s.mockInstalled(c, "name: bar\nversion: 1\n", &snap.SideInfo{RealName: "bar", SnapID: "bar-id", Revision: snap.R(3)})
+	s.mockCandidate(c, "name: bar\nversion: 1.1\n", &snap.SideInfo{RealName: "bar", SnapID: "bar-id", Revision: snap.R(4)}, 2000, nil)
+
+	// bar is held by foo and the user, foo's hold on itself expired
+	s.state.Set("snaps-hold", map[string]map[string]interface{}{
+		"bar": {
+			"foo":    map[string]interface{}{"first-held": time.Now(), "hold-until": time.Now().Add(time.Hour)},
+			"system": map[string]interface{}{"first-held": time.Now(), "hold-until": time.Now().Add(time.Hour)},
+		},
+		"foo": {
+			"foo": map[string]interface{}{"first-held": time.Now(), "hold-until": time.Now().Add(-time.Hour)},
+		},
+	})
+
+	plan, err := snapstate.RefreshPlan(s.state, nil)
+	c.Assert(err, IsNil)
+	c.Assert(plan, HasLen, 2)
+
+	bar := plan[0]
+	c.Check(bar.Current.InstanceName(), Equals, "bar")
+	c.Check(bar.Current.Revision, Equals, snap.R(3))
+	c.Check(bar.Candidate.Revision, Equals, snap.R(4))
+	c.Check(bar.DownloadSize, Equals, int64(2000))
+	c.Check(bar.DeltaSize, Equals, int64(0))
+	c.Check(bar.PlugsAdded, HasLen, 0)
+	c.Check(bar.PlugsRemoved, HasLen, 0)
+	c.Check(bar.ServicesAdded, HasLen, 0)
+	c.Check(bar.BaseChanged(), Equals, false)
+	c.Check(bar.HeldBy, DeepEquals, []string{"foo", "system"})
+
+	foo := plan[1]
+	c.Check(foo.Current.InstanceName(), Equals, "foo")
+	c.Check(foo.DownloadSize, Equals, int64(10000))
+	c.Check(foo.DeltaSize, Equals, int64(1000))
+	c.Assert(foo.PlugsAdded, HasLen, 1)
+	c.Check(foo.PlugsAdded[0].Name, Equals, "camera")
+	c.Assert(foo.PlugsRemoved, HasLen, 1)
+	c.Check(foo.PlugsRemoved[0].Name, Equals, "home")
+	c.Check(foo.SlotsAdded, HasLen, 0)
+	c.Check(foo.SlotsRemoved, HasLen, 0)
+	c.Check(foo.ServicesAdded, DeepEquals, []string{"new-svc"})
+	c.Check(foo.ServicesRemoved, DeepEquals, []string{"old-svc"})
+	c.Check(foo.HooksAdded, DeepEquals, []string{"install"})
+	c.Check(foo.HooksRemoved, HasLen, 0)
+	c.Check(foo.BaseChanged(), Equals, true)
+	c.Check(foo.Current.Base, Equals, "core20")
+	c.Check(foo.Candidate.Base, Equals, "core22")
+	c.Check(foo.ContentProvidersAdded, DeepEquals, []string{"new-themes"})
+	c.Check(foo.ContentProvidersRemoved, DeepEquals, []string{"old-themes"})
+	c.Check(foo.HeldBy, HasLen, 0)
+}
+
+func (s *refreshPlanSuite) TestRefreshPlanInterfaceChanged(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockInstalled(c, "name: foo\nversion: 1\nslots:\n  foo-slot:\n    interface: mpris\n", &snap.SideInfo{RealName: "foo", SnapID: "foo-id", Revision: snap.R(1)})
+	s.mockCandidate(c, "name: foo\nversion: 2\nslots:\n  foo-slot:\n    interface: dbus\n", &snap.SideInfo{RealName: "foo", SnapID: "foo-id", Revision: snap.R(2)}, 100, nil)
+
+	plan, err := snapstate.RefreshPlan(s.state, nil)
+	c.Assert(err, IsNil)
+	c.Assert(plan, HasLen, 1)
+
+	c.Assert(plan[0].SlotsAdded, HasLen, 1)
+	c.Check(plan[0].SlotsAdded[0].Interface, Equals, "dbus")
+	c.Assert(plan[0].SlotsRemoved, HasLen, 1)
+	c.Check(plan[0].SlotsRemoved[0].Interface, Equals, "mpris")
+}
+
+func (s *refreshPlanSuite) TestRefreshPlanNothingToRefresh(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockInstalled(c, "name: foo\nversion: 1\n", &snap.SideInfo{RealName: "foo", SnapID: "foo-id", Revision: snap.R(1)})
+
+	plan, err := snapstate.RefreshPlan(s.state, nil)
+	c.Assert(err, IsNil)
+	c.Check(plan, HasLen, 0)
+}
@@ -545,6 +545,15 @@ func Manager(st *state.State, runner *state.TaskRunner) (*SnapManager, error) {
 	runner.AddHandler("switch-snap", m.doSwitchSnap, nil)
 	runner.AddHandler("migrate-snap-home", m.doMigrateSnapHome, m.undoMigrateSnapHome)
 
+	// these can be run again after they failed, when retrying the
+	// change, as they only fetch what is missing, or in the case of
+	// mount-snap undo the partial setup of the snap when failing
+	for _, kind := range []string{"prerequisites", "download-snap", "mount-snap"} {
+		if err := runner.MarkIdempotent(kind); err != nil {
+			return nil, err
+		}
+	}
+
 	// control serialisation
 	runner.AddBlocked(m.blockedTask)
 
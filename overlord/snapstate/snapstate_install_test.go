@@ -3191,6 +3191,19 @@ func (s *snapmgrTestSuite) TestInstallUserDaemonsChecksFeatureFlag(c *C) {
 	c.Assert(err, ErrorMatches, "experimental feature disabled - test it by setting 'experimental.user-daemons' to true")
 }
 
+func (s *snapmgrTestSuite) TestInstallStoreUserDaemonFailsEarly(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// the user daemon is only known from the snap.yaml served by the
+	// store, still the feature flag is checked before downloading
+	opts := &snapstate.RevisionOptions{Channel: "channel-for-user-daemon-in-snap-yaml"}
+	_, err := snapstate.Install(context.Background(), s.state, "some-snap", opts, s.user.ID, snapstate.Flags{})
+	c.Assert(err, ErrorMatches, "experimental feature disabled - test it by setting 'experimental.user-daemons' to true")
+	c.Check(s.fakeStore.downloads, HasLen, 0)
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
 func (s *snapmgrTestSuite) TestInstallUserDaemonsUsupportedOnTrusty(c *C) {
 	restore := release.MockReleaseInfo(&release.OS{ID: "ubuntu", VersionID: "14.04"})
 	defer restore()
@@ -4713,6 +4713,67 @@ func (s *snapmgrTestSuite) TestUpdateManyTransactionally(c *C) {
 	}
 }
 
+func (s *snapmgrTestSuite) TestUpdateRetryAfterDownloadFailure(c *C) {
+	si := snap.SideInfo{
+		RealName: "services-snap",
+		Revision: snap.R(7),
+		SnapID:   "services-snap-id",
+	}
+	snaptest.MockSnap(c, `name: services-snap`, &si)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "services-snap", &snapstate.SnapState{
+		Active:          true,
+		Sequence:        []*snap.SideInfo{&si},
+		Current:         si.Revision,
+		SnapType:        "app",
+		TrackingChannel: "latest/stable",
+	})
+
+	s.fakeStore.downloadError["services-snap"] = fmt.Errorf("boom")
+
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	ts, err := snapstate.Update(s.state, "services-snap", &snapstate.RevisionOptions{Channel: "some-channel"}, s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+	for _, t := range chg.Tasks() {
+		switch t.Kind() {
+		case "prerequisites":
+			// the completed work is kept
+			c.Check(t.Status(), Equals, state.DoneStatus, Commentf("%s", t.Kind()))
+		case "download-snap":
+			c.Check(t.Status(), Equals, state.ErrorStatus)
+		case "check-rerefresh":
+			// not part of the lane of the snap
+		default:
+			c.Check(t.Status(), Equals, state.HoldStatus, Commentf("%s", t.Kind()))
+		}
+	}
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "services-snap", &snapst), IsNil)
+	c.Check(snapst.Current, Equals, snap.R(7))
+
+	// the store works again and the refresh is retried from the download
+	delete(s.fakeStore.downloadError, "services-snap")
+	s.fakeBackend.ops = nil
+	c.Assert(s.o.TaskRunner().Retry(chg), IsNil)
+	s.settle(c)
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Assert(snapstate.Get(s.state, "services-snap", &snapst), IsNil)
+	c.Check(snapst.Current, Equals, snap.R(11))
+	c.Check(s.fakeBackend.ops.Ops()[0], Equals, "storesvc-download")
+	c.Check(s.fakeBackend.ops.Ops(), Not(testutil.Contains), "storesvc-snap-action")
+}
+
 func (s *snapmgrTestSuite) TestUpdateManyTransactionallyFails(c *C) {
 	restore := release.MockOnClassic(true)
 	defer restore()
@@ -5731,6 +5792,55 @@ func (s *snapmgrTestSuite) TestStopSnapServicesFirstSavesSnapSetupLastActiveDisa
 	c.Assert(snapst.LastActiveDisabledServices, DeepEquals, []string{"svc1", "svc2"})
 }
 
+func (s *snapmgrTestSuite) TestStopSnapServicesForRefreshCallsHook(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	var called []string
+	old := snapstate.StoppingServicesForRefresh
+	defer func() { snapstate.StoppingServicesForRefresh = old }()
+	snapstate.StoppingServicesForRefresh = func(st *state.State, instanceName string, svcs []*snap.AppInfo) {
+		// the state is unlocked
+		st.Lock()
+		st.Unlock()
+		for _, svc := range svcs {
+			called = append(called, instanceName+"."+svc.Name)
+		}
+	}
+
+	snapstate.Set(s.state, "services-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "services-snap", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+		Active:  true,
+	})
+
+	snapsup := &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "services-snap",
+			Revision: snap.R(11),
+			SnapID:   "services-snap-id",
+		},
+	}
+
+	chg := s.state.NewChange("stop-services", "stop the services")
+	for _, reason := range []snap.ServiceStopReason{snap.StopReasonDisable, snap.StopReasonRefresh} {
+		t := s.state.NewTask("stop-snap-services", "...")
+		t.Set("stop-reason", reason)
+		t.Set("snap-setup", snapsup)
+		chg.AddTask(t)
+	}
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Err(), IsNil)
+	// only called for the refresh
+	sort.Strings(called)
+	c.Check(called, DeepEquals, []string{"services-snap.svc1", "services-snap.svc2", "services-snap.svc3"})
+}
+
 func (s *snapmgrTestSuite) TestRefreshDoesntRestoreRevisionConfig(c *C) {
 	restore := release.MockOnClassic(false)
 	defer restore()
@@ -315,6 +315,15 @@ func (c *Change) markReady() {
 	}
 }
 
+// resetReady marks the change as not ready anymore, for some of its tasks to
+// run again.
+func (c *Change) resetReady() {
+	c.status = DefaultStatus
+	c.ready = make(chan struct{})
+	c.readyTime = time.Time{}
+	c.clean = false
+}
+
 // Ready returns a channel that is closed the first time the change becomes ready.
 func (c *Change) Ready() <-chan struct{} {
 	return c.ready
@@ -326,15 +335,21 @@ func (c *Change) taskStatusChanged(t *Task, old, new Status) {
 	if old.Ready() == new.Ready() {
 		return
 	}
+	if !new.Ready() {
+		// tasks only go back to not ready when the change is
+		// retried, which resets it to not ready first
+		if c.IsReady() {
+			panic(fmt.Errorf("change %s unexpectedly became unready (%s)", c.ID(), c.Status()))
+		}
+		return
+	}
 	for _, tid := range c.taskIDs {
 		task := c.state.tasks[tid]
 		if task != t && !task.status.Ready() {
 			return
 		}
 	}
-	// Here is the exact moment when a change goes from unready to ready,
-	// and from ready to unready. For now handle only the first of those.
-	// For the latter the channel might be replaced in the future.
+	// Here is the exact moment when a change goes from unready to ready.
 	if c.IsReady() && !c.Status().Ready() {
 		panic(fmt.Errorf("change %s unexpectedly became unready (%s)", c.ID(), c.Status()))
 	}
@@ -595,6 +610,38 @@ NextLaneTask:
 	}
 }
 
+// holdLanes holds the pending tasks in the given lanes and any tasks waiting
+// on them, leaving the tasks that are done or in progress alone.
+func (c *Change) holdLanes(lanes []int) {
+	c.state.writing()
+	inLanes := make(map[int]bool, len(lanes))
+	for _, lane := range lanes {
+		inLanes[lane] = true
+	}
+	var tasks []*Task
+	for _, tid := range c.taskIDs {
+		t := c.state.tasks[tid]
+		for _, lane := range t.Lanes() {
+			if inLanes[lane] {
+				tasks = append(tasks, t)
+				break
+			}
+		}
+	}
+	seenTasks := make(map[string]bool)
+	for i := 0; i < len(tasks); i++ {
+		t := tasks[i]
+		if seenTasks[t.id] {
+			continue
+		}
+		seenTasks[t.id] = true
+		if t.Status() == DoStatus {
+			t.SetStatus(HoldStatus)
+		}
+		tasks = append(tasks, t.HaltTasks()...)
+	}
+}
+
 func (c *Change) abortTasks(tasks []*Task, abortedLanes map[int]bool, seenTasks map[string]bool) {
 	var lanes []int
 	for i := 0; i < len(tasks); i++ {
@@ -107,15 +107,9 @@ func CopyState(srcStatePath, dstStatePath string, dataEntries []string) error {
 		return fmt.Errorf("cannot copy state: must provide at least one data entry to copy")
 	}
 
-	f, err := os.Open(srcStatePath)
-	if err != nil {
-		return fmt.Errorf("cannot open state: %s", err)
-	}
-	defer f.Close()
-
 	// No need to lock/unlock the state here, srcState should not be
 	// in use at all.
-	srcState, err := ReadState(nil, f)
+	srcState, err := ReadStateFile(nil, srcStatePath)
 	if err != nil {
 		return err
 	}
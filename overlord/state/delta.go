@@ -0,0 +1,173 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/snapcore/snapd/logger"
+)
+
+var nullJSON = json.RawMessage("null")
+
+func isNullJSON(raw json.RawMessage) bool {
+	return bytes.Equal(raw, nullJSON)
+}
+
+// A Delta holds the serialized entries of the state that changed since the
+// previous checkpoint. The entries that were removed are set to null.
+type Delta struct {
+	Data     map[string]json.RawMessage `json:"data,omitempty"`
+	Changes  map[string]json.RawMessage `json:"changes,omitempty"`
+	Tasks    map[string]json.RawMessage `json:"tasks,omitempty"`
+	Warnings json.RawMessage            `json:"warnings,omitempty"`
+
+	LastChangeId int `json:"last-change-id"`
+	LastTaskId   int `json:"last-task-id"`
+	LastLaneId   int `json:"last-lane-id"`
+}
+
+// writtenState holds the serialized entries of the state as last
+// checkpointed, to compute the next delta.
+type writtenState struct {
+	data     map[string][]byte
+	changes  map[string][]byte
+	tasks    map[string][]byte
+	warnings []byte
+
+	lastChangeId int
+	lastTaskId   int
+	lastLaneId   int
+}
+
+func newWrittenState() *writtenState {
+	return &writtenState{
+		data:    make(map[string][]byte),
+		changes: make(map[string][]byte),
+		tasks:   make(map[string][]byte),
+	}
+}
+
+func applyEntries(written map[string][]byte, delta map[string]json.RawMessage) {
+	for k, v := range delta {
+		if isNullJSON(v) {
+			delete(written, k)
+		} else {
+			written[k] = v
+		}
+	}
+}
+
+// apply records the delta as checkpointed.
+func (w *writtenState) apply(delta *Delta) {
+	if delta == nil {
+		return
+	}
+	applyEntries(w.data, delta.Data)
+	applyEntries(w.changes, delta.Changes)
+	applyEntries(w.tasks, delta.Tasks)
+	if delta.Warnings != nil {
+		w.warnings = delta.Warnings
+	}
+	w.lastChangeId = delta.LastChangeId
+	w.lastTaskId = delta.LastTaskId
+	w.lastLaneId = delta.LastLaneId
+}
+
+// diffEntries returns the entries that differ from the written ones,
+// serialized with marshal, with the removed ones set to null.
+func diffEntries(written map[string][]byte, ids []string, marshal func(id string) []byte) map[string]json.RawMessage {
+	var diff map[string]json.RawMessage
+	set := func(id string, raw json.RawMessage) {
+		if diff == nil {
+			diff = make(map[string]json.RawMessage)
+		}
+		diff[id] = raw
+	}
+	present := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		present[id] = true
+		raw := marshal(id)
+		if old, ok := written[id]; !ok || !bytes.Equal(old, raw) {
+			set(id, raw)
+		}
+	}
+	for id := range written {
+		if !present[id] {
+			set(id, nullJSON)
+		}
+	}
+	return diff
+}
+
+func mustMarshal(what string, v interface{}) []byte {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		// this shouldn't happen, because the actual delicate serializing happens at various Set()s
+		logger.Panicf("internal error: could not marshal %s for checkpointing: %v", what, err)
+	}
+	return raw
+}
+
+// delta returns the entries of the state that changed since they were last
+// checkpointed, or nil if nothing changed.
+func (s *State) delta() *Delta {
+	w := s.written
+	delta := &Delta{
+		LastChangeId: s.lastChangeId,
+		LastTaskId:   s.lastTaskId,
+		LastLaneId:   s.lastLaneId,
+	}
+
+	ids := make([]string, 0, len(s.data))
+	for k := range s.data {
+		ids = append(ids, k)
+	}
+	delta.Data = diffEntries(w.data, ids, func(k string) []byte {
+		return []byte(*s.data[k])
+	})
+
+	ids = make([]string, 0, len(s.changes))
+	for id := range s.changes {
+		ids = append(ids, id)
+	}
+	delta.Changes = diffEntries(w.changes, ids, func(id string) []byte {
+		return mustMarshal("change "+id, s.changes[id])
+	})
+
+	ids = make([]string, 0, len(s.tasks))
+	for id := range s.tasks {
+		ids = append(ids, id)
+	}
+	delta.Tasks = diffEntries(w.tasks, ids, func(id string) []byte {
+		return mustMarshal("task "+id, s.tasks[id])
+	})
+
+	if warnings := mustMarshal("warnings", s.flattenWarnings()); !bytes.Equal(w.warnings, warnings) {
+		delta.Warnings = warnings
+	}
+
+	if delta.Data == nil && delta.Changes == nil && delta.Tasks == nil && delta.Warnings == nil &&
+		delta.LastChangeId == w.lastChangeId && delta.LastTaskId == w.lastTaskId && delta.LastLaneId == w.lastLaneId {
+		return nil
+	}
+	return delta
+}
@@ -0,0 +1,181 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type fakeDeltaBackend struct {
+	fakeStateBackend
+	deltas []*state.Delta
+	error  func() error
+}
+
+func (b *fakeDeltaBackend) CheckpointDelta(delta *state.Delta) error {
+	if b.error != nil {
+		if err := b.error(); err != nil {
+			return err
+		}
+	}
+	b.deltas = append(b.deltas, delta)
+	return nil
+}
+
+func keys(m map[string]json.RawMessage) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	return ks
+}
+
+func (ss *stateSuite) TestDeltaCheckpoint(c *C) {
+	b := new(fakeDeltaBackend)
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Set("b", "foo")
+	st.Unlock()
+
+	c.Assert(b.deltas, HasLen, 1)
+	c.Check(b.checkpoints, HasLen, 0)
+	c.Check(b.deltas[0].Data, DeepEquals, map[string]json.RawMessage{
+		"a": json.RawMessage(`1`),
+		"b": json.RawMessage(`"foo"`),
+	})
+
+	// only what changed is checkpointed
+	st.Lock()
+	st.Set("a", 2)
+	st.Set("b", "foo")
+	st.Unlock()
+	c.Assert(b.deltas, HasLen, 2)
+	c.Check(b.deltas[1].Data, DeepEquals, map[string]json.RawMessage{
+		"a": json.RawMessage(`2`),
+	})
+	c.Check(b.deltas[1].Changes, IsNil)
+	c.Check(b.deltas[1].Tasks, IsNil)
+	c.Check(b.deltas[1].Warnings, IsNil)
+
+	// removed entries are set to null
+	st.Lock()
+	st.Set("b", nil)
+	st.Unlock()
+	c.Assert(b.deltas, HasLen, 3)
+	c.Check(b.deltas[2].Data, DeepEquals, map[string]json.RawMessage{
+		"b": json.RawMessage(`null`),
+	})
+
+	// nothing is checkpointed if nothing actually changed
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+	c.Check(b.deltas, HasLen, 3)
+}
+
+func (ss *stateSuite) TestDeltaCheckpointChangesAndTasks(c *C) {
+	b := new(fakeDeltaBackend)
+	st := state.New(b)
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("download", "1...")
+	chg.AddTask(t1)
+	st.Unlock()
+
+	c.Assert(b.deltas, HasLen, 1)
+	c.Check(keys(b.deltas[0].Changes), DeepEquals, []string{chg.ID()})
+	c.Check(keys(b.deltas[0].Tasks), DeepEquals, []string{t1.ID()})
+	c.Check(b.deltas[0].LastChangeId, Equals, 1)
+	c.Check(b.deltas[0].LastTaskId, Equals, 1)
+
+	st.Lock()
+	t2 := st.NewTask("link", "2...")
+	st.Unlock()
+	c.Assert(b.deltas, HasLen, 2)
+	c.Check(b.deltas[1].Changes, IsNil)
+	c.Check(keys(b.deltas[1].Tasks), DeepEquals, []string{t2.ID()})
+	c.Check(b.deltas[1].LastTaskId, Equals, 2)
+
+	st.Lock()
+	t1.SetStatus(state.DoingStatus)
+	st.Unlock()
+	c.Assert(b.deltas, HasLen, 3)
+	c.Check(b.deltas[2].Changes, IsNil)
+	c.Check(keys(b.deltas[2].Tasks), DeepEquals, []string{t1.ID()})
+
+	st.Lock()
+	st.Warnf("hello")
+	st.Unlock()
+	c.Assert(b.deltas, HasLen, 4)
+	c.Check(b.deltas[3].Warnings, Matches, `(?s)\[\{"message":"hello".*`)
+}
+
+func (ss *stateSuite) TestDeltaCheckpointRetry(c *C) {
+	restore := state.MockCheckpointRetryDelay(2*time.Millisecond, 1*time.Second)
+	defer restore()
+
+	b := new(fakeDeltaBackend)
+	n := 0
+	b.error = func() error {
+		n++
+		if n < 3 {
+			return errors.New("boom")
+		}
+		return nil
+	}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+
+	c.Check(n, Equals, 3)
+	c.Assert(b.deltas, HasLen, 1)
+
+	// the retried delta was recorded as checkpointed
+	st.Lock()
+	st.Set("b", 1)
+	st.Unlock()
+	c.Assert(b.deltas, HasLen, 2)
+	c.Check(keys(b.deltas[1].Data), DeepEquals, []string{"b"})
+}
+
+func (ss *stateSuite) TestDeltaCheckpointAfterRead(c *C) {
+	b := new(fakeDeltaBackend)
+	buf := bytes.NewBufferString(`{"data":{"a":1,"b":2},"changes":{},"tasks":{}}`)
+	st, err := state.ReadState(b, buf)
+	c.Assert(err, IsNil)
+
+	// what was read is not checkpointed again
+	st.Lock()
+	st.Set("b", 3)
+	st.Unlock()
+	c.Assert(b.deltas, HasLen, 1)
+	c.Check(b.deltas[0].Data, DeepEquals, map[string]json.RawMessage{
+		"b": json.RawMessage(`3`),
+	})
+}
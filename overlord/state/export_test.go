@@ -73,3 +73,16 @@ var (
 	ErrNoWarningExpireAfter = errNoWarningExpireAfter
 	ErrNoWarningRepeatAfter = errNoWarningRepeatAfter
 )
+
+func MockJournalCompactMinSize(size int64) (restore func()) {
+	old := journalCompactMinSize
+	journalCompactMinSize = size
+	return func() {
+		journalCompactMinSize = old
+	}
+}
+
+// WaitCompacted waits for the compaction of the journal in progress, if any.
+func (j *Journal) WaitCompacted() {
+	j.wg.Wait()
+}
@@ -0,0 +1,591 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/randutil"
+)
+
+// The journal of a state file is kept next to it, as a series of records,
+// one per line, each prefixed with its CRC32. The first record identifies
+// the state file the journal applies to, by the journal ID recorded in the
+// state file, the next ones hold the deltas of the state with increasing
+// sequence numbers. The state file records the sequence number of the last
+// delta it includes.
+//
+// To be compacted, the journal is moved aside and a new one is started,
+// then the deltas of the moved journal are applied to the state file, and
+// the moved journal is removed.
+
+const (
+	journalSuffix           = ".journal"
+	compactingJournalSuffix = ".journal.compacting"
+)
+
+var (
+	// the journal is compacted once it is bigger than the state file,
+	// and than this
+	journalCompactMinSize int64 = 1024 * 1024
+	// how long to wait before trying to compact the journal again if
+	// compacting failed
+	journalCompactRetryInterval = time.Minute
+)
+
+type journalRecord struct {
+	JournalID string `json:"journal-id,omitempty"`
+	Seq       uint64 `json:"seq,omitempty"`
+	Delta     *Delta `json:"delta,omitempty"`
+}
+
+// journaledState is the content of a state file with the deltas of its
+// journal applied to it.
+type journaledState struct {
+	Data     map[string]json.RawMessage `json:"data"`
+	Changes  map[string]json.RawMessage `json:"changes"`
+	Tasks    map[string]json.RawMessage `json:"tasks"`
+	Warnings json.RawMessage            `json:"warnings,omitempty"`
+
+	LastChangeId int `json:"last-change-id"`
+	LastTaskId   int `json:"last-task-id"`
+	LastLaneId   int `json:"last-lane-id"`
+
+	JournalID  string `json:"journal-id,omitempty"`
+	JournalSeq uint64 `json:"journal-seq,omitempty"`
+}
+
+func applyRawEntries(entries *map[string]json.RawMessage, delta map[string]json.RawMessage) {
+	if *entries == nil {
+		*entries = make(map[string]json.RawMessage)
+	}
+	for k, v := range delta {
+		if isNullJSON(v) {
+			delete(*entries, k)
+		} else {
+			(*entries)[k] = v
+		}
+	}
+}
+
+func (js *journaledState) apply(rec *journalRecord) {
+	if delta := rec.Delta; delta != nil {
+		applyRawEntries(&js.Data, delta.Data)
+		applyRawEntries(&js.Changes, delta.Changes)
+		applyRawEntries(&js.Tasks, delta.Tasks)
+		if delta.Warnings != nil {
+			js.Warnings = delta.Warnings
+		}
+		js.LastChangeId = delta.LastChangeId
+		js.LastTaskId = delta.LastTaskId
+		js.LastLaneId = delta.LastLaneId
+	}
+	js.JournalSeq = rec.Seq
+}
+
+// applyJournal applies the deltas of the given journal that are not in the
+// state yet, and returns how many it applied. A journal of another state
+// file is ignored.
+func (js *journaledState) applyJournal(fn string) (applied int, err error) {
+	id, records, _, err := readJournal(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if js.JournalID == "" || id != js.JournalID {
+		if len(records) > 0 {
+			logger.Noticef("Ignoring state journal %q, it does not apply to the state.", fn)
+		}
+		return 0, nil
+	}
+	for _, rec := range records {
+		if rec.Seq <= js.JournalSeq {
+			continue
+		}
+		js.apply(rec)
+		applied++
+	}
+	return applied, nil
+}
+
+func readJournaledState(path string) (*journaledState, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var js journaledState
+	if err := json.NewDecoder(f).Decode(&js); err != nil {
+		return nil, fmt.Errorf("cannot read state: %v", err)
+	}
+	return &js, nil
+}
+
+func writeJournaledState(path string, js *journaledState) error {
+	data, err := json.Marshal(js)
+	if err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(path, data, 0600, 0)
+}
+
+func encodeJournalRecord(rec *journalRecord) ([]byte, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	line := make([]byte, 0, len(data)+10)
+	line = append(line, fmt.Sprintf("%08x ", crc32.ChecksumIEEE(data))...)
+	line = append(line, data...)
+	return append(line, '\n'), nil
+}
+
+func decodeJournalRecord(line []byte) (*journalRecord, error) {
+	if len(line) < 10 || line[8] != ' ' || line[len(line)-1] != '\n' {
+		return nil, fmt.Errorf("malformed record")
+	}
+	sum, err := strconv.ParseUint(string(line[:8]), 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("malformed record checksum")
+	}
+	data := line[9 : len(line)-1]
+	if crc32.ChecksumIEEE(data) != uint32(sum) {
+		return nil, fmt.Errorf("record checksum mismatch")
+	}
+	var rec journalRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// readJournal reads the journal in the given file, returning the ID of the
+// state file it applies to, its deltas, and the size of its valid part. A
+// truncated or corrupted record, as left by a crash while writing it, and
+// what follows it are ignored.
+func readJournal(fn string) (id string, records []*journalRecord, size int64, err error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return "", nil, 0, err
+		}
+		rec, err := decodeJournalRecord(line)
+		if err == nil && size == 0 && rec.JournalID == "" {
+			err = fmt.Errorf("missing journal ID")
+		}
+		if err == nil && size > 0 && (rec.Seq == 0 || rec.Delta == nil) {
+			err = fmt.Errorf("missing delta")
+		}
+		if err != nil {
+			logger.Noticef("Ignoring the rest of state journal %q from offset %d: %v.", fn, size, err)
+			break
+		}
+		if size == 0 {
+			id = rec.JournalID
+		} else {
+			records = append(records, rec)
+		}
+		size += int64(len(line))
+	}
+	return id, records, size, nil
+}
+
+func hasJournal(path string) bool {
+	return osutil.FileExists(path+journalSuffix) || osutil.FileExists(path+compactingJournalSuffix)
+}
+
+func removeJournal(path string) error {
+	for _, fn := range []string{path + compactingJournalSuffix, path + journalSuffix} {
+		if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadStateFile returns the state read from the state file at path, with
+// the deltas in its journal, if any, applied to it.
+//
+// If the backend does not journal the changes to the state, the journal is
+// folded into the state file and removed.
+func ReadStateFile(backend Backend, path string) (*State, error) {
+	if !hasJournal(path) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the state file: %v", err)
+		}
+		defer f.Close()
+		return ReadState(backend, f)
+	}
+
+	js, err := readJournaledState(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("cannot read the state file: %v", err)
+		}
+		return nil, err
+	}
+	applied := 0
+	for _, fn := range []string{path + compactingJournalSuffix, path + journalSuffix} {
+		n, err := js.applyJournal(fn)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read state journal: %v", err)
+		}
+		applied += n
+	}
+	data, err := json.Marshal(js)
+	if err != nil {
+		return nil, err
+	}
+	s, err := ReadState(backend, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := backend.(DeltaBackend); backend == nil || ok {
+		return s, nil
+	}
+	if applied > 0 {
+		s.Lock()
+		data := s.checkpointData()
+		s.unlock()
+		if err := backend.Checkpoint(data); err != nil {
+			return nil, fmt.Errorf("cannot fold the state journal into the state file: %v", err)
+		}
+	}
+	if err := removeJournal(path); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// A Journal persists the changes to the state as deltas appended to a
+// journal file next to the state file, instead of rewriting the whole state
+// file on every checkpoint. Once the journal grows bigger than the state
+// file it is compacted into it, in the background.
+//
+// The state needs to be read with ReadStateFile for the journal to be
+// taken into account.
+type Journal struct {
+	path string
+
+	mu       sync.Mutex
+	f        *os.File
+	id       string
+	seq      uint64
+	size     int64
+	records  int
+	baseSize int64
+
+	compacting     bool
+	compactPending bool
+	lastCompactErr time.Time
+	wg             sync.WaitGroup
+}
+
+// NewJournal returns the journal of the state file at path. The journal
+// and state file are only accessed on the first delta appended.
+func NewJournal(path string) *Journal {
+	return &Journal{path: path}
+}
+
+// open sets up the journal to append deltas to it, starting a new one if
+// the existing one does not apply to the state file.
+func (j *Journal) open() error {
+	js, err := readJournaledState(j.path)
+	if os.IsNotExist(err) {
+		js, err = &journaledState{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if js.JournalID == "" {
+		// the state file was not journaled to so far, any journal
+		// left around is stale
+		js.JournalID = randutil.RandomString(16)
+		js.JournalSeq = 0
+		if err := removeJournal(j.path); err != nil {
+			return err
+		}
+		if err := writeJournaledState(j.path, js); err != nil {
+			return err
+		}
+	}
+	j.id = js.JournalID
+	j.seq = js.JournalSeq
+	if fi, err := os.Stat(j.path); err == nil {
+		j.baseSize = fi.Size()
+	}
+
+	compactingFn := j.path + compactingJournalSuffix
+	id, records, _, err := readJournal(compactingFn)
+	switch {
+	case os.IsNotExist(err):
+		// nothing pending
+	case err != nil:
+		return err
+	case id != j.id:
+		if err := os.Remove(compactingFn); err != nil {
+			return err
+		}
+	default:
+		if n := len(records); n > 0 && records[n-1].Seq > j.seq {
+			j.seq = records[n-1].Seq
+		}
+		j.compactPending = true
+	}
+
+	fn := j.path + journalSuffix
+	id, records, size, err := readJournal(fn)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err != nil || id != j.id {
+		return j.create()
+	}
+	for _, rec := range records {
+		// the sequence numbers of the journal need to keep
+		// increasing
+		if rec.Seq <= j.seq {
+			return j.create()
+		}
+		j.seq = rec.Seq
+	}
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	// drop a torn record
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return err
+	}
+	j.f = f
+	j.size = size
+	j.records = len(records)
+	return nil
+}
+
+// create starts a new journal.
+func (j *Journal) create() error {
+	f, err := os.OpenFile(j.path+journalSuffix, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	j.f = f
+	j.size = 0
+	j.records = 0
+	if err := j.write(&journalRecord{JournalID: j.id}); err != nil {
+		j.f = nil
+		f.Close()
+		return err
+	}
+	return nil
+}
+
+// write appends the record to the journal, dropping what was written of
+// it on error.
+func (j *Journal) write(rec *journalRecord) error {
+	line, err := encodeJournalRecord(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := j.f.Write(line); err == nil {
+		err = j.f.Sync()
+	}
+	if err != nil {
+		if err := j.f.Truncate(j.size); err != nil {
+			// the journal is in an unknown state, set it up
+			// again on the next append
+			j.f.Close()
+			j.f = nil
+		}
+		return err
+	}
+	j.size += int64(len(line))
+	return nil
+}
+
+// Append appends the delta to the journal.
+func (j *Journal) Append(delta *Delta) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.f == nil {
+		if err := j.open(); err != nil {
+			return fmt.Errorf("cannot open state journal: %v", err)
+		}
+	}
+	if err := j.write(&journalRecord{Seq: j.seq + 1, Delta: delta}); err != nil {
+		return fmt.Errorf("cannot write to state journal: %v", err)
+	}
+	j.seq++
+	j.records++
+
+	j.maybeCompact()
+	return nil
+}
+
+// maybeCompact starts compacting the journal in the background if it is
+// big enough.
+func (j *Journal) maybeCompact() {
+	if j.compacting || time.Since(j.lastCompactErr) < journalCompactRetryInterval {
+		return
+	}
+	if !j.compactPending {
+		if j.size < journalCompactMinSize || j.size < j.baseSize {
+			return
+		}
+		if err := j.rotate(); err != nil {
+			logger.Noticef("Cannot compact state journal: %v", err)
+			j.lastCompactErr = time.Now()
+			return
+		}
+	}
+
+	j.compacting = true
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		baseSize, err := j.compact()
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.compacting = false
+		if err != nil {
+			logger.Noticef("Cannot compact state journal: %v", err)
+			j.compactPending = true
+			j.lastCompactErr = time.Now()
+			return
+		}
+		j.compactPending = false
+		j.baseSize = baseSize
+	}()
+}
+
+// rotate moves the journal aside to be compacted, and starts a new one.
+func (j *Journal) rotate() error {
+	err := j.f.Close()
+	j.f = nil
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(j.path+journalSuffix, j.path+compactingJournalSuffix); err != nil {
+		return err
+	}
+	j.compactPending = true
+	return j.create()
+}
+
+// compact applies the journal moved aside to the state file, and removes
+// it. It returns the new size of the state file.
+func (j *Journal) compact() (int64, error) {
+	js, err := readJournaledState(j.path)
+	if err != nil {
+		return 0, err
+	}
+	compactingFn := j.path + compactingJournalSuffix
+	if _, err := js.applyJournal(compactingFn); err != nil {
+		return 0, err
+	}
+	data, err := json.Marshal(js)
+	if err != nil {
+		return 0, err
+	}
+	if err := osutil.AtomicWriteFile(j.path, data, 0600, 0); err != nil {
+		return 0, err
+	}
+	if err := os.Remove(compactingFn); err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+// Checkpoint writes the whole state to the state file, dropping the
+// journal.
+func (j *Journal) Checkpoint(data []byte) error {
+	j.wg.Wait()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.f != nil {
+		j.f.Close()
+		j.f = nil
+	}
+	if err := osutil.AtomicWriteFile(j.path, data, 0600, 0); err != nil {
+		return err
+	}
+	j.compactPending = false
+	return removeJournal(j.path)
+}
+
+// Close waits for any compaction in progress, and compacts what is left in
+// the journal into the state file, so that the state file is up to date
+// for those reading it directly.
+func (j *Journal) Close() error {
+	j.wg.Wait()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.f == nil {
+		return nil
+	}
+	if j.compactPending {
+		if _, err := j.compact(); err != nil {
+			return fmt.Errorf("cannot compact state journal: %v", err)
+		}
+		j.compactPending = false
+	}
+	if j.records > 0 {
+		if err := j.rotate(); err != nil {
+			return fmt.Errorf("cannot compact state journal: %v", err)
+		}
+		if _, err := j.compact(); err != nil {
+			return fmt.Errorf("cannot compact state journal: %v", err)
+		}
+		j.compactPending = false
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
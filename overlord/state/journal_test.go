@@ -0,0 +1,281 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type journalSuite struct {
+	testutil.BaseTest
+	path string
+}
+
+var _ = Suite(&journalSuite{})
+
+func (s *journalSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.path = filepath.Join(c.MkDir(), "state.json")
+}
+
+type journalBackend struct {
+	journal *state.Journal
+}
+
+func (b *journalBackend) Checkpoint(data []byte) error {
+	return b.journal.Checkpoint(data)
+}
+
+func (b *journalBackend) CheckpointDelta(delta *state.Delta) error {
+	return b.journal.Append(delta)
+}
+
+func (b *journalBackend) EnsureBefore(d time.Duration) {}
+
+type plainBackend struct {
+	path string
+}
+
+func (b *plainBackend) Checkpoint(data []byte) error {
+	return osutil.AtomicWriteFile(b.path, data, 0600, 0)
+}
+
+func (b *plainBackend) EnsureBefore(d time.Duration) {}
+
+func (s *journalSuite) readState(c *C, b state.Backend) *state.State {
+	st, err := state.ReadStateFile(b, s.path)
+	c.Assert(err, IsNil)
+	return st
+}
+
+func (s *journalSuite) get(c *C, st *state.State, key string) int {
+	st.Lock()
+	defer st.Unlock()
+	var v int
+	err := st.Get(key, &v)
+	if errors.Is(err, state.ErrNoState) {
+		return -1
+	}
+	c.Assert(err, IsNil)
+	return v
+}
+
+func (s *journalSuite) set(st *state.State, key string, v interface{}) {
+	st.Lock()
+	defer st.Unlock()
+	st.Set(key, v)
+}
+
+func (s *journalSuite) TestJournalRoundtrip(c *C) {
+	b := &journalBackend{journal: state.NewJournal(s.path)}
+	st := state.New(b)
+	s.set(st, "a", 1)
+	s.set(st, "b", 2)
+	s.set(st, "b", nil)
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	chg.AddTask(st.NewTask("download", "..."))
+	st.Unlock()
+
+	c.Check(s.path+".journal", testutil.FilePresent)
+
+	// the state file does not have the changes yet
+	st1, err := state.ReadState(nil, mustOpen(c, s.path))
+	c.Assert(err, IsNil)
+	c.Check(s.get(c, st1, "a"), Equals, -1)
+
+	st2 := s.readState(c, &journalBackend{journal: state.NewJournal(s.path)})
+	c.Check(s.get(c, st2, "a"), Equals, 1)
+	c.Check(s.get(c, st2, "b"), Equals, -1)
+	st2.Lock()
+	c.Check(st2.Changes(), HasLen, 1)
+	c.Check(st2.Tasks(), HasLen, 1)
+	st2.Unlock()
+
+	// journaling goes on after reading
+	s.set(st2, "c", 3)
+	st3 := s.readState(c, nil)
+	c.Check(s.get(c, st3, "a"), Equals, 1)
+	c.Check(s.get(c, st3, "c"), Equals, 3)
+}
+
+func mustOpen(c *C, fn string) *os.File {
+	f, err := os.Open(fn)
+	c.Assert(err, IsNil)
+	return f
+}
+
+func (s *journalSuite) TestJournalFromPlainState(c *C) {
+	st := state.New(&plainBackend{path: s.path})
+	s.set(st, "a", 1)
+	// a stale journal
+	c.Assert(ioutil.WriteFile(s.path+".journal", []byte("garbage\n"), 0600), IsNil)
+
+	b := &journalBackend{journal: state.NewJournal(s.path)}
+	st = s.readState(c, b)
+	c.Check(s.get(c, st, "a"), Equals, 1)
+	s.set(st, "b", 2)
+
+	var content map[string]interface{}
+	data, err := ioutil.ReadFile(s.path)
+	c.Assert(err, IsNil)
+	c.Assert(json.Unmarshal(data, &content), IsNil)
+	c.Check(content["journal-id"], Not(Equals), "")
+
+	st = s.readState(c, nil)
+	c.Check(s.get(c, st, "a"), Equals, 1)
+	c.Check(s.get(c, st, "b"), Equals, 2)
+}
+
+func (s *journalSuite) TestJournalToPlainState(c *C) {
+	b := &journalBackend{journal: state.NewJournal(s.path)}
+	st := state.New(b)
+	s.set(st, "a", 1)
+	s.set(st, "b", 2)
+
+	// reading with a plain backend folds the journal into the state
+	// file
+	st = s.readState(c, &plainBackend{path: s.path})
+	c.Check(s.get(c, st, "a"), Equals, 1)
+	c.Check(s.get(c, st, "b"), Equals, 2)
+	c.Check(s.path+".journal", testutil.FileAbsent)
+
+	st1, err := state.ReadState(nil, mustOpen(c, s.path))
+	c.Assert(err, IsNil)
+	c.Check(s.get(c, st1, "b"), Equals, 2)
+
+	// and journaling again after that ignores the old journal
+	s.set(st, "a", 3)
+	b = &journalBackend{journal: state.NewJournal(s.path)}
+	st = s.readState(c, b)
+	s.set(st, "c", 4)
+	st = s.readState(c, nil)
+	c.Check(s.get(c, st, "a"), Equals, 3)
+	c.Check(s.get(c, st, "c"), Equals, 4)
+}
+
+func (s *journalSuite) TestJournalStaleIgnored(c *C) {
+	b := &journalBackend{journal: state.NewJournal(s.path)}
+	st := state.New(b)
+	s.set(st, "a", 1)
+	journal, err := ioutil.ReadFile(s.path + ".journal")
+	c.Assert(err, IsNil)
+
+	// the state file is rewritten by something not aware of the
+	// journal, which is left around
+	st = s.readState(c, &plainBackend{path: s.path})
+	s.set(st, "a", 2)
+	c.Assert(ioutil.WriteFile(s.path+".journal", journal, 0600), IsNil)
+
+	st = s.readState(c, nil)
+	c.Check(s.get(c, st, "a"), Equals, 2)
+}
+
+func (s *journalSuite) TestJournalTornRecord(c *C) {
+	b := &journalBackend{journal: state.NewJournal(s.path)}
+	st := state.New(b)
+	s.set(st, "a", 1)
+	s.set(st, "b", 2)
+
+	// a crash while writing the last record
+	fn := s.path + ".journal"
+	fi, err := os.Stat(fn)
+	c.Assert(err, IsNil)
+	c.Assert(os.Truncate(fn, fi.Size()-3), IsNil)
+
+	b = &journalBackend{journal: state.NewJournal(s.path)}
+	st = s.readState(c, b)
+	c.Check(s.get(c, st, "a"), Equals, 1)
+	c.Check(s.get(c, st, "b"), Equals, -1)
+
+	// the torn record is dropped before appending
+	s.set(st, "c", 3)
+	st = s.readState(c, nil)
+	c.Check(s.get(c, st, "a"), Equals, 1)
+	c.Check(s.get(c, st, "b"), Equals, -1)
+	c.Check(s.get(c, st, "c"), Equals, 3)
+}
+
+func (s *journalSuite) TestJournalCompaction(c *C) {
+	s.AddCleanup(state.MockJournalCompactMinSize(1))
+
+	j := state.NewJournal(s.path)
+	st := state.New(&journalBackend{journal: j})
+	for i := 0; i < 10; i++ {
+		s.set(st, "a", i)
+		j.WaitCompacted()
+	}
+	c.Check(s.path+".journal.compacting", testutil.FileAbsent)
+
+	// the state file was brought up to date
+	st1, err := state.ReadState(nil, mustOpen(c, s.path))
+	c.Assert(err, IsNil)
+	c.Check(s.get(c, st1, "a"), Not(Equals), -1)
+
+	st = s.readState(c, nil)
+	c.Check(s.get(c, st, "a"), Equals, 9)
+}
+
+func (s *journalSuite) TestJournalCompactionInterrupted(c *C) {
+	j := state.NewJournal(s.path)
+	st := state.New(&journalBackend{journal: j})
+	s.set(st, "a", 1)
+	// as if the journal was moved aside to be compacted
+	c.Assert(os.Rename(s.path+".journal", s.path+".journal.compacting"), IsNil)
+
+	j = state.NewJournal(s.path)
+	st = s.readState(c, &journalBackend{journal: j})
+	c.Check(s.get(c, st, "a"), Equals, 1)
+	s.set(st, "b", 2)
+	j.WaitCompacted()
+	c.Check(s.path+".journal.compacting", testutil.FileAbsent)
+
+	st = s.readState(c, nil)
+	c.Check(s.get(c, st, "a"), Equals, 1)
+	c.Check(s.get(c, st, "b"), Equals, 2)
+}
+
+func (s *journalSuite) TestJournalCloseCompacts(c *C) {
+	j := state.NewJournal(s.path)
+	st := state.New(&journalBackend{journal: j})
+	s.set(st, "a", 1)
+	c.Assert(j.Close(), IsNil)
+
+	st1, err := state.ReadState(nil, mustOpen(c, s.path))
+	c.Assert(err, IsNil)
+	c.Check(s.get(c, st1, "a"), Equals, 1)
+	c.Check(s.path+".journal.compacting", testutil.FileAbsent)
+}
+
+func (s *journalSuite) TestReadStateFileMissing(c *C) {
+	_, err := state.ReadStateFile(nil, s.path)
+	c.Check(err, ErrorMatches, `cannot read the state file: open .*/state.json: no such file or directory`)
+}
@@ -41,6 +41,17 @@ type Backend interface {
 	EnsureBefore(d time.Duration)
 }
 
+// A DeltaBackend is a Backend that checkpoints only the entries of the
+// state that changed since the previous checkpoint. State uses
+// CheckpointDelta instead of Checkpoint with such a backend.
+type DeltaBackend interface {
+	Backend
+	// CheckpointDelta persists the given delta. If it returns an
+	// error nothing must have been persisted, as the same delta is
+	// given again on retry.
+	CheckpointDelta(delta *Delta) error
+}
+
 type customData map[string]*json.RawMessage
 
 func (data customData) get(key string, value interface{}) error {
@@ -95,8 +106,13 @@ type State struct {
 	warnings map[string]*Warning
 
 	modified bool
+	// written holds the serialized entries of the state as last
+	// checkpointed, when the backend is a DeltaBackend
+	written *writtenState
 
 	cache map[interface{}]interface{}
+
+	pruneHandler func(chg *Change)
 }
 
 // New returns a new empty state.
@@ -218,11 +234,35 @@ func (s *State) Unlock() {
 		return
 	}
 
-	data := s.checkpointData()
+	var checkpoint func() error
+	if db, ok := s.backend.(DeltaBackend); ok {
+		if s.written == nil {
+			s.written = newWrittenState()
+		}
+		delta := s.delta()
+		if delta == nil {
+			// nothing actually changed
+			s.modified = false
+			return
+		}
+		checkpoint = func() error {
+			if err := db.CheckpointDelta(delta); err != nil {
+				return err
+			}
+			s.written.apply(delta)
+			return nil
+		}
+	} else {
+		data := s.checkpointData()
+		checkpoint = func() error {
+			return s.backend.Checkpoint(data)
+		}
+	}
+
 	var err error
 	start := time.Now()
 	for time.Since(start) <= unlockCheckpointRetryMaxTime {
-		if err = s.backend.Checkpoint(data); err == nil {
+		if err = checkpoint(); err == nil {
 			s.modified = false
 			return
 		}
@@ -433,6 +473,9 @@ func (s *State) Prune(startOfOperation time.Time, pruneWait, abortWait time.Dura
 		}
 		// change old or we have too many changes
 		if readyTime.Before(pruneLimit) || readyChangesCount > maxReadyChanges {
+			if s.pruneHandler != nil {
+				s.pruneHandler(chg)
+			}
 			s.writing()
 			for _, t := range chg.Tasks() {
 				delete(s.tasks, t.ID())
@@ -451,6 +494,12 @@ func (s *State) Prune(startOfOperation time.Time, pruneWait, abortWait time.Dura
 	}
 }
 
+// OnPrune sets f to be called by Prune with each ready change it is about
+// to remove, with the state locked.
+func (s *State) OnPrune(f func(chg *Change)) {
+	s.pruneHandler = f
+}
+
 // GetMaybeTimings implements timings.GetSaver
 func (s *State) GetMaybeTimings(timings interface{}) error {
 	if err := s.Get("timings", timings); err != nil && !errors.Is(err, ErrNoState) {
@@ -477,5 +526,10 @@ func ReadState(backend Backend, r io.Reader) (*State, error) {
 	s.backend = backend
 	s.modified = false
 	s.cache = make(map[interface{}]interface{})
+	if _, ok := backend.(DeltaBackend); ok {
+		// what was read is what is checkpointed already
+		s.written = newWrittenState()
+		s.written.apply(s.delta())
+	}
 	return s, err
 }
@@ -812,6 +812,39 @@ func (ss *stateSuite) TestPrune(c *C) {
 	c.Check(st.AllWarnings(), HasLen, 1)
 }
 
+func (ss *stateSuite) TestPruneHandler(c *C) {
+	st := state.New(&fakeStateBackend{})
+	st.Lock()
+	defer st.Unlock()
+
+	now := time.Now()
+	pruneWait := 1 * time.Hour
+	abortWait := 3 * time.Hour
+
+	t1 := st.NewTask("foo", "...")
+	chg1 := st.NewChange("prune", "...")
+	chg1.AddTask(t1)
+	state.MockChangeTimes(chg1, now.Add(-pruneWait), now.Add(-pruneWait))
+
+	chg2 := st.NewChange("ready-but-recent", "...")
+	chg2.AddTask(st.NewTask("foo", "..."))
+	state.MockChangeTimes(chg2, now.Add(-pruneWait), now.Add(-pruneWait/2))
+
+	var pruned []*state.Change
+	st.OnPrune(func(chg *state.Change) {
+		// the change is still complete
+		c.Check(chg.Tasks(), DeepEquals, []*state.Task{t1})
+		c.Check(st.Task(t1.ID()), Equals, t1)
+		pruned = append(pruned, chg)
+	})
+
+	past := time.Now().AddDate(-1, 0, 0)
+	st.Prune(past, pruneWait, abortWait, 100)
+
+	c.Check(pruned, DeepEquals, []*state.Change{chg1})
+	c.Check(st.Change(chg1.ID()), IsNil)
+}
+
 func (ss *stateSuite) TestPruneEmptyChange(c *C) {
 	// Empty changes are a bit special because they start out on Hold
 	// which is a Ready status, but the change itself is not considered Ready
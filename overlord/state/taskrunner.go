@@ -20,6 +20,7 @@
 package state
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -57,7 +58,9 @@ type TaskRunner struct {
 	handlers map[string]handlerPair
 	optional []optionalHandler
 	cleanups map[string]HandlerFunc
-	stopped  bool
+	// kinds of tasks that can be retried after failing
+	idempotent map[string]bool
+	stopped    bool
 
 	blocked     []blockedFunc
 	someBlocked bool
@@ -81,10 +84,11 @@ type optionalHandler struct {
 // NewTaskRunner creates a new TaskRunner
 func NewTaskRunner(s *State) *TaskRunner {
 	return &TaskRunner{
-		state:    s,
-		handlers: make(map[string]handlerPair),
-		cleanups: make(map[string]HandlerFunc),
-		tombs:    make(map[string]*tomb.Tomb),
+		state:      s,
+		handlers:   make(map[string]handlerPair),
+		cleanups:   make(map[string]HandlerFunc),
+		idempotent: make(map[string]bool),
+		tombs:      make(map[string]*tomb.Tomb),
 	}
 }
 
@@ -150,6 +154,74 @@ func (r *TaskRunner) AddCleanup(kind string, cleanup HandlerFunc) {
 	r.cleanups[kind] = cleanup
 }
 
+// MarkIdempotent declares that the do handler of tasks of the specified kind
+// can be run again on a task that failed part way through it or was undone,
+// which allows for such tasks to be retried with Retry. When such a task
+// fails, the work already done in its lanes is kept rather than undone if it
+// can be kept, see the error handling in run.
+//
+// The handler for tasks of the provided kind must have been previously
+// registered before MarkIdempotent is called for it.
+func (r *TaskRunner) MarkIdempotent(kind string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.handlers[kind]; !ok {
+		return fmt.Errorf("cannot mark unknown task kind %q as idempotent", kind)
+	}
+	r.idempotent[kind] = true
+	return nil
+}
+
+// Retry sets the tasks of the given failed change that did not complete
+// back to DoStatus, for the change to run again from where it failed. The
+// tasks that are done are left alone.
+//
+// The tasks in ErrorStatus may have done part of their work, and the ones
+// in UndoneStatus would run again after what they did was undone, so Retry
+// fails unless all of them are of kinds marked with MarkIdempotent. The tasks
+// in HoldStatus never ran and are simply run for the first time.
+//
+// The state must be locked by the caller, who should then ensure the state
+// soon for the tasks to run.
+func (r *TaskRunner) Retry(chg *Change) error {
+	if chg.Status() != ErrorStatus {
+		return fmt.Errorf("cannot retry change %s with status %s, only failed changes can be retried", chg.ID(), chg.Status())
+	}
+
+	// handlers are all registered before the runner is used, so these
+	// can be read without holding r.mu, which must not be taken with
+	// the state locked
+	tasks := chg.Tasks()
+	var reset []*Task
+	for _, t := range tasks {
+		switch t.Status() {
+		case ErrorStatus, UndoneStatus:
+			if !r.idempotent[t.Kind()] {
+				return fmt.Errorf("cannot retry change %s: task %q cannot be run again", chg.ID(), t.Summary())
+			}
+		case HoldStatus:
+		default:
+			continue
+		}
+		reset = append(reset, t)
+	}
+	for _, t := range tasks {
+		if _, ok := r.cleanups[t.Kind()]; ok && !t.IsClean() {
+			// the cleanup expects the change to stay ready
+			return fmt.Errorf("cannot retry change %s yet: its tasks are being cleaned up", chg.ID())
+		}
+	}
+
+	r.state.writing()
+	chg.resetReady()
+	for _, t := range reset {
+		t.SetStatus(DoStatus)
+		t.readyTime = time.Time{}
+		t.clean = false
+	}
+	return nil
+}
+
 // SetBlocked sets a predicate function to decide whether to block a task from running based on the current running tasks. It can be used to control task serialisation.
 func (r *TaskRunner) SetBlocked(pred func(t *Task, running []*Task) bool) {
 	r.mu.Lock()
@@ -264,7 +336,13 @@ func (r *TaskRunner) run(t *Task) {
 				r.state.EnsureBefore(0)
 			}
 		default:
-			r.abortLanes(t.Change(), t.Lanes())
+			if r.canKeepDone(t) {
+				// the change can be retried from this task, so
+				// keep what was done in the meantime
+				t.Change().holdLanes(t.Lanes())
+			} else {
+				r.abortLanes(t.Change(), t.Lanes())
+			}
 			t.SetStatus(ErrorStatus)
 			t.Errorf("%s", err)
 			// ensure the error is available in the global log too
@@ -329,6 +407,41 @@ func (r *TaskRunner) abortLanes(chg *Change, lanes []int) {
 	}
 }
 
+// canKeepDone returns whether the work done in the lanes of the failed task t
+// can be kept instead of being undone, for the change to be retried from t.
+// This is the case if t can be run again and the tasks of its lanes that did
+// or are doing something that would otherwise be undone can be run again too,
+// so that keeping their work around is harmless if the change is not retried.
+func (r *TaskRunner) canKeepDone(t *Task) bool {
+	if !r.idempotent[t.Kind()] {
+		return false
+	}
+	if t.Status() != DoingStatus {
+		// the change is being aborted already
+		return false
+	}
+	lanes := make(map[int]bool)
+	for _, lane := range t.Lanes() {
+		lanes[lane] = true
+	}
+	for _, other := range t.Change().Tasks() {
+		switch other.Status() {
+		case DoingStatus, DoneStatus:
+		default:
+			continue
+		}
+		if r.idempotent[other.Kind()] || r.handlerPair(other).undo == nil {
+			continue
+		}
+		for _, lane := range other.Lanes() {
+			if lanes[lane] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // tryUndo replaces the status of a knowingly aborted task.
 func (r *TaskRunner) tryUndo(t *Task) {
 	if t.Status() == AbortStatus && r.handlerPair(t).undo == nil {
@@ -1279,3 +1279,308 @@ func (ts *taskRunnerSuite) TestErrorCallbackNotCalled(c *C) {
 	c.Check(t1.Status(), Equals, state.DoneStatus)
 	c.Check(called, Equals, false)
 }
+
+func (ts *taskRunnerSuite) TestRetry(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	var calls []string
+	record := func(label string, err error) state.HandlerFunc {
+		return func(t *state.Task, tb *tomb.Tomb) error {
+			st.Lock()
+			defer st.Unlock()
+			calls = append(calls, label)
+			return err
+		}
+	}
+	failFetch := true
+	r.AddHandler("prep", record("prep:do", nil), nil)
+	r.AddHandler("download", record("download:do", nil), record("download:undo", nil))
+	r.AddHandler("fetch", func(t *state.Task, tb *tomb.Tomb) error {
+		st.Lock()
+		defer st.Unlock()
+		calls = append(calls, "fetch:do")
+		if failFetch {
+			return fmt.Errorf("boom")
+		}
+		return nil
+	}, nil)
+	r.AddHandler("link", record("link:do", nil), record("link:undo", nil))
+	for _, kind := range []string{"download", "fetch", "link"} {
+		c.Assert(r.MarkIdempotent(kind), IsNil)
+	}
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	prep := st.NewTask("prep", "prep")
+	download := st.NewTask("download", "download")
+	download.WaitFor(prep)
+	fetch := st.NewTask("fetch", "fetch")
+	fetch.WaitFor(download)
+	link := st.NewTask("link", "link")
+	link.WaitFor(fetch)
+	chg.AddAll(state.NewTaskSet(prep, download, fetch, link))
+	st.Unlock()
+
+	ensureChange(c, r, sb, chg)
+	// let the cleanups run
+	r.Ensure()
+	r.Wait()
+
+	st.Lock()
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+	// the work that was done was kept for the retry
+	c.Check(prep.Status(), Equals, state.DoneStatus)
+	c.Check(download.Status(), Equals, state.DoneStatus)
+	c.Check(fetch.Status(), Equals, state.ErrorStatus)
+	c.Check(link.Status(), Equals, state.HoldStatus)
+	c.Check(calls, DeepEquals, []string{"prep:do", "download:do", "fetch:do"})
+	calls = nil
+
+	failFetch = false
+	c.Assert(r.Retry(chg), IsNil)
+	c.Check(chg.IsReady(), Equals, false)
+	c.Check(chg.ReadyTime().IsZero(), Equals, true)
+	c.Check(chg.Status(), Equals, state.DoStatus)
+	c.Check(prep.Status(), Equals, state.DoneStatus)
+	st.Unlock()
+
+	ensureChange(c, r, sb, chg)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(chg.IsReady(), Equals, true)
+	c.Check(chg.Err(), IsNil)
+	// the work that was done was not done again
+	c.Check(calls, DeepEquals, []string{"fetch:do", "link:do"})
+}
+
+func (ts *taskRunnerSuite) TestFailedIdempotentTaskUndoesWorkThatCannotBeKept(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	var calls []string
+	record := func(label string, err error) state.HandlerFunc {
+		return func(t *state.Task, tb *tomb.Tomb) error {
+			st.Lock()
+			defer st.Unlock()
+			calls = append(calls, label)
+			return err
+		}
+	}
+	r.AddHandler("setup", record("setup:do", nil), record("setup:undo", nil))
+	r.AddHandler("fetch", record("fetch:do", fmt.Errorf("boom")), nil)
+	r.AddHandler("other", record("other:do", nil), nil)
+	c.Assert(r.MarkIdempotent("fetch"), IsNil)
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	setup := st.NewTask("setup", "setup")
+	fetch := st.NewTask("fetch", "fetch")
+	fetch.WaitFor(setup)
+	other := st.NewTask("other", "other")
+	other.WaitFor(setup)
+	other.JoinLane(1)
+	setup.JoinLane(1)
+	fetch.JoinLane(1)
+	chg.AddAll(state.NewTaskSet(setup, fetch, other))
+	// a pending task of another lane waiting on the failed one
+	waiting := st.NewTask("other", "other lane")
+	waiting.WaitFor(fetch)
+	waiting.JoinLane(2)
+	chg.AddTask(waiting)
+	st.Unlock()
+
+	ensureChange(c, r, sb, chg)
+
+	st.Lock()
+	defer st.Unlock()
+	// the setup cannot be run again, so it is undone as usual
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(setup.Status(), Equals, state.UndoneStatus)
+	c.Check(fetch.Status(), Equals, state.ErrorStatus)
+	c.Check(waiting.Status(), Equals, state.HoldStatus)
+	c.Check(calls[len(calls)-1], Equals, "setup:undo")
+}
+
+func (ts *taskRunnerSuite) TestFailedIdempotentTaskHoldsItsLanes(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	r.AddHandler("fetch", func(t *state.Task, tb *tomb.Tomb) error {
+		st.Lock()
+		defer st.Unlock()
+		if t.Summary() == "fail" {
+			return fmt.Errorf("boom")
+		}
+		return nil
+	}, func(t *state.Task, tb *tomb.Tomb) error {
+		return fmt.Errorf("unexpected undo")
+	})
+	r.AddHandler("link", func(t *state.Task, tb *tomb.Tomb) error { return nil }, nil)
+	c.Assert(r.MarkIdempotent("fetch"), IsNil)
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	fetch1 := st.NewTask("fetch", "ok")
+	fetch2 := st.NewTask("fetch", "fail")
+	fetch2.WaitFor(fetch1)
+	// in the same lane, but not waiting on the failed task
+	link1 := st.NewTask("link", "link 1")
+	link1.WaitFor(fetch1)
+	link1.At(time.Now().Add(time.Hour))
+	link2 := st.NewTask("link", "link 2")
+	link2.WaitFor(fetch2)
+	for _, t := range []*state.Task{fetch1, fetch2, link1, link2} {
+		t.JoinLane(1)
+	}
+	chg.AddAll(state.NewTaskSet(fetch1, fetch2, link1, link2))
+	st.Unlock()
+
+	ensureChange(c, r, sb, chg)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(fetch1.Status(), Equals, state.DoneStatus)
+	c.Check(fetch2.Status(), Equals, state.ErrorStatus)
+	c.Check(link1.Status(), Equals, state.HoldStatus)
+	c.Check(link2.Status(), Equals, state.HoldStatus)
+}
+
+func (ts *taskRunnerSuite) TestRetryNotIdempotent(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	r.AddHandler("fail", func(t *state.Task, tb *tomb.Tomb) error {
+		return fmt.Errorf("boom")
+	}, nil)
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	chg.AddTask(st.NewTask("fail", "fail for real"))
+	st.Unlock()
+
+	ensureChange(c, r, sb, chg)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+	err := r.Retry(chg)
+	c.Check(err, ErrorMatches, `cannot retry change 1: task "fail for real" cannot be run again`)
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.IsReady(), Equals, true)
+}
+
+func (ts *taskRunnerSuite) TestRetryNotFailed(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	r.AddHandler("ok", func(t *state.Task, tb *tomb.Tomb) error { return nil }, nil)
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	chg.AddTask(st.NewTask("ok", "..."))
+	err := r.Retry(chg)
+	c.Check(err, ErrorMatches, `cannot retry change 1 with status Do, only failed changes can be retried`)
+	st.Unlock()
+
+	ensureChange(c, r, sb, chg)
+
+	st.Lock()
+	defer st.Unlock()
+	err = r.Retry(chg)
+	c.Check(err, ErrorMatches, `cannot retry change 1 with status Done, only failed changes can be retried`)
+}
+
+func (ts *taskRunnerSuite) TestRetryWhileCleaningUp(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	r.AddHandler("fail", func(t *state.Task, tb *tomb.Tomb) error {
+		return fmt.Errorf("boom")
+	}, nil)
+	r.AddCleanup("fail", func(t *state.Task, tb *tomb.Tomb) error {
+		return fmt.Errorf("not yet")
+	})
+	c.Assert(r.MarkIdempotent("fail"), IsNil)
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	chg.AddTask(st.NewTask("fail", "..."))
+	st.Unlock()
+
+	ensureChange(c, r, sb, chg)
+
+	st.Lock()
+	defer st.Unlock()
+	err := r.Retry(chg)
+	c.Check(err, ErrorMatches, `cannot retry change 1 yet: its tasks are being cleaned up`)
+}
+
+func (ts *taskRunnerSuite) TestMarkIdempotentUnknownKind(c *C) {
+	r := state.NewTaskRunner(state.New(nil))
+	c.Check(r.MarkIdempotent("foo"), ErrorMatches, `cannot mark unknown task kind "foo" as idempotent`)
+}
+
+func (ts *taskRunnerSuite) TestRetryUndoneNotIdempotent(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	r.AddHandler("setup", func(t *state.Task, tb *tomb.Tomb) error { return nil }, func(t *state.Task, tb *tomb.Tomb) error { return nil })
+	r.AddHandler("fail", func(t *state.Task, tb *tomb.Tomb) error {
+		return fmt.Errorf("boom")
+	}, nil)
+	r.AddHandler("link", func(t *state.Task, tb *tomb.Tomb) error { return nil }, nil)
+	c.Assert(r.MarkIdempotent("fail"), IsNil)
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	setup := st.NewTask("setup", "set up for real")
+	fail := st.NewTask("fail", "fail")
+	fail.WaitFor(setup)
+	link := st.NewTask("link", "link for real")
+	link.WaitFor(fail)
+	chg.AddAll(state.NewTaskSet(setup, fail, link))
+	st.Unlock()
+
+	ensureChange(c, r, sb, chg)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+	c.Assert(setup.Status(), Equals, state.UndoneStatus)
+	c.Assert(link.Status(), Equals, state.HoldStatus)
+
+	// the undone task is not idempotent
+	err := r.Retry(chg)
+	c.Check(err, ErrorMatches, `cannot retry change 1: task "set up for real" cannot be run again`)
+
+	// nothing was reset
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.IsReady(), Equals, true)
+	c.Check(setup.Status(), Equals, state.UndoneStatus)
+	c.Check(fail.Status(), Equals, state.ErrorStatus)
+
+	// the held task never ran, so it does not need to be idempotent
+	c.Assert(r.MarkIdempotent("setup"), IsNil)
+	c.Assert(r.Retry(chg), IsNil)
+	c.Check(setup.Status(), Equals, state.DoStatus)
+	c.Check(fail.Status(), Equals, state.DoStatus)
+	c.Check(link.Status(), Equals, state.DoStatus)
+}
@@ -0,0 +1,103 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build linux
+// +build linux
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cgroup
+
+import (
+	"os"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// bpfObjGetAttr is the BPF_OBJ_GET flavor of union bpf_attr
+type bpfObjGetAttr struct {
+	pathname  uint64
+	bpfFd     uint32
+	fileFlags uint32
+}
+
+// bpfMapElemAttr is the BPF_MAP_*_ELEM flavor of union bpf_attr
+type bpfMapElemAttr struct {
+	mapFd   uint32
+	_       uint32
+	key     uint64
+	nextKey uint64
+	flags   uint64
+}
+
+func bpf(cmd int, attr unsafe.Pointer, size uintptr) (uintptr, error) {
+	r, _, errno := unix.Syscall(unix.SYS_BPF, uintptr(cmd), uintptr(attr), size)
+	if errno != 0 {
+		return 0, errno
+	}
+	return r, nil
+}
+
+// bpfMapKeysImpl opens the BPF map pinned at the given path read-only and
+// iterates over its keys.
+func bpfMapKeysImpl(path string) ([][]byte, error) {
+	p, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	objGet := bpfObjGetAttr{
+		pathname:  uint64(uintptr(unsafe.Pointer(p))),
+		fileFlags: unix.BPF_F_RDONLY,
+	}
+	fd, err := bpf(unix.BPF_OBJ_GET, unsafe.Pointer(&objGet), unsafe.Sizeof(objGet))
+	// the attribute only holds the address of the path, keep the path
+	// alive until the kernel is done with it
+	runtime.KeepAlive(p)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	defer unix.Close(int(fd))
+
+	var keys [][]byte
+	var key []byte
+	for {
+		nextKey := make([]byte, bpfDeviceKeySize)
+		getNext := bpfMapElemAttr{
+			mapFd:   uint32(fd),
+			nextKey: uint64(uintptr(unsafe.Pointer(&nextKey[0]))),
+		}
+		// a nil key gets the first key
+		if key != nil {
+			getNext.key = uint64(uintptr(unsafe.Pointer(&key[0])))
+		}
+		_, err := bpf(unix.BPF_MAP_GET_NEXT_KEY, unsafe.Pointer(&getNext), unsafe.Sizeof(getNext))
+		// same for the key buffers
+		runtime.KeepAlive(key)
+		runtime.KeepAlive(nextKey)
+		if err != nil {
+			if err == unix.ENOENT {
+				// no more keys
+				break
+			}
+			return nil, err
+		}
+		keys = append(keys, nextKey)
+		key = nextKey
+	}
+	return keys, nil
+}
@@ -0,0 +1,201 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cgroup
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// AnyDevice is the major or minor number of device rules matching any
+// number.
+const AnyDevice = math.MaxUint32
+
+// Device is a device, or a range of devices when the major or minor number
+// is AnyDevice, that a device cgroup allows access to.
+type Device struct {
+	// Type is 'c' for character devices, 'b' for block devices or 'a'
+	// for all devices.
+	Type  byte
+	Major uint32
+	Minor uint32
+}
+
+func (d Device) String() string {
+	number := func(n uint32) string {
+		if n == AnyDevice {
+			return "*"
+		}
+		return strconv.FormatUint(uint64(n), 10)
+	}
+	return fmt.Sprintf("%c %s:%s", d.Type, number(d.Major), number(d.Minor))
+}
+
+// Allows returns whether the rule allows access to the given device.
+func (d Device) Allows(other Device) bool {
+	return (d.Type == 'a' || d.Type == other.Type) &&
+		(d.Major == AnyDevice || d.Major == other.Major) &&
+		(d.Minor == AnyDevice || d.Minor == other.Minor)
+}
+
+// ErrDeviceCgroupNotFound is returned when there is no device cgroup for a
+// security tag.
+var ErrDeviceCgroupNotFound = errors.New("device cgroup not found")
+
+const (
+	defaultDevicesCgroupV1Dir = "/sys/fs/cgroup/devices"
+	defaultSnapBPFDir         = "/sys/fs/bpf/snap"
+)
+
+// bpfDeviceKeySize is the size of the keys of the map of allowed devices
+// of snap-confine, see struct sc_cgroup_v2_device_key
+const bpfDeviceKeySize = 9
+
+// bpfMapKeys returns the keys of the BPF map pinned at the given path.
+var bpfMapKeys = bpfMapKeysImpl
+
+// DeviceCgroupDevices returns the devices that the device cgroup that
+// snap-confine set up for the given security tag allows access to.
+//
+// With the unified hierarchy those are read back from the map of the eBPF
+// program attached to the cgroup, with v1 from the devices.list of the
+// device cgroup. ErrDeviceCgroupNotFound is returned when there is no
+// device cgroup for the security tag, as snap-confine only sets it up once
+// an app or hook with devices tagged for it is run.
+func DeviceCgroupDevices(securityTag string) ([]Device, error) {
+	var devices []Device
+	var err error
+	if IsUnified() {
+		devices, err = deviceCgroupDevicesV2(securityTag)
+	} else {
+		devices, err = deviceCgroupDevicesV1(securityTag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].Type != devices[j].Type {
+			return devices[i].Type < devices[j].Type
+		}
+		if devices[i].Major != devices[j].Major {
+			return devices[i].Major < devices[j].Major
+		}
+		return devices[i].Minor < devices[j].Minor
+	})
+	return devices, nil
+}
+
+func deviceCgroupDevicesV2(securityTag string) ([]Device, error) {
+	// bpffs does not like dots in names
+	name := strings.Replace(securityTag, ".", "_", -1)
+	keys, err := bpfMapKeys(filepath.Join(rootPath, defaultSnapBPFDir, name))
+	if os.IsNotExist(err) {
+		return nil, ErrDeviceCgroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the device map of %q: %v", securityTag, err)
+	}
+	devices := make([]Device, 0, len(keys))
+	order := nativeEndian()
+	for _, key := range keys {
+		if len(key) != bpfDeviceKeySize {
+			return nil, fmt.Errorf("cannot read the device map of %q: unexpected key size %d", securityTag, len(key))
+		}
+		devices = append(devices, Device{
+			Type:  key[0],
+			Major: order.Uint32(key[1:5]),
+			Minor: order.Uint32(key[5:9]),
+		})
+	}
+	return devices, nil
+}
+
+func deviceCgroupDevicesV1(securityTag string) ([]Device, error) {
+	f, err := os.Open(filepath.Join(rootPath, defaultDevicesCgroupV1Dir, securityTag, "devices.list"))
+	if os.IsNotExist(err) {
+		return nil, ErrDeviceCgroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var devices []Device
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		device, err := parseDevicesListEntry(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// parseDevicesListEntry parses an entry of devices.list, e.g. "c 1:3 rwm"
+// or "c 136:* rwm".
+func parseDevicesListEntry(line string) (Device, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 || len(fields[0]) != 1 {
+		return Device{}, fmt.Errorf("cannot parse devices.list entry %q", line)
+	}
+	numbers := strings.Split(fields[1], ":")
+	if len(numbers) != 2 {
+		return Device{}, fmt.Errorf("cannot parse devices.list entry %q", line)
+	}
+	parse := func(s string) (uint32, error) {
+		if s == "*" {
+			return AnyDevice, nil
+		}
+		n, err := strconv.ParseUint(s, 10, 32)
+		return uint32(n), err
+	}
+	major, err := parse(numbers[0])
+	if err != nil {
+		return Device{}, fmt.Errorf("cannot parse devices.list entry %q", line)
+	}
+	minor, err := parse(numbers[1])
+	if err != nil {
+		return Device{}, fmt.Errorf("cannot parse devices.list entry %q", line)
+	}
+	return Device{Type: fields[0][0], Major: major, Minor: minor}, nil
+}
+
+// nativeEndian returns the byte order of the host, which is the one of the
+// keys of the BPF maps.
+func nativeEndian() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
@@ -0,0 +1,147 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cgroup_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+)
+
+type devicesSuite struct{}
+
+var _ = Suite(&devicesSuite{})
+
+func (s *devicesSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *devicesSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func deviceKey(typ byte, major, minor uint32) []byte {
+	key := make([]byte, 9)
+	key[0] = typ
+	cgroup.NativeEndian().PutUint32(key[1:5], major)
+	cgroup.NativeEndian().PutUint32(key[5:9], minor)
+	return key
+}
+
+func (s *devicesSuite) TestDeviceString(c *C) {
+	c.Check(cgroup.Device{Type: 'c', Major: 1, Minor: 3}.String(), Equals, "c 1:3")
+	c.Check(cgroup.Device{Type: 'c', Major: 136, Minor: cgroup.AnyDevice}.String(), Equals, "c 136:*")
+	c.Check(cgroup.Device{Type: 'a', Major: cgroup.AnyDevice, Minor: cgroup.AnyDevice}.String(), Equals, "a *:*")
+}
+
+func (s *devicesSuite) TestDeviceAllows(c *C) {
+	pts := cgroup.Device{Type: 'c', Major: 136, Minor: cgroup.AnyDevice}
+	c.Check(pts.Allows(cgroup.Device{Type: 'c', Major: 136, Minor: 4}), Equals, true)
+	c.Check(pts.Allows(cgroup.Device{Type: 'b', Major: 136, Minor: 4}), Equals, false)
+	c.Check(pts.Allows(cgroup.Device{Type: 'c', Major: 137, Minor: 4}), Equals, false)
+
+	all := cgroup.Device{Type: 'a', Major: cgroup.AnyDevice, Minor: cgroup.AnyDevice}
+	c.Check(all.Allows(cgroup.Device{Type: 'b', Major: 8, Minor: 0}), Equals, true)
+
+	null := cgroup.Device{Type: 'c', Major: 1, Minor: 3}
+	c.Check(null.Allows(cgroup.Device{Type: 'c', Major: 1, Minor: 3}), Equals, true)
+	c.Check(null.Allows(cgroup.Device{Type: 'c', Major: 1, Minor: 5}), Equals, false)
+}
+
+func (s *devicesSuite) TestDeviceCgroupDevicesV2(c *C) {
+	defer cgroup.MockVersion(cgroup.V2, nil)()
+	var paths []string
+	defer cgroup.MockBPFMapKeys(func(path string) ([][]byte, error) {
+		paths = append(paths, path)
+		return [][]byte{
+			deviceKey('c', 81, 0),
+			deviceKey('c', 1, 3),
+			deviceKey('c', 136, cgroup.AnyDevice),
+			deviceKey('b', 8, 1),
+		}, nil
+	})()
+
+	devices, err := cgroup.DeviceCgroupDevices("snap.foo.app")
+	c.Assert(err, IsNil)
+	c.Check(paths, DeepEquals, []string{filepath.Join(dirs.GlobalRootDir, "/sys/fs/bpf/snap/snap_foo_app")})
+	c.Check(devices, DeepEquals, []cgroup.Device{
+		{Type: 'b', Major: 8, Minor: 1},
+		{Type: 'c', Major: 1, Minor: 3},
+		{Type: 'c', Major: 81, Minor: 0},
+		{Type: 'c', Major: 136, Minor: cgroup.AnyDevice},
+	})
+}
+
+func (s *devicesSuite) TestDeviceCgroupDevicesV2NotFound(c *C) {
+	defer cgroup.MockVersion(cgroup.V2, nil)()
+	defer cgroup.MockBPFMapKeys(func(path string) ([][]byte, error) {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	})()
+
+	_, err := cgroup.DeviceCgroupDevices("snap.foo.app")
+	c.Check(err, Equals, cgroup.ErrDeviceCgroupNotFound)
+}
+
+func (s *devicesSuite) TestDeviceCgroupDevicesV2Errors(c *C) {
+	defer cgroup.MockVersion(cgroup.V2, nil)()
+	restore := cgroup.MockBPFMapKeys(func(path string) ([][]byte, error) {
+		return nil, errors.New("boom")
+	})
+	_, err := cgroup.DeviceCgroupDevices("snap.foo.app")
+	c.Check(err, ErrorMatches, `cannot read the device map of "snap.foo.app": boom`)
+	restore()
+
+	defer cgroup.MockBPFMapKeys(func(path string) ([][]byte, error) {
+		return [][]byte{{'c', 1, 2}}, nil
+	})()
+	_, err = cgroup.DeviceCgroupDevices("snap.foo.app")
+	c.Check(err, ErrorMatches, `cannot read the device map of "snap.foo.app": unexpected key size 3`)
+}
+
+func (s *devicesSuite) TestDeviceCgroupDevicesV1(c *C) {
+	defer cgroup.MockVersion(cgroup.V1, nil)()
+
+	_, err := cgroup.DeviceCgroupDevices("snap.foo.app")
+	c.Check(err, Equals, cgroup.ErrDeviceCgroupNotFound)
+
+	list := filepath.Join(dirs.GlobalRootDir, "/sys/fs/cgroup/devices/snap.foo.app/devices.list")
+	c.Assert(os.MkdirAll(filepath.Dir(list), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(list, []byte("c 1:3 rwm\nc 136:* rwm\nb 8:1 rwm\n"), 0644), IsNil)
+
+	devices, err := cgroup.DeviceCgroupDevices("snap.foo.app")
+	c.Assert(err, IsNil)
+	c.Check(devices, DeepEquals, []cgroup.Device{
+		{Type: 'b', Major: 8, Minor: 1},
+		{Type: 'c', Major: 1, Minor: 3},
+		{Type: 'c', Major: 136, Minor: cgroup.AnyDevice},
+	})
+
+	for _, bad := range []string{"c 1:3", "c 1 rwm", "cc 1:3 rwm", "c x:3 rwm", "c 1:x rwm"} {
+		c.Assert(ioutil.WriteFile(list, []byte(bad+"\n"), 0644), IsNil)
+		_, err = cgroup.DeviceCgroupDevices("snap.foo.app")
+		c.Check(err, ErrorMatches, `cannot parse devices.list entry ".*"`, Commentf(bad))
+	}
+}
@@ -0,0 +1,133 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cgroup
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MemoryEvents are the counters of memory events of a control group, as
+// found in the memory.events file of the unified hierarchy. The counters
+// include the events of the descendants of the group.
+type MemoryEvents struct {
+	// Max is the number of times the memory usage of the group was about
+	// to go over the memory limit.
+	Max uint64
+	// OOM is the number of times the memory usage of the group reached the
+	// limit and an allocation was about to fail.
+	OOM uint64
+	// OOMKill is the number of processes of the group killed by the OOM
+	// killer.
+	OOMKill uint64
+}
+
+// CPUStat are the CPU statistics of a control group, as found in the
+// cpu.stat file of the unified hierarchy.
+type CPUStat struct {
+	// Usage is the total CPU time used by the group.
+	Usage time.Duration
+	// Periods is the number of enforcement periods of the CPU limit that
+	// elapsed, ThrottledPeriods the number of those in which the group was
+	// throttled.
+	Periods          uint64
+	ThrottledPeriods uint64
+	// Throttled is the total time the group was throttled for.
+	Throttled time.Duration
+}
+
+// readKeyedCounters reads a flat keyed file of the unified hierarchy, made
+// of lines in the "<key> <value>" format.
+func readKeyedCounters(fname string) (map[string]uint64, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	counters := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("cannot parse %s: invalid line %q", fname, line)
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: invalid value in line %q", fname, line)
+		}
+		counters[fields[0]] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read %s: %v", fname, err)
+	}
+	return counters, nil
+}
+
+func unifiedGroupFile(groupPath, name string) (string, error) {
+	if !IsUnified() {
+		return "", fmt.Errorf("cannot read %s: not using the unified hierarchy", name)
+	}
+	return filepath.Join(rootPath, cgroupMountPoint, groupPath, name), nil
+}
+
+// MemoryEventsOfGroup returns the memory events of the control group at the
+// given path relative to the root of the unified hierarchy.
+func MemoryEventsOfGroup(groupPath string) (*MemoryEvents, error) {
+	fname, err := unifiedGroupFile(groupPath, "memory.events")
+	if err != nil {
+		return nil, err
+	}
+	counters, err := readKeyedCounters(fname)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryEvents{
+		Max:     counters["max"],
+		OOM:     counters["oom"],
+		OOMKill: counters["oom_kill"],
+	}, nil
+}
+
+// CPUStatOfGroup returns the CPU statistics of the control group at the given
+// path relative to the root of the unified hierarchy. The period counters are
+// only present when the group has a CPU limit, otherwise they are 0.
+func CPUStatOfGroup(groupPath string) (*CPUStat, error) {
+	fname, err := unifiedGroupFile(groupPath, "cpu.stat")
+	if err != nil {
+		return nil, err
+	}
+	counters, err := readKeyedCounters(fname)
+	if err != nil {
+		return nil, err
+	}
+	return &CPUStat{
+		Usage:            time.Duration(counters["usage_usec"]) * time.Microsecond,
+		Periods:          counters["nr_periods"],
+		ThrottledPeriods: counters["nr_throttled"],
+		Throttled:        time.Duration(counters["throttled_usec"]) * time.Microsecond,
+	}, nil
+}
@@ -0,0 +1,126 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cgroup_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type eventsSuite struct {
+	testutil.BaseTest
+
+	rootDir string
+}
+
+var _ = Suite(&eventsSuite{})
+
+func (s *eventsSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.rootDir = c.MkDir()
+	dirs.SetRootDir(s.rootDir)
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	s.AddCleanup(cgroup.MockVersion(cgroup.V2, nil))
+}
+
+func (s *eventsSuite) mockGroupFile(c *C, groupPath, name, content string) {
+	dir := filepath.Join(s.rootDir, "/sys/fs/cgroup", groupPath)
+	c.Assert(os.MkdirAll(dir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644), IsNil)
+}
+
+func (s *eventsSuite) TestMemoryEventsOfGroup(c *C) {
+	s.mockGroupFile(c, "/snap.foo.slice", "memory.events", `low 0
+high 0
+max 12
+oom 3
+oom_kill 2
+oom_group_kill 0
+`)
+
+	events, err := cgroup.MemoryEventsOfGroup("/snap.foo.slice")
+	c.Assert(err, IsNil)
+	c.Check(events, DeepEquals, &cgroup.MemoryEvents{
+		Max:     12,
+		OOM:     3,
+		OOMKill: 2,
+	})
+}
+
+func (s *eventsSuite) TestCPUStatOfGroup(c *C) {
+	s.mockGroupFile(c, "/snap.foo.slice/snap.foo-bar.slice", "cpu.stat", `usage_usec 2500000
+user_usec 2000000
+system_usec 500000
+nr_periods 100
+nr_throttled 40
+throttled_usec 1000000
+`)
+
+	stat, err := cgroup.CPUStatOfGroup("/snap.foo.slice/snap.foo-bar.slice")
+	c.Assert(err, IsNil)
+	c.Check(stat, DeepEquals, &cgroup.CPUStat{
+		Usage:            2500 * time.Millisecond,
+		Periods:          100,
+		ThrottledPeriods: 40,
+		Throttled:        time.Second,
+	})
+}
+
+func (s *eventsSuite) TestCPUStatOfGroupNoLimit(c *C) {
+	s.mockGroupFile(c, "/snap.foo.slice", "cpu.stat", `usage_usec 1000
+user_usec 1000
+system_usec 0
+`)
+
+	stat, err := cgroup.CPUStatOfGroup("/snap.foo.slice")
+	c.Assert(err, IsNil)
+	c.Check(stat, DeepEquals, &cgroup.CPUStat{
+		Usage: time.Millisecond,
+	})
+}
+
+func (s *eventsSuite) TestEventsErrors(c *C) {
+	_, err := cgroup.MemoryEventsOfGroup("/snap.foo.slice")
+	c.Check(err, ErrorMatches, `open .*/sys/fs/cgroup/snap.foo.slice/memory.events: no such file or directory`)
+
+	s.mockGroupFile(c, "/snap.foo.slice", "memory.events", "oom\n")
+	_, err = cgroup.MemoryEventsOfGroup("/snap.foo.slice")
+	c.Check(err, ErrorMatches, `cannot parse .*/memory.events: invalid line "oom"`)
+
+	s.mockGroupFile(c, "/snap.foo.slice", "cpu.stat", "nr_periods many\n")
+	_, err = cgroup.CPUStatOfGroup("/snap.foo.slice")
+	c.Check(err, ErrorMatches, `cannot parse .*/cpu.stat: invalid value in line "nr_periods many"`)
+
+	restore := cgroup.MockVersion(cgroup.V1, nil)
+	defer restore()
+	_, err = cgroup.MemoryEventsOfGroup("/snap.foo.slice")
+	c.Check(err, ErrorMatches, `cannot read memory.events: not using the unified hierarchy`)
+	_, err = cgroup.CPUStatOfGroup("/snap.foo.slice")
+	c.Check(err, ErrorMatches, `cannot read cpu.stat: not using the unified hierarchy`)
+}
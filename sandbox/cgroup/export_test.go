@@ -111,3 +111,11 @@ func MockCgroupsFilePath(path string) (restore func()) {
 	cgroupsFilePath = path
 	return r
 }
+
+var NativeEndian = nativeEndian
+
+func MockBPFMapKeys(f func(path string) ([][]byte, error)) (restore func()) {
+	r := testutil.Backup(&bpfMapKeys)
+	bpfMapKeys = f
+	return r
+}
@@ -22,7 +22,9 @@
 package sandbox
 
 import (
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/sandbox/apparmor"
+	"github.com/snapcore/snapd/sandbox/landlock"
 )
 
 // For testing only
@@ -39,6 +41,18 @@ func ForceDevMode() bool {
 	return !apparmorFull
 }
 
+// LandlockConfinement returns true if the filesystem access of strictly
+// confined snaps is restricted with Landlock, on systems without full
+// AppArmor support, as enabled by the experimental.landlock-confinement
+// feature. Landlock does not mediate everything AppArmor does, so such
+// systems are still in forced devmode as reported by ForceDevMode.
+func LandlockConfinement() bool {
+	if apparmor.ProbedLevel() == apparmor.Full {
+		return false
+	}
+	return features.LandlockConfinement.IsEnabled() && landlock.ABIVersion() > 0
+}
+
 // MockForceDevMode fake the system to believe its in a distro
 // that is in forced devmode as returned by ForceDevMode.
 func MockForceDevMode(forcedDevMode bool) (restore func()) {
@@ -20,13 +20,18 @@
 package sandbox_test
 
 import (
+	"os"
 	"testing"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/sandbox"
 	"github.com/snapcore/snapd/sandbox/apparmor"
 	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/sandbox/landlock"
 )
 
 func Test(t *testing.T) { TestingT(t) }
@@ -64,6 +69,41 @@ func (s *forceDevModeSuite) TestForceDevMode(c *C) {
 	}
 }
 
+func (s *forceDevModeSuite) TestForceDevModeLandlock(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+	c.Assert(os.MkdirAll(dirs.FeaturesDir, 0755), IsNil)
+
+	for _, tc := range []struct {
+		apparmorLevel apparmor.LevelType
+		landlockABI   int
+		enabled       bool
+		landlock      bool
+		devMode       bool
+	}{
+		{apparmor.Full, 3, true, false, false},
+		// landlock only restricts filesystem access, the confinement
+		// is still reported as partial
+		{apparmor.Partial, 3, true, true, true},
+		{apparmor.Unsupported, 1, true, true, true},
+		{apparmor.Unsupported, 0, true, false, true},
+		{apparmor.Unsupported, 3, false, false, true},
+	} {
+		restore := apparmor.MockLevel(tc.apparmorLevel)
+		defer restore()
+		restore = landlock.MockABIVersion(tc.landlockABI)
+		defer restore()
+		if tc.enabled {
+			c.Assert(osutil.AtomicWriteFile(features.LandlockConfinement.ControlFile(), nil, 0644, 0), IsNil)
+		} else {
+			c.Assert(os.RemoveAll(features.LandlockConfinement.ControlFile()), IsNil)
+		}
+		comment := Commentf("AppArmor level %v, landlock ABI %v, enabled: %v", tc.apparmorLevel, tc.landlockABI, tc.enabled)
+		c.Check(sandbox.LandlockConfinement(), Equals, tc.landlock, comment)
+		c.Check(sandbox.ForceDevMode(), Equals, tc.devMode, comment)
+	}
+}
+
 func (s *forceDevModeSuite) TestMockForceDevMode(c *C) {
 	for _, devmode := range []bool{true, false} {
 		restore := sandbox.MockForceDevMode(devmode)
@@ -0,0 +1,54 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package landlock
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/snapcore/snapd/testutil"
+)
+
+var HandledAccessFS = handledAccessFS
+
+func MockSyscall6(f func(trap, a1, a2, a3, a4, a5, a6 uintptr) (r1, r2 uintptr, err syscall.Errno)) (restore func()) {
+	restore = testutil.Backup(&syscall6)
+	syscall6 = f
+	return restore
+}
+
+func FreshLandlockProbe() {
+	landlockProber = &landlockProbe{}
+}
+
+var NoNewPrivsNeeded = noNewPrivsNeeded
+
+func MockPrctlRetInt(f func(option int, arg2, arg3, arg4, arg5 uintptr) (int, error)) (restore func()) {
+	restore = testutil.Backup(&prctlRetInt)
+	prctlRetInt = f
+	return restore
+}
+
+func MockCapget(f func(hdr *unix.CapUserHeader, data *unix.CapUserData) error) (restore func()) {
+	restore = testutil.Backup(&capget)
+	capget = f
+	return restore
+}
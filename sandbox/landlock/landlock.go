@@ -0,0 +1,346 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package landlock offers support for the Landlock LSM, which lets an
+// unprivileged process restrict its own access to the filesystem, and the
+// access of all the processes it executes, to a set of file hierarchies.
+package landlock
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// AccessFS is a set of Landlock filesystem access rights.
+type AccessFS uint64
+
+const (
+	accessFSExecute    AccessFS = unix.LANDLOCK_ACCESS_FS_EXECUTE
+	accessFSWriteFile  AccessFS = unix.LANDLOCK_ACCESS_FS_WRITE_FILE
+	accessFSReadFile   AccessFS = unix.LANDLOCK_ACCESS_FS_READ_FILE
+	accessFSReadDir    AccessFS = unix.LANDLOCK_ACCESS_FS_READ_DIR
+	accessFSRemoveDir  AccessFS = unix.LANDLOCK_ACCESS_FS_REMOVE_DIR
+	accessFSRemoveFile AccessFS = unix.LANDLOCK_ACCESS_FS_REMOVE_FILE
+	accessFSMakeChar   AccessFS = unix.LANDLOCK_ACCESS_FS_MAKE_CHAR
+	accessFSMakeDir    AccessFS = unix.LANDLOCK_ACCESS_FS_MAKE_DIR
+	accessFSMakeReg    AccessFS = unix.LANDLOCK_ACCESS_FS_MAKE_REG
+	accessFSMakeSock   AccessFS = unix.LANDLOCK_ACCESS_FS_MAKE_SOCK
+	accessFSMakeFifo   AccessFS = unix.LANDLOCK_ACCESS_FS_MAKE_FIFO
+	accessFSMakeBlock  AccessFS = unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK
+	accessFSMakeSym    AccessFS = unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+	// available since ABI version 2
+	accessFSRefer AccessFS = unix.LANDLOCK_ACCESS_FS_REFER
+	// available since ABI version 3
+	accessFSTruncate AccessFS = 1 << 14
+
+	// accessFSFile are the only access rights that apply to files rather
+	// than to directories.
+	accessFSFile = accessFSExecute | accessFSWriteFile | accessFSReadFile | accessFSTruncate
+)
+
+const (
+	// AccessRead grants reading and executing files and listing
+	// directories.
+	AccessRead = accessFSExecute | accessFSReadFile | accessFSReadDir
+	// AccessWrite grants AccessRead as well as creating, modifying,
+	// renaming and removing files and directories.
+	AccessWrite = AccessRead | accessFSWriteFile | accessFSRemoveDir | accessFSRemoveFile |
+		accessFSMakeChar | accessFSMakeDir | accessFSMakeReg | accessFSMakeSock |
+		accessFSMakeFifo | accessFSMakeBlock | accessFSMakeSym | accessFSRefer | accessFSTruncate
+)
+
+// handledAccessFS returns the access rights known to the given version of
+// the Landlock ABI, all of which are denied unless granted by a rule.
+func handledAccessFS(abi int) AccessFS {
+	switch {
+	case abi <= 0:
+		return 0
+	case abi == 1:
+		return AccessWrite &^ (accessFSRefer | accessFSTruncate)
+	case abi == 2:
+		return AccessWrite &^ accessFSTruncate
+	default:
+		return AccessWrite
+	}
+}
+
+// String returns the representation of the access rights used in rule
+// files.
+func (a AccessFS) String() string {
+	switch a {
+	case AccessRead:
+		return "read"
+	case AccessWrite:
+		return "write"
+	}
+	return fmt.Sprintf("%#x", uint64(a))
+}
+
+// Rule grants access to the file hierarchy rooted at Path.
+type Rule struct {
+	Access AccessFS
+	Path   string
+}
+
+// String returns the representation of the rule used in rule files.
+func (r Rule) String() string {
+	return fmt.Sprintf("%s %s", r.Access, r.Path)
+}
+
+// ParseRule parses a rule in the form "read <path>" or "write <path>".
+func ParseRule(line string) (Rule, error) {
+	fields := strings.SplitN(line, " ", 2)
+	if len(fields) != 2 || fields[1] == "" {
+		return Rule{}, fmt.Errorf("cannot parse landlock rule %q", line)
+	}
+	var access AccessFS
+	switch fields[0] {
+	case "read":
+		access = AccessRead
+	case "write":
+		access = AccessWrite
+	default:
+		return Rule{}, fmt.Errorf("cannot parse landlock rule %q: unknown access %q", line, fields[0])
+	}
+	return Rule{Access: access, Path: fields[1]}, nil
+}
+
+// ReadRules reads rules, one per line, skipping empty lines and comments
+// starting with #.
+func ReadRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := ParseRule(line)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ExpandRules returns the rules with the $VARIABLES in their paths expanded
+// through the given mapping. Rules using a variable that expands to an
+// empty value are dropped, as they would otherwise grant access to
+// unrelated paths. Paths with the shell patterns of filepath.Match, used
+// for numbered device nodes, are replaced by the paths matching them at the
+// time of the call. As in the shell, hidden files only match patterns that
+// start with a dot.
+func ExpandRules(rules []Rule, mapping func(string) string) []Rule {
+	expanded := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		missing := false
+		path := os.Expand(rule.Path, func(name string) string {
+			value := mapping(name)
+			if value == "" {
+				missing = true
+			}
+			return value
+		})
+		if missing {
+			continue
+		}
+		if strings.ContainsAny(path, "*?[") {
+			// malformed patterns match nothing
+			matches, _ := filepath.Glob(path)
+			for _, match := range matches {
+				if matchesHiddenFile(path, match) {
+					continue
+				}
+				expanded = append(expanded, Rule{Access: rule.Access, Path: match})
+			}
+			continue
+		}
+		expanded = append(expanded, Rule{Access: rule.Access, Path: path})
+	}
+	return expanded
+}
+
+// matchesHiddenFile returns whether the pattern matches a hidden file or
+// directory through one of its elements that does not start with a dot.
+func matchesHiddenFile(pattern, match string) bool {
+	patternElems := strings.Split(pattern, "/")
+	matchElems := strings.Split(match, "/")
+	// filepath.Glob matches element by element
+	for i, elem := range patternElems {
+		if i < len(matchElems) && strings.HasPrefix(matchElems[i], ".") && !strings.HasPrefix(elem, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// probing
+
+var landlockProber = &landlockProbe{}
+
+type landlockProbe struct {
+	abi  int
+	once sync.Once
+}
+
+func (lp *landlockProbe) abiVersion() int {
+	lp.once.Do(func() {
+		lp.abi = probeABIVersion()
+	})
+	return lp.abi
+}
+
+var syscall6 = unix.Syscall6
+
+func probeABIVersion() int {
+	abi, _, errno := syscall6(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION, 0, 0, 0)
+	if errno != 0 {
+		// ENOSYS when the kernel was built without Landlock,
+		// EOPNOTSUPP when it is not enabled at boot
+		return 0
+	}
+	return int(abi)
+}
+
+// ABIVersion returns the version of the Landlock ABI supported by the
+// kernel, or 0 if Landlock is not supported or not enabled.
+func ABIVersion() int {
+	return landlockProber.abiVersion()
+}
+
+// RestrictSelf restricts the access of the calling thread to the
+// filesystem to what the rules grant. Rules for paths that do not exist
+// are ignored. The restriction cannot be undone and is inherited by the
+// processes executed by the thread afterwards.
+//
+// As Landlock applies to individual threads, the caller must lock the
+// goroutine to its OS thread before calling RestrictSelf and then exec
+// from it.
+func RestrictSelf(rules []Rule) error {
+	abi := ABIVersion()
+	if abi == 0 {
+		return fmt.Errorf("cannot restrict filesystem access: landlock is not supported")
+	}
+	handled := handledAccessFS(abi)
+
+	attr := unix.LandlockRulesetAttr{Access_fs: uint64(handled)}
+	fd, _, errno := syscall6(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0, 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("cannot create landlock ruleset: %v", errno)
+	}
+	rulesetFd := int(fd)
+	defer unix.Close(rulesetFd)
+
+	for _, rule := range rules {
+		if err := addPathRule(rulesetFd, rule, handled); err != nil {
+			return err
+		}
+	}
+
+	needed, err := noNewPrivsNeeded()
+	if err != nil {
+		return err
+	}
+	if needed {
+		if err := prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+			return fmt.Errorf("cannot set no_new_privs: %v", err)
+		}
+	}
+	if _, _, errno := syscall6(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(rulesetFd), 0, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("cannot restrict filesystem access: %v", errno)
+	}
+	return nil
+}
+
+var (
+	prctlRetInt = unix.PrctlRetInt
+	prctl       = unix.Prctl
+	capget      = unix.Capget
+)
+
+// noNewPrivsNeeded returns whether no_new_privs must be set before the
+// calling thread can restrict itself. The kernel only requires it from
+// threads without CAP_SYS_ADMIN, and it is left alone when it is already
+// set, so that privileged applications keep being able to gain privileges
+// through set-user-ID and file capabilities.
+func noNewPrivsNeeded() (bool, error) {
+	nnp, err := prctlRetInt(unix.PR_GET_NO_NEW_PRIVS, 0, 0, 0, 0)
+	if err != nil {
+		return false, fmt.Errorf("cannot get no_new_privs: %v", err)
+	}
+	if nnp == 1 {
+		return false, nil
+	}
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := capget(&hdr, &data[0]); err != nil {
+		return false, fmt.Errorf("cannot get capabilities: %v", err)
+	}
+	return data[0].Effective&(1<<unix.CAP_SYS_ADMIN) == 0, nil
+}
+
+func addPathRule(rulesetFd int, rule Rule, handled AccessFS) error {
+	pathFd, err := unix.Open(rule.Path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		if err == unix.ENOENT {
+			return nil
+		}
+		return fmt.Errorf("cannot open %q for landlock rule: %v", rule.Path, err)
+	}
+	defer unix.Close(pathFd)
+
+	access := rule.Access & handled
+	var st unix.Stat_t
+	if err := unix.Fstat(pathFd, &st); err != nil {
+		return fmt.Errorf("cannot stat %q for landlock rule: %v", rule.Path, err)
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= accessFSFile
+	}
+
+	attr := unix.LandlockPathBeneathAttr{Allowed_access: uint64(access), Parent_fd: int32(pathFd)}
+	if _, _, errno := syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(rulesetFd), unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&attr)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("cannot add landlock rule for %q: %v", rule.Path, errno)
+	}
+	return nil
+}
+
+// mocking
+
+// MockABIVersion makes ABIVersion return the given version.
+func MockABIVersion(abi int) (restore func()) {
+	old := landlockProber
+	landlockProber = &landlockProbe{abi: abi}
+	landlockProber.once.Do(func() {})
+	return func() {
+		landlockProber = old
+	}
+}
@@ -0,0 +1,231 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package landlock_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/sandbox/landlock"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type landlockSuite struct{}
+
+var _ = Suite(&landlockSuite{})
+
+func (s *landlockSuite) TearDownTest(c *C) {
+	landlock.FreshLandlockProbe()
+}
+
+func (s *landlockSuite) TestParseRule(c *C) {
+	rule, err := landlock.ParseRule("read /usr")
+	c.Assert(err, IsNil)
+	c.Check(rule, Equals, landlock.Rule{Access: landlock.AccessRead, Path: "/usr"})
+	c.Check(rule.String(), Equals, "read /usr")
+
+	rule, err = landlock.ParseRule("write $SNAP_REAL_HOME/some dir")
+	c.Assert(err, IsNil)
+	c.Check(rule, Equals, landlock.Rule{Access: landlock.AccessWrite, Path: "$SNAP_REAL_HOME/some dir"})
+	c.Check(rule.String(), Equals, "write $SNAP_REAL_HOME/some dir")
+
+	for _, line := range []string{"", "read", "read ", "/usr"} {
+		_, err = landlock.ParseRule(line)
+		c.Check(err, ErrorMatches, `cannot parse landlock rule .*`, Commentf(line))
+	}
+	_, err = landlock.ParseRule("exec /usr")
+	c.Check(err, ErrorMatches, `cannot parse landlock rule "exec /usr": unknown access "exec"`)
+}
+
+func (s *landlockSuite) TestReadRules(c *C) {
+	rules, err := landlock.ReadRules(strings.NewReader(`# a comment
+
+read /usr
+  write /tmp
+`))
+	c.Assert(err, IsNil)
+	c.Check(rules, DeepEquals, []landlock.Rule{
+		{Access: landlock.AccessRead, Path: "/usr"},
+		{Access: landlock.AccessWrite, Path: "/tmp"},
+	})
+
+	_, err = landlock.ReadRules(strings.NewReader("read /usr\nbogus\n"))
+	c.Check(err, ErrorMatches, `cannot parse landlock rule "bogus"`)
+}
+
+func (s *landlockSuite) TestExpandRules(c *C) {
+	env := map[string]string{"SNAP": "/snap/foo/1", "SNAP_REAL_HOME": "/home/user"}
+	rules := landlock.ExpandRules([]landlock.Rule{
+		{Access: landlock.AccessRead, Path: "$SNAP"},
+		{Access: landlock.AccessWrite, Path: "$SNAP_REAL_HOME/.config/foo"},
+		{Access: landlock.AccessWrite, Path: "$XDG_RUNTIME_DIR/foo"},
+		{Access: landlock.AccessRead, Path: "/usr"},
+	}, func(name string) string { return env[name] })
+	c.Check(rules, DeepEquals, []landlock.Rule{
+		{Access: landlock.AccessRead, Path: "/snap/foo/1"},
+		{Access: landlock.AccessWrite, Path: "/home/user/.config/foo"},
+		{Access: landlock.AccessRead, Path: "/usr"},
+	})
+}
+
+func (s *landlockSuite) TestExpandRulesPatterns(c *C) {
+	dev := c.MkDir()
+	for _, name := range []string{"video0", "video1", "vchiq"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(dev, name), nil, 0644), IsNil)
+	}
+	env := map[string]string{"DEV": dev}
+	rules := landlock.ExpandRules([]landlock.Rule{
+		{Access: landlock.AccessWrite, Path: "$DEV/video[0-9]*"},
+		{Access: landlock.AccessWrite, Path: "$DEV/ttyUSB[0-9]*"},
+		{Access: landlock.AccessWrite, Path: "$DEV/vchiq"},
+		{Access: landlock.AccessWrite, Path: "$DEV/[bogus"},
+	}, func(name string) string { return env[name] })
+	c.Check(rules, DeepEquals, []landlock.Rule{
+		{Access: landlock.AccessWrite, Path: filepath.Join(dev, "video0")},
+		{Access: landlock.AccessWrite, Path: filepath.Join(dev, "video1")},
+		{Access: landlock.AccessWrite, Path: filepath.Join(dev, "vchiq")},
+	})
+}
+
+func (s *landlockSuite) TestExpandRulesPatternsSkipHiddenFiles(c *C) {
+	home := c.MkDir()
+	for _, name := range []string{"Documents", ".ssh", "snap", ".bashrc", "notes.txt"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(home, name), nil, 0644), IsNil)
+	}
+	env := map[string]string{"HOME": home}
+	rules := landlock.ExpandRules([]landlock.Rule{
+		{Access: landlock.AccessWrite, Path: "$HOME/*"},
+		{Access: landlock.AccessRead, Path: "$HOME/.s*"},
+	}, func(name string) string { return env[name] })
+	c.Check(rules, DeepEquals, []landlock.Rule{
+		{Access: landlock.AccessWrite, Path: filepath.Join(home, "Documents")},
+		{Access: landlock.AccessWrite, Path: filepath.Join(home, "notes.txt")},
+		{Access: landlock.AccessWrite, Path: filepath.Join(home, "snap")},
+		{Access: landlock.AccessRead, Path: filepath.Join(home, ".ssh")},
+	})
+}
+
+func (s *landlockSuite) TestHandledAccessFS(c *C) {
+	c.Check(landlock.HandledAccessFS(0), Equals, landlock.AccessFS(0))
+	c.Check(landlock.HandledAccessFS(1), Equals, landlock.AccessFS(0x1fff))
+	c.Check(landlock.HandledAccessFS(2), Equals, landlock.AccessFS(0x3fff))
+	c.Check(landlock.HandledAccessFS(3), Equals, landlock.AccessFS(0x7fff))
+	c.Check(landlock.HandledAccessFS(4), Equals, landlock.AccessWrite)
+	c.Check(landlock.AccessRead&^landlock.AccessWrite, Equals, landlock.AccessFS(0))
+}
+
+func (s *landlockSuite) TestABIVersion(c *C) {
+	calls := 0
+	restore := landlock.MockSyscall6(func(trap, a1, a2, a3, a4, a5, a6 uintptr) (r1, r2 uintptr, err syscall.Errno) {
+		calls++
+		c.Check(trap, Equals, uintptr(444))
+		c.Check([]uintptr{a1, a2, a3}, DeepEquals, []uintptr{0, 0, 1})
+		return 3, 0, 0
+	})
+	defer restore()
+
+	c.Check(landlock.ABIVersion(), Equals, 3)
+	// the result is cached
+	c.Check(landlock.ABIVersion(), Equals, 3)
+	c.Check(calls, Equals, 1)
+}
+
+func (s *landlockSuite) TestABIVersionUnsupported(c *C) {
+	restore := landlock.MockSyscall6(func(trap, a1, a2, a3, a4, a5, a6 uintptr) (r1, r2 uintptr, err syscall.Errno) {
+		return ^uintptr(0), 0, syscall.EOPNOTSUPP
+	})
+	defer restore()
+
+	c.Check(landlock.ABIVersion(), Equals, 0)
+}
+
+func (s *landlockSuite) TestMockABIVersion(c *C) {
+	restore := landlock.MockABIVersion(2)
+	c.Check(landlock.ABIVersion(), Equals, 2)
+	restore()
+
+	restore = landlock.MockABIVersion(0)
+	defer restore()
+	c.Check(landlock.ABIVersion(), Equals, 0)
+}
+
+func (s *landlockSuite) TestRestrictSelfUnsupported(c *C) {
+	restore := landlock.MockABIVersion(0)
+	defer restore()
+
+	err := landlock.RestrictSelf([]landlock.Rule{{Access: landlock.AccessRead, Path: "/"}})
+	c.Check(err, ErrorMatches, "cannot restrict filesystem access: landlock is not supported")
+}
+
+func (s *landlockSuite) TestNoNewPrivsNeeded(c *C) {
+	for _, t := range []struct {
+		nnp       int
+		effective uint32
+		needed    bool
+	}{
+		{nnp: 0, effective: 0, needed: true},
+		{nnp: 0, effective: 1 << unix.CAP_NET_ADMIN, needed: true},
+		{nnp: 0, effective: 1 << unix.CAP_SYS_ADMIN, needed: false},
+		{nnp: 1, effective: 0, needed: false},
+	} {
+		restore := landlock.MockPrctlRetInt(func(option int, arg2, arg3, arg4, arg5 uintptr) (int, error) {
+			c.Check(option, Equals, unix.PR_GET_NO_NEW_PRIVS)
+			return t.nnp, nil
+		})
+		defer restore()
+		restore = landlock.MockCapget(func(hdr *unix.CapUserHeader, data *unix.CapUserData) error {
+			c.Check(hdr.Version, Equals, uint32(unix.LINUX_CAPABILITY_VERSION_3))
+			data.Effective = t.effective
+			return nil
+		})
+		defer restore()
+
+		needed, err := landlock.NoNewPrivsNeeded()
+		c.Assert(err, IsNil)
+		c.Check(needed, Equals, t.needed, Commentf("%+v", t))
+	}
+}
+
+func (s *landlockSuite) TestNoNewPrivsNeededErrors(c *C) {
+	restore := landlock.MockPrctlRetInt(func(option int, arg2, arg3, arg4, arg5 uintptr) (int, error) {
+		return 0, syscall.EINVAL
+	})
+	defer restore()
+	_, err := landlock.NoNewPrivsNeeded()
+	c.Check(err, ErrorMatches, "cannot get no_new_privs: invalid argument")
+
+	restore = landlock.MockPrctlRetInt(func(option int, arg2, arg3, arg4, arg5 uintptr) (int, error) {
+		return 0, nil
+	})
+	defer restore()
+	restore = landlock.MockCapget(func(hdr *unix.CapUserHeader, data *unix.CapUserData) error {
+		return syscall.EPERM
+	})
+	defer restore()
+	_, err = landlock.NoNewPrivsNeeded()
+	c.Check(err, ErrorMatches, "cannot get capabilities: operation not permitted")
+}
@@ -0,0 +1,52 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package seccomp
+
+// IoctlSetsFeature is the feature advertised in the version information
+// of snap-seccomp when it can compile the named sets of ioctl requests.
+const IoctlSetsFeature = "ioctl-sets"
+
+// IoctlSets are the named sets of ioctl requests that policies can allow
+// with "ioctl - @<set>". snap-seccomp expands each set into one rule per
+// request, so every request must be known to its resolver.
+var IoctlSets = map[string][]string{
+	// the requests needed to look up network interfaces, e.g. the CAN
+	// interface to bind a socket to
+	"netdevice": {"SIOCGIFNAME", "SIOCGIFINDEX", "SIOCGIFFLAGS", "SIOCGIFMTU", "SIOCGIFHWADDR"},
+	// the requests needed to create and configure an input device
+	"uinput": {
+		"UI_DEV_CREATE", "UI_DEV_DESTROY",
+		"UI_SET_EVBIT", "UI_SET_KEYBIT", "UI_SET_RELBIT", "UI_SET_ABSBIT",
+		"UI_SET_MSCBIT", "UI_SET_LEDBIT", "UI_SET_SNDBIT", "UI_SET_FFBIT",
+		"UI_SET_PHYS", "UI_SET_SWBIT", "UI_SET_PROPBIT",
+	},
+	// the requests used by libusb to talk to USB devices through usbfs
+	"usbdevfs": {
+		"USBDEVFS_CONTROL", "USBDEVFS_BULK", "USBDEVFS_RESETEP",
+		"USBDEVFS_SETINTERFACE", "USBDEVFS_SETCONFIGURATION",
+		"USBDEVFS_GETDRIVER", "USBDEVFS_SUBMITURB", "USBDEVFS_DISCARDURB",
+		"USBDEVFS_REAPURB", "USBDEVFS_REAPURBNDELAY",
+		"USBDEVFS_CLAIMINTERFACE", "USBDEVFS_RELEASEINTERFACE",
+		"USBDEVFS_CONNECTINFO", "USBDEVFS_IOCTL", "USBDEVFS_RESET",
+		"USBDEVFS_CLEAR_HALT", "USBDEVFS_DISCONNECT", "USBDEVFS_CONNECT",
+		"USBDEVFS_GET_CAPABILITIES", "USBDEVFS_DISCONNECT_CLAIM",
+		"USBDEVFS_ALLOC_STREAMS", "USBDEVFS_FREE_STREAMS", "USBDEVFS_GET_SPEED",
+	},
+}
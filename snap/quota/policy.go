@@ -0,0 +1,93 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package quota
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/gadget/quantity"
+)
+
+// LimitAction is the action taken by snapd when a quota group hits one of
+// its limits.
+type LimitAction string
+
+const (
+	// LimitActionWarn only records a warning about the group hitting its
+	// limit.
+	LimitActionWarn LimitAction = "warn"
+	// LimitActionRestart restarts the services of the snaps in the group.
+	LimitActionRestart LimitAction = "restart"
+	// LimitActionRaise raises the memory limit of the group to the memory
+	// ceiling of the policy when the group runs out of memory. For other
+	// limits it behaves like LimitActionWarn.
+	LimitActionRaise LimitAction = "raise"
+)
+
+// LimitPolicy describes what snapd does when the processes of a quota group
+// are killed by the OOM killer, or are throttled for a sustained period of
+// time by the CPU limit of the group.
+type LimitPolicy struct {
+	// Action is the action taken when the group hits one of its limits.
+	Action LimitAction `json:"action"`
+
+	// MemoryCeiling is the memory limit the group is raised to by the
+	// "raise" action, it must be at least the memory limit of the group.
+	MemoryCeiling quantity.Size `json:"memory-ceiling,omitempty"`
+}
+
+// Validate checks that the policy is consistent in itself and with the
+// resource limits it applies to.
+func (p *LimitPolicy) Validate(limits Resources) error {
+	switch p.Action {
+	case LimitActionWarn, LimitActionRestart:
+		if p.MemoryCeiling != 0 {
+			return fmt.Errorf("memory ceiling can only be used with the %q limit action", LimitActionRaise)
+		}
+	case LimitActionRaise:
+		if limits.Memory == nil {
+			return fmt.Errorf("cannot use the %q limit action without a memory limit", LimitActionRaise)
+		}
+		if p.MemoryCeiling == 0 {
+			return fmt.Errorf("cannot use the %q limit action without a memory ceiling", LimitActionRaise)
+		}
+		if p.MemoryCeiling < limits.Memory.Limit {
+			return fmt.Errorf("memory ceiling %s is smaller than the memory limit %s",
+				p.MemoryCeiling.IECString(), limits.Memory.Limit.IECString())
+		}
+	default:
+		return fmt.Errorf("unknown limit action %q", p.Action)
+	}
+	return nil
+}
+
+// CheckFeatureRequirements checks if the current system meets the
+// requirements for detecting the events the policy reacts to.
+func (p *LimitPolicy) CheckFeatureRequirements() error {
+	if cgroupVerErr != nil {
+		return cgroupVerErr
+	}
+	// the OOM events and the CPU throttling statistics are only available
+	// with the unified hierarchy
+	if cgroupVer < 2 {
+		return fmt.Errorf("cannot use limit policy with cgroup version %d", cgroupVer)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package quota_test
+
+import (
+	"fmt"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/snap/quota"
+)
+
+type policyTestSuite struct{}
+
+var _ = Suite(&policyTestSuite{})
+
+func (s *policyTestSuite) TestValidate(c *C) {
+	withMemory := quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build()
+	withThreads := quota.NewResourcesBuilder().WithThreadLimit(16).Build()
+
+	tests := []struct {
+		policy quota.LimitPolicy
+		limits quota.Resources
+		err    string
+	}{
+		{quota.LimitPolicy{Action: quota.LimitActionWarn}, withThreads, ""},
+		{quota.LimitPolicy{Action: quota.LimitActionRestart}, withMemory, ""},
+		{quota.LimitPolicy{Action: quota.LimitActionRaise, MemoryCeiling: quantity.SizeMiB}, withMemory, ""},
+		{quota.LimitPolicy{Action: quota.LimitActionRaise, MemoryCeiling: quantity.SizeGiB}, withMemory, ""},
+		{quota.LimitPolicy{Action: "explode"}, withMemory, `unknown limit action "explode"`},
+		{quota.LimitPolicy{}, withMemory, `unknown limit action ""`},
+		{quota.LimitPolicy{Action: quota.LimitActionWarn, MemoryCeiling: quantity.SizeGiB}, withMemory, `memory ceiling can only be used with the "raise" limit action`},
+		{quota.LimitPolicy{Action: quota.LimitActionRaise, MemoryCeiling: quantity.SizeGiB}, withThreads, `cannot use the "raise" limit action without a memory limit`},
+		{quota.LimitPolicy{Action: quota.LimitActionRaise}, withMemory, `cannot use the "raise" limit action without a memory ceiling`},
+		{quota.LimitPolicy{Action: quota.LimitActionRaise, MemoryCeiling: quantity.SizeKiB}, withMemory, `memory ceiling 1 KiB is smaller than the memory limit 1 MiB`},
+	}
+
+	for _, t := range tests {
+		err := t.policy.Validate(t.limits)
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf("%+v", t.policy))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf("%+v", t.policy))
+		}
+	}
+}
+
+func (s *policyTestSuite) TestCheckFeatureRequirements(c *C) {
+	policy := &quota.LimitPolicy{Action: quota.LimitActionWarn}
+
+	r := quota.MockCgroupVer(2)
+	defer r()
+	c.Check(policy.CheckFeatureRequirements(), IsNil)
+
+	r = quota.MockCgroupVer(1)
+	defer r()
+	c.Check(policy.CheckFeatureRequirements(), ErrorMatches, `cannot use limit policy with cgroup version 1`)
+
+	r = quota.MockCgroupVerErr(fmt.Errorf("some cgroup detection error"))
+	defer r()
+	c.Check(policy.CheckFeatureRequirements(), ErrorMatches, `some cgroup detection error`)
+}
@@ -24,10 +24,13 @@ package quota
 import (
 	"bytes"
 	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"time"
 
+	"github.com/snapcore/snapd/dirs"
 	// TODO: move this to snap/quantity? or similar
 	"github.com/snapcore/snapd/gadget/quantity"
 	"github.com/snapcore/snapd/progress"
@@ -81,6 +84,32 @@ type GroupQuotaJournal struct {
 	RatePeriod time.Duration `json:"rate-period,omitempty"`
 }
 
+// GroupQuotaIO contains the supported block I/O limits. The bandwidth and IOPS
+// limits are applied to the block device backing Device, and they are subject
+// to the same nesting rules as memory, the sum of the limits of the sub-groups
+// cannot exceed the limit of the parent group. The weight is relative to other
+// groups on the same level and is thus not subject to the nesting rules.
+type GroupQuotaIO struct {
+	// Device is a block device node, or a path residing on a file system of
+	// the block device the limits apply to. If empty, the limits apply to
+	// the device backing the snap data directory.
+	Device string `json:"device,omitempty"`
+
+	// ReadBandwidth and WriteBandwidth are the maximum bytes per second
+	// that can be read and written by the group. A value of 0 means no limit.
+	ReadBandwidth  quantity.Size `json:"read-bandwidth,omitempty"`
+	WriteBandwidth quantity.Size `json:"write-bandwidth,omitempty"`
+
+	// ReadIOPS and WriteIOPS are the maximum number of read and write I/O
+	// operations per second for the group. A value of 0 means no limit.
+	ReadIOPS  int `json:"read-iops,omitempty"`
+	WriteIOPS int `json:"write-iops,omitempty"`
+
+	// Weight is the I/O weight of the group in the range 1-10000, where the
+	// default used by systemd when unset is 100.
+	Weight int `json:"weight,omitempty"`
+}
+
 // Group is a quota group of snaps, services or sub-groups that are all subject
 // to specific resource quotas. The only quota resource types currently
 // supported is memory, but this can be expanded in the future.
@@ -122,6 +151,15 @@ type Group struct {
 	// journald.
 	JournalLimit *GroupQuotaJournal `json:"journal-limit,omitempty"`
 
+	// IOLimit is the block I/O limits that apply to the processes in the
+	// group. The limits require cgroup v2.
+	IOLimit *GroupQuotaIO `json:"io-limit,omitempty"`
+
+	// LimitPolicy is the action snapd takes when the group hits its memory
+	// or CPU limits. If unset, snapd does not react to the group hitting
+	// its limits.
+	LimitPolicy *LimitPolicy `json:"limit-policy,omitempty"`
+
 	// ParentGroup is the the parent group that this group is a child of. If it
 	// is empty, then this is a "root" quota group.
 	ParentGroup string `json:"parent-group,omitempty"`
@@ -184,6 +222,26 @@ func (grp *Group) GetQuotaResources() Resources {
 			resourcesBuilder.WithJournalRate(grp.JournalLimit.RateCount, grp.JournalLimit.RatePeriod)
 		}
 	}
+	if grp.IOLimit != nil {
+		if grp.IOLimit.Device != "" {
+			resourcesBuilder.WithIODevice(grp.IOLimit.Device)
+		}
+		if grp.IOLimit.ReadBandwidth != 0 {
+			resourcesBuilder.WithIOReadBandwidth(grp.IOLimit.ReadBandwidth)
+		}
+		if grp.IOLimit.WriteBandwidth != 0 {
+			resourcesBuilder.WithIOWriteBandwidth(grp.IOLimit.WriteBandwidth)
+		}
+		if grp.IOLimit.ReadIOPS != 0 {
+			resourcesBuilder.WithIOReadIOPS(grp.IOLimit.ReadIOPS)
+		}
+		if grp.IOLimit.WriteIOPS != 0 {
+			resourcesBuilder.WithIOWriteIOPS(grp.IOLimit.WriteIOPS)
+		}
+		if grp.IOLimit.Weight != 0 {
+			resourcesBuilder.WithIOWeight(grp.IOLimit.Weight)
+		}
+	}
 	return resourcesBuilder.Build()
 }
 
@@ -236,6 +294,61 @@ func (grp *Group) CurrentTaskUsage() (int, error) {
 	return int(count), nil
 }
 
+// CurrentCPUUsage returns the total CPU time consumed by the quota group. For
+// quota groups which do not yet have a backing systemd slice on the system (
+// i.e. quota groups without any snaps in them), the CPU usage is reported as
+// 0.
+func (grp *Group) CurrentCPUUsage() (time.Duration, error) {
+	sysd := systemd.New(systemd.SystemMode, progress.Null)
+
+	// check if this group is actually active, it could not physically exist yet
+	// since it has no snaps in it
+	isActive, err := sysd.IsActive(grp.SliceFileName())
+	if err != nil {
+		return 0, err
+	}
+	if !isActive {
+		return 0, nil
+	}
+
+	return sysd.CurrentCPUUsage(grp.SliceFileName())
+}
+
+// CurrentJournalUsage returns the disk space used by the journal namespace of
+// the quota group, including both the persistent and the volatile journal.
+// Quota groups without a journal quota have no journal namespace, and their
+// journal usage is reported as 0.
+func (grp *Group) CurrentJournalUsage() (quantity.Size, error) {
+	if grp.JournalLimit == nil {
+		return 0, nil
+	}
+
+	// journald stores the files of a namespace in a directory named
+	// <machine-id>.<namespace>
+	var usage quantity.Size
+	for _, journalDir := range []string{"/var/log/journal", "/run/log/journal"} {
+		nsDirs, err := filepath.Glob(filepath.Join(dirs.GlobalRootDir, journalDir, "*."+grp.JournalNamespaceName()))
+		if err != nil {
+			return 0, err
+		}
+		for _, nsDir := range nsDirs {
+			err := filepath.Walk(nsDir, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.Mode().IsRegular() {
+					usage += quantity.Size(info.Size())
+				}
+				return nil
+			})
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	return usage, nil
+}
+
 // SliceFileName returns the name of the slice file that should be used for this
 // quota group. This name will include all of the group's parents in the name.
 // For example, a group named "bar" that is a child of the "foo" group will have
@@ -266,6 +379,27 @@ func (grp *Group) SliceFileName() string {
 	return buf.String()
 }
 
+// CgroupPath returns the path of the control group of the systemd slice of
+// the quota group, relative to the root of the cgroup v2 hierarchy. Systemd
+// nests the slices following the dashes in their names, so a group named
+// "bar" that is a child of the "foo" group will have the path
+// "/snap.foo.slice/snap.foo-bar.slice".
+func (grp *Group) CgroupPath() string {
+	grps := []*Group{grp}
+	for parentGrp := grp.parentGroup; parentGrp != nil; parentGrp = parentGrp.parentGroup {
+		grps = append([]*Group{parentGrp}, grps...)
+	}
+
+	buf := &bytes.Buffer{}
+	prefix := "snap."
+	for _, g := range grps {
+		name := prefix + systemd.EscapeUnitNamePath(g.Name)
+		fmt.Fprintf(buf, "/%s.slice", name)
+		prefix = name + "-"
+	}
+	return buf.String()
+}
+
 // JournalNamespaceName returns the snap formatted name of the log namespace
 func (grp *Group) JournalNamespaceName() string {
 	return fmt.Sprintf("snap-%s", grp.Name)
@@ -307,6 +441,37 @@ type groupQuotaAllocations struct {
 
 	CPUSetLimit              []int
 	CPUSetReservedByChildren []int
+
+	// The I/O limits only nest for the same device, so the limits reserved
+	// by children are kept for each of the devices they apply to.
+	IODevice             string
+	IOLimit              ioAllocation
+	IOReservedByChildren map[string]*ioAllocation
+}
+
+// ioAllocation contains the I/O limits that are subject to the nesting rules.
+type ioAllocation struct {
+	ReadBandwidth  quantity.Size
+	WriteBandwidth quantity.Size
+	ReadIOPS       int
+	WriteIOPS      int
+}
+
+// ioLimitFor returns the I/O limits set by the group for the given device.
+func (a *groupQuotaAllocations) ioLimitFor(device string) *ioAllocation {
+	if a.IODevice != device {
+		return &ioAllocation{}
+	}
+	return &a.IOLimit
+}
+
+// ioReservedFor returns the I/O limits reserved by the children of the group
+// for the given device.
+func (a *groupQuotaAllocations) ioReservedFor(device string) *ioAllocation {
+	if reserved, ok := a.IOReservedByChildren[device]; ok {
+		return reserved
+	}
+	return &ioAllocation{}
 }
 
 func max(a, b int) int {
@@ -391,6 +556,10 @@ func (grp *Group) getQuotaAllocations(allQuotas map[string]*groupQuotaAllocation
 		ThreadsLimit: grp.ThreadLimit,
 		CPUSetLimit:  grp.GetLocalCPUSetQuota(),
 	}
+	if grp.IOLimit != nil {
+		limits.IODevice = grp.IOLimit.Device
+		limits.IOLimit = *ioLimitAllocation(grp.IOLimit)
+	}
 
 	// sliceUniqueAndSort sorts an array of ints in ascending order and removes duplicates
 	sliceUniqueAndSort := func(input []int) []int {
@@ -419,6 +588,35 @@ func (grp *Group) getQuotaAllocations(allQuotas map[string]*groupQuotaAllocation
 		limits.CPUReservedByChildren += max(subGroupLimits.CPULimit, subGroupLimits.CPUReservedByChildren)
 		limits.ThreadsReservedByChildren += max(subGroupLimits.ThreadsLimit, subGroupLimits.ThreadsReservedByChildren)
 
+		// The I/O limits are counted separately for each device the sub-group
+		// or its own sub-groups have limits for.
+		subGroupIODevices := map[string]bool{subGroupLimits.IODevice: true}
+		for device := range subGroupLimits.IOReservedByChildren {
+			subGroupIODevices[device] = true
+		}
+		for device := range subGroupIODevices {
+			subLimit := subGroupLimits.ioLimitFor(device)
+			subReserved := subGroupLimits.ioReservedFor(device)
+			usage := ioAllocation{
+				ReadBandwidth:  maxq(subLimit.ReadBandwidth, subReserved.ReadBandwidth),
+				WriteBandwidth: maxq(subLimit.WriteBandwidth, subReserved.WriteBandwidth),
+				ReadIOPS:       max(subLimit.ReadIOPS, subReserved.ReadIOPS),
+				WriteIOPS:      max(subLimit.WriteIOPS, subReserved.WriteIOPS),
+			}
+			if usage == (ioAllocation{}) {
+				continue
+			}
+			if limits.IOReservedByChildren == nil {
+				limits.IOReservedByChildren = make(map[string]*ioAllocation)
+			}
+			reserved := limits.ioReservedFor(device)
+			reserved.ReadBandwidth += usage.ReadBandwidth
+			reserved.WriteBandwidth += usage.WriteBandwidth
+			reserved.ReadIOPS += usage.ReadIOPS
+			reserved.WriteIOPS += usage.WriteIOPS
+			limits.IOReservedByChildren[device] = reserved
+		}
+
 		// We need to merge the allowed CPUs lists, but we need to make sure that the list is unique, since cpu cores
 		// can be reused between sub-groups.
 		if len(subGroupLimits.CPUSetLimit) > 0 {
@@ -656,6 +854,140 @@ func (grp *Group) validateThreadResourceFit(allQuotas map[string]*groupQuotaAllo
 	return nil
 }
 
+// ioQuotaAllocation describes one of the nested I/O limits in an
+// ioAllocation, all values are converted to uint64 so the bandwidth
+// and IOPS limits can share the same validation code.
+type ioQuotaAllocation struct {
+	name  string
+	unit  string
+	value func(*ioAllocation) uint64
+}
+
+var ioQuotaAllocations = []ioQuotaAllocation{
+	{
+		name:  "read bandwidth",
+		unit:  "B/s",
+		value: func(a *ioAllocation) uint64 { return uint64(a.ReadBandwidth) },
+	},
+	{
+		name:  "write bandwidth",
+		unit:  "B/s",
+		value: func(a *ioAllocation) uint64 { return uint64(a.WriteBandwidth) },
+	},
+	{
+		name:  "read iops",
+		unit:  "",
+		value: func(a *ioAllocation) uint64 { return uint64(a.ReadIOPS) },
+	},
+	{
+		name:  "write iops",
+		unit:  "",
+		value: func(a *ioAllocation) uint64 { return uint64(a.WriteIOPS) },
+	},
+}
+
+// ioLimitAllocation returns the nested I/O limits of the given I/O quota.
+func ioLimitAllocation(ioLimit *GroupQuotaIO) *ioAllocation {
+	if ioLimit == nil {
+		return &ioAllocation{}
+	}
+	return &ioAllocation{
+		ReadBandwidth:  ioLimit.ReadBandwidth,
+		WriteBandwidth: ioLimit.WriteBandwidth,
+		ReadIOPS:       ioLimit.ReadIOPS,
+		WriteIOPS:      ioLimit.WriteIOPS,
+	}
+}
+
+// validateIOResourceFit verifies that the new I/O limits don't conflict with the current reserved I/O
+// limits of the group, and if not locates for each limit the nearest parent group that has a matching
+// quota for the same device, and then verifies if that group has any space available. This follows
+// the same logic as validateMemoryResourceFit, but is done for each of the bandwidth and IOPS limits.
+// Limits set for different devices are independent of each other, the device is compared as given
+// by the groups, with an empty device meaning the device backing the snap data directory.
+func (grp *Group) validateIOResourceFit(allQuotas map[string]*groupQuotaAllocations, ioLimit *GroupQuotaIO) error {
+	if ioLimit == nil {
+		return nil
+	}
+	device := ioLimit.Device
+	requested := ioLimitAllocation(ioLimit)
+	local := &ioAllocation{}
+	if grp.IOLimit != nil && grp.IOLimit.Device == device {
+		local = ioLimitAllocation(grp.IOLimit)
+	}
+
+	for _, alloc := range ioQuotaAllocations {
+		newLimit := alloc.value(requested)
+		if newLimit == 0 {
+			continue
+		}
+
+		// make sure current usage does not exceed the new limit, we can avoid any
+		// recursive descent as we already have counted up the usage of our children.
+		currentLimits := allQuotas[grp.Name]
+		reserved := alloc.value(local)
+		if currentLimits != nil {
+			childrenReserved := alloc.value(currentLimits.ioReservedFor(device))
+			if childrenReserved > newLimit {
+				return fmt.Errorf("group io %s limit of %d%s is too small to fit current subgroup usage of %d%s",
+					alloc.name, newLimit, alloc.unit, childrenReserved, alloc.unit)
+			}
+
+			// if we are reducing the limit, then we don't need to check upper parents,
+			// as we can assume it will fit by this point
+			if newLimit < alloc.value(local) {
+				continue
+			}
+
+			if childrenReserved > reserved {
+				reserved = childrenReserved
+			}
+		}
+
+		// now we check parents up the tree to make sure we also fit with any
+		// previous usage limits of our parents for the same device.
+		parent := grp.parentGroup
+		for parent != nil {
+			limits := allQuotas[parent.Name]
+			if limits != nil && alloc.value(limits.ioLimitFor(device)) != 0 {
+				// We need to take into account that we might have a matching limit in this group, and
+				// thus we account for some of the reserved quota. So subtract that.
+				parentLimit := alloc.value(limits.ioLimitFor(device))
+				available := parentLimit - (alloc.value(limits.ioReservedFor(device)) - reserved)
+				if newLimit > available {
+					return fmt.Errorf("sub-group io %s limit of %d%s is too large to fit inside group %q remaining quota space %d%s",
+						alloc.name, newLimit, alloc.unit, parent.Name, available, alloc.unit)
+				}
+				break
+			}
+			parent = parent.parentGroup
+		}
+	}
+	return nil
+}
+
+// resultingIOLimit returns the I/O limits of the group that result from
+// applying the given changes to the current limits, or nil if no I/O limit
+// remains.
+func (grp *Group) resultingIOLimit(ioLimits *ResourceIO) *GroupQuotaIO {
+	res := &ResourceIO{}
+	if grp.IOLimit != nil {
+		res = grp.GetQuotaResources().IO
+	}
+	res.merge(ioLimits)
+	if !res.hasLimit() {
+		return nil
+	}
+	return &GroupQuotaIO{
+		Device:         res.Device,
+		ReadBandwidth:  res.ReadBandwidth,
+		WriteBandwidth: res.WriteBandwidth,
+		ReadIOPS:       res.ReadIOPS,
+		WriteIOPS:      res.WriteIOPS,
+		Weight:         res.Weight,
+	}
+}
+
 // validateQuotasFit verifies that the given group's current limits fits correctly
 // into the group's parent group's limits. This is done in multiple steps, where the first
 // one is to get a statistics for the upper-most parent group, to get a combined overview
@@ -692,6 +1024,11 @@ func (grp *Group) validateQuotasFit(resourceLimits Resources) error {
 			return err
 		}
 	}
+	if resourceLimits.IO != nil {
+		if err := grp.validateIOResourceFit(allQuotas, grp.resultingIOLimit(resourceLimits.IO)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -740,6 +1077,9 @@ func (grp *Group) UpdateQuotaLimits(resourceLimits Resources) error {
 			grp.JournalLimit.RatePeriod = resourceLimits.Journal.Rate.Period
 		}
 	}
+	if resourceLimits.IO != nil {
+		grp.IOLimit = grp.resultingIOLimit(resourceLimits.IO)
+	}
 	return nil
 }
 
@@ -759,6 +1099,11 @@ func (grp *Group) validate() error {
 	if err := limits.Validate(); err != nil {
 		return err
 	}
+	if grp.LimitPolicy != nil {
+		if err := grp.LimitPolicy.Validate(limits); err != nil {
+			return err
+		}
+	}
 
 	if grp.ParentGroup != "" && grp.Name == grp.ParentGroup {
 		return fmt.Errorf("group has circular parent reference to itself")
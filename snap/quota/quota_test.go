@@ -21,12 +21,16 @@ package quota_test
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/gadget/quantity"
 	"github.com/snapcore/snapd/snap/quota"
 	"github.com/snapcore/snapd/systemd"
@@ -310,6 +314,31 @@ func (ts *quotaTestSuite) TestGroupUnmixableSnapsSubgroups(c *C) {
 	c.Assert(err, ErrorMatches, "cannot mix sub groups with snaps in the same group")
 }
 
+func (ts *quotaTestSuite) TestCgroupPath(c *C) {
+	rootGrp, err := quota.NewGroup("myroot", quota.NewResourcesBuilder().WithMemoryLimit(2*quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(rootGrp.CgroupPath(), Equals, "/snap.myroot.slice")
+
+	sub1, err := rootGrp.NewSubGroup("sub1", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(sub1.CgroupPath(), Equals, "/snap.myroot.slice/snap.myroot-sub1.slice")
+
+	subsub1, err := sub1.NewSubGroup("sub-sub1", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(subsub1.CgroupPath(), Equals, `/snap.myroot.slice/snap.myroot-sub1.slice/snap.myroot-sub1-sub\x2dsub1.slice`)
+}
+
+func (ts *quotaTestSuite) TestGroupValidateLimitPolicy(c *C) {
+	grp, err := quota.NewGroup("foo", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+
+	grp.LimitPolicy = &quota.LimitPolicy{Action: quota.LimitActionRestart}
+	c.Check(grp.ValidateGroup(), IsNil)
+
+	grp.LimitPolicy = &quota.LimitPolicy{Action: quota.LimitActionRaise, MemoryCeiling: quantity.SizeKiB}
+	c.Check(grp.ValidateGroup(), ErrorMatches, `memory ceiling 1 KiB is smaller than the memory limit 1 MiB`)
+}
+
 func (ts *quotaTestSuite) TestJournalNamespaceName(c *C) {
 	grp, err := quota.NewGroup("foo", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build())
 	c.Assert(err, IsNil)
@@ -864,6 +893,84 @@ func (ts *quotaTestSuite) TestCurrentTaskUsage(c *C) {
 	c.Check(systemctlCalls, Equals, 5)
 }
 
+func (ts *quotaTestSuite) TestCurrentCPUUsage(c *C) {
+	systemctlCalls := 0
+	r := systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		systemctlCalls++
+		switch systemctlCalls {
+
+		// inactive case, cpu usage must be 0
+		case 1:
+			c.Assert(args, DeepEquals, []string{"is-active", "snap.group.slice"})
+			return []byte("inactive"), systemctlInactiveServiceError{}
+
+		// active case
+		case 2:
+			c.Assert(args, DeepEquals, []string{"is-active", "snap.group.slice"})
+			return []byte("active"), nil
+		case 3:
+			c.Assert(args, DeepEquals, []string{"show", "--property", "CPUUsageNSec", "snap.group.slice"})
+			return []byte("CPUUsageNSec=2000000000"), nil
+
+		default:
+			c.Errorf("unexpected number of systemctl calls (%d) (current call is %+v)", systemctlCalls, args)
+			return []byte("broken test"), fmt.Errorf("broken test")
+		}
+	})
+	defer r()
+
+	grp1, err := quota.NewGroup("group", quota.NewResourcesBuilder().WithCPUPercentage(50).Build())
+	c.Assert(err, IsNil)
+
+	cpuUsage, err := grp1.CurrentCPUUsage()
+	c.Check(err, IsNil)
+	c.Check(cpuUsage, Equals, time.Duration(0))
+	c.Check(systemctlCalls, Equals, 1)
+
+	cpuUsage, err = grp1.CurrentCPUUsage()
+	c.Check(err, IsNil)
+	c.Check(cpuUsage, Equals, 2*time.Second)
+	c.Check(systemctlCalls, Equals, 3)
+}
+
+func (ts *quotaTestSuite) TestCurrentJournalUsage(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	grp1, err := quota.NewGroup("group", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+
+	// without a journal quota there is no namespace
+	usage, err := grp1.CurrentJournalUsage()
+	c.Check(err, IsNil)
+	c.Check(usage, Equals, quantity.Size(0))
+
+	grp2, err := quota.NewGroup("group2", quota.NewResourcesBuilder().WithJournalNamespace().Build())
+	c.Assert(err, IsNil)
+
+	usage, err = grp2.CurrentJournalUsage()
+	c.Check(err, IsNil)
+	c.Check(usage, Equals, quantity.Size(0))
+
+	// both persistent and volatile journal files are accounted for, but
+	// other namespaces are not
+	for path, size := range map[string]int{
+		"/var/log/journal/1234.snap-group2/system.journal":       100,
+		"/var/log/journal/1234.snap-group2/system@1.journal":     50,
+		"/run/log/journal/1234.snap-group2/system.journal":       25,
+		"/var/log/journal/1234.snap-group/system.journal":        1000,
+		"/var/log/journal/1234.snap-group2-other/system.journal": 1000,
+	} {
+		fullPath := filepath.Join(dirs.GlobalRootDir, path)
+		c.Assert(os.MkdirAll(filepath.Dir(fullPath), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(fullPath, make([]byte, size), 0644), IsNil)
+	}
+
+	usage, err = grp2.CurrentJournalUsage()
+	c.Check(err, IsNil)
+	c.Check(usage, Equals, quantity.Size(175))
+}
+
 func (ts *quotaTestSuite) TestGetGroupQuotaAllocations(c *C) {
 	// Verify we get the correct allocations for a group with a more complex tree-structure
 	// and different quotas split out into different sub-groups.
@@ -1134,6 +1241,82 @@ func (ts *quotaTestSuite) TestChangingParentThreadLimits(c *C) {
 	c.Check(err, ErrorMatches, `group thread limit of 16 is too small to fit current subgroup usage of 32`)
 }
 
+func (ts *quotaTestSuite) TestIOLimitsSubGroups(c *C) {
+	grp1, err := quota.NewGroup("groot", quota.NewResourcesBuilder().WithIOReadBandwidth(10*quantity.SizeMiB).WithIOWriteIOPS(100).Build())
+	c.Assert(err, IsNil)
+
+	// a sub-group can take a part of the parent limits
+	subgrp1, err := grp1.NewSubGroup("io-sub1", quota.NewResourcesBuilder().WithIOReadBandwidth(6*quantity.SizeMiB).WithIOWriteIOPS(50).Build())
+	c.Assert(err, IsNil)
+
+	// but the sum of the sub-groups cannot exceed the parent limit
+	_, err = grp1.NewSubGroup("io-sub2", quota.NewResourcesBuilder().WithIOReadBandwidth(6*quantity.SizeMiB).Build())
+	c.Check(err, ErrorMatches, `sub-group io read bandwidth limit of 6291456B/s is too large to fit inside group "groot" remaining quota space 4194304B/s`)
+	_, err = grp1.NewSubGroup("io-sub2", quota.NewResourcesBuilder().WithIOWriteIOPS(51).Build())
+	c.Check(err, ErrorMatches, `sub-group io write iops limit of 51 is too large to fit inside group "groot" remaining quota space 50`)
+
+	// limits which the parent does not have are not restricted, and the
+	// weight is never restricted
+	_, err = grp1.NewSubGroup("io-sub2", quota.NewResourcesBuilder().WithIOWriteBandwidth(quantity.SizeGiB).WithIOWeight(1000).Build())
+	c.Check(err, IsNil)
+
+	// the parent limit cannot be lowered below the usage of the sub-groups
+	err = grp1.QuotaUpdateCheck(quota.NewResourcesBuilder().WithIOReadBandwidth(5 * quantity.SizeMiB).Build())
+	c.Check(err, ErrorMatches, `group io read bandwidth limit of 5242880B/s is too small to fit current subgroup usage of 6291456B/s`)
+
+	// raising a sub-group limit takes its current limit into account
+	err = subgrp1.UpdateQuotaLimits(quota.NewResourcesBuilder().WithIOReadBandwidth(10 * quantity.SizeMiB).Build())
+	c.Check(err, IsNil)
+	c.Check(subgrp1.IOLimit, DeepEquals, &quota.GroupQuotaIO{
+		ReadBandwidth: 10 * quantity.SizeMiB,
+		WriteIOPS:     50,
+	})
+}
+
+func (ts *quotaTestSuite) TestIOLimitsSubGroupsDifferentDevices(c *C) {
+	grp1, err := quota.NewGroup("groot", quota.NewResourcesBuilder().WithIODevice("/dev/sda").WithIOReadBandwidth(10*quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+
+	// limits for another device are not restricted by the parent
+	subgrp1, err := grp1.NewSubGroup("io-sub1", quota.NewResourcesBuilder().WithIODevice("/dev/sdb").WithIOReadBandwidth(20*quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	_, err = grp1.NewSubGroup("io-sub2", quota.NewResourcesBuilder().WithIOReadBandwidth(20*quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+
+	// and do not count towards the usage of the parent device
+	subgrp3, err := grp1.NewSubGroup("io-sub3", quota.NewResourcesBuilder().WithIODevice("/dev/sda").WithIOReadBandwidth(10*quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	err = grp1.QuotaUpdateCheck(quota.NewResourcesBuilder().WithIOReadBandwidth(10 * quantity.SizeMiB).Build())
+	c.Check(err, IsNil)
+
+	// but moving a sub-group to the device of the parent does
+	err = subgrp1.UpdateQuotaLimits(quota.NewResourcesBuilder().WithIODevice("/dev/sda").Build())
+	c.Check(err, ErrorMatches, `sub-group io read bandwidth limit of 20971520B/s is too large to fit inside group "groot" remaining quota space 0B/s`)
+
+	// the usage of nested sub-groups is counted for their own device
+	_, err = subgrp3.NewSubGroup("io-sub3-sub", quota.NewResourcesBuilder().WithIODevice("/dev/sdb").WithIOReadBandwidth(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	err = grp1.QuotaUpdateCheck(quota.NewResourcesBuilder().WithIODevice("/dev/sdb").Build())
+	c.Check(err, ErrorMatches, `group io read bandwidth limit of 10485760B/s is too small to fit current subgroup usage of 22020096B/s`)
+}
+
+func (ts *quotaTestSuite) TestIOLimitsUnset(c *C) {
+	grp1, err := quota.NewGroup("groot", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).WithIODevice("/dev/sda").WithIOReadBandwidth(10*quantity.SizeMiB).WithIOWeight(100).Build())
+	c.Assert(err, IsNil)
+
+	err = grp1.UpdateQuotaLimits(quota.NewResourcesBuilder().WithIOUnset("device", "weight").Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.IOLimit, DeepEquals, &quota.GroupQuotaIO{
+		ReadBandwidth: 10 * quantity.SizeMiB,
+	})
+
+	// removing the last io limit removes the io quota
+	err = grp1.UpdateQuotaLimits(quota.NewResourcesBuilder().WithIOUnset("read-bandwidth").Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.IOLimit, IsNil)
+	c.Check(grp1.GetQuotaResources().IO, IsNil)
+}
+
 func (ts *quotaTestSuite) TestChangingMiddleParentLimits(c *C) {
 	// Catch any algorithmic mistakes made in regards to not catching parents
 	// that are also children of other parents.
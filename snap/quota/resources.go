@@ -21,10 +21,12 @@ package quota
 
 import (
 	"fmt"
+	"path/filepath"
 	"time"
 
 	"github.com/snapcore/snapd/gadget/quantity"
 	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/strutil"
 )
 
 var (
@@ -75,6 +77,93 @@ type ResourceJournal struct {
 	Rate *ResourceJournalRate `json:"rate,omitempty"`
 }
 
+// ResourceIO represents the block I/O quotas. The bandwidth and IOPS limits
+// apply to the block device backing Device, which may be either a device node
+// or any path on a file system residing on the device.
+type ResourceIO struct {
+	Device string `json:"device,omitempty"`
+	// ReadBandwidth and WriteBandwidth are expressed in bytes per second.
+	ReadBandwidth  quantity.Size `json:"read-bandwidth,omitempty"`
+	WriteBandwidth quantity.Size `json:"write-bandwidth,omitempty"`
+	ReadIOPS       int           `json:"read-iops,omitempty"`
+	WriteIOPS      int           `json:"write-iops,omitempty"`
+	// Weight is the relative share of the I/O bandwidth compared to other
+	// groups, in the range 1-10000.
+	Weight int `json:"weight,omitempty"`
+	// Unset lists the limits to remove when changing the limits of an
+	// existing group, using the names of the JSON fields above. It is only
+	// valid as part of a change and is never part of the resulting limits.
+	Unset []string `json:"unset,omitempty"`
+}
+
+// ioLimitNames are the names of the I/O limits that can be unset.
+var ioLimitNames = []string{"device", "read-bandwidth", "write-bandwidth", "read-iops", "write-iops", "weight"}
+
+// isSet returns whether the named limit has a value.
+func (io *ResourceIO) isSet(name string) bool {
+	switch name {
+	case "device":
+		return io.Device != ""
+	case "read-bandwidth":
+		return io.ReadBandwidth != 0
+	case "write-bandwidth":
+		return io.WriteBandwidth != 0
+	case "read-iops":
+		return io.ReadIOPS != 0
+	case "write-iops":
+		return io.WriteIOPS != 0
+	case "weight":
+		return io.Weight != 0
+	}
+	return false
+}
+
+// hasLimit returns whether any of the I/O limits are set, the device on its
+// own is not a limit.
+func (io *ResourceIO) hasLimit() bool {
+	return io.ReadBandwidth != 0 || io.WriteBandwidth != 0 || io.ReadIOPS != 0 || io.WriteIOPS != 0 || io.Weight != 0
+}
+
+// merge updates the limits with each of the limits set in other, and removes
+// the limits listed in other.Unset. Limits which are neither set nor unset in
+// other are left untouched.
+func (io *ResourceIO) merge(other *ResourceIO) {
+	for _, name := range other.Unset {
+		switch name {
+		case "device":
+			io.Device = ""
+		case "read-bandwidth":
+			io.ReadBandwidth = 0
+		case "write-bandwidth":
+			io.WriteBandwidth = 0
+		case "read-iops":
+			io.ReadIOPS = 0
+		case "write-iops":
+			io.WriteIOPS = 0
+		case "weight":
+			io.Weight = 0
+		}
+	}
+	if other.Device != "" {
+		io.Device = other.Device
+	}
+	if other.ReadBandwidth != 0 {
+		io.ReadBandwidth = other.ReadBandwidth
+	}
+	if other.WriteBandwidth != 0 {
+		io.WriteBandwidth = other.WriteBandwidth
+	}
+	if other.ReadIOPS != 0 {
+		io.ReadIOPS = other.ReadIOPS
+	}
+	if other.WriteIOPS != 0 {
+		io.WriteIOPS = other.WriteIOPS
+	}
+	if other.Weight != 0 {
+		io.Weight = other.Weight
+	}
+}
+
 // Resources are built up of multiple quota limits. Each quota limit is a pointer
 // value to indicate that their presence may be optional, and because we want to detect
 // whenever someone changes a limit to '0' explicitly.
@@ -84,6 +173,7 @@ type Resources struct {
 	CPUSet  *ResourceCPUSet  `json:"cpu-set,omitempty"`
 	Threads *ResourceThreads `json:"thread,omitempty"`
 	Journal *ResourceJournal `json:"journal,omitempty"`
+	IO      *ResourceIO      `json:"io,omitempty"`
 }
 
 const (
@@ -99,6 +189,10 @@ const (
 	// usage, but we have selected 64kB to protect against ridiculously small values.
 	journalLimitMin = 64 * quantity.SizeKiB
 	journalLimitMax = 4 * quantity.SizeGiB
+
+	// The range of the I/O weight accepted by systemd for IOWeight=.
+	ioWeightMin = 1
+	ioWeightMax = 10000
 )
 
 func (qr *Resources) validateMemoryQuota() error {
@@ -186,6 +280,26 @@ func (qr *Resources) validateJournalQuota() error {
 	return nil
 }
 
+func (qr *Resources) validateIOQuota() error {
+	io := qr.IO
+	if len(io.Unset) != 0 {
+		return fmt.Errorf("cannot unset io limits of a new quota group")
+	}
+	if !io.hasLimit() {
+		return fmt.Errorf("io quota must have at least one limit set")
+	}
+	if io.Device != "" && !filepath.IsAbs(io.Device) {
+		return fmt.Errorf("io quota device %q must be an absolute path", io.Device)
+	}
+	if io.ReadIOPS < 0 || io.WriteIOPS < 0 {
+		return fmt.Errorf("invalid io quota with negative iops limit")
+	}
+	if io.Weight != 0 && (io.Weight < ioWeightMin || io.Weight > ioWeightMax) {
+		return fmt.Errorf("invalid io quota weight %d: weight must be between %d and %d", io.Weight, ioWeightMin, ioWeightMax)
+	}
+	return nil
+}
+
 // CheckFeatureRequirements checks if the current system meets the
 // requirements for the given resource request.
 //
@@ -202,6 +316,14 @@ func (qr *Resources) CheckFeatureRequirements() error {
 	if qr.Memory != nil && cgroupCheckMemoryCgroupErr != nil {
 		return fmt.Errorf("cannot use memory quota: %v", cgroupCheckMemoryCgroupErr)
 	}
+	if qr.IO != nil {
+		if cgroupVerErr != nil {
+			return cgroupVerErr
+		}
+		if cgroupVer < 2 {
+			return fmt.Errorf("cannot use io quota with cgroup version %d", cgroupVer)
+		}
+	}
 
 	return nil
 }
@@ -251,6 +373,12 @@ func (qr *Resources) Validate() error {
 			return err
 		}
 	}
+
+	if qr.IO != nil {
+		if err := qr.validateIOQuota(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -347,6 +475,18 @@ func (qr *Resources) ValidateChange(newLimits Resources) error {
 		// rate-limit for the group, overriding the journal default which is 10000/30s
 	}
 
+	// Verify that the io limits being unset are known and not set at the same time
+	if newLimits.IO != nil {
+		for _, name := range newLimits.IO.Unset {
+			if !strutil.ListContains(ioLimitNames, name) {
+				return fmt.Errorf("cannot unset unknown io limit %q", name)
+			}
+			if newLimits.IO.isSet(name) {
+				return fmt.Errorf("cannot both set and unset io limit %q", name)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -374,6 +514,11 @@ func (qr *Resources) clone() Resources {
 			resourcesCopy.Journal.Rate = &ResourceJournalRate{Count: qr.Journal.Rate.Count, Period: qr.Journal.Rate.Period}
 		}
 	}
+	if qr.IO != nil {
+		ioCopy := *qr.IO
+		ioCopy.Unset = append([]string(nil), qr.IO.Unset...)
+		resourcesCopy.IO = &ioCopy
+	}
 	return resourcesCopy
 }
 
@@ -402,6 +547,16 @@ func (qr *Resources) changeInternal(newLimits Resources) {
 			qr.Journal.Rate = newLimits.Journal.Rate
 		}
 	}
+	if newLimits.IO != nil {
+		if qr.IO == nil {
+			qr.IO = &ResourceIO{}
+		}
+		qr.IO.merge(newLimits.IO)
+		if !qr.IO.hasLimit() {
+			// removing all the limits removes the io quota entirely
+			qr.IO = nil
+		}
+	}
 }
 
 // Change updates the current quota limits with the new limits. Additional verification
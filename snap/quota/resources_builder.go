@@ -49,6 +49,26 @@ type ResourcesBuilder struct {
 	JournalRateCountLimit  int
 	JournalRatePeriodLimit time.Duration
 	JournalRateSet         bool
+
+	IODevice    string
+	IODeviceSet bool
+
+	IOReadBandwidth    quantity.Size
+	IOReadBandwidthSet bool
+
+	IOWriteBandwidth    quantity.Size
+	IOWriteBandwidthSet bool
+
+	IOReadIOPS    int
+	IOReadIOPSSet bool
+
+	IOWriteIOPS    int
+	IOWriteIOPSSet bool
+
+	IOWeight    int
+	IOWeightSet bool
+
+	IOUnset []string
 }
 
 func (rb *ResourcesBuilder) WithMemoryLimit(limit quantity.Size) *ResourcesBuilder {
@@ -99,6 +119,49 @@ func (rb *ResourcesBuilder) WithJournalRate(count int, period time.Duration) *Re
 	return rb
 }
 
+func (rb *ResourcesBuilder) WithIODevice(device string) *ResourcesBuilder {
+	rb.IODevice = device
+	rb.IODeviceSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithIOReadBandwidth(limit quantity.Size) *ResourcesBuilder {
+	rb.IOReadBandwidth = limit
+	rb.IOReadBandwidthSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithIOWriteBandwidth(limit quantity.Size) *ResourcesBuilder {
+	rb.IOWriteBandwidth = limit
+	rb.IOWriteBandwidthSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithIOReadIOPS(limit int) *ResourcesBuilder {
+	rb.IOReadIOPS = limit
+	rb.IOReadIOPSSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithIOWriteIOPS(limit int) *ResourcesBuilder {
+	rb.IOWriteIOPS = limit
+	rb.IOWriteIOPSSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithIOWeight(weight int) *ResourcesBuilder {
+	rb.IOWeight = weight
+	rb.IOWeightSet = true
+	return rb
+}
+
+// WithIOUnset removes the named io limits when changing the limits of an
+// existing group.
+func (rb *ResourcesBuilder) WithIOUnset(names ...string) *ResourcesBuilder {
+	rb.IOUnset = append(rb.IOUnset, names...)
+	return rb
+}
+
 func (rb *ResourcesBuilder) Build() Resources {
 	var quotaResources Resources
 	if rb.MemoryLimitSet {
@@ -136,6 +199,18 @@ func (rb *ResourcesBuilder) Build() Resources {
 			}
 		}
 	}
+	if rb.IODeviceSet || rb.IOReadBandwidthSet || rb.IOWriteBandwidthSet ||
+		rb.IOReadIOPSSet || rb.IOWriteIOPSSet || rb.IOWeightSet || len(rb.IOUnset) != 0 {
+		quotaResources.IO = &ResourceIO{
+			Device:         rb.IODevice,
+			ReadBandwidth:  rb.IOReadBandwidth,
+			WriteBandwidth: rb.IOWriteBandwidth,
+			ReadIOPS:       rb.IOReadIOPS,
+			WriteIOPS:      rb.IOWriteIOPS,
+			Weight:         rb.IOWeight,
+			Unset:          rb.IOUnset,
+		}
+	}
 	return quotaResources
 }
 
@@ -50,6 +50,10 @@ func (s *resourcesTestSuite) TestQuotaValidationFails(c *C) {
 		{quota.NewResourcesBuilder().WithJournalRate(0, 1).Build(), `journal quota must have a period of at least 1 microsecond \(minimum resolution\)`},
 		{quota.NewResourcesBuilder().WithJournalRate(1, time.Nanosecond).Build(), `journal quota must have a period of at least 1 microsecond \(minimum resolution\)`},
 		{quota.NewResourcesBuilder().WithJournalSize(0).Build(), `journal size quota must have a limit set`},
+		{quota.NewResourcesBuilder().WithIODevice("/dev/sda").Build(), `io quota must have at least one limit set`},
+		{quota.NewResourcesBuilder().WithIODevice("dev/sda").WithIOWeight(100).Build(), `io quota device "dev/sda" must be an absolute path`},
+		{quota.NewResourcesBuilder().WithIOReadIOPS(-1).Build(), `invalid io quota with negative iops limit`},
+		{quota.NewResourcesBuilder().WithIOWeight(10001).Build(), `invalid io quota weight 10001: weight must be between 1 and 10000`},
 	}
 
 	for _, t := range tests {
@@ -69,6 +73,10 @@ func (s *resourcesTestSuite) TestResourceCheckFeatureRequirementsCgroupv1(c *C)
 	// cpu set with cgroup v1 is not supported
 	bad := quota.NewResourcesBuilder().WithCPUSet([]int{0, 1}).Build()
 	c.Check(bad.CheckFeatureRequirements(), ErrorMatches, "cannot use CPU set with cgroup version 1")
+
+	// io limits with cgroup v1 are not supported
+	bad = quota.NewResourcesBuilder().WithIOReadBandwidth(quantity.SizeMiB).Build()
+	c.Check(bad.CheckFeatureRequirements(), ErrorMatches, "cannot use io quota with cgroup version 1")
 }
 
 func (s *resourcesTestSuite) TestResourceCheckFeatureRequirementsCgroupv1Err(c *C) {
@@ -95,6 +103,8 @@ func (s *resourcesTestSuite) TestQuotaValidationPasses(c *C) {
 		{quota.NewResourcesBuilder().WithJournalSize(quantity.SizeMiB).Build()},
 		{quota.NewResourcesBuilder().WithJournalRate(1, time.Microsecond).Build()},
 		{quota.NewResourcesBuilder().WithJournalNamespace().Build()},
+		{quota.NewResourcesBuilder().WithIOReadBandwidth(quantity.SizeMiB).Build()},
+		{quota.NewResourcesBuilder().WithIODevice("/dev/mmcblk0").WithIOWriteIOPS(100).WithIOWeight(50).Build()},
 	}
 
 	for _, t := range tests {
@@ -337,6 +347,49 @@ func (s *resourcesTestSuite) TestResourceCloneComplete(c *C) {
 	}
 }
 
+func (s *resourcesTestSuite) TestResourceChangeMergesIOLimits(c *C) {
+	limits := quota.NewResourcesBuilder().WithIODevice("/dev/sda").WithIOReadBandwidth(quantity.SizeMiB).WithIOWeight(100).Build()
+	err := limits.Change(quota.NewResourcesBuilder().WithIOWriteIOPS(50).WithIOWeight(200).Build())
+	c.Assert(err, IsNil)
+	c.Check(limits.IO, DeepEquals, &quota.ResourceIO{
+		Device:        "/dev/sda",
+		ReadBandwidth: quantity.SizeMiB,
+		WriteIOPS:     50,
+		Weight:        200,
+	})
+}
+
+func (s *resourcesTestSuite) TestResourceChangeUnsetsIOLimits(c *C) {
+	limits := quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).WithIODevice("/dev/sda").WithIOReadBandwidth(quantity.SizeMiB).WithIOWeight(100).Build()
+	err := limits.Change(quota.NewResourcesBuilder().WithIOWriteIOPS(50).WithIOUnset("device", "read-bandwidth").Build())
+	c.Assert(err, IsNil)
+	c.Check(limits.IO, DeepEquals, &quota.ResourceIO{
+		WriteIOPS: 50,
+		Weight:    100,
+	})
+
+	// removing all io limits removes the io quota
+	err = limits.Change(quota.NewResourcesBuilder().WithIOUnset("write-iops", "weight").Build())
+	c.Assert(err, IsNil)
+	c.Check(limits.IO, IsNil)
+}
+
+func (s *resourcesTestSuite) TestResourceChangeUnsetIOLimitsErrors(c *C) {
+	limits := quota.NewResourcesBuilder().WithIOReadBandwidth(quantity.SizeMiB).Build()
+
+	err := limits.Change(quota.NewResourcesBuilder().WithIOUnset("foo").Build())
+	c.Check(err, ErrorMatches, `cannot unset unknown io limit "foo"`)
+	err = limits.Change(quota.NewResourcesBuilder().WithIOWeight(10).WithIOUnset("weight").Build())
+	c.Check(err, ErrorMatches, `cannot both set and unset io limit "weight"`)
+	// the group must keep at least one limit
+	err = limits.Change(quota.NewResourcesBuilder().WithIOUnset("read-bandwidth").Build())
+	c.Check(err, ErrorMatches, `quota group must have at least one resource limit set`)
+
+	// unsetting is only meaningful for existing groups
+	bad := quota.NewResourcesBuilder().WithIOReadBandwidth(quantity.SizeMiB).WithIOUnset("weight").Build()
+	c.Check(bad.Validate(), ErrorMatches, `cannot unset io limits of a new quota group`)
+}
+
 func (s *resourcesTestSuite) TestResourceBuilerWithJournalNamespaceOnly(c *C) {
 	r := quota.NewResourcesBuilder().WithJournalNamespace().Build()
 	c.Assert(r.Journal, NotNil)
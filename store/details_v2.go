@@ -295,7 +295,7 @@ func infoFromStoreSnap(d *storeSnap) (*snap.Info, error) {
 	info.Website = d.Website
 	info.StoreURL = d.StoreURL
 
-	// fill in the plug/slot data etc
+	// fill in the plug/slot, app and hook data etc
 	if rawYamlInfo, err := snap.InfoFromSnapYaml([]byte(d.SnapYAML)); err == nil {
 		if info.Plugs == nil {
 			info.Plugs = make(map[string]*snap.PlugInfo)
@@ -311,6 +311,20 @@ func infoFromStoreSnap(d *storeSnap) (*snap.Info, error) {
 			info.Slots[k] = v
 			info.Slots[k].Snap = info
 		}
+		for k, v := range rawYamlInfo.Apps {
+			if info.Apps == nil {
+				info.Apps = make(map[string]*snap.AppInfo)
+			}
+			info.Apps[k] = v
+			info.Apps[k].Snap = info
+		}
+		for k, v := range rawYamlInfo.Hooks {
+			if info.Hooks == nil {
+				info.Hooks = make(map[string]*snap.HookInfo)
+			}
+			info.Hooks[k] = v
+			info.Hooks[k].Snap = info
+		}
 		for _, s := range rawYamlInfo.Assumes {
 			info.Assumes = append(info.Assumes, s)
 		}
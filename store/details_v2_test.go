@@ -202,6 +202,7 @@ func (s *detailsV2Suite) TestInfoFromStoreSnap(c *C) {
 	// clear recursive bits
 	info2.Plugs = nil
 	info2.Slots = nil
+	info2.Apps = nil
 	c.Check(&info2, DeepEquals, &snap.Info{
 		Architectures: []string{"amd64"},
 		Assumes:       []string{"snapd2.49"},
@@ -275,6 +276,12 @@ func (s *detailsV2Suite) TestInfoFromStoreSnap(c *C) {
 	c.Check(slot.Apps, HasLen, 1)
 	c.Check(slot.Apps["content-plug"].Command, Equals, "bin/content-plug")
 
+	// and the apps
+	c.Assert(info.Apps, HasLen, 1)
+	app := info.Apps["content-plug"]
+	c.Check(app.Snap, Equals, info)
+	c.Check(app, Equals, plug.Apps["content-plug"])
+
 	// private
 	err = json.Unmarshal([]byte(strings.Replace(thingyStoreJSON, `"private": false`, `"private": true`, 1)), &snp)
 	c.Assert(err, IsNil)
@@ -296,7 +303,6 @@ func (s *detailsV2Suite) TestInfoFromStoreSnap(c *C) {
 		"Environment",
 		"LicenseAgreement", // XXX go away?
 		"LicenseVersion",   // XXX go away?
-		"Apps",
 		"LegacyAliases",
 		"Hooks",
 		"BadInterfaces",
@@ -335,6 +341,57 @@ func (s *detailsV2Suite) TestInfoFromStoreSnap(c *C) {
 	checker("", x)
 }
 
+func (s *detailsV2Suite) TestInfoFromStoreSnapAppsAndHooks(c *C) {
+	var snp storeSnap
+	err := json.Unmarshal([]byte(coreStoreJSON), &snp)
+	c.Assert(err, IsNil)
+	snp.SnapYAML = `name: core
+version: 16-2.30
+type: os
+apps:
+  svc:
+    command: bin/svc
+    daemon: simple
+hooks:
+  configure:
+`
+
+	info, err := infoFromStoreSnap(&snp)
+	c.Assert(err, IsNil)
+
+	c.Assert(info.Apps, HasLen, 1)
+	c.Check(info.Apps["svc"].Snap, Equals, info)
+	c.Check(info.Apps["svc"].IsService(), Equals, true)
+	c.Assert(info.Hooks, HasLen, 1)
+	c.Check(info.Hooks["configure"].Snap, Equals, info)
+}
+
+func (s *detailsV2Suite) TestInfoFromStoreSnapUserDaemon(c *C) {
+	var snp storeSnap
+	err := json.Unmarshal([]byte(coreStoreJSON), &snp)
+	c.Assert(err, IsNil)
+	snp.SnapYAML = `name: core
+version: 16-2.30
+type: os
+apps:
+  user-svc:
+    command: bin/user-svc
+    daemon: simple
+    daemon-scope: user
+`
+
+	info, err := infoFromStoreSnap(&snp)
+	c.Assert(err, IsNil)
+
+	// this is what snapstate checks against experimental.user-daemons
+	// before downloading the snap
+	c.Assert(info.Apps, HasLen, 1)
+	app := info.Apps["user-svc"]
+	c.Check(app.Snap, Equals, info)
+	c.Check(app.IsService(), Equals, true)
+	c.Check(app.DaemonScope, Equals, snap.UserDaemon)
+}
+
 // arg must be a pointer to a struct
 func fillStruct(a interface{}, c *C) {
 	if t := reflect.TypeOf(a); t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
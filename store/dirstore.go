@@ -0,0 +1,852 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/arch"
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/channel"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/snap/snapfile"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// ErrDirStoreUnsupported is returned by the directory store for the
+// operations that need the online store, e.g. buying snaps.
+var ErrDirStoreUnsupported = errors.New("operation not supported by the directory store")
+
+var snapfileOpen = snapfile.Open
+
+// DirStore is a store serving snaps and assertions from a local directory
+// tree, e.g. a USB stick or an NFS share, for air-gapped systems.
+//
+// The tree holds .snap files along with .assert files carrying, in any
+// number and order, the assertions needed to install them: their
+// snap-declaration and snap-revision assertions as well as the account and
+// account-key assertions of their publishers, as written by "snap
+// download". Only the snaps with a matching snap-revision assertion are
+// served, the highest revision being picked unless a revision is asked for.
+//
+// The snaps are in all channels, unless listed in a channels.yaml file of
+// their directory, which maps the names of the snap files to the channels
+// they are in, e.g.:
+//
+//	foo_12.snap: [latest/stable]
+//	foo_14.snap: [latest/beta, latest/edge]
+//
+// Refreshes only pick the revisions that are in the channel tracked by the
+// snap and whose epoch can read the epoch of the current revision.
+//
+// The tree is scanned again for each request so that it can be updated in
+// place, the digests of the unchanged .snap files are cached.
+type DirStore struct {
+	dir string
+
+	mu    sync.Mutex
+	files map[string]*dirStoreFile
+}
+
+type dirStoreFile struct {
+	modTime time.Time
+	size    int64
+	digest  string
+	info    *snap.Info
+}
+
+// dirStoreIndex indexes the content of the directory tree.
+type dirStoreIndex struct {
+	// assertions are indexed by their unique reference, only the latest
+	// revision is kept
+	assertions map[string]asserts.Assertion
+	// sequences are the sequence forming assertions indexed by the
+	// unique reference of their sequence, sorted by sequence number
+	sequences map[string][]asserts.Assertion
+	// snaps are indexed by snap id, sorted by revision
+	snaps map[string][]*snap.Info
+	// snapIDs are indexed by snap name
+	snapIDs map[string]string
+	// channels are the full names of the channels of the snaps listed in
+	// the channel maps, indexed by the path of the snap file
+	channels map[string][]string
+}
+
+// dirStoreChannelMapName is the name of the files mapping the snap files of
+// their directory to their channels.
+const dirStoreChannelMapName = "channels.yaml"
+
+// NewDirStore returns a store serving the snaps and assertions found in the
+// given directory tree.
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{
+		dir:   dir,
+		files: make(map[string]*dirStoreFile),
+	}
+}
+
+// Dir returns the directory the store serves snaps and assertions from.
+func (s *DirStore) Dir() string {
+	return s.dir
+}
+
+func (s *DirStore) index() (*dirStoreIndex, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var snapPaths, assertPaths, channelMapPaths []string
+	err := filepath.Walk(s.dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		if fi.Name() == dirStoreChannelMapName {
+			channelMapPaths = append(channelMapPaths, path)
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".snap":
+			snapPaths = append(snapPaths, path)
+		case ".assert":
+			assertPaths = append(assertPaths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot scan store directory: %v", err)
+	}
+
+	idx := &dirStoreIndex{
+		assertions: make(map[string]asserts.Assertion),
+		sequences:  make(map[string][]asserts.Assertion),
+		snaps:      make(map[string][]*snap.Info),
+		snapIDs:    make(map[string]string),
+		channels:   make(map[string][]string),
+	}
+	for _, path := range channelMapPaths {
+		if err := idx.addChannelMap(path); err != nil {
+			// the snaps are then in all channels
+			logger.Noticef("cannot read channel map %q: %v", path, err)
+		}
+	}
+	for _, path := range assertPaths {
+		if err := idx.addAssertions(path); err != nil {
+			// skip the broken bundles, others might still be fine
+			logger.Noticef("cannot read assertions from %q: %v", path, err)
+		}
+	}
+	for _, seq := range idx.sequences {
+		sort.Slice(seq, func(i, j int) bool {
+			return seq[i].(asserts.SequenceMember).Sequence() < seq[j].(asserts.SequenceMember).Sequence()
+		})
+	}
+
+	seen := make(map[string]bool, len(snapPaths))
+	for _, path := range snapPaths {
+		seen[path] = true
+		info, err := s.snapInfo(idx, path)
+		if err != nil {
+			logger.Noticef("cannot serve snap %q: %v", path, err)
+			continue
+		}
+		if info == nil {
+			continue
+		}
+		idx.snaps[info.SnapID] = append(idx.snaps[info.SnapID], info)
+		idx.snapIDs[info.SnapName()] = info.SnapID
+	}
+	for _, infos := range idx.snaps {
+		sort.Slice(infos, func(i, j int) bool {
+			return infos[i].Revision.N < infos[j].Revision.N
+		})
+	}
+	// forget about the files that are gone
+	for path := range s.files {
+		if !seen[path] {
+			delete(s.files, path)
+		}
+	}
+	return idx, nil
+}
+
+func (idx *dirStoreIndex) addAssertions(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := asserts.NewDecoder(f)
+	for {
+		a, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ref := a.Ref()
+		if old := idx.assertions[ref.Unique()]; old != nil && old.Revision() >= a.Revision() {
+			continue
+		}
+		idx.assertions[ref.Unique()] = a
+		if _, ok := a.(asserts.SequenceMember); ok {
+			seq := sequenceOf(a)
+			members := idx.sequences[seq.Unique()]
+			for i, member := range members {
+				if member.(asserts.SequenceMember).Sequence() == seq.Sequence {
+					// replaced by the newer revision
+					members = append(members[:i], members[i+1:]...)
+					break
+				}
+			}
+			idx.sequences[seq.Unique()] = append(members, a)
+		}
+	}
+}
+
+func (idx *dirStoreIndex) addChannelMap(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var channelMap map[string][]string
+	if err := yaml.Unmarshal(data, &channelMap); err != nil {
+		return err
+	}
+	snapChannels := make(map[string][]string, len(channelMap))
+	for name, chNames := range channelMap {
+		if name != filepath.Base(name) || filepath.Ext(name) != ".snap" {
+			return fmt.Errorf("invalid snap file name %q", name)
+		}
+		channels := make([]string, 0, len(chNames))
+		for _, chName := range chNames {
+			full, err := channel.Full(chName)
+			if err != nil || full == "" {
+				return fmt.Errorf("invalid channel %q of %q", chName, name)
+			}
+			channels = append(channels, full)
+		}
+		snapChannels[filepath.Join(filepath.Dir(path), name)] = channels
+	}
+	for snapPath, channels := range snapChannels {
+		idx.channels[snapPath] = channels
+	}
+	return nil
+}
+
+// inChannel returns whether the snap is in the given channel, the stable
+// channel of the latest track if unset.
+func (idx *dirStoreIndex) inChannel(info *snap.Info, chName string) bool {
+	channels, ok := idx.channels[info.DownloadURL]
+	if !ok {
+		return true
+	}
+	if chName == "" {
+		chName = "stable"
+	}
+	full, err := channel.Full(chName)
+	if err != nil {
+		return false
+	}
+	return strutil.ListContains(channels, full)
+}
+
+func sequenceOf(a asserts.Assertion) *asserts.AtSequence {
+	ref := a.Ref()
+	return &asserts.AtSequence{
+		Type:        ref.Type,
+		SequenceKey: ref.PrimaryKey[:len(ref.PrimaryKey)-1],
+		Sequence:    a.(asserts.SequenceMember).Sequence(),
+		Revision:    a.Revision(),
+	}
+}
+
+// snapInfo returns the information about the snap at the given path, nil
+// if it has no snap-revision assertion or is for another architecture.
+func (s *DirStore) snapInfo(idx *dirStoreIndex, path string) (*snap.Info, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	cached := s.files[path]
+	if cached == nil || !cached.modTime.Equal(fi.ModTime()) || cached.size != fi.Size() {
+		digest, _, err := asserts.SnapFileSHA3_384(path)
+		if err != nil {
+			return nil, err
+		}
+		cached = &dirStoreFile{modTime: fi.ModTime(), size: fi.Size(), digest: digest}
+		s.files[path] = cached
+	}
+	revRef := &asserts.Ref{Type: asserts.SnapRevisionType, PrimaryKey: []string{cached.digest}}
+	a := idx.assertions[revRef.Unique()]
+	if a == nil {
+		logger.Debugf("no snap-revision assertion for %q, ignoring", path)
+		return nil, nil
+	}
+	snapRev := a.(*asserts.SnapRevision)
+	declRef := &asserts.Ref{Type: asserts.SnapDeclarationType, PrimaryKey: []string{release.Series, snapRev.SnapID()}}
+	a = idx.assertions[declRef.Unique()]
+	if a == nil {
+		return nil, fmt.Errorf("no snap-declaration assertion for snap id %q", snapRev.SnapID())
+	}
+	snapDecl := a.(*asserts.SnapDeclaration)
+	// the assertions might have changed since the info was cached
+	if info := cached.info; info != nil && info.SnapID == snapDecl.SnapID() && info.SnapName() == snapDecl.SnapName() && info.Revision.N == snapRev.SnapRevision() {
+		info.Publisher = snap.StoreAccount{ID: snapDecl.PublisherID()}
+		return info, nil
+	}
+
+	snapf, err := snapfileOpen(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := snap.ReadInfoFromSnapFile(snapf, &snap.SideInfo{
+		RealName: snapDecl.SnapName(),
+		SnapID:   snapDecl.SnapID(),
+		Revision: snap.R(snapRev.SnapRevision()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !dirStoreArchitectureMatches(info) {
+		logger.Debugf("snap %q is for another architecture, ignoring", path)
+		return nil, nil
+	}
+	info.Publisher = snap.StoreAccount{ID: snapDecl.PublisherID()}
+	info.DownloadInfo = snap.DownloadInfo{
+		DownloadURL: path,
+		Size:        fi.Size(),
+		Sha3_384:    cached.digest,
+	}
+	cached.info = info
+	return info, nil
+}
+
+func dirStoreArchitectureMatches(info *snap.Info) bool {
+	for _, a := range info.Architectures {
+		if a == "all" || a == arch.DpkgArchitecture() {
+			return true
+		}
+	}
+	return false
+}
+
+// copyInfo returns a copy of the snap information callers can modify, e.g.
+// to set the instance key.
+func copyInfo(info *snap.Info, chName string) *snap.Info {
+	c := *info
+	if chName == "" {
+		chName = "stable"
+	}
+	c.Channel = chName
+	return &c
+}
+
+// pick returns the snap with the given revision, or with the highest
+// revision if unset, among the ones accepted by the given function if any.
+func (idx *dirStoreIndex) pick(snapID string, revision snap.Revision, accept func(*snap.Info) bool) *snap.Info {
+	infos := idx.snaps[snapID]
+	for i := len(infos) - 1; i >= 0; i-- {
+		info := infos[i]
+		if !revision.Unset() && info.Revision != revision {
+			continue
+		}
+		if accept == nil || accept(info) {
+			return info
+		}
+	}
+	return nil
+}
+
+// EnsureDeviceSession does nothing, there is no device session with the
+// directory store.
+func (s *DirStore) EnsureDeviceSession() error {
+	return nil
+}
+
+// SnapInfo returns the information about the snap with the highest
+// revision of the given name.
+func (s *DirStore) SnapInfo(ctx context.Context, spec SnapSpec, user *auth.UserState) (*snap.Info, error) {
+	idx, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	info := idx.pick(idx.snapIDs[spec.Name], snap.Revision{}, nil)
+	if info == nil {
+		return nil, ErrSnapNotFound
+	}
+	return copyInfo(info, ""), nil
+}
+
+// SnapExists checks whether a snap of the given name is available.
+func (s *DirStore) SnapExists(ctx context.Context, spec SnapSpec, user *auth.UserState) (naming.SnapRef, *channel.Channel, error) {
+	info, err := s.SnapInfo(ctx, spec, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch, err := channel.Parse("stable", arch.DpkgArchitecture())
+	if err != nil {
+		return nil, nil, err
+	}
+	return naming.NewSnapRef(info.SnapName(), info.SnapID), &ch, nil
+}
+
+// Find returns the snaps, with their highest revision, whose name, title
+// or summary contain the query, or whose name starts with the query for
+// prefix searches.
+func (s *DirStore) Find(ctx context.Context, search *Search, user *auth.UserState) ([]*snap.Info, error) {
+	if search.Private {
+		return nil, ErrUnauthenticated
+	}
+	if search.Scope != "" && search.Scope != "wide" {
+		return nil, ErrInvalidScope
+	}
+	query := strings.ToLower(strings.TrimSpace(search.Query))
+	if strings.ContainsAny(query, `+=&|><!(){}[]^"~*?:\/`) {
+		return nil, ErrBadQuery
+	}
+
+	idx, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	var found []*snap.Info
+	for snapID := range idx.snaps {
+		info := idx.pick(snapID, snap.Revision{}, nil)
+		var match bool
+		if search.Prefix {
+			match = strings.HasPrefix(info.SnapName(), query)
+		} else {
+			match = strings.Contains(info.SnapName(), query) ||
+				strings.Contains(strings.ToLower(info.Title()), query) ||
+				strings.Contains(strings.ToLower(info.Summary()), query)
+		}
+		if match {
+			found = append(found, copyInfo(info, ""))
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].SnapName() < found[j].SnapName()
+	})
+	return found, nil
+}
+
+// SnapAction resolves the install, download and refresh actions against
+// the snaps of the directory tree, and the assertions to resolve of the
+// query against its assertions.
+func (s *DirStore) SnapAction(ctx context.Context, currentSnaps []*CurrentSnap, actions []*SnapAction, assertQuery AssertionQuery, user *auth.UserState, opts *RefreshOptions) ([]SnapActionResult, []AssertionResult, error) {
+	var toResolve map[asserts.Grouping][]*asserts.AtRevision
+	var toResolveSeq map[asserts.Grouping][]*asserts.AtSequence
+	if assertQuery != nil {
+		var err error
+		toResolve, toResolveSeq, err = assertQuery.ToResolve()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(currentSnaps) == 0 && len(actions) == 0 && len(toResolve) == 0 && len(toResolveSeq) == 0 {
+		// nothing to do
+		return nil, nil, &SnapActionError{NoResults: true}
+	}
+
+	idx, err := s.index()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	curSnaps := make(map[string]*CurrentSnap, len(currentSnaps))
+	for _, cur := range currentSnaps {
+		curSnaps[cur.InstanceName] = cur
+	}
+
+	refreshErrors := make(map[string]error)
+	installErrors := make(map[string]error)
+	downloadErrors := make(map[string]error)
+	var sars []SnapActionResult
+	for _, a := range actions {
+		if !isValidAction(a.Action) {
+			return nil, nil, fmt.Errorf("internal error: unsupported action %q", a.Action)
+		}
+		switch a.Action {
+		case "refresh":
+			cur := curSnaps[a.InstanceName]
+			if cur == nil {
+				return nil, nil, fmt.Errorf("internal error: action %q for non-current snap %q", a.Action, a.InstanceName)
+			}
+			chName := a.Channel
+			if chName == "" {
+				chName = cur.TrackingChannel
+			}
+			info := idx.pick(a.SnapID, a.Revision, func(info *snap.Info) bool {
+				if !info.Epoch.CanRead(cur.Epoch) {
+					return false
+				}
+				// the channel does not matter for a given revision
+				return !a.Revision.Unset() || idx.inChannel(info, chName)
+			})
+			switch {
+			case len(idx.snaps[a.SnapID]) == 0:
+				refreshErrors[a.InstanceName] = ErrSnapNotFound
+			case info == nil && a.Revision.Unset():
+				refreshErrors[a.InstanceName] = ErrNoUpdateAvailable
+			case info == nil:
+				refreshErrors[a.InstanceName] = &RevisionNotAvailableError{Action: a.Action, Channel: a.Channel}
+			case info.Revision == cur.Revision || findRev(info.Revision, cur.Block):
+				refreshErrors[a.InstanceName] = ErrNoUpdateAvailable
+			case a.Revision.Unset() && info.Revision.N < cur.Revision.N:
+				// do not go back to older revisions unless asked to
+				refreshErrors[a.InstanceName] = ErrNoUpdateAvailable
+			default:
+				result := copyInfo(info, chName)
+				_, result.InstanceKey = snap.SplitInstanceName(a.InstanceName)
+				sars = append(sars, SnapActionResult{Info: result})
+			}
+		case "install", "download":
+			errors := installErrors
+			if a.Action == "download" {
+				errors = downloadErrors
+			}
+			snapName, instanceKey := snap.SplitInstanceName(a.InstanceName)
+			snapID := a.SnapID
+			if snapID == "" {
+				snapID = idx.snapIDs[snapName]
+			}
+			info := idx.pick(snapID, a.Revision, func(info *snap.Info) bool {
+				return !a.Revision.Unset() || idx.inChannel(info, a.Channel)
+			})
+			switch {
+			case len(idx.snaps[snapID]) == 0:
+				errors[a.InstanceName] = ErrSnapNotFound
+			case info == nil:
+				errors[a.InstanceName] = &RevisionNotAvailableError{Action: a.Action, Channel: a.Channel}
+			default:
+				result := copyInfo(info, a.Channel)
+				if a.Action == "install" {
+					result.InstanceKey = instanceKey
+				}
+				sars = append(sars, SnapActionResult{Info: result})
+			}
+		}
+	}
+
+	ars, err := idx.resolveAssertions(assertQuery, toResolve, toResolveSeq)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	noResults := len(actions) == 0 && len(ars) == 0
+	if len(refreshErrors)+len(installErrors)+len(downloadErrors) != 0 || noResults {
+		// normalize empty maps
+		if len(refreshErrors) == 0 {
+			refreshErrors = nil
+		}
+		if len(installErrors) == 0 {
+			installErrors = nil
+		}
+		if len(downloadErrors) == 0 {
+			downloadErrors = nil
+		}
+		return sars, ars, &SnapActionError{
+			NoResults: noResults,
+			Refresh:   refreshErrors,
+			Install:   installErrors,
+			Download:  downloadErrors,
+		}
+	}
+	return sars, ars, nil
+}
+
+// assertionStreamURL returns the pseudo URL under which DownloadAssertions
+// serves the given assertion.
+func assertionStreamURL(a asserts.Assertion) string {
+	return "assertion:" + a.Ref().Unique()
+}
+
+func (idx *dirStoreIndex) resolveAssertions(assertQuery AssertionQuery, toResolve map[asserts.Grouping][]*asserts.AtRevision, toResolveSeq map[asserts.Grouping][]*asserts.AtSequence) ([]AssertionResult, error) {
+	urls := make(map[asserts.Grouping][]string)
+	for grouping, atRevs := range toResolve {
+		for _, at := range atRevs {
+			a := idx.assertions[at.Ref.Unique()]
+			if a == nil {
+				headers, _ := asserts.HeadersFromPrimaryKey(at.Type, at.PrimaryKey)
+				if err := assertQuery.AddError(&asserts.NotFoundError{Type: at.Type, Headers: headers}, &at.Ref); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if a.Revision() > at.Revision {
+				urls[grouping] = append(urls[grouping], assertionStreamURL(a))
+			}
+		}
+	}
+	for grouping, atSeqs := range toResolveSeq {
+		for _, at := range atSeqs {
+			a := idx.sequenceMember(at)
+			if a == nil {
+				headers := make(map[string]string, len(at.SequenceKey)+1)
+				for i, name := range at.Type.PrimaryKey[:len(at.SequenceKey)] {
+					headers[name] = at.SequenceKey[i]
+				}
+				if at.Sequence > 0 {
+					headers["sequence"] = fmt.Sprintf("%d", at.Sequence)
+				}
+				if err := assertQuery.AddSequenceError(&asserts.NotFoundError{Type: at.Type, Headers: headers}, at); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			seq := sequenceOf(a)
+			if seq.Sequence > at.Sequence || (seq.Sequence == at.Sequence && seq.Revision > at.Revision) {
+				urls[grouping] = append(urls[grouping], assertionStreamURL(a))
+			}
+		}
+	}
+
+	groupings := make([]string, 0, len(urls))
+	for grouping := range urls {
+		groupings = append(groupings, string(grouping))
+	}
+	sort.Strings(groupings)
+	ars := make([]AssertionResult, 0, len(groupings))
+	for _, grouping := range groupings {
+		ars = append(ars, AssertionResult{
+			Grouping:   asserts.Grouping(grouping),
+			StreamURLs: urls[asserts.Grouping(grouping)],
+		})
+	}
+	return ars, nil
+}
+
+// sequenceMember returns the assertion of the sequence matching the given
+// pinned sequence number, or the latest one.
+func (idx *dirStoreIndex) sequenceMember(at *asserts.AtSequence) asserts.Assertion {
+	members := idx.sequences[at.Unique()]
+	if len(members) == 0 {
+		return nil
+	}
+	if !at.Pinned {
+		return members[len(members)-1]
+	}
+	for _, a := range members {
+		if a.(asserts.SequenceMember).Sequence() == at.Sequence {
+			return a
+		}
+	}
+	return nil
+}
+
+// Sections returns no sections, the directory store has none.
+func (s *DirStore) Sections(ctx context.Context, user *auth.UserState) ([]string, error) {
+	return nil, nil
+}
+
+// WriteCatalogs writes the names and the commands of the snaps of the
+// directory tree.
+func (s *DirStore) WriteCatalogs(ctx context.Context, names io.Writer, adder SnapAdder) error {
+	idx, err := s.index()
+	if err != nil {
+		return err
+	}
+	for snapID := range idx.snaps {
+		info := idx.pick(snapID, snap.Revision{}, nil)
+		fmt.Fprintln(names, info.SnapName())
+		if len(info.Apps) == 0 {
+			continue
+		}
+		commands := make([]string, 0, len(info.Apps))
+		for _, app := range info.Apps {
+			if app.IsService() {
+				continue
+			}
+			commands = append(commands, app.Name)
+		}
+		sort.Strings(commands)
+		for i, app := range commands {
+			commands[i] = snap.JoinSnapApp(info.SnapName(), app)
+		}
+		if err := adder.AddSnap(info.SnapName(), info.Version, info.Summary(), commands); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Download copies the snap from the directory tree to the target path,
+// checking its digest.
+func (s *DirStore) Download(ctx context.Context, name, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, user *auth.UserState, dlOpts *DownloadOptions) error {
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return err
+	}
+	partialPath := targetPath + ".partial"
+	if err := osutil.CopyFile(downloadInfo.DownloadURL, partialPath, osutil.CopyFlagOverwrite); err != nil {
+		return fmt.Errorf("cannot copy snap %q from the store directory: %v", name, err)
+	}
+	digest, _, err := asserts.SnapFileSHA3_384(partialPath)
+	if err != nil {
+		os.Remove(partialPath)
+		return err
+	}
+	if digest != downloadInfo.Sha3_384 {
+		os.Remove(partialPath)
+		return HashError{name, digest, downloadInfo.Sha3_384}
+	}
+	return os.Rename(partialPath, targetPath)
+}
+
+// DownloadStream returns a reader of the snap from the directory tree,
+// starting at the resume offset.
+func (s *DirStore) DownloadStream(ctx context.Context, name string, downloadInfo *snap.DownloadInfo, resume int64, user *auth.UserState) (io.ReadCloser, int, error) {
+	f, err := os.Open(downloadInfo.DownloadURL)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot open snap %q in the store directory: %v", name, err)
+	}
+	if resume <= 0 {
+		return f, 200, nil
+	}
+	if _, err := f.Seek(resume, io.SeekStart); err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, 206, nil
+}
+
+// Assertion returns the assertion with the given type and primary key.
+func (s *DirStore) Assertion(assertType *asserts.AssertionType, primaryKey []string, user *auth.UserState) (asserts.Assertion, error) {
+	idx, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	ref := &asserts.Ref{Type: assertType, PrimaryKey: primaryKey}
+	if a := idx.assertions[ref.Unique()]; a != nil {
+		return a, nil
+	}
+	headers, err := asserts.HeadersFromPrimaryKey(assertType, primaryKey)
+	if err != nil {
+		return nil, err
+	}
+	return nil, &asserts.NotFoundError{Type: assertType, Headers: headers}
+}
+
+// SeqFormingAssertion returns the sequence forming assertion with the
+// given sequence key and sequence number, or the latest one of the
+// sequence if the sequence number is not positive.
+func (s *DirStore) SeqFormingAssertion(assertType *asserts.AssertionType, sequenceKey []string, sequence int, user *auth.UserState) (asserts.Assertion, error) {
+	if !assertType.SequenceForming() {
+		return nil, fmt.Errorf("internal error: requested non sequence-forming assertion type %q", assertType.Name)
+	}
+	idx, err := s.index()
+	if err != nil {
+		return nil, err
+	}
+	at := &asserts.AtSequence{
+		Type:        assertType,
+		SequenceKey: sequenceKey,
+		Sequence:    sequence,
+		Pinned:      sequence > 0,
+	}
+	if a := idx.sequenceMember(at); a != nil {
+		return a, nil
+	}
+	headers := make(map[string]string, len(sequenceKey)+1)
+	for i, name := range assertType.PrimaryKey[:len(sequenceKey)] {
+		headers[name] = sequenceKey[i]
+	}
+	if sequence > 0 {
+		headers["sequence"] = fmt.Sprintf("%d", sequence)
+	}
+	return nil, &asserts.NotFoundError{Type: assertType, Headers: headers}
+}
+
+// DownloadAssertions adds the assertions of the pseudo URLs returned by
+// SnapAction to the batch.
+func (s *DirStore) DownloadAssertions(streamURLs []string, b *asserts.Batch, user *auth.UserState) error {
+	idx, err := s.index()
+	if err != nil {
+		return err
+	}
+	for _, u := range streamURLs {
+		a := idx.assertions[strings.TrimPrefix(u, "assertion:")]
+		if a == nil || !strings.HasPrefix(u, "assertion:") {
+			return fmt.Errorf("cannot find assertion %q in the store directory", u)
+		}
+		if err := b.Add(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SuggestedCurrency returns no currency, snaps cannot be bought.
+func (s *DirStore) SuggestedCurrency() string {
+	return ""
+}
+
+// Buy is not supported by the directory store.
+func (s *DirStore) Buy(options *client.BuyOptions, user *auth.UserState) (*client.BuyResult, error) {
+	return nil, ErrDirStoreUnsupported
+}
+
+// ReadyToBuy is not supported by the directory store.
+func (s *DirStore) ReadyToBuy(user *auth.UserState) error {
+	return ErrDirStoreUnsupported
+}
+
+// ConnectivityCheck checks that the directory tree can be read.
+func (s *DirStore) ConnectivityCheck() (map[string]bool, error) {
+	_, err := os.Stat(s.dir)
+	return map[string]bool{s.dir: err == nil}, nil
+}
+
+// CreateCohorts is not supported by the directory store.
+func (s *DirStore) CreateCohorts(ctx context.Context, snaps []string) (map[string]string, error) {
+	return nil, ErrDirStoreUnsupported
+}
+
+// LoginUser is not supported by the directory store.
+func (s *DirStore) LoginUser(username, password, otp string) (string, string, error) {
+	return "", "", ErrDirStoreUnsupported
+}
+
+// UserInfo is not supported by the directory store.
+func (s *DirStore) UserInfo(email string) (userinfo *User, err error) {
+	return nil, ErrDirStoreUnsupported
+}
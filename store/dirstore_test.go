@@ -0,0 +1,564 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snapdir"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/testutil"
+)
+
+// the directory store is a drop-in replacement of the store
+var _ snapstate.StoreService = (*store.DirStore)(nil)
+
+type dirStoreSuite struct {
+	testutil.BaseTest
+
+	storeSigning *assertstest.StoreStack
+	devAcct      *asserts.Account
+
+	dir      string
+	snapDirs map[string]string
+	sto      *store.DirStore
+}
+
+var _ = Suite(&dirStoreSuite{})
+
+func (s *dirStoreSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+
+	s.storeSigning = assertstest.NewStoreStack("can0nical", nil)
+	s.devAcct = assertstest.NewAccount(s.storeSigning, "developer1", map[string]interface{}{
+		"account-id": "developer1",
+	}, "")
+
+	s.dir = c.MkDir()
+	s.snapDirs = make(map[string]string)
+	// mksquashfs is not needed, the snaps are read from directories
+	s.AddCleanup(store.MockSnapfileOpen(func(path string) (snap.Container, error) {
+		dir, ok := s.snapDirs[path]
+		if !ok {
+			return nil, fmt.Errorf("cannot open %q", path)
+		}
+		return snapdir.New(dir), nil
+	}))
+	s.sto = store.NewDirStore(s.dir)
+}
+
+func (s *dirStoreSuite) writeAssertions(c *C, name string, as ...asserts.Assertion) {
+	buf := bytes.NewBuffer(nil)
+	enc := asserts.NewEncoder(buf)
+	for _, a := range as {
+		c.Assert(enc.Encode(a), IsNil)
+	}
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, name), buf.Bytes(), 0644), IsNil)
+}
+
+func (s *dirStoreSuite) snapDecl(c *C, name string) *asserts.SnapDeclaration {
+	a, err := s.storeSigning.Sign(asserts.SnapDeclarationType, map[string]interface{}{
+		"series":       "16",
+		"snap-id":      name + "-id",
+		"snap-name":    name,
+		"publisher-id": "developer1",
+		"timestamp":    time.Now().UTC().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	return a.(*asserts.SnapDeclaration)
+}
+
+// addSnap adds a snap file to the directory along with its snap-revision
+// assertion unless unasserted.
+func (s *dirStoreSuite) addSnap(c *C, name string, revision int, yaml string, asserted bool) (path string) {
+	path = filepath.Join(s.dir, fmt.Sprintf("%s_%d.snap", name, revision))
+	// the content only matters for the digest
+	c.Assert(ioutil.WriteFile(path, []byte(fmt.Sprintf("%s-%d", name, revision)), 0644), IsNil)
+	snapDir := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(snapDir, "meta"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(snapDir, "meta", "snap.yaml"), []byte(yaml), 0644), IsNil)
+	s.snapDirs[path] = snapDir
+
+	if !asserted {
+		return path
+	}
+	digest, size, err := asserts.SnapFileSHA3_384(path)
+	c.Assert(err, IsNil)
+	a, err := s.storeSigning.Sign(asserts.SnapRevisionType, map[string]interface{}{
+		"snap-sha3-384": digest,
+		"snap-id":       name + "-id",
+		"snap-size":     fmt.Sprintf("%d", size),
+		"snap-revision": fmt.Sprintf("%d", revision),
+		"developer-id":  "developer1",
+		"timestamp":     time.Now().UTC().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	s.writeAssertions(c, fmt.Sprintf("%s_%d.assert", name, revision), a)
+	return path
+}
+
+const dirStoreFooYaml = `name: foo
+version: %d.0
+summary: Foo does things
+architectures: [all]
+apps:
+  foo:
+  bar:
+  svc:
+    daemon: simple
+`
+
+func (s *dirStoreSuite) addFoo(c *C) {
+	s.writeAssertions(c, "foo.assert", s.snapDecl(c, "foo"), s.devAcct)
+	s.addSnap(c, "foo", 1, fmt.Sprintf(dirStoreFooYaml, 1), true)
+	s.addSnap(c, "foo", 3, fmt.Sprintf(dirStoreFooYaml, 3), true)
+}
+
+func (s *dirStoreSuite) TestSnapInfo(c *C) {
+	s.addFoo(c)
+	path := filepath.Join(s.dir, "foo_3.snap")
+	digest, size, err := asserts.SnapFileSHA3_384(path)
+	c.Assert(err, IsNil)
+
+	info, err := s.sto.SnapInfo(context.TODO(), store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.SnapName(), Equals, "foo")
+	c.Check(info.SnapID, Equals, "foo-id")
+	c.Check(info.Revision, Equals, snap.R(3))
+	c.Check(info.Version, Equals, "3.0")
+	c.Check(info.Channel, Equals, "stable")
+	c.Check(info.Publisher.ID, Equals, "developer1")
+	c.Check(info.DownloadInfo, DeepEquals, snap.DownloadInfo{
+		DownloadURL: path,
+		Size:        int64(size),
+		Sha3_384:    digest,
+	})
+
+	_, err = s.sto.SnapInfo(context.TODO(), store.SnapSpec{Name: "bar"}, nil)
+	c.Check(err, Equals, store.ErrSnapNotFound)
+}
+
+func (s *dirStoreSuite) TestUnassertedSnapsIgnored(c *C) {
+	s.writeAssertions(c, "foo.assert", s.snapDecl(c, "foo"))
+	s.addSnap(c, "foo", 1, fmt.Sprintf(dirStoreFooYaml, 1), true)
+	s.addSnap(c, "foo", 2, fmt.Sprintf(dirStoreFooYaml, 2), false)
+
+	info, err := s.sto.SnapInfo(context.TODO(), store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Revision, Equals, snap.R(1))
+}
+
+func (s *dirStoreSuite) TestOtherArchitectureIgnored(c *C) {
+	s.writeAssertions(c, "foo.assert", s.snapDecl(c, "foo"))
+	s.addSnap(c, "foo", 1, "name: foo\nversion: 1\narchitectures: [no-such-arch]\n", true)
+
+	_, err := s.sto.SnapInfo(context.TODO(), store.SnapSpec{Name: "foo"}, nil)
+	c.Check(err, Equals, store.ErrSnapNotFound)
+}
+
+func (s *dirStoreSuite) TestRescan(c *C) {
+	_, err := s.sto.SnapInfo(context.TODO(), store.SnapSpec{Name: "foo"}, nil)
+	c.Check(err, Equals, store.ErrSnapNotFound)
+
+	s.addFoo(c)
+	info, err := s.sto.SnapInfo(context.TODO(), store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Revision, Equals, snap.R(3))
+
+	c.Assert(os.Remove(filepath.Join(s.dir, "foo_3.snap")), IsNil)
+	info, err = s.sto.SnapInfo(context.TODO(), store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Revision, Equals, snap.R(1))
+}
+
+func (s *dirStoreSuite) TestFind(c *C) {
+	s.addFoo(c)
+	s.writeAssertions(c, "bar.assert", s.snapDecl(c, "bar"))
+	s.addSnap(c, "bar", 7, "name: bar\nversion: 1\nsummary: Bar is not foo\n", true)
+
+	names := func(infos []*snap.Info) []string {
+		var names []string
+		for _, info := range infos {
+			names = append(names, info.SnapName())
+		}
+		return names
+	}
+
+	found, err := s.sto.Find(context.TODO(), &store.Search{Query: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(names(found), DeepEquals, []string{"bar", "foo"})
+
+	found, err = s.sto.Find(context.TODO(), &store.Search{Query: "fo", Prefix: true}, nil)
+	c.Assert(err, IsNil)
+	c.Check(names(found), DeepEquals, []string{"foo"})
+	c.Check(found[0].Revision, Equals, snap.R(3))
+
+	found, err = s.sto.Find(context.TODO(), &store.Search{Query: "baz"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(found, HasLen, 0)
+
+	_, err = s.sto.Find(context.TODO(), &store.Search{Query: "foo*"}, nil)
+	c.Check(err, Equals, store.ErrBadQuery)
+}
+
+func (s *dirStoreSuite) TestSnapActionInstall(c *C) {
+	s.addFoo(c)
+
+	results, _, err := s.sto.SnapAction(context.TODO(), nil, []*store.SnapAction{
+		{Action: "install", InstanceName: "foo_instance", Channel: "edge"},
+		{Action: "download", InstanceName: "foo", Revision: snap.R(1)},
+	}, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 2)
+	c.Check(results[0].InstanceName(), Equals, "foo_instance")
+	c.Check(results[0].Revision, Equals, snap.R(3))
+	c.Check(results[0].Channel, Equals, "edge")
+	c.Check(results[1].InstanceName(), Equals, "foo")
+	c.Check(results[1].Revision, Equals, snap.R(1))
+	c.Check(results[1].Channel, Equals, "stable")
+}
+
+func (s *dirStoreSuite) TestSnapActionInstallErrors(c *C) {
+	s.addFoo(c)
+
+	results, _, err := s.sto.SnapAction(context.TODO(), nil, []*store.SnapAction{
+		{Action: "install", InstanceName: "bar"},
+		{Action: "install", InstanceName: "foo", Revision: snap.R(2)},
+	}, nil, nil, nil)
+	c.Check(results, HasLen, 0)
+	c.Assert(err, FitsTypeOf, &store.SnapActionError{})
+	c.Check(err.(*store.SnapActionError).Install, DeepEquals, map[string]error{
+		"bar": store.ErrSnapNotFound,
+		"foo": &store.RevisionNotAvailableError{Action: "install"},
+	})
+}
+
+func (s *dirStoreSuite) TestSnapActionRefresh(c *C) {
+	s.addFoo(c)
+	s.writeAssertions(c, "bar.assert", s.snapDecl(c, "bar"))
+	s.addSnap(c, "bar", 7, "name: bar\nversion: 1\n", true)
+
+	current := []*store.CurrentSnap{
+		{InstanceName: "foo", SnapID: "foo-id", Revision: snap.R(1), TrackingChannel: "latest/beta"},
+		{InstanceName: "bar", SnapID: "bar-id", Revision: snap.R(7), TrackingChannel: "latest/stable"},
+	}
+	results, _, err := s.sto.SnapAction(context.TODO(), current, []*store.SnapAction{
+		{Action: "refresh", InstanceName: "foo", SnapID: "foo-id"},
+		{Action: "refresh", InstanceName: "bar", SnapID: "bar-id"},
+	}, nil, nil, nil)
+	c.Assert(results, HasLen, 1)
+	c.Check(results[0].InstanceName(), Equals, "foo")
+	c.Check(results[0].Revision, Equals, snap.R(3))
+	c.Check(results[0].Channel, Equals, "latest/beta")
+	c.Assert(err, FitsTypeOf, &store.SnapActionError{})
+	c.Check(err.(*store.SnapActionError).Refresh, DeepEquals, map[string]error{
+		"bar": store.ErrNoUpdateAvailable,
+	})
+
+	// blocked revisions are not refreshed to
+	current[0].Block = []snap.Revision{snap.R(3)}
+	results, _, err = s.sto.SnapAction(context.TODO(), current[:1], []*store.SnapAction{
+		{Action: "refresh", InstanceName: "foo", SnapID: "foo-id"},
+	}, nil, nil, nil)
+	c.Check(results, HasLen, 0)
+	c.Assert(err, FitsTypeOf, &store.SnapActionError{})
+	c.Check(err.(*store.SnapActionError).Refresh, DeepEquals, map[string]error{
+		"foo": store.ErrNoUpdateAvailable,
+	})
+}
+
+func (s *dirStoreSuite) TestSnapActionRefreshChannels(c *C) {
+	s.addFoo(c)
+	s.addSnap(c, "foo", 5, fmt.Sprintf(dirStoreFooYaml, 5), true)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "channels.yaml"), []byte(`
+foo_1.snap: [stable]
+foo_3.snap: [stable, beta]
+foo_5.snap: [latest/edge]
+`), 0644), IsNil)
+
+	refresh := func(current *store.CurrentSnap, action *store.SnapAction) ([]store.SnapActionResult, error) {
+		results, _, err := s.sto.SnapAction(context.TODO(), []*store.CurrentSnap{current}, []*store.SnapAction{action}, nil, nil, nil)
+		return results, err
+	}
+	for _, t := range []struct {
+		tracking, channel string
+		revision          snap.Revision
+	}{
+		{tracking: "latest/stable", revision: snap.R(3)},
+		{tracking: "latest/beta", revision: snap.R(3)},
+		{tracking: "latest/edge", revision: snap.R(5)},
+		{tracking: "latest/stable", channel: "edge", revision: snap.R(5)},
+	} {
+		results, err := refresh(
+			&store.CurrentSnap{InstanceName: "foo", SnapID: "foo-id", Revision: snap.R(1), TrackingChannel: t.tracking},
+			&store.SnapAction{Action: "refresh", InstanceName: "foo", SnapID: "foo-id", Channel: t.channel})
+		c.Assert(err, IsNil, Commentf("%v", t))
+		c.Assert(results, HasLen, 1)
+		c.Check(results[0].Revision, Equals, t.revision, Commentf("%v", t))
+	}
+
+	// nothing newer in the channel
+	results, err := refresh(
+		&store.CurrentSnap{InstanceName: "foo", SnapID: "foo-id", Revision: snap.R(1), TrackingChannel: "latest/candidate"},
+		&store.SnapAction{Action: "refresh", InstanceName: "foo", SnapID: "foo-id"})
+	c.Check(results, HasLen, 0)
+	c.Assert(err, FitsTypeOf, &store.SnapActionError{})
+	c.Check(err.(*store.SnapActionError).Refresh, DeepEquals, map[string]error{
+		"foo": store.ErrNoUpdateAvailable,
+	})
+
+	// a given revision is picked whatever its channels
+	results, err = refresh(
+		&store.CurrentSnap{InstanceName: "foo", SnapID: "foo-id", Revision: snap.R(1), TrackingChannel: "latest/stable"},
+		&store.SnapAction{Action: "refresh", InstanceName: "foo", SnapID: "foo-id", Revision: snap.R(5)})
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 1)
+	c.Check(results[0].Revision, Equals, snap.R(5))
+
+	// installs pick from the channel too
+	results, _, err = s.sto.SnapAction(context.TODO(), nil, []*store.SnapAction{
+		{Action: "install", InstanceName: "foo", Channel: "beta"},
+	}, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 1)
+	c.Check(results[0].Revision, Equals, snap.R(3))
+	results, _, err = s.sto.SnapAction(context.TODO(), nil, []*store.SnapAction{
+		{Action: "install", InstanceName: "foo", Channel: "2.0/stable"},
+	}, nil, nil, nil)
+	c.Check(results, HasLen, 0)
+	c.Assert(err, FitsTypeOf, &store.SnapActionError{})
+	c.Check(err.(*store.SnapActionError).Install, DeepEquals, map[string]error{
+		"foo": &store.RevisionNotAvailableError{Action: "install", Channel: "2.0/stable"},
+	})
+}
+
+func (s *dirStoreSuite) TestInvalidChannelMapIgnored(c *C) {
+	s.addFoo(c)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "channels.yaml"), []byte("foo_1.snap: [stable]\nfoo_3.snap: [a/b/c/d]\n"), 0644), IsNil)
+
+	results, _, err := s.sto.SnapAction(context.TODO(), nil, []*store.SnapAction{
+		{Action: "install", InstanceName: "foo", Channel: "edge"},
+	}, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 1)
+	c.Check(results[0].Revision, Equals, snap.R(3))
+}
+
+func (s *dirStoreSuite) TestSnapActionRefreshEpochs(c *C) {
+	s.writeAssertions(c, "foo.assert", s.snapDecl(c, "foo"), s.devAcct)
+	s.addSnap(c, "foo", 1, fmt.Sprintf(dirStoreFooYaml, 1), true)
+	s.addSnap(c, "foo", 2, fmt.Sprintf(dirStoreFooYaml, 2)+"epoch: 1*\n", true)
+	s.addSnap(c, "foo", 3, fmt.Sprintf(dirStoreFooYaml, 3)+"epoch: 2*\n", true)
+
+	refresh := func(epoch snap.Epoch, revision snap.Revision) ([]store.SnapActionResult, error) {
+		results, _, err := s.sto.SnapAction(context.TODO(), []*store.CurrentSnap{
+			{InstanceName: "foo", SnapID: "foo-id", Revision: snap.R(1), TrackingChannel: "latest/stable", Epoch: epoch},
+		}, []*store.SnapAction{
+			{Action: "refresh", InstanceName: "foo", SnapID: "foo-id", Revision: revision},
+		}, nil, nil, nil)
+		return results, err
+	}
+
+	// revision 3 cannot read the data of epoch 0, revision 2 can
+	results, err := refresh(snap.E("0"), snap.Revision{})
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 1)
+	c.Check(results[0].Revision, Equals, snap.R(2))
+
+	results, err = refresh(snap.E("0"), snap.R(3))
+	c.Check(results, HasLen, 0)
+	c.Assert(err, FitsTypeOf, &store.SnapActionError{})
+	c.Check(err.(*store.SnapActionError).Refresh, DeepEquals, map[string]error{
+		"foo": &store.RevisionNotAvailableError{Action: "refresh"},
+	})
+
+	results, err = refresh(snap.E("1"), snap.Revision{})
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 1)
+	c.Check(results[0].Revision, Equals, snap.R(3))
+}
+
+func (s *dirStoreSuite) TestSnapActionNothingToDo(c *C) {
+	_, _, err := s.sto.SnapAction(context.TODO(), nil, nil, nil, nil, nil)
+	c.Check(err, DeepEquals, &store.SnapActionError{NoResults: true})
+}
+
+func (s *dirStoreSuite) TestSnapActionFetchAssertions(c *C) {
+	s.addFoo(c)
+	decl := s.snapDecl(c, "foo")
+
+	assertq := &testAssertQuery{
+		toResolve: map[asserts.Grouping][]*asserts.AtRevision{
+			asserts.Grouping("g1"): {
+				{Ref: *decl.Ref(), Revision: asserts.RevisionNotKnown},
+				{Ref: asserts.Ref{Type: asserts.SnapDeclarationType, PrimaryKey: []string{"16", "bar-id"}}, Revision: asserts.RevisionNotKnown},
+			},
+		},
+	}
+	results, aresults, err := s.sto.SnapAction(context.TODO(), nil, nil, assertq, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(results, HasLen, 0)
+	c.Assert(aresults, HasLen, 1)
+	c.Check(aresults[0].Grouping, Equals, asserts.Grouping("g1"))
+	c.Assert(aresults[0].StreamURLs, HasLen, 1)
+	c.Check(assertq.errors, DeepEquals, map[string]error{
+		"snap-declaration/16/bar-id": &asserts.NotFoundError{
+			Type:    asserts.SnapDeclarationType,
+			Headers: map[string]string{"series": "16", "snap-id": "bar-id"},
+		},
+	})
+
+	batch := asserts.NewBatch(nil)
+	c.Assert(s.sto.DownloadAssertions(aresults[0].StreamURLs, batch, nil), IsNil)
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   s.storeSigning.Trusted,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(db.Add(s.storeSigning.StoreAccountKey("")), IsNil)
+	c.Assert(db.Add(s.devAcct), IsNil)
+	c.Assert(batch.CommitTo(db, nil), IsNil)
+	_, err = db.Find(asserts.SnapDeclarationType, map[string]string{"series": "16", "snap-id": "foo-id"})
+	c.Check(err, IsNil)
+
+	// nothing newer to fetch
+	assertq.toResolve[asserts.Grouping("g1")] = []*asserts.AtRevision{{Ref: *decl.Ref(), Revision: 0}}
+	_, _, err = s.sto.SnapAction(context.TODO(), nil, nil, assertq, nil, nil)
+	c.Check(err, DeepEquals, &store.SnapActionError{NoResults: true})
+}
+
+func (s *dirStoreSuite) TestAssertion(c *C) {
+	s.addFoo(c)
+
+	a, err := s.sto.Assertion(asserts.SnapDeclarationType, []string{"16", "foo-id"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.SnapDeclaration).SnapName(), Equals, "foo")
+
+	_, err = s.sto.Assertion(asserts.SnapDeclarationType, []string{"16", "bar-id"}, nil)
+	c.Check(asserts.IsNotFound(err), Equals, true)
+}
+
+func (s *dirStoreSuite) TestSeqFormingAssertion(c *C) {
+	vset := func(sequence int) asserts.Assertion {
+		a, err := s.storeSigning.Sign(asserts.ValidationSetType, map[string]interface{}{
+			"series":     "16",
+			"account-id": "can0nical",
+			"name":       "base-set",
+			"sequence":   fmt.Sprintf("%d", sequence),
+			"snaps": []interface{}{
+				map[string]interface{}{
+					"name":     "foo",
+					"id":       "foosnapidfoosnapidfoosnapidfoosn",
+					"presence": "required",
+				},
+			},
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+		}, nil, "")
+		c.Assert(err, IsNil)
+		return a
+	}
+	s.writeAssertions(c, "vset.assert", vset(2), vset(1))
+
+	a, err := s.sto.SeqFormingAssertion(asserts.ValidationSetType, []string{"16", "can0nical", "base-set"}, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.ValidationSet).Sequence(), Equals, 2)
+
+	a, err = s.sto.SeqFormingAssertion(asserts.ValidationSetType, []string{"16", "can0nical", "base-set"}, 1, nil)
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.ValidationSet).Sequence(), Equals, 1)
+
+	_, err = s.sto.SeqFormingAssertion(asserts.ValidationSetType, []string{"16", "can0nical", "base-set"}, 3, nil)
+	c.Check(asserts.IsNotFound(err), Equals, true)
+}
+
+func (s *dirStoreSuite) TestDownload(c *C) {
+	s.addFoo(c)
+	info, err := s.sto.SnapInfo(context.TODO(), store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+
+	target := filepath.Join(c.MkDir(), "downloads", "foo_3.snap")
+	c.Assert(s.sto.Download(context.TODO(), "foo", target, &info.DownloadInfo, nil, nil, nil), IsNil)
+	c.Check(target, testutil.FileEquals, "foo-3")
+
+	// the content is checked
+	info.Sha3_384 = strings.Repeat("0", 96)
+	err = s.sto.Download(context.TODO(), "foo", target+".other", &info.DownloadInfo, nil, nil, nil)
+	c.Check(err, ErrorMatches, `sha3-384 mismatch for "foo": .*`)
+	c.Check(target+".other", testutil.FileAbsent)
+	c.Check(target+".other.partial", testutil.FileAbsent)
+}
+
+func (s *dirStoreSuite) TestDownloadStream(c *C) {
+	s.addFoo(c)
+	info, err := s.sto.SnapInfo(context.TODO(), store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+
+	r, status, err := s.sto.DownloadStream(context.TODO(), "foo", &info.DownloadInfo, 2, nil)
+	c.Assert(err, IsNil)
+	defer r.Close()
+	c.Check(status, Equals, 206)
+	data, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "o-3")
+}
+
+type testSnapAdder struct {
+	added map[string][]string
+}
+
+func (a *testSnapAdder) AddSnap(snapName, version, summary string, commands []string) error {
+	a.added[snapName] = commands
+	return nil
+}
+
+func (s *dirStoreSuite) TestWriteCatalogs(c *C) {
+	s.addFoo(c)
+
+	names := bytes.NewBuffer(nil)
+	adder := &testSnapAdder{added: make(map[string][]string)}
+	c.Assert(s.sto.WriteCatalogs(context.TODO(), names, adder), IsNil)
+	c.Check(names.String(), Equals, "foo\n")
+	c.Check(adder.added, DeepEquals, map[string][]string{
+		"foo": {"foo.bar", "foo"},
+	})
+}
+
+func (s *dirStoreSuite) TestUnsupported(c *C) {
+	_, err := s.sto.Buy(nil, nil)
+	c.Check(err, Equals, store.ErrDirStoreUnsupported)
+	_, _, err = s.sto.LoginUser("user", "pass", "")
+	c.Check(err, Equals, store.ErrDirStoreUnsupported)
+	_, err = s.sto.CreateCohorts(context.TODO(), []string{"foo"})
+	c.Check(err, Equals, store.ErrDirStoreUnsupported)
+}
@@ -60,6 +60,8 @@ var (
 	SnapActionFields = snapActionFields
 
 	Cancelled = cancelled
+
+	ParseAvahiBrowseOutput = parseAvahiBrowseOutput
 )
 
 func MockSnapdtoolCommandFromSystemSnap(f func(name string, args ...string) (*exec.Cmd, error)) (restore func()) {
@@ -237,6 +239,30 @@ func MockRatelimitReader(f func(r io.Reader, bucket *ratelimit.Bucket) io.Reader
 	}
 }
 
+func MockSnapfileOpen(f func(path string) (snap.Container, error)) (restore func()) {
+	old := snapfileOpen
+	snapfileOpen = f
+	return func() {
+		snapfileOpen = old
+	}
+}
+
+func MockDiscoverPeers(f func(ctx context.Context) ([]string, error)) (restore func()) {
+	old := discoverPeers
+	discoverPeers = f
+	return func() {
+		discoverPeers = old
+	}
+}
+
+func MockAdvertisePeerService(f func(port int) (stop func(), err error)) (restore func()) {
+	old := advertisePeerService
+	advertisePeerService = f
+	return func() {
+		advertisePeerService = old
+	}
+}
+
 func MockRequestTimeout(d time.Duration) (restore func()) {
 	old := requestTimeout
 	requestTimeout = d
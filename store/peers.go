@@ -0,0 +1,515 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/ratelimit"
+
+	"github.com/snapcore/snapd/httputil"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+)
+
+const (
+	// PeerServiceType is the mDNS service type under which snapd
+	// advertises its download cache to the peers of the LAN.
+	PeerServiceType = "_snapd-blobs._tcp"
+	// DefaultPeerPort is the port the download cache is served on to
+	// peers by default.
+	DefaultPeerPort = 42380
+
+	peerBlobsPath = "/v1/blobs/"
+)
+
+var (
+	// peerDiscoveryTimeout is how long the peers are looked for through
+	// mDNS
+	peerDiscoveryTimeout = 5 * time.Second
+	// peerReadHeaderTimeout is how long the peers have to send their
+	// requests
+	peerReadHeaderTimeout = 10 * time.Second
+
+	discoverPeers        = discoverPeersWithAvahi
+	advertisePeerService = advertisePeerServiceWithAvahi
+
+	validPeerBlobKey = regexp.MustCompile("^[0-9a-f]{96}$").MatchString
+
+	// localPeerNets are the networks, besides the loopback ones, that
+	// the peers served the download cache can be on: the private and
+	// link-local ones
+	localPeerNets = mustParseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "169.254.0.0/16", "fc00::/7", "fe80::/10")
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// isLocalPeer returns whether the given host:port address is on the
+// loopback, a private or a link-local network.
+func isLocalPeer(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	// drop the zone of link-local IPv6 addresses
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		host = host[:i]
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	for _, n := range localPeerNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// PeerSettings are the settings of the sharing of the downloaded snaps with
+// the peers of the LAN. The snaps are fetched from the peers, when they
+// have them, after getting their download information from the store, and
+// their sha3-384 digest is checked as for store downloads.
+type PeerSettings struct {
+	// Discover enables looking for peers through mDNS.
+	Discover bool
+	// Peers are the host:port addresses of known peers.
+	Peers []string
+	// Serve enables serving the download cache to the peers. The cache
+	// is served on all the interfaces over plain HTTP, without any
+	// authentication, to anyone knowing the digest of a snap, so the
+	// snaps downloaded by the system, including private ones, are
+	// exposed to the LAN. Requests from outside the loopback, private
+	// and link-local networks are rejected.
+	Serve bool
+	// Port is the port the download cache is served on, any free
+	// port if 0.
+	Port int
+}
+
+func (ps *PeerSettings) fetchEnabled() bool {
+	return ps != nil && (ps.Discover || len(ps.Peers) > 0)
+}
+
+// errNoPeers is returned by downloadFromPeers when fetching from peers is
+// not enabled.
+var errNoPeers = errors.New("no peers to download from")
+
+func (s *Store) peerSettings() (*PeerSettings, error) {
+	if s.cfg.Peers == nil {
+		return nil, nil
+	}
+	return s.cfg.Peers()
+}
+
+// downloadFromPeers downloads the snap to the target path from the first
+// peer that has it.
+func (s *Store) downloadFromPeers(ctx context.Context, name, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, dlOpts *DownloadOptions) error {
+	settings, err := s.peerSettings()
+	if err != nil {
+		return fmt.Errorf("cannot get peer settings: %v", err)
+	}
+	if !settings.fetchEnabled() {
+		return errNoPeers
+	}
+
+	peers := settings.Peers
+	if settings.Discover {
+		discovered, err := discoverPeers(ctx)
+		if err != nil {
+			logger.Noticef("Cannot discover peers: %v", err)
+		}
+		peers = append(append([]string(nil), peers...), discovered...)
+	}
+
+	tried := make(map[string]bool, len(peers))
+	for _, peer := range peers {
+		if tried[peer] {
+			continue
+		}
+		tried[peer] = true
+		err := s.downloadFromPeer(ctx, peer, name, targetPath, downloadInfo, pbar, dlOpts)
+		if err == nil {
+			logger.Noticef("Downloaded snap %q from peer %s.", name, peer)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		logger.Debugf("Cannot download snap %q from peer %s: %v", name, peer, err)
+	}
+	return fmt.Errorf("none of %d peers has snap %q", len(tried), name)
+}
+
+func (s *Store) peerClient() *http.Client {
+	s.peerClientOnce.Do(func() {
+		s.peerHTTPClient = httputil.NewHTTPClient(&httputil.ClientOptions{
+			// the peers are on the LAN
+			Proxy: func(*http.Request) (*url.URL, error) { return nil, nil },
+		})
+	})
+	return s.peerHTTPClient
+}
+
+// downloadFromPeer downloads the snap from the given peer. It shares the
+// partial file with the store downloads, resuming it as they do and leaving
+// it behind on errors when asked to by the download options.
+func (s *Store) downloadFromPeer(ctx context.Context, peer, name, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, dlOpts *DownloadOptions) (err error) {
+	if dlOpts == nil {
+		dlOpts = &DownloadOptions{}
+	}
+
+	partialPath := targetPath + ".partial"
+	w, err := os.OpenFile(partialPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		fi, _ := w.Stat()
+		if cerr := w.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		if err == nil {
+			return
+		}
+		_, badContent := err.(HashError)
+		if badContent || !dlOpts.LeavePartialOnError || fi == nil || fi.Size() == 0 {
+			os.Remove(partialPath)
+		}
+	}()
+
+	// the digest covers what was downloaded already
+	h := crypto.SHA3_384.New()
+	resume, err := io.Copy(h, w)
+	if err != nil {
+		return err
+	}
+	restart := func() error {
+		h.Reset()
+		resume = 0
+		if err := w.Truncate(0); err != nil {
+			return err
+		}
+		_, err := w.Seek(0, io.SeekStart)
+		return err
+	}
+	if downloadInfo.Size > 0 && resume >= downloadInfo.Size {
+		if err := restart(); err != nil {
+			return err
+		}
+	}
+
+	blobURL := &url.URL{
+		Scheme: "http",
+		Host:   peer,
+		Path:   peerBlobsPath + downloadInfo.Sha3_384,
+	}
+	req, err := http.NewRequest("GET", blobURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if resume > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", resume))
+	}
+	resp, err := s.peerClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case 200:
+		// the peer sent the whole snap
+		if resume > 0 {
+			if err := restart(); err != nil {
+				return err
+			}
+		}
+	case 206:
+	default:
+		return fmt.Errorf("unexpected status %q", resp.Status)
+	}
+	if downloadInfo.Size > 0 && resp.ContentLength >= 0 && resume+resp.ContentLength != downloadInfo.Size {
+		return fmt.Errorf("unexpected size %d, expected %d", resume+resp.ContentLength, downloadInfo.Size)
+	}
+
+	if pbar == nil {
+		pbar = progress.Null
+	}
+	pbar.Start(name, float64(downloadInfo.Size))
+	pbar.Set(float64(resume))
+	// never read more than the expected size from the peers
+	body := io.Reader(resp.Body)
+	if downloadInfo.Size > 0 {
+		body = io.LimitReader(resp.Body, downloadInfo.Size-resume+1)
+	}
+	if limit := dlOpts.RateLimit; limit > 0 {
+		bucket := ratelimit.NewBucketWithRate(float64(limit), 2*limit)
+		body = ratelimitReader(body, bucket)
+	}
+	n, err := io.Copy(io.MultiWriter(w, h, pbar), body)
+	pbar.Finished()
+	if err != nil {
+		return err
+	}
+	if downloadInfo.Size > 0 && resume+n != downloadInfo.Size {
+		return fmt.Errorf("unexpected size %d, expected %d", resume+n, downloadInfo.Size)
+	}
+	actualSha3 := fmt.Sprintf("%x", h.Sum(nil))
+	if actualSha3 != downloadInfo.Sha3_384 {
+		return HashError{name, actualSha3, downloadInfo.Sha3_384}
+	}
+	if err := w.Sync(); err != nil {
+		return err
+	}
+	return os.Rename(partialPath, targetPath)
+}
+
+// discoverPeersWithAvahi looks for the peers advertising their download
+// cache through mDNS with avahi-browse, returning their host:port
+// addresses.
+func discoverPeersWithAvahi(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, peerDiscoveryTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "avahi-browse", "--terminate", "--resolve", "--parsable", "--no-db-lookup", PeerServiceType)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("cannot browse mDNS services: %v", err)
+	}
+	return parseAvahiBrowseOutput(output), nil
+}
+
+// parseAvahiBrowseOutput returns the addresses of the resolved services
+// of the parsable output of avahi-browse, e.g.:
+//
+// =;eth0;IPv4;snapd on host;_snapd-blobs._tcp;local;host.local;192.168.1.5;42380;
+func parseAvahiBrowseOutput(output []byte) []string {
+	var peers []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ";")
+		if len(fields) < 9 || fields[0] != "=" {
+			continue
+		}
+		iface, proto, addr, port := fields[1], fields[2], fields[7], fields[8]
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil || net.ParseIP(addr) == nil {
+			continue
+		}
+		if proto == "IPv6" && strings.HasPrefix(addr, "fe80:") {
+			// link-local addresses need the interface
+			addr += "%" + iface
+		}
+		peer := net.JoinHostPort(addr, port)
+		if !seen[peer] {
+			seen[peer] = true
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// advertisePeerServiceWithAvahi advertises the download cache served on the
+// given port through mDNS with avahi-publish-service, until stop is called.
+func advertisePeerServiceWithAvahi(port int) (stop func(), err error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("avahi-publish-service", fmt.Sprintf("snapd on %s", hostname), PeerServiceType, strconv.Itoa(port))
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("cannot publish mDNS service: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(done)
+	}()
+	return func() {
+		cmd.Process.Kill()
+		<-done
+	}, nil
+}
+
+// PeerServer serves the download cache to the peers of the LAN when
+// enabled by the peer settings, advertising it through mDNS. It is a state
+// manager so that it follows the settings as they change.
+type PeerServer struct {
+	cacheDir string
+	settings func() (*PeerSettings, error)
+
+	mu              sync.Mutex
+	port            int
+	listener        net.Listener
+	server          *http.Server
+	stopAdvertising func()
+}
+
+// NewPeerServer returns a server of the download cache in the given
+// directory.
+func NewPeerServer(cacheDir string, settings func() (*PeerSettings, error)) *PeerServer {
+	return &PeerServer{
+		cacheDir: cacheDir,
+		settings: settings,
+	}
+}
+
+// Ensure implements StateManager.Ensure, starting or stopping serving the
+// download cache as set by the peer settings.
+func (ps *PeerServer) Ensure() error {
+	settings, err := ps.settings()
+	if err != nil {
+		return fmt.Errorf("cannot get peer settings: %v", err)
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	serve := settings != nil && settings.Serve
+	if ps.server != nil && (!serve || settings.Port != ps.port) {
+		ps.stop()
+	}
+	if !serve || ps.server != nil {
+		return nil
+	}
+
+	// listen on all the interfaces as the LAN addresses can change,
+	// ServeHTTP turns away the requests from outside the LAN
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", settings.Port))
+	if err != nil {
+		return fmt.Errorf("cannot serve the download cache to peers: %v", err)
+	}
+	ps.port = settings.Port
+	ps.listener = l
+	ps.server = &http.Server{
+		Handler:           ps,
+		ReadHeaderTimeout: peerReadHeaderTimeout,
+	}
+	go ps.server.Serve(l)
+	logger.Noticef("Serving the download cache to peers on %s.", l.Addr())
+
+	port := l.Addr().(*net.TCPAddr).Port
+	if stop, err := advertisePeerService(port); err != nil {
+		// the peers knowing about it can still use it
+		logger.Noticef("Cannot advertise the download cache to peers: %v", err)
+	} else {
+		ps.stopAdvertising = stop
+	}
+	return nil
+}
+
+// Stop implements StateStopper, it stops serving the download cache.
+func (ps *PeerServer) Stop() {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.server != nil {
+		ps.stop()
+	}
+}
+
+func (ps *PeerServer) stop() {
+	if ps.stopAdvertising != nil {
+		ps.stopAdvertising()
+		ps.stopAdvertising = nil
+	}
+	ps.server.Close()
+	ps.server = nil
+	ps.listener = nil
+	logger.Noticef("Stopped serving the download cache to peers.")
+}
+
+// Addr returns the address the download cache is served on, nil if it is
+// not served.
+func (ps *PeerServer) Addr() net.Addr {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.listener == nil {
+		return nil
+	}
+	return ps.listener.Addr()
+}
+
+// ServeHTTP serves the snaps of the download cache, addressed by their
+// sha3-384 digest, to the peers on the loopback, private and link-local
+// networks.
+func (ps *PeerServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the cache is served on all the interfaces, only to the LAN
+	if !isLocalPeer(r.RemoteAddr) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, peerBlobsPath) {
+		http.NotFound(w, r)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, peerBlobsPath)
+	if !validPeerBlobKey(key) {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(filepath.Join(ps.cacheDir, key))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", fi.ModTime(), f)
+}
@@ -0,0 +1,358 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store_test
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/juju/ratelimit"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type peersSuite struct {
+	baseStoreSuite
+
+	cacheDir string
+	settings *store.PeerSettings
+	server   *store.PeerServer
+
+	advertised []int
+}
+
+var _ = Suite(&peersSuite{})
+
+func (s *peersSuite) SetUpTest(c *C) {
+	s.baseStoreSuite.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+
+	s.cacheDir = c.MkDir()
+	s.settings = &store.PeerSettings{}
+	s.server = store.NewPeerServer(s.cacheDir, func() (*store.PeerSettings, error) {
+		return s.settings, nil
+	})
+	s.AddCleanup(s.server.Stop)
+
+	s.advertised = nil
+	s.AddCleanup(store.MockAdvertisePeerService(func(port int) (func(), error) {
+		s.advertised = append(s.advertised, port)
+		return func() { s.advertised = s.advertised[:len(s.advertised)-1] }, nil
+	}))
+	s.AddCleanup(store.MockDiscoverPeers(func(ctx context.Context) ([]string, error) {
+		c.Fatalf("unexpected peer discovery")
+		return nil, nil
+	}))
+}
+
+func sha3_384(content string) string {
+	h := crypto.SHA3_384.New()
+	io.WriteString(h, content)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// addBlob adds a snap to the download cache served to the peers.
+func (s *peersSuite) addBlob(c *C, content string) *snap.DownloadInfo {
+	digest := sha3_384(content)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.cacheDir, digest), []byte(content), 0600), IsNil)
+	return &snap.DownloadInfo{
+		DownloadURL: "https://store.example.com/foo.snap",
+		Size:        int64(len(content)),
+		Sha3_384:    digest,
+	}
+}
+
+func (s *peersSuite) TestParseAvahiBrowseOutput(c *C) {
+	output := []byte(`+;eth0;IPv4;snapd on one;_snapd-blobs._tcp;local
+=;eth0;IPv4;snapd on one;_snapd-blobs._tcp;local;one.local;192.168.1.5;42380;
+=;eth0;IPv6;snapd on one;_snapd-blobs._tcp;local;one.local;fe80::1;42380;
+=;wlan0;IPv4;snapd on one;_snapd-blobs._tcp;local;one.local;192.168.1.5;42380;
+=;eth0;IPv4;snapd on two;_snapd-blobs._tcp;local;two.local;192.168.1.6;8080;
+=;eth0;IPv4;broken;_snapd-blobs._tcp;local;two.local;not-an-ip;8080;
+=;eth0;IPv4;broken;_snapd-blobs._tcp;local;two.local;192.168.1.7;port;
+`)
+	c.Check(store.ParseAvahiBrowseOutput(output), DeepEquals, []string{
+		"192.168.1.5:42380",
+		"[fe80::1%eth0]:42380",
+		"192.168.1.6:8080",
+	})
+}
+
+func (s *peersSuite) TestServeHTTP(c *C) {
+	dlInfo := s.addBlob(c, "snap content")
+	srv := httptest.NewServer(s.server)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/v1/blobs/" + dlInfo.Sha3_384)
+	c.Assert(err, IsNil)
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	c.Assert(err, IsNil)
+	c.Check(resp.StatusCode, Equals, 200)
+	c.Check(string(data), Equals, "snap content")
+
+	for _, path := range []string{
+		"/v1/blobs/" + sha3_384("other content"),
+		"/v1/blobs/..%2f" + dlInfo.Sha3_384,
+		"/v1/blobs/",
+		"/" + dlInfo.Sha3_384,
+	} {
+		resp, err := http.Get(srv.URL + path)
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+		c.Check(resp.StatusCode, Equals, 404, Commentf(path))
+	}
+
+	resp, err = http.Post(srv.URL+"/v1/blobs/"+dlInfo.Sha3_384, "text/plain", nil)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Check(resp.StatusCode, Equals, 405)
+}
+
+func (s *peersSuite) TestServeHTTPOnlyToLocalPeers(c *C) {
+	dlInfo := s.addBlob(c, "snap content")
+
+	for _, tc := range []struct {
+		remoteAddr string
+		status     int
+	}{
+		{"127.0.0.1:1234", 200},
+		{"[::1]:1234", 200},
+		{"10.1.2.3:1234", 200},
+		{"172.16.0.5:1234", 200},
+		{"192.168.1.5:1234", 200},
+		{"169.254.1.1:1234", 200},
+		{"[fd00::1]:1234", 200},
+		{"[fe80::1%eth0]:1234", 200},
+		{"8.8.8.8:1234", 403},
+		{"172.32.0.1:1234", 403},
+		{"[2001:db8::1]:1234", 403},
+		{"garbage", 403},
+	} {
+		req := httptest.NewRequest("GET", "/v1/blobs/"+dlInfo.Sha3_384, nil)
+		req.RemoteAddr = tc.remoteAddr
+		rec := httptest.NewRecorder()
+		s.server.ServeHTTP(rec, req)
+		c.Check(rec.Code, Equals, tc.status, Commentf(tc.remoteAddr))
+	}
+}
+
+func (s *peersSuite) TestPeerServerEnsure(c *C) {
+	c.Assert(s.server.Ensure(), IsNil)
+	c.Check(s.server.Addr(), IsNil)
+	c.Check(s.advertised, HasLen, 0)
+
+	s.settings.Serve = true
+	c.Assert(s.server.Ensure(), IsNil)
+	addr := s.server.Addr()
+	c.Assert(addr, NotNil)
+	c.Check(s.advertised, HasLen, 1)
+	// nothing changed
+	c.Assert(s.server.Ensure(), IsNil)
+	c.Check(s.server.Addr(), Equals, addr)
+	c.Check(s.advertised, HasLen, 1)
+
+	dlInfo := s.addBlob(c, "snap content")
+	resp, err := http.Get(fmt.Sprintf("http://%s/v1/blobs/%s", addr, dlInfo.Sha3_384))
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Check(resp.StatusCode, Equals, 200)
+
+	s.settings.Serve = false
+	c.Assert(s.server.Ensure(), IsNil)
+	c.Check(s.server.Addr(), IsNil)
+	c.Check(s.advertised, HasLen, 0)
+}
+
+func (s *peersSuite) newStore(c *C) *store.Store {
+	cfg := store.DefaultConfig()
+	cfg.CacheDownloads = 5
+	cfg.Peers = func() (*store.PeerSettings, error) {
+		return s.settings, nil
+	}
+	return store.New(cfg, nil)
+}
+
+func (s *peersSuite) TestDownloadFromPeer(c *C) {
+	dlInfo := s.addBlob(c, "snap content")
+	srv := httptest.NewServer(s.server)
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	c.Assert(err, IsNil)
+	s.settings.Peers = []string{"127.0.0.1:1", u.Host}
+
+	restore := store.MockDownload(func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *store.Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *store.DownloadOptions) error {
+		c.Fatalf("unexpected download from the store")
+		return nil
+	})
+	defer restore()
+
+	path := filepath.Join(c.MkDir(), "foo.snap")
+	err = s.newStore(c).Download(s.ctx, "foo", path, dlInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(path, testutil.FileEquals, "snap content")
+	c.Check(path+".partial", testutil.FileAbsent)
+	// and it is cached for other peers
+	c.Check(filepath.Join(dirs.SnapDownloadCacheDir, dlInfo.Sha3_384), testutil.FilePresent)
+}
+
+func (s *peersSuite) TestDownloadFromPeerResumes(c *C) {
+	dlInfo := s.addBlob(c, "snap content")
+	srv := httptest.NewServer(s.server)
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	c.Assert(err, IsNil)
+	s.settings.Peers = []string{u.Host}
+
+	path := filepath.Join(c.MkDir(), "foo.snap")
+	// left behind by an earlier download
+	c.Assert(ioutil.WriteFile(path+".partial", []byte("snap "), 0600), IsNil)
+	err = s.newStore(c).Download(s.ctx, "foo", path, dlInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(path, testutil.FileEquals, "snap content")
+	c.Check(path+".partial", testutil.FileAbsent)
+}
+
+func (s *peersSuite) TestDownloadFromPeerOptions(c *C) {
+	dlInfo := s.addBlob(c, "snap content")
+	// the peer closes the connection half way through
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", dlInfo.Size))
+		io.WriteString(w, "snap ")
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	c.Assert(err, IsNil)
+	s.settings.Peers = []string{u.Host}
+
+	limited := 0
+	restore := store.MockRatelimitReader(func(r io.Reader, bucket *ratelimit.Bucket) io.Reader {
+		limited++
+		c.Check(bucket.Capacity(), Equals, int64(2048))
+		return r
+	})
+	defer restore()
+	restore = store.MockDownload(func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *store.Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *store.DownloadOptions) error {
+		// resumed from what the peer sent
+		c.Check(resume, Equals, int64(len("snap ")))
+		return fmt.Errorf("no network")
+	})
+	defer restore()
+
+	path := filepath.Join(c.MkDir(), "foo.snap")
+	dlOpts := &store.DownloadOptions{RateLimit: 1024, LeavePartialOnError: true}
+	err = s.newStore(c).Download(s.ctx, "foo", path, dlInfo, nil, nil, dlOpts)
+	c.Assert(err, ErrorMatches, "no network")
+	c.Check(limited, Equals, 1)
+	c.Check(path+".partial", testutil.FileEquals, "snap ")
+
+	// without LeavePartialOnError nothing is left behind
+	c.Assert(os.Remove(path+".partial"), IsNil)
+	restore = store.MockDownload(func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *store.Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *store.DownloadOptions) error {
+		c.Check(resume, Equals, int64(0))
+		return fmt.Errorf("no network")
+	})
+	defer restore()
+	err = s.newStore(c).Download(s.ctx, "foo", path, dlInfo, nil, nil, nil)
+	c.Assert(err, ErrorMatches, "no network")
+	c.Check(path+".partial", testutil.FileAbsent)
+}
+
+func (s *peersSuite) TestDownloadFromDiscoveredPeer(c *C) {
+	dlInfo := s.addBlob(c, "snap content")
+	srv := httptest.NewServer(s.server)
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	c.Assert(err, IsNil)
+	s.settings.Discover = true
+	restore := store.MockDiscoverPeers(func(ctx context.Context) ([]string, error) {
+		return []string{u.Host}, nil
+	})
+	defer restore()
+
+	path := filepath.Join(c.MkDir(), "foo.snap")
+	err = s.newStore(c).Download(s.ctx, "foo", path, dlInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(path, testutil.FileEquals, "snap content")
+}
+
+func (s *peersSuite) TestDownloadFallsBackToStore(c *C) {
+	dlInfo := s.addBlob(c, "snap content")
+	// the peer serves something else
+	c.Assert(ioutil.WriteFile(filepath.Join(s.cacheDir, dlInfo.Sha3_384), []byte("snap kontent"), 0600), IsNil)
+	srv := httptest.NewServer(s.server)
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	c.Assert(err, IsNil)
+	s.settings.Peers = []string{u.Host}
+
+	downloaded := 0
+	restore := store.MockDownload(func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *store.Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *store.DownloadOptions) error {
+		downloaded++
+		c.Check(url, Equals, "https://store.example.com/foo.snap")
+		_, err := io.WriteString(w, "snap content")
+		return err
+	})
+	defer restore()
+
+	path := filepath.Join(c.MkDir(), "foo.snap")
+	err = s.newStore(c).Download(s.ctx, "foo", path, dlInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(downloaded, Equals, 1)
+	c.Check(path, testutil.FileEquals, "snap content")
+	c.Check(path+".partial", testutil.FileAbsent)
+	c.Check(s.logbuf.String(), Matches, `(?s).*Cannot download snap "foo" from peers, using the store: none of 1 peers has snap "foo".*`)
+}
+
+func (s *peersSuite) TestDownloadWithoutPeers(c *C) {
+	downloaded := 0
+	restore := store.MockDownload(func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *store.Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *store.DownloadOptions) error {
+		downloaded++
+		_, err := io.WriteString(w, "snap content")
+		return err
+	})
+	defer restore()
+
+	dlInfo := &snap.DownloadInfo{
+		DownloadURL: "https://store.example.com/foo.snap",
+		Size:        int64(len("snap content")),
+		Sha3_384:    sha3_384("snap content"),
+	}
+	path := filepath.Join(c.MkDir(), "foo.snap")
+	err := s.newStore(c).Download(s.ctx, "foo", path, dlInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(downloaded, Equals, 1)
+	c.Check(s.logbuf.String(), Not(Matches), `(?s).*peers.*`)
+}
@@ -114,6 +114,10 @@ type Config struct {
 
 	// Proxy returns the HTTP proxy to use when talking to the store
 	Proxy func(*http.Request) (*url.URL, error)
+
+	// Peers returns the settings of the sharing of downloaded snaps
+	// with the peers of the LAN, can be nil.
+	Peers func() (*PeerSettings, error)
 }
 
 // setBaseURL updates the store API's base URL in the Config. Must not be used
@@ -162,6 +166,9 @@ type Store struct {
 
 	cacher downloadCache
 
+	peerClientOnce sync.Once
+	peerHTTPClient *http.Client
+
 	proxy              func(*http.Request) (*url.URL, error)
 	proxyConnectHeader http.Header
 
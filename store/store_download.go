@@ -224,6 +224,13 @@ func (s *Store) Download(ctx context.Context, name string, targetPath string, do
 		return nil
 	}
 
+	// the peers of the LAN might have it already
+	if err := s.downloadFromPeers(ctx, name, targetPath, downloadInfo, pbar, dlOpts); err == nil {
+		return s.cacher.Put(downloadInfo.Sha3_384, targetPath)
+	} else if err != errNoPeers {
+		logger.Noticef("Cannot download snap %q from peers, using the store: %v", name, err)
+	}
+
 	if s.useDeltas() {
 		logger.Debugf("Available deltas returned by store: %v", downloadInfo.Deltas)
 
@@ -112,6 +112,14 @@ func (s *emulation) CurrentTasksCount(unit string) (uint64, error) {
 	return 0, &notImplementedError{"CurrentTasksCount"}
 }
 
+func (s *emulation) CurrentCPUUsage(unit string) (time.Duration, error) {
+	return 0, &notImplementedError{"CurrentCPUUsage"}
+}
+
+func (s *emulation) RestartsCount(unit string) (uint64, error) {
+	return 0, &notImplementedError{"RestartsCount"}
+}
+
 func (s *emulation) IsEnabled(service string) (bool, error) {
 	return false, &notImplementedError{"IsEnabled"}
 }
@@ -22,9 +22,11 @@ package systemd
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"log/syslog"
 	"net"
 	"os"
+	"strconv"
 )
 
 var journalStdoutPath = "/run/systemd/journal/stdout"
@@ -70,3 +72,31 @@ func NewJournalStreamFile(identifier string, priority syslog.Priority, levelPref
 
 	return conn.File()
 }
+
+// jctlAudit calls journalctl to get the JSON logs of the kernel audit
+// subsystem, which is where the AppArmor and seccomp denials end up, either
+// through the audit or the kernel transport of the journal.
+var jctlAudit = func(cursor string, n int) (io.ReadCloser, error) {
+	args := []string{"-o", "json", "--no-pager", "_TRANSPORT=audit", "_TRANSPORT=kernel"}
+	if cursor != "" {
+		args = append(args, "--after-cursor", cursor)
+	} else {
+		args = append(args, "-n", strconv.Itoa(n))
+	}
+	return osutilStreamCommand("journalctl", args...)
+}
+
+// AuditLogReader returns a reader for the JSON logs of the kernel audit
+// subsystem that follow the journal entry with the given cursor, or for the
+// last n of them when the cursor is empty.
+func AuditLogReader(cursor string, n int) (io.ReadCloser, error) {
+	return jctlAudit(cursor, n)
+}
+
+func MockJournalctlAudit(f func(cursor string, n int) (io.ReadCloser, error)) (restore func()) {
+	old := jctlAudit
+	jctlAudit = f
+	return func() {
+		jctlAudit = old
+	}
+}
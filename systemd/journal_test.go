@@ -20,6 +20,7 @@
 package systemd_test
 
 import (
+	"io"
 	"log/syslog"
 	"net"
 	"path"
@@ -87,3 +88,21 @@ func (j *journalTestSuite) TestStreamFileHeader(c *C) {
 
 	<-doneCh
 }
+
+func (j *journalTestSuite) TestAuditLogReader(c *C) {
+	var args []string
+	restore := MockOsutilStreamCommand(func(name string, myargs ...string) (io.ReadCloser, error) {
+		c.Check(name, Equals, "journalctl")
+		args = myargs
+		return nil, nil
+	})
+	defer restore()
+
+	_, err := AuditLogReader("", 100)
+	c.Assert(err, IsNil)
+	c.Check(args, DeepEquals, []string{"-o", "json", "--no-pager", "_TRANSPORT=audit", "_TRANSPORT=kernel", "-n", "100"})
+
+	_, err = AuditLogReader("s=1234;i=5", 100)
+	c.Assert(err, IsNil)
+	c.Check(args, DeepEquals, []string{"-o", "json", "--no-pager", "_TRANSPORT=audit", "_TRANSPORT=kernel", "--after-cursor", "s=1234;i=5"})
+}
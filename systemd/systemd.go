@@ -403,6 +403,12 @@ type Systemd interface {
 	// threads if enabled, etc) part of the unit, which can be a service or a
 	// slice.
 	CurrentTasksCount(unit string) (uint64, error)
+	// CurrentCPUUsage returns the total CPU time consumed by the unit, which
+	// can be a service or a slice.
+	CurrentCPUUsage(unit string) (time.Duration, error)
+	// RestartsCount returns the number of times systemd restarted the
+	// given service automatically since it was last started.
+	RestartsCount(unit string) (uint64, error)
 	// Run a command
 	Run(command []string, opts *RunOptions) ([]byte, error)
 }
@@ -655,6 +661,9 @@ type UnitStatus struct {
 	Names   []string
 	Enabled bool
 	Active  bool
+	// Failed is true when the unit is in the failed state, e.g. after
+	// the service exited with an error or too many restarts.
+	Failed bool
 	// Installed is false if the queried unit doesn't exist.
 	Installed bool
 	// NeedDaemonReload is true when systemd reports that the unit on disk
@@ -762,6 +771,7 @@ func (s *systemd) getUnitStatus(properties []string, unitNames []string) ([]*Uni
 		case "ActiveState":
 			// made to match “systemctl is-active” behaviour, at least at systemd 229
 			cur.Active = v == "active" || v == "reloading"
+			cur.Failed = v == "failed"
 		case "UnitFileState":
 			// "static" means it can't be disabled
 			cur.Enabled = v == "enabled" || v == "static"
@@ -885,6 +895,32 @@ func (s *systemd) CurrentMemoryUsage(unit string) (quantity.Size, error) {
 	return quantity.Size(memBytes), nil
 }
 
+func (s *systemd) CurrentCPUUsage(unit string) (time.Duration, error) {
+	cpuNSec, err := s.getPropertyUintValue(unit, "CPUUsageNSec")
+	if err != nil && err != errNotSet {
+		return 0, err
+	}
+
+	if err == errNotSet {
+		return 0, fmt.Errorf("cpu usage unavailable")
+	}
+
+	return time.Duration(cpuNSec), nil
+}
+
+func (s *systemd) RestartsCount(unit string) (uint64, error) {
+	restarts, err := s.getPropertyUintValue(unit, "NRestarts")
+	if err != nil && err != errNotSet {
+		return 0, err
+	}
+
+	if err == errNotSet {
+		return 0, fmt.Errorf("restarts count unavailable")
+	}
+
+	return restarts, nil
+}
+
 func (s *systemd) InactiveEnterTimestamp(unit string) (time.Time, error) {
 	timeStr, err := s.getPropertyStringValue(unit, "InactiveEnterTimestamp")
 	if err != nil {
@@ -1317,6 +1353,17 @@ func (l Log) PID() string {
 	return "-"
 }
 
+// Cursor is the position of the Log in the journal, if any; otherwise, "".
+func (l Log) Cursor() string {
+	cursor, err := l.parseLogRawMessageString("__CURSOR", func([]string) (string, error) {
+		return "", fmt.Errorf("multiple cursors not supported")
+	})
+	if err != nil {
+		return ""
+	}
+	return cursor
+}
+
 type UnitLifetime int
 
 const (
@@ -459,7 +459,7 @@ NeedDaemonReload=no
 Type=potato
 Id=baz.service
 Names=baz.service
-ActiveState=inactive
+ActiveState=failed
 UnitFileState=disabled
 NeedDaemonReload=yes
 
@@ -528,6 +528,7 @@ NeedDaemonReload=yes
 			Name:             "baz.service",
 			Names:            []string{"baz.service"},
 			Active:           false,
+			Failed:           true,
 			Enabled:          false,
 			Installed:        true,
 			Id:               "baz.service",
@@ -1114,6 +1115,12 @@ func (s *SystemdTestSuite) TestLogPID(c *C) {
 	}.PID(), Equals, "42")
 }
 
+func (s *SystemdTestSuite) TestLogCursor(c *C) {
+	c.Check(Log{}.Cursor(), Equals, "")
+	c.Check(Log{"__CURSOR": mustJSONMarshal("s=1234;i=5")}.Cursor(), Equals, "s=1234;i=5")
+	c.Check(Log{"__CURSOR": mustJSONMarshal([]string{"s=1", "s=2"})}.Cursor(), Equals, "")
+}
+
 func (s *SystemdTestSuite) TestTime(c *C) {
 	t, err := Log{}.Time()
 	c.Check(t.IsZero(), Equals, true)
@@ -2024,6 +2031,53 @@ func (s *SystemdTestSuite) TestCurrentUsageFamilyHappy(c *C) {
 	})
 }
 
+func (s *SystemdTestSuite) TestRestartsCount(c *C) {
+	s.outs = [][]byte{
+		[]byte(`NRestarts=3`),
+		[]byte(`NRestarts=[not set]`),
+		[]byte(`NRestarts=blah`),
+		[]byte(``),
+	}
+	sysd := New(SystemMode, s.rep)
+	restarts, err := sysd.RestartsCount("bar.service")
+	c.Assert(err, IsNil)
+	c.Check(restarts, Equals, uint64(3))
+	_, err = sysd.RestartsCount("bar.service")
+	c.Assert(err, ErrorMatches, "restarts count unavailable")
+	_, err = sysd.RestartsCount("bar.service")
+	c.Assert(err, ErrorMatches, `invalid property value from systemd for NRestarts: cannot parse "blah" as an integer`)
+	// systemd before 235 does not know about NRestarts
+	_, err = sysd.RestartsCount("bar.service")
+	c.Assert(err, ErrorMatches, `invalid property format from systemd for NRestarts \(got \)`)
+	c.Check(s.argses, DeepEquals, [][]string{
+		{"show", "--property", "NRestarts", "bar.service"},
+		{"show", "--property", "NRestarts", "bar.service"},
+		{"show", "--property", "NRestarts", "bar.service"},
+		{"show", "--property", "NRestarts", "bar.service"},
+	})
+}
+
+func (s *SystemdTestSuite) TestCurrentCPUUsage(c *C) {
+	s.outs = [][]byte{
+		[]byte(`CPUUsageNSec=1500000000`),
+		[]byte(`CPUUsageNSec=[not set]`),
+		[]byte(`CPUUsageNSec=blah`),
+	}
+	sysd := New(SystemMode, s.rep)
+	cpuUsage, err := sysd.CurrentCPUUsage("bar.slice")
+	c.Assert(err, IsNil)
+	c.Check(cpuUsage, Equals, 1500*time.Millisecond)
+	_, err = sysd.CurrentCPUUsage("bar.slice")
+	c.Assert(err, ErrorMatches, "cpu usage unavailable")
+	_, err = sysd.CurrentCPUUsage("bar.slice")
+	c.Assert(err, ErrorMatches, `invalid property value from systemd for CPUUsageNSec: cannot parse "blah" as an integer`)
+	c.Check(s.argses, DeepEquals, [][]string{
+		{"show", "--property", "CPUUsageNSec", "bar.slice"},
+		{"show", "--property", "CPUUsageNSec", "bar.slice"},
+		{"show", "--property", "CPUUsageNSec", "bar.slice"},
+	})
+}
+
 func (s *SystemdTestSuite) TestInactiveEnterTimestampZero(c *C) {
 	s.outs = [][]byte{
 		[]byte(`InactiveEnterTimestamp=`),
@@ -138,6 +138,42 @@ TasksAccounting=true
 	return buf.String()
 }
 
+func formatIOGroupSlice(grp *quota.Group) string {
+	if grp.IOLimit == nil {
+		return ""
+	}
+
+	header := `
+# Always enable io accounting otherwise the IO settings do nothing.
+IOAccounting=true
+`
+	buf := bytes.NewBufferString(header)
+
+	// the limits apply to the device backing the snap data directory unless
+	// a specific device was requested, systemd resolves paths that are not
+	// device nodes to the backing block device
+	device := grp.IOLimit.Device
+	if device == "" {
+		device = dirs.SnapDataDir
+	}
+	if grp.IOLimit.Weight != 0 {
+		fmt.Fprintf(buf, "IOWeight=%d\n", grp.IOLimit.Weight)
+	}
+	if grp.IOLimit.ReadBandwidth != 0 {
+		fmt.Fprintf(buf, "IOReadBandwidthMax=%s %d\n", device, grp.IOLimit.ReadBandwidth)
+	}
+	if grp.IOLimit.WriteBandwidth != 0 {
+		fmt.Fprintf(buf, "IOWriteBandwidthMax=%s %d\n", device, grp.IOLimit.WriteBandwidth)
+	}
+	if grp.IOLimit.ReadIOPS != 0 {
+		fmt.Fprintf(buf, "IOReadIOPSMax=%s %d\n", device, grp.IOLimit.ReadIOPS)
+	}
+	if grp.IOLimit.WriteIOPS != 0 {
+		fmt.Fprintf(buf, "IOWriteIOPSMax=%s %d\n", device, grp.IOLimit.WriteIOPS)
+	}
+	return buf.String()
+}
+
 // generateGroupSliceFile generates a systemd slice unit definition for the
 // specified quota group.
 func generateGroupSliceFile(grp *quota.Group) []byte {
@@ -146,6 +182,7 @@ func generateGroupSliceFile(grp *quota.Group) []byte {
 	cpuOptions := formatCpuGroupSlice(grp)
 	memoryOptions := formatMemoryGroupSlice(grp)
 	taskOptions := formatTaskGroupSlice(grp)
+	ioOptions := formatIOGroupSlice(grp)
 	template := `[Unit]
 Description=Slice for snap quota group %[1]s
 Before=slices.target
@@ -155,7 +192,7 @@ X-Snappy=yes
 `
 
 	fmt.Fprintf(&buf, template, grp.Name)
-	fmt.Fprint(&buf, cpuOptions, memoryOptions, taskOptions)
+	fmt.Fprint(&buf, cpuOptions, memoryOptions, taskOptions, ioOptions)
 	return buf.Bytes()
 }
 
@@ -322,6 +322,61 @@ TasksMax=%[5]d
 	c.Assert(svcFile, testutil.FileEquals, svcContent)
 }
 
+func (s *servicesTestSuite) TestEnsureSnapServicesWithIOQuotas(c *C) {
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+
+	resourceLimits := quota.NewResourcesBuilder().
+		WithIOReadBandwidth(10 * quantity.SizeMiB).
+		WithIOWriteBandwidth(5 * quantity.SizeMiB).
+		WithIOWriteIOPS(100).
+		WithIOWeight(50).
+		Build()
+	grp, err := quota.NewGroup("foogroup", resourceLimits)
+	c.Assert(err, IsNil)
+
+	m := map[*snap.Info]*wrappers.SnapServiceOptions{
+		info: {QuotaGroup: grp},
+	}
+
+	err = wrappers.EnsureSnapServices(m, nil, nil, progress.Null)
+	c.Assert(err, IsNil)
+
+	// without an explicit device the limits apply to the device backing the
+	// snap data directory
+	sliceFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.foogroup.slice")
+	c.Check(sliceFile, testutil.FileEquals, fmt.Sprintf(`[Unit]
+Description=Slice for snap quota group foogroup
+Before=slices.target
+X-Snappy=yes
+
+[Slice]
+# Always enable cpu accounting, so the following cpu quota options have an effect
+CPUAccounting=true
+
+# Always enable memory accounting otherwise the MemoryMax setting does nothing.
+MemoryAccounting=true
+# Always enable task accounting in order to be able to count the processes/
+# threads, etc for a slice
+TasksAccounting=true
+
+# Always enable io accounting otherwise the IO settings do nothing.
+IOAccounting=true
+IOWeight=50
+IOReadBandwidthMax=%[1]s 10485760
+IOWriteBandwidthMax=%[1]s 5242880
+IOWriteIOPSMax=%[1]s 100
+`, dirs.SnapDataDir))
+
+	// with an explicit device
+	grp.IOLimit.Device = "/dev/mmcblk0"
+	err = wrappers.EnsureSnapServices(m, nil, nil, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(sliceFile, testutil.FileContains, `IOReadBandwidthMax=/dev/mmcblk0 10485760
+IOWriteBandwidthMax=/dev/mmcblk0 5242880
+IOWriteIOPSMax=/dev/mmcblk0 100
+`)
+}
+
 func (s *servicesTestSuite) TestEnsureSnapServicesWithZeroCpuCountQuotas(c *C) {
 	// Kind of a special case, if the cpu count is zero it needs to automatically scale
 	// at the moment of writing the service file to the current number of cpu cores